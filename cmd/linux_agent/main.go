@@ -100,6 +100,20 @@ func (p *agentService) run() {
 		return
 	}
 
+	go func() {
+		err := agent.WatchDrives(p.ctx, func(drives []utils.DriveInfo) {
+			if err := p.sendDrives(drives); err != nil {
+				syslog.L.Error(err).WithMessage("failed to update changed drives").Write()
+			}
+		})
+		if err != nil {
+			syslog.L.Warn().WithMessage("cannot watch for drive changes, relying on periodic re-scans").
+				WithField("error", err.Error()).Write()
+		}
+	}()
+
+	// Periodic re-scans catch changes the watcher misses, such as a volume
+	// label being renamed.
 	go func() {
 		delay := utils.ComputeDelay()
 		for {
@@ -167,14 +181,18 @@ func (p *agentService) waitForBootstrap() error {
 }
 
 func (p *agentService) initializeDrives() error {
-	hostname, err := os.Hostname()
+	drives, err := agent.LocalDrives()
 	if err != nil {
-		return fmt.Errorf("failed to get hostname: %w", err)
+		return fmt.Errorf("failed to get local drives list: %w", err)
 	}
 
-	drives, err := utils.GetLocalDrives()
+	return p.sendDrives(drives)
+}
+
+func (p *agentService) sendDrives(drives []utils.DriveInfo) error {
+	hostname, err := os.Hostname()
 	if err != nil {
-		return fmt.Errorf("failed to get local drives list: %w", err)
+		return fmt.Errorf("failed to get hostname: %w", err)
 	}
 
 	reqBody, err := json.Marshal(&AgentDrivesRequest{
@@ -244,30 +262,24 @@ func (p *agentService) connectARPC() error {
 		return controllers.BackupStartHandler(req, session)
 	})
 	router.Handle("cleanup", controllers.BackupCloseHandler)
+	router.Handle(controllers.LogTailMethod, controllers.LogTailHandler)
 
 	session.SetRouter(router)
 
 	go func() {
 		defer session.Close()
-		for {
-			select {
-			case <-p.ctx.Done():
-				return
-			default:
-				syslog.L.Info().WithMessage("connecting ARPC endpoint from /plus/arpc").Write()
-				if err := session.Serve(); err != nil {
-					store, err := agent.NewBackupStore()
-					if err != nil {
-						syslog.L.Error(err).WithMessage("error initializing backup store").Write()
-					} else {
-						err = store.ClearAll()
-						if err != nil {
-							syslog.L.Error(err).WithMessage("error clearing backup store").Write()
-						}
-					}
+		syslog.L.Info().WithMessage("connecting ARPC endpoint from /plus/arpc").Write()
+		arpc.ServeWithBackoff(p.ctx, controllers.ServeReconnectConfig(), session.Serve, func(error) {
+			store, err := agent.NewBackupStore()
+			if err != nil {
+				syslog.L.Error(err).WithMessage("error initializing backup store").Write()
+			} else {
+				err = store.ClearAll()
+				if err != nil {
+					syslog.L.Error(err).WithMessage("error clearing backup store").Write()
 				}
 			}
-		}
+		})
 	}()
 
 	return nil
@@ -0,0 +1,119 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/backend/backup"
+)
+
+const taskLogsDir = "/var/log/proxmox-backup/tasks"
+
+// patternFlags collects the values of a repeatable flag.
+type patternFlags []string
+
+func (p *patternFlags) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *patternFlags) Set(value string) error {
+	if value == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	*p = append(*p, value)
+	return nil
+}
+
+// cleanTaskLogs runs the clean-task-logs subcommand with the arguments
+// following it.
+func cleanTaskLogs(args []string) {
+	flags := flag.NewFlagSet("clean-task-logs", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Report the lines that would be removed per file without modifying anything")
+	noDefaults := flags.Bool("no-default-patterns", false, "Only remove lines matching the given patterns, not the built-in ones")
+	var patterns patternFlags
+	flags.Var(&patterns, "pattern", "Additional substring of lines to remove (repeatable)")
+	_ = flags.Parse(args)
+
+	var substrings []string
+	if !*noDefaults {
+		substrings = append(substrings, backup.JunkSubstrings...)
+	}
+	substrings = append(substrings, patterns...)
+	if len(substrings) == 0 {
+		log.Fatal("clean-task-logs: no patterns to remove; pass --pattern or drop --no-default-patterns")
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: counting junk lines in %s\n", taskLogsDir)
+	} else {
+		fmt.Println("WARNING: You are about to remove all junk logs recursively from:")
+		fmt.Printf("         %s\n", taskLogsDir)
+	}
+	fmt.Println()
+	fmt.Println("All log entries with the following substrings will be removed if found in any log file:")
+	for _, substr := range substrings {
+		fmt.Printf(" - %s\n", substr)
+	}
+	fmt.Println()
+
+	if !*dryRun {
+		fmt.Println("If this is not what you intend, press Ctrl+C within the next 10 seconds to cancel.")
+		fmt.Println()
+
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt)
+
+		cancelChan := make(chan struct{})
+		go func() {
+			<-sigChan
+			fmt.Println("\nOperation cancelled by user.")
+			close(cancelChan)
+		}()
+
+		for i := 10; i > 0; i-- {
+			select {
+			case <-cancelChan:
+				// User cancelled the operation.
+				return
+			default:
+				fmt.Printf("Proceeding in %d seconds...\n", i)
+				time.Sleep(1 * time.Second)
+			}
+		}
+
+		fmt.Println("Proceeding with log cleanup...")
+	}
+
+	removed, err := backup.RemoveJunkLogsRecursively(taskLogsDir, substrings, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var total int64
+	for _, count := range removed {
+		total += count
+	}
+
+	if !*dryRun {
+		fmt.Printf("Successfully removed %d of junk lines from all task logs files.\n", total)
+		return
+	}
+
+	paths := make([]string, 0, len(removed))
+	for path := range removed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Printf("%s: %d\n", path, removed[path])
+	}
+	fmt.Printf("Would remove %d junk lines from %d task log files.\n", total, len(paths))
+}
@@ -7,18 +7,21 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/auth/certificates"
 	"github.com/sonroyaalmerol/pbs-plus/internal/auth/server"
 	"github.com/sonroyaalmerol/pbs-plus/internal/auth/token"
 	"github.com/sonroyaalmerol/pbs-plus/internal/backend/backup"
+	"github.com/sonroyaalmerol/pbs-plus/internal/backend/metrics"
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy"
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers/agents"
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers/arpc"
@@ -26,6 +29,7 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers/jobs"
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers/plus"
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers/targets"
+	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers/templates"
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers/tokens"
 	mw "github.com/sonroyaalmerol/pbs-plus/internal/proxy/middlewares"
 	rpcmount "github.com/sonroyaalmerol/pbs-plus/internal/proxy/rpc"
@@ -33,6 +37,7 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/proxmox"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/system"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 
 	"net/http/pprof"
@@ -58,46 +63,29 @@ func main() {
 	argsWithoutProg := os.Args[1:]
 
 	if len(argsWithoutProg) > 0 && argsWithoutProg[0] == "clean-task-logs" {
-		fmt.Println("WARNING: You are about to remove all junk logs recursively from:")
-		fmt.Println("         /var/log/proxmox-backup/tasks")
-		fmt.Println()
-		fmt.Println("All log entries with the following substrings will be removed if found in any log file:")
-		for _, substr := range backup.JunkSubstrings {
-			fmt.Printf(" - %s\n", substr)
-		}
-		fmt.Println()
-		fmt.Println("If this is not what you intend, press Ctrl+C within the next 10 seconds to cancel.")
-		fmt.Println()
-
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt)
-
-		cancelChan := make(chan struct{})
-		go func() {
-			<-sigChan
-			fmt.Println("\nOperation cancelled by user.")
-			close(cancelChan)
-		}()
-
-		for i := 10; i > 0; i-- {
-			select {
-			case <-cancelChan:
-				// User cancelled the operation.
-				return
-			default:
-				fmt.Printf("Proceeding in %d seconds...\n", i)
-				time.Sleep(1 * time.Second)
-			}
-		}
+		cleanTaskLogs(argsWithoutProg[1:])
+		return
+	}
 
-		fmt.Println("Proceeding with log cleanup...")
+	if len(argsWithoutProg) > 0 && argsWithoutProg[0] == "validate-config" {
+		validateConfig(argsWithoutProg[1:])
+		return
+	}
 
-		removed, err := backup.RemoveJunkLogsRecursively("/var/log/proxmox-backup/tasks")
+	// The server takes the instance lock before it opens the store, so a
+	// second server never runs migrations against the database of the
+	// first. Job runs are started by the server and share its store.
+	if *jobRun == "" {
+		instanceLock, err := system.AcquireInstanceLock(constants.InstanceLockPath)
 		if err != nil {
-			log.Fatal(err)
+			syslog.L.Error(err).WithMessage("refusing to start a second server instance").Write()
+			fmt.Fprintln(os.Stderr, err)
+			// Nothing but the main context is set up yet; cancel it
+			// since os.Exit skips deferred calls.
+			mainCancel()
+			os.Exit(1)
 		}
-		fmt.Printf("Successfully removed %d of junk lines from all task logs files.\n", removed)
-		return
+		defer instanceLock.Close()
 	}
 
 	storeInstance, err := store.Initialize(mainCtx, nil)
@@ -124,53 +112,69 @@ func main() {
 			return
 		}
 
+		if !jobTask.Enabled {
+			syslog.L.Warn().WithMessage("job is disabled, not running it").WithField("jobId", jobTask.ID).Write()
+			fmt.Fprintf(os.Stderr, "job %s is disabled; enable it to run it\n", jobTask.ID)
+			return
+		}
+
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
 		if retryAttempts == nil || *retryAttempts == "" {
 			system.RemoveAllRetrySchedules(jobTask)
+		} else if attempt, err := strconv.Atoi(*retryAttempts); err == nil {
+			jobTask.RetryAttempt = attempt
 		}
 
+		started := time.Now()
 		op, err := backup.RunBackup(ctx, jobTask, storeInstance, true)
 		if err != nil {
 			syslog.L.Error(err).WithField("jobId", jobTask.ID).Write()
 
-			if !errors.Is(err, backup.ErrOneInstance) {
-				if task, err := proxmox.GenerateTaskErrorFile(jobTask, err, []string{"Error handling from a scheduled job run request", "Job ID: " + jobTask.ID, "Source Mode: " + jobTask.SourceMode}); err != nil {
+			if !errors.Is(err, backup.ErrOneInstance) && !backup.DeferRun(storeInstance, jobTask, err) {
+				var upid string
+				if task, err := proxmox.GenerateTaskErrorFile(jobTask, err, append([]string{"Error handling from a scheduled job run request", "Job ID: " + jobTask.ID, "Source Mode: " + jobTask.SourceMode}, backup.TaskLogLines(err)...)); err != nil {
 					syslog.L.Error(err).WithField("jobId", jobTask.ID).Write()
 				} else {
-					// Update job status
-					latestJob, err := storeInstance.Database.GetJob(jobTask.ID)
-					if err != nil {
-						latestJob = jobTask
-					}
-
-					latestJob.LastRunUpid = task.UPID
-					latestJob.LastRunState = task.Status
-					latestJob.LastRunEndtime = task.EndTime
+					upid = task.UPID
 
-					err = storeInstance.Database.UpdateJob(nil, latestJob)
+					// Update job status
+					err = storeInstance.Database.UpdateJobStatus(nil, jobTask.ID, types.JobStatus{LastRunUpid: task.UPID})
 					if err != nil {
-						syslog.L.Error(err).WithField("jobId", latestJob.ID).WithField("upid", task.UPID).Write()
+						syslog.L.Error(err).WithField("jobId", jobTask.ID).WithField("upid", task.UPID).Write()
 					}
 				}
-				if err := system.SetRetrySchedule(jobTask); err != nil {
+				if _, err := backup.ScheduleRetry(jobTask, err); err != nil {
 					syslog.L.Error(err).WithField("jobId", jobTask.ID).Write()
 				}
+				backup.NotifyRunError(ctx, jobTask, started, upid, err)
 			}
+
+			return
 		}
 
 		if waitErr := op.Wait(); waitErr != nil {
-			syslog.L.Error(waitErr).Write()
+			syslog.L.Error(waitErr).
+				WithField("jobId", jobTask.ID).
+				WithField("retryable", backup.IsRetryable(waitErr)).
+				Write()
 		}
 
 		return
 	}
 
-	if err = storeInstance.MigrateLegacyData(); err != nil {
+	migration, err := storeInstance.MigrateLegacyData()
+	if err != nil {
 		syslog.L.Error(err).WithMessage("error migrating legacy database").Write()
 		return
 	}
+	if len(migration.Steps) > 0 {
+		syslog.L.Info().
+			WithMessage("legacy database migration finished").
+			WithField("report", migration.String()).
+			Write()
+	}
 
 	if err := proxy.ModifyPBSJavascript(); err != nil {
 		syslog.L.Error(err).WithMessage("failed to mount modified proxmox-backup-gui.js").Write()
@@ -178,6 +182,9 @@ func main() {
 	}
 
 	certOpts := certificates.DefaultOptions()
+	if err := certOpts.ApplyRenewalEnv(os.Getenv); err != nil {
+		syslog.L.Error(err).WithMessage("ignoring certificate renewal settings, using defaults").Write()
+	}
 	generator, err := certificates.NewGenerator(certOpts)
 	if err != nil {
 		syslog.L.Error(err).WithMessage("failed to initialize certificate generator").Write()
@@ -195,18 +202,12 @@ func main() {
 	serverConfig.KeyFile = filepath.Join(certOpts.OutputDir, "server.key")
 	serverConfig.CAFile = filepath.Join(certOpts.OutputDir, "ca.crt")
 	serverConfig.CAKey = filepath.Join(certOpts.OutputDir, "ca.key")
+	serverConfig.CRLFile = filepath.Join(certOpts.OutputDir, certificates.RevocationFileName)
 	serverConfig.TokenSecret = string(csrfKey)
 
-	if err := generator.ValidateExistingCerts(); err != nil {
-		if err := generator.GenerateCA(); err != nil {
-			syslog.L.Error(err).WithMessage("failed to generate certificate").Write()
-			return
-		}
-
-		if err := generator.GenerateCert("server"); err != nil {
-			syslog.L.Error(err).WithMessage("failed to generate certificate").Write()
-			return
-		}
+	if err := renewCertificates(generator); err != nil {
+		syslog.L.Error(err).WithMessage("failed to generate certificate").Write()
+		return
 	}
 
 	if err := serverConfig.Validate(); err != nil {
@@ -216,13 +217,13 @@ func main() {
 
 	storeInstance.CertGenerator = generator
 
-	err = os.Chown(serverConfig.KeyFile, 0, 34)
+	err = os.Chown(serverConfig.KeyFile, server.ProxyFileUID, server.ProxyFileGID)
 	if err != nil {
 		syslog.L.Error(err).WithMessage("failed to change cert key permissions").Write()
 		return
 	}
 
-	err = os.Chown(serverConfig.CertFile, 0, 34)
+	err = os.Chown(serverConfig.CertFile, server.ProxyFileUID, server.ProxyFileGID)
 	if err != nil {
 		syslog.L.Error(err).WithMessage("failed to change cert permissions").Write()
 		return
@@ -245,6 +246,7 @@ func main() {
 	tokenManager, err := token.NewManager(token.Config{
 		TokenExpiration: serverConfig.TokenExpiration,
 		SecretKey:       serverConfig.TokenSecret,
+		RevocationFile:  constants.TokenRevocationFile,
 	})
 	if err != nil {
 		syslog.L.Error(err).WithMessage("failed to initialize token manager").Write()
@@ -265,21 +267,44 @@ func main() {
 			select {
 			case <-caRenewalCtx.Done():
 				return
-			case <-time.After(time.Hour):
-				if err := generator.ValidateExistingCerts(); err != nil {
-					if err := generator.GenerateCA(); err != nil {
-						syslog.L.Error(err).WithMessage("failed to generate CA").Write()
-					}
-
-					if err := generator.GenerateCert("server"); err != nil {
-						syslog.L.Error(err).WithMessage("failed to generate server certificate").Write()
-					}
+			case <-time.After(certOpts.RenewalInterval):
+				if err := renewCertificates(generator); err != nil {
+					syslog.L.Error(err).WithMessage("failed to renew certificates").Write()
 				}
+			}
+		}
+	}()
 
+	go func() {
+		ticker := time.NewTicker(serverConfig.MountCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-caRenewalCtx.Done():
+				return
+			case <-ticker.C:
+				drift, err := serverConfig.VerifyMount()
+				for path, problems := range drift {
+					syslog.L.Warn().
+						WithMessage("certificate mount drifted, re-applied").
+						WithField("path", path).
+						WithField("drift", strings.Join(problems, "; ")).
+						Write()
+				}
+				if err != nil {
+					syslog.L.Error(err).WithMessage("failed to repair certificate mount").Write()
+				}
 			}
 		}
 	}()
 
+	taskLogCleaner, err := backup.TaskLogCleanerConfigFromEnv(os.Getenv)
+	if err != nil {
+		syslog.L.Error(err).WithMessage("task log cleaner disabled").Write()
+	} else if taskLogCleaner.Enabled {
+		go backup.RunTaskLogCleaner(caRenewalCtx, taskLogsDir, taskLogCleaner)
+	}
+
 	// Unmount and remove all stale mount points
 	// Get all mount points under the base path
 	mountPoints, err := filepath.Glob(filepath.Join(constants.AgentMountBasePath, "*"))
@@ -323,45 +348,70 @@ func main() {
 	}()
 
 	mux := http.NewServeMux()
+	router := mw.NewRouter(storeInstance, mux)
 
 	// API routes
-	mux.HandleFunc("/plus/token", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, plus.TokenHandler(storeInstance))))
-	mux.HandleFunc("/api2/json/plus/version", mw.AgentOrServer(storeInstance, mw.CORS(storeInstance, plus.VersionHandler(storeInstance, Version))))
-	mux.HandleFunc("/api2/json/plus/binary", mw.CORS(storeInstance, plus.DownloadBinary(storeInstance, Version)))
-	mux.HandleFunc("/api2/json/plus/updater-binary", mw.CORS(storeInstance, plus.DownloadUpdater(storeInstance, Version)))
-	mux.HandleFunc("/api2/json/plus/binary/checksum", mw.AgentOrServer(storeInstance, mw.CORS(storeInstance, plus.DownloadChecksum(storeInstance, Version))))
-	mux.HandleFunc("/api2/json/d2d/backup", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, jobs.D2DJobHandler(storeInstance))))
-	mux.HandleFunc("/api2/json/d2d/target", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, targets.D2DTargetHandler(storeInstance))))
-	mux.HandleFunc("/api2/json/d2d/target/agent", mw.AgentOnly(storeInstance, mw.CORS(storeInstance, targets.D2DTargetAgentHandler(storeInstance))))
-	mux.HandleFunc("/api2/json/d2d/token", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, tokens.D2DTokenHandler(storeInstance))))
-	mux.HandleFunc("/api2/json/d2d/exclusion", mw.AgentOrServer(storeInstance, mw.CORS(storeInstance, exclusions.D2DExclusionHandler(storeInstance))))
-	mux.HandleFunc("/api2/json/d2d/agent-log", mw.AgentOnly(storeInstance, mw.CORS(storeInstance, agents.AgentLogHandler(storeInstance))))
+	router.HandleFunc("/plus/token", mw.PolicyServer, mw.CORS(storeInstance, plus.TokenHandler(storeInstance)))
+	router.HandleFunc("/api2/json/plus/version", mw.PolicyAgentOrServer, mw.CORS(storeInstance, plus.VersionHandler(storeInstance, Version)))
+	router.HandleFunc("/api2/json/plus/binary", mw.PolicyPublic, mw.CORS(storeInstance, plus.DownloadBinary(storeInstance, Version)))
+	router.HandleFunc("/api2/json/plus/binary/linux", mw.PolicyPublic, mw.CORS(storeInstance, plus.DownloadLinuxBinary(storeInstance, Version)))
+	router.HandleFunc("/api2/json/plus/updater-binary", mw.PolicyPublic, mw.CORS(storeInstance, plus.DownloadUpdater(storeInstance, Version)))
+	router.HandleFunc("/api2/json/plus/binary/checksum", mw.PolicyAgentOrServer, mw.CORS(storeInstance, plus.DownloadChecksum(storeInstance, Version)))
+	router.HandleFunc("/metrics", mw.PolicyServer, metrics.Handler(storeInstance.Database.GetAllJobs))
+	router.HandleFunc("/api2/json/plus/health", mw.PolicyServer, mw.CORS(storeInstance, plus.HealthHandler(storeInstance)))
+	router.HandleFunc("/api2/json/plus/config/orphans", mw.PolicyServer, mw.CORS(storeInstance, plus.OrphansHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/backup", mw.PolicyServer, mw.CORS(storeInstance, jobs.D2DJobHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/backup/export", mw.PolicyServer, mw.CORS(storeInstance, jobs.D2DJobExportHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/backup/import", mw.PolicyServer, mw.CORS(storeInstance, jobs.D2DJobImportHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/backup/plan", mw.PolicyServer, mw.CORS(storeInstance, jobs.D2DJobPlanHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/backup/{job}/effective-filters", mw.PolicyServer, mw.CORS(storeInstance, jobs.D2DJobEffectiveFiltersHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/backup/{job}/manifest", mw.PolicyServer, mw.CORS(storeInstance, jobs.D2DJobManifestHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/target", mw.PolicyServer, mw.CORS(storeInstance, targets.D2DTargetHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/target/agent", mw.PolicyAgent, mw.CORS(storeInstance, targets.D2DTargetAgentHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/token", mw.PolicyServer, mw.CORS(storeInstance, tokens.D2DTokenHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/exclusion", mw.PolicyAgentOrServer, mw.CORS(storeInstance, exclusions.D2DExclusionHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/job-template", mw.PolicyServer, mw.CORS(storeInstance, templates.D2DJobTemplateHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/agent-log", mw.PolicyAgent, mw.CORS(storeInstance, agents.AgentLogHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/agent-revoke", mw.PolicyServer, mw.CORS(storeInstance, agents.AgentRevokeHandler(storeInstance)))
+	router.HandleFunc("/api2/json/d2d/agent/{hostname}/logs", mw.PolicyServer, mw.CORS(storeInstance, agents.AgentLogTailHandler(storeInstance)))
 
 	// ExtJS routes with path parameters
-	mux.HandleFunc("/api2/extjs/d2d/backup/{job}", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, jobs.ExtJsJobRunHandler(storeInstance))))
-	mux.HandleFunc("/api2/extjs/config/d2d-target", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, targets.ExtJsTargetHandler(storeInstance))))
-	mux.HandleFunc("/api2/extjs/config/d2d-target/{target}", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, targets.ExtJsTargetSingleHandler(storeInstance))))
-	mux.HandleFunc("/api2/extjs/config/d2d-token", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, tokens.ExtJsTokenHandler(storeInstance))))
-	mux.HandleFunc("/api2/extjs/config/d2d-token/{token}", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, tokens.ExtJsTokenSingleHandler(storeInstance))))
-	mux.HandleFunc("/api2/extjs/config/d2d-exclusion", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, exclusions.ExtJsExclusionHandler(storeInstance))))
-	mux.HandleFunc("/api2/extjs/config/d2d-exclusion/{exclusion}", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, exclusions.ExtJsExclusionSingleHandler(storeInstance))))
-	mux.HandleFunc("/api2/extjs/config/disk-backup-job", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, jobs.ExtJsJobHandler(storeInstance))))
-	mux.HandleFunc("/api2/extjs/config/disk-backup-job/{job}", mw.ServerOnly(storeInstance, mw.CORS(storeInstance, jobs.ExtJsJobSingleHandler(storeInstance))))
+	router.HandleFunc("/api2/extjs/d2d/backup/{job}", mw.PolicyServer, mw.CORS(storeInstance, jobs.ExtJsJobRunHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/d2d/backup/{job}/now", mw.PolicyServer, mw.CORS(storeInstance, jobs.ExtJsJobRunNowHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/d2d/backup-tag/{tag}", mw.PolicyServer, mw.CORS(storeInstance, jobs.ExtJsJobTagHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/d2d-target", mw.PolicyServer, mw.CORS(storeInstance, targets.ExtJsTargetHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/d2d-target/{target}", mw.PolicyServer, mw.CORS(storeInstance, targets.ExtJsTargetSingleHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/d2d-target/{target}/test", mw.PolicyServer, mw.CORS(storeInstance, targets.ExtJsTargetTestHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/d2d-token", mw.PolicyServer, mw.CORS(storeInstance, tokens.ExtJsTokenHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/d2d-token/{token}", mw.PolicyServer, mw.CORS(storeInstance, tokens.ExtJsTokenSingleHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/d2d-exclusion", mw.PolicyServer, mw.CORS(storeInstance, exclusions.ExtJsExclusionHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/d2d-exclusion/{exclusion}", mw.PolicyServer, mw.CORS(storeInstance, exclusions.ExtJsExclusionSingleHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/disk-backup-job", mw.PolicyServer, mw.CORS(storeInstance, jobs.ExtJsJobHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/disk-backup-job/{job}", mw.PolicyServer, mw.CORS(storeInstance, jobs.ExtJsJobSingleHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/d2d-job-template", mw.PolicyServer, mw.CORS(storeInstance, templates.ExtJsJobTemplateHandler(storeInstance)))
+	router.HandleFunc("/api2/extjs/config/d2d-job-template/{template}", mw.PolicyServer, mw.CORS(storeInstance, templates.ExtJsJobTemplateSingleHandler(storeInstance)))
 
 	// aRPC route
-	mux.HandleFunc("/plus/arpc", mw.AgentOnly(storeInstance, arpc.ARPCHandler(storeInstance)))
+	router.HandleFunc("/plus/arpc", mw.PolicyAgent, arpc.ARPCHandler(storeInstance))
 
 	// Agent auth routes
-	mux.HandleFunc("/plus/agent/bootstrap", mw.CORS(storeInstance, agents.AgentBootstrapHandler(storeInstance)))
-	mux.HandleFunc("/plus/agent/renew", mw.AgentOnly(storeInstance, mw.CORS(storeInstance, agents.AgentRenewHandler(storeInstance))))
-	mux.HandleFunc("/plus/agent/install/win", mw.CORS(storeInstance, plus.AgentInstallScriptHandler(storeInstance, Version)))
-
-	// pprof routes
-	mux.HandleFunc("/debug/pprof/", pprof.Index)
-	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	router.HandleFunc("/plus/agent/bootstrap", mw.PolicyPublic, mw.CORS(storeInstance, agents.AgentBootstrapHandler(storeInstance)))
+	router.HandleFunc("/plus/agent/renew", mw.PolicyAgent, mw.CORS(storeInstance, agents.AgentRenewHandler(storeInstance)))
+	router.HandleFunc("/plus/agent/install/win", mw.PolicyPublic, mw.CORS(storeInstance, plus.AgentInstallScriptHandler(storeInstance, Version)))
+	router.HandleFunc("/plus/agent/install/linux", mw.PolicyPublic, mw.CORS(storeInstance, plus.AgentInstallLinuxScriptHandler(storeInstance, Version)))
+
+	// pprof routes expose the command line and heap contents, so they take
+	// server credentials like the rest of the API.
+	router.HandleFunc("/debug/pprof/", mw.PolicyServer, pprof.Index)
+	router.HandleFunc("/debug/pprof/cmdline", mw.PolicyServer, pprof.Cmdline)
+	router.HandleFunc("/debug/pprof/profile", mw.PolicyServer, pprof.Profile)
+	router.HandleFunc("/debug/pprof/symbol", mw.PolicyServer, pprof.Symbol)
+	router.HandleFunc("/debug/pprof/trace", mw.PolicyServer, pprof.Trace)
+
+	if err := router.Validate(); err != nil {
+		syslog.L.Error(err).WithMessage("refusing to start proxy server").Write()
+		return
+	}
 
 	server := &http.Server{
 		Addr:           serverConfig.Address,
@@ -373,8 +423,79 @@ func main() {
 		MaxHeaderBytes: serverConfig.MaxHeaderBytes,
 	}
 
+	// On SIGTERM, stop accepting connections and let the calls agents are
+	// in the middle of finish before exiting.
+	drained := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		defer close(drained)
+		<-signals
+
+		syslog.L.Info().WithMessage("shutting down, draining agent sessions").Write()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			syslog.L.Error(err).WithMessage("failed to shut down http server").Write()
+		}
+		if err := storeInstance.ARPCSessionManager.Shutdown(ctx); err != nil {
+			syslog.L.Error(err).WithMessage("failed to drain agent sessions").Write()
+		}
+		rpcCancel()
+	}()
+
 	syslog.L.Info().WithMessage("starting proxy server on :8008").Write()
 	if err := server.ListenAndServeTLS(serverConfig.CertFile, serverConfig.KeyFile); err != nil {
+		if errors.Is(err, http.ErrServerClosed) {
+			<-drained
+			return
+		}
 		syslog.L.Error(err).WithMessage("http server failed")
 	}
 }
+
+// renewCertificates regenerates what InspectCerts reports as missing,
+// invalid or about to expire: the server certificate alone whenever the CA
+// is still usable, and the CA, which forces every agent to re-bootstrap,
+// only when it is not.
+func renewCertificates(generator *certificates.Generator) error {
+	report := generator.InspectCerts()
+	for _, status := range []certificates.CertStatus{report.CA, report.CAKey, report.Server} {
+		switch {
+		case status.Missing:
+			syslog.L.Warn().WithMessage("certificate file missing").WithField("path", status.Path).Write()
+		case status.Err != nil:
+			syslog.L.Warn().WithMessage("certificate file invalid").
+				WithField("path", status.Path).
+				WithField("error", status.Err.Error()).
+				Write()
+		case status.Expiring:
+			syslog.L.Info().WithMessage("certificate expiring soon").
+				WithField("path", status.Path).
+				WithField("days_left", status.DaysUntilExpiry).
+				Write()
+		}
+	}
+
+	switch report.Action {
+	case certificates.RenewalServerCert:
+		syslog.L.Info().WithMessage("renewing server certificate").WithField("reason", report.Reason.Error()).Write()
+	case certificates.RenewalCA:
+		syslog.L.Error(report.Reason).
+			WithMessage("CA certificate is invalid; regenerating CA, all agents must be re-bootstrapped").
+			Write()
+		if err := generator.GenerateCA(); err != nil {
+			return fmt.Errorf("failed to generate CA: %w", err)
+		}
+	case certificates.RenewalBlocked:
+		return fmt.Errorf("%w; fix the file or remove it to regenerate the CA", report.Reason)
+	default:
+		return nil
+	}
+
+	if err := generator.GenerateCert("server"); err != nil {
+		return fmt.Errorf("failed to generate server certificate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/sqlite"
+)
+
+// validateConfig runs the validate-config subcommand with the arguments
+// following it and exits with its status.
+func validateConfig(args []string) {
+	os.Exit(runValidateConfig(args, os.Stdout, os.Stderr))
+}
+
+// runValidateConfig prints a pass/fail line per job, target, exclusion and
+// inclusion stored in the database and returns the exit status: 0 when every
+// record is valid, 1 when any is not and 2 on usage or I/O errors.
+func runValidateConfig(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dbPath := flags.String("db", sqlite.DefaultPath, "SQLite database holding the configuration")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err := sqlite.ValidateStore(*dbPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "validate-config: %v\n", err)
+		return 2
+	}
+
+	for _, record := range report.Records {
+		if record.Err != nil {
+			fmt.Fprintf(stdout, "FAIL %s %s: %v\n", record.Kind, record.ID, record.Err)
+			continue
+		}
+		fmt.Fprintf(stdout, "ok   %s %s\n", record.Kind, record.ID)
+	}
+
+	failed := report.Failed()
+	fmt.Fprintf(stdout, "\n%d records checked, %d failed\n", len(report.Records), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
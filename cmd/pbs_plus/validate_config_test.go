@@ -0,0 +1,43 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunValidateConfig(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "plus.db")
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, runValidateConfig([]string{"-db", dbPath}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "validate-config:")
+
+	_, err := sqlite.Initialize(dbPath)
+	require.NoError(t, err)
+
+	stdout.Reset()
+	assert.Equal(t, 0, runValidateConfig([]string{"-db", dbPath}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "ok   exclusion ")
+	assert.Contains(t, stdout.String(), ", 0 failed")
+
+	db, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`INSERT INTO targets (name, path) VALUES ('relative', 'mnt/nas')`)
+	require.NoError(t, err)
+
+	stdout.Reset()
+	assert.Equal(t, 1, runValidateConfig([]string{"-db", dbPath}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "FAIL target relative: invalid target path")
+	assert.Contains(t, stdout.String(), ", 1 failed")
+
+	assert.Equal(t, 2, runValidateConfig([]string{"-unknown"}, &stdout, &stderr))
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/kardianos/service"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/forks"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
@@ -133,6 +134,19 @@ func main() {
 	}
 	prg.svc = s
 
+	// Configuration commands are meant to run next to the service, so they
+	// are handled before the single instance mutex is taken.
+	if len(os.Args) > 1 {
+		if handled, err := handleConfigCommands(s, os.Args[1:]); handled {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				syslog.L.Error(err).WithMessage("failed to handle command").Write()
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	if err := createMutex(); err != nil {
 		syslog.L.Error(err).Write()
 		os.Exit(1)
@@ -183,16 +197,6 @@ func handleServiceCommands(s service.Service, cmd string) error {
 		if err != nil {
 			return fmt.Errorf("failed to %s service: %v", cmd, err)
 		}
-	// case "--set-server-url":
-	// 	if !isAdmin() {
-	// 		return fmt.Errorf("needs to be running as administrator")
-	// 	}
-	// 	if len(os.Args) > 2 {
-	// 		serverUrl := os.Args[2]
-	// 		if err := setServerURLAdmin(serverUrl); err != nil {
-	// 			return fmt.Errorf("error setting server URL: %v", err)
-	// 		}
-	// 	}
 	default:
 		err := service.Control(s, cmd)
 		if err != nil {
@@ -202,6 +206,50 @@ func handleServiceCommands(s service.Service, cmd string) error {
 	return nil
 }
 
+// handleConfigCommands runs the commands reading or writing the agent
+// configuration. It reports false when args is not one of them.
+func handleConfigCommands(s service.Service, args []string) (bool, error) {
+	switch args[0] {
+	case "set-server-url", "--set-server-url":
+		if !isAdmin() {
+			return true, fmt.Errorf("needs to be running as administrator")
+		}
+		if len(args) < 2 {
+			return true, fmt.Errorf("usage: %s set-server-url <url>", filepath.Base(os.Args[0]))
+		}
+		if err := agent.SetServerURL(agent.SystemRegistry, args[1]); err != nil {
+			return true, fmt.Errorf("error setting server URL: %w", err)
+		}
+		if err := reloadService(s); err != nil {
+			return true, fmt.Errorf("server URL set, but failed to restart the service: %w", err)
+		}
+		fmt.Println("Server URL set.")
+	case "get-server-url", "--get-server-url":
+		serverURL, err := agent.GetServerURL(agent.SystemRegistry)
+		if err != nil {
+			return true, err
+		}
+		fmt.Println(serverURL)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// reloadService restarts the service if it is running, so it connects to the
+// server URL just written. A stopped service reads it when it starts.
+func reloadService(s service.Service) error {
+	status, err := s.Status()
+	if err != nil || status != service.StatusRunning {
+		return nil
+	}
+	return service.Control(s, "restart")
+}
+
+func isAdmin() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
 func createMutex() error {
 	mutex.Lock()
 	defer mutex.Unlock()
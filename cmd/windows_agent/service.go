@@ -22,6 +22,7 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/controllers"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/registry"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
@@ -86,6 +87,19 @@ func (p *agentService) Start(s service.Service) error {
 	if err != nil {
 		syslog.L.Error(err).WithMessage("error initializing backup store").Write()
 	} else {
+		// Backups run in their own processes and can outlive the service,
+		// so only the snapshots of jobs no longer running are released.
+		released, err := snapshots.ReleaseLeftoverSnapshots(func(jobId string) bool {
+			active, err := store.HasActiveBackupForJob(jobId)
+			return active || err != nil
+		}, snapshots.DefaultLeftoverSnapshotAge)
+		if err != nil {
+			syslog.L.Error(err).WithMessage("error releasing leftover snapshots").Write()
+		}
+		if len(released) > 0 {
+			syslog.L.Warn().WithMessage("released snapshots left by a previous run").WithField("jobs", released).Write()
+		}
+
 		err = store.ClearAll()
 		if err != nil {
 			syslog.L.Error(err).WithMessage("error clearing backup store").Write()
@@ -124,6 +138,20 @@ func (p *agentService) run() {
 		return
 	}
 
+	go func() {
+		err := agent.WatchDrives(p.ctx, func(drives []utils.DriveInfo) {
+			if err := p.sendDrives(drives); err != nil {
+				syslog.L.Error(err).WithMessage("failed to update changed drives").Write()
+			}
+		})
+		if err != nil {
+			syslog.L.Warn().WithMessage("cannot watch for drive changes, relying on periodic re-scans").
+				WithField("error", err.Error()).Write()
+		}
+	}()
+
+	// Periodic re-scans catch changes the watcher misses, such as a volume
+	// label being renamed.
 	go func() {
 		delay := utils.ComputeDelay()
 		for {
@@ -191,14 +219,18 @@ func (p *agentService) waitForBootstrap() error {
 }
 
 func (p *agentService) initializeDrives() error {
-	hostname, err := os.Hostname()
+	drives, err := agent.LocalDrives()
 	if err != nil {
-		return fmt.Errorf("failed to get hostname: %w", err)
+		return fmt.Errorf("failed to get local drives list: %w", err)
 	}
 
-	drives, err := utils.GetLocalDrives()
+	return p.sendDrives(drives)
+}
+
+func (p *agentService) sendDrives(drives []utils.DriveInfo) error {
+	hostname, err := os.Hostname()
 	if err != nil {
-		return fmt.Errorf("failed to get local drives list: %w", err)
+		return fmt.Errorf("failed to get hostname: %w", err)
 	}
 
 	reqBody, err := json.Marshal(&AgentDrivesRequest{
@@ -292,30 +324,24 @@ func (p *agentService) connectARPC() error {
 		return controllers.BackupStartHandler(req, session)
 	})
 	router.Handle("cleanup", controllers.BackupCloseHandler)
+	router.Handle(controllers.LogTailMethod, controllers.LogTailHandler)
 
 	session.SetRouter(router)
 
 	go func() {
 		defer session.Close()
-		for {
-			select {
-			case <-p.ctx.Done():
-				return
-			default:
-				syslog.L.Info().WithMessage("connecting arpc endpoing from /plus/arpc").Write()
-				if err := session.Serve(); err != nil {
-					store, err := agent.NewBackupStore()
-					if err != nil {
-						syslog.L.Error(err).WithMessage("error initializing backup store").Write()
-					} else {
-						err = store.ClearAll()
-						if err != nil {
-							syslog.L.Error(err).WithMessage("error clearing backup store").Write()
-						}
-					}
+		syslog.L.Info().WithMessage("connecting arpc endpoing from /plus/arpc").Write()
+		arpc.ServeWithBackoff(p.ctx, controllers.ServeReconnectConfig(), session.Serve, func(error) {
+			store, err := agent.NewBackupStore()
+			if err != nil {
+				syslog.L.Error(err).WithMessage("error initializing backup store").Write()
+			} else {
+				err = store.ClearAll()
+				if err != nil {
+					syslog.L.Error(err).WithMessage("error clearing backup store").Write()
 				}
 			}
-		}
+		})
 	}()
 
 	return nil
@@ -16,6 +16,7 @@ import (
 	"github.com/alexflint/go-filemutex"
 	"github.com/kardianos/service"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/registry"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 	"golang.org/x/sys/windows"
 )
@@ -31,8 +32,6 @@ type VersionResp struct {
 	Version string `json:"version"`
 }
 
-const updateCheckInterval = 2 * time.Minute
-
 var (
 	mutex  sync.Mutex
 	handle windows.Handle
@@ -57,8 +56,19 @@ func (u *UpdaterService) Stop(s service.Service) error {
 	return nil
 }
 
+// loadSchedule reads the update schedule. Invalid settings are logged and
+// replaced by their defaults.
+func (u *UpdaterService) loadSchedule() agent.UpdateSchedule {
+	schedule, err := agent.LoadUpdateSchedule(agent.SystemRegistry)
+	if err != nil {
+		syslog.L.Error(err).WithMessage("invalid update schedule, using defaults").Write()
+	}
+	return schedule
+}
+
 func (u *UpdaterService) runUpdateCheck() {
-	ticker := time.NewTicker(updateCheckInterval)
+	schedule := u.loadSchedule()
+	ticker := time.NewTicker(schedule.Interval)
 	defer ticker.Stop()
 
 	checkAndUpdate := func() {
@@ -78,6 +88,11 @@ func (u *UpdaterService) runUpdateCheck() {
 			return
 		}
 
+		// Agents installed on the manual channel are only updated by hand.
+		if channel, err := registry.GetEntry(registry.CONFIG, "UpdateChannel", false); err == nil && channel != nil && channel.Value == "manual" {
+			return
+		}
+
 		newVersion, err := u.checkForNewVersion()
 		if err != nil {
 			syslog.L.Error(err).WithMessage("failed to check version").Write()
@@ -94,6 +109,11 @@ func (u *UpdaterService) runUpdateCheck() {
 				WithFields(map[string]interface{}{"new": newVersion, "current": mainVersion}).
 				Write()
 
+			if !schedule.UpdateAllowed(time.Now()) {
+				syslog.L.Info().WithMessage("postponing update until the maintenance window").Write()
+				return
+			}
+
 			// Double-check before updating
 			hasActiveBackups, _ = u.checkForActiveBackups()
 			if hasActiveBackups {
@@ -123,6 +143,9 @@ func (u *UpdaterService) runUpdateCheck() {
 		case <-u.ctx.Done():
 			return
 		case <-ticker.C:
+			// The schedule is read again so changes apply without a restart.
+			schedule = u.loadSchedule()
+			ticker.Reset(schedule.Interval)
 			checkAndUpdate()
 		}
 	}
@@ -3,8 +3,6 @@
 package main
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,6 +13,7 @@ import (
 	"time"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 )
 
 const (
@@ -23,6 +22,11 @@ const (
 	mainBinaryName   = "pbs-plus-agent.exe"
 	maxUpdateRetries = 3
 	updateRetryDelay = 5 * time.Second
+
+	// The binary replaced by an update is kept as pbs-plus-agent.exe.prev
+	// for this long, to roll back to.
+	previousBinarySuffix    = ".prev"
+	previousBinaryRetention = 48 * time.Hour
 )
 
 func (u *UpdaterService) getMainServiceVersion() (string, error) {
@@ -62,32 +66,20 @@ func (p *UpdaterService) ensureTempDir() (string, error) {
 	return tempDir, nil
 }
 
-func (p *UpdaterService) downloadAndVerifyMD5() (string, error) {
+// downloadChecksum fetches the checksum published for the binary served by
+// the server.
+func (p *UpdaterService) downloadChecksum() (string, error) {
 	resp, err := agent.ProxmoxHTTPRequest(http.MethodGet, "/api2/json/plus/binary/checksum", nil, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to download MD5: %w", err)
+		return "", fmt.Errorf("failed to download checksum: %w", err)
 	}
 	defer resp.Close()
 
-	md5Bytes, err := io.ReadAll(resp)
+	checksum, err := io.ReadAll(io.LimitReader(resp, 4096))
 	if err != nil {
-		return "", fmt.Errorf("failed to read MD5: %w", err)
+		return "", fmt.Errorf("failed to read checksum: %w", err)
 	}
-	return strings.TrimSpace(string(md5Bytes)), nil
-}
-
-func (p *UpdaterService) calculateMD5(filepath string) (string, error) {
-	file, err := os.Open(filepath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", fmt.Errorf("failed to calculate MD5: %w", err)
-	}
-	return hex.EncodeToString(hash.Sum(nil)), nil
+	return string(checksum), nil
 }
 
 func (p *UpdaterService) downloadUpdate() (string, error) {
@@ -117,20 +109,25 @@ func (p *UpdaterService) downloadUpdate() (string, error) {
 	return tempFile, nil
 }
 
+// verifyUpdate refuses an update that is empty, not an executable or does
+// not match its published checksum. Without a usable checksum the update is
+// refused too.
 func (p *UpdaterService) verifyUpdate(tempFile string) error {
-	expectedMD5, err := p.downloadAndVerifyMD5()
+	checksum, err := p.downloadChecksum()
 	if err != nil {
-		return fmt.Errorf("failed to get expected MD5: %w", err)
+		return err
 	}
 
-	actualMD5, err := p.calculateMD5(tempFile)
+	sum, err := agent.VerifyBinary(tempFile, checksum)
 	if err != nil {
-		return fmt.Errorf("failed to calculate actual MD5: %w", err)
+		syslog.L.Error(err).
+			WithMessage("downloaded update failed verification, keeping the current binary").
+			WithField("sha256", sum).
+			Write()
+		return fmt.Errorf("failed to verify update: %w", err)
 	}
 
-	if !strings.EqualFold(actualMD5, expectedMD5) {
-		return fmt.Errorf("MD5 mismatch: expected %s, got %s", expectedMD5, actualMD5)
-	}
+	syslog.L.Info().WithMessage("downloaded update verified").WithField("sha256", sum).Write()
 	return nil
 }
 
@@ -156,46 +153,83 @@ func (p *UpdaterService) startMainService() error {
 	return cmd.Run()
 }
 
+func (p *UpdaterService) isServiceRunning() bool {
+	output, err := exec.Command("sc", "query", mainServiceName).Output()
+	return err == nil && strings.Contains(string(output), "RUNNING")
+}
+
+// applyUpdate swaps the agent binary for tempFile, keeping the previous one
+// next to it, and rolls back when the new binary does not come up healthy.
 func (p *UpdaterService) applyUpdate(tempFile string) error {
 	mainBinary, err := p.getMainBinaryPath()
 	if err != nil {
 		return err
 	}
+	previousBinary := mainBinary + previousBinarySuffix
+
+	previousVersion, _ := p.getMainServiceVersion()
 
-	backupPath := mainBinary + ".backup"
 	if err := p.stopMainService(); err != nil {
 		return fmt.Errorf("failed to stop service: %w", err)
 	}
 
-	if err := os.Rename(mainBinary, backupPath); err != nil && !os.IsExist(err) {
-		return fmt.Errorf("failed to create backup: %w", err)
+	os.Remove(previousBinary)
+	if err := os.Rename(mainBinary, previousBinary); err != nil {
+		return fmt.Errorf("failed to keep previous binary: %w", err)
 	}
 
 	if err := os.Rename(tempFile, mainBinary); err != nil {
-		os.Rename(backupPath, mainBinary)
+		os.Rename(previousBinary, mainBinary)
 		return fmt.Errorf("failed to replace binary: %w", err)
 	}
 
+	healthy := false
 	if err := p.startMainService(); err != nil {
-		os.Rename(backupPath, mainBinary)
-		return fmt.Errorf("failed to start service: %w", err)
+		syslog.L.Error(err).WithMessage("failed to start updated agent").Write()
+	} else {
+		healthy = agent.WaitForHealthyUpdate(p.ctx, agent.DefaultUpdateCheck, previousVersion, func() agent.UpdateHealth {
+			version, _ := p.getMainServiceVersion()
+			return agent.UpdateHealth{Running: p.isServiceRunning(), Version: version}
+		})
+	}
+	if healthy {
+		return nil
 	}
 
-	os.Remove(backupPath)
-	os.Remove(tempFile)
-	return nil
+	syslog.L.Warn().
+		WithMessage("updated agent did not become healthy, rolling back").
+		WithField("previous", previousVersion).
+		Write()
+	return p.rollback(mainBinary, previousBinary)
+}
+
+// rollback restores the binary kept by applyUpdate and restarts the agent.
+func (p *UpdaterService) rollback(mainBinary, previousBinary string) error {
+	_ = p.stopMainService()
+
+	if err := os.Remove(mainBinary); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove unhealthy binary: %w", err)
+	}
+	if err := os.Rename(previousBinary, mainBinary); err != nil {
+		return fmt.Errorf("failed to restore previous binary: %w", err)
+	}
+	if err := p.startMainService(); err != nil {
+		return fmt.Errorf("failed to start previous binary: %w", err)
+	}
+	return fmt.Errorf("update rolled back: new binary did not become healthy")
 }
 
 func (p *UpdaterService) performUpdate() error {
+	var err error
 	for retry := 0; retry < maxUpdateRetries; retry++ {
 		if retry > 0 {
 			time.Sleep(updateRetryDelay)
 		}
-		if err := p.tryUpdate(); err == nil {
+		if err = p.tryUpdate(); err == nil {
 			return nil
 		}
 	}
-	return fmt.Errorf("all update attempts failed")
+	return fmt.Errorf("all update attempts failed: %w", err)
 }
 
 func (p *UpdaterService) tryUpdate() error {
@@ -233,11 +267,15 @@ func (p *UpdaterService) cleanupOldUpdates() error {
 		}
 	}
 
-	backups, _ := filepath.Glob(filepath.Join(tempDir, "*.backup"))
-	for _, backup := range backups {
-		info, _ := os.Stat(backup)
-		if time.Since(info.ModTime()) > 48*time.Hour {
-			os.Remove(backup)
+	// The previous binary is kept for a rollback until the updated one has
+	// been in place for a while.
+	mainBinary, err := p.getMainBinaryPath()
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(mainBinary); err == nil && time.Since(info.ModTime()) > previousBinaryRetention {
+		if err := os.Remove(mainBinary + previousBinarySuffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove previous binary: %w", err)
 		}
 	}
 	return nil
@@ -0,0 +1,147 @@
+//go:build linux
+
+package agentfs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func xattrOf(t *testing.T, server *AgentFSServer, path string) types.AgentFileInfo {
+	t.Helper()
+
+	payload, err := (&types.StatReq{Path: path}).Encode()
+	require.NoError(t, err)
+	resp, err := server.handleXattr(arpc.Request{Payload: payload})
+	require.NoError(t, err)
+
+	var info types.AgentFileInfo
+	require.NoError(t, info.Decode(resp.Data))
+	return info
+}
+
+func TestSetXattrRequiresAllowWrite(t *testing.T) {
+	testDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "file.txt"), nil, 0644))
+
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{})
+	defer server.Close()
+
+	payload, err := (&types.SetXattrReq{Path: "file.txt", ApplyACLs: true}).Encode()
+	require.NoError(t, err)
+	resp, err := server.handleSetXattr(arpc.Request{Payload: payload})
+	require.NoError(t, err)
+	assert.Equal(t, 403, resp.Status)
+}
+
+func TestSetXattrCopiesPosixACL(t *testing.T) {
+	for _, tool := range []string{"getfacl", "setfacl"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+
+	testDir := t.TempDir()
+	source := filepath.Join(testDir, "source.txt")
+	require.NoError(t, os.WriteFile(source, nil, 0640))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "dest.txt"), nil, 0600))
+
+	// Give the source an extended ACL with a named user and a mask.
+	if out, err := exec.Command("setfacl", "-m", "u:65534:r--,m::rw-", source).CombinedOutput(); err != nil {
+		t.Skipf("filesystem does not support ACLs: %v: %s", err, out)
+	}
+
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{AllowWrite: true})
+	defer server.Close()
+
+	sourceInfo := xattrOf(t, server, "source.txt")
+	require.NotEmpty(t, sourceInfo.PosixACLs)
+	assert.NotEqual(t, sourceInfo.PosixACLs, xattrOf(t, server, "dest.txt").PosixACLs)
+
+	payload, err := (&types.SetXattrReq{
+		Path:      "dest.txt",
+		Owner:     sourceInfo.Owner,
+		Group:     sourceInfo.Group,
+		ApplyACLs: true,
+		PosixACLs: sourceInfo.PosixACLs,
+	}).Encode()
+	require.NoError(t, err)
+	resp, err := server.handleSetXattr(arpc.Request{Payload: payload})
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.Status)
+
+	destInfo := xattrOf(t, server, "dest.txt")
+	assert.Equal(t, sourceInfo.PosixACLs, destInfo.PosixACLs)
+	assert.Equal(t, sourceInfo.Owner, destInfo.Owner)
+	assert.Equal(t, sourceInfo.Group, destInfo.Group)
+}
+
+func TestSetXattrLargeACLOverSession(t *testing.T) {
+	testDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "dest.txt"), nil, 0600))
+
+	// Record what setfacl is asked to apply instead of needing ACL support.
+	specPath := filepath.Join(testDir, "spec")
+	script := filepath.Join(t.TempDir(), "setfacl")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nprintf '%s' \"$2\" > "+specPath+"\n"), 0755))
+	orig := setfaclBinary
+	setfaclBinary = script
+	defer func() { setfaclBinary = orig }()
+
+	acls := []types.PosixACL{{Tag: "user", ID: -1, Perms: 6}}
+	for i := 0; i < 500; i++ {
+		acls = append(acls, types.PosixACL{Tag: "user", ID: int32(100000 + i), Perms: 4})
+	}
+	acls = append(acls,
+		types.PosixACL{Tag: "group", ID: -1, Perms: 4},
+		types.PosixACL{Tag: "mask", ID: -1, Perms: 6},
+		types.PosixACL{Tag: "other", ID: -1, Perms: 0},
+	)
+	req := types.SetXattrReq{Path: "dest.txt", ApplyACLs: true, PosixACLs: acls}
+	encoded, err := req.Encode()
+	require.NoError(t, err)
+	require.Greater(t, len(encoded), 4096)
+
+	serverConn, clientConn := net.Pipe()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	serverRouter := arpc.NewRouter()
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{AllowWrite: true})
+	server.RegisterHandlers(&serverRouter)
+	defer server.Close()
+
+	serverSession, err := arpc.NewServerSession(serverConn, nil)
+	require.NoError(t, err)
+	serverSession.SetRouter(serverRouter)
+	go func() { _ = serverSession.Serve() }()
+	defer serverSession.Close()
+
+	clientSession, err := arpc.NewClientSession(clientConn, nil)
+	require.NoError(t, err)
+	defer clientSession.Close()
+
+	_, err = clientSession.CallMsg(ctx, "agentFs/SetXattr", &req)
+	require.NoError(t, err)
+
+	spec, err := os.ReadFile(specPath)
+	require.NoError(t, err)
+	entries := strings.Split(string(spec), ",")
+	require.Len(t, entries, len(acls))
+	assert.Equal(t, "user::rw-", entries[0])
+	assert.Equal(t, fmt.Sprintf("user:%d:r--", 100000+499), entries[500])
+	assert.Equal(t, "other::---", entries[len(entries)-1])
+}
@@ -4,9 +4,11 @@ package agentfs
 
 import (
 	"fmt"
+	"sync"
 	"syscall"
 	"unsafe"
 
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
 	"golang.org/x/sys/windows"
 )
 
@@ -21,6 +23,7 @@ var (
 	procGetExplicitEntriesFromACL  = modAdvapi32.NewProc("GetExplicitEntriesFromAclW")
 	procGetSecurityDescriptorOwner = modAdvapi32.NewProc("GetSecurityDescriptorOwner")
 	procGetSecurityDescriptorGroup = modAdvapi32.NewProc("GetSecurityDescriptorGroup")
+	procInitializeAcl              = modAdvapi32.NewProc("InitializeAcl")
 	procLocalFree                  = modKernel32.NewProc("LocalFree")
 )
 
@@ -221,3 +224,107 @@ func getOwnerGroupAbsolute(absoluteSD []uint16) (string, string, error) {
 	}
 	return ownerStr, groupStr, nil
 }
+
+// aclRevision is ACL_REVISION, the revision of ACLs holding only standard
+// ACEs.
+const aclRevision = 2
+
+// emptyACL returns an ACL without entries. Unlike a NULL DACL, which grants
+// everyone full access, it leaves only the inherited entries in effect.
+func emptyACL() (*windows.ACL, error) {
+	buf := make([]byte, unsafe.Sizeof(windows.ACL{}))
+	ret, _, err := procInitializeAcl.Call(
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(len(buf)),
+		aclRevision,
+	)
+	if ret == 0 {
+		return nil, fmt.Errorf("InitializeAcl failed: %w", err)
+	}
+	return (*windows.ACL)(unsafe.Pointer(&buf[0])), nil
+}
+
+var restorePrivilegeOnce sync.Once
+
+// enableRestorePrivilege enables SeRestorePrivilege for the process, which
+// setting the owner of a file to another account requires. It only succeeds
+// for accounts holding the privilege, such as the agent service.
+func enableRestorePrivilege() {
+	restorePrivilegeOnce.Do(func() {
+		var token windows.Token
+		if err := windows.OpenProcessToken(windows.CurrentProcess(), windows.TOKEN_ADJUST_PRIVILEGES|windows.TOKEN_QUERY, &token); err != nil {
+			return
+		}
+		defer token.Close()
+
+		var luid windows.LUID
+		if err := windows.LookupPrivilegeValue(nil, windows.StringToUTF16Ptr("SeRestorePrivilege"), &luid); err != nil {
+			return
+		}
+		privileges := windows.Tokenprivileges{PrivilegeCount: 1}
+		privileges.Privileges[0] = windows.LUIDAndAttributes{Luid: luid, Attributes: windows.SE_PRIVILEGE_ENABLED}
+		_ = windows.AdjustTokenPrivileges(token, false, &privileges, 0, nil, nil)
+	})
+}
+
+// SetWinACLs applies an owner, a group and explicit ACL entries as returned
+// by GetWinACLs to filePath. Empty owner and group are left unchanged, and
+// the DACL is only replaced when applyACLs is set. Inherited entries keep
+// flowing from the parent.
+func SetWinACLs(filePath string, owner string, group string, applyACLs bool, acls []types.WinACL) error {
+	var secInfo windows.SECURITY_INFORMATION
+	var ownerSid, groupSid *windows.SID
+	var dacl *windows.ACL
+	var err error
+
+	if owner != "" {
+		if ownerSid, err = windows.StringToSid(owner); err != nil {
+			return fmt.Errorf("invalid owner SID %q: %w", owner, err)
+		}
+		secInfo |= windows.OWNER_SECURITY_INFORMATION
+		enableRestorePrivilege()
+	}
+	if group != "" {
+		if groupSid, err = windows.StringToSid(group); err != nil {
+			return fmt.Errorf("invalid group SID %q: %w", group, err)
+		}
+		secInfo |= windows.GROUP_SECURITY_INFORMATION
+	}
+
+	if applyACLs {
+		if len(acls) == 0 {
+			dacl, err = emptyACL()
+		} else {
+			entries := make([]windows.EXPLICIT_ACCESS, 0, len(acls))
+			for _, acl := range acls {
+				sid, err := windows.StringToSid(acl.SID)
+				if err != nil {
+					return fmt.Errorf("invalid trustee SID %q: %w", acl.SID, err)
+				}
+				entries = append(entries, windows.EXPLICIT_ACCESS{
+					AccessPermissions: windows.ACCESS_MASK(acl.AccessMask),
+					AccessMode:        windows.ACCESS_MODE(acl.Type),
+					Inheritance:       uint32(acl.Flags),
+					Trustee: windows.TRUSTEE{
+						TrusteeForm:  windows.TRUSTEE_IS_SID,
+						TrusteeType:  windows.TRUSTEE_IS_UNKNOWN,
+						TrusteeValue: windows.TrusteeValueFromSID(sid),
+					},
+				})
+			}
+			dacl, err = windows.ACLFromEntries(entries, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to build ACL: %w", err)
+		}
+		secInfo |= windows.DACL_SECURITY_INFORMATION | windows.UNPROTECTED_DACL_SECURITY_INFORMATION
+	}
+
+	if secInfo == 0 {
+		return nil
+	}
+	if err := windows.SetNamedSecurityInfo(filePath, windows.SE_FILE_OBJECT, secInfo, ownerSid, groupSid, dacl, nil); err != nil {
+		return fmt.Errorf("SetNamedSecurityInfo failed: %w", err)
+	}
+	return nil
+}
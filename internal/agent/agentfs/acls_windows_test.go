@@ -0,0 +1,73 @@
+//go:build windows
+
+package agentfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+)
+
+func TestSetXattrCopiesWinACLs(t *testing.T) {
+	testDir := t.TempDir()
+	source := filepath.Join(testDir, "source.txt")
+	dest := filepath.Join(testDir, "dest.txt")
+	require.NoError(t, os.WriteFile(source, nil, 0644))
+	require.NoError(t, os.WriteFile(dest, nil, 0644))
+
+	// Give the source explicit entries the destination lacks: read for
+	// Users, and a denied write for Guests.
+	require.NoError(t, SetWinACLs(source, "", "", true, []types.WinACL{
+		{SID: "S-1-5-32-545", AccessMask: uint32(windows.GENERIC_READ), Type: uint8(windows.GRANT_ACCESS)},
+		{SID: "S-1-5-32-546", AccessMask: uint32(windows.GENERIC_WRITE), Type: uint8(windows.DENY_ACCESS)},
+	}))
+
+	owner, group, sourceACLs, err := GetWinACLs(source)
+	require.NoError(t, err)
+	require.NotEmpty(t, sourceACLs)
+	_, _, destACLs, err := GetWinACLs(dest)
+	require.NoError(t, err)
+	require.NotEqual(t, sourceACLs, destACLs)
+
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{AllowWrite: true})
+	defer server.Close()
+
+	payload, err := (&types.SetXattrReq{
+		Path:      "dest.txt",
+		Owner:     owner,
+		Group:     group,
+		ApplyACLs: true,
+		WinACLs:   sourceACLs,
+	}).Encode()
+	require.NoError(t, err)
+	resp, err := server.handleSetXattr(arpc.Request{Payload: payload})
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.Status)
+
+	destOwner, destGroup, destACLs, err := GetWinACLs(dest)
+	require.NoError(t, err)
+	assert.Equal(t, owner, destOwner)
+	assert.Equal(t, group, destGroup)
+	assert.ElementsMatch(t, sourceACLs, destACLs)
+}
+
+func TestSetXattrRequiresAllowWrite(t *testing.T) {
+	testDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "file.txt"), nil, 0644))
+
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{})
+	defer server.Close()
+
+	payload, err := (&types.SetXattrReq{Path: "file.txt", ApplyACLs: true}).Encode()
+	require.NoError(t, err)
+	resp, err := server.handleSetXattr(arpc.Request{Payload: payload})
+	require.NoError(t, err)
+	assert.Equal(t, 403, resp.Status)
+}
@@ -1,17 +1,25 @@
 package agentfs
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	binarystream "github.com/sonroyaalmerol/pbs-plus/internal/arpc/binary"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils/idgen"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils/safemap"
+	"github.com/xtaci/smux"
 )
 
 type AgentFSServer struct {
@@ -21,14 +29,83 @@ type AgentFSServer struct {
 	snapshot         snapshots.Snapshot
 	handleIdGen      *idgen.IDGenerator
 	handles          *safemap.Map[uint64, *FileHandle]
+	handlesMu        sync.Mutex
+	maxOpenHandles   int
+	maxReadAtLength  int
 	arpcRouter       *arpc.Router
 	statFs           types.StatFS
 	allocGranularity uint32
+	consistentCopy   *consistentCopier
+	allowWrite       bool
+	lockRetry        LockRetryPolicy
+	symlinkPolicy    SymlinkPolicy
+	readStrategy     ReadStrategy
+	mmapThreshold    int
+	changedFiles     *types.ChangedFiles
+	exclusions       atomic.Pointer[pattern.RootedMatcher]
+	excludedCount    atomic.Int64
 }
 
-func NewAgentFSServer(jobId string, snapshot snapshots.Snapshot) *AgentFSServer {
+// AgentFSOptions configures an AgentFSServer.
+type AgentFSOptions struct {
+	// AllowWrite lets OpenFile accept write flags and enables WriteAt,
+	// Allocate and SetXattr, so a restore can push data back to the source.
+	// Backups leave it off and get a read-only server.
+	AllowWrite bool
+	// LockRetry retries opens and reads that fail because the file is
+	// briefly locked. The zero value does not retry.
+	LockRetry LockRetryPolicy
+	// SymlinkPolicy controls how listings treat links. The zero value
+	// skips them.
+	SymlinkPolicy SymlinkPolicy
+	// ReadStrategy picks between file mappings and ReadFile for ReadAt on
+	// Windows. The zero value is ReadAuto.
+	ReadStrategy ReadStrategy
+	// MmapThreshold is the read size from which ReadAuto maps the file.
+	// Zero uses DefaultMmapThreshold.
+	MmapThreshold int
+	// ChangedFiles is what the ChangedFiles call reports: the paths changed
+	// since the last backup of the job. Nil tells the server to walk the
+	// whole tree.
+	ChangedFiles *types.ChangedFiles
+	// MaxOpenHandles caps the handles a client can hold open. At the cap,
+	// OpenFile closes the least recently used idle handle, or fails when
+	// all of them are in use. Zero uses DefaultMaxOpenHandles.
+	MaxOpenHandles int
+	// IdleHandleTimeout is how long a handle can go without requests before
+	// the server closes it. Zero uses DefaultIdleHandleTimeout and a
+	// negative value keeps idle handles open.
+	IdleHandleTimeout time.Duration
+	// MaxReadAtLength is the longest ReadAt the server serves; longer ones
+	// are refused. Zero uses types.DefaultMaxReadAtLength, and values below
+	// types.ReadAtChunkSize are raised to it.
+	MaxReadAtLength int
+}
+
+func NewAgentFSServer(jobId string, snapshot snapshots.Snapshot, opts AgentFSOptions) *AgentFSServer {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	mmapThreshold := opts.MmapThreshold
+	if mmapThreshold <= 0 {
+		mmapThreshold = DefaultMmapThreshold
+	}
+
+	maxOpenHandles := opts.MaxOpenHandles
+	if maxOpenHandles <= 0 {
+		maxOpenHandles = DefaultMaxOpenHandles
+	}
+
+	idleHandleTimeout := opts.IdleHandleTimeout
+	if idleHandleTimeout == 0 {
+		idleHandleTimeout = DefaultIdleHandleTimeout
+	}
+
+	maxReadAtLength := opts.MaxReadAtLength
+	if maxReadAtLength == 0 {
+		maxReadAtLength = types.DefaultMaxReadAtLength
+	}
+	maxReadAtLength = max(maxReadAtLength, types.ReadAtChunkSize)
+
 	allocGranularity := GetAllocGranularity()
 	if allocGranularity == 0 {
 		allocGranularity = 65536 // 64 KB usually
@@ -38,16 +115,28 @@ func NewAgentFSServer(jobId string, snapshot snapshots.Snapshot) *AgentFSServer
 		snapshot:         snapshot,
 		jobId:            jobId,
 		handles:          safemap.New[uint64, *FileHandle](),
+		maxOpenHandles:   maxOpenHandles,
+		maxReadAtLength:  maxReadAtLength,
 		ctx:              ctx,
 		ctxCancel:        cancel,
 		handleIdGen:      idgen.NewIDGenerator(),
 		allocGranularity: uint32(allocGranularity),
+		allowWrite:       opts.AllowWrite,
+		lockRetry:        opts.LockRetry,
+		symlinkPolicy:    opts.SymlinkPolicy,
+		readStrategy:     opts.ReadStrategy,
+		mmapThreshold:    mmapThreshold,
+		changedFiles:     opts.ChangedFiles,
 	}
 
 	if err := s.initializeStatFS(); err != nil && syslog.L != nil {
 		syslog.L.Error(err).WithMessage("failed to initialize statfs").Write()
 	}
 
+	if idleHandleTimeout > 0 {
+		go s.sweepIdleHandles(idleHandleTimeout)
+	}
+
 	return s
 }
 
@@ -69,12 +158,24 @@ func safeHandler(fn func(req arpc.Request) (arpc.Response, error)) func(req arpc
 func (s *AgentFSServer) RegisterHandlers(r *arpc.Router) {
 	r.Handle(s.jobId+"/OpenFile", safeHandler(s.handleOpenFile))
 	r.Handle(s.jobId+"/Attr", safeHandler(s.handleAttr))
+	r.Handle(s.jobId+"/StatBatch", safeHandler(s.handleStatBatch))
 	r.Handle(s.jobId+"/Xattr", safeHandler(s.handleXattr))
+	r.Handle(s.jobId+"/SetXattr", safeHandler(s.handleSetXattr))
+	r.Handle(s.jobId+"/Streams", safeHandler(s.handleStreams))
+	r.Handle(s.jobId+"/Readlink", safeHandler(s.handleReadlink))
 	r.Handle(s.jobId+"/ReadDir", safeHandler(s.handleReadDir))
+	r.Handle(s.jobId+"/ReadDirStream", safeHandler(s.handleReadDirStream))
 	r.Handle(s.jobId+"/ReadAt", safeHandler(s.handleReadAt))
+	r.Handle(s.jobId+"/HashAt", safeHandler(s.handleHashAt))
+	r.Handle(s.jobId+"/WriteAt", safeHandler(s.handleWriteAt))
+	r.Handle(s.jobId+"/Allocate", safeHandler(s.handleAllocate))
+	r.Handle(s.jobId+"/ReadFile", safeHandler(s.handleReadFile))
 	r.Handle(s.jobId+"/Lseek", safeHandler(s.handleLseek))
 	r.Handle(s.jobId+"/Close", safeHandler(s.handleClose))
 	r.Handle(s.jobId+"/StatFS", safeHandler(s.handleStatFS))
+	r.Handle(s.jobId+"/ChangedFiles", safeHandler(s.handleChangedFiles))
+	r.Handle(s.jobId+"/SetExclusions", safeHandler(s.handleSetExclusions))
+	r.Handle(s.jobId+"/ExclusionStats", safeHandler(s.handleExclusionStats))
 
 	s.arpcRouter = r
 }
@@ -84,18 +185,60 @@ func (s *AgentFSServer) Close() {
 		r := s.arpcRouter
 		r.CloseHandle(s.jobId + "/OpenFile")
 		r.CloseHandle(s.jobId + "/Attr")
+		r.CloseHandle(s.jobId + "/StatBatch")
 		r.CloseHandle(s.jobId + "/Xattr")
+		r.CloseHandle(s.jobId + "/SetXattr")
+		r.CloseHandle(s.jobId + "/Streams")
+		r.CloseHandle(s.jobId + "/Readlink")
 		r.CloseHandle(s.jobId + "/ReadDir")
+		r.CloseHandle(s.jobId + "/ReadDirStream")
 		r.CloseHandle(s.jobId + "/ReadAt")
+		r.CloseHandle(s.jobId + "/HashAt")
+		r.CloseHandle(s.jobId + "/WriteAt")
+		r.CloseHandle(s.jobId + "/Allocate")
+		r.CloseHandle(s.jobId + "/ReadFile")
 		r.CloseHandle(s.jobId + "/Lseek")
 		r.CloseHandle(s.jobId + "/Close")
 		r.CloseHandle(s.jobId + "/StatFS")
+		r.CloseHandle(s.jobId + "/ChangedFiles")
+		r.CloseHandle(s.jobId + "/SetExclusions")
+		r.CloseHandle(s.jobId + "/ExclusionStats")
 	}
 
 	s.closeFileHandles()
+	s.consistentCopy.Close()
 	s.ctxCancel()
 }
 
+// SetConsistentCopyRules enables consistent copies of the files matched by
+// rules. Copies are only taken for direct (non-snapshot) backups; a snapshot
+// is already consistent.
+func (s *AgentFSServer) SetConsistentCopyRules(rules []ConsistentCopyRule) {
+	if !s.snapshot.Direct || len(rules) == 0 {
+		return
+	}
+	s.consistentCopy = newConsistentCopier(rules)
+}
+
+// isWriteFlag reports whether an OpenFile flag asks for anything beyond
+// read access.
+func isWriteFlag(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+}
+
+// writeNotAllowed is the response to write requests on a read-only server.
+func writeNotAllowed() (arpc.Response, error) {
+	errStr := arpc.StringMsg("write operations not allowed")
+	errBytes, err := errStr.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	return arpc.Response{
+		Status: 403,
+		Data:   errBytes,
+	}, nil
+}
+
 func (s *AgentFSServer) abs(filename string) (string, error) {
 	if filename == "" || filename == "." {
 		return s.snapshot.Path, nil
@@ -106,3 +249,48 @@ func (s *AgentFSServer) abs(filename string) (string, error) {
 	}
 	return path, nil
 }
+
+// absNoFollow is abs without resolving the last element, so a link keeps
+// naming the link itself rather than its target.
+func (s *AgentFSServer) absNoFollow(filename string) (string, error) {
+	dir, base := filepath.Split(filepath.Clean(string(filepath.Separator) + filename))
+	parent, err := s.abs(dir)
+	if err != nil || base == "" {
+		return parent, err
+	}
+	return filepath.Join(parent, base), nil
+}
+
+// checkReadAtLength refuses ReadAt lengths the server will not buffer.
+func (s *AgentFSServer) checkReadAtLength(length int) error {
+	if length < 0 {
+		return fmt.Errorf("invalid negative length requested: %d", length)
+	}
+	if length > s.maxReadAtLength {
+		return fmt.Errorf("ReadAt of %d bytes exceeds the limit of %d bytes, read in chunks of %d bytes",
+			length, s.maxReadAtLength, types.ReadAtChunkSize)
+	}
+	return nil
+}
+
+// readAtResponse replies to a ReadAt with data, compressed with one of the
+// accepted codecs when it is large and compressible enough. done, if set,
+// runs once the data was sent.
+func readAtResponse(data []byte, accepted binarystream.Codecs, done func()) arpc.Response {
+	codec := binarystream.ChooseCodec(data, accepted)
+
+	streamCallback := func(stream *smux.Stream) {
+		if done != nil {
+			defer done()
+		}
+		if err := binarystream.SendDataFromReaderWithCodec(bytes.NewReader(data), len(data), stream, codec); err != nil {
+			syslog.L.Error(err).WithMessage("failed sending data from reader via binary stream").Write()
+		}
+	}
+
+	return arpc.Response{
+		Status:    213,
+		Data:      codec.Metadata(),
+		RawStream: streamCallback,
+	}
+}
@@ -5,6 +5,7 @@ package agentfs
 import (
 	"bytes"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"os/user"
@@ -24,15 +25,15 @@ type FileHandle struct {
 	file     *os.File
 	fileSize int64
 	isDir    bool
-}
+	writable bool
+	// path is the requested path of read handles.
+	path string
 
-func (s *AgentFSServer) closeFileHandles() {
-	s.handles.ForEach(func(u uint64, fh *FileHandle) bool {
-		fh.file.Close()
-		return true
-	})
+	handleState
+}
 
-	s.handles.Clear()
+func (fh *FileHandle) close() error {
+	return fh.file.Close()
 }
 
 func (s *AgentFSServer) initializeStatFS() error {
@@ -91,23 +92,19 @@ func (s *AgentFSServer) handleOpenFile(req arpc.Request) (arpc.Response, error)
 		return arpc.Response{}, err
 	}
 
-	// Disallow write operations.
-	if payload.Flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
-		errStr := arpc.StringMsg("write operations not allowed")
-		errBytes, err := errStr.Encode()
-		if err != nil {
-			return arpc.Response{}, err
+	// Write operations are only allowed on servers built with AllowWrite.
+	if isWriteFlag(payload.Flag) {
+		if !s.allowWrite {
+			return writeNotAllowed()
 		}
-		return arpc.Response{
-			Status: 403,
-			Data:   errBytes,
-		}, nil
+		return s.handleOpenFileWrite(payload)
 	}
 
 	path, err := s.abs(payload.Path)
 	if err != nil {
 		return arpc.Response{}, err
 	}
+	path = s.consistentCopy.resolve(payload.Path, path)
 
 	// Check file status to mark directories.
 	stat, err := os.Stat(path)
@@ -120,18 +117,23 @@ func (s *AgentFSServer) handleOpenFile(req arpc.Request) (arpc.Response, error)
 		return arpc.Response{}, err
 	}
 
-	handleId := s.handleIdGen.NextID()
 	fh := &FileHandle{
 		file:     file,
 		fileSize: stat.Size(),
 		isDir:    stat.IsDir(),
+		path:     payload.Path,
+	}
+	handleId, err := s.addHandle(fh)
+	if err != nil {
+		file.Close()
+		return arpc.Response{}, err
 	}
-	s.handles.Set(handleId, fh)
 
 	// Return the handle ID to the client.
 	fhId := types.FileHandleId(handleId)
 	dataBytes, err := fhId.Encode()
 	if err != nil {
+		s.handles.Del(handleId)
 		file.Close()
 		return arpc.Response{}, err
 	}
@@ -142,34 +144,115 @@ func (s *AgentFSServer) handleOpenFile(req arpc.Request) (arpc.Response, error)
 	}, nil
 }
 
-func (s *AgentFSServer) handleAttr(req arpc.Request) (arpc.Response, error) {
-	var payload types.StatReq
-	if err := payload.Decode(req.Payload); err != nil {
+// handleOpenFileWrite opens a file with the requested write flags. Unlike
+// reads, writes always go to the live path and never to a consistent copy.
+func (s *AgentFSServer) handleOpenFileWrite(payload types.OpenFileReq) (arpc.Response, error) {
+	path, err := s.abs(payload.Path)
+	if err != nil {
 		return arpc.Response{}, err
 	}
 
-	fullPath, err := s.abs(payload.Path)
+	file, err := os.OpenFile(path, payload.Flag, os.FileMode(payload.Perm))
 	if err != nil {
 		return arpc.Response{}, err
 	}
 
-	rawInfo, err := os.Stat(fullPath)
+	stat, err := file.Stat()
 	if err != nil {
+		file.Close()
 		return arpc.Response{}, err
 	}
+	if stat.IsDir() {
+		file.Close()
+		return arpc.Response{}, os.ErrInvalid
+	}
+
+	fh := &FileHandle{
+		file:     file,
+		fileSize: stat.Size(),
+		writable: true,
+	}
+	handleId, err := s.addHandle(fh)
+	if err != nil {
+		file.Close()
+		return arpc.Response{}, err
+	}
+
+	fhId := types.FileHandleId(handleId)
+	dataBytes, err := fhId.Encode()
+	if err != nil {
+		s.handles.Del(handleId)
+		file.Close()
+		return arpc.Response{}, err
+	}
+
+	return arpc.Response{
+		Status: 200,
+		Data:   dataBytes,
+	}, nil
+}
+
+// statPath returns the attributes of relPath as reported by Attr.
+func (s *AgentFSServer) statPath(relPath string) (types.AgentFileInfo, error) {
+	// Links listed as links have to be reported as such.
+	if s.symlinkPolicy == SymlinkRecordAsLink {
+		linkPath, err := s.absNoFollow(relPath)
+		if err != nil {
+			return types.AgentFileInfo{}, err
+		}
+		if info, err := os.Lstat(linkPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return types.AgentFileInfo{
+				Name:    info.Name(),
+				Size:    info.Size(),
+				Mode:    uint32(info.Mode()),
+				ModTime: info.ModTime(),
+			}, nil
+		}
+	}
+
+	fullPath, err := s.abs(relPath)
+	if err != nil {
+		return types.AgentFileInfo{}, err
+	}
+
+	rawInfo, err := os.Stat(fullPath)
+	if err != nil {
+		return types.AgentFileInfo{}, err
+	}
+
+	// A consistent copy is what OpenFile will serve, so report its size while
+	// keeping the metadata of the original file.
+	size := rawInfo.Size()
+	if copyPath := s.consistentCopy.resolve(relPath, fullPath); copyPath != fullPath {
+		if copyInfo, err := os.Stat(copyPath); err == nil {
+			size = copyInfo.Size()
+		}
+	}
 
 	blocks := uint64(0)
 	if !rawInfo.IsDir() && s.statFs.Bsize != 0 {
-		blocks = uint64((rawInfo.Size() + int64(s.statFs.Bsize) - 1) / int64(s.statFs.Bsize))
+		blocks = uint64((size + int64(s.statFs.Bsize) - 1) / int64(s.statFs.Bsize))
 	}
 
-	info := types.AgentFileInfo{
+	return types.AgentFileInfo{
 		Name:    rawInfo.Name(),
-		Size:    rawInfo.Size(),
+		Size:    size,
 		Mode:    uint32(rawInfo.Mode()),
 		ModTime: rawInfo.ModTime(),
 		IsDir:   rawInfo.IsDir(),
 		Blocks:  blocks,
+	}, nil
+}
+
+func (s *AgentFSServer) handleAttr(req arpc.Request) (arpc.Response, error) {
+	var payload types.StatReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	info, err := s.statPath(payload.Path)
+	if err != nil {
+		return arpc.Response{}, err
 	}
 
 	data, err := info.Encode()
@@ -182,6 +265,39 @@ func (s *AgentFSServer) handleAttr(req arpc.Request) (arpc.Response, error) {
 	}, nil
 }
 
+// absStream is abs; Linux files have no alternate data streams, so a colon
+// is part of the name.
+func (s *AgentFSServer) absStream(relPath string) (string, error) {
+	return s.abs(relPath)
+}
+
+// handleStreams reports no alternate data streams, which only exist on
+// NTFS.
+func (s *AgentFSServer) handleStreams(req arpc.Request) (arpc.Response, error) {
+	var payload types.StatReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	fullPath, err := s.abs(payload.Path)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	if _, err := os.Lstat(fullPath); err != nil {
+		return arpc.Response{}, err
+	}
+
+	streams := types.StreamInfos{}
+	data, err := streams.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	return arpc.Response{
+		Status: 200,
+		Data:   data,
+	}, nil
+}
+
 func (s *AgentFSServer) handleXattr(req arpc.Request) (arpc.Response, error) {
 	var payload types.StatReq
 	if err := payload.Decode(req.Payload); err != nil {
@@ -263,6 +379,65 @@ func (s *AgentFSServer) handleXattr(req arpc.Request) (arpc.Response, error) {
 	}, nil
 }
 
+// handleSetXattr restores the ownership and POSIX ACL of a file. Owner and
+// group are names or numeric IDs, as Xattr reports them.
+func (s *AgentFSServer) handleSetXattr(req arpc.Request) (arpc.Response, error) {
+	if !s.allowWrite {
+		return writeNotAllowed()
+	}
+
+	var payload types.SetXattrReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	fullPath, err := s.abs(payload.Path)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	uid, gid := -1, -1
+	if payload.Owner != "" {
+		uid, err = lookupID(payload.Owner, func(name string) (string, error) {
+			usr, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return usr.Uid, nil
+		})
+		if err != nil {
+			return arpc.Response{}, fmt.Errorf("unknown owner %q: %w", payload.Owner, err)
+		}
+	}
+	if payload.Group != "" {
+		gid, err = lookupID(payload.Group, func(name string) (string, error) {
+			grp, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return grp.Gid, nil
+		})
+		if err != nil {
+			return arpc.Response{}, fmt.Errorf("unknown group %q: %w", payload.Group, err)
+		}
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Lchown(fullPath, uid, gid); err != nil {
+			return arpc.Response{}, err
+		}
+	}
+
+	// Every file has at least the base ACL entries, so an empty list is
+	// nothing to apply.
+	if payload.ApplyACLs && len(payload.PosixACLs) > 0 {
+		if err := setPosixACL(fullPath, payload.PosixACLs); err != nil {
+			return arpc.Response{}, err
+		}
+	}
+
+	return arpc.Response{Status: 200}, nil
+}
+
 func (s *AgentFSServer) handleReadDir(req arpc.Request) (arpc.Response, error) {
 	var payload types.ReadDirReq
 	if err := payload.Decode(req.Payload); err != nil {
@@ -274,7 +449,7 @@ func (s *AgentFSServer) handleReadDir(req arpc.Request) (arpc.Response, error) {
 		return arpc.Response{}, err
 	}
 
-	entries, err := readDirBulk(fullDirPath)
+	entries, err := readDirBulk(req.Context(), fullDirPath, s.symlinkPolicy, s.listingFilter(payload.Path))
 	if err != nil {
 		return arpc.Response{}, err
 	}
@@ -297,17 +472,31 @@ func (s *AgentFSServer) handleReadAt(req arpc.Request) (arpc.Response, error) {
 	if err := payload.Decode(req.Payload); err != nil {
 		return arpc.Response{}, err
 	}
+	if err := s.checkReadAtLength(payload.Length); err != nil {
+		return arpc.Response{}, err
+	}
 
-	fh, exists := s.handles.Get(uint64(payload.HandleID))
-	if !exists {
-		return arpc.Response{}, os.ErrNotExist
+	fh, release, err := s.acquireHandle(payload.HandleID)
+	if err != nil {
+		return arpc.Response{}, err
 	}
+	defer release()
 	if fh.isDir {
 		return arpc.Response{}, os.ErrInvalid
 	}
 
 	reader := io.NewSectionReader(fh.file, payload.Offset, int64(payload.Length))
 
+	// Compression needs the data up front to judge whether it pays off.
+	if payload.AcceptCodecs != 0 && payload.Length >= binarystream.CompressThreshold {
+		buf := make([]byte, payload.Length)
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return arpc.Response{}, err
+		}
+		return readAtResponse(buf[:n], payload.AcceptCodecs, nil), nil
+	}
+
 	streamCallback := func(stream *smux.Stream) {
 		err := binarystream.SendDataFromReader(reader, payload.Length, stream)
 		if err != nil {
@@ -321,24 +510,131 @@ func (s *AgentFSServer) handleReadAt(req arpc.Request) (arpc.Response, error) {
 	}, nil
 }
 
+// hashRange feeds length bytes of fh starting at offset to h and returns the
+// number of bytes hashed.
+func (s *AgentFSServer) hashRange(fh *FileHandle, offset, length int64, h hash.Hash) (int64, error) {
+	bufPtr := hashBufPool.Get().(*[]byte)
+	defer hashBufPool.Put(bufPtr)
+
+	return io.CopyBuffer(h, io.NewSectionReader(fh.file, offset, length), *bufPtr)
+}
+
+func (s *AgentFSServer) handleWriteAt(req arpc.Request) (arpc.Response, error) {
+	if !s.allowWrite {
+		return writeNotAllowed()
+	}
+
+	var payload types.WriteAtReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+	if payload.Offset < 0 {
+		return arpc.Response{}, fmt.Errorf("invalid negative offset requested: %d", payload.Offset)
+	}
+
+	fh, release, err := s.acquireHandle(payload.HandleID)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	defer release()
+	if !fh.writable {
+		return arpc.Response{}, os.ErrPermission
+	}
+
+	written, err := fh.file.WriteAt(payload.Data, payload.Offset)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	if end := payload.Offset + int64(written); end > fh.fileSize {
+		fh.fileSize = end
+	}
+
+	resp := types.WriteAtResp{BytesWritten: written}
+	respBytes, err := resp.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	return arpc.Response{
+		Status: 200,
+		Data:   respBytes,
+	}, nil
+}
+
+func (s *AgentFSServer) handleAllocate(req arpc.Request) (arpc.Response, error) {
+	if !s.allowWrite {
+		return writeNotAllowed()
+	}
+
+	var payload types.AllocateReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+	if payload.Offset < 0 || payload.Length <= 0 {
+		return arpc.Response{}, os.ErrInvalid
+	}
+
+	fh, release, err := s.acquireHandle(payload.HandleID)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	defer release()
+	if !fh.writable {
+		return arpc.Response{}, os.ErrPermission
+	}
+
+	mode := uint32(0)
+	if payload.Mode&types.AllocateKeepSize != 0 {
+		mode |= unix.FALLOC_FL_KEEP_SIZE
+	}
+	if payload.Mode&types.AllocatePunchHole != 0 {
+		mode |= unix.FALLOC_FL_PUNCH_HOLE | unix.FALLOC_FL_KEEP_SIZE
+	}
+
+	if err := unix.Fallocate(int(fh.file.Fd()), mode, payload.Offset, payload.Length); err != nil {
+		return arpc.Response{}, err
+	}
+
+	var stat unix.Stat_t
+	if err := unix.Fstat(int(fh.file.Fd()), &stat); err != nil {
+		return arpc.Response{}, err
+	}
+	fh.fileSize = stat.Size
+
+	// st_blocks is always in 512-byte units.
+	resp := types.AllocateResp{AllocationSize: stat.Blocks * 512}
+	respBytes, err := resp.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	return arpc.Response{
+		Status: 200,
+		Data:   respBytes,
+	}, nil
+}
+
 func (s *AgentFSServer) handleLseek(req arpc.Request) (arpc.Response, error) {
 	var payload types.LseekReq
 	if err := payload.Decode(req.Payload); err != nil {
 		return arpc.Response{}, err
 	}
 
-	fh, exists := s.handles.Get(uint64(payload.HandleID))
-	if !exists {
-		return arpc.Response{}, os.ErrNotExist
+	fh, release, err := s.acquireHandle(payload.HandleID)
+	if err != nil {
+		return arpc.Response{}, err
 	}
+	defer release()
 	if fh.isDir {
 		return arpc.Response{}, os.ErrInvalid
 	}
 
-	// Handle SEEK_HOLE and SEEK_DATA explicitly
-	// TODO: linux implementation
 	if payload.Whence == SeekHole || payload.Whence == SeekData {
-		return arpc.Response{}, os.ErrInvalid
+		newOffset, err := sparseSeek(fh.file, payload.Offset, payload.Whence)
+		if err != nil {
+			return arpc.Response{}, err
+		}
+		return lseekResponse(newOffset)
 	}
 
 	// Get the file size
@@ -359,6 +655,10 @@ func (s *AgentFSServer) handleLseek(req arpc.Request) (arpc.Response, error) {
 		return arpc.Response{}, err
 	}
 
+	return lseekResponse(newOffset)
+}
+
+func lseekResponse(newOffset int64) (arpc.Response, error) {
 	resp := types.LseekResp{
 		NewOffset: newOffset,
 	}
@@ -379,13 +679,12 @@ func (s *AgentFSServer) handleClose(req arpc.Request) (arpc.Response, error) {
 		return arpc.Response{}, err
 	}
 
-	handle, exists := s.handles.Get(uint64(payload.HandleID))
+	handle, exists := s.removeHandle(payload.HandleID)
 	if !exists {
 		return arpc.Response{}, os.ErrNotExist
 	}
 
-	handle.file.Close()
-	s.handles.Del(uint64(payload.HandleID))
+	handle.close()
 
 	closed := arpc.StringMsg("closed")
 	data, err := closed.Encode()
@@ -2,6 +2,7 @@ package agentfs
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"math/rand"
@@ -20,6 +21,8 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zeebo/blake3"
+	"github.com/zeebo/xxh3"
 )
 
 type latencyConn struct {
@@ -171,7 +174,7 @@ func TestAgentFSServer(t *testing.T) {
 
 	// Start the server with the latency-wrapped connection.
 	serverRouter := arpc.NewRouter()
-	agentFsServer := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir, SourcePath: ""})
+	agentFsServer := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir, SourcePath: ""}, AgentFSOptions{})
 	agentFsServer.RegisterHandlers(&serverRouter)
 
 	serverSession, err := arpc.NewServerSession(serverConn, nil)
@@ -440,6 +443,105 @@ func TestAgentFSServer(t *testing.T) {
 		assert.Equal(t, 200, resp.Status)
 	})
 
+	t.Run("ReadFile_MatchesHandleRead", func(t *testing.T) {
+		for _, name := range []string{"test1.txt", "test2.txt", "medium_file.bin", "subdir/subfile.txt"} {
+			info, err := os.Stat(filepath.Join(testDir, name))
+			require.NoError(t, err)
+			size := int(info.Size())
+
+			// One-shot read.
+			readFilePayload := types.ReadFileReq{Path: name, MaxSize: 128 * 1024}
+			oneShot := make([]byte, 128*1024)
+			oneShotRead, err := clientSession.CallBinary(ctx, "agentFs/ReadFile", &readFilePayload, oneShot)
+			require.NoError(t, err, "ReadFile should succeed for %s", name)
+
+			// Handle-based read.
+			openPayload := types.OpenFileReq{Path: name, Flag: 0, Perm: 0644}
+			var handle types.FileHandleId
+			raw, err := clientSession.CallMsg(ctx, "agentFs/OpenFile", &openPayload)
+			require.NoError(t, err)
+			require.NoError(t, handle.Decode(raw))
+
+			readAtPayload := types.ReadAtReq{HandleID: handle, Offset: 0, Length: size}
+			viaHandle := make([]byte, size)
+			handleRead, err := clientSession.CallBinary(ctx, "agentFs/ReadAt", &readAtPayload, viaHandle)
+			require.NoError(t, err)
+
+			resp, err := clientSession.Call("agentFs/Close", &types.CloseReq{HandleID: handle})
+			require.NoError(t, err)
+			assert.Equal(t, 200, resp.Status)
+
+			assert.Equal(t, size, oneShotRead, "ReadFile should return the whole of %s", name)
+			assert.Equal(t, viaHandle[:handleRead], oneShot[:oneShotRead], "ReadFile and ReadAt disagree on %s", name)
+		}
+	})
+
+	t.Run("ReadFile_RejectsLargeFile", func(t *testing.T) {
+		payload := types.ReadFileReq{Path: "large_file.bin", MaxSize: 128 * 1024}
+		resp, err := clientSession.Call("agentFs/ReadFile", &payload)
+		require.NoError(t, err)
+		assert.NotEqual(t, 200, resp.Status)
+		assert.NotEqual(t, 213, resp.Status)
+
+		payload = types.ReadFileReq{Path: "subdir", MaxSize: 128 * 1024}
+		resp, err = clientSession.Call("agentFs/ReadFile", &payload)
+		require.NoError(t, err)
+		assert.NotEqual(t, 213, resp.Status)
+	})
+
+	t.Run("HashAt_MatchesLocalHash", func(t *testing.T) {
+		payload := types.OpenFileReq{Path: "large_file.bin", Flag: 0, Perm: 0644}
+		var handle types.FileHandleId
+		raw, err := clientSession.CallMsg(ctx, "agentFs/OpenFile", &payload)
+		require.NoError(t, err)
+		require.NoError(t, handle.Decode(raw))
+		defer clientSession.Call("agentFs/Close", &types.CloseReq{HandleID: handle})
+
+		content, err := os.ReadFile(largePath)
+		require.NoError(t, err)
+
+		localHash := func(algorithm string, data []byte) []byte {
+			switch algorithm {
+			case types.HashSHA256:
+				sum := sha256.Sum256(data)
+				return sum[:]
+			case types.HashBLAKE3:
+				sum := blake3.Sum256(data)
+				return sum[:]
+			}
+			h := xxh3.New()
+			h.Write(data)
+			return h.Sum(nil)
+		}
+
+		ranges := []struct {
+			name           string
+			offset, length int64
+			expected       []byte
+		}{
+			{"Middle", 1000, 300000, content[1000:301000]},
+			{"WholeFile", 0, int64(len(content)), content},
+			{"PastEOF", int64(len(content)) - 100, 4096, content[len(content)-100:]},
+		}
+
+		for _, algorithm := range []string{types.HashXXH3, types.HashSHA256, types.HashBLAKE3} {
+			for _, r := range ranges {
+				hashPayload := types.HashReq{HandleID: handle, Offset: r.offset, Length: r.length, Algorithm: algorithm}
+				raw, err := clientSession.CallMsg(ctx, "agentFs/HashAt", &hashPayload)
+				require.NoError(t, err, "%s/%s", algorithm, r.name)
+
+				var resp types.HashResp
+				require.NoError(t, resp.Decode(raw))
+				assert.Equal(t, int64(len(r.expected)), resp.Length, "%s/%s hashed length", algorithm, r.name)
+				assert.Equal(t, localHash(algorithm, r.expected), resp.Digest, "%s/%s digest", algorithm, r.name)
+			}
+		}
+
+		hashPayload := types.HashReq{HandleID: handle, Offset: 0, Length: 10, Algorithm: "md4"}
+		_, err = clientSession.CallMsg(ctx, "agentFs/HashAt", &hashPayload)
+		assert.Error(t, err, "unsupported algorithms should be rejected")
+	})
+
 	// Test for error conditions with invalid handles
 	t.Run("InvalidHandle_Operations", func(t *testing.T) {
 		// Try to read with a non-existent handle
@@ -463,6 +565,26 @@ func TestAgentFSServer(t *testing.T) {
 		assert.Equal(t, 500, resp.Status, "Close with invalid handle should return 500 status")
 	})
 
+	t.Run("OpenFile_WriteRejected", func(t *testing.T) {
+		payload := types.OpenFileReq{Path: ("test1.txt"), Flag: os.O_RDWR, Perm: 0644}
+		resp, err := clientSession.Call("agentFs/OpenFile", &payload)
+		require.NoError(t, err)
+		assert.Equal(t, 403, resp.Status)
+
+		var msg arpc.StringMsg
+		require.NoError(t, msg.Decode(resp.Data))
+		assert.Equal(t, "write operations not allowed", string(msg))
+
+		writeAtPayload := types.WriteAtReq{HandleID: 1, Offset: 0, Data: []byte("x")}
+		resp, err = clientSession.Call("agentFs/WriteAt", &writeAtPayload)
+		require.NoError(t, err)
+		assert.Equal(t, 403, resp.Status)
+
+		content, err := os.ReadFile(testFile1Path)
+		require.NoError(t, err)
+		assert.Equal(t, "test file 1 content", string(content))
+	})
+
 	// Test for double close behavior
 	t.Run("DoubleClose", func(t *testing.T) {
 		// Open file
@@ -841,3 +963,237 @@ func TestAgentFSServer(t *testing.T) {
 		assert.Equal(t, 200, resp.Status)
 	})
 }
+
+func TestAgentFSServerWrite(t *testing.T) {
+	testDir := t.TempDir()
+
+	existingPath := filepath.Join(testDir, "existing.txt")
+	require.NoError(t, os.WriteFile(existingPath, []byte("0123456789"), 0644))
+
+	serverConn, clientConn := net.Pipe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	serverRouter := arpc.NewRouter()
+	agentFsServer := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir, SourcePath: ""}, AgentFSOptions{AllowWrite: true})
+	agentFsServer.RegisterHandlers(&serverRouter)
+	defer agentFsServer.Close()
+
+	serverSession, err := arpc.NewServerSession(serverConn, nil)
+	require.NoError(t, err)
+	serverSession.SetRouter(serverRouter)
+
+	go func() {
+		err := serverSession.Serve()
+		if err != nil && ctx.Err() == nil && err != io.EOF && !strings.Contains(err.Error(), "closed pipe") {
+			t.Errorf("Server error: %v", err)
+		}
+	}()
+	defer serverSession.Close()
+
+	clientSession, err := arpc.NewClientSession(clientConn, nil)
+	require.NoError(t, err)
+	defer clientSession.Close()
+
+	writeAt := func(t *testing.T, handle types.FileHandleId, offset int64, data string) {
+		t.Helper()
+		payload := types.WriteAtReq{HandleID: handle, Offset: offset, Data: []byte(data)}
+		raw, err := clientSession.CallMsg(ctx, "agentFs/WriteAt", &payload)
+		require.NoError(t, err)
+
+		var result types.WriteAtResp
+		require.NoError(t, result.Decode(raw))
+		assert.Equal(t, len(data), result.BytesWritten)
+	}
+
+	closeHandle := func(t *testing.T, handle types.FileHandleId) {
+		t.Helper()
+		resp, err := clientSession.Call("agentFs/Close", &types.CloseReq{HandleID: handle})
+		require.NoError(t, err)
+		assert.Equal(t, 200, resp.Status)
+	}
+
+	t.Run("WriteAt_ExistingFile", func(t *testing.T) {
+		payload := types.OpenFileReq{Path: "existing.txt", Flag: os.O_RDWR, Perm: 0644}
+		var handle types.FileHandleId
+		raw, err := clientSession.CallMsg(ctx, "agentFs/OpenFile", &payload)
+		require.NoError(t, err)
+		require.NoError(t, handle.Decode(raw))
+
+		writeAt(t, handle, 4, "abcd")
+		closeHandle(t, handle)
+
+		content, err := os.ReadFile(existingPath)
+		require.NoError(t, err)
+		assert.Equal(t, "0123abcd89", string(content))
+	})
+
+	t.Run("WriteAt_NewFile", func(t *testing.T) {
+		payload := types.OpenFileReq{Path: "restored.txt", Flag: os.O_WRONLY | os.O_CREATE | os.O_TRUNC, Perm: 0644}
+		var handle types.FileHandleId
+		raw, err := clientSession.CallMsg(ctx, "agentFs/OpenFile", &payload)
+		require.NoError(t, err)
+		require.NoError(t, handle.Decode(raw))
+
+		writeAt(t, handle, 0, "restored ")
+		writeAt(t, handle, 9, "content")
+		closeHandle(t, handle)
+
+		content, err := os.ReadFile(filepath.Join(testDir, "restored.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "restored content", string(content))
+	})
+
+	t.Run("WriteAt_LargeData", func(t *testing.T) {
+		payload := types.OpenFileReq{Path: "large.bin", Flag: os.O_WRONLY | os.O_CREATE | os.O_TRUNC, Perm: 0644}
+		var handle types.FileHandleId
+		raw, err := clientSession.CallMsg(ctx, "agentFs/OpenFile", &payload)
+		require.NoError(t, err)
+		require.NoError(t, handle.Decode(raw))
+
+		// Larger than the buffer a request is first read into.
+		data := strings.Repeat("0123456789abcdef", 4096)
+		writeAt(t, handle, 0, data)
+		writeAt(t, handle, int64(len(data)), data[:8192])
+		closeHandle(t, handle)
+
+		content, err := os.ReadFile(filepath.Join(testDir, "large.bin"))
+		require.NoError(t, err)
+		assert.Equal(t, data+data[:8192], string(content))
+	})
+
+	t.Run("Allocate_PunchHole", func(t *testing.T) {
+		const mb = 1024 * 1024
+		sparsePath := filepath.Join(testDir, "punched.bin")
+		createLargeTestFile(t, sparsePath, 3*mb)
+		original, err := os.ReadFile(sparsePath)
+		require.NoError(t, err)
+
+		payload := types.OpenFileReq{Path: "punched.bin", Flag: os.O_RDWR, Perm: 0644}
+		var handle types.FileHandleId
+		raw, err := clientSession.CallMsg(ctx, "agentFs/OpenFile", &payload)
+		require.NoError(t, err)
+		require.NoError(t, handle.Decode(raw))
+		defer closeHandle(t, handle)
+
+		allocatePayload := types.AllocateReq{
+			HandleID: handle,
+			Offset:   mb,
+			Length:   mb,
+			Mode:     types.AllocatePunchHole,
+		}
+		raw, err = clientSession.CallMsg(ctx, "agentFs/Allocate", &allocatePayload)
+		if err != nil && strings.Contains(err.Error(), "not supported") {
+			t.Skipf("filesystem does not support hole punching: %v", err)
+		}
+		require.NoError(t, err)
+
+		var allocateResp types.AllocateResp
+		require.NoError(t, allocateResp.Decode(raw))
+		assert.Less(t, allocateResp.AllocationSize, int64(3*mb), "punched range should no longer be allocated")
+
+		seek := func(offset int64, whence int) int64 {
+			t.Helper()
+			lseekPayload := types.LseekReq{HandleID: handle, Offset: offset, Whence: whence}
+			raw, err := clientSession.CallMsg(ctx, "agentFs/Lseek", &lseekPayload)
+			require.NoError(t, err)
+			var resp types.LseekResp
+			require.NoError(t, resp.Decode(raw))
+			return resp.NewOffset
+		}
+
+		assert.Equal(t, int64(mb), seek(0, SeekHole), "hole should start where it was punched")
+		assert.Equal(t, int64(2*mb), seek(mb, SeekData), "data should resume after the hole")
+
+		info, err := os.Stat(sparsePath)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3*mb), info.Size(), "punching a hole must keep the file size")
+
+		content, err := os.ReadFile(sparsePath)
+		require.NoError(t, err)
+		assert.Equal(t, make([]byte, mb), content[mb:2*mb], "hole should read back as zeroes")
+		assert.Equal(t, original[:mb], content[:mb], "data before the hole should be intact")
+		assert.Equal(t, original[2*mb:], content[2*mb:], "data after the hole should be intact")
+	})
+
+	t.Run("WriteAt_ReadOnlyHandle", func(t *testing.T) {
+		payload := types.OpenFileReq{Path: "existing.txt", Flag: os.O_RDONLY, Perm: 0644}
+		var handle types.FileHandleId
+		raw, err := clientSession.CallMsg(ctx, "agentFs/OpenFile", &payload)
+		require.NoError(t, err)
+		require.NoError(t, handle.Decode(raw))
+
+		writeAtPayload := types.WriteAtReq{HandleID: handle, Offset: 0, Data: []byte("x")}
+		resp, err := clientSession.Call("agentFs/WriteAt", &writeAtPayload)
+		require.NoError(t, err)
+		assert.NotEqual(t, 200, resp.Status)
+		closeHandle(t, handle)
+
+		content, err := os.ReadFile(existingPath)
+		require.NoError(t, err)
+		assert.Equal(t, "0123abcd89", string(content))
+	})
+}
+
+func TestAgentFSServerReadAtLimit(t *testing.T) {
+	const (
+		fileSize = 3 << 20
+		limit    = 2 << 20
+	)
+
+	testDir := t.TempDir()
+	createLargeTestFile(t, filepath.Join(testDir, "large.bin"), fileSize)
+
+	serverConn, clientConn := net.Pipe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	serverRouter := arpc.NewRouter()
+	agentFsServer := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir, SourcePath: ""}, AgentFSOptions{MaxReadAtLength: limit})
+	agentFsServer.RegisterHandlers(&serverRouter)
+	defer agentFsServer.Close()
+
+	serverSession, err := arpc.NewServerSession(serverConn, nil)
+	require.NoError(t, err)
+	serverSession.SetRouter(serverRouter)
+	go func() { _ = serverSession.Serve() }()
+	defer serverSession.Close()
+
+	clientSession, err := arpc.NewClientSession(clientConn, nil)
+	require.NoError(t, err)
+	defer clientSession.Close()
+
+	var handle types.FileHandleId
+	raw, err := clientSession.CallMsg(ctx, "agentFs/OpenFile", &types.OpenFileReq{Path: "large.bin", Flag: os.O_RDONLY})
+	require.NoError(t, err)
+	require.NoError(t, handle.Decode(raw))
+
+	t.Run("oversized", func(t *testing.T) {
+		buf := make([]byte, limit+1)
+		_, err := clientSession.CallBinary(ctx, "agentFs/ReadAt", &types.ReadAtReq{HandleID: handle, Offset: 0, Length: len(buf)}, buf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds the limit")
+	})
+
+	t.Run("chunk", func(t *testing.T) {
+		const offset = 512 * 1024
+		buf := make([]byte, types.ReadAtChunkSize)
+		n, err := clientSession.CallBinary(ctx, "agentFs/ReadAt", &types.ReadAtReq{HandleID: handle, Offset: offset, Length: len(buf)}, buf)
+		require.NoError(t, err)
+		require.Equal(t, len(buf), n)
+		for i, b := range buf {
+			if want := byte(((offset + i) % (64 * 1024)) % 251); b != want {
+				t.Fatalf("byte %d: expected %d, got %d", offset+i, want, b)
+			}
+		}
+	})
+
+	t.Run("at the limit", func(t *testing.T) {
+		buf := make([]byte, limit)
+		n, err := clientSession.CallBinary(ctx, "agentFs/ReadAt", &types.ReadAtReq{HandleID: handle, Offset: 0, Length: len(buf)}, buf)
+		require.NoError(t, err)
+		assert.Equal(t, limit, n)
+	})
+}
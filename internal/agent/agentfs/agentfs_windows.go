@@ -5,6 +5,7 @@ package agentfs
 import (
 	"bytes"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
@@ -24,6 +25,11 @@ type FileHandle struct {
 	handle   windows.Handle
 	fileSize int64
 	isDir    bool
+	writable bool
+	// path is the requested path of read handles.
+	path string
+
+	handleState
 }
 
 type FileStandardInfo struct {
@@ -32,14 +38,8 @@ type FileStandardInfo struct {
 	DeletePending, Directory  bool
 }
 
-func (s *AgentFSServer) closeFileHandles() {
-	s.handles.ForEach(func(u uint64, fh *FileHandle) bool {
-		windows.CloseHandle(fh.handle)
-
-		return true
-	})
-
-	s.handles.Clear()
+func (fh *FileHandle) close() error {
+	return windows.CloseHandle(fh.handle)
 }
 
 func (s *AgentFSServer) initializeStatFS() error {
@@ -73,20 +73,15 @@ func (s *AgentFSServer) handleOpenFile(req arpc.Request) (arpc.Response, error)
 		return arpc.Response{}, err
 	}
 
-	// Disallow write operations.
-	if payload.Flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
-		errStr := arpc.StringMsg("write operations not allowed")
-		errBytes, err := errStr.Encode()
-		if err != nil {
-			return arpc.Response{}, err
+	// Write operations are only allowed on servers built with AllowWrite.
+	if isWriteFlag(payload.Flag) {
+		if !s.allowWrite {
+			return writeNotAllowed()
 		}
-		return arpc.Response{
-			Status: 403,
-			Data:   errBytes,
-		}, nil
+		return s.handleOpenFileWrite(payload)
 	}
 
-	path, err := s.abs(payload.Path)
+	path, err := s.absStream(payload.Path)
 	if err != nil {
 		return arpc.Response{}, err
 	}
@@ -97,17 +92,94 @@ func (s *AgentFSServer) handleOpenFile(req arpc.Request) (arpc.Response, error)
 		return arpc.Response{}, err
 	}
 
+	var handle windows.Handle
+	err = s.lockRetry.do(func() error {
+		var err error
+		handle, err = windows.CreateFile(
+			windows.StringToUTF16Ptr(path),
+			windows.GENERIC_READ,
+			windows.FILE_SHARE_READ,
+			nil,
+			windows.OPEN_EXISTING,
+			windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_SEQUENTIAL_SCAN|windows.FILE_FLAG_OVERLAPPED,
+			0,
+		)
+		return err
+	})
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	fileSize, err := getFileSize(handle)
+	if err != nil {
+		windows.CloseHandle(handle)
+		return arpc.Response{}, err
+	}
+
+	fh := &FileHandle{
+		handle:   handle,
+		fileSize: fileSize,
+		isDir:    stat.IsDir(),
+		path:     payload.Path,
+	}
+	handleId, err := s.addHandle(fh)
+	if err != nil {
+		windows.CloseHandle(handle)
+		return arpc.Response{}, err
+	}
+
+	// Return the handle ID to the client.
+	fhId := types.FileHandleId(handleId)
+	dataBytes, err := fhId.Encode()
+	if err != nil {
+		s.handles.Del(handleId)
+		windows.CloseHandle(handle)
+		return arpc.Response{}, err
+	}
+
+	return arpc.Response{
+		Status: 200,
+		Data:   dataBytes,
+	}, nil
+}
+
+// createDisposition maps the creation flags of an OpenFile request to the
+// CreateFile disposition with the same meaning.
+func createDisposition(flag int) uint32 {
+	switch {
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return windows.CREATE_NEW
+	case flag&(os.O_CREATE|os.O_TRUNC) == os.O_CREATE|os.O_TRUNC:
+		return windows.CREATE_ALWAYS
+	case flag&os.O_CREATE == os.O_CREATE:
+		return windows.OPEN_ALWAYS
+	case flag&os.O_TRUNC == os.O_TRUNC:
+		return windows.TRUNCATE_EXISTING
+	default:
+		return windows.OPEN_EXISTING
+	}
+}
+
+// handleOpenFileWrite opens a file with the requested write flags. The handle
+// is synchronous so WriteAt can position each write with an OVERLAPPED offset
+// and have it complete before replying.
+func (s *AgentFSServer) handleOpenFileWrite(payload types.OpenFileReq) (arpc.Response, error) {
+	path, err := s.absStream(payload.Path)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
 	handle, err := windows.CreateFile(
 		windows.StringToUTF16Ptr(path),
-		windows.GENERIC_READ,
+		windows.GENERIC_READ|windows.GENERIC_WRITE,
 		windows.FILE_SHARE_READ,
 		nil,
-		windows.OPEN_EXISTING,
-		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_SEQUENTIAL_SCAN|windows.FILE_FLAG_OVERLAPPED,
+		createDisposition(payload.Flag),
+		windows.FILE_ATTRIBUTE_NORMAL|windows.FILE_FLAG_BACKUP_SEMANTICS,
 		0,
 	)
 	if err != nil {
-		return arpc.Response{}, err
+		return arpc.Response{}, mapWinError(err, "handleOpenFileWrite CreateFile")
 	}
 
 	fileSize, err := getFileSize(handle)
@@ -116,18 +188,21 @@ func (s *AgentFSServer) handleOpenFile(req arpc.Request) (arpc.Response, error)
 		return arpc.Response{}, err
 	}
 
-	handleId := s.handleIdGen.NextID()
 	fh := &FileHandle{
 		handle:   handle,
 		fileSize: fileSize,
-		isDir:    stat.IsDir(),
+		writable: true,
+	}
+	handleId, err := s.addHandle(fh)
+	if err != nil {
+		windows.CloseHandle(handle)
+		return arpc.Response{}, err
 	}
-	s.handles.Set(handleId, fh)
 
-	// Return the handle ID to the client.
 	fhId := types.FileHandleId(handleId)
 	dataBytes, err := fhId.Encode()
 	if err != nil {
+		s.handles.Del(handleId)
 		windows.CloseHandle(handle)
 		return arpc.Response{}, err
 	}
@@ -138,27 +213,41 @@ func (s *AgentFSServer) handleOpenFile(req arpc.Request) (arpc.Response, error)
 	}, nil
 }
 
-func (s *AgentFSServer) handleAttr(req arpc.Request) (arpc.Response, error) {
-	var payload types.StatReq
-	if err := payload.Decode(req.Payload); err != nil {
-		return arpc.Response{}, err
+// statPath returns the attributes of relPath as reported by Attr.
+func (s *AgentFSServer) statPath(relPath string) (types.AgentFileInfo, error) {
+	// Links listed as links have to be reported as such. Junctions are not
+	// reported as symlinks by os.Lstat, hence the reparse tag.
+	if s.symlinkPolicy == SymlinkRecordAsLink {
+		linkPath, err := s.absNoFollow(relPath)
+		if err != nil {
+			return types.AgentFileInfo{}, err
+		}
+		if info, err := os.Lstat(linkPath); err == nil && info.Mode()&(os.ModeSymlink|os.ModeIrregular) != 0 {
+			if tag, err := reparseTag(linkPath); err == nil && isLinkReparseTag(tag) {
+				return types.AgentFileInfo{
+					Name:    info.Name(),
+					Mode:    uint32(os.ModeSymlink | 0777),
+					ModTime: info.ModTime(),
+				}, nil
+			}
+		}
 	}
 
-	fullPath, err := s.abs(payload.Path)
+	fullPath, err := s.abs(relPath)
 	if err != nil {
-		return arpc.Response{}, err
+		return types.AgentFileInfo{}, err
 	}
 
 	rawInfo, err := os.Stat(fullPath)
 	if err != nil {
-		return arpc.Response{}, err
+		return types.AgentFileInfo{}, err
 	}
 
 	blocks := uint64(0)
 	if !rawInfo.IsDir() {
 		file, err := os.Open(fullPath)
 		if err != nil {
-			return arpc.Response{}, err
+			return types.AgentFileInfo{}, err
 		}
 		defer file.Close()
 
@@ -176,20 +265,31 @@ func (s *AgentFSServer) handleAttr(req arpc.Request) (arpc.Response, error) {
 		}
 	}
 
-	info := types.AgentFileInfo{
+	return types.AgentFileInfo{
 		Name:    rawInfo.Name(),
 		Size:    rawInfo.Size(),
 		Mode:    uint32(rawInfo.Mode()),
 		ModTime: rawInfo.ModTime(),
 		IsDir:   rawInfo.IsDir(),
 		Blocks:  blocks,
+	}, nil
+}
+
+func (s *AgentFSServer) handleAttr(req arpc.Request) (arpc.Response, error) {
+	var payload types.StatReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
 	}
 
-	data, err := info.Encode()
+	info, err := s.statPath(payload.Path)
 	if err != nil {
 		return arpc.Response{}, err
 	}
 
+	data, err := info.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
 	return arpc.Response{
 		Status: 200,
 		Data:   data,
@@ -258,6 +358,29 @@ func (s *AgentFSServer) handleXattr(req arpc.Request) (arpc.Response, error) {
 
 // handleReadDir first attempts to serve the directory listing from the cache.
 // It returns the cached DirEntries for that directory.
+// handleSetXattr restores the owner, group and explicit ACL entries of a
+// file, as reported by Xattr.
+func (s *AgentFSServer) handleSetXattr(req arpc.Request) (arpc.Response, error) {
+	if !s.allowWrite {
+		return writeNotAllowed()
+	}
+
+	var payload types.SetXattrReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	fullPath, err := s.abs(payload.Path)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	if err := SetWinACLs(fullPath, payload.Owner, payload.Group, payload.ApplyACLs, payload.WinACLs); err != nil {
+		return arpc.Response{}, err
+	}
+	return arpc.Response{Status: 200}, nil
+}
+
 func (s *AgentFSServer) handleReadDir(req arpc.Request) (arpc.Response, error) {
 	var payload types.ReadDirReq
 	if err := payload.Decode(req.Payload); err != nil {
@@ -275,7 +398,7 @@ func (s *AgentFSServer) handleReadDir(req arpc.Request) (arpc.Response, error) {
 		fullDirPath = s.snapshot.Path
 	}
 
-	entries, err := readDirBulk(fullDirPath)
+	entries, err := readDirBulk(req.Context(), fullDirPath, s.symlinkPolicy, s.listingFilter(filepath.ToSlash(payload.Path)))
 	if err != nil {
 		return arpc.Response{}, err
 	}
@@ -302,15 +425,16 @@ func (s *AgentFSServer) handleReadAt(req arpc.Request) (arpc.Response, error) {
 	}
 
 	// Validate the payload parameters.
-	if payload.Length < 0 {
-		return arpc.Response{}, fmt.Errorf("invalid negative length requested: %d", payload.Length)
+	if err := s.checkReadAtLength(payload.Length); err != nil {
+		return arpc.Response{}, err
 	}
 
 	// Retrieve the file handle.
-	fh, exists := s.handles.Get(uint64(payload.HandleID))
-	if !exists {
-		return arpc.Response{}, os.ErrNotExist
+	fh, release, err := s.acquireHandle(payload.HandleID)
+	if err != nil {
+		return arpc.Response{}, err
 	}
+	defer release()
 	if fh.isDir {
 		return arpc.Response{}, os.ErrInvalid
 	}
@@ -335,81 +459,351 @@ func (s *AgentFSServer) handleReadAt(req arpc.Request) (arpc.Response, error) {
 		payload.Length = int(fh.fileSize - payload.Offset)
 	}
 
+	data, release, err := s.readRange(fh, payload.Offset, payload.Length)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	if payload.AcceptCodecs != 0 {
+		return readAtResponse(data, payload.AcceptCodecs, release), nil
+	}
+
+	reader := bytes.NewReader(data)
+	streamCallback := func(stream *smux.Stream) {
+		// Ensure we free up resources once streaming is done.
+		if release != nil {
+			defer release()
+		}
+		if err := binarystream.SendDataFromReader(reader, len(data), stream); err != nil {
+			syslog.L.Error(err).WithMessage("failed sending data from reader via binary stream").Write()
+		}
+	}
+
+	return arpc.Response{
+		Status:    213,
+		RawStream: streamCallback,
+	}, nil
+}
+
+// readRange reads length bytes of fh starting at offset, which must lie
+// within the file. Depending on the read strategy the bytes come from a file
+// mapping, in which case release must be called once they are no longer
+// used, or from ReadFile, in which case release is nil.
+func (s *AgentFSServer) readRange(fh *FileHandle, offset int64, length int) ([]byte, func(), error) {
+	if s.useMmap(length) {
+		if data, release, err := s.readRangeMapped(fh, offset, length); err == nil {
+			return data, release, nil
+		}
+	}
+
+	// Fallback to using the OVERLAPPED ReadFile method.
+	var overlapped windows.Overlapped
+	overlapped.Offset = uint32(offset & 0xFFFFFFFF)
+	overlapped.OffsetHigh = uint32(offset >> 32)
+
+	buffer := make([]byte, length)
+	var bytesRead uint32
+	err := s.lockRetry.do(func() error {
+		return windows.ReadFile(fh.handle, buffer, &bytesRead, &overlapped)
+	})
+	if err != nil {
+		return nil, nil, mapWinError(err, "handleReadAt ReadFile (OVERLAPPED fallback)")
+	}
+
+	return buffer[:bytesRead], nil, nil
+}
+
+// readRangeMapped is the file mapping path of readRange.
+func (s *AgentFSServer) readRangeMapped(fh *FileHandle, offset int64, length int) ([]byte, func(), error) {
 	// Align the offset down to the nearest multiple of the allocation granularity.
-	alignedOffset := payload.Offset - (payload.Offset % int64(s.allocGranularity))
-	offsetDiff := int(payload.Offset - alignedOffset)
-	viewSize := uintptr(payload.Length + offsetDiff)
+	alignedOffset := offset - (offset % int64(s.allocGranularity))
+	offsetDiff := int(offset - alignedOffset)
+	viewSize := uintptr(length + offsetDiff)
 
-	// Attempt to create a file mapping.
 	h, err := windows.CreateFileMapping(fh.handle, nil, windows.PAGE_READONLY, 0, 0, nil)
-	if err == nil {
-		// Map the requested view.
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addr, err := windows.MapViewOfFile(
+		h,
+		windows.FILE_MAP_READ,
+		uint32(alignedOffset>>32),
+		uint32(alignedOffset&0xFFFFFFFF),
+		viewSize,
+	)
+	if err != nil {
+		windows.CloseHandle(h)
+		return nil, nil, err
+	}
+
+	release := func() {
+		windows.UnmapViewOfFile(addr)
+		windows.CloseHandle(h)
+	}
+
+	data := viewBytes(addr, viewSize)
+	// Verify we’re not slicing outside the allocated region.
+	if offsetDiff+length > len(data) {
+		syslog.L.Error(fmt.Errorf(
+			"invalid slice bounds: offsetDiff=%d, length=%d, data len=%d",
+			offsetDiff, length, len(data)),
+		).WithMessage("invalid file mapping boundaries").Write()
+
+		release()
+		return nil, nil, fmt.Errorf("invalid file mapping boundaries")
+	}
+
+	return data[offsetDiff : offsetDiff+length], release, nil
+}
+
+func (s *AgentFSServer) handleWriteAt(req arpc.Request) (arpc.Response, error) {
+	if !s.allowWrite {
+		return writeNotAllowed()
+	}
+
+	var payload types.WriteAtReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+	if payload.Offset < 0 {
+		return arpc.Response{}, fmt.Errorf("invalid negative offset requested: %d", payload.Offset)
+	}
+
+	fh, release, err := s.acquireHandle(payload.HandleID)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	defer release()
+	if !fh.writable {
+		return arpc.Response{}, os.ErrPermission
+	}
+
+	var overlapped windows.Overlapped
+	overlapped.Offset = uint32(payload.Offset & 0xFFFFFFFF)
+	overlapped.OffsetHigh = uint32(payload.Offset >> 32)
+
+	var bytesWritten uint32
+	err = windows.WriteFile(fh.handle, payload.Data, &bytesWritten, &overlapped)
+	if err != nil {
+		return arpc.Response{}, mapWinError(err, "handleWriteAt WriteFile")
+	}
+	if end := payload.Offset + int64(bytesWritten); end > fh.fileSize {
+		fh.fileSize = end
+	}
+
+	resp := types.WriteAtResp{BytesWritten: int(bytesWritten)}
+	respBytes, err := resp.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	return arpc.Response{
+		Status: 200,
+		Data:   respBytes,
+	}, nil
+}
+
+// hashViewSize is how much of a file hashRange maps at a time. It is a
+// multiple of every allocation granularity Windows uses.
+const hashViewSize = 16 * 1024 * 1024
+
+// hashRange feeds length bytes of fh starting at offset to h and returns the
+// number of bytes hashed. Like handleReadAt it hashes straight from a file
+// mapping, one view at a time, and falls back to ReadFile when the file
+// cannot be mapped or the read strategy is ReadReadFile.
+func (s *AgentFSServer) hashRange(fh *FileHandle, offset, length int64, h hash.Hash) (int64, error) {
+	if offset >= fh.fileSize {
+		return 0, nil
+	}
+	length = min(length, fh.fileSize-offset)
+	if length == 0 {
+		return 0, nil
+	}
+
+	if s.readStrategy == ReadReadFile {
+		return hashRangeRead(fh, offset, length, h)
+	}
+
+	mapping, err := windows.CreateFileMapping(fh.handle, nil, windows.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return hashRangeRead(fh, offset, length, h)
+	}
+	defer windows.CloseHandle(mapping)
+
+	var hashed int64
+	for hashed < length {
+		pos := offset + hashed
+		alignedOffset := pos - (pos % int64(s.allocGranularity))
+		offsetDiff := pos - alignedOffset
+		chunk := min(length-hashed, hashViewSize)
+		viewSize := uintptr(offsetDiff + chunk)
+
 		addr, err := windows.MapViewOfFile(
-			h,
+			mapping,
 			windows.FILE_MAP_READ,
 			uint32(alignedOffset>>32),
 			uint32(alignedOffset&0xFFFFFFFF),
 			viewSize,
 		)
-		if err == nil {
-			ptr := (*byte)(unsafe.Pointer(addr))
-			data := unsafe.Slice(ptr, viewSize)
-			// Verify we’re not slicing outside the allocated region.
-			if offsetDiff+payload.Length > len(data) {
-				syslog.L.Error(fmt.Errorf(
-					"invalid slice bounds: offsetDiff=%d, payload.Length=%d, data len=%d",
-					offsetDiff, payload.Length, len(data)),
-				).WithMessage("invalid file mapping boundaries").Write()
-
-				windows.UnmapViewOfFile(addr)
-				windows.CloseHandle(h)
-				return arpc.Response{}, fmt.Errorf("invalid file mapping boundaries")
-			}
-			result := data[offsetDiff : offsetDiff+payload.Length]
-			reader := bytes.NewReader(result)
-
-			streamCallback := func(stream *smux.Stream) {
-				// Ensure we free up resources once streaming is done.
-				defer func() {
-					windows.UnmapViewOfFile(addr)
-					windows.CloseHandle(h)
-				}()
-				if err := binarystream.SendDataFromReader(reader, payload.Length, stream); err != nil {
-					syslog.L.Error(err).WithMessage("failed sending data from reader via binary stream").Write()
-				}
-			}
+		if err != nil {
+			// The hash state is kept, so the rest can still be read.
+			n, err := hashRangeRead(fh, pos, length-hashed, h)
+			return hashed + n, err
+		}
+
+		data := viewBytes(addr, viewSize)
+		h.Write(data[offsetDiff:])
+		windows.UnmapViewOfFile(addr)
 
-			return arpc.Response{
-				Status:    213,
-				RawStream: streamCallback,
-			}, nil
+		hashed += chunk
+	}
+
+	return hashed, nil
+}
+
+// viewBytes returns the size bytes of the file view MapViewOfFile mapped at
+// addr. The view is outside the Go heap, so the address is reinterpreted in
+// place rather than converted from a uintptr value.
+func viewBytes(addr, size uintptr) []byte {
+	return unsafe.Slice((*byte)(*(*unsafe.Pointer)(unsafe.Pointer(&addr))), size)
+}
+
+// hashRangeRead is the ReadFile fallback of hashRange.
+func hashRangeRead(fh *FileHandle, offset, length int64, h hash.Hash) (int64, error) {
+	bufPtr := hashBufPool.Get().(*[]byte)
+	defer hashBufPool.Put(bufPtr)
+	buffer := *bufPtr
+
+	var hashed int64
+	for hashed < length {
+		pos := offset + hashed
+		chunk := min(length-hashed, int64(len(buffer)))
+
+		var overlapped windows.Overlapped
+		overlapped.Offset = uint32(pos & 0xFFFFFFFF)
+		overlapped.OffsetHigh = uint32(pos >> 32)
+
+		var bytesRead uint32
+		err := windows.ReadFile(fh.handle, buffer[:chunk], &bytesRead, &overlapped)
+		if err == windows.ERROR_IO_PENDING {
+			err = windows.GetOverlappedResult(fh.handle, &overlapped, &bytesRead, true)
 		}
-		// If mapping fails, clean up.
-		windows.CloseHandle(h)
+		if err != nil {
+			return hashed, mapWinError(err, "hashRangeRead ReadFile")
+		}
+		if bytesRead == 0 {
+			break
+		}
+
+		h.Write(buffer[:bytesRead])
+		hashed += int64(bytesRead)
 	}
 
-	// Fallback to using the OVERLAPPED ReadFile method.
-	var overlapped windows.Overlapped
-	overlapped.Offset = uint32(payload.Offset & 0xFFFFFFFF)
-	overlapped.OffsetHigh = uint32(payload.Offset >> 32)
+	return hashed, nil
+}
 
-	buffer := make([]byte, payload.Length)
-	var bytesRead uint32
-	err = windows.ReadFile(fh.handle, buffer, &bytesRead, &overlapped)
+// fileZeroDataInformation is FILE_ZERO_DATA_INFORMATION.
+type fileZeroDataInformation struct {
+	FileOffset      int64
+	BeyondFinalZero int64
+}
+
+// fileAllocationInfo is FILE_ALLOCATION_INFO.
+type fileAllocationInfo struct {
+	AllocationSize int64
+}
+
+// fileEndOfFileInfo is FILE_END_OF_FILE_INFO.
+type fileEndOfFileInfo struct {
+	EndOfFile int64
+}
+
+func (s *AgentFSServer) handleAllocate(req arpc.Request) (arpc.Response, error) {
+	if !s.allowWrite {
+		return writeNotAllowed()
+	}
+
+	var payload types.AllocateReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+	if payload.Offset < 0 || payload.Length <= 0 {
+		return arpc.Response{}, os.ErrInvalid
+	}
+
+	fh, release, err := s.acquireHandle(payload.HandleID)
 	if err != nil {
-		return arpc.Response{}, mapWinError(err, "handleReadAt ReadFile (OVERLAPPED fallback)")
+		return arpc.Response{}, err
+	}
+	defer release()
+	if !fh.writable {
+		return arpc.Response{}, os.ErrPermission
 	}
 
-	reader := bytes.NewReader(buffer[:bytesRead])
-	streamCallback := func(stream *smux.Stream) {
-		if err := binarystream.SendDataFromReader(reader, int(bytesRead), stream); err != nil {
-			syslog.L.Error(err).WithMessage("failed sending data from reader via binary stream").Write()
+	end := payload.Offset + payload.Length
+	var bytesReturned uint32
+
+	switch {
+	case payload.Mode&types.AllocatePunchHole != 0:
+		// Only sparse files release the zeroed range; mark the file sparse
+		// first so the range becomes a hole instead of written zeroes.
+		err := windows.DeviceIoControl(fh.handle, windows.FSCTL_SET_SPARSE,
+			nil, 0, nil, 0, &bytesReturned, nil)
+		if err != nil {
+			return arpc.Response{}, mapWinError(err, "handleAllocate DeviceIoControl (FSCTL_SET_SPARSE)")
+		}
+
+		zeroData := fileZeroDataInformation{
+			FileOffset:      payload.Offset,
+			BeyondFinalZero: min(end, fh.fileSize),
+		}
+		if zeroData.BeyondFinalZero > zeroData.FileOffset {
+			err = windows.DeviceIoControl(fh.handle, windows.FSCTL_SET_ZERO_DATA,
+				(*byte)(unsafe.Pointer(&zeroData)), uint32(unsafe.Sizeof(zeroData)),
+				nil, 0, &bytesReturned, nil)
+			if err != nil {
+				return arpc.Response{}, mapWinError(err, "handleAllocate DeviceIoControl (FSCTL_SET_ZERO_DATA)")
+			}
+		}
+
+	case payload.Mode&types.AllocateKeepSize != 0:
+		allocation := fileAllocationInfo{AllocationSize: end}
+		err := windows.SetFileInformationByHandle(fh.handle, windows.FileAllocationInfo,
+			(*byte)(unsafe.Pointer(&allocation)), uint32(unsafe.Sizeof(allocation)))
+		if err != nil {
+			return arpc.Response{}, mapWinError(err, "handleAllocate SetFileInformationByHandle (FileAllocationInfo)")
+		}
+
+	default:
+		if end > fh.fileSize {
+			eof := fileEndOfFileInfo{EndOfFile: end}
+			err := windows.SetFileInformationByHandle(fh.handle, windows.FileEndOfFileInfo,
+				(*byte)(unsafe.Pointer(&eof)), uint32(unsafe.Sizeof(eof)))
+			if err != nil {
+				return arpc.Response{}, mapWinError(err, "handleAllocate SetFileInformationByHandle (FileEndOfFileInfo)")
+			}
+			fh.fileSize = end
 		}
 	}
 
+	var standardInfo FileStandardInfo
+	err = windows.GetFileInformationByHandleEx(fh.handle, windows.FileStandardInfo,
+		(*byte)(unsafe.Pointer(&standardInfo)), uint32(unsafe.Sizeof(standardInfo)))
+	if err != nil {
+		return arpc.Response{}, mapWinError(err, "handleAllocate GetFileInformationByHandleEx")
+	}
+	fh.fileSize = standardInfo.EndOfFile
+
+	resp := types.AllocateResp{AllocationSize: standardInfo.AllocationSize}
+	respBytes, err := resp.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
 	return arpc.Response{
-		Status:    213,
-		RawStream: streamCallback,
+		Status: 200,
+		Data:   respBytes,
 	}, nil
 }
 
@@ -429,10 +823,11 @@ func (s *AgentFSServer) handleLseek(req arpc.Request) (arpc.Response, error) {
 	}
 
 	// Retrieve the file handle
-	fh, exists := s.handles.Get(uint64(payload.HandleID))
-	if !exists {
-		return arpc.Response{}, os.ErrNotExist
+	fh, release, err := s.acquireHandle(payload.HandleID)
+	if err != nil {
+		return arpc.Response{}, err
 	}
+	defer release()
 	if fh.isDir {
 		return arpc.Response{}, os.ErrInvalid
 	}
@@ -510,14 +905,12 @@ func (s *AgentFSServer) handleClose(req arpc.Request) (arpc.Response, error) {
 		return arpc.Response{}, err
 	}
 
-	handle, exists := s.handles.Get(uint64(payload.HandleID))
+	handle, exists := s.removeHandle(payload.HandleID)
 	if !exists {
 		return arpc.Response{}, os.ErrNotExist
 	}
 
-	// Close the Windows handle directly
-	windows.CloseHandle(handle.handle)
-	s.handles.Del(uint64(payload.HandleID))
+	handle.close()
 
 	closed := arpc.StringMsg("closed")
 	data, err := closed.Encode()
@@ -0,0 +1,52 @@
+package agentfs
+
+import (
+	"errors"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+)
+
+// MaxChangedFiles bounds the paths a change set lists. Past it, enumerating
+// the changes is not much cheaper than walking the tree.
+const MaxChangedFiles = 100_000
+
+var (
+	// ErrChangeJournalUnavailable is returned when the volume has no
+	// change journal, or on platforms without one.
+	ErrChangeJournalUnavailable = errors.New("change journal unavailable")
+	// ErrChangeJournalTruncated is returned when the journal no longer goes
+	// back to the requested USN, or was recreated since.
+	ErrChangeJournalTruncated = errors.New("change journal does not cover the requested range")
+	// ErrTooManyChanges is returned when more than MaxChangedFiles paths
+	// changed.
+	ErrTooManyChanges = errors.New("too many changed files")
+)
+
+// ChangeSet lists the paths changed in a range of a change journal. Paths
+// are slash-separated and relative to the root of the volume; deleted and
+// renamed entries are listed under their old and new names.
+type ChangeSet struct {
+	Paths []string
+	// NextUSN is the watermark to read the next change set from.
+	NextUSN int64
+}
+
+// handleChangedFiles reports the changes the session was given through
+// AgentFSOptions.ChangedFiles. Without one the agent falls back to a full
+// walk, which the reply tells the server.
+func (s *AgentFSServer) handleChangedFiles(req arpc.Request) (arpc.Response, error) {
+	changed := types.ChangedFiles{}
+	if s.changedFiles != nil {
+		changed = *s.changedFiles
+	}
+
+	data, err := changed.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	return arpc.Response{
+		Status: 200,
+		Data:   data,
+	}, nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package agentfs
+
+// ChangeJournal reads the change journal of a volume. Only NTFS volumes on
+// Windows have one.
+type ChangeJournal struct {
+	ID      uint64
+	NextUSN int64
+}
+
+// OpenChangeJournal always fails outside Windows.
+func OpenChangeJournal(volume string) (*ChangeJournal, error) {
+	return nil, ErrChangeJournalUnavailable
+}
+
+func (j *ChangeJournal) Close() error {
+	return nil
+}
+
+func (j *ChangeJournal) ChangedFilesSince(usn int64) (ChangeSet, error) {
+	return ChangeSet{}, ErrChangeJournalUnavailable
+}
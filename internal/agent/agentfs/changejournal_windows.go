@@ -0,0 +1,236 @@
+//go:build windows
+
+package agentfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	fsctlQueryUSNJournal = 0x000900f4
+	fsctlReadUSNJournal  = 0x000900bb
+
+	// usnRecordV2Size is the size of a USN_RECORD_V2 up to the file name.
+	usnRecordV2Size = 60
+
+	usnJournalBufferSize = 64 * 1024
+
+	volumeNameNone = 0x4
+)
+
+var procOpenFileById = modkernel32.NewProc("OpenFileById")
+
+// usnJournalData mirrors USN_JOURNAL_DATA_V0.
+type usnJournalData struct {
+	UsnJournalID    uint64
+	FirstUsn        int64
+	NextUsn         int64
+	LowestValidUsn  int64
+	MaxUsn          int64
+	MaximumSize     uint64
+	AllocationDelta uint64
+}
+
+// readUSNJournalData mirrors READ_USN_JOURNAL_DATA_V0, which makes the
+// journal return USN_RECORD_V2 records.
+type readUSNJournalData struct {
+	StartUsn          int64
+	ReasonMask        uint32
+	ReturnOnlyOnClose uint32
+	Timeout           uint64
+	BytesToWaitFor    uint64
+	UsnJournalID      uint64
+}
+
+// fileIDDescriptor mirrors FILE_ID_DESCRIPTOR with a 64-bit file ID.
+type fileIDDescriptor struct {
+	Size   uint32
+	Type   uint32
+	FileID uint64
+	_      uint64
+}
+
+// ChangeJournal reads the USN change journal of an NTFS volume. ID and
+// NextUSN are the identity and the end of the journal when it was opened;
+// a watermark from another journal ID cannot be read from.
+type ChangeJournal struct {
+	volume   windows.Handle
+	ID       uint64
+	NextUSN  int64
+	firstUSN int64
+}
+
+// OpenChangeJournal opens the change journal of volume, a drive letter with
+// or without its colon. Reading the journal requires administrator rights.
+func OpenChangeJournal(volume string) (*ChangeJournal, error) {
+	letter := strings.TrimSuffix(volume, ":")
+	if len(letter) != 1 {
+		return nil, fmt.Errorf("%w: invalid volume %q", ErrChangeJournalUnavailable, volume)
+	}
+
+	volumePath, err := windows.UTF16PtrFromString(`\\.\` + letter + ":")
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.CreateFile(volumePath,
+		windows.GENERIC_READ,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil,
+		windows.OPEN_EXISTING,
+		0,
+		0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: opening volume %s: %v", ErrChangeJournalUnavailable, letter, err)
+	}
+
+	var data usnJournalData
+	var returned uint32
+	err = windows.DeviceIoControl(handle, fsctlQueryUSNJournal, nil, 0,
+		(*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), &returned, nil)
+	if err != nil {
+		windows.CloseHandle(handle)
+		return nil, fmt.Errorf("%w: querying journal of %s: %v", ErrChangeJournalUnavailable, letter, err)
+	}
+
+	return &ChangeJournal{
+		volume:   handle,
+		ID:       data.UsnJournalID,
+		NextUSN:  data.NextUsn,
+		firstUSN: data.FirstUsn,
+	}, nil
+}
+
+func (j *ChangeJournal) Close() error {
+	return windows.CloseHandle(j.volume)
+}
+
+// ChangedFilesSince lists the paths changed between usn and the end of the
+// journal as it was when opened.
+func (j *ChangeJournal) ChangedFilesSince(usn int64) (ChangeSet, error) {
+	if usn < j.firstUSN || usn > j.NextUSN {
+		return ChangeSet{}, ErrChangeJournalTruncated
+	}
+
+	// Entries are named by their parent directory, which stays resolvable
+	// after the entry itself was deleted or renamed.
+	names := make(map[uint64]map[string]struct{})
+	count := 0
+
+	buf := make([]byte, usnJournalBufferSize)
+	next := usn
+	for next < j.NextUSN {
+		read := readUSNJournalData{
+			StartUsn:     next,
+			ReasonMask:   0xFFFFFFFF,
+			UsnJournalID: j.ID,
+		}
+		var returned uint32
+		err := windows.DeviceIoControl(j.volume, fsctlReadUSNJournal,
+			(*byte)(unsafe.Pointer(&read)), uint32(unsafe.Sizeof(read)),
+			&buf[0], uint32(len(buf)), &returned, nil)
+		if err != nil {
+			if errors.Is(err, windows.ERROR_JOURNAL_ENTRY_DELETED) || errors.Is(err, windows.ERROR_JOURNAL_DELETE_IN_PROGRESS) {
+				return ChangeSet{}, ErrChangeJournalTruncated
+			}
+			return ChangeSet{}, fmt.Errorf("reading change journal: %w", err)
+		}
+		if returned < 8 {
+			break
+		}
+
+		records := buf[8:returned]
+		for len(records) >= usnRecordV2Size {
+			length := binary.LittleEndian.Uint32(records[0:])
+			if length < usnRecordV2Size || int(length) > len(records) {
+				return ChangeSet{}, fmt.Errorf("reading change journal: corrupt record")
+			}
+			record := records[:length]
+			records = records[length:]
+
+			if binary.LittleEndian.Uint16(record[4:]) != 2 {
+				continue
+			}
+			if int64(binary.LittleEndian.Uint64(record[24:])) >= j.NextUSN {
+				continue
+			}
+
+			parent := binary.LittleEndian.Uint64(record[16:])
+			nameLength := int(binary.LittleEndian.Uint16(record[56:]))
+			nameOffset := int(binary.LittleEndian.Uint16(record[58:]))
+			if nameOffset+nameLength > len(record) {
+				return ChangeSet{}, fmt.Errorf("reading change journal: corrupt record")
+			}
+			name := windows.UTF16ToString(unsafe.Slice((*uint16)(unsafe.Pointer(&record[nameOffset])), nameLength/2))
+
+			if names[parent] == nil {
+				names[parent] = make(map[string]struct{})
+			}
+			if _, seen := names[parent][name]; !seen {
+				names[parent][name] = struct{}{}
+				count++
+				if count > MaxChangedFiles {
+					return ChangeSet{}, ErrTooManyChanges
+				}
+			}
+		}
+
+		following := int64(binary.LittleEndian.Uint64(buf[0:]))
+		if following <= next {
+			break
+		}
+		next = following
+	}
+
+	paths := make([]string, 0, count)
+	for parent, children := range names {
+		dir, err := j.resolveDir(parent)
+		if err != nil {
+			// The directory is gone, and with it everything below.
+			continue
+		}
+		for name := range children {
+			paths = append(paths, path.Join(dir, name))
+		}
+	}
+	sort.Strings(paths)
+
+	return ChangeSet{Paths: paths, NextUSN: j.NextUSN}, nil
+}
+
+// resolveDir returns the slash-separated path, relative to the volume root,
+// of the directory with the given file reference number.
+func (j *ChangeJournal) resolveDir(ref uint64) (string, error) {
+	desc := fileIDDescriptor{
+		Size:   uint32(unsafe.Sizeof(fileIDDescriptor{})),
+		FileID: ref,
+	}
+	r, _, callErr := procOpenFileById.Call(
+		uintptr(j.volume),
+		uintptr(unsafe.Pointer(&desc)),
+		0,
+		uintptr(windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE),
+		0,
+		uintptr(windows.FILE_FLAG_BACKUP_SEMANTICS),
+	)
+	handle := windows.Handle(r)
+	if handle == windows.InvalidHandle {
+		return "", callErr
+	}
+	defer windows.CloseHandle(handle)
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	n, err := windows.GetFinalPathNameByHandle(handle, &buf[0], uint32(len(buf)), volumeNameNone)
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimPrefix(windows.UTF16ToString(buf[:n]), `\`)
+	return strings.ReplaceAll(dir, `\`, "/"), nil
+}
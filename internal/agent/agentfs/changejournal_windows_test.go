@@ -0,0 +1,54 @@
+//go:build windows
+
+package agentfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedFilesSince(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+	}
+
+	volume := filepath.VolumeName(dir)
+	journal, err := OpenChangeJournal(volume)
+	if err != nil {
+		t.Skipf("change journal of %s not readable: %v", volume, err)
+	}
+	start := journal.NextUSN
+	require.NoError(t, journal.Close())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("changed"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d.txt"), []byte("new"), 0644))
+
+	journal, err = OpenChangeJournal(volume)
+	require.NoError(t, err)
+	defer journal.Close()
+
+	changes, err := journal.ChangedFilesSince(start)
+	require.NoError(t, err)
+	assert.Equal(t, journal.NextUSN, changes.NextUSN)
+
+	prefix := filepath.ToSlash(strings.TrimPrefix(dir, volume+`\`)) + "/"
+	var inDir []string
+	for _, path := range changes.Paths {
+		if strings.HasPrefix(strings.ToLower(path), strings.ToLower(prefix)) {
+			inDir = append(inDir, path[len(prefix):])
+		}
+	}
+	assert.Equal(t, []string{"b.txt", "d.txt"}, inDir)
+
+	// Nothing changed since the end of the journal.
+	changes, err = journal.ChangedFilesSince(journal.NextUSN)
+	require.NoError(t, err)
+	assert.Empty(t, changes.Paths)
+}
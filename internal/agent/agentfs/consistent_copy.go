@@ -0,0 +1,224 @@
+package agentfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// Methods used to take a consistent copy of a file before it is read.
+const (
+	// CopyMethodSQLite uses the SQLite online backup API (through the sqlite3
+	// CLI) so that a database being written to is copied at a single
+	// transaction boundary.
+	CopyMethodSQLite = "sqlite"
+	// CopyMethodCopy copies the file and retries until its size and
+	// modification time did not change while copying.
+	CopyMethodCopy = "copy"
+)
+
+// sqlite3Binary is the sqlite3 CLI used by CopyMethodSQLite.
+var sqlite3Binary = "sqlite3"
+
+// copyStableAttempts bounds the retries of CopyMethodCopy.
+const copyStableAttempts = 3
+
+// ConsistentCopyRule maps a glob pattern to the method used to copy the
+// matching files. Patterns containing a slash are matched against the path
+// relative to the backup root (with a leading slash); other patterns are
+// matched against the file name.
+type ConsistentCopyRule struct {
+	Pattern string
+	Method  string
+}
+
+// ParseConsistentCopyRules parses one "pattern = method" rule per line.
+// Blank lines and lines starting with # are ignored.
+func ParseConsistentCopyRules(value string) ([]ConsistentCopyRule, error) {
+	var rules []ConsistentCopyRule
+	for i, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		idx := strings.LastIndex(line, "=")
+		if idx < 0 {
+			return nil, fmt.Errorf("line %d: expected \"pattern = method\"", i+1)
+		}
+		rule := ConsistentCopyRule{
+			Pattern: strings.TrimSpace(line[:idx]),
+			Method:  strings.TrimSpace(line[idx+1:]),
+		}
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil || rule.Pattern == "" {
+			return nil, fmt.Errorf("line %d: invalid pattern %q", i+1, rule.Pattern)
+		}
+		switch rule.Method {
+		case CopyMethodSQLite, CopyMethodCopy:
+		default:
+			return nil, fmt.Errorf("line %d: unknown copy method %q", i+1, rule.Method)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matchConsistentCopyRule returns the rule that applies to relPath, if any.
+func matchConsistentCopyRule(rules []ConsistentCopyRule, relPath string) (ConsistentCopyRule, bool) {
+	relPath = "/" + strings.TrimPrefix(filepath.ToSlash(relPath), "/")
+	name := filepath.Base(relPath)
+	for _, rule := range rules {
+		target := name
+		if strings.Contains(rule.Pattern, "/") {
+			target = relPath
+		}
+		if ok, _ := filepath.Match(rule.Pattern, target); ok {
+			return rule, true
+		}
+	}
+	return ConsistentCopyRule{}, false
+}
+
+// consistentCopier keeps the consistent copies taken during one backup
+// session. Each file is copied once, on first access, and the copy is served
+// for the rest of the session.
+type consistentCopier struct {
+	rules  []ConsistentCopyRule
+	mu     sync.Mutex
+	dir    string
+	copies map[string]string
+}
+
+func newConsistentCopier(rules []ConsistentCopyRule) *consistentCopier {
+	return &consistentCopier{
+		rules:  rules,
+		copies: make(map[string]string),
+	}
+}
+
+// resolve returns the path that should be read for fullPath. Files without
+// a matching rule, and files whose copy fails, are read in place.
+func (c *consistentCopier) resolve(relPath, fullPath string) string {
+	if c == nil || len(c.rules) == 0 {
+		return fullPath
+	}
+
+	rule, ok := matchConsistentCopyRule(c.rules, relPath)
+	if !ok {
+		return fullPath
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return fullPath
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if copyPath, ok := c.copies[fullPath]; ok {
+		return copyPath
+	}
+
+	if c.dir == "" {
+		c.dir, err = os.MkdirTemp("", "pbs-plus-consistent-copy-")
+		if err != nil {
+			syslog.L.Error(err).WithMessage("failed to create consistent copy directory").Write()
+			return fullPath
+		}
+	}
+
+	copyPath := filepath.Join(c.dir, strconv.Itoa(len(c.copies)))
+	switch rule.Method {
+	case CopyMethodSQLite:
+		err = copySQLite(fullPath, copyPath)
+	default:
+		err = copyStable(fullPath, copyPath)
+	}
+	if err != nil {
+		_ = os.Remove(copyPath)
+		syslog.L.Warn().
+			WithMessage("consistent copy failed, reading file in place").
+			WithField("path", fullPath).
+			WithField("method", rule.Method).
+			WithField("error", err.Error()).
+			Write()
+		return fullPath
+	}
+
+	c.copies[fullPath] = copyPath
+	return copyPath
+}
+
+// Close removes all copies taken by the session.
+func (c *consistentCopier) Close() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dir != "" {
+		_ = os.RemoveAll(c.dir)
+		c.dir = ""
+	}
+	c.copies = make(map[string]string)
+}
+
+// copySQLite takes an online backup of the database at src.
+func copySQLite(src, dst string) error {
+	cmd := exec.Command(sqlite3Binary, "-readonly", src, ".backup '"+strings.ReplaceAll(dst, "'", "''")+"'")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("sqlite3 backup: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// copyStable copies src to dst, retrying while src changes underneath.
+func copyStable(src, dst string) error {
+	for attempt := 0; attempt < copyStableAttempts; attempt++ {
+		before, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+		after, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		if before.Size() == after.Size() && before.ModTime().Equal(after.ModTime()) {
+			return nil
+		}
+	}
+	return errors.New("file kept changing while being copied")
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
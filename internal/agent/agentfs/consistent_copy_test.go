@@ -0,0 +1,83 @@
+//go:build linux
+
+package agentfs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConsistentCopyRules(t *testing.T) {
+	rules, err := ParseConsistentCopyRules(`
+# databases
+*.sqlite = sqlite
+/var/lib/app/*.db = sqlite
+
+/srv/state.json = copy
+`)
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	assert.Equal(t, ConsistentCopyRule{Pattern: "*.sqlite", Method: CopyMethodSQLite}, rules[0])
+	assert.Equal(t, ConsistentCopyRule{Pattern: "/srv/state.json", Method: CopyMethodCopy}, rules[2])
+
+	rule, ok := matchConsistentCopyRule(rules, "home/user/app.sqlite")
+	assert.True(t, ok)
+	assert.Equal(t, CopyMethodSQLite, rule.Method)
+
+	_, ok = matchConsistentCopyRule(rules, "var/lib/app/main.db")
+	assert.True(t, ok)
+	_, ok = matchConsistentCopyRule(rules, "var/lib/other/main.db")
+	assert.False(t, ok)
+
+	_, err = ParseConsistentCopyRules("*.db = rsync")
+	assert.Error(t, err)
+	_, err = ParseConsistentCopyRules("*.db")
+	assert.Error(t, err)
+}
+
+func TestConsistentCopySQLite(t *testing.T) {
+	if _, err := exec.LookPath(sqlite3Binary); err != nil {
+		t.Skip("sqlite3 not available")
+	}
+
+	root := t.TempDir()
+	dbPath := filepath.Join(root, "app.sqlite")
+
+	// Leave committed rows in the WAL so a plain file copy would miss them.
+	out, err := exec.Command(sqlite3Binary, dbPath,
+		"PRAGMA journal_mode=WAL;",
+		"PRAGMA wal_autocheckpoint=0;",
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);",
+		"INSERT INTO items (name) VALUES ('a'), ('b'), ('c');",
+	).CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	copier := newConsistentCopier([]ConsistentCopyRule{{Pattern: "*.sqlite", Method: CopyMethodSQLite}})
+	defer copier.Close()
+
+	copyPath := copier.resolve("app.sqlite", dbPath)
+	require.NotEqual(t, dbPath, copyPath)
+
+	// The copy is taken once per session.
+	assert.Equal(t, copyPath, copier.resolve("app.sqlite", dbPath))
+
+	// The copy is a self-contained database with every committed row.
+	out, err = exec.Command(sqlite3Binary, "-readonly", copyPath, "SELECT count(*) FROM items;").CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Equal(t, "3", strings.TrimSpace(string(out)))
+
+	// Unmatched files are read in place.
+	other := filepath.Join(root, "notes.txt")
+	require.NoError(t, os.WriteFile(other, []byte("notes"), 0644))
+	assert.Equal(t, other, copier.resolve("notes.txt", other))
+
+	copier.Close()
+	_, err = os.Stat(copyPath)
+	assert.True(t, os.IsNotExist(err))
+}
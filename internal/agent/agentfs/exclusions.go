@@ -0,0 +1,87 @@
+package agentfs
+
+import (
+	"os"
+	"path"
+	"runtime"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+)
+
+// caseInsensitivePaths tells whether the served volumes resolve names
+// regardless of case, as Windows volumes do.
+const caseInsensitivePaths = runtime.GOOS == "windows"
+
+// handleSetExclusions compiles the exclusion rules pushed by the server.
+// From then on listings leave out what they exclude, so excluded entries
+// never cross the wire. An empty rule set turns filtering off.
+func (s *AgentFSServer) handleSetExclusions(req arpc.Request) (arpc.Response, error) {
+	var payload types.ExclusionsReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	if len(payload.Rules) == 0 {
+		s.exclusions.Store(nil)
+		return arpc.Response{Status: 200}, nil
+	}
+
+	matcher, err := pattern.NewMatcher(pattern.EffectiveFilters{Rules: payload.Rules})
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	s.exclusions.Store(pattern.NewRootedMatcher(matcher, payload.Root, caseInsensitivePaths))
+
+	return arpc.Response{Status: 200}, nil
+}
+
+// handleExclusionStats reports how many entries the exclusions left out of
+// listings so far.
+func (s *AgentFSServer) handleExclusionStats(req arpc.Request) (arpc.Response, error) {
+	stats := types.ExclusionStats{Excluded: s.excludedCount.Load()}
+	data, err := stats.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	return arpc.Response{
+		Status: 200,
+		Data:   data,
+	}, nil
+}
+
+// listingFilter returns the filter for the entries of dir, a slash-separated
+// path relative to the snapshot, or nil when nothing is excluded. Entries
+// that failed to stat are kept so the server can report them.
+func (s *AgentFSServer) listingFilter(dir string) func(types.AgentDirEntry) bool {
+	exclusions := s.exclusions.Load()
+	if exclusions == nil {
+		return nil
+	}
+
+	return func(entry types.AgentDirEntry) bool {
+		if entry.Err != "" {
+			return true
+		}
+		if exclusions.Excluded(path.Join(dir, entry.Name), os.FileMode(entry.Mode).IsDir()) {
+			s.excludedCount.Add(1)
+			return false
+		}
+		return true
+	}
+}
+
+// filterEntries drops the entries keep rejects, in place.
+func filterEntries(batch types.ReadDirEntries, keep func(types.AgentDirEntry) bool) types.ReadDirEntries {
+	if keep == nil {
+		return batch
+	}
+	kept := batch[:0]
+	for _, entry := range batch {
+		if keep(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
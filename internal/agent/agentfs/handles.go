@@ -0,0 +1,191 @@
+package agentfs
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// DefaultMaxOpenHandles is the number of handles a server keeps open when
+// AgentFSOptions.MaxOpenHandles is zero.
+const DefaultMaxOpenHandles = 4096
+
+// DefaultIdleHandleTimeout is how long a handle can go unused before the
+// server closes it when AgentFSOptions.IdleHandleTimeout is zero.
+const DefaultIdleHandleTimeout = 30 * time.Minute
+
+// handleState tracks the use of an open handle, so the server can close the
+// ones a client left behind without pulling them from under a request.
+type handleState struct {
+	mu       sync.Mutex
+	busy     int
+	closed   bool
+	lastUsed atomic.Int64
+}
+
+func (h *handleState) touch() {
+	h.lastUsed.Store(time.Now().UnixNano())
+}
+
+// addHandle registers fh and returns its ID. At the handle limit the least
+// recently used idle handle is closed to make room; when every handle is in
+// use, it fails with EMFILE.
+func (s *AgentFSServer) addHandle(fh *FileHandle) (uint64, error) {
+	fh.touch()
+
+	s.handlesMu.Lock()
+	defer s.handlesMu.Unlock()
+
+	for s.handles.Len() >= s.maxOpenHandles {
+		if !s.evictLRU() {
+			return 0, syscall.EMFILE
+		}
+	}
+
+	handleId := s.handleIdGen.NextID()
+	s.handles.Set(handleId, fh)
+	return handleId, nil
+}
+
+// evictLRU closes the idle handle that was used the longest time ago and
+// reports whether there was one.
+func (s *AgentFSServer) evictLRU() bool {
+	for {
+		var (
+			oldestId uint64
+			oldest   *FileHandle
+		)
+		s.handles.ForEach(func(id uint64, fh *FileHandle) bool {
+			fh.mu.Lock()
+			idle := fh.busy == 0 && !fh.closed
+			fh.mu.Unlock()
+			if idle && (oldest == nil || fh.lastUsed.Load() < oldest.lastUsed.Load()) {
+				oldestId, oldest = id, fh
+			}
+			return true
+		})
+		if oldest == nil {
+			return false
+		}
+		// The handle may have been picked up since it was looked at.
+		if s.closeIdleHandle(oldestId, oldest, "open handle limit reached") {
+			return true
+		}
+	}
+}
+
+// closeIdleHandle closes a handle the client did not close, unless a request
+// is using it, and reports whether it did.
+func (s *AgentFSServer) closeIdleHandle(id uint64, fh *FileHandle, reason string) bool {
+	fh.mu.Lock()
+	if fh.busy > 0 || fh.closed {
+		fh.mu.Unlock()
+		return false
+	}
+	fh.closed = true
+	fh.mu.Unlock()
+
+	s.handles.Del(id)
+	fh.close()
+
+	if syslog.L != nil {
+		syslog.L.Warn().
+			WithMessage("closed file handle left open by the client").
+			WithField("jobId", s.jobId).
+			WithField("path", fh.path).
+			WithField("idle", time.Since(time.Unix(0, fh.lastUsed.Load())).String()).
+			WithField("reason", reason).
+			Write()
+	}
+	return true
+}
+
+// sweepIdleHandles closes the handles unused for longer than timeout every
+// half timeout, until the server is closed.
+func (s *AgentFSServer) sweepIdleHandles(timeout time.Duration) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.closeIdleHandles(timeout)
+		}
+	}
+}
+
+// closeIdleHandles closes the handles that no request used for longer than
+// timeout.
+func (s *AgentFSServer) closeIdleHandles(timeout time.Duration) {
+	cutoff := time.Now().Add(-timeout).UnixNano()
+	s.handles.ForEach(func(id uint64, fh *FileHandle) bool {
+		if fh.lastUsed.Load() < cutoff {
+			s.closeIdleHandle(id, fh, "idle timeout")
+		}
+		return true
+	})
+}
+
+// acquireHandle returns the handle with the given ID and marks it in use
+// until release is called, so it is not closed for being idle meanwhile.
+func (s *AgentFSServer) acquireHandle(id types.FileHandleId) (fh *FileHandle, release func(), err error) {
+	fh, exists := s.handles.Get(uint64(id))
+	if !exists {
+		return nil, nil, os.ErrNotExist
+	}
+
+	fh.mu.Lock()
+	if fh.closed {
+		fh.mu.Unlock()
+		return nil, nil, os.ErrNotExist
+	}
+	fh.busy++
+	fh.mu.Unlock()
+	fh.touch()
+
+	return fh, func() {
+		fh.touch()
+		fh.mu.Lock()
+		fh.busy--
+		fh.mu.Unlock()
+	}, nil
+}
+
+// removeHandle takes the handle with the given ID out of the server for the
+// client to close it.
+func (s *AgentFSServer) removeHandle(id types.FileHandleId) (*FileHandle, bool) {
+	fh, exists := s.handles.GetAndDel(uint64(id))
+	if !exists {
+		return nil, false
+	}
+
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if fh.closed {
+		return nil, false
+	}
+	fh.closed = true
+	return fh, true
+}
+
+func (s *AgentFSServer) closeFileHandles() {
+	s.handles.ForEach(func(u uint64, fh *FileHandle) bool {
+		fh.mu.Lock()
+		closed := fh.closed
+		fh.closed = true
+		fh.mu.Unlock()
+		if !closed {
+			fh.close()
+		}
+		return true
+	})
+
+	s.handles.Clear()
+}
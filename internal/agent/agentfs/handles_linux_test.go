@@ -0,0 +1,159 @@
+//go:build linux
+
+package agentfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestHandle(t *testing.T, server *AgentFSServer, path string) (types.FileHandleId, error) {
+	t.Helper()
+	encoded, err := (&types.OpenFileReq{Path: path}).Encode()
+	require.NoError(t, err)
+	resp, err := server.handleOpenFile(arpc.Request{Payload: encoded})
+	if err != nil {
+		return 0, err
+	}
+	var handle types.FileHandleId
+	require.NoError(t, handle.Decode(resp.Data))
+	return handle, nil
+}
+
+func TestMaxOpenHandles(t *testing.T) {
+	testDir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(testDir, fmt.Sprintf("file%d.txt", i)), []byte("data"), 0644))
+	}
+
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{MaxOpenHandles: 3})
+	defer server.Close()
+
+	var ids []types.FileHandleId
+	var files []*os.File
+	for i := 0; i < 3; i++ {
+		id, err := openTestHandle(t, server, fmt.Sprintf("file%d.txt", i))
+		require.NoError(t, err)
+		fh, ok := server.handles.Get(uint64(id))
+		require.True(t, ok)
+		ids = append(ids, id)
+		files = append(files, fh.file)
+	}
+
+	// Using the first handle makes the second the least recently used.
+	_, release, err := server.acquireHandle(ids[0])
+	require.NoError(t, err)
+	release()
+
+	_, err = openTestHandle(t, server, "file3.txt")
+	require.NoError(t, err)
+	assert.Equal(t, 3, server.handles.Len())
+
+	_, _, err = server.acquireHandle(ids[1])
+	assert.ErrorIs(t, err, os.ErrNotExist, "least recently used handle is evicted")
+	_, err = files[1].Stat()
+	assert.ErrorIs(t, err, os.ErrClosed, "evicted handle is closed")
+
+	for _, i := range []int{0, 2} {
+		_, release, err := server.acquireHandle(ids[i])
+		require.NoError(t, err, "handle %d is kept", i)
+		release()
+		_, err = files[i].Stat()
+		assert.NoError(t, err)
+	}
+
+	t.Run("all handles in use", func(t *testing.T) {
+		var releases []func()
+		server.handles.ForEach(func(id uint64, fh *FileHandle) bool {
+			_, release, err := server.acquireHandle(types.FileHandleId(id))
+			require.NoError(t, err)
+			releases = append(releases, release)
+			return true
+		})
+
+		_, err := openTestHandle(t, server, "file4.txt")
+		assert.ErrorIs(t, err, syscall.EMFILE)
+		assert.Equal(t, 3, server.handles.Len())
+
+		for _, release := range releases {
+			release()
+		}
+		_, err = openTestHandle(t, server, "file4.txt")
+		assert.NoError(t, err)
+	})
+}
+
+func TestIdleHandleTimeout(t *testing.T) {
+	testDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "idle.txt"), []byte("idle"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "active.txt"), make([]byte, 64*1024), 0644))
+
+	const timeout = 200 * time.Millisecond
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{IdleHandleTimeout: timeout})
+	defer server.Close()
+
+	idle, err := openTestHandle(t, server, "idle.txt")
+	require.NoError(t, err)
+	idleHandle, ok := server.handles.Get(uint64(idle))
+	require.True(t, ok)
+	active, err := openTestHandle(t, server, "active.txt")
+	require.NoError(t, err)
+
+	// Keep reading the active handle from several goroutines while the
+	// sweeper runs.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				encoded, err := (&types.LseekReq{HandleID: active, Offset: 0, Whence: io.SeekStart}).Encode()
+				if !assert.NoError(t, err) {
+					return
+				}
+				if _, err := server.handleLseek(arpc.Request{Payload: encoded}); !assert.NoError(t, err) {
+					return
+				}
+				encoded, err = (&types.ReadAtReq{HandleID: active, Offset: 0, Length: 4096}).Encode()
+				if !assert.NoError(t, err) {
+					return
+				}
+				if _, err := server.handleReadAt(arpc.Request{Payload: encoded}); !assert.NoError(t, err) {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+	}
+
+	assert.Eventually(t, func() bool {
+		_, ok := server.handles.Get(uint64(idle))
+		return !ok
+	}, 5*timeout, 10*time.Millisecond, "idle handle is reaped")
+	close(stop)
+	wg.Wait()
+
+	_, err = idleHandle.file.Stat()
+	assert.ErrorIs(t, err, os.ErrClosed)
+	_, release, err := server.acquireHandle(active)
+	require.NoError(t, err, "active handle survives")
+	release()
+}
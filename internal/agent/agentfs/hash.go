@@ -0,0 +1,82 @@
+package agentfs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"os"
+	"sync"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/zeebo/blake3"
+	"github.com/zeebo/xxh3"
+)
+
+// hashBufPool holds the buffers used to feed file ranges to a hash when they
+// cannot be hashed in place.
+var hashBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 1024*1024)
+		return &b
+	},
+}
+
+func newHasher(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case types.HashXXH3:
+		return xxh3.New(), nil
+	case types.HashSHA256:
+		return sha256.New(), nil
+	case types.HashBLAKE3:
+		return blake3.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %q", algorithm)
+	}
+}
+
+// handleHashAt hashes a range of an open file on the agent so the caller can
+// tell whether it changed without transferring it. A range running past EOF
+// is hashed up to EOF.
+func (s *AgentFSServer) handleHashAt(req arpc.Request) (arpc.Response, error) {
+	var payload types.HashReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+	if payload.Offset < 0 || payload.Length < 0 {
+		return arpc.Response{}, os.ErrInvalid
+	}
+
+	h, err := newHasher(payload.Algorithm)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	fh, release, err := s.acquireHandle(payload.HandleID)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	defer release()
+	if fh.isDir {
+		return arpc.Response{}, os.ErrInvalid
+	}
+
+	hashed, err := s.hashRange(fh, payload.Offset, payload.Length, h)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	resp := types.HashResp{
+		Length: hashed,
+		Digest: h.Sum(nil),
+	}
+	respBytes, err := resp.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	return arpc.Response{
+		Status: 200,
+		Data:   respBytes,
+	}, nil
+}
@@ -3,7 +3,9 @@
 package agentfs
 
 import (
+	"errors"
 	"os"
+	"unsafe"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 	"golang.org/x/sys/windows"
@@ -26,3 +28,78 @@ func mapWinError(err error, helper string) error {
 		}
 	}
 }
+
+// isTransientLockError reports whether err is a sharing or lock violation or
+// an access denial. Antivirus scanners cause all three while they hold a
+// file, and they clear once the scan is done.
+func isTransientLockError(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) ||
+		errors.Is(err, windows.ERROR_LOCK_VIOLATION) ||
+		errors.Is(err, windows.ERROR_ACCESS_DENIED)
+}
+
+// openForRead opens path for a sequential read with backup semantics, so
+// files the agent account has no ACL entry for are readable too. Opens that
+// hit a briefly locked file are retried.
+func (s *AgentFSServer) openForRead(path string) (*os.File, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var handle windows.Handle
+	err = s.lockRetry.do(func() error {
+		var err error
+		handle, err = windows.CreateFile(
+			pathPtr,
+			windows.GENERIC_READ,
+			windows.FILE_SHARE_READ,
+			nil,
+			windows.OPEN_EXISTING,
+			windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_SEQUENTIAL_SCAN,
+			0,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, mapWinError(err, "openForRead CreateFile")
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
+
+// reparseTag returns the reparse tag of the file at path without following
+// it, 0 when it is not a reparse point.
+func reparseTag(path string) (uint32, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	handle, err := windows.CreateFile(
+		pathPtr,
+		windows.FILE_READ_ATTRIBUTES,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OPEN_REPARSE_POINT,
+		0,
+	)
+	if err != nil {
+		return 0, mapWinError(err, "reparseTag CreateFile")
+	}
+	defer windows.CloseHandle(handle)
+
+	// FILE_ATTRIBUTE_TAG_INFO
+	var info struct {
+		FileAttributes uint32
+		ReparseTag     uint32
+	}
+	err = windows.GetFileInformationByHandleEx(handle, windows.FileAttributeTagInfo, (*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+	if err != nil {
+		return 0, mapWinError(err, "reparseTag GetFileInformationByHandleEx")
+	}
+	if info.FileAttributes&windows.FILE_ATTRIBUTE_REPARSE_POINT == 0 {
+		return 0, nil
+	}
+	return info.ReparseTag, nil
+}
@@ -0,0 +1,70 @@
+package agentfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockRetryPolicy controls how opening and reading a file is retried when
+// another process holds it locked for a moment, as real-time antivirus
+// scanners do. Only Windows reports such locks; elsewhere the policy is a
+// no-op.
+type LockRetryPolicy struct {
+	// Attempts is the number of retries after the first try. Zero disables
+	// retrying.
+	Attempts int
+	// Delay is the wait before the first retry; it doubles for each retry
+	// after that.
+	Delay time.Duration
+}
+
+// DefaultLockRetryPolicy waits up to about 350ms in total for a lock to
+// clear.
+var DefaultLockRetryPolicy = LockRetryPolicy{
+	Attempts: 3,
+	Delay:    50 * time.Millisecond,
+}
+
+// transientLock reports whether err is a lock expected to clear on its own.
+// It is a variable so tests can inject transient failures.
+var transientLock = isTransientLockError
+
+// ParseLockRetryPolicy parses the retry count and the initial delay in
+// milliseconds. An empty value keeps the default for that field.
+func ParseLockRetryPolicy(attempts, delayMs string) (LockRetryPolicy, error) {
+	policy := DefaultLockRetryPolicy
+
+	if attempts = strings.TrimSpace(attempts); attempts != "" {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 0 {
+			return DefaultLockRetryPolicy, fmt.Errorf("invalid lock retry attempts %q", attempts)
+		}
+		policy.Attempts = n
+	}
+
+	if delayMs = strings.TrimSpace(delayMs); delayMs != "" {
+		ms, err := strconv.Atoi(delayMs)
+		if err != nil || ms < 0 {
+			return DefaultLockRetryPolicy, fmt.Errorf("invalid lock retry delay %q", delayMs)
+		}
+		policy.Delay = time.Duration(ms) * time.Millisecond
+	}
+
+	return policy, nil
+}
+
+// do runs op until it succeeds, fails with an error other than a transient
+// lock, or the retries run out. The last error is returned.
+func (p LockRetryPolicy) do(op func() error) error {
+	delay := p.Delay
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= p.Attempts || !transientLock(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,91 @@
+package agentfs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errFakeLock = errors.New("file is locked by another process")
+
+func injectTransientLock(t *testing.T) {
+	t.Helper()
+	orig := transientLock
+	transientLock = func(err error) bool {
+		return errors.Is(err, errFakeLock)
+	}
+	t.Cleanup(func() { transientLock = orig })
+}
+
+func TestLockRetryPolicy(t *testing.T) {
+	injectTransientLock(t)
+
+	policy := LockRetryPolicy{Attempts: 3, Delay: time.Millisecond}
+
+	t.Run("RecoversFromTransientLock", func(t *testing.T) {
+		calls := 0
+		err := policy.do(func() error {
+			calls++
+			if calls < 3 {
+				return errFakeLock
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("GivesUpAfterAttempts", func(t *testing.T) {
+		calls := 0
+		err := policy.do(func() error {
+			calls++
+			return errFakeLock
+		})
+		assert.ErrorIs(t, err, errFakeLock)
+		assert.Equal(t, 4, calls, "first try plus three retries")
+	})
+
+	t.Run("DoesNotRetryOtherErrors", func(t *testing.T) {
+		calls := 0
+		notFound := errors.New("not found")
+		err := policy.do(func() error {
+			calls++
+			return notFound
+		})
+		assert.ErrorIs(t, err, notFound)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("ZeroValueDoesNotRetry", func(t *testing.T) {
+		calls := 0
+		err := LockRetryPolicy{}.do(func() error {
+			calls++
+			return errFakeLock
+		})
+		assert.ErrorIs(t, err, errFakeLock)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestParseLockRetryPolicy(t *testing.T) {
+	policy, err := ParseLockRetryPolicy("", "")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultLockRetryPolicy, policy)
+
+	policy, err = ParseLockRetryPolicy("5", "200")
+	require.NoError(t, err)
+	assert.Equal(t, LockRetryPolicy{Attempts: 5, Delay: 200 * time.Millisecond}, policy)
+
+	policy, err = ParseLockRetryPolicy("0", "")
+	require.NoError(t, err)
+	assert.Equal(t, 0, policy.Attempts)
+
+	_, err = ParseLockRetryPolicy("-1", "")
+	assert.Error(t, err)
+
+	_, err = ParseLockRetryPolicy("", "soon")
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+//go:build windows
+
+package agentfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+)
+
+func TestIsTransientLockError(t *testing.T) {
+	assert.True(t, isTransientLockError(windows.ERROR_SHARING_VIOLATION))
+	assert.True(t, isTransientLockError(windows.ERROR_LOCK_VIOLATION))
+	assert.True(t, isTransientLockError(&os.PathError{Op: "open", Err: windows.ERROR_ACCESS_DENIED}))
+	assert.False(t, isTransientLockError(windows.ERROR_FILE_NOT_FOUND))
+}
+
+func TestOpenFileRetriesSharingViolation(t *testing.T) {
+	testDir := t.TempDir()
+	path := filepath.Join(testDir, "scanned.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+
+	// Hold the file without sharing, like a scanner would, and let go of it
+	// shortly after the first open attempt.
+	lock, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(path),
+		windows.GENERIC_READ,
+		0,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_ATTRIBUTE_NORMAL,
+		0,
+	)
+	require.NoError(t, err)
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		windows.CloseHandle(lock)
+		close(released)
+	}()
+
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{
+		LockRetry: LockRetryPolicy{Attempts: 5, Delay: 20 * time.Millisecond},
+	})
+	defer server.Close()
+
+	payload := types.OpenFileReq{Path: "scanned.txt"}
+	encoded, err := payload.Encode()
+	require.NoError(t, err)
+
+	resp, err := server.handleOpenFile(arpc.Request{Payload: encoded})
+	<-released
+	require.NoError(t, err, "open should succeed once the lock clears")
+	assert.Equal(t, 200, resp.Status)
+}
@@ -0,0 +1,92 @@
+package agentfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	binarystream "github.com/sonroyaalmerol/pbs-plus/internal/arpc/binary"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/xtaci/smux"
+)
+
+// readDirBatchSize is the number of entries read from the directory between
+// checks for cancellation.
+const readDirBatchSize = 1024
+
+// readDirBulk lists dirPath into a single encoded ReadDirEntries, treating
+// links as policy says and leaving out the entries keep rejects. A nil keep
+// lists everything.
+func readDirBulk(ctx context.Context, dirPath string, policy SymlinkPolicy, keep func(types.AgentDirEntry) bool) ([]byte, error) {
+	var entries types.ReadDirEntries
+	err := readDirStream(ctx, dirPath, readDirBatchSize, policy, func(batch types.ReadDirEntries) error {
+		entries = append(entries, filterEntries(batch, keep)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries.Encode()
+}
+
+// handleReadDirStream lists a directory as a sequence of frames, each an
+// encoded ReadDirEntries of at most ChunkSize entries, so neither side holds
+// the whole listing of a very large directory.
+func (s *AgentFSServer) handleReadDirStream(req arpc.Request) (arpc.Response, error) {
+	var payload types.ReadDirStreamReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	chunkSize := payload.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = types.ReadDirChunkSize
+	}
+	chunkSize = min(chunkSize, types.MaxReadDirChunkSize)
+
+	fullDirPath, err := s.abs(filepath.FromSlash(payload.Path))
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	// Fail before the stream starts if the directory cannot be listed.
+	info, err := os.Stat(fullDirPath)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	if !info.IsDir() {
+		return arpc.Response{}, os.ErrInvalid
+	}
+
+	ctx := req.Context()
+	keep := s.listingFilter(filepath.ToSlash(payload.Path))
+	streamCallback := func(stream *smux.Stream) {
+		err := readDirStream(ctx, fullDirPath, chunkSize, s.symlinkPolicy, func(batch types.ReadDirEntries) error {
+			batch = filterEntries(batch, keep)
+			if len(batch) == 0 {
+				return nil
+			}
+			encoded, err := batch.Encode()
+			if err != nil {
+				return err
+			}
+			return binarystream.SendFrame(stream, encoded)
+		})
+		if err != nil {
+			// Leaving out the end marker tells the client the listing is
+			// incomplete.
+			syslog.L.Error(err).WithMessage("failed streaming directory entries").WithField("path", payload.Path).Write()
+			return
+		}
+		if err := binarystream.SendEndOfFrames(stream); err != nil {
+			syslog.L.Error(err).WithMessage("failed ending directory stream").Write()
+		}
+	}
+
+	return arpc.Response{
+		Status:    213,
+		RawStream: streamCallback,
+	}, nil
+}
@@ -3,60 +3,160 @@
 package agentfs
 
 import (
-	"errors"
+	"context"
+	"io"
 	"os"
+	"path/filepath"
 	"syscall"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 )
 
-func readDirBulk(dirPath string) ([]byte, error) {
+// entryInfo resolves the attributes of a single directory entry. It is a
+// variable so tests can inject per-entry failures.
+var entryInfo = func(entry os.DirEntry) (os.FileInfo, error) {
+	return entry.Info()
+}
+
+// readDirStream lists dirPath and hands the entries to emit in batches of at
+// most chunkSize. The batch is reused after emit returns. Entries whose
+// attributes cannot be read are returned with Err set instead of failing the
+// whole listing, and a listing that breaks off partway keeps whatever was
+// enumerated before the error. Symlinks are treated as policy says. The
+// listing stops with ctx.Err() once ctx is cancelled.
+func readDirStream(ctx context.Context, dirPath string, chunkSize int, policy SymlinkPolicy, emit func(types.ReadDirEntries) error) error {
 	// Open the directory
 	dir, err := os.Open(dirPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer dir.Close()
 
-	// Read all directory entries
-	entries, err := dir.Readdir(-1)
-	if err != nil {
-		return nil, err
-	}
+	resultEntries := make(types.ReadDirEntries, 0, chunkSize)
+	listed := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entries, err := dir.ReadDir(chunkSize)
+		listed += len(entries)
+
+		for _, entry := range entries {
+			// Skip "." and ".."
+			if entry.Name() == "." || entry.Name() == ".." {
+				continue
+			}
+
+			info, err := entryInfo(entry)
+			if err != nil {
+				// The entry vanished between listing and stat; nothing to report.
+				if os.IsNotExist(err) {
+					continue
+				}
+				resultEntries = append(resultEntries, types.AgentDirEntry{
+					Name: entry.Name(),
+					Mode: uint32(entry.Type()),
+					Err:  err.Error(),
+				})
+				continue
+			}
 
-	var resultEntries types.ReadDirEntries
+			// Get file attributes
+			stat, ok := info.Sys().(*syscall.Stat_t)
+			if !ok {
+				resultEntries = append(resultEntries, types.AgentDirEntry{
+					Name: entry.Name(),
+					Mode: uint32(entry.Type()),
+					Err:  "failed to retrieve file attributes",
+				})
+				continue
+			}
 
-	for _, entry := range entries {
-		// Skip "." and ".."
-		if entry.Name() == "." || entry.Name() == ".." {
-			continue
+			if (stat.Mode & syscall.S_IFMT) == syscall.S_IFLNK {
+				mode, ok, err := linkMode(filepath.Join(dirPath, entry.Name()), info, policy)
+				if err != nil {
+					resultEntries = append(resultEntries, types.AgentDirEntry{
+						Name: entry.Name(),
+						Mode: uint32(entry.Type()),
+						Err:  err.Error(),
+					})
+				} else if ok {
+					resultEntries = append(resultEntries, types.AgentDirEntry{
+						Name: entry.Name(),
+						Mode: mode,
+					})
+				}
+				continue
+			}
+
+			// Filter out specific attributes (e.g., devices, etc.)
+			if (stat.Mode&syscall.S_IFMT) == syscall.S_IFCHR || // Character device
+				(stat.Mode&syscall.S_IFMT) == syscall.S_IFBLK || // Block device
+				(stat.Mode&syscall.S_IFMT) == syscall.S_IFIFO || // FIFO
+				(stat.Mode&syscall.S_IFMT) == syscall.S_IFSOCK { // Socket
+				continue
+			}
+
+			// Convert file mode to os.FileMode
+			mode := info.Mode()
+
+			// Append the entry to the result
+			resultEntries = append(resultEntries, types.AgentDirEntry{
+				Name: entry.Name(),
+				Mode: uint32(mode),
+			})
 		}
 
-		// Get file attributes
-		stat, ok := entry.Sys().(*syscall.Stat_t)
-		if !ok {
-			return nil, errors.New("failed to retrieve file attributes")
+		if len(resultEntries) > 0 {
+			if err := emit(resultEntries); err != nil {
+				return err
+			}
+			resultEntries = resultEntries[:0]
 		}
 
-		// Filter out specific attributes (e.g., symlinks, devices, etc.)
-		if (stat.Mode&syscall.S_IFMT) == syscall.S_IFLNK || // Symlink
-			(stat.Mode&syscall.S_IFMT) == syscall.S_IFCHR || // Character device
-			(stat.Mode&syscall.S_IFMT) == syscall.S_IFBLK || // Block device
-			(stat.Mode&syscall.S_IFMT) == syscall.S_IFIFO || // FIFO
-			(stat.Mode&syscall.S_IFMT) == syscall.S_IFSOCK { // Socket
-			continue
+		if err == io.EOF {
+			break
 		}
+		if err != nil {
+			if listed == 0 {
+				return err
+			}
+			syslog.L.Warn().
+				WithMessage("directory listing interrupted, returning partial results").
+				WithField("path", dirPath).
+				WithField("error", err.Error()).
+				Write()
+			break
+		}
+	}
 
-		// Convert file mode to os.FileMode
-		mode := entry.Mode()
+	return nil
+}
 
-		// Append the entry to the result
-		resultEntries = append(resultEntries, types.AgentDirEntry{
-			Name: entry.Name(),
-			Mode: uint32(mode),
-		})
+// linkMode returns the mode to list the symlink at path with, or false when
+// policy leaves it out. Followed links to anything but a regular file or a
+// directory are left out, like such entries are, and so are dangling links.
+// A target that exists but cannot be stat'ed is an error.
+func linkMode(path string, info os.FileInfo, policy SymlinkPolicy) (uint32, bool, error) {
+	switch policy {
+	case SymlinkRecordAsLink:
+		return uint32(info.Mode()), true, nil
+	case SymlinkFollow:
+		target, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return 0, false, nil
+			}
+			return 0, false, err
+		}
+		if !(target.Mode().IsRegular() || target.IsDir()) {
+			return 0, false, nil
+		}
+		return uint32(target.Mode()), true, nil
+	default:
+		return 0, false, nil
 	}
-
-	// Encode the result entries
-	return resultEntries.Encode()
 }
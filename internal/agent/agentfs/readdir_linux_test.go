@@ -0,0 +1,143 @@
+//go:build linux
+
+package agentfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDirBulkPartialResults(t *testing.T) {
+	testDir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(testDir, "healthy"), 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(testDir, "unavailable"), 0755))
+
+	// Fail the stat of one subdirectory to simulate a transient error.
+	origEntryInfo := entryInfo
+	entryInfo = func(entry os.DirEntry) (os.FileInfo, error) {
+		if entry.Name() == "unavailable" {
+			return nil, &os.PathError{Op: "lstat", Path: entry.Name(), Err: syscall.EIO}
+		}
+		return origEntryInfo(entry)
+	}
+	defer func() { entryInfo = origEntryInfo }()
+
+	raw, err := readDirBulk(context.Background(), testDir, SymlinkSkip, nil)
+	require.NoError(t, err)
+
+	var entries types.ReadDirEntries
+	require.NoError(t, entries.Decode(raw))
+	require.Len(t, entries, 3)
+
+	byName := make(map[string]types.AgentDirEntry, len(entries))
+	for _, entry := range entries {
+		byName[entry.Name] = entry
+	}
+
+	assert.Empty(t, byName["file.txt"].Err)
+	assert.Empty(t, byName["healthy"].Err)
+	assert.True(t, os.FileMode(byName["healthy"].Mode).IsDir())
+
+	failed, ok := byName["unavailable"]
+	require.True(t, ok)
+	assert.Contains(t, failed.Err, syscall.EIO.Error())
+	assert.True(t, os.FileMode(failed.Mode).IsDir())
+}
+
+func TestReadDirBulkSkipsVanishedEntries(t *testing.T) {
+	testDir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "kept.txt"), []byte("content"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "gone.txt"), []byte("content"), 0644))
+
+	origEntryInfo := entryInfo
+	entryInfo = func(entry os.DirEntry) (os.FileInfo, error) {
+		if entry.Name() == "gone.txt" {
+			return nil, &os.PathError{Op: "lstat", Path: entry.Name(), Err: os.ErrNotExist}
+		}
+		return origEntryInfo(entry)
+	}
+	defer func() { entryInfo = origEntryInfo }()
+
+	raw, err := readDirBulk(context.Background(), testDir, SymlinkSkip, nil)
+	require.NoError(t, err)
+
+	var entries types.ReadDirEntries
+	require.NoError(t, entries.Decode(raw))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "kept.txt", entries[0].Name)
+	assert.Empty(t, entries[0].Err)
+}
+
+func TestReadDirBulkCancelled(t *testing.T) {
+	testDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "file.txt"), []byte("content"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := readDirBulk(ctx, testDir, SymlinkSkip, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReadDirSymlinkPolicy(t *testing.T) {
+	testDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(testDir, "target"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "target.txt"), []byte("content"), 0644))
+	require.NoError(t, os.Symlink("target", filepath.Join(testDir, "dirlink")))
+	require.NoError(t, os.Symlink("target.txt", filepath.Join(testDir, "filelink")))
+	require.NoError(t, os.Symlink("missing", filepath.Join(testDir, "dangling")))
+
+	list := func(policy SymlinkPolicy) map[string]os.FileMode {
+		raw, err := readDirBulk(context.Background(), testDir, policy, nil)
+		require.NoError(t, err)
+		var entries types.ReadDirEntries
+		require.NoError(t, entries.Decode(raw))
+		modes := make(map[string]os.FileMode, len(entries))
+		for _, entry := range entries {
+			modes[entry.Name] = os.FileMode(entry.Mode)
+		}
+		return modes
+	}
+
+	modes := list(SymlinkSkip)
+	assert.Len(t, modes, 2)
+	assert.NotContains(t, modes, "dirlink")
+
+	modes = list(SymlinkFollow)
+	assert.True(t, modes["dirlink"].IsDir())
+	assert.True(t, modes["filelink"].IsRegular())
+	assert.NotContains(t, modes, "dangling")
+
+	modes = list(SymlinkRecordAsLink)
+	for _, name := range []string{"dirlink", "filelink", "dangling"} {
+		assert.NotZero(t, modes[name]&os.ModeSymlink, name)
+	}
+
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{SymlinkPolicy: SymlinkRecordAsLink})
+	defer server.Close()
+
+	info, err := server.statPath("dirlink")
+	require.NoError(t, err)
+	assert.NotZero(t, os.FileMode(info.Mode)&os.ModeSymlink)
+	assert.False(t, info.IsDir)
+
+	payload, err := (&types.StatReq{Path: "dirlink"}).Encode()
+	require.NoError(t, err)
+	resp, err := server.handleReadlink(arpc.Request{Payload: payload})
+	require.NoError(t, err)
+	var target arpc.StringMsg
+	require.NoError(t, target.Decode(resp.Data))
+	assert.Equal(t, "target", string(target))
+}
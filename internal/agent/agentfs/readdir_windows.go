@@ -3,12 +3,15 @@
 package agentfs
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"sync"
 	"unicode/utf16"
 	"unsafe"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 	"golang.org/x/sys/windows"
 )
 
@@ -157,10 +160,15 @@ func utf16ToString(s []uint16) string {
 	return string(utf16.Decode(s))
 }
 
-func readDirBulk(dirPath string) ([]byte, error) {
+// readDirStream lists dirPath and hands the entries to emit in batches of at
+// most chunkSize. The batch is reused after emit returns. Followed links
+// whose target cannot be read are returned with Err set instead of being
+// dropped. The listing stops with ctx.Err() once ctx is cancelled. Symlinks
+// and junctions are treated as policy says.
+func readDirStream(ctx context.Context, dirPath string, chunkSize int, policy SymlinkPolicy, emit func(types.ReadDirEntries) error) error {
 	pDir, err := windows.UTF16PtrFromString(dirPath)
 	if err != nil {
-		return nil, mapWinError(err, "readDirBulk UTF16PtrFromString")
+		return mapWinError(err, "readDirStream UTF16PtrFromString")
 	}
 
 	handle, err := windows.CreateFile(
@@ -173,7 +181,7 @@ func readDirBulk(dirPath string) ([]byte, error) {
 		0,
 	)
 	if err != nil {
-		return nil, mapWinError(err, "readDirBulk CreateFile")
+		return mapWinError(err, "readDirStream CreateFile")
 	}
 	defer windows.CloseHandle(handle)
 
@@ -185,13 +193,17 @@ func readDirBulk(dirPath string) ([]byte, error) {
 		}
 	}()
 
-	var entries types.ReadDirEntries
-	entries = make([]types.AgentDirEntry, 0, 100)
+	entries := make(types.ReadDirEntries, 0, chunkSize)
+	listed := 0
 
 	usingFull := false
 	infoClass := windows.FileIdBothDirectoryInfo
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		err = windows.GetFileInformationByHandleEx(
 			handle,
 			uint32(infoClass),
@@ -212,53 +224,71 @@ func readDirBulk(dirPath string) ([]byte, error) {
 			if err == windows.ERROR_NO_MORE_FILES {
 				break
 			}
-			return nil, mapWinError(err, "readDirBulk GetFileInformationByHandleEx")
+			// Keep what was enumerated so far rather than failing the
+			// whole listing on a transient error.
+			if listed > 0 {
+				syslog.L.Warn().
+					WithMessage("directory listing interrupted, returning partial results").
+					WithField("path", dirPath).
+					WithField("error", err.Error()).
+					Write()
+				break
+			}
+			return mapWinError(err, "readDirStream GetFileInformationByHandleEx")
 		}
 
 		offset := 0
 		for {
 			var nextOffset int
-			var name string
-			var attrs uint32
+			var nameSlice []uint16
+			var attrs, reparseTag uint32
 
 			if usingFull {
 				fullInfo := (*FILE_FULL_DIR_INFO)(unsafe.Pointer(&buf[offset]))
 				nextOffset = int(fullInfo.NextEntryOffset)
 				nameLen := int(fullInfo.FileNameLength) / 2
 				attrs = fullInfo.FileAttributes
-
+				reparseTag = fullInfo.EaSize // holds the tag of reparse points
 				if nameLen > 0 {
-					filenamePtr := fileNamePtrFull(fullInfo)
-					nameSlice := unsafe.Slice(filenamePtr, nameLen)
-					if !((nameLen == 1 && nameSlice[0] == '.') ||
-						(nameLen == 2 && nameSlice[0] == '.' && nameSlice[1] == '.')) &&
-						(attrs&excludedAttrs) == 0 {
-						name = utf16ToString(nameSlice)
-					}
+					nameSlice = unsafe.Slice(fileNamePtrFull(fullInfo), nameLen)
 				}
 			} else {
 				bothInfo := (*FILE_ID_BOTH_DIR_INFO)(unsafe.Pointer(&buf[offset]))
 				nextOffset = int(bothInfo.NextEntryOffset)
 				nameLen := int(bothInfo.FileNameLength) / 2
 				attrs = bothInfo.FileAttributes
-
+				reparseTag = bothInfo.EaSize // holds the tag of reparse points
 				if nameLen > 0 {
-					filenamePtr := fileNamePtrIdBoth(bothInfo)
-					nameSlice := unsafe.Slice(filenamePtr, nameLen)
-					if !((nameLen == 1 && nameSlice[0] == '.') ||
-						(nameLen == 2 && nameSlice[0] == '.' && nameSlice[1] == '.')) &&
-						(attrs&excludedAttrs) == 0 {
-						name = utf16ToString(nameSlice)
-					}
+					nameSlice = unsafe.Slice(fileNamePtrIdBoth(bothInfo), nameLen)
 				}
 			}
 
-			if name != "" {
-				mode := windowsAttributesToFileMode(attrs)
-				entries = append(entries, types.AgentDirEntry{
-					Name: name,
-					Mode: mode,
-				})
+			nameLen := len(nameSlice)
+			if nameLen > 0 && !((nameLen == 1 && nameSlice[0] == '.') ||
+				(nameLen == 2 && nameSlice[0] == '.' && nameSlice[1] == '.')) {
+				name := utf16ToString(nameSlice)
+				mode, ok, err := entryMode(dirPath, name, attrs, reparseTag, policy)
+				if err != nil {
+					entries = append(entries, types.AgentDirEntry{
+						Name: name,
+						Mode: uint32(os.ModeSymlink | 0777),
+						Err:  err.Error(),
+					})
+					listed++
+				} else if ok {
+					entries = append(entries, types.AgentDirEntry{
+						Name: name,
+						Mode: mode,
+					})
+					listed++
+				}
+
+				if len(entries) == chunkSize {
+					if err := emit(entries); err != nil {
+						return err
+					}
+					entries = entries[:0]
+				}
 			}
 
 			if nextOffset == 0 {
@@ -269,5 +299,48 @@ func readDirBulk(dirPath string) ([]byte, error) {
 		// Continue the outer loop until ERROR_NO_MORE_FILES is returned.
 	}
 
-	return entries.Encode()
+	if len(entries) > 0 {
+		return emit(entries)
+	}
+	return nil
+}
+
+// isLinkReparseTag reports whether tag marks a symbolic link or a junction,
+// as opposed to reparse points such as deduplicated or cloud files.
+func isLinkReparseTag(tag uint32) bool {
+	return tag == windows.IO_REPARSE_TAG_SYMLINK || tag == windows.IO_REPARSE_TAG_MOUNT_POINT
+}
+
+// entryMode returns the mode to list an entry of dirPath with, or false when
+// it is left out. Links are treated as policy says; other reparse points
+// and entries with excluded attributes are always left out. The attributes
+// of every other entry come with the listing, so the only per-entry error
+// is a followed link whose target exists but cannot be read.
+func entryMode(dirPath string, name string, attrs uint32, reparseTag uint32, policy SymlinkPolicy) (uint32, bool, error) {
+	if attrs&excludedAttrs == 0 {
+		return windowsAttributesToFileMode(attrs), true, nil
+	}
+	if attrs&(excludedAttrs&^windows.FILE_ATTRIBUTE_REPARSE_POINT) != 0 || !isLinkReparseTag(reparseTag) {
+		return 0, false, nil
+	}
+
+	switch policy {
+	case SymlinkRecordAsLink:
+		return uint32(os.ModeSymlink | 0777), true, nil
+	case SymlinkFollow:
+		target, err := os.Stat(filepath.Join(dirPath, name))
+		if err != nil {
+			// A dangling link has nothing to back up.
+			if os.IsNotExist(err) {
+				return 0, false, nil
+			}
+			return 0, false, err
+		}
+		if target.IsDir() {
+			return uint32(os.ModeDir | 0755), true, nil
+		}
+		return 0644, true, nil
+	default:
+		return 0, false, nil
+	}
 }
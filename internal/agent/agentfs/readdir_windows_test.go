@@ -3,14 +3,19 @@
 package agentfs
 
 import (
+	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"unsafe"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
 )
 
 func TestStructAlignment(t *testing.T) {
@@ -84,7 +89,7 @@ func testBasicFunctionality(t *testing.T, tempDir string) {
 	}
 
 	// Call readDirBulk
-	entriesBytes, err := readDirBulk(tempDir)
+	entriesBytes, err := readDirBulk(context.Background(), tempDir, SymlinkSkip, nil)
 	if err != nil {
 		t.Fatalf("readDirBulk failed: %v", err)
 	}
@@ -112,7 +117,7 @@ func testEmptyDirectory(t *testing.T, tempDir string) {
 	}
 
 	// Call readDirBulk
-	entriesBytes, err := readDirBulk(emptyDir)
+	entriesBytes, err := readDirBulk(context.Background(), emptyDir, SymlinkSkip, nil)
 	if err != nil {
 		t.Fatalf("readDirBulk failed: %v", err)
 	}
@@ -143,7 +148,7 @@ func testLargeDirectory(t *testing.T, tempDir string) {
 	}
 
 	// Call readDirBulk
-	entriesBytes, err := readDirBulk(largeDir)
+	entriesBytes, err := readDirBulk(context.Background(), largeDir, SymlinkSkip, nil)
 	if err != nil {
 		t.Fatalf("readDirBulk failed: %v", err)
 	}
@@ -175,7 +180,7 @@ func testFileAttributes(t *testing.T, tempDir string) {
 	}
 
 	// Call readDirBulk
-	entriesBytes, err := readDirBulk(tempDir)
+	entriesBytes, err := readDirBulk(context.Background(), tempDir, SymlinkSkip, nil)
 	if err != nil {
 		t.Fatalf("readDirBulk failed: %v", err)
 	}
@@ -237,7 +242,7 @@ func testSymbolicLinks(t *testing.T, tempDir string) {
 	}
 
 	// Call readDirBulk
-	entriesBytes, err := readDirBulk(tempDir)
+	entriesBytes, err := readDirBulk(context.Background(), tempDir, SymlinkSkip, nil)
 	if err != nil {
 		t.Fatalf("readDirBulk failed: %v", err)
 	}
@@ -267,7 +272,7 @@ func testUnicodeFileNames(t *testing.T, tempDir string) {
 	}
 
 	// Call readDirBulk
-	entriesBytes, err := readDirBulk(tempDir)
+	entriesBytes, err := readDirBulk(context.Background(), tempDir, SymlinkSkip, nil)
 	if err != nil {
 		t.Fatalf("readDirBulk failed: %v", err)
 	}
@@ -304,7 +309,7 @@ func testSpecialCharacters(t *testing.T, tempDir string) {
 	}
 
 	// Call readDirBulk
-	entriesBytes, err := readDirBulk(tempDir)
+	entriesBytes, err := readDirBulk(context.Background(), tempDir, SymlinkSkip, nil)
 	if err != nil {
 		t.Fatalf("readDirBulk failed: %v", err)
 	}
@@ -329,3 +334,97 @@ func testSpecialCharacters(t *testing.T, tempDir string) {
 		}
 	}
 }
+
+func TestReadDirSymlinkPolicy(t *testing.T) {
+	tempDir := t.TempDir()
+
+	targetDir := filepath.Join(tempDir, "target")
+	if err := os.Mkdir(targetDir, 0755); err != nil {
+		t.Fatalf("Failed to create target directory: %v", err)
+	}
+	targetFile := filepath.Join(tempDir, "target.txt")
+	if err := os.WriteFile(targetFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create target file: %v", err)
+	}
+
+	// Junctions need no privilege, unlike symlinks.
+	if out, err := exec.Command("cmd", "/c", "mklink", "/J", filepath.Join(tempDir, "junction"), targetDir).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create junction: %v: %s", err, out)
+	}
+	if err := os.Symlink(targetFile, filepath.Join(tempDir, "symlink.txt")); err != nil {
+		t.Fatalf("Failed to create symbolic link: %v", err)
+	}
+
+	list := func(policy SymlinkPolicy) map[string]os.FileMode {
+		entriesBytes, err := readDirBulk(context.Background(), tempDir, policy, nil)
+		if err != nil {
+			t.Fatalf("readDirBulk failed: %v", err)
+		}
+		var entries types.ReadDirEntries
+		if err := entries.Decode(entriesBytes); err != nil {
+			t.Fatalf("Failed to decode directory entries: %v", err)
+		}
+		modes := make(map[string]os.FileMode, len(entries))
+		for _, entry := range entries {
+			modes[entry.Name] = os.FileMode(entry.Mode)
+		}
+		return modes
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		modes := list(SymlinkSkip)
+		for _, name := range []string{"junction", "symlink.txt"} {
+			if _, ok := modes[name]; ok {
+				t.Errorf("%s should not be listed", name)
+			}
+		}
+	})
+
+	t.Run("Follow", func(t *testing.T) {
+		modes := list(SymlinkFollow)
+		if mode, ok := modes["junction"]; !ok || !mode.IsDir() {
+			t.Errorf("junction should be listed as a directory, got %v (listed: %v)", mode, ok)
+		}
+		if mode, ok := modes["symlink.txt"]; !ok || !mode.IsRegular() {
+			t.Errorf("symlink.txt should be listed as a regular file, got %v (listed: %v)", mode, ok)
+		}
+	})
+
+	t.Run("RecordAsLink", func(t *testing.T) {
+		modes := list(SymlinkRecordAsLink)
+		for _, name := range []string{"junction", "symlink.txt"} {
+			if mode, ok := modes[name]; !ok || mode&os.ModeSymlink == 0 || mode.IsDir() {
+				t.Errorf("%s should be listed as a symlink, got %v (listed: %v)", name, mode, ok)
+			}
+		}
+
+		server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: tempDir}, AgentFSOptions{SymlinkPolicy: SymlinkRecordAsLink})
+		defer server.Close()
+
+		for name, want := range map[string]string{"junction": targetDir, "symlink.txt": targetFile} {
+			info, err := server.statPath(name)
+			if err != nil {
+				t.Fatalf("statPath(%s) failed: %v", name, err)
+			}
+			if os.FileMode(info.Mode)&os.ModeSymlink == 0 {
+				t.Errorf("%s should be reported as a symlink, got %v", name, os.FileMode(info.Mode))
+			}
+
+			payload, err := (&types.StatReq{Path: name}).Encode()
+			if err != nil {
+				t.Fatalf("Failed to encode request: %v", err)
+			}
+			resp, err := server.handleReadlink(arpc.Request{Payload: payload})
+			if err != nil {
+				t.Fatalf("Readlink(%s) failed: %v", name, err)
+			}
+			var target arpc.StringMsg
+			if err := target.Decode(resp.Data); err != nil {
+				t.Fatalf("Failed to decode Readlink response: %v", err)
+			}
+			if !strings.EqualFold(filepath.Clean(string(target)), want) {
+				t.Errorf("Readlink(%s) = %q, want %q", name, target, want)
+			}
+		}
+	})
+}
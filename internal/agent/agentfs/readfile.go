@@ -0,0 +1,85 @@
+package agentfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	binarystream "github.com/sonroyaalmerol/pbs-plus/internal/arpc/binary"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/xtaci/smux"
+)
+
+// readSmallFile reads the whole file at path, refusing directories and
+// files larger than maxSize.
+func (s *AgentFSServer) readSmallFile(path string, maxSize int) ([]byte, error) {
+	file, err := s.openForRead(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, os.ErrInvalid
+	}
+	if stat.Size() > int64(maxSize) {
+		return nil, fmt.Errorf("file size %d exceeds ReadFile limit of %d bytes", stat.Size(), maxSize)
+	}
+
+	// The file may grow between Stat and the read; read one byte past the
+	// limit to notice.
+	data, err := io.ReadAll(io.LimitReader(file, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("file grew beyond ReadFile limit of %d bytes", maxSize)
+	}
+
+	return data, nil
+}
+
+// handleReadFile opens, reads and closes a small file in one request so the
+// caller saves the OpenFile and Close round trips.
+func (s *AgentFSServer) handleReadFile(req arpc.Request) (arpc.Response, error) {
+	var payload types.ReadFileReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	maxSize := payload.MaxSize
+	if maxSize <= 0 || maxSize > types.ReadFileMaxSize {
+		maxSize = types.ReadFileMaxSize
+	}
+
+	path, err := s.absStream(payload.Path)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	path = s.consistentCopy.resolve(payload.Path, path)
+
+	data, err := s.readSmallFile(path, maxSize)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	reader := bytes.NewReader(data)
+	streamCallback := func(stream *smux.Stream) {
+		if err := binarystream.SendDataFromReader(reader, len(data), stream); err != nil {
+			syslog.L.Error(err).WithMessage("failed sending data from reader via binary stream").Write()
+			return
+		}
+	}
+
+	return arpc.Response{
+		Status:    213,
+		RawStream: streamCallback,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package agentfs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReadStrategy selects how ReadAt reads regular files on Windows. Other
+// platforms always use pread.
+type ReadStrategy int
+
+const (
+	// ReadAuto maps reads of at least the mmap threshold and uses ReadFile
+	// for smaller ones, where setting up a view costs more than it saves.
+	ReadAuto ReadStrategy = iota
+	// ReadMmap maps every read. Files that cannot be mapped, such as empty
+	// ones, still fall back to ReadFile.
+	ReadMmap
+	// ReadReadFile never maps. Mapping is often slower than plain reads on
+	// network shares and VSS snapshots.
+	ReadReadFile
+)
+
+// DefaultMmapThreshold is the read size from which ReadAuto maps the file.
+const DefaultMmapThreshold = 64 * 1024
+
+func (r ReadStrategy) String() string {
+	switch r {
+	case ReadAuto:
+		return "auto"
+	case ReadMmap:
+		return "mmap"
+	case ReadReadFile:
+		return "readfile"
+	default:
+		return fmt.Sprintf("ReadStrategy(%d)", int(r))
+	}
+}
+
+// ParseReadStrategy parses "auto", "mmap" or "readfile". An empty value is
+// ReadAuto.
+func ParseReadStrategy(value string) (ReadStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "auto":
+		return ReadAuto, nil
+	case "mmap":
+		return ReadMmap, nil
+	case "readfile":
+		return ReadReadFile, nil
+	default:
+		return ReadAuto, fmt.Errorf("invalid read strategy %q", value)
+	}
+}
+
+// useMmap reports whether a read of length bytes should go through a file
+// mapping.
+func (s *AgentFSServer) useMmap(length int) bool {
+	switch s.readStrategy {
+	case ReadMmap:
+		return true
+	case ReadReadFile:
+		return false
+	default:
+		return length >= s.mmapThreshold
+	}
+}
@@ -0,0 +1,127 @@
+//go:build windows
+
+package agentfs
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+)
+
+var readStrategies = []ReadStrategy{ReadAuto, ReadMmap, ReadReadFile}
+
+// openReadHandle writes size random bytes to a file and returns them with a
+// read handle on the file.
+func openReadHandle(tb testing.TB, size int) ([]byte, *FileHandle) {
+	tb.Helper()
+
+	content := make([]byte, size)
+	_, err := rand.Read(content)
+	require.NoError(tb, err)
+
+	path := filepath.Join(tb.TempDir(), "data.bin")
+	require.NoError(tb, os.WriteFile(path, content, 0644))
+
+	handle, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(path),
+		windows.GENERIC_READ,
+		windows.FILE_SHARE_READ,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_ATTRIBUTE_NORMAL,
+		0,
+	)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { windows.CloseHandle(handle) })
+
+	return content, &FileHandle{handle: handle, fileSize: int64(size)}
+}
+
+func TestReadStrategiesReturnSameBytes(t *testing.T) {
+	const size = 3*1024*1024 + 123
+	content, fh := openReadHandle(t, size)
+
+	ranges := []struct{ offset, length int }{
+		{0, 1},
+		{0, 4096},
+		{1, 100},
+		{65535, 2},
+		{70000, DefaultMmapThreshold},
+		{123457, 1024 * 1024},
+		{size - 10, 10},
+		{0, size},
+	}
+
+	for _, strategy := range readStrategies {
+		server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: t.TempDir()}, AgentFSOptions{
+			ReadStrategy: strategy,
+		})
+
+		for _, r := range ranges {
+			data, release, err := server.readRange(fh, int64(r.offset), r.length)
+			require.NoError(t, err, "%s at %d+%d", strategy, r.offset, r.length)
+			assert.Equal(t, content[r.offset:r.offset+r.length], data, "%s at %d+%d", strategy, r.offset, r.length)
+			if release != nil {
+				release()
+			}
+		}
+
+		server.Close()
+	}
+}
+
+func TestReadMmapFallsBackOnEmptyFile(t *testing.T) {
+	_, fh := openReadHandle(t, 0)
+
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: t.TempDir()}, AgentFSOptions{
+		ReadStrategy: ReadMmap,
+	})
+	defer server.Close()
+
+	data, release, err := server.readRange(fh, 0, 0)
+	require.NoError(t, err)
+	assert.Nil(t, release)
+	assert.Empty(t, data)
+}
+
+func BenchmarkReadStrategy(b *testing.B) {
+	const size = 64 * 1024 * 1024
+	_, fh := openReadHandle(b, size)
+
+	for _, length := range []int{4 * 1024, 64 * 1024, 1024 * 1024, 8 * 1024 * 1024} {
+		for _, strategy := range readStrategies {
+			b.Run(fmt.Sprintf("%s/%dKiB", strategy, length/1024), func(b *testing.B) {
+				server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: b.TempDir()}, AgentFSOptions{
+					ReadStrategy: strategy,
+				})
+				defer server.Close()
+
+				b.SetBytes(int64(length))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					offset := int64(i*length) % (size - int64(length))
+					data, release, err := server.readRange(fh, offset, length)
+					if err != nil {
+						b.Fatal(err)
+					}
+					// Touch every page so mapped reads are not free.
+					var sum byte
+					for j := 0; j < len(data); j += 4096 {
+						sum += data[j]
+					}
+					_ = sum
+					if release != nil {
+						release()
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package agentfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// sparseSeek finds the next data region or hole from offset with lseek(2).
+// Like the Windows implementation, it fails with ENXIO past the last data
+// region when looking for data, and returns offset when it already lies in a
+// hole. Filesystems without hole tracking report the whole file as data.
+func sparseSeek(file *os.File, offset int64, whence int) (int64, error) {
+	return unix.Seek(int(file.Fd()), offset, whence)
+}
@@ -0,0 +1,101 @@
+//go:build linux
+
+package agentfs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestLseekSparseFile(t *testing.T) {
+	const (
+		kb = 1024
+		mb = 1024 * kb
+	)
+
+	// The layout of createSparseFileWithFsutil once NTFS rounds its writes
+	// to 64K allocation units: data at 0 and 1M, and a last byte at 3M.
+	testDir := t.TempDir()
+	path := filepath.Join(testDir, "sparse.bin")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, file.Truncate(4*mb))
+	block := make([]byte, 64*kb)
+	for i := range block {
+		block[i] = 'x'
+	}
+	for _, offset := range []int64{0, mb} {
+		_, err := unix.Pwrite(int(file.Fd()), block, offset)
+		require.NoError(t, err)
+	}
+	_, err = unix.Pwrite(int(file.Fd()), []byte("data3"), 3*mb)
+	require.NoError(t, err)
+	require.NoError(t, file.Sync())
+
+	if hole, err := unix.Seek(int(file.Fd()), 0, SeekHole); err != nil || hole != 64*kb {
+		file.Close()
+		t.Skipf("filesystem does not report holes (SEEK_HOLE from 0 gave %d, %v)", hole, err)
+	}
+	require.NoError(t, file.Close())
+
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{})
+	defer server.Close()
+
+	encoded, err := (&types.OpenFileReq{Path: "sparse.bin"}).Encode()
+	require.NoError(t, err)
+	resp, err := server.handleOpenFile(arpc.Request{Payload: encoded})
+	require.NoError(t, err)
+	var handle types.FileHandleId
+	require.NoError(t, handle.Decode(resp.Data))
+
+	seek := func(offset int64, whence int) (int64, error) {
+		t.Helper()
+		encoded, err := (&types.LseekReq{HandleID: handle, Offset: offset, Whence: whence}).Encode()
+		require.NoError(t, err)
+		resp, err := server.handleLseek(arpc.Request{Payload: encoded})
+		if err != nil {
+			return 0, err
+		}
+		var lseekResp types.LseekResp
+		require.NoError(t, lseekResp.Decode(resp.Data))
+		return lseekResp.NewOffset, nil
+	}
+
+	for _, tc := range []struct {
+		name   string
+		offset int64
+		whence int
+		want   int64
+	}{
+		{"first data", 0, SeekData, 0},
+		{"first hole", 0, SeekHole, 64 * kb},
+		{"inside data", 4 * kb, SeekData, 4 * kb},
+		{"second data", 64 * kb, SeekData, mb},
+		{"already in hole", 512 * kb, SeekHole, 512 * kb},
+		{"second hole", mb, SeekHole, mb + 64*kb},
+	} {
+		got, err := seek(tc.offset, tc.whence)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+
+	// The last region is found, whatever block size it was rounded to.
+	got, err := seek(2*mb, SeekData)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, got, int64(3*mb))
+	assert.Greater(t, got, int64(2*mb))
+
+	_, err = seek(4*mb, SeekData)
+	assert.ErrorIs(t, err, syscall.ENXIO, "no data at EOF")
+	_, err = seek(4*mb, SeekHole)
+	assert.ErrorIs(t, err, syscall.ENXIO, "no hole at EOF")
+}
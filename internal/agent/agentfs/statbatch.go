@@ -0,0 +1,35 @@
+package agentfs
+
+import (
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+)
+
+// handleStatBatch stats several paths in one round-trip. Each path gets its
+// own entry, in request order, so a path that cannot be stat'ed only fails
+// its own entry.
+func (s *AgentFSServer) handleStatBatch(req arpc.Request) (arpc.Response, error) {
+	var payload types.StatBatchReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	resp := make(types.StatBatchResp, len(payload.Paths))
+	for i, path := range payload.Paths {
+		info, err := s.statPath(path)
+		if err != nil {
+			resp[i].Err = arpc.WrapError(err)
+			continue
+		}
+		resp[i].Info = info
+	}
+
+	data, err := resp.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	return arpc.Response{
+		Status: 200,
+		Data:   data,
+	}, nil
+}
@@ -0,0 +1,140 @@
+//go:build windows
+
+package agentfs
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"unsafe"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procFindFirstStreamW = modkernel32.NewProc("FindFirstStreamW")
+	procFindNextStreamW  = modkernel32.NewProc("FindNextStreamW")
+)
+
+// findStreamInfoStandard is the FindStreamInfoStandard level of
+// FindFirstStreamW.
+const findStreamInfoStandard = 0
+
+// win32FindStreamData mirrors WIN32_FIND_STREAM_DATA.
+type win32FindStreamData struct {
+	StreamSize int64
+	StreamName [windows.MAX_PATH + 36]uint16
+}
+
+// splitStreamPath splits a path naming an alternate data stream as
+// "file:stream" into the file and the stream. Paths without a colon in
+// their last element have no stream.
+func splitStreamPath(relPath string) (string, string, error) {
+	base := strings.LastIndexAny(relPath, `\/`) + 1
+	i := strings.IndexByte(relPath[base:], ':')
+	if i < 0 {
+		return relPath, "", nil
+	}
+
+	file, stream := relPath[:base+i], relPath[base+i+1:]
+	if i == 0 || stream == "" || strings.ContainsAny(stream, `:\/`) {
+		return "", "", os.ErrInvalid
+	}
+	return file, stream, nil
+}
+
+// absStream is abs for paths that may name an alternate data stream. The
+// stream is kept out of the secure join, where "file:stream" could be taken
+// for a drive-relative path.
+func (s *AgentFSServer) absStream(relPath string) (string, error) {
+	file, stream, err := splitStreamPath(relPath)
+	if err != nil {
+		return "", err
+	}
+	fullPath, err := s.abs(file)
+	if err != nil || stream == "" {
+		return fullPath, err
+	}
+	return fullPath + ":" + stream, nil
+}
+
+// streamName returns NAME for a stream reported as ":NAME:$DATA". The
+// unnamed default stream and non-data streams are skipped.
+func streamName(raw string) (string, bool) {
+	name, ok := strings.CutSuffix(raw, ":$DATA")
+	if !ok {
+		return "", false
+	}
+	name = strings.TrimPrefix(name, ":")
+	return name, name != ""
+}
+
+// listStreams returns the alternate data streams of the file or directory at
+// path.
+func listStreams(path string) (types.StreamInfos, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data win32FindStreamData
+	r1, _, err := procFindFirstStreamW.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		findStreamInfoStandard,
+		uintptr(unsafe.Pointer(&data)),
+		0,
+	)
+	handle := windows.Handle(r1)
+	if handle == windows.InvalidHandle {
+		if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+			return types.StreamInfos{}, nil
+		}
+		return nil, mapWinError(err, "listStreams FindFirstStreamW")
+	}
+	defer windows.FindClose(handle)
+
+	streams := types.StreamInfos{}
+	for {
+		if name, ok := streamName(windows.UTF16ToString(data.StreamName[:])); ok {
+			streams = append(streams, types.StreamInfo{Name: name, Size: data.StreamSize})
+		}
+
+		r1, _, err := procFindNextStreamW.Call(uintptr(handle), uintptr(unsafe.Pointer(&data)))
+		if r1 == 0 {
+			if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+				return streams, nil
+			}
+			return nil, mapWinError(err, "listStreams FindNextStreamW")
+		}
+	}
+}
+
+// handleStreams lists the alternate data streams of a file. Their content
+// is read through OpenFile or ReadFile with the "file:stream" syntax.
+func (s *AgentFSServer) handleStreams(req arpc.Request) (arpc.Response, error) {
+	var payload types.StatReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	fullPath, err := s.abs(payload.Path)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	streams, err := listStreams(fullPath)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	data, err := streams.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	return arpc.Response{
+		Status: 200,
+		Data:   data,
+	}, nil
+}
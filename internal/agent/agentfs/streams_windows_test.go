@@ -0,0 +1,88 @@
+//go:build windows
+
+package agentfs
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStreamPath(t *testing.T) {
+	for _, tc := range []struct {
+		path, file, stream string
+	}{
+		{"file.txt", "file.txt", ""},
+		{"dir/file.txt:secret", "dir/file.txt", "secret"},
+		{`dir\file.txt:Zone.Identifier`, `dir\file.txt`, "Zone.Identifier"},
+	} {
+		file, stream, err := splitStreamPath(tc.path)
+		require.NoError(t, err, tc.path)
+		assert.Equal(t, tc.file, file, tc.path)
+		assert.Equal(t, tc.stream, stream, tc.path)
+	}
+
+	for _, path := range []string{":secret", "file.txt:", "file.txt:a:$DATA", "dir/:secret"} {
+		_, _, err := splitStreamPath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestAlternateDataStreams(t *testing.T) {
+	testDir := t.TempDir()
+	path := filepath.Join(testDir, "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("main content"), 0644))
+	require.NoError(t, os.WriteFile(path+":secret", []byte("hidden stream content"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "plain.txt"), []byte("plain"), 0644))
+
+	serverConn, clientConn := net.Pipe()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	router := arpc.NewRouter()
+	server := NewAgentFSServer("agentFs", snapshots.Snapshot{Path: testDir}, AgentFSOptions{})
+	server.RegisterHandlers(&router)
+	defer server.Close()
+
+	serverSession, err := arpc.NewServerSession(serverConn, nil)
+	require.NoError(t, err)
+	serverSession.SetRouter(router)
+	go func() {
+		_ = serverSession.Serve()
+	}()
+	defer serverSession.Close()
+
+	clientSession, err := arpc.NewClientSession(clientConn, nil)
+	require.NoError(t, err)
+	defer clientSession.Close()
+
+	listStreams := func(name string) types.StreamInfos {
+		raw, err := clientSession.CallMsg(ctx, "agentFs/Streams", &types.StatReq{Path: name})
+		require.NoError(t, err)
+		var streams types.StreamInfos
+		require.NoError(t, streams.Decode(raw))
+		return streams
+	}
+
+	assert.Equal(t, types.StreamInfos{{Name: "secret", Size: int64(len("hidden stream content"))}}, listStreams("file.txt"))
+	assert.Empty(t, listStreams("plain.txt"))
+
+	buf := make([]byte, 1024)
+	n, err := clientSession.CallBinary(ctx, "agentFs/ReadFile", &types.ReadFileReq{Path: "file.txt:secret", MaxSize: len(buf)}, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hidden stream content", string(buf[:n]))
+
+	// The default stream is untouched by the named one.
+	n, err = clientSession.CallBinary(ctx, "agentFs/ReadFile", &types.ReadFileReq{Path: "file.txt", MaxSize: len(buf)}, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "main content", string(buf[:n]))
+}
@@ -0,0 +1,83 @@
+package agentfs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+)
+
+// SymlinkPolicy controls how directory listings treat symbolic links and, on
+// Windows, directory junctions.
+type SymlinkPolicy int
+
+const (
+	// SymlinkSkip leaves links out of listings.
+	SymlinkSkip SymlinkPolicy = iota
+	// SymlinkFollow lists links as what they point to, so their targets are
+	// backed up in place. Links to a parent directory make the tree
+	// infinite.
+	SymlinkFollow
+	// SymlinkRecordAsLink lists links as symbolic links, whose target is
+	// read with Readlink.
+	SymlinkRecordAsLink
+)
+
+func (p SymlinkPolicy) String() string {
+	switch p {
+	case SymlinkSkip:
+		return "skip"
+	case SymlinkFollow:
+		return "follow"
+	case SymlinkRecordAsLink:
+		return "link"
+	default:
+		return fmt.Sprintf("SymlinkPolicy(%d)", int(p))
+	}
+}
+
+// ParseSymlinkPolicy parses "skip", "follow" or "link". An empty value is
+// SymlinkSkip.
+func ParseSymlinkPolicy(value string) (SymlinkPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "skip":
+		return SymlinkSkip, nil
+	case "follow":
+		return SymlinkFollow, nil
+	case "link":
+		return SymlinkRecordAsLink, nil
+	default:
+		return SymlinkSkip, fmt.Errorf("invalid symlink policy %q", value)
+	}
+}
+
+// handleReadlink returns the target of a link listed under
+// SymlinkRecordAsLink, as stored on the agent.
+func (s *AgentFSServer) handleReadlink(req arpc.Request) (arpc.Response, error) {
+	var payload types.StatReq
+	if err := payload.Decode(req.Payload); err != nil {
+		return arpc.Response{}, err
+	}
+
+	fullPath, err := s.absNoFollow(payload.Path)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	target, err := os.Readlink(fullPath)
+	if err != nil {
+		return arpc.Response{}, err
+	}
+
+	msg := arpc.StringMsg(target)
+	data, err := msg.Encode()
+	if err != nil {
+		return arpc.Response{}, err
+	}
+	return arpc.Response{
+		Status: 200,
+		Data:   data,
+	}, nil
+}
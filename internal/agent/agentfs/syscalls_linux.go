@@ -3,7 +3,9 @@
 package agentfs
 
 import (
+	"fmt"
 	"math"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -19,9 +21,10 @@ func GetAllocGranularity() int {
 }
 
 // getPosixACL uses the "getfacl" command to obtain the ACL for path.
-// It returns a slice of PosixACLEntry. It ignores comment lines.
+// It returns a slice of PosixACLEntry. It ignores comment lines. Qualifiers
+// are numeric, and default entries have a "default:" prefixed tag.
 func getPosixACL(path string) ([]types.PosixACL, error) {
-	out, err := exec.Command("getfacl", "-p", "-c", path).Output()
+	out, err := exec.Command("getfacl", "-p", "-c", "-n", "-E", path).Output()
 	if err != nil {
 		return nil, err
 	}
@@ -35,6 +38,9 @@ func getPosixACL(path string) ([]types.PosixACL, error) {
 		// Expected entry format: tag:qualifier:perms, e.g.,
 		// "user:1001:rwx" or "group::r-x"
 		parts := strings.Split(line, ":")
+		if parts[0] == "default" && len(parts) > 1 {
+			parts = append([]string{"default:" + parts[1]}, parts[2:]...)
+		}
 		if len(parts) < 3 {
 			continue
 		}
@@ -68,3 +74,60 @@ func getPosixACL(path string) ([]types.PosixACL, error) {
 	}
 	return entries, nil
 }
+
+// setfaclBinary is the setfacl CLI used by setPosixACL.
+var setfaclBinary = "setfacl"
+
+// setPosixACL replaces the ACL of path with entries, as returned by
+// getPosixACL, using the "setfacl" command.
+func setPosixACL(path string, entries []types.PosixACL) error {
+	spec := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		qualifier := ""
+		if entry.ID >= 0 {
+			qualifier = strconv.Itoa(int(entry.ID))
+		}
+		perms := []byte("---")
+		if entry.Perms&4 != 0 {
+			perms[0] = 'r'
+		}
+		if entry.Perms&2 != 0 {
+			perms[1] = 'w'
+		}
+		if entry.Perms&1 != 0 {
+			perms[2] = 'x'
+		}
+		spec = append(spec, entry.Tag+":"+qualifier+":"+string(perms))
+	}
+
+	out, err := exec.Command(setfaclBinary, "--set", strings.Join(spec, ","), path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("setfacl failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// lookupID resolves a user or group name as reported by Xattr, falling back
+// to a numeric ID.
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	idStr, err := lookup(name)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(idStr)
+}
+
+// isTransientLockError is always false on Linux, where file locks are
+// advisory and do not fail opens or reads.
+func isTransientLockError(err error) bool {
+	return false
+}
+
+// openForRead opens path for reading. Linux needs no special access mode
+// and never fails an open on a lock.
+func (s *AgentFSServer) openForRead(path string) (*os.File, error) {
+	return os.Open(path)
+}
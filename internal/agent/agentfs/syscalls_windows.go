@@ -291,7 +291,7 @@ func GetWinACLs(filePath string) (string, string, []types.WinACL, error) {
 	// Iterate over each explicit access entry.
 	for _, entry := range expEntries {
 		// In an absolute descriptor, Trustee.TrusteeValue is a pointer to a SID.
-		pSid := (*windows.SID)(unsafe.Pointer(entry.Trustee.TrusteeValue))
+		pSid := *(**windows.SID)(unsafe.Pointer(&entry.Trustee.TrusteeValue))
 		if pSid == nil {
 			continue
 		}
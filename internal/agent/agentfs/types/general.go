@@ -86,3 +86,47 @@ func (entries *ReadDirEntries) Decode(buf []byte) error {
 	arpcdata.ReleaseDecoder(dec)
 	return nil
 }
+
+// StatBatchResp holds the results of a StatBatchReq, in the order of its
+// paths.
+type StatBatchResp []StatBatchEntry
+
+func (resp *StatBatchResp) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoder()
+	if err := enc.WriteUint32(uint32(len(*resp))); err != nil {
+		return nil, err
+	}
+	for _, entry := range *resp {
+		entryBytes, err := entry.Encode()
+		if err != nil {
+			return nil, err
+		}
+		if err := enc.WriteBytes(entryBytes); err != nil {
+			return nil, err
+		}
+	}
+	return enc.Bytes(), nil
+}
+
+func (resp *StatBatchResp) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	count, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	*resp = make([]StatBatchEntry, count)
+	for i := uint32(0); i < count; i++ {
+		entryBytes, err := dec.ReadBytes()
+		if err != nil {
+			return err
+		}
+		if err := (*resp)[i].Decode(entryBytes); err != nil {
+			return err
+		}
+	}
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
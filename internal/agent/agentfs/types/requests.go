@@ -1,7 +1,11 @@
 package types
 
 import (
+	"fmt"
+
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc/arpcdata"
+	binarystream "github.com/sonroyaalmerol/pbs-plus/internal/arpc/binary"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
 )
 
 // OpenFileReq represents a request to open a file
@@ -76,6 +80,52 @@ func (req *StatReq) Decode(buf []byte) error {
 	return nil
 }
 
+// StatBatchMaxPaths bounds the paths of a single StatBatchReq.
+const StatBatchMaxPaths = 1024
+
+// StatBatchReq asks for the attributes of several paths in one call. The
+// result of each path is reported separately, see StatBatchResp.
+type StatBatchReq struct {
+	Paths []string
+}
+
+func (req *StatBatchReq) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoder()
+	if err := enc.WriteUint32(uint32(len(req.Paths))); err != nil {
+		return nil, err
+	}
+	for _, path := range req.Paths {
+		if err := enc.WriteString(path); err != nil {
+			return nil, err
+		}
+	}
+	return enc.Bytes(), nil
+}
+
+func (req *StatBatchReq) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	count, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	if count > StatBatchMaxPaths {
+		return fmt.Errorf("stat batch of %d paths exceeds limit of %d", count, StatBatchMaxPaths)
+	}
+	req.Paths = make([]string, count)
+	for i := range req.Paths {
+		path, err := dec.ReadString()
+		if err != nil {
+			return err
+		}
+		req.Paths[i] = path
+	}
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
 // ReadDirReq represents a request to read a directory
 type ReadDirReq struct {
 	Path string
@@ -103,6 +153,50 @@ func (req *ReadDirReq) Decode(buf []byte) error {
 	return nil
 }
 
+// ReadDirChunkSize is the default number of entries per batch of a
+// ReadDirStream response. MaxReadDirChunkSize caps what a request may ask for.
+const (
+	ReadDirChunkSize    = 4096
+	MaxReadDirChunkSize = 65536
+)
+
+// ReadDirStreamReq represents a request to list a directory in batches of
+// ChunkSize entries. A ChunkSize of 0 uses ReadDirChunkSize.
+type ReadDirStreamReq struct {
+	Path      string
+	ChunkSize int
+}
+
+func (req *ReadDirStreamReq) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(len(req.Path) + 4)
+	if err := enc.WriteString(req.Path); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteUint32(uint32(req.ChunkSize)); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (req *ReadDirStreamReq) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	path, err := dec.ReadString()
+	if err != nil {
+		return err
+	}
+	req.Path = path
+	chunkSize, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	req.ChunkSize = int(chunkSize)
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
 // ReadReq represents a request to read from a file
 type ReadReq struct {
 	HandleID FileHandleId
@@ -139,15 +233,28 @@ func (req *ReadReq) Decode(buf []byte) error {
 	return nil
 }
 
+// ReadAtChunkSize is the Length callers should read files with. Agents
+// refuse a ReadAt longer than their limit, DefaultMaxReadAtLength unless
+// configured otherwise and never below ReadAtChunkSize, rather than buffer
+// it.
+const (
+	ReadAtChunkSize        = 1 << 20
+	DefaultMaxReadAtLength = 16 << 20
+)
+
 // ReadAtReq represents a request to read from a file at a specific offset
 type ReadAtReq struct {
 	HandleID FileHandleId
 	Offset   int64
 	Length   int
+	// AcceptCodecs lists the codecs the caller can decode; the agent may
+	// compress the data with one of them. Requests of older callers leave
+	// it out, which means no compression.
+	AcceptCodecs binarystream.Codecs
 }
 
 func (req *ReadAtReq) Encode() ([]byte, error) {
-	enc := arpcdata.NewEncoderWithSize(8 + 8 + 4)
+	enc := arpcdata.NewEncoderWithSize(8 + 8 + 4 + 1)
 	if err := enc.WriteUint64(uint64(req.HandleID)); err != nil {
 		return nil, err
 	}
@@ -157,6 +264,9 @@ func (req *ReadAtReq) Encode() ([]byte, error) {
 	if err := enc.WriteUint32(uint32(req.Length)); err != nil {
 		return nil, err
 	}
+	if err := enc.WriteUint8(uint8(req.AcceptCodecs)); err != nil {
+		return nil, err
+	}
 	return enc.Bytes(), nil
 }
 
@@ -180,6 +290,226 @@ func (req *ReadAtReq) Decode(buf []byte) error {
 		return err
 	}
 	req.Length = int(length)
+	req.AcceptCodecs = 0
+	if dec.Remaining() > 0 {
+		accept, err := dec.ReadUint8()
+		if err != nil {
+			return err
+		}
+		req.AcceptCodecs = binarystream.Codecs(accept)
+	}
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// WriteAtReq represents a request to write to a file at a specific offset.
+// Data travels in the request itself, so a request is limited to
+// arpc.MaxRequestSize; larger writes are split by the caller.
+type WriteAtReq struct {
+	HandleID FileHandleId
+	Offset   int64
+	Data     []byte
+}
+
+func (req *WriteAtReq) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(8 + 8 + 4 + len(req.Data))
+	if err := enc.WriteUint64(uint64(req.HandleID)); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteInt64(req.Offset); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteBytes(req.Data); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (req *WriteAtReq) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	handleID, err := dec.ReadUint64()
+	if err != nil {
+		return err
+	}
+	req.HandleID = FileHandleId(handleID)
+	offset, err := dec.ReadInt64()
+	if err != nil {
+		return err
+	}
+	req.Offset = offset
+	data, err := dec.ReadBytes()
+	if err != nil {
+		return err
+	}
+	req.Data = data
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// ReadFileMaxSize is the largest file a single ReadFile request returns.
+// Larger files have to be read through a handle.
+const ReadFileMaxSize = 1 << 20
+
+// ReadFileReq represents a request to read a whole file in one call. Files
+// larger than MaxSize (capped at ReadFileMaxSize) are refused.
+type ReadFileReq struct {
+	Path    string
+	MaxSize int
+}
+
+func (req *ReadFileReq) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(len(req.Path) + 4)
+	if err := enc.WriteString(req.Path); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteUint32(uint32(req.MaxSize)); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (req *ReadFileReq) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	path, err := dec.ReadString()
+	if err != nil {
+		return err
+	}
+	req.Path = path
+	maxSize, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	req.MaxSize = int(maxSize)
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// Allocate modes. They mirror the fallocate(2) flags of the same name.
+const (
+	// AllocateKeepSize allocates space without changing the file size.
+	AllocateKeepSize = 0x1
+	// AllocatePunchHole deallocates the range so it reads back as zeroes.
+	// It implies AllocateKeepSize.
+	AllocatePunchHole = 0x2
+)
+
+// AllocateReq represents a request to allocate or deallocate a file range
+type AllocateReq struct {
+	HandleID FileHandleId
+	Offset   int64
+	Length   int64
+	Mode     uint32
+}
+
+func (req *AllocateReq) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(8 + 8 + 8 + 4)
+	if err := enc.WriteUint64(uint64(req.HandleID)); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteInt64(req.Offset); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteInt64(req.Length); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteUint32(req.Mode); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (req *AllocateReq) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	handleID, err := dec.ReadUint64()
+	if err != nil {
+		return err
+	}
+	req.HandleID = FileHandleId(handleID)
+	offset, err := dec.ReadInt64()
+	if err != nil {
+		return err
+	}
+	req.Offset = offset
+	length, err := dec.ReadInt64()
+	if err != nil {
+		return err
+	}
+	req.Length = length
+	mode, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	req.Mode = mode
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// Hash algorithms accepted by HashAt.
+const (
+	HashXXH3   = "xxh3"
+	HashSHA256 = "sha256"
+	HashBLAKE3 = "blake3"
+)
+
+// HashReq represents a request to hash a range of a file
+type HashReq struct {
+	HandleID  FileHandleId
+	Offset    int64
+	Length    int64
+	Algorithm string
+}
+
+func (req *HashReq) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(8 + 8 + 8 + len(req.Algorithm))
+	if err := enc.WriteUint64(uint64(req.HandleID)); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteInt64(req.Offset); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteInt64(req.Length); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteString(req.Algorithm); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (req *HashReq) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	handleID, err := dec.ReadUint64()
+	if err != nil {
+		return err
+	}
+	req.HandleID = FileHandleId(handleID)
+	offset, err := dec.ReadInt64()
+	if err != nil {
+		return err
+	}
+	req.Offset = offset
+	length, err := dec.ReadInt64()
+	if err != nil {
+		return err
+	}
+	req.Length = length
+	algorithm, err := dec.ReadString()
+	if err != nil {
+		return err
+	}
+	req.Algorithm = algorithm
 	arpcdata.ReleaseDecoder(dec)
 	return nil
 }
@@ -211,6 +541,95 @@ func (req *CloseReq) Decode(buf []byte) error {
 	return nil
 }
 
+// SetXattrReq writes back the ownership and ACLs reported by Xattr. Empty
+// Owner and Group are left unchanged; the ACL of the file is only replaced
+// when ApplyACLs is set, with WinACLs on Windows and PosixACLs elsewhere.
+type SetXattrReq struct {
+	Path      string
+	Owner     string
+	Group     string
+	ApplyACLs bool
+	WinACLs   []WinACL
+	PosixACLs []PosixACL
+}
+
+func (req *SetXattrReq) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoder()
+	if err := enc.WriteString(req.Path); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteString(req.Owner); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteString(req.Group); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteBool(req.ApplyACLs); err != nil {
+		return nil, err
+	}
+
+	winAcls := WinACLArray(req.WinACLs)
+	winAclsBytes, err := winAcls.Encode()
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.WriteBytes(winAclsBytes); err != nil {
+		return nil, err
+	}
+
+	posixAcls := PosixACLArray(req.PosixACLs)
+	posixAclsBytes, err := posixAcls.Encode()
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.WriteBytes(posixAclsBytes); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (req *SetXattrReq) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	if req.Path, err = dec.ReadString(); err != nil {
+		return err
+	}
+	if req.Owner, err = dec.ReadString(); err != nil {
+		return err
+	}
+	if req.Group, err = dec.ReadString(); err != nil {
+		return err
+	}
+	if req.ApplyACLs, err = dec.ReadBool(); err != nil {
+		return err
+	}
+
+	winAclsBytes, err := dec.ReadBytes()
+	if err != nil {
+		return err
+	}
+	var winAcls WinACLArray
+	if err := winAcls.Decode(winAclsBytes); err != nil {
+		return err
+	}
+	req.WinACLs = winAcls
+
+	posixAclsBytes, err := dec.ReadBytes()
+	if err != nil {
+		return err
+	}
+	var posixAcls PosixACLArray
+	if err := posixAcls.Decode(posixAclsBytes); err != nil {
+		return err
+	}
+	req.PosixACLs = posixAcls
+
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
 // BackupReq represents a request to back up a file
 type BackupReq struct {
 	JobId      string
@@ -309,3 +728,70 @@ func (req *LseekReq) Decode(buf []byte) error {
 	arpcdata.ReleaseDecoder(dec)
 	return nil
 }
+
+// ExclusionsReq pushes the exclusion rules of a job to the agent, which
+// then leaves the entries they exclude out of its listings. Patterns are
+// matched relative to Root.
+type ExclusionsReq struct {
+	Root  string
+	Rules []pattern.FilterRule
+}
+
+func (req *ExclusionsReq) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoder()
+	if err := enc.WriteString(req.Root); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteUint32(uint32(len(req.Rules))); err != nil {
+		return nil, err
+	}
+	for _, rule := range req.Rules {
+		if err := enc.WriteString(rule.Pattern); err != nil {
+			return nil, err
+		}
+		if err := enc.WriteString(rule.Arg); err != nil {
+			return nil, err
+		}
+		if err := enc.WriteBool(rule.Include); err != nil {
+			return nil, err
+		}
+		if err := enc.WriteString(rule.MatchType); err != nil {
+			return nil, err
+		}
+	}
+	return enc.Bytes(), nil
+}
+
+func (req *ExclusionsReq) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	root, err := dec.ReadString()
+	if err != nil {
+		return err
+	}
+	count, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	req.Root = root
+	req.Rules = make([]pattern.FilterRule, count)
+	for i := range req.Rules {
+		rule := &req.Rules[i]
+		if rule.Pattern, err = dec.ReadString(); err != nil {
+			return err
+		}
+		if rule.Arg, err = dec.ReadString(); err != nil {
+			return err
+		}
+		if rule.Include, err = dec.ReadBool(); err != nil {
+			return err
+		}
+		if rule.MatchType, err = dec.ReadString(); err != nil {
+			return err
+		}
+	}
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
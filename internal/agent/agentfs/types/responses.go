@@ -34,6 +34,100 @@ func (resp *LseekResp) Decode(buf []byte) error {
 	return nil
 }
 
+// WriteAtResp represents the response to a write request
+type WriteAtResp struct {
+	BytesWritten int
+}
+
+func (resp *WriteAtResp) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(4)
+	if err := enc.WriteUint32(uint32(resp.BytesWritten)); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (resp *WriteAtResp) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	written, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	resp.BytesWritten = int(written)
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// AllocateResp represents the response to an allocate request
+type AllocateResp struct {
+	// AllocationSize is the space allocated to the file on disk after the
+	// request, in bytes.
+	AllocationSize int64
+}
+
+func (resp *AllocateResp) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(8)
+	if err := enc.WriteInt64(resp.AllocationSize); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (resp *AllocateResp) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	size, err := dec.ReadInt64()
+	if err != nil {
+		return err
+	}
+	resp.AllocationSize = size
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// HashResp represents the response to a hash request
+type HashResp struct {
+	// Length is the number of bytes hashed, which is less than requested
+	// when the range runs past EOF.
+	Length int64
+	Digest []byte
+}
+
+func (resp *HashResp) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(8 + len(resp.Digest))
+	if err := enc.WriteInt64(resp.Length); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteBytes(resp.Digest); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (resp *HashResp) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	length, err := dec.ReadInt64()
+	if err != nil {
+		return err
+	}
+	resp.Length = length
+	digest, err := dec.ReadBytes()
+	if err != nil {
+		return err
+	}
+	resp.Digest = digest
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
 // WinACL represents an Access Control Entry
 type WinACL struct {
 	SID        string
@@ -260,7 +354,9 @@ func (acls *PosixACLArray) Decode(buf []byte) error {
 	return nil
 }
 
-// AgentFileInfo represents file metadata
+// AgentFileInfo represents file metadata. ModTime is transferred as UTC
+// Unix nanoseconds; CreationTime, LastAccessTime and LastWriteTime are UTC
+// Unix seconds. None of them depend on the timezone of the agent.
 type AgentFileInfo struct {
 	Name           string
 	Size           int64
@@ -454,20 +550,32 @@ func (info *AgentFileInfo) Decode(buf []byte) error {
 	return nil
 }
 
-// AgentDirEntry represents a directory entry
+// AgentDirEntry represents a directory entry. Err is non-empty when the
+// entry was enumerated but its attributes could not be read; such entries
+// are still returned so the caller can decide whether to retry them.
+//
+// Err is a trailing field written only when set, so healthy entries encode
+// exactly as they did before it existed and either side decodes the other's
+// entries regardless of version.
 type AgentDirEntry struct {
 	Name string
 	Mode uint32
+	Err  string
 }
 
 func (entry *AgentDirEntry) Encode() ([]byte, error) {
-	enc := arpcdata.NewEncoderWithSize(len(entry.Name) + 4)
+	enc := arpcdata.NewEncoderWithSize(len(entry.Name) + 4 + len(entry.Err))
 	if err := enc.WriteString(entry.Name); err != nil {
 		return nil, err
 	}
 	if err := enc.WriteUint32(entry.Mode); err != nil {
 		return nil, err
 	}
+	if entry.Err != "" {
+		if err := enc.WriteString(entry.Err); err != nil {
+			return nil, err
+		}
+	}
 	return enc.Bytes(), nil
 }
 
@@ -486,6 +594,201 @@ func (entry *AgentDirEntry) Decode(buf []byte) error {
 		return err
 	}
 	entry.Mode = mode
+	entry.Err = ""
+	if dec.Remaining() > 0 {
+		entryErr, err := dec.ReadString()
+		if err != nil {
+			return err
+		}
+		entry.Err = entryErr
+	}
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// StatBatchEntry is the result for one path of a StatBatchReq. Err is set
+// instead of Info when the path could not be stat'ed; arpc.UnwrapError turns
+// it back into the original error type.
+type StatBatchEntry struct {
+	Info AgentFileInfo
+	Err  *arpc.SerializableError
+}
+
+func (entry *StatBatchEntry) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoder()
+	if err := enc.WriteBool(entry.Err != nil); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	var err error
+	if entry.Err != nil {
+		data, err = entry.Err.Encode()
+	} else {
+		data, err = entry.Info.Encode()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.WriteBytes(data); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (entry *StatBatchEntry) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	failed, err := dec.ReadBool()
+	if err != nil {
+		return err
+	}
+	data, err := dec.ReadBytes()
+	if err != nil {
+		return err
+	}
+
+	*entry = StatBatchEntry{}
+	if failed {
+		entry.Err = &arpc.SerializableError{}
+		if err := entry.Err.Decode(data); err != nil {
+			return err
+		}
+	} else if err := entry.Info.Decode(data); err != nil {
+		return err
+	}
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// StreamInfo describes a named data stream of a file, an NTFS alternate
+// data stream on Windows. The default unnamed stream is never listed.
+type StreamInfo struct {
+	Name string
+	Size int64
+}
+
+// StreamInfos is the response of a Streams request.
+type StreamInfos []StreamInfo
+
+func (streams *StreamInfos) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoder()
+	if err := enc.WriteUint32(uint32(len(*streams))); err != nil {
+		return nil, err
+	}
+	for _, stream := range *streams {
+		if err := enc.WriteString(stream.Name); err != nil {
+			return nil, err
+		}
+		if err := enc.WriteInt64(stream.Size); err != nil {
+			return nil, err
+		}
+	}
+	return enc.Bytes(), nil
+}
+
+func (streams *StreamInfos) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	count, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	*streams = make([]StreamInfo, count)
+	for i := range *streams {
+		name, err := dec.ReadString()
+		if err != nil {
+			return err
+		}
+		size, err := dec.ReadInt64()
+		if err != nil {
+			return err
+		}
+		(*streams)[i] = StreamInfo{Name: name, Size: size}
+	}
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// ChangedFiles is the response of a ChangedFiles request. It lists the
+// paths, relative to the backup root, changed since the last finished
+// backup of the job. Available is false when the agent has no usable change
+// journal and every file has to be looked at.
+type ChangedFiles struct {
+	Available bool
+	Paths     []string
+}
+
+func (changed *ChangedFiles) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoder()
+	if err := enc.WriteBool(changed.Available); err != nil {
+		return nil, err
+	}
+	if err := enc.WriteUint32(uint32(len(changed.Paths))); err != nil {
+		return nil, err
+	}
+	for _, path := range changed.Paths {
+		if err := enc.WriteString(path); err != nil {
+			return nil, err
+		}
+	}
+	return enc.Bytes(), nil
+}
+
+func (changed *ChangedFiles) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	available, err := dec.ReadBool()
+	if err != nil {
+		return err
+	}
+	count, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	changed.Available = available
+	changed.Paths = make([]string, count)
+	for i := range changed.Paths {
+		path, err := dec.ReadString()
+		if err != nil {
+			return err
+		}
+		changed.Paths[i] = path
+	}
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// ExclusionStats is the response of an ExclusionStats request: the number
+// of listed entries the pushed exclusions left out.
+type ExclusionStats struct {
+	Excluded int64
+}
+
+func (stats *ExclusionStats) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(8)
+	if err := enc.WriteInt64(stats.Excluded); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (stats *ExclusionStats) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	excluded, err := dec.ReadInt64()
+	if err != nil {
+		return err
+	}
+	stats.Excluded = excluded
 	arpcdata.ReleaseDecoder(dec)
 	return nil
 }
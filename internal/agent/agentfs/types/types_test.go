@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc/arpcdata"
+	binarystream "github.com/sonroyaalmerol/pbs-plus/internal/arpc/binary"
 )
 
 func TestEncodeDecodeConcurrency(t *testing.T) {
@@ -57,6 +58,16 @@ func TestEncodeDecodeConcurrency(t *testing.T) {
 		})
 	})
 
+	t.Run("ChangedFiles", func(t *testing.T) {
+		original := &ChangedFiles{
+			Available: true,
+			Paths:     []string{"Users/a/report.docx", "Windows/Temp/x.tmp"},
+		}
+		validateEncodeDecodeConcurrency(t, original, func() arpcdata.Encodable {
+			return &ChangedFiles{}
+		})
+	})
+
 	t.Run("OpenFileReq", func(t *testing.T) {
 		original := &OpenFileReq{
 			Path: "/path/to/file",
@@ -82,6 +93,13 @@ func TestEncodeDecodeConcurrency(t *testing.T) {
 		})
 	})
 
+	t.Run("ReadDirStreamReq", func(t *testing.T) {
+		original := &ReadDirStreamReq{Path: "/path/to/dir", ChunkSize: 4096}
+		validateEncodeDecodeConcurrency(t, original, func() arpcdata.Encodable {
+			return &ReadDirStreamReq{}
+		})
+	})
+
 	t.Run("ReadReq", func(t *testing.T) {
 		original := &ReadReq{
 			HandleID: FileHandleId(12345),
@@ -103,6 +121,51 @@ func TestEncodeDecodeConcurrency(t *testing.T) {
 		})
 	})
 
+	t.Run("WriteAtReq", func(t *testing.T) {
+		original := &WriteAtReq{
+			HandleID: FileHandleId(12345),
+			Offset:   1024,
+			Data:     []byte("restored content"),
+		}
+		validateEncodeDecodeConcurrency(t, original, func() arpcdata.Encodable {
+			return &WriteAtReq{}
+		})
+	})
+
+	t.Run("ReadFileReq", func(t *testing.T) {
+		original := &ReadFileReq{
+			Path:    "/path/to/small/file",
+			MaxSize: 65536,
+		}
+		validateEncodeDecodeConcurrency(t, original, func() arpcdata.Encodable {
+			return &ReadFileReq{}
+		})
+	})
+
+	t.Run("AllocateReq", func(t *testing.T) {
+		original := &AllocateReq{
+			HandleID: FileHandleId(12345),
+			Offset:   1 << 20,
+			Length:   1 << 20,
+			Mode:     AllocatePunchHole,
+		}
+		validateEncodeDecodeConcurrency(t, original, func() arpcdata.Encodable {
+			return &AllocateReq{}
+		})
+	})
+
+	t.Run("HashReq", func(t *testing.T) {
+		original := &HashReq{
+			HandleID:  FileHandleId(12345),
+			Offset:    4096,
+			Length:    1 << 20,
+			Algorithm: HashSHA256,
+		}
+		validateEncodeDecodeConcurrency(t, original, func() arpcdata.Encodable {
+			return &HashReq{}
+		})
+	})
+
 	t.Run("CloseReq", func(t *testing.T) {
 		original := &CloseReq{HandleID: FileHandleId(12345)}
 		validateEncodeDecodeConcurrency(t, original, func() arpcdata.Encodable {
@@ -142,6 +205,141 @@ func TestEncodeDecodeConcurrency(t *testing.T) {
 	})
 }
 
+func TestAgentFileInfoTimesAcrossTimezoneChange(t *testing.T) {
+	origLocal := time.Local
+	defer func() { time.Local = origLocal }()
+
+	// The agent encodes while on daylight saving time...
+	time.Local = time.FixedZone("EDT", -4*60*60)
+	modTime := time.Date(2024, time.November, 3, 1, 30, 0, 123456789, time.Local)
+	original := &AgentFileInfo{
+		Name:           "report.docx",
+		Size:           2048,
+		Mode:           0644,
+		ModTime:        modTime,
+		CreationTime:   modTime.Unix() - 3600,
+		LastAccessTime: modTime.Unix(),
+		LastWriteTime:  modTime.Unix(),
+	}
+	encoded, err := original.Encode()
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	// ...and the server decodes after the clocks went back.
+	time.Local = time.FixedZone("EST", -5*60*60)
+	decoded := &AgentFileInfo{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	if !decoded.ModTime.Equal(modTime) {
+		t.Fatalf("mtime drifted: expected %v, got %v", modTime, decoded.ModTime)
+	}
+	if decoded.ModTime.UnixNano() != modTime.UnixNano() {
+		t.Fatalf("mtime nanos drifted: expected %d, got %d", modTime.UnixNano(), decoded.ModTime.UnixNano())
+	}
+	if decoded.ModTime.Location() != time.UTC {
+		t.Fatalf("expected decoded mtime in UTC, got %v", decoded.ModTime.Location())
+	}
+	if decoded.CreationTime != original.CreationTime ||
+		decoded.LastAccessTime != original.LastAccessTime ||
+		decoded.LastWriteTime != original.LastWriteTime {
+		t.Fatalf("file times drifted: expected %+v, got %+v", original, decoded)
+	}
+
+	// Re-encoding under the new timezone must produce identical bytes.
+	reencoded, err := decoded.Encode()
+	if err != nil {
+		t.Fatalf("re-encode failed: %v", err)
+	}
+	if !bytes.Equal(encoded, reencoded) {
+		t.Fatal("re-encoded file info differs from the original encoding")
+	}
+}
+
+func TestAgentFileInfoZeroModTime(t *testing.T) {
+	original := &AgentFileInfo{Name: "unknown"}
+	encoded, err := original.Encode()
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded := &AgentFileInfo{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !decoded.ModTime.IsZero() {
+		t.Fatalf("expected zero mtime, got %v", decoded.ModTime)
+	}
+}
+
+func TestReadAtReqWithoutCodecs(t *testing.T) {
+	// Callers predating compression send the request without AcceptCodecs.
+	enc := arpcdata.NewEncoderWithSize(8 + 8 + 4)
+	_ = enc.WriteUint64(7)
+	_ = enc.WriteInt64(1024)
+	_ = enc.WriteUint32(4096)
+
+	decoded := &ReadAtReq{AcceptCodecs: binarystream.SupportedCodecs}
+	if err := decoded.Decode(enc.Bytes()); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.HandleID != 7 || decoded.Offset != 1024 || decoded.Length != 4096 || decoded.AcceptCodecs != 0 {
+		t.Fatalf("unexpected request %+v", decoded)
+	}
+
+	original := &ReadAtReq{HandleID: 7, Offset: 1024, Length: 4096, AcceptCodecs: binarystream.SupportedCodecs}
+	encoded, err := original.Encode()
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded = &ReadAtReq{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if *decoded != *original {
+		t.Fatalf("expected %+v, got %+v", original, decoded)
+	}
+}
+
+func TestAgentDirEntryWithoutErr(t *testing.T) {
+	// Agents predating per-entry errors send only the name and mode.
+	enc := arpcdata.NewEncoderWithSize(8 + 4)
+	_ = enc.WriteString("file.txt")
+	_ = enc.WriteUint32(0644)
+	legacy := enc.Bytes()
+
+	decoded := &AgentDirEntry{Err: "stale"}
+	if err := decoded.Decode(legacy); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.Name != "file.txt" || decoded.Mode != 0644 || decoded.Err != "" {
+		t.Fatalf("unexpected entry %+v", decoded)
+	}
+
+	// A healthy entry encodes exactly as before, so older servers read it.
+	encoded, err := (&AgentDirEntry{Name: "file.txt", Mode: 0644}).Encode()
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if !bytes.Equal(encoded, legacy) {
+		t.Fatalf("expected legacy encoding %v, got %v", legacy, encoded)
+	}
+
+	original := &AgentDirEntry{Name: "broken", Mode: 0644, Err: "input/output error"}
+	encoded, err = original.Encode()
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded = &AgentDirEntry{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if *decoded != *original {
+		t.Fatalf("expected %+v, got %+v", original, decoded)
+	}
+}
+
 // validateEncodeDecodeConcurrency tests encoding and decoding concurrently.
 func validateEncodeDecodeConcurrency(t *testing.T, original arpcdata.Encodable, newDecoded func() arpcdata.Encodable) {
 	const numGoroutines = 100
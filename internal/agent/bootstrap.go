@@ -48,7 +48,7 @@ func Bootstrap() error {
 
 	encodedCSR := base64.StdEncoding.EncodeToString(csr)
 
-	drives, err := utils.GetLocalDrives()
+	drives, err := LocalDrives()
 	if err != nil {
 		return fmt.Errorf("Bootstrap: failed to get local drives list: %w", err)
 	}
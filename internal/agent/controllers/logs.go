@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"encoding/json"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// LogTailMethod is the method of LogTailHandler.
+const LogTailMethod = "logs/tail"
+
+// logTailBuffer is how many entries a slow reader can fall behind before
+// entries are dropped.
+const logTailBuffer = 256
+
+// LogTailHandler streams the entries logged by the agent from now on as
+// JSON frames until the caller cancels. The payload is an
+// arpc.MapStringStringMsg whose optional "level" sets the lowest level sent;
+// it defaults to info. Backups log from their own processes and are not
+// included.
+func LogTailHandler(req arpc.Request) (arpc.Response, error) {
+	level := syslog.LevelInfo
+	if len(req.Payload) > 0 {
+		var params arpc.MapStringStringMsg
+		if err := params.Decode(req.Payload); err != nil {
+			return arpc.Response{}, err
+		}
+		if name := params["level"]; name != "" {
+			parsed, err := syslog.ParseLevel(name)
+			if err != nil {
+				return arpc.Response{}, err
+			}
+			level = parsed
+		}
+	}
+
+	ctx := req.Context()
+	return arpc.FrameStream(func(send func([]byte) error) error {
+		entries, cancel := syslog.Subscribe(level, logTailBuffer)
+		defer cancel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case entry := <-entries:
+				data, err := json.Marshal(entry)
+				if err != nil {
+					continue
+				}
+				if err := send(data); err != nil {
+					return err
+				}
+			}
+		}
+	}), nil
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+)
+
+// ServeReconnectConfig returns how an agent reconnects its aRPC session to
+// the server: exponential backoff up to 30s, and a 5 minute pause after 10
+// failures in a row.
+func ServeReconnectConfig() arpc.ReconnectConfig {
+	return arpc.ReconnectConfig{
+		InitialBackoff:   time.Second,
+		MaxBackoff:       30 * time.Second,
+		BackoffJitter:    0.2,
+		CircuitBreakTime: 5 * time.Minute,
+		MaxAttempts:      10,
+	}
+}
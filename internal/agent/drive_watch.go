@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+)
+
+// driveChangeSettle is how long WatchDrives waits for a burst of change
+// signals, such as the several mounts of a docked disk, to end before it
+// lists the drives.
+const driveChangeSettle = 2 * time.Second
+
+// DriveWatcher signals that a drive may have been added or removed. Signals
+// may be spurious; WatchDrives compares drive lists before reporting.
+type DriveWatcher interface {
+	// Changes returns a channel receiving a value on every possible change.
+	// The channel is closed once ctx is done.
+	Changes(ctx context.Context) (<-chan struct{}, error)
+}
+
+// WatchDrives calls onChange with the drives from LocalDrives whenever the
+// platform reports that the set of drives changed. It blocks until ctx is
+// done, and returns an error right away if the platform cannot be watched,
+// in which case callers are left with periodic re-scans.
+func WatchDrives(ctx context.Context, onChange func([]utils.DriveInfo)) error {
+	return watchDrives(ctx, newDriveWatcher(), LocalDrives, driveChangeSettle, onChange)
+}
+
+func watchDrives(ctx context.Context, watcher DriveWatcher, list func() ([]utils.DriveInfo, error), settle time.Duration, onChange func([]utils.DriveInfo)) error {
+	changes, err := watcher.Changes(ctx)
+	if err != nil {
+		return err
+	}
+
+	var last string
+	if drives, err := list(); err == nil {
+		last = driveSetKey(drives)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-changes:
+			if !ok {
+				return nil
+			}
+		}
+
+		if !drainChanges(ctx, changes, settle) {
+			return nil
+		}
+
+		drives, err := list()
+		if err != nil {
+			continue
+		}
+		if key := driveSetKey(drives); key != last {
+			last = key
+			onChange(drives)
+		}
+	}
+}
+
+// drainChanges swallows the signals arriving until none came for settle. It
+// returns false once ctx is done or changes is closed.
+func drainChanges(ctx context.Context, changes <-chan struct{}, settle time.Duration) bool {
+	timer := time.NewTimer(settle)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case _, ok := <-changes:
+			if !ok {
+				return false
+			}
+			timer.Reset(settle)
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// driveSetKey identifies a list of drives by what the server registers as
+// targets, ignoring usage figures that change all the time.
+func driveSetKey(drives []utils.DriveInfo) string {
+	keys := make([]string, 0, len(drives))
+	for _, drive := range drives {
+		keys = append(keys, strings.Join([]string{drive.Letter, drive.Type, drive.FileSystem, drive.VolumeName}, "\x00"))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\n")
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package agent
+
+import (
+	"context"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountTableWatcher waits on /proc/self/mounts, which the kernel flags with
+// POLLPRI whenever something is mounted or unmounted in the namespace.
+type mountTableWatcher struct{}
+
+func newDriveWatcher() DriveWatcher {
+	return mountTableWatcher{}
+}
+
+func (mountTableWatcher) Changes(ctx context.Context) (<-chan struct{}, error) {
+	mounts, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		defer mounts.Close()
+
+		fds := []unix.PollFd{{Fd: int32(mounts.Fd()), Events: unix.POLLPRI}}
+		for ctx.Err() == nil {
+			// Wake up every second to notice ctx being done.
+			n, err := unix.Poll(fds, 1000)
+			if err != nil {
+				if err == unix.EINTR {
+					continue
+				}
+				return
+			}
+			if n == 0 || fds[0].Revents&(unix.POLLPRI|unix.POLLERR) == 0 {
+				continue
+			}
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDriveWatcher struct {
+	changes chan struct{}
+}
+
+func (w *mockDriveWatcher) Changes(ctx context.Context) (<-chan struct{}, error) {
+	return w.changes, nil
+}
+
+// mockDrives is a drive list that tests change under the watcher.
+type mockDrives struct {
+	mu     sync.Mutex
+	drives []utils.DriveInfo
+	calls  int
+}
+
+func (m *mockDrives) set(drives ...utils.DriveInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.drives = drives
+}
+
+func (m *mockDrives) list() ([]utils.DriveInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	return append([]utils.DriveInfo(nil), m.drives...), nil
+}
+
+func TestWatchDrives(t *testing.T) {
+	system := utils.DriveInfo{Letter: "C", Type: "Fixed", FileSystem: "NTFS", UsedBytes: 100}
+	usb := utils.DriveInfo{Letter: "E", Type: "Removable", FileSystem: "exFAT"}
+
+	drives := &mockDrives{}
+	drives.set(system)
+
+	watcher := &mockDriveWatcher{changes: make(chan struct{})}
+	updates := make(chan []utils.DriveInfo, 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watchDrives(ctx, watcher, drives.list, 10*time.Millisecond, func(d []utils.DriveInfo) {
+			updates <- d
+		})
+	}()
+
+	// Wait for the initial listing, so later changes are compared with it.
+	require.Eventually(t, func() bool {
+		drives.mu.Lock()
+		defer drives.mu.Unlock()
+		return drives.calls > 0
+	}, time.Second, time.Millisecond)
+
+	expectUpdate := func() []utils.DriveInfo {
+		select {
+		case d := <-updates:
+			return d
+		case <-time.After(time.Second):
+			t.Fatal("drive change was not reported")
+			return nil
+		}
+	}
+	expectNoUpdate := func() {
+		select {
+		case d := <-updates:
+			t.Fatalf("unexpected drive update %v", d)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	t.Run("DriveAdded", func(t *testing.T) {
+		drives.set(system, usb)
+		watcher.changes <- struct{}{}
+		assert.Equal(t, []utils.DriveInfo{system, usb}, expectUpdate())
+	})
+
+	t.Run("SpuriousSignal", func(t *testing.T) {
+		watcher.changes <- struct{}{}
+		expectNoUpdate()
+	})
+
+	t.Run("UsageChangeIgnored", func(t *testing.T) {
+		grown := system
+		grown.UsedBytes = 200
+		drives.set(grown, usb)
+		watcher.changes <- struct{}{}
+		expectNoUpdate()
+	})
+
+	t.Run("BurstReportedOnce", func(t *testing.T) {
+		drives.set(system)
+		for range 3 {
+			watcher.changes <- struct{}{}
+		}
+		assert.Equal(t, []utils.DriveInfo{system}, expectUpdate())
+		expectNoUpdate()
+	})
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not stop")
+	}
+}
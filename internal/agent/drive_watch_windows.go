@@ -0,0 +1,56 @@
+//go:build windows
+
+package agent
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// logicalDrivesPollInterval is how often the mask of drive letters is read.
+// GetLogicalDrives does not touch the drives, so this is cheap.
+const logicalDrivesPollInterval = 2 * time.Second
+
+// logicalDrivesWatcher signals when a drive letter appears or disappears,
+// as when a USB disk is plugged in or a volume is mounted.
+type logicalDrivesWatcher struct{}
+
+func newDriveWatcher() DriveWatcher {
+	return logicalDrivesWatcher{}
+}
+
+func (logicalDrivesWatcher) Changes(ctx context.Context) (<-chan struct{}, error) {
+	last, err := windows.GetLogicalDrives()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(logicalDrivesPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			mask, err := windows.GetLogicalDrives()
+			if err != nil || mask == last {
+				continue
+			}
+			last = mask
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changes, nil
+}
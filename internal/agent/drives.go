@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/registry"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+)
+
+// Values of registry.CONFIG narrowing down the drives reported. All are
+// optional and hold comma-separated lists, except IncludeNetworkDrivesKey.
+const (
+	ExcludedDrivesKey = "ExcludedDrives"
+	// DriveFsTypesKey lists the only filesystem types reported.
+	DriveFsTypesKey = "DriveFsTypes"
+	// ExcludedFsTypesKey lists filesystem types left out on top of the
+	// pseudo filesystems utils.DefaultDriveFilter leaves out.
+	ExcludedFsTypesKey = "ExcludedFsTypes"
+	// IncludeNetworkDrivesKey set to "true" reports network mounts.
+	IncludeNetworkDrivesKey = "IncludeNetworkDrives"
+)
+
+// LocalDrives returns the local drives that should be registered as targets,
+// filtered by LoadDriveFilter and leaving out the ones listed in the
+// ExcludedDrives config entry.
+func LocalDrives() ([]utils.DriveInfo, error) {
+	drives, err := utils.GetLocalDrives(LoadDriveFilter(SystemRegistry))
+	if err != nil {
+		return nil, err
+	}
+
+	excluded, err := registry.GetEntry(registry.CONFIG, ExcludedDrivesKey, false)
+	if err != nil || excluded == nil {
+		return drives, nil
+	}
+
+	return filterDrives(drives, excluded.Value), nil
+}
+
+// LoadDriveFilter reads from reg which filesystem types are reported as
+// drives, starting from utils.DefaultDriveFilter.
+func LoadDriveFilter(reg ConfigRegistry) utils.DriveFilter {
+	filter := utils.DefaultDriveFilter()
+
+	value := func(key string) string {
+		entry, err := reg.GetEntry(registry.CONFIG, key, false)
+		if err != nil || entry == nil {
+			return ""
+		}
+		return strings.TrimSpace(entry.Value)
+	}
+
+	if allow := utils.ParseFsTypeList(value(DriveFsTypesKey)); len(allow) > 0 {
+		filter.AllowFsTypes = allow
+	}
+	for fsType := range utils.ParseFsTypeList(value(ExcludedFsTypesKey)) {
+		filter.DenyFsTypes[fsType] = true
+	}
+	filter.IncludeNetwork, _ = strconv.ParseBool(value(IncludeNetworkDrivesKey))
+
+	return filter
+}
+
+// filterDrives removes the drives listed in the comma-separated excluded
+// list. Entries are drive letters on Windows and mount points on Linux.
+func filterDrives(drives []utils.DriveInfo, excluded string) []utils.DriveInfo {
+	skip := make(map[string]bool)
+	for _, letter := range strings.Split(excluded, ",") {
+		letter = normalizeDriveLetter(letter)
+		if letter != "" {
+			skip[letter] = true
+		}
+	}
+	if len(skip) == 0 {
+		return drives
+	}
+
+	filtered := make([]utils.DriveInfo, 0, len(drives))
+	for _, drive := range drives {
+		if skip[normalizeDriveLetter(drive.Letter)] {
+			continue
+		}
+		filtered = append(filtered, drive)
+	}
+	return filtered
+}
+
+// normalizeDriveLetter upper-cases Windows drive letters and leaves mount
+// points, which are case-sensitive, untouched.
+func normalizeDriveLetter(letter string) string {
+	letter = strings.TrimSpace(letter)
+	if len(letter) == 1 {
+		return strings.ToUpper(letter)
+	}
+	return letter
+}
@@ -12,6 +12,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -20,6 +21,7 @@ import (
 	"github.com/containers/winquit/pkg/winquit"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/registry"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
@@ -36,13 +38,23 @@ func init() {
 }
 
 type backupSession struct {
-	jobId    string
-	ctx      context.Context
-	cancel   context.CancelFunc
-	store    *agent.BackupStore
-	snapshot snapshots.Snapshot
-	fs       *agentfs.AgentFSServer
-	once     sync.Once
+	jobId     string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	store     *agent.BackupStore
+	snapshot  snapshots.Snapshot
+	fs        *agentfs.AgentFSServer
+	watermark *agent.USNWatermark
+	once      sync.Once
+}
+
+// Finish closes a session the server ended on purpose, so the next change
+// set is read from where this one ends.
+func (s *backupSession) Finish() {
+	s.Close()
+	if s.store != nil && s.watermark != nil {
+		_ = s.store.SaveUSNWatermark(s.jobId, *s.watermark)
+	}
 }
 
 func (s *backupSession) Close() {
@@ -138,7 +150,7 @@ func CmdBackup() {
 		<-done
 		rpcSess.Close()
 		if session, ok := activeSessions.Get(*jobId); ok {
-			session.Close()
+			session.Finish()
 		}
 	}()
 
@@ -240,6 +252,11 @@ func Backup(rpcSess *arpc.Session, sourceMode string, drive string, jobId string
 		return "", err
 	}
 
+	// Read before the snapshot is taken, so that what changes in between is
+	// reported again next time rather than missed.
+	changed, watermark := changedFiles(store, jobId, drive)
+	session.watermark = watermark
+
 	var snapshot snapshots.Snapshot
 
 	backupMode := sourceMode
@@ -280,13 +297,178 @@ func Backup(rpcSess *arpc.Session, sourceMode string, drive string, jobId string
 
 	session.snapshot = snapshot
 
-	fs := agentfs.NewAgentFSServer(jobId, snapshot)
+	fs := agentfs.NewAgentFSServer(jobId, snapshot, agentfs.AgentFSOptions{
+		LockRetry:         lockRetryPolicy(),
+		SymlinkPolicy:     symlinkPolicy(),
+		ReadStrategy:      readStrategy(),
+		MmapThreshold:     mmapThreshold(),
+		ChangedFiles:      changed,
+		MaxOpenHandles:    maxOpenHandles(),
+		IdleHandleTimeout: idleHandleTimeout(),
+		MaxReadAtLength:   maxReadAtLength(),
+	})
 	if fs == nil {
 		session.Close()
 		return "", fmt.Errorf("fs is nil")
 	}
+	if entry, err := registry.GetEntry(registry.CONFIG, "ConsistentCopy", false); err == nil {
+		rules, err := agentfs.ParseConsistentCopyRules(entry.Value)
+		if err != nil {
+			syslog.L.Error(err).WithMessage("invalid consistent copy rules, ignoring").Write()
+		} else {
+			fs.SetConsistentCopyRules(rules)
+		}
+	}
 	fs.RegisterHandlers(rpcSess.GetRouter())
 	session.fs = fs
 
 	return backupMode, nil
 }
+
+// changedFiles reads the change journal of drive from the watermark saved by
+// the last finished backup of jobId. It returns the changes, nil when the
+// whole tree has to be walked, and the watermark to save once this backup
+// finishes, nil without a journal.
+func changedFiles(store *agent.BackupStore, jobId string, drive string) (*types.ChangedFiles, *agent.USNWatermark) {
+	journal, err := agentfs.OpenChangeJournal(drive)
+	if err != nil {
+		return nil, nil
+	}
+	defer journal.Close()
+
+	next := &agent.USNWatermark{Volume: drive, JournalID: journal.ID, USN: journal.NextUSN}
+
+	last, ok, err := store.LoadUSNWatermark(jobId)
+	if err != nil || !ok || last.Volume != drive || last.JournalID != journal.ID {
+		return nil, next
+	}
+
+	changes, err := journal.ChangedFilesSince(last.USN)
+	if err != nil {
+		syslog.L.Warn().
+			WithMessage("change journal not usable, walking the full tree").
+			WithField("jobId", jobId).
+			WithField("error", err.Error()).
+			Write()
+		return nil, next
+	}
+
+	return &types.ChangedFiles{Available: true, Paths: changes.Paths}, next
+}
+
+// symlinkPolicy reads the SymlinkPolicy config value ("skip", "follow" or
+// "link"), falling back to skipping links.
+func symlinkPolicy() agentfs.SymlinkPolicy {
+	var value string
+	if entry, err := registry.GetEntry(registry.CONFIG, "SymlinkPolicy", false); err == nil {
+		value = entry.Value
+	}
+
+	policy, err := agentfs.ParseSymlinkPolicy(value)
+	if err != nil {
+		syslog.L.Error(err).WithMessage("invalid symlink policy config, skipping links").Write()
+	}
+	return policy
+}
+
+// readStrategy reads the ReadStrategy config value ("auto", "mmap" or
+// "readfile"), falling back to auto.
+func readStrategy() agentfs.ReadStrategy {
+	var value string
+	if entry, err := registry.GetEntry(registry.CONFIG, "ReadStrategy", false); err == nil {
+		value = entry.Value
+	}
+
+	strategy, err := agentfs.ParseReadStrategy(value)
+	if err != nil {
+		syslog.L.Error(err).WithMessage("invalid read strategy config, using auto").Write()
+	}
+	return strategy
+}
+
+// mmapThreshold reads the MmapThreshold config value in bytes. Zero, the
+// result of a missing or invalid value, uses the default.
+func mmapThreshold() int {
+	entry, err := registry.GetEntry(registry.CONFIG, "MmapThreshold", false)
+	if err != nil {
+		return 0
+	}
+
+	threshold, err := strconv.Atoi(strings.TrimSpace(entry.Value))
+	if err != nil || threshold < 0 {
+		syslog.L.Error(fmt.Errorf("invalid mmap threshold %q", entry.Value)).
+			WithMessage("invalid mmap threshold config, using default").Write()
+		return 0
+	}
+	return threshold
+}
+
+// maxOpenHandles reads the MaxOpenHandles config value. Zero, the result
+// of a missing or invalid value, uses the default.
+func maxOpenHandles() int {
+	entry, err := registry.GetEntry(registry.CONFIG, "MaxOpenHandles", false)
+	if err != nil {
+		return 0
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(entry.Value))
+	if err != nil || limit < 0 {
+		syslog.L.Error(fmt.Errorf("invalid max open handles %q", entry.Value)).
+			WithMessage("invalid max open handles config, using default").Write()
+		return 0
+	}
+	return limit
+}
+
+// maxReadAtLength reads the MaxReadAtLength config value in bytes. Zero,
+// the result of a missing or invalid value, uses the default.
+func maxReadAtLength() int {
+	entry, err := registry.GetEntry(registry.CONFIG, "MaxReadAtLength", false)
+	if err != nil {
+		return 0
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(entry.Value))
+	if err != nil || length < 0 {
+		syslog.L.Error(fmt.Errorf("invalid max ReadAt length %q", entry.Value)).
+			WithMessage("invalid max ReadAt length config, using default").Write()
+		return 0
+	}
+	return length
+}
+
+// idleHandleTimeout reads the IdleHandleTimeout config value in seconds.
+// Zero, the result of a missing or invalid value, uses the default; a
+// negative value keeps idle handles open.
+func idleHandleTimeout() time.Duration {
+	entry, err := registry.GetEntry(registry.CONFIG, "IdleHandleTimeout", false)
+	if err != nil {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(strings.TrimSpace(entry.Value))
+	if err != nil {
+		syslog.L.Error(fmt.Errorf("invalid idle handle timeout %q", entry.Value)).
+			WithMessage("invalid idle handle timeout config, using default").Write()
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// lockRetryPolicy reads the LockRetryAttempts and LockRetryDelay (in
+// milliseconds) config values, falling back to the defaults.
+func lockRetryPolicy() agentfs.LockRetryPolicy {
+	var attempts, delay string
+	if entry, err := registry.GetEntry(registry.CONFIG, "LockRetryAttempts", false); err == nil {
+		attempts = entry.Value
+	}
+	if entry, err := registry.GetEntry(registry.CONFIG, "LockRetryDelay", false); err == nil {
+		delay = entry.Value
+	}
+
+	policy, err := agentfs.ParseLockRetryPolicy(attempts, delay)
+	if err != nil {
+		syslog.L.Error(err).WithMessage("invalid lock retry config, using defaults").Write()
+	}
+	return policy
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/registry"
+)
+
+// ServerURLKey is the value of registry.CONFIG holding the URL of the PBS
+// Plus server.
+const ServerURLKey = "ServerURL"
+
+// ConfigRegistry reads and writes agent configuration entries. SystemRegistry
+// is backed by the registry package; tests substitute their own.
+type ConfigRegistry interface {
+	GetEntry(path string, key string, isSecret bool) (*registry.RegistryEntry, error)
+	CreateEntry(entry *registry.RegistryEntry) error
+}
+
+type systemRegistry struct{}
+
+func (systemRegistry) GetEntry(path string, key string, isSecret bool) (*registry.RegistryEntry, error) {
+	return registry.GetEntry(path, key, isSecret)
+}
+
+func (systemRegistry) CreateEntry(entry *registry.RegistryEntry) error {
+	return registry.CreateEntry(entry)
+}
+
+// SystemRegistry is the registry of the machine the agent runs on.
+var SystemRegistry ConfigRegistry = systemRegistry{}
+
+var ErrInvalidServerURL = errors.New("invalid server URL")
+
+// ValidateServerURL checks that rawURL is the base URL of a PBS Plus server,
+// an https URL with a host and no path, query or credentials, and returns it
+// without its trailing slash.
+func ValidateServerURL(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidServerURL, err)
+	}
+
+	switch {
+	case u.Scheme != "https":
+		return "", fmt.Errorf("%w: scheme must be https, got %q", ErrInvalidServerURL, u.Scheme)
+	case u.Hostname() == "":
+		return "", fmt.Errorf("%w: missing host", ErrInvalidServerURL)
+	case u.User != nil:
+		return "", fmt.Errorf("%w: credentials are not allowed", ErrInvalidServerURL)
+	case strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "":
+		return "", fmt.Errorf("%w: only scheme, host and port are allowed", ErrInvalidServerURL)
+	}
+
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// SetServerURL validates rawURL and stores it as the server URL in reg.
+func SetServerURL(reg ConfigRegistry, rawURL string) error {
+	serverURL, err := ValidateServerURL(rawURL)
+	if err != nil {
+		return err
+	}
+
+	return reg.CreateEntry(&registry.RegistryEntry{
+		Path:  registry.CONFIG,
+		Key:   ServerURLKey,
+		Value: serverURL,
+	})
+}
+
+// GetServerURL returns the server URL stored in reg.
+func GetServerURL(reg ConfigRegistry) (string, error) {
+	entry, err := reg.GetEntry(registry.CONFIG, ServerURLKey, false)
+	if err != nil {
+		return "", fmt.Errorf("GetServerURL: %w", err)
+	}
+	return entry.Value, nil
+}
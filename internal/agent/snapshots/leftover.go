@@ -0,0 +1,58 @@
+package snapshots
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultLeftoverSnapshotAge is how old a snapshot of a backup still listed
+// as active can get before ReleaseLeftoverSnapshots treats it as left behind.
+const DefaultLeftoverSnapshotAge = 24 * time.Hour
+
+// leftoverSnapshot is a snapshot the agent created for a job.
+type leftoverSnapshot struct {
+	JobId   string
+	Path    string
+	Created time.Time
+}
+
+// snapshotLinks lists and releases the snapshots the agent created, so a
+// snapshot kept by a process that died can be found on the next start.
+type snapshotLinks interface {
+	List() ([]leftoverSnapshot, error)
+	Release(snapshot leftoverSnapshot) error
+}
+
+// ReleaseLeftoverSnapshots releases the snapshots left behind by an agent
+// that stopped mid-backup: those whose job has no active backup, and those
+// older than maxAge whatever the job. It returns the jobs of the snapshots
+// it released.
+func ReleaseLeftoverSnapshots(active func(jobId string) bool, maxAge time.Duration) ([]string, error) {
+	return releaseLeftoverSnapshots(platformSnapshotLinks, active, maxAge, time.Now())
+}
+
+func releaseLeftoverSnapshots(links snapshotLinks, active func(jobId string) bool, maxAge time.Duration, now time.Time) ([]string, error) {
+	leftovers, err := links.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var (
+		released []string
+		errs     []error
+	)
+	for _, snapshot := range leftovers {
+		stale := maxAge > 0 && !snapshot.Created.IsZero() && now.Sub(snapshot.Created) > maxAge
+		if !stale && active(snapshot.JobId) {
+			continue
+		}
+		if err := links.Release(snapshot); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release snapshot of %s: %w", snapshot.JobId, err))
+			continue
+		}
+		released = append(released, snapshot.JobId)
+	}
+
+	return released, errors.Join(errs...)
+}
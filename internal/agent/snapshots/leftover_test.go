@@ -0,0 +1,53 @@
+package snapshots
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSnapshotLinks struct {
+	snapshots []leftoverSnapshot
+	failing   map[string]bool
+	released  []string
+}
+
+func (f *fakeSnapshotLinks) List() ([]leftoverSnapshot, error) {
+	return f.snapshots, nil
+}
+
+func (f *fakeSnapshotLinks) Release(snapshot leftoverSnapshot) error {
+	if f.failing[snapshot.JobId] {
+		return errors.New("access denied")
+	}
+	f.released = append(f.released, snapshot.JobId)
+	return nil
+}
+
+func TestReleaseLeftoverSnapshots(t *testing.T) {
+	now := time.Now()
+	links := &fakeSnapshotLinks{
+		snapshots: []leftoverSnapshot{
+			{JobId: "running", Created: now.Add(-time.Hour)},
+			{JobId: "crashed", Created: now.Add(-time.Hour)},
+			{JobId: "stuck", Created: now.Add(-48 * time.Hour)},
+			{JobId: "dangling"},
+			{JobId: "locked", Created: now.Add(-time.Hour)},
+		},
+		failing: map[string]bool{"locked": true},
+	}
+	active := map[string]bool{"running": true, "stuck": true}
+
+	released, err := releaseLeftoverSnapshots(links, func(jobId string) bool {
+		return active[jobId]
+	}, DefaultLeftoverSnapshotAge, now)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "locked")
+
+	assert.ElementsMatch(t, []string{"crashed", "stuck", "dangling"}, released)
+	assert.Equal(t, released, links.released)
+	assert.NotContains(t, links.released, "running", "snapshot of an active backup is kept")
+}
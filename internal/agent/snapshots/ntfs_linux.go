@@ -19,3 +19,16 @@ func (w *NtfsSnapshotHandler) DeleteSnapshot(snapshot Snapshot) error {
 func (w *NtfsSnapshotHandler) IsSupported(sourcePath string) bool {
 	return false
 }
+
+// vssLinks has no snapshots to list outside of Windows.
+type vssLinks struct{}
+
+var platformSnapshotLinks snapshotLinks = vssLinks{}
+
+func (vssLinks) List() ([]leftoverSnapshot, error) {
+	return nil, nil
+}
+
+func (vssLinks) Release(snapshot leftoverSnapshot) error {
+	return nil
+}
@@ -163,3 +163,43 @@ func cleanupExistingSnapshot(path string) {
 		}
 	}
 }
+
+// vssLinks lists the snapshots linked under the VSS folder, one per job.
+type vssLinks struct{}
+
+var platformSnapshotLinks snapshotLinks = vssLinks{}
+
+func (vssLinks) List() ([]leftoverSnapshot, error) {
+	vssFolder, err := getVSSFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(vssFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	leftovers := make([]leftoverSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		snapshot := leftoverSnapshot{
+			JobId: entry.Name(),
+			Path:  filepath.Join(vssFolder, entry.Name()),
+		}
+		// A link whose shadow copy is gone is listed without a date and
+		// released as soon as its job is not running.
+		if sc, err := vss.Get(snapshot.Path); err == nil {
+			snapshot.Created = sc.InstallDate
+		}
+		leftovers = append(leftovers, snapshot)
+	}
+	return leftovers, nil
+}
+
+func (vssLinks) Release(snapshot leftoverSnapshot) error {
+	cleanupExistingSnapshot(snapshot.Path)
+	if _, err := os.Lstat(snapshot.Path); err == nil {
+		return fmt.Errorf("snapshot link %q still exists", snapshot.Path)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package agent
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/alexflint/go-filemutex"
@@ -13,9 +14,33 @@ type BackupSessionData struct {
 	StartTime time.Time `json:"start_time"`
 }
 
+// USNWatermark is the position in the change journal of a volume up to
+// which the last finished backup of a job saw the changes. It only applies
+// to the journal it was taken from.
+type USNWatermark struct {
+	Volume    string    `json:"volume"`
+	JournalID uint64    `json:"journal_id"`
+	USN       int64     `json:"usn"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type BackupStore struct {
-	filePath string
-	fileLock *filemutex.FileMutex
+	filePath      string
+	watermarkPath string
+	fileLock      *filemutex.FileMutex
+}
+
+func newBackupStore(dir string) (*BackupStore, error) {
+	fl, err := filemutex.New(filepath.Join(dir, "backup_sessions.lock"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &BackupStore{
+		filePath:      filepath.Join(dir, "backup_sessions.json"),
+		watermarkPath: filepath.Join(dir, "backup_watermarks.json"),
+		fileLock:      fl,
+	}, nil
 }
 
 func (bs *BackupStore) updateSessions(fn func(map[string]*BackupSessionData)) error {
@@ -101,6 +126,12 @@ func (bs *BackupStore) HasActiveBackupForJob(job string) (bool, error) {
 	return exists, nil
 }
 
+// ClearAll forgets every backup session, as when the connection to the
+// server drops. Interrupted jobs are not resumed from where they stopped:
+// a PBS snapshot has to hold the whole source and a failed run leaves none
+// behind, so skipping the files read before the drop would leave them out
+// of the next snapshot. The next run starts over instead, and the client
+// still reuses the chunks of the last finished snapshot for unchanged files.
 func (bs *BackupStore) ClearAll() error {
 	return bs.updateSessions(func(sessions map[string]*BackupSessionData) {
 		for job := range sessions {
@@ -108,3 +139,62 @@ func (bs *BackupStore) ClearAll() error {
 		}
 	})
 }
+
+// SaveUSNWatermark stores the change journal position of the last finished
+// backup of jobId.
+func (bs *BackupStore) SaveUSNWatermark(jobId string, wm USNWatermark) error {
+	if wm.UpdatedAt.IsZero() {
+		wm.UpdatedAt = time.Now()
+	}
+
+	if err := bs.fileLock.Lock(); err != nil {
+		return err
+	}
+	defer bs.fileLock.Unlock()
+
+	watermarks := make(map[string]*USNWatermark)
+	data, err := os.ReadFile(bs.watermarkPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &watermarks); err != nil {
+			watermarks = make(map[string]*USNWatermark)
+		}
+	}
+
+	watermarks[jobId] = &wm
+
+	newData, err := json.MarshalIndent(watermarks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(bs.watermarkPath, newData, 0644)
+}
+
+// LoadUSNWatermark returns the change journal position saved for jobId and
+// whether there was any.
+func (bs *BackupStore) LoadUSNWatermark(jobId string) (USNWatermark, bool, error) {
+	if err := bs.fileLock.Lock(); err != nil {
+		return USNWatermark{}, false, err
+	}
+	defer bs.fileLock.Unlock()
+
+	watermarks := make(map[string]*USNWatermark)
+	data, err := os.ReadFile(bs.watermarkPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return USNWatermark{}, false, nil
+		}
+		return USNWatermark{}, false, err
+	}
+	if err := json.Unmarshal(data, &watermarks); err != nil {
+		return USNWatermark{}, false, err
+	}
+
+	wm, exists := watermarks[jobId]
+	if !exists || wm == nil {
+		return USNWatermark{}, false, nil
+	}
+	return *wm, true, nil
+}
@@ -0,0 +1,33 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupStoreUSNWatermark(t *testing.T) {
+	store, err := newBackupStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, ok, err := store.LoadUSNWatermark("a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, store.SaveUSNWatermark("a", USNWatermark{Volume: "C", JournalID: 7, USN: 100}))
+	require.NoError(t, store.SaveUSNWatermark("b", USNWatermark{Volume: "D", JournalID: 8, USN: 200}))
+	require.NoError(t, store.SaveUSNWatermark("a", USNWatermark{Volume: "C", JournalID: 7, USN: 150}))
+
+	wm, ok, err := store.LoadUSNWatermark("a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "C", wm.Volume)
+	assert.Equal(t, uint64(7), wm.JournalID)
+	assert.Equal(t, int64(150), wm.USN)
+
+	wm, ok, err = store.LoadUSNWatermark("b")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(200), wm.USN)
+}
@@ -14,7 +14,6 @@ import (
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/registry"
 	"github.com/sonroyaalmerol/pbs-plus/internal/auth/certificates"
-	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
 )
 
 func GetTLSConfig() (*tls.Config, error) {
@@ -99,7 +98,7 @@ func renewCertificate() error {
 
 	encodedCSR := base64.StdEncoding.EncodeToString(csr)
 
-	drives, err := utils.GetLocalDrives()
+	drives, err := LocalDrives()
 	if err != nil {
 		return fmt.Errorf("Bootstrap: failed to get local drives list: %w", err)
 	}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"time"
+)
+
+// UpdateHealth is the state of the agent service observed after its binary
+// was swapped by an update.
+type UpdateHealth struct {
+	Running bool
+	// Version is the content of version.txt, written by the agent when it
+	// starts.
+	Version string
+}
+
+// UpdateHealthy reports whether h shows the new binary up: the service runs
+// and wrote a version other than previous, the version before the update.
+func UpdateHealthy(h UpdateHealth, previous string) bool {
+	return h.Running && h.Version != "" && h.Version != previous
+}
+
+// UpdateCheck tells how long an updated agent has to become healthy.
+type UpdateCheck struct {
+	Timeout  time.Duration
+	Interval time.Duration
+	// Settle is the number of consecutive healthy observations required, so
+	// a binary that crashes shortly after starting is caught.
+	Settle int
+}
+
+var DefaultUpdateCheck = UpdateCheck{
+	Timeout:  2 * time.Minute,
+	Interval: 5 * time.Second,
+	Settle:   3,
+}
+
+// WaitForHealthyUpdate polls observe until the update has been healthy for
+// check.Settle consecutive observations. It returns false, meaning the update
+// is to be rolled back, when that does not happen within check.Timeout or
+// ctx is done first.
+func WaitForHealthyUpdate(ctx context.Context, check UpdateCheck, previous string, observe func() UpdateHealth) bool {
+	settle := max(check.Settle, 1)
+
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(check.Interval)
+	defer ticker.Stop()
+
+	healthy := 0
+	for {
+		if UpdateHealthy(observe(), previous) {
+			healthy++
+			if healthy >= settle {
+				return true
+			}
+		} else {
+			healthy = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// observations replays states, repeating the last one once exhausted.
+func observations(states ...UpdateHealth) func() UpdateHealth {
+	i := 0
+	return func() UpdateHealth {
+		state := states[min(i, len(states)-1)]
+		i++
+		return state
+	}
+}
+
+func TestUpdateHealthy(t *testing.T) {
+	assert.True(t, UpdateHealthy(UpdateHealth{Running: true, Version: "v0.51.0"}, "v0.50.0"))
+	assert.False(t, UpdateHealthy(UpdateHealth{Running: false, Version: "v0.51.0"}, "v0.50.0"), "stopped")
+	assert.False(t, UpdateHealthy(UpdateHealth{Running: true, Version: "v0.50.0"}, "v0.50.0"), "version not rewritten")
+	assert.False(t, UpdateHealthy(UpdateHealth{Running: true}, "v0.50.0"), "no version")
+}
+
+func TestWaitForHealthyUpdate(t *testing.T) {
+	check := UpdateCheck{Timeout: 200 * time.Millisecond, Interval: time.Millisecond, Settle: 3}
+	const previous = "v0.50.0"
+	up := UpdateHealth{Running: true, Version: "v0.51.0"}
+	starting := UpdateHealth{Running: true, Version: previous}
+	stopped := UpdateHealth{Version: "v0.51.0"}
+
+	t.Run("Healthy", func(t *testing.T) {
+		assert.True(t, WaitForHealthyUpdate(context.Background(), check, previous, observations(up)))
+	})
+
+	t.Run("HealthyAfterStarting", func(t *testing.T) {
+		assert.True(t, WaitForHealthyUpdate(context.Background(), check, previous, observations(starting, starting, up)))
+	})
+
+	t.Run("NeverStarts", func(t *testing.T) {
+		assert.False(t, WaitForHealthyUpdate(context.Background(), check, previous, observations(starting)))
+	})
+
+	t.Run("CrashesAfterStarting", func(t *testing.T) {
+		assert.False(t, WaitForHealthyUpdate(context.Background(), check, previous, observations(up, up, stopped)))
+	})
+
+	t.Run("CrashLoop", func(t *testing.T) {
+		flapping := func() func() UpdateHealth {
+			i := 0
+			return func() UpdateHealth {
+				i++
+				if i%2 == 0 {
+					return stopped
+				}
+				return up
+			}
+		}()
+		assert.False(t, WaitForHealthyUpdate(context.Background(), check, previous, flapping))
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.False(t, WaitForHealthyUpdate(ctx, check, previous, observations(starting)))
+	})
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/registry"
+)
+
+// Values of registry.CONFIG read by the updater. All are optional.
+const (
+	// UpdateCheckIntervalKey holds a duration such as "30m", or a number of
+	// minutes.
+	UpdateCheckIntervalKey = "UpdateCheckInterval"
+	// UpdateWindowStartKey and UpdateWindowEndKey hold times of day such as
+	// "22:00". A window ending before it starts spans midnight.
+	UpdateWindowStartKey = "UpdateWindowStart"
+	UpdateWindowEndKey   = "UpdateWindowEnd"
+	// UpdateWindowDaysKey holds the comma-separated days the window opens
+	// on, such as "Sat,Sun". It defaults to every day.
+	UpdateWindowDaysKey = "UpdateWindowDays"
+)
+
+const (
+	DefaultUpdateCheckInterval = 2 * time.Minute
+	MinUpdateCheckInterval     = time.Minute
+)
+
+// MaintenanceWindow is the time of day, on some days of the week, during
+// which the agent may be updated.
+type MaintenanceWindow struct {
+	Start time.Duration // since midnight
+	End   time.Duration // since midnight
+	Days  [7]bool       // indexed by time.Weekday
+}
+
+// Allows reports whether t falls in the window. A window spanning midnight
+// belongs to the day it opens on, and a window whose start equals its end
+// lasts the whole day.
+func (w MaintenanceWindow) Allows(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+
+	switch {
+	case w.Start == w.End:
+		return w.Days[day]
+	case w.Start < w.End:
+		return w.Days[day] && offset >= w.Start && offset < w.End
+	default:
+		if offset >= w.Start {
+			return w.Days[day]
+		}
+		return offset < w.End && w.Days[(day+6)%7]
+	}
+}
+
+// UpdateSchedule tells how often the updater checks for a new version and
+// when it may install one. Without a window, updates are allowed any time.
+type UpdateSchedule struct {
+	Interval time.Duration
+	Window   *MaintenanceWindow
+}
+
+// UpdateAllowed reports whether an update may be installed at now.
+func (s UpdateSchedule) UpdateAllowed(now time.Time) bool {
+	return s.Window == nil || s.Window.Allows(now)
+}
+
+// LoadUpdateSchedule reads the update schedule from reg. Missing values
+// leave the defaults; invalid ones are reported along with the defaults, so
+// a typo never stops updates altogether.
+func LoadUpdateSchedule(reg ConfigRegistry) (UpdateSchedule, error) {
+	schedule := UpdateSchedule{Interval: DefaultUpdateCheckInterval}
+
+	value := func(key string) string {
+		entry, err := reg.GetEntry(registry.CONFIG, key, false)
+		if err != nil || entry == nil {
+			return ""
+		}
+		return strings.TrimSpace(entry.Value)
+	}
+
+	if raw := value(UpdateCheckIntervalKey); raw != "" {
+		interval, err := ParseUpdateCheckInterval(raw)
+		if err != nil {
+			return UpdateSchedule{Interval: DefaultUpdateCheckInterval}, err
+		}
+		schedule.Interval = interval
+	}
+
+	start, end, days := value(UpdateWindowStartKey), value(UpdateWindowEndKey), value(UpdateWindowDaysKey)
+	if start != "" || end != "" || days != "" {
+		window, err := ParseMaintenanceWindow(start, end, days)
+		if err != nil {
+			return UpdateSchedule{Interval: schedule.Interval}, err
+		}
+		schedule.Window = &window
+	}
+
+	return schedule, nil
+}
+
+// ParseUpdateCheckInterval reads a duration such as "30m", or a plain number
+// of minutes. Intervals under MinUpdateCheckInterval are raised to it.
+func ParseUpdateCheckInterval(raw string) (time.Duration, error) {
+	var interval time.Duration
+	if minutes, err := strconv.Atoi(raw); err == nil {
+		interval = time.Duration(minutes) * time.Minute
+	} else if interval, err = time.ParseDuration(raw); err != nil {
+		return 0, fmt.Errorf("invalid update check interval %q", raw)
+	}
+	return max(interval, MinUpdateCheckInterval), nil
+}
+
+// ParseMaintenanceWindow reads a window from its start and end times of day,
+// "HH:MM" in local time, and its comma-separated days. Both times are
+// required; days default to every day.
+func ParseMaintenanceWindow(start, end, days string) (MaintenanceWindow, error) {
+	var window MaintenanceWindow
+
+	if start == "" || end == "" {
+		return window, fmt.Errorf("maintenance window needs both a start and an end")
+	}
+	var err error
+	if window.Start, err = parseTimeOfDay(start); err != nil {
+		return window, err
+	}
+	if window.End, err = parseTimeOfDay(end); err != nil {
+		return window, err
+	}
+
+	if strings.TrimSpace(days) == "" {
+		for day := range window.Days {
+			window.Days[day] = true
+		}
+		return window, nil
+	}
+	for _, name := range strings.Split(days, ",") {
+		day, ok := parseWeekday(strings.TrimSpace(name))
+		if !ok {
+			return window, fmt.Errorf("invalid maintenance window day %q", name)
+		}
+		window.Days[day] = true
+	}
+	return window, nil
+}
+
+func parseTimeOfDay(raw string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(raw))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", raw)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := day.String()
+		if strings.EqualFold(name, full) || strings.EqualFold(name, full[:3]) {
+			return day, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// at returns the given time of day on the first day of October 2023 that
+// falls on weekday.
+func at(weekday time.Weekday, hour, minute int) time.Time {
+	// 1 October 2023 is a Sunday.
+	return time.Date(2023, time.October, 1+int(weekday), hour, minute, 0, 0, time.Local)
+}
+
+func TestMaintenanceWindowAllows(t *testing.T) {
+	t.Run("SameDay", func(t *testing.T) {
+		window, err := ParseMaintenanceWindow("02:00", "05:00", "")
+		require.NoError(t, err)
+
+		assert.False(t, window.Allows(at(time.Monday, 1, 59)))
+		assert.True(t, window.Allows(at(time.Monday, 2, 0)), "start is inclusive")
+		assert.True(t, window.Allows(at(time.Monday, 4, 59)))
+		assert.False(t, window.Allows(at(time.Monday, 5, 0)), "end is exclusive")
+	})
+
+	t.Run("AcrossMidnight", func(t *testing.T) {
+		window, err := ParseMaintenanceWindow("22:00", "04:00", "Fri")
+		require.NoError(t, err)
+
+		assert.False(t, window.Allows(at(time.Friday, 21, 59)))
+		assert.True(t, window.Allows(at(time.Friday, 22, 0)))
+		assert.True(t, window.Allows(at(time.Friday, 23, 59)))
+		assert.True(t, window.Allows(at(time.Saturday, 0, 0)), "the window opened on Friday")
+		assert.True(t, window.Allows(at(time.Saturday, 3, 59)))
+		assert.False(t, window.Allows(at(time.Saturday, 4, 0)))
+		assert.False(t, window.Allows(at(time.Saturday, 22, 30)), "the window does not open on Saturday")
+		assert.False(t, window.Allows(at(time.Friday, 1, 0)), "Thursday's window is not configured")
+	})
+
+	t.Run("WholeDay", func(t *testing.T) {
+		window, err := ParseMaintenanceWindow("00:00", "00:00", "sat, Sunday")
+		require.NoError(t, err)
+
+		assert.True(t, window.Allows(at(time.Saturday, 0, 0)))
+		assert.True(t, window.Allows(at(time.Sunday, 23, 59)))
+		assert.False(t, window.Allows(at(time.Monday, 12, 0)))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ParseMaintenanceWindow("22:00", "", "")
+		assert.Error(t, err)
+		_, err = ParseMaintenanceWindow("25:00", "04:00", "")
+		assert.Error(t, err)
+		_, err = ParseMaintenanceWindow("22:00", "04:00", "Funday")
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadUpdateSchedule(t *testing.T) {
+	set := func(reg *memoryRegistry, key, value string) {
+		require.NoError(t, reg.CreateEntry(&registry.RegistryEntry{Path: registry.CONFIG, Key: key, Value: value}))
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		schedule, err := LoadUpdateSchedule(newMemoryRegistry())
+		require.NoError(t, err)
+		assert.Equal(t, DefaultUpdateCheckInterval, schedule.Interval)
+		assert.Nil(t, schedule.Window)
+		assert.True(t, schedule.UpdateAllowed(at(time.Wednesday, 13, 0)))
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		for raw, want := range map[string]time.Duration{
+			"30":  30 * time.Minute,
+			"1h":  time.Hour,
+			"10s": MinUpdateCheckInterval,
+		} {
+			reg := newMemoryRegistry()
+			set(reg, UpdateCheckIntervalKey, raw)
+			schedule, err := LoadUpdateSchedule(reg)
+			require.NoError(t, err, raw)
+			assert.Equal(t, want, schedule.Interval, raw)
+		}
+
+		reg := newMemoryRegistry()
+		set(reg, UpdateCheckIntervalKey, "often")
+		schedule, err := LoadUpdateSchedule(reg)
+		assert.Error(t, err)
+		assert.Equal(t, DefaultUpdateCheckInterval, schedule.Interval)
+	})
+
+	t.Run("UpdatesSkippedOutsideWindow", func(t *testing.T) {
+		reg := newMemoryRegistry()
+		set(reg, UpdateWindowStartKey, "01:00")
+		set(reg, UpdateWindowEndKey, "03:00")
+		set(reg, UpdateWindowDaysKey, "Sun")
+
+		schedule, err := LoadUpdateSchedule(reg)
+		require.NoError(t, err)
+		require.NotNil(t, schedule.Window)
+
+		assert.True(t, schedule.UpdateAllowed(at(time.Sunday, 2, 0)))
+		assert.False(t, schedule.UpdateAllowed(at(time.Sunday, 3, 0)))
+		assert.False(t, schedule.UpdateAllowed(at(time.Monday, 2, 0)))
+	})
+
+	t.Run("InvalidWindowAllowsUpdates", func(t *testing.T) {
+		reg := newMemoryRegistry()
+		set(reg, UpdateWindowStartKey, "01:00")
+
+		schedule, err := LoadUpdateSchedule(reg)
+		assert.Error(t, err)
+		assert.True(t, schedule.UpdateAllowed(at(time.Monday, 12, 0)))
+	})
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	ErrEmptyBinary      = errors.New("downloaded binary is empty")
+	ErrNotExecutable    = errors.New("downloaded binary is not a Windows executable")
+	ErrChecksumFormat   = errors.New("unrecognized checksum")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// ParseChecksum reads a checksum as published next to a release binary: a
+// hex digest, optionally followed by the file name the way sha256sum and
+// md5sum print it. It returns the digest in lower case along with the name
+// of its algorithm, "sha256" or "md5", told apart by length.
+func ParseChecksum(raw string) (string, string, error) {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return "", "", fmt.Errorf("%w: empty", ErrChecksumFormat)
+	}
+
+	digest := strings.ToLower(fields[0])
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", "", fmt.Errorf("%w: %q is not hexadecimal", ErrChecksumFormat, fields[0])
+	}
+
+	switch len(digest) {
+	case sha256.Size * 2:
+		return digest, "sha256", nil
+	case md5.Size * 2:
+		return digest, "md5", nil
+	default:
+		return "", "", fmt.Errorf("%w: unexpected digest length %d", ErrChecksumFormat, len(digest))
+	}
+}
+
+// VerifyBinary checks the update downloaded at path against checksum before
+// it may replace the running executable: the file must be a non-empty
+// Windows executable whose digest matches. The SHA-256 of the file is
+// returned for logging, whichever algorithm checksum uses.
+func VerifyBinary(path string, checksum string) (string, error) {
+	expected, algorithm, err := ParseChecksum(checksum)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(file, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", ErrEmptyBinary
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return "", ErrNotExecutable
+		}
+		return "", err
+	}
+	if !bytes.Equal(header, []byte("MZ")) {
+		return "", ErrNotExecutable
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	sha := sha256.New()
+	var check hash.Hash = sha
+	var w io.Writer = sha
+	if algorithm == "md5" {
+		check = md5.New()
+		w = io.MultiWriter(sha, check)
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(sha.Sum(nil))
+	if actual := hex.EncodeToString(check.Sum(nil)); actual != expected {
+		return sum, fmt.Errorf("%w: expected %s %s, got %s", ErrChecksumMismatch, algorithm, expected, actual)
+	}
+	return sum, nil
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBinary(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "update.tmp")
+	require.NoError(t, os.WriteFile(path, content, 0644))
+	return path
+}
+
+func TestVerifyBinary(t *testing.T) {
+	content := []byte("MZ\x90\x00 pretend this is a PE image")
+	sha := sha256.Sum256(content)
+	shaHex := hex.EncodeToString(sha[:])
+	md := md5.Sum(content)
+	mdHex := hex.EncodeToString(md[:])
+
+	path := writeBinary(t, content)
+
+	t.Run("MatchingSHA256", func(t *testing.T) {
+		sum, err := VerifyBinary(path, shaHex+"  pbs-plus-agent.exe\n")
+		require.NoError(t, err)
+		assert.Equal(t, shaHex, sum)
+	})
+
+	t.Run("MatchingMD5", func(t *testing.T) {
+		sum, err := VerifyBinary(path, strings.ToUpper(mdHex))
+		require.NoError(t, err)
+		assert.Equal(t, shaHex, sum, "the SHA-256 is reported whatever the checksum")
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		other := sha256.Sum256([]byte("something else"))
+		_, err := VerifyBinary(path, hex.EncodeToString(other[:]))
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+
+	t.Run("ZeroByteDownload", func(t *testing.T) {
+		empty := sha256.Sum256(nil)
+		_, err := VerifyBinary(writeBinary(t, nil), hex.EncodeToString(empty[:]))
+		assert.ErrorIs(t, err, ErrEmptyBinary)
+	})
+
+	t.Run("NotAnExecutable", func(t *testing.T) {
+		page := []byte("<html>404 not found</html>")
+		sum := sha256.Sum256(page)
+		_, err := VerifyBinary(writeBinary(t, page), hex.EncodeToString(sum[:]))
+		assert.ErrorIs(t, err, ErrNotExecutable)
+	})
+
+	t.Run("UnusableChecksum", func(t *testing.T) {
+		for _, checksum := range []string{"", "not found", "abc123"} {
+			_, err := VerifyBinary(path, checksum)
+			assert.ErrorIs(t, err, ErrChecksumFormat, checksum)
+		}
+	})
+}
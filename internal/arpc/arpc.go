@@ -11,6 +11,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	binarystream "github.com/sonroyaalmerol/pbs-plus/internal/arpc/binary"
 	"github.com/xtaci/smux"
 )
 
@@ -37,12 +38,51 @@ type Session struct {
 	cancelFunc context.CancelFunc
 
 	version string
+
+	// Throttles the payload of binary calls; nil when unlimited.
+	rateLimiter atomic.Pointer[binarystream.Limiter]
+
+	// Streams being served, and the channel closed once they are done
+	// after Shutdown; nil until then.
+	drainMu  sync.Mutex
+	inflight int
+	drained  chan struct{}
+
+	// Streams served at once past which new ones are rejected; zero when
+	// unlimited.
+	maxStreams int
 }
 
+// ErrTooManyStreams is returned to calls that reach a session already
+// serving its maximum number of concurrent streams.
+var ErrTooManyStreams = errors.New("arpc session has too many concurrent streams")
+
 func (s *Session) SetRouter(router Router) {
 	s.router.Store(&router) // Store a pointer to the value
 }
 
+// SetRateLimiter throttles the data received by CallBinary to the rate of
+// limiter. A nil limiter removes the limit.
+func (s *Session) SetRateLimiter(limiter *binarystream.Limiter) {
+	s.rateLimiter.Store(limiter)
+}
+
+// SetMaxConcurrentStreams caps the streams Serve handles at once. Streams
+// opened beyond it are answered with ErrTooManyStreams. Zero removes the
+// cap.
+func (s *Session) SetMaxConcurrentStreams(n int) {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	s.maxStreams = n
+}
+
+// ActiveStreams returns the number of streams being served.
+func (s *Session) ActiveStreams() int {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	return s.inflight
+}
+
 func (s *Session) GetRouter() *Router {
 	return s.router.Load()
 }
@@ -108,7 +148,15 @@ func defaultSmuxConfig() *smux.Config {
 }
 
 // If a stream accept fails and auto‑reconnect is enabled, it attempts to reconnect.
+// With a KeepaliveInterval configured, the peer is pinged while serving and
+// a peer that stopped answering is treated as a failed accept.
 func (s *Session) Serve() error {
+	if rc := s.reconnectConfig; rc.KeepaliveInterval > 0 {
+		ctx, cancel := context.WithCancel(s.ctx)
+		defer cancel()
+		go s.keepalive(ctx, rc)
+	}
+
 	for {
 		curSession := s.muxSess.Load()
 		rc := s.reconnectConfig
@@ -116,6 +164,9 @@ func (s *Session) Serve() error {
 		stream, err := curSession.AcceptStream()
 		if err != nil {
 			s.state.Store(int32(StateDisconnected))
+			if s.draining() {
+				return ErrShuttingDown
+			}
 			if rc.AutoReconnect {
 				if err2 := s.attemptReconnect(); err2 != nil {
 					return err2
@@ -125,9 +176,17 @@ func (s *Session) Serve() error {
 			return err
 		}
 		router := s.GetRouter()
-		if router != nil {
-			go router.ServeStream(stream)
+		if router == nil {
+			continue
+		}
+		if err := s.beginStream(); err != nil {
+			go rejectStream(stream, err)
+			continue
 		}
+		go func() {
+			defer s.endStream()
+			router.ServeStream(stream)
+		}()
 	}
 }
 
@@ -177,6 +236,10 @@ func ConnectToServer(ctx context.Context, autoReconnect bool, serverAddr string,
 			BackoffJitter:    0.2,
 			CircuitBreakTime: 60 * time.Second,
 			ReconnectCtx:     ctx,
+
+			KeepaliveInterval:  30 * time.Second,
+			KeepaliveTimeout:   10 * time.Second,
+			KeepaliveMaxMissed: 3,
 		})
 	}
 
@@ -3,11 +3,13 @@ package arpc
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/rand"
 	"net"
 	_ "net/http/pprof"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -240,6 +242,47 @@ func TestSessionCall_Success(t *testing.T) {
 	}
 }
 
+func TestSessionCall_LargeRequest(t *testing.T) {
+	router := NewRouter()
+	router.Handle("echo", func(req Request) (Response, error) {
+		var msg StringMsg
+		if err := msg.Decode(req.Payload); err != nil {
+			return Response{}, err
+		}
+		data, _ := msg.Encode()
+		return Response{Status: 200, Data: data}, nil
+	})
+
+	clientSession, cleanup := setupSessionWithRouter(t, router)
+	defer cleanup()
+
+	// Well past the 4 KB pooled buffer the request is first read into.
+	payload := StringMsg(strings.Repeat("x", 100*1024))
+	resp, err := clientSession.Call("echo", &payload)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if resp.Status != 200 {
+		t.Fatalf("expected status 200, got %d: %s", resp.Status, resp.Message)
+	}
+
+	var echoed StringMsg
+	if err := echoed.Decode(resp.Data); err != nil {
+		t.Fatalf("failed to decode echo: %v", err)
+	}
+	if echoed != payload {
+		t.Fatalf("expected %d bytes echoed back, got %d", len(payload), len(echoed))
+	}
+}
+
+func TestReadRequest_RejectsOversizedLength(t *testing.T) {
+	header := make([]byte, 4)
+	binary.LittleEndian.PutUint32(header, MaxRequestSize+1)
+	if _, err := readRequest(bytes.NewReader(header), make([]byte, 4096)); err == nil {
+		t.Fatal("expected an oversized request to be rejected")
+	}
+}
+
 // ---------------------------------------------------------------------
 // Test 3: Concurrency test.
 // Spawn many concurrent goroutines making calls via the same session.
@@ -319,6 +362,207 @@ func TestCallContext_Timeout(t *testing.T) {
 	}
 }
 
+func TestRouter_HandlerTimeout(t *testing.T) {
+	observed := make(chan error, 1)
+
+	router := NewRouter()
+	router.Handle("slow", func(req Request) (Response, error) {
+		select {
+		case <-req.Context().Done():
+			observed <- req.Context().Err()
+			return Response{}, req.Context().Err()
+		case <-time.After(5 * time.Second):
+			observed <- nil
+			return Response{Status: 200}, nil
+		}
+	}, WithTimeout(100*time.Millisecond))
+	router.Handle("fast", func(req Request) (Response, error) {
+		var done StringMsg = "done"
+		doneBytes, _ := done.Encode()
+		return Response{Status: 200, Data: doneBytes}, nil
+	}, WithTimeout(time.Second))
+
+	clientSession, cleanup := setupSessionWithRouter(t, router)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := clientSession.CallMsg(ctx, "slow", nil)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected a handler timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("call took %v, expected it to end at the handler timeout", elapsed)
+	}
+
+	select {
+	case err := <-observed:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("handler did not observe the timeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not observe the timeout")
+	}
+
+	data, err := clientSession.CallMsg(ctx, "fast", nil)
+	if err != nil {
+		t.Fatalf("fast handler failed: %v", err)
+	}
+	var msg StringMsg
+	if err := msg.Decode(data); err != nil || msg != "done" {
+		t.Fatalf("unexpected fast response %q (%v)", msg, err)
+	}
+}
+
+func TestCallContext_CancelReachesHandler(t *testing.T) {
+	started := make(chan struct{})
+	observed := make(chan error, 1)
+
+	router := NewRouter()
+	router.Handle("slow", func(req Request) (Response, error) {
+		close(started)
+		select {
+		case <-req.Context().Done():
+			observed <- req.Context().Err()
+			return Response{}, req.Context().Err()
+		case <-time.After(5 * time.Second):
+			observed <- nil
+			return Response{Status: 200}, nil
+		}
+	})
+
+	clientSession, cleanup := setupSessionWithRouter(t, router)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	_, err := clientSession.CallMsg(ctx, "slow", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	select {
+	case err := <-observed:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("handler ran to completion instead of observing cancellation")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not observe cancellation")
+	}
+}
+
+func TestCallStream_Messages(t *testing.T) {
+	const count = 20
+
+	router := NewRouter()
+	router.Handle("tail", func(req Request) (Response, error) {
+		return FrameStream(func(send func([]byte) error) error {
+			for i := 0; i < count; i++ {
+				time.Sleep(time.Millisecond)
+				if err := send([]byte(fmt.Sprintf("message %d", i))); err != nil {
+					return err
+				}
+			}
+			return nil
+		}), nil
+	})
+
+	clientSession, cleanup := setupSessionWithRouter(t, router)
+	defer cleanup()
+
+	var received []string
+	err := clientSession.CallStream(context.Background(), "tail", nil, func(frame []byte) error {
+		received = append(received, string(frame))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("CallStream failed: %v", err)
+	}
+	if len(received) != count {
+		t.Fatalf("expected %d messages, got %d", count, len(received))
+	}
+	for i, msg := range received {
+		if want := fmt.Sprintf("message %d", i); msg != want {
+			t.Fatalf("message %d: expected %q, got %q", i, want, msg)
+		}
+	}
+}
+
+func TestCallStream_CancelStopsHandler(t *testing.T) {
+	stopped := make(chan error, 1)
+
+	router := NewRouter()
+	router.Handle("tail", func(req Request) (Response, error) {
+		ctx := req.Context()
+		return FrameStream(func(send func([]byte) error) error {
+			ticker := time.NewTicker(5 * time.Millisecond)
+			defer ticker.Stop()
+			for i := 0; ; i++ {
+				select {
+				case <-ctx.Done():
+					stopped <- ctx.Err()
+					return ctx.Err()
+				case <-ticker.C:
+				}
+				if err := send([]byte(fmt.Sprintf("message %d", i))); err != nil {
+					stopped <- err
+					return err
+				}
+			}
+		}), nil
+	})
+
+	clientSession, cleanup := setupSessionWithRouter(t, router)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var received int
+	err := clientSession.CallStream(ctx, "tail", nil, func(frame []byte) error {
+		if want := fmt.Sprintf("message %d", received); string(frame) != want {
+			t.Errorf("expected %q, got %q", want, frame)
+		}
+		received++
+		if received == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if received < 5 {
+		t.Fatalf("expected at least 5 messages before cancelling, got %d", received)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler kept streaming after the call was cancelled")
+	}
+}
+
+func TestCancel_EncodeDecode(t *testing.T) {
+	original := Cancel{RequestID: 42}
+	encoded, err := original.Encode()
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	var decoded Cancel
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded != original {
+		t.Fatalf("expected %+v, got %+v", original, decoded)
+	}
+}
+
 // ---------------------------------------------------------------------
 // Test 5: Auto-reconnect.
 // Simulate a broken connection by closing the underlying session, and
@@ -616,6 +860,410 @@ func TestCallBinary_Concurrency(t *testing.T) {
 	clientWg.Wait()
 }
 
+// TestServeWithBackoff_ServerDown verifies that when the server is
+// unreachable the serve loop backs off between attempts and that the
+// circuit breaker holds off retries after MaxAttempts failures.
+func TestServeWithBackoff_ServerDown(t *testing.T) {
+	// Reserve an address and close the listener so every dial is refused.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var mu sync.Mutex
+	var attempts []time.Time
+	serve := func() error {
+		mu.Lock()
+		attempts = append(attempts, time.Now())
+		mu.Unlock()
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return errors.New("expected server to be down")
+		}
+		return err
+	}
+
+	var failures int32
+	rc := ReconnectConfig{
+		InitialBackoff:   10 * time.Millisecond,
+		MaxBackoff:       40 * time.Millisecond,
+		BackoffJitter:    0.1,
+		CircuitBreakTime: 400 * time.Millisecond,
+		MaxAttempts:      4,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	ServeWithBackoff(ctx, rc, serve, func(error) {
+		atomic.AddInt32(&failures, 1)
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// 4 attempts before the circuit opens; the 400ms break outlasts the
+	// context, so no further attempts may be made.
+	if len(attempts) != rc.MaxAttempts {
+		t.Fatalf("expected %d attempts before the circuit opened, got %d", rc.MaxAttempts, len(attempts))
+	}
+	if int(atomic.LoadInt32(&failures)) != len(attempts) {
+		t.Fatalf("expected onFailure for every attempt, got %d for %d attempts", failures, len(attempts))
+	}
+	for i := 2; i < len(attempts); i++ {
+		prev := attempts[i-1].Sub(attempts[i-2])
+		cur := attempts[i].Sub(attempts[i-1])
+		if cur < prev*3/2 {
+			t.Fatalf("expected backoff to grow: gap %d was %v after %v", i, cur, prev)
+		}
+	}
+}
+
+func TestServeBackoff_CircuitBreaker(t *testing.T) {
+	b := newServeBackoff(ReconnectConfig{
+		InitialBackoff:   100 * time.Millisecond,
+		MaxBackoff:       300 * time.Millisecond,
+		BackoffJitter:    0.1,
+		CircuitBreakTime: 10 * time.Second,
+		MaxAttempts:      5,
+	})
+
+	expected := []time.Duration{100, 200, 300, 300}
+	for i, base := range expected {
+		delay, open := b.failure()
+		if open {
+			t.Fatalf("circuit opened early at failure %d", i+1)
+		}
+		base *= time.Millisecond
+		if delay < base*9/10 || delay > base*11/10 {
+			t.Fatalf("failure %d: expected delay around %v, got %v", i+1, base, delay)
+		}
+	}
+
+	delay, open := b.failure()
+	if !open {
+		t.Fatal("expected circuit to open after MaxAttempts failures")
+	}
+	if delay < 9*time.Second || delay > 11*time.Second {
+		t.Fatalf("expected circuit break delay around 10s, got %v", delay)
+	}
+
+	// After the break the backoff starts over.
+	delay, open = b.failure()
+	if open || delay > 110*time.Millisecond {
+		t.Fatalf("expected backoff to restart after circuit break, got %v (open=%v)", delay, open)
+	}
+}
+
+// TestServeWithBackoff_ResetsAfterConnection verifies that a connection
+// that stayed up longer than MaxBackoff starts the backoff over.
+func TestServeWithBackoff_ResetsAfterConnection(t *testing.T) {
+	rc := ReconnectConfig{
+		InitialBackoff:   10 * time.Millisecond,
+		MaxBackoff:       40 * time.Millisecond,
+		BackoffJitter:    0.1,
+		CircuitBreakTime: time.Minute,
+		MaxAttempts:      100,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Three quick failures, one long-lived connection, then one more
+	// failure before stopping.
+	var starts, ends []time.Time
+	serve := func() error {
+		starts = append(starts, time.Now())
+		defer func() { ends = append(ends, time.Now()) }()
+		switch len(starts) {
+		case 4:
+			time.Sleep(3 * rc.MaxBackoff)
+		case 6:
+			cancel()
+		}
+		return errors.New("connection lost")
+	}
+	ServeWithBackoff(ctx, rc, serve, nil)
+
+	if len(starts) != 6 {
+		t.Fatalf("expected 6 attempts, got %d", len(starts))
+	}
+	gap := func(i int) time.Duration { return starts[i].Sub(ends[i-1]) }
+
+	if gap(3) < gap(1)*3/2 {
+		t.Fatalf("expected backoff to grow before the connection: %v then %v", gap(1), gap(3))
+	}
+	if gap(4) > gap(3)*3/4 {
+		t.Fatalf("expected backoff to reset after the connection: %v after %v", gap(4), gap(3))
+	}
+}
+
+// TestServeWithBackoff_CancelDuringBackoff verifies that cancelling the
+// context ends the loop without waiting out the current delay.
+func TestServeWithBackoff_CancelDuringBackoff(t *testing.T) {
+	rc := ReconnectConfig{
+		InitialBackoff:   time.Hour,
+		MaxBackoff:       time.Hour,
+		CircuitBreakTime: time.Hour,
+		MaxAttempts:      10,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ServeWithBackoff(ctx, rc, func() error { return errors.New("refused") }, nil)
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ServeWithBackoff did not return after cancellation")
+	}
+}
+
+// TestWaitForSession_UnreachableAgent verifies that waiting for an agent
+// that never connects uses up the retry budget and reports it.
+func TestWaitForSession_UnreachableAgent(t *testing.T) {
+	sm := NewSessionManager()
+
+	start := time.Now()
+	session, attempts, err := sm.WaitForSession(context.Background(), "offline-agent", 3, 20*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if session != nil {
+		t.Fatal("expected no session for an unreachable agent")
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "offline-agent") {
+		t.Fatalf("expected error to name the agent, got %q", err.Error())
+	}
+	if elapsed < 40*time.Millisecond {
+		t.Fatalf("expected to wait between attempts, returned after %v", elapsed)
+	}
+}
+
+// TestWaitForSession_AgentReconnects verifies that an agent connecting
+// within the retry budget is picked up.
+func TestWaitForSession_AgentReconnects(t *testing.T) {
+	sm := NewSessionManager()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		if _, err := sm.GetOrCreateSession("late-agent", "v0", serverConn); err != nil {
+			t.Errorf("failed to create session: %v", err)
+		}
+	}()
+
+	session, attempts, err := sm.WaitForSession(context.Background(), "late-agent", 10, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected session, got %v", err)
+	}
+	defer sm.CloseSession("late-agent")
+	if session == nil {
+		t.Fatal("expected non-nil session")
+	}
+	if attempts < 2 {
+		t.Fatalf("expected the session to show up after a retry, got %d attempts", attempts)
+	}
+}
+
+// TestSessionManager_TwoAgents verifies that sessions are looked up by
+// the agent they were registered for and leave the registry when their
+// agent disconnects.
+func TestSessionManager_TwoAgents(t *testing.T) {
+	sm := NewSessionManager()
+
+	connect := func(hostname string) *Session {
+		serverConn, clientConn := net.Pipe()
+		t.Cleanup(func() { clientConn.Close() })
+		session, err := sm.RegisterSession(hostname, "v1", serverConn)
+		if err != nil {
+			t.Fatalf("failed to register %s: %v", hostname, err)
+		}
+		return session
+	}
+
+	alpha := connect("alpha")
+	beta := connect("beta")
+
+	if got, ok := sm.GetSession("alpha"); !ok || got != alpha {
+		t.Fatal("expected alpha to be registered with its own session")
+	}
+	if got, ok := sm.GetSession("beta"); !ok || got != beta {
+		t.Fatal("expected beta to be registered with its own session")
+	}
+
+	if !sm.UnregisterSession("alpha", alpha) {
+		t.Fatal("expected alpha to be unregistered")
+	}
+	if _, ok := sm.GetSession("alpha"); ok {
+		t.Fatal("expected alpha to be gone after disconnecting")
+	}
+	if got, ok := sm.GetSession("beta"); !ok || got != beta {
+		t.Fatal("expected beta to stay registered")
+	}
+	if status := sm.Status("alpha"); status.Connected || status.LastSeen.IsZero() {
+		t.Fatalf("expected alpha to be reported disconnected with a last-seen time, got %+v", status)
+	}
+
+	if !sm.UnregisterSession("beta", beta) {
+		t.Fatal("expected beta to be unregistered")
+	}
+	if _, ok := sm.GetSession("beta"); ok {
+		t.Fatal("expected beta to be gone after disconnecting")
+	}
+}
+
+// TestSessionManager_Reconnect verifies that an agent reconnecting before
+// its previous connection was torn down gets a fresh session, and that the
+// teardown of the previous one leaves the fresh one registered.
+func TestSessionManager_Reconnect(t *testing.T) {
+	sm := NewSessionManager()
+
+	staleServer, staleClient := net.Pipe()
+	defer staleClient.Close()
+	stale, err := sm.RegisterSession("agent", "v1", staleServer)
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+
+	freshServer, freshClient := net.Pipe()
+	defer freshClient.Close()
+	fresh, err := sm.RegisterSession("agent", "v2", freshServer)
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	defer sm.CloseSession("agent")
+
+	if fresh == stale {
+		t.Fatal("expected the reconnect to get its own session")
+	}
+	if sm.UnregisterSession("agent", stale) {
+		t.Fatal("expected the stale session not to unregister its replacement")
+	}
+	if got, ok := sm.GetSession("agent"); !ok || got != fresh {
+		t.Fatal("expected the fresh session to stay registered")
+	}
+	if version := sm.Status("agent").Version; version != "v2" {
+		t.Fatalf("expected the version of the fresh session, got %q", version)
+	}
+}
+
+// TestSessionManager_CloseClientSessions verifies that closing the sessions
+// of an agent takes its per-job sessions along, and only those.
+func TestSessionManager_CloseClientSessions(t *testing.T) {
+	sm := NewSessionManager()
+
+	for _, id := range []string{"agent", "agent|job-1", "agent|job-2", "agent2", "other|job-1"} {
+		serverConn, clientConn := net.Pipe()
+		t.Cleanup(func() { clientConn.Close() })
+		if _, err := sm.RegisterSession(id, "v1", serverConn); err != nil {
+			t.Fatalf("failed to register %s: %v", id, err)
+		}
+	}
+	t.Cleanup(func() {
+		sm.CloseSession("agent2")
+		sm.CloseSession("other|job-1")
+	})
+
+	if closed := sm.CloseClientSessions("agent"); closed != 3 {
+		t.Fatalf("expected 3 sessions closed, got %d", closed)
+	}
+	for _, id := range []string{"agent", "agent|job-1", "agent|job-2"} {
+		if _, ok := sm.GetSession(id); ok {
+			t.Fatalf("expected %s to be closed", id)
+		}
+	}
+	for _, id := range []string{"agent2", "other|job-1"} {
+		if _, ok := sm.GetSession(id); !ok {
+			t.Fatalf("expected %s to stay registered", id)
+		}
+	}
+}
+
+// blackholeConn swallows everything written once frozen, like a NAT that
+// silently dropped the connection.
+type blackholeConn struct {
+	net.Conn
+	frozen atomic.Bool
+}
+
+func (c *blackholeConn) Write(b []byte) (int, error) {
+	if c.frozen.Load() {
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
+
+// TestServe_KeepaliveDeadPeer verifies that Serve notices a server that
+// stopped answering keepalives and tears the session down.
+func TestServe_KeepaliveDeadPeer(t *testing.T) {
+	clientConn, rawServerConn := net.Pipe()
+	serverConn := &blackholeConn{Conn: rawServerConn}
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	serverSession, err := NewServerSession(serverConn, nil)
+	if err != nil {
+		t.Fatalf("failed to create server session: %v", err)
+	}
+	serverSession.SetRouter(NewRouter())
+	go serverSession.Serve()
+
+	clientSession, err := NewClientSession(clientConn, nil)
+	if err != nil {
+		t.Fatalf("failed to create client session: %v", err)
+	}
+	defer clientSession.Close()
+
+	const (
+		interval  = 20 * time.Millisecond
+		timeout   = 20 * time.Millisecond
+		maxMissed = 3
+	)
+	clientSession.reconnectConfig = ReconnectConfig{
+		KeepaliveInterval:  interval,
+		KeepaliveTimeout:   timeout,
+		KeepaliveMaxMissed: maxMissed,
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- clientSession.Serve() }()
+
+	// Answered pings keep the session up.
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned while the server was answering: %v", err)
+	case <-time.After(10 * interval):
+	}
+
+	serverConn.frozen.Store(true)
+	frozenAt := time.Now()
+
+	window := maxMissed*(interval+timeout) + 500*time.Millisecond
+	select {
+	case <-served:
+	case <-time.After(window):
+		t.Fatalf("expected Serve to return within %v of the server going silent", window)
+	}
+	if elapsed := time.Since(frozenAt); elapsed < maxMissed*interval {
+		t.Fatalf("expected %d pings to be missed first, returned after %v", maxMissed, elapsed)
+	}
+}
+
 func setupSessionWithRouterForBenchmark(b *testing.B, router Router) (clientSession *Session, cleanup func()) {
 	b.Helper()
 
@@ -717,3 +1365,220 @@ func BenchmarkSessionCall(b *testing.B) {
 	}
 	b.StopTimer() // Stop the timer after the benchmark is complete.
 }
+
+func TestSessionShutdown_DrainsInFlightCalls(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	serverSession, err := NewServerSession(serverConn, nil)
+	if err != nil {
+		t.Fatalf("failed to create server session: %v", err)
+	}
+	clientSession, err := NewClientSession(clientConn, nil)
+	if err != nil {
+		t.Fatalf("failed to create client session: %v", err)
+	}
+	defer clientSession.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var completed atomic.Bool
+	router := NewRouter()
+	router.Handle("slow", func(req Request) (Response, error) {
+		close(started)
+		<-release
+		completed.Store(true)
+		return Response{Status: 200, Data: []byte("done")}, nil
+	})
+	router.Handle("fast", func(req Request) (Response, error) {
+		return Response{Status: 200}, nil
+	})
+	serverSession.SetRouter(router)
+
+	served := make(chan error, 1)
+	go func() { served <- serverSession.Serve() }()
+
+	slowResult := make(chan error, 1)
+	go func() {
+		data, err := clientSession.CallMsgWithTimeout(5*time.Second, "slow", nil)
+		if err == nil && string(data) != "done" {
+			err = fmt.Errorf("unexpected data %q", data)
+		}
+		slowResult <- err
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- serverSession.Shutdown(ctx)
+	}()
+	for !serverSession.draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// New calls are turned away while the slow one is still running.
+	resp, err := clientSession.CallWithTimeout(time.Second, "fast", nil)
+	if err != nil {
+		t.Fatalf("expected an error response during drain, got %v", err)
+	}
+	if resp.Status != 503 || !strings.Contains(resp.Message, ErrShuttingDown.Error()) {
+		t.Fatalf("expected status 503 with %q, got %d %q", ErrShuttingDown, resp.Status, resp.Message)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned before the in-flight handler finished: %v", err)
+	default:
+	}
+
+	close(release)
+	if err := <-slowResult; err != nil {
+		t.Fatalf("in-flight call failed during drain: %v", err)
+	}
+	if !completed.Load() {
+		t.Fatal("expected the in-flight handler to complete")
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Fatalf("expected Serve to return ErrShuttingDown, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Serve to return after Shutdown")
+	}
+}
+
+func TestSession_MaxConcurrentStreams(t *testing.T) {
+	const maxStreams = 3
+
+	started := make(chan struct{}, maxStreams)
+	release := make(chan struct{})
+	router := NewRouter()
+	router.Handle("slow", func(req Request) (Response, error) {
+		started <- struct{}{}
+		<-release
+		return Response{Status: 200, Data: []byte("done")}, nil
+	})
+	router.Handle("fast", func(req Request) (Response, error) {
+		return Response{Status: 200, Data: []byte("fast")}, nil
+	})
+
+	clientConn, serverConn := net.Pipe()
+	serverSession, err := NewServerSession(serverConn, nil)
+	if err != nil {
+		t.Fatalf("failed to create server session: %v", err)
+	}
+	defer serverSession.Close()
+	clientSession, err := NewClientSession(clientConn, nil)
+	if err != nil {
+		t.Fatalf("failed to create client session: %v", err)
+	}
+	defer clientSession.Close()
+	serverSession.SetRouter(router)
+	serverSession.SetMaxConcurrentStreams(maxStreams)
+	go func() { _ = serverSession.Serve() }()
+
+	results := make(chan error, maxStreams)
+	for i := 0; i < maxStreams; i++ {
+		go func() {
+			data, err := clientSession.CallMsgWithTimeout(5*time.Second, "slow", nil)
+			if err == nil && string(data) != "done" {
+				err = fmt.Errorf("unexpected data %q", data)
+			}
+			results <- err
+		}()
+	}
+	for i := 0; i < maxStreams; i++ {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("slow calls did not start")
+		}
+	}
+	if n := serverSession.ActiveStreams(); n != maxStreams {
+		t.Fatalf("expected %d active streams, got %d", maxStreams, n)
+	}
+
+	// The session is full: another call is turned away.
+	resp, err := clientSession.CallWithTimeout(time.Second, "fast", nil)
+	if err != nil {
+		t.Fatalf("expected an error response at the cap, got %v", err)
+	}
+	if resp.Status != 429 || !strings.Contains(resp.Message, ErrTooManyStreams.Error()) {
+		t.Fatalf("expected status 429 with %q, got %d %q", ErrTooManyStreams, resp.Status, resp.Message)
+	}
+
+	// A request larger than a single read is drained before the reply.
+	large := StringMsg(strings.Repeat("x", 4<<20))
+	resp, err = clientSession.CallWithTimeout(time.Second, "fast", &large)
+	if err != nil {
+		t.Fatalf("expected an error response at the cap for a large request, got %v", err)
+	}
+	if resp.Status != 429 || !strings.Contains(resp.Message, ErrTooManyStreams.Error()) {
+		t.Fatalf("expected status 429 with %q for a large request, got %d %q", ErrTooManyStreams, resp.Status, resp.Message)
+	}
+
+	// The streams already served are not affected.
+	close(release)
+	for i := 0; i < maxStreams; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("call below the cap failed: %v", err)
+		}
+	}
+
+	// A stream is released once its response is written.
+	deadline := time.Now().Add(time.Second)
+	for serverSession.ActiveStreams() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	data, err := clientSession.CallMsgWithTimeout(time.Second, "fast", nil)
+	if err != nil || string(data) != "fast" {
+		t.Fatalf("expected calls to succeed once streams are released, got %q, %v", data, err)
+	}
+}
+
+func TestSessionShutdown_ContextExpires(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	serverSession, err := NewServerSession(serverConn, nil)
+	if err != nil {
+		t.Fatalf("failed to create server session: %v", err)
+	}
+	clientSession, err := NewClientSession(clientConn, nil)
+	if err != nil {
+		t.Fatalf("failed to create client session: %v", err)
+	}
+	defer clientSession.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	router := NewRouter()
+	router.Handle("stuck", func(req Request) (Response, error) {
+		close(started)
+		<-release
+		return Response{Status: 200}, nil
+	})
+	serverSession.SetRouter(router)
+	go serverSession.Serve()
+
+	go clientSession.CallWithTimeout(5*time.Second, "stuck", nil)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := serverSession.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if !serverSession.muxSess.Load().IsClosed() {
+		t.Fatal("expected the session to be closed once the drain timed out")
+	}
+}
@@ -59,6 +59,12 @@ func (d *Decoder) Reset(buf []byte) error {
 	return nil
 }
 
+// Remaining returns the number of bytes left to read. Decoders of messages
+// that gained trailing fields use it to accept the older, shorter form.
+func (d *Decoder) Remaining() int {
+	return len(d.buf) - d.pos
+}
+
 func (d *Decoder) ReadByte() (byte, error) {
 	if len(d.buf)-d.pos < 1 {
 		return 0, errors.New("buffer too small to read byte")
@@ -153,12 +159,22 @@ func (d *Decoder) ReadBool() (bool, error) {
 	return value, nil
 }
 
+// ReadTime reads a time written by WriteTime. The result is always in UTC
+// so that it does not pick up the local timezone of the reader.
+//
+// WriteTime encodes the zero time.Time as 0, so 0 is read back as the zero
+// time.Time rather than the Unix epoch; IsZero holds on both ends. A time
+// at exactly the epoch therefore does not survive the round trip.
 func (d *Decoder) ReadTime() (time.Time, error) {
 	nano, err := d.ReadInt64()
 	if err != nil {
 		return time.Time{}, err
 	}
-	return time.Unix(0, nano), nil
+	if nano == 0 {
+		// The zero time, see WriteTime.
+		return time.Time{}, nil
+	}
+	return time.Unix(0, nano).UTC(), nil
 }
 
 func (d *Decoder) ReadInt32Array() ([]int32, error) {
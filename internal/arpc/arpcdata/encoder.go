@@ -136,9 +136,14 @@ func (e *Encoder) WriteBool(value bool) error {
 	return nil
 }
 
-// WriteTime writes a time.Time by encoding its UnixNano (int64).
+// WriteTime writes a time.Time as UTC Unix nanoseconds (int64). The
+// location of value is not encoded, so the result does not depend on the
+// local timezone or DST rules of the writer. The zero time is written as 0.
 func (e *Encoder) WriteTime(value time.Time) error {
-	return e.WriteInt64(value.UnixNano())
+	if value.IsZero() {
+		return e.WriteInt64(0)
+	}
+	return e.WriteInt64(value.UTC().UnixNano())
 }
 
 // WriteInt32Array writes a length-prefixed array of int32 values.
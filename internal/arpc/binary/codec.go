@@ -0,0 +1,164 @@
+package binarystream
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec identifies the compression applied to the chunks of a binary
+// payload. Only zstd is offered; new codecs, such as LZ4, take the next
+// values and are negotiated through Codecs, so peers that lack them keep
+// working.
+type Codec uint8
+
+const (
+	CodecNone Codec = iota
+	CodecZstd
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("codec(%d)", uint8(c))
+	}
+}
+
+// Codecs is a set of codecs, advertised by a receiver to tell the sender
+// what it can decode.
+type Codecs uint8
+
+// SupportedCodecs holds every codec this build can encode and decode.
+const SupportedCodecs = Codecs(1 << CodecZstd)
+
+// Has reports whether codec is in the set. CodecNone always is.
+func (c Codecs) Has(codec Codec) bool {
+	return codec == CodecNone || c&(1<<codec) != 0
+}
+
+// CompressThreshold is the payload size below which compressing is not
+// worth the extra CPU time.
+const CompressThreshold = 16 << 10
+
+// compressibleEntropy is the byte entropy, in bits per byte, below which a
+// payload is considered compressible. Compressed or encrypted data sits just
+// under 8.
+const compressibleEntropy = 7.5
+
+// entropySample bounds the bytes looked at to estimate the entropy.
+const entropySample = 64 << 10
+
+// ChooseCodec returns the codec to send data with: the preferred codec in
+// accepted when data is at least CompressThreshold bytes and looks
+// compressible, CodecNone otherwise.
+func ChooseCodec(data []byte, accepted Codecs) Codec {
+	if len(data) < CompressThreshold {
+		return CodecNone
+	}
+
+	if !accepted.Has(CodecZstd) {
+		return CodecNone
+	}
+
+	if entropy(data[:min(len(data), entropySample)]) >= compressibleEntropy {
+		return CodecNone
+	}
+	return CodecZstd
+}
+
+// entropy returns the Shannon entropy of the bytes of data in bits per
+// byte.
+func entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	total := float64(len(data))
+	var bits float64
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / total
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// Metadata returns the Data of a binary response whose payload is sent with
+// c. It is empty for CodecNone, which is what receivers predating
+// compression expect.
+func (c Codec) Metadata() []byte {
+	if c == CodecNone {
+		return nil
+	}
+	return []byte{byte(c)}
+}
+
+// CodecFromMetadata returns the codec announced by the Data of a binary
+// response.
+func CodecFromMetadata(data []byte) (Codec, error) {
+	if len(data) == 0 {
+		return CodecNone, nil
+	}
+
+	codec := Codec(data[0])
+	if !SupportedCodecs.Has(codec) {
+		return CodecNone, fmt.Errorf("unsupported codec %s", codec)
+	}
+	return codec, nil
+}
+
+// The zstd encoder and decoder are safe for concurrent EncodeAll and
+// DecodeAll calls. Chunks never exceed the largest buffer pool, so the
+// decoder memory limit only guards against corrupt frames.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil,
+		zstd.WithEncoderLevel(zstd.SpeedFastest),
+		zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil,
+		zstd.WithDecoderConcurrency(0),
+		zstd.WithDecoderMaxMemory(1<<20))
+)
+
+// compressChunk appends chunk compressed with codec to dst.
+func compressChunk(codec Codec, dst, chunk []byte) ([]byte, error) {
+	switch codec {
+	case CodecZstd:
+		return zstdEncoder.EncodeAll(chunk, dst), nil
+	default:
+		return nil, fmt.Errorf("unsupported codec %s", codec)
+	}
+}
+
+// decompressChunk decompresses a chunk compressed with codec into dst and
+// returns the number of bytes written. A chunk that does not fit in dst is
+// an error.
+func decompressChunk(codec Codec, dst, chunk []byte) (int, error) {
+	switch codec {
+	case CodecZstd:
+		out, err := zstdDecoder.DecodeAll(chunk, dst[:0])
+		if err != nil {
+			return 0, err
+		}
+		if len(out) > len(dst) {
+			return 0, fmt.Errorf("buffer overflow: need %d bytes, have %d", len(out), len(dst))
+		}
+		if len(out) > 0 && &out[0] != &dst[0] {
+			copy(dst, out)
+		}
+		return len(out), nil
+	default:
+		return 0, fmt.Errorf("unsupported codec %s", codec)
+	}
+}
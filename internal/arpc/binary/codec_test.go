@@ -0,0 +1,132 @@
+package binarystream
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xtaci/smux"
+)
+
+// streamPair returns both ends of a smux stream over an in-memory pipe.
+func streamPair(t *testing.T) (*smux.Stream, *smux.Stream) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	client, err := smux.Client(clientConn, nil)
+	require.NoError(t, err)
+	server, err := smux.Server(serverConn, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	sending, err := client.OpenStream()
+	require.NoError(t, err)
+	receiving, err := server.AcceptStream()
+	require.NoError(t, err)
+	return sending, receiving
+}
+
+func compressiblePayload(size int) []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < size; i++ {
+		fmt.Fprintf(&buf, "%08d C:\\Users\\backup\\Documents\\report-%d.docx modified\n", i, i%97)
+	}
+	return buf.Bytes()[:size]
+}
+
+func randomPayload(t *testing.T, size int) []byte {
+	payload := make([]byte, size)
+	_, err := rand.Read(payload)
+	require.NoError(t, err)
+	return payload
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	payloads := map[string][]byte{
+		"compressible":   compressiblePayload(100_000),
+		"incompressible": randomPayload(t, 100_000),
+		"small":          compressiblePayload(100),
+		"repetitive":     bytes.Repeat([]byte{0}, 70_000),
+	}
+
+	for _, codec := range []Codec{CodecNone, CodecZstd} {
+		for name, payload := range payloads {
+			t.Run(codec.String()+"/"+name, func(t *testing.T) {
+				sending, receiving := streamPair(t)
+
+				errCh := make(chan error, 1)
+				go func() {
+					errCh <- SendDataFromReaderWithCodec(bytes.NewReader(payload), len(payload), sending, codec)
+				}()
+
+				buffer := make([]byte, len(payload))
+				n, err := ReceiveDataWithCodec(receiving, buffer, codec)
+				require.NoError(t, err)
+				require.NoError(t, <-errCh)
+				assert.Equal(t, len(payload), n)
+				assert.True(t, bytes.Equal(payload, buffer[:n]), "payload differs after round trip")
+			})
+		}
+	}
+}
+
+func TestCodecBlockRoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		{},
+		[]byte("short"),
+		[]byte("exactly twelve"),
+		compressiblePayload(32768),
+		randomPayload(t, 32768),
+		bytes.Repeat([]byte("ab"), 16384),
+	}
+
+	for _, codec := range []Codec{CodecZstd} {
+		for _, input := range inputs {
+			compressed, err := compressChunk(codec, nil, input)
+			require.NoError(t, err)
+
+			out := make([]byte, len(input))
+			n, err := decompressChunk(codec, out, compressed)
+			require.NoError(t, err, "%s of %d bytes", codec, len(input))
+			assert.Equal(t, input, out[:n], "%s of %d bytes", codec, len(input))
+		}
+	}
+
+	// Compressible data has to actually shrink.
+	input := compressiblePayload(32768)
+	compressed, err := compressChunk(CodecZstd, nil, input)
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(input)/2)
+
+	// A chunk that does not fit is refused rather than truncated.
+	_, err = decompressChunk(CodecZstd, make([]byte, 100), compressed)
+	assert.Error(t, err)
+}
+
+func TestChooseCodec(t *testing.T) {
+	compressible := compressiblePayload(CompressThreshold)
+
+	assert.Equal(t, CodecZstd, ChooseCodec(compressible, SupportedCodecs))
+	assert.Equal(t, CodecNone, ChooseCodec(compressible, 0), "receiver without codecs")
+	assert.Equal(t, CodecNone, ChooseCodec(compressible[:CompressThreshold-1], SupportedCodecs), "below threshold")
+	assert.Equal(t, CodecNone, ChooseCodec(randomPayload(t, CompressThreshold), SupportedCodecs), "incompressible")
+}
+
+func TestCodecMetadata(t *testing.T) {
+	for _, codec := range []Codec{CodecNone, CodecZstd} {
+		got, err := CodecFromMetadata(codec.Metadata())
+		require.NoError(t, err)
+		assert.Equal(t, codec, got)
+	}
+	assert.Nil(t, CodecNone.Metadata())
+
+	_, err := CodecFromMetadata([]byte{42})
+	assert.Error(t, err)
+}
@@ -57,11 +57,23 @@ func selectBufferPool(totalLength int) (pool *sync.Pool, poolSize int) {
 	return last.Pool, last.Size
 }
 
+// compressedChunkLimit bounds a compressed chunk accepted by
+// ReceiveDataWithCodec. Incompressible chunks grow slightly when compressed.
+const compressedChunkLimit = 2 * 32768
+
 // SendDataFromReader reads up to 'length' bytes from the provided io.Reader
 // in chunks. For each chunk it writes a 4-byte little-endian prefix (the actual
 // size of that chunk) to the smux stream, followed immediately by the chunk data.
 // After sending all chunks it writes a sentinel (0) and then the final total.
 func SendDataFromReader(r io.Reader, length int, stream *smux.Stream) error {
+	return SendDataFromReaderWithCodec(r, length, stream, CodecNone)
+}
+
+// SendDataFromReaderWithCodec works like SendDataFromReader but compresses
+// each chunk with codec; the size prefix is then that of the compressed
+// chunk. The final total remains the number of uncompressed bytes. The
+// receiver has to be told the codec, see Codec.Metadata.
+func SendDataFromReaderWithCodec(r io.Reader, length int, stream *smux.Stream, codec Codec) error {
 	if stream == nil {
 		return fmt.Errorf("stream is nil")
 	}
@@ -84,6 +96,7 @@ func SendDataFromReader(r io.Reader, length int, stream *smux.Stream) error {
 	chunkBuf = chunkBuf[:poolSize]
 	defer pool.Put(chunkBuf)
 
+	var compressed []byte
 	totalRead := 0
 
 	for totalRead < length {
@@ -101,13 +114,22 @@ func SendDataFromReader(r io.Reader, length int, stream *smux.Stream) error {
 			break
 		}
 
+		chunk := chunkBuf[:n]
+		if codec != CodecNone {
+			compressed, err = compressChunk(codec, compressed[:0], chunk)
+			if err != nil {
+				return fmt.Errorf("failed to compress chunk: %w", err)
+			}
+			chunk = compressed
+		}
+
 		// Write the chunk's size prefix (32-bit little-endian).
-		if err := binary.Write(stream, binary.LittleEndian, uint32(n)); err != nil {
+		if err := binary.Write(stream, binary.LittleEndian, uint32(len(chunk))); err != nil {
 			return fmt.Errorf("failed to write chunk size prefix: %w", err)
 		}
 
 		// Write the actual chunk data.
-		if _, err := stream.Write(chunkBuf[:n]); err != nil {
+		if _, err := stream.Write(chunk); err != nil {
 			return fmt.Errorf("failed to write chunk data: %w", err)
 		}
 
@@ -127,11 +149,22 @@ func SendDataFromReader(r io.Reader, length int, stream *smux.Stream) error {
 	return nil
 }
 
-// ReceiveData reads data from the smux stream into the provided buffer.
+// ReceiveData reads data from the stream into the provided buffer.
 // It expects each chunk to be preceded by its 4-byte size. A chunk size of 0
 // signals that data transfer has finished; it is then followed by a final total
 // which is compared to the accumulated data.
-func ReceiveData(stream *smux.Stream, buffer []byte) (int, error) {
+func ReceiveData(stream io.Reader, buffer []byte) (int, error) {
+	return ReceiveDataWithCodec(stream, buffer, CodecNone)
+}
+
+// ReceiveDataWithCodec reads data sent by SendDataFromReaderWithCodec with
+// codec into the provided buffer.
+func ReceiveDataWithCodec(stream io.Reader, buffer []byte, codec Codec) (int, error) {
+	if codec != CodecNone && !SupportedCodecs.Has(codec) {
+		return 0, fmt.Errorf("unsupported codec %s", codec)
+	}
+
+	var compressed []byte
 	totalRead := 0
 
 	for {
@@ -153,6 +186,25 @@ func ReceiveData(stream *smux.Stream, buffer []byte) (int, error) {
 			break
 		}
 
+		if codec != CodecNone {
+			if chunkSize > uint32(compressedChunkLimit) {
+				return totalRead, fmt.Errorf("compressed chunk of %d bytes exceeds limit of %d", chunkSize, compressedChunkLimit)
+			}
+			if cap(compressed) < int(chunkSize) {
+				compressed = make([]byte, chunkSize)
+			}
+			compressed = compressed[:chunkSize]
+			if _, err := io.ReadFull(stream, compressed); err != nil {
+				return totalRead, fmt.Errorf("failed to read chunk data: %w", err)
+			}
+			n, err := decompressChunk(codec, buffer[totalRead:], compressed)
+			if err != nil {
+				return totalRead, fmt.Errorf("failed to decompress chunk: %w", err)
+			}
+			totalRead += n
+			continue
+		}
+
 		// Ensure the provided buffer is large enough.
 		if totalRead+int(chunkSize) > len(buffer) {
 			return totalRead, fmt.Errorf("buffer overflow: need %d bytes, have %d",
@@ -0,0 +1,68 @@
+package binarystream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/xtaci/smux"
+)
+
+// MaxFrameSize bounds a single frame accepted by ReceiveFrames.
+const MaxFrameSize = 64 << 20
+
+// SendFrame writes data to the stream as one frame, preceded by its 4-byte
+// size. Empty data is skipped since a size of 0 ends the sequence.
+func SendFrame(stream *smux.Stream, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds limit of %d", len(data), MaxFrameSize)
+	}
+
+	if err := binary.Write(stream, binary.LittleEndian, uint32(len(data))); err != nil {
+		return fmt.Errorf("failed to write frame size: %w", err)
+	}
+	if _, err := stream.Write(data); err != nil {
+		return fmt.Errorf("failed to write frame data: %w", err)
+	}
+	return nil
+}
+
+// SendEndOfFrames writes the zero size that ends a sequence of frames.
+func SendEndOfFrames(stream *smux.Stream) error {
+	return binary.Write(stream, binary.LittleEndian, uint32(0))
+}
+
+// ReceiveFrames reads frames written with SendFrame and hands each to fn
+// until the sequence ends. The slice passed to fn is reused for the next
+// frame. A stream that closes before the end of the sequence is an error.
+func ReceiveFrames(stream *smux.Stream, fn func([]byte) error) error {
+	var buf []byte
+
+	for {
+		var frameSize uint32
+		if err := binary.Read(stream, binary.LittleEndian, &frameSize); err != nil {
+			return fmt.Errorf("failed to read frame size: %w", err)
+		}
+		if frameSize == 0 {
+			return nil
+		}
+		if frameSize > MaxFrameSize {
+			return fmt.Errorf("frame of %d bytes exceeds limit of %d", frameSize, MaxFrameSize)
+		}
+
+		if cap(buf) < int(frameSize) {
+			buf = make([]byte, frameSize)
+		}
+		buf = buf[:frameSize]
+		if _, err := io.ReadFull(stream, buf); err != nil {
+			return fmt.Errorf("failed to read frame data: %w", err)
+		}
+
+		if err := fn(buf); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package binarystream
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitedReaderRate(t *testing.T) {
+	const rate = 256 * 1024
+	// The bucket starts full, so the first second's worth is free and the
+	// remaining two seconds' worth take two seconds.
+	payload := make([]byte, 3*rate)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+
+	limiter := NewLimiter(rate)
+	require.NotNil(t, limiter)
+	assert.Equal(t, int64(rate), limiter.Rate())
+
+	reader := NewLimitedReader(context.Background(), bytes.NewReader(payload), limiter)
+
+	start := time.Now()
+	received, err := io.ReadAll(reader)
+	elapsed := time.Since(start)
+
+	require.NoError(t, err)
+	assert.Equal(t, payload, received)
+	assert.GreaterOrEqual(t, elapsed, 1800*time.Millisecond)
+	assert.Less(t, elapsed, 3*time.Second)
+}
+
+func TestLimitedReaderUnlimited(t *testing.T) {
+	assert.Nil(t, NewLimiter(0))
+	assert.Nil(t, NewLimiter(-1))
+	assert.Equal(t, int64(0), (*Limiter)(nil).Rate())
+
+	src := bytes.NewReader([]byte("payload"))
+	assert.Same(t, src, NewLimitedReader(context.Background(), src, nil))
+}
+
+func TestLimitedReaderCancelled(t *testing.T) {
+	limiter := NewLimiter(1024)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	reader := NewLimitedReader(ctx, bytes.NewReader(make([]byte, 64*1024)), limiter)
+
+	start := time.Now()
+	_, err := io.ReadAll(reader)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -76,10 +76,17 @@ func (s *Session) CallContext(ctx context.Context, method string, payload arpcda
 		return Response{}, fmt.Errorf("failed to write request: %w", err)
 	}
 
+	// Tell the server to abort the handler if the caller gives up.
+	stopWatch := watchCancel(ctx, stream)
+	defer stopWatch()
+
 	prefix := headerPool.Get().([]byte)
 	defer headerPool.Put(prefix)
 
 	if _, err := io.ReadFull(stream, prefix); err != nil {
+		if ctx.Err() != nil {
+			return Response{}, ctx.Err()
+		}
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			return Response{}, context.DeadlineExceeded
 		}
@@ -97,6 +104,9 @@ func (s *Session) CallContext(ctx context.Context, method string, payload arpcda
 	copy(buf, prefix)
 	// Read the remaining totalLength-4 bytes.
 	if _, err := io.ReadFull(stream, buf[4:]); err != nil {
+		if ctx.Err() != nil {
+			return Response{}, ctx.Err()
+		}
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			return Response{}, context.DeadlineExceeded
 		}
@@ -186,11 +196,18 @@ func (s *Session) CallBinary(ctx context.Context, method string, payload arpcdat
 		return 0, fmt.Errorf("failed to write request: %w", err)
 	}
 
+	// Tell the server to abort the handler if the caller gives up.
+	stopWatch := watchCancel(ctx, stream)
+	defer stopWatch()
+
 	// Read the response
 	headerPrefix := headerPool.Get().([]byte)
 	defer headerPool.Put(headerPrefix)
 
 	if _, err := io.ReadFull(stream, headerPrefix); err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
 		return 0, fmt.Errorf("failed to read header length prefix: %w", err)
 	}
 	headerTotalLength := binary.LittleEndian.Uint32(headerPrefix)
@@ -223,5 +240,97 @@ func (s *Session) CallBinary(ctx context.Context, method string, payload arpcdat
 		return 0, fmt.Errorf("RPC error: status %d", resp.Status)
 	}
 
-	return binarystream.ReceiveData(stream, buffer)
+	// The server announces a compressed payload in the response data.
+	codec, err := binarystream.CodecFromMetadata(resp.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	return binarystream.ReceiveDataWithCodec(binarystream.NewLimitedReader(ctx, stream, s.rateLimiter.Load()), buffer, codec)
+}
+
+// CallStream performs an RPC call whose server replies with a sequence of
+// frames (see FrameStream and binarystream.SendFrame) and hands each frame
+// to fn, in order, until the server ends the sequence. The slice passed to
+// fn is reused for the next frame. An error returned by fn aborts the call;
+// so does ctx, which also cancels the context of the handler.
+func (s *Session) CallStream(ctx context.Context, method string, payload arpcdata.Encodable, fn func([]byte) error) error {
+	curSession := s.muxSess.Load()
+	stream, err := openStreamWithReconnect(s, curSession)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	// Propagate context deadlines to the stream
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+
+	// Serialize the payload
+	var payloadBytes []byte
+	if payload != nil {
+		payloadBytes, err = payload.Encode()
+		if err != nil {
+			return fmt.Errorf("failed to encode payload: %w", err)
+		}
+	}
+
+	req := Request{
+		Method:  method,
+		Payload: payloadBytes,
+	}
+	reqBytes, err := req.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	if _, err := stream.Write(reqBytes); err != nil {
+		return fmt.Errorf("failed to write request: %w", err)
+	}
+
+	// Tell the server to abort the handler if the caller gives up.
+	stopWatch := watchCancel(ctx, stream)
+	defer stopWatch()
+
+	headerPrefix := headerPool.Get().([]byte)
+	defer headerPool.Put(headerPrefix)
+
+	if _, err := io.ReadFull(stream, headerPrefix); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to read header length prefix: %w", err)
+	}
+	headerTotalLength := binary.LittleEndian.Uint32(headerPrefix)
+	if headerTotalLength < 4 {
+		return fmt.Errorf("invalid header length %d", headerTotalLength)
+	}
+
+	headerBuf := make([]byte, headerTotalLength)
+	copy(headerBuf, headerPrefix)
+	if _, err := io.ReadFull(stream, headerBuf[4:]); err != nil {
+		return fmt.Errorf("failed to read full header: %w", err)
+	}
+
+	var resp Response
+	if err := resp.Decode(headerBuf); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if resp.Status != 213 {
+		var serErr SerializableError
+		if err := serErr.Decode(resp.Data); err == nil {
+			return UnwrapError(serErr)
+		}
+		return fmt.Errorf("RPC error: status %d", resp.Status)
+	}
+
+	if err := binarystream.ReceiveFrames(stream, fn); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
 }
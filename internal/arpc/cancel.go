@@ -0,0 +1,146 @@
+package arpc
+
+import (
+	"context"
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc/arpcdata"
+	"github.com/xtaci/smux"
+)
+
+// cancelMethod is the reserved method of the control frame a client sends on
+// the stream of a call it gave up on.
+const cancelMethod = "arpc/cancel"
+
+// Cancel asks the server to abort the call in flight on the stream
+// RequestID.
+type Cancel struct {
+	RequestID uint32
+}
+
+func (c *Cancel) Encode() ([]byte, error) {
+	enc := arpcdata.NewEncoderWithSize(4)
+	if err := enc.WriteUint32(c.RequestID); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), nil
+}
+
+func (c *Cancel) Decode(buf []byte) error {
+	dec, err := arpcdata.NewDecoder(buf)
+	if err != nil {
+		return err
+	}
+	requestID, err := dec.ReadUint32()
+	if err != nil {
+		return err
+	}
+	c.RequestID = requestID
+	arpcdata.ReleaseDecoder(dec)
+	return nil
+}
+
+// writeCancel sends a Cancel frame for the call on stream.
+func writeCancel(stream *smux.Stream) error {
+	cancel := Cancel{RequestID: stream.ID()}
+	payload, err := cancel.Encode()
+	if err != nil {
+		return err
+	}
+
+	req := Request{Method: cancelMethod, Payload: payload}
+	reqBytes, err := req.Encode()
+	if err != nil {
+		return err
+	}
+
+	// The deadline of the call may already have passed.
+	_ = stream.SetWriteDeadline(time.Now().Add(time.Second))
+	_, err = stream.Write(reqBytes)
+	return err
+}
+
+// watchCancel sends a Cancel frame on stream once ctx is done and unblocks
+// pending reads on it. The returned function stops watching.
+func watchCancel(ctx context.Context, stream *smux.Stream) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-ctx.Done():
+			_ = writeCancel(stream)
+			_ = stream.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// isCancelFrame reports whether frame is a Cancel for the call on stream.
+func isCancelFrame(frame []byte, stream *smux.Stream) bool {
+	var req Request
+	if err := req.Decode(frame); err != nil || req.Method != cancelMethod {
+		return false
+	}
+
+	var cancel Cancel
+	if err := cancel.Decode(req.Payload); err != nil {
+		return false
+	}
+	return cancel.RequestID == stream.ID()
+}
+
+// watchStream reads control frames from stream while its handler runs and
+// calls cancel when the client sends a Cancel or goes away. The returned
+// function stops watching and leaves the stream ready for the response.
+func watchStream(stream *smux.Stream, cancel context.CancelFunc) func() {
+	var stopped atomic.Bool
+	finished := make(chan struct{})
+	var buf []byte
+
+	go func() {
+		defer close(finished)
+
+		chunk := make([]byte, 512)
+		for {
+			for len(buf) >= 4 {
+				frameLen := int(binary.LittleEndian.Uint32(buf))
+				if frameLen < 4 || len(buf) < frameLen {
+					break
+				}
+				if isCancelFrame(buf[:frameLen], stream) {
+					cancel()
+					return
+				}
+				buf = buf[frameLen:]
+			}
+
+			n, err := stream.Read(chunk)
+			if err != nil {
+				if !stopped.Load() {
+					cancel()
+				}
+				return
+			}
+			buf = append(buf, chunk[:n]...)
+		}
+	}()
+
+	return func() {
+		stopped.Store(true)
+		_ = stream.SetReadDeadline(time.Now())
+		<-finished
+		_ = stream.SetReadDeadline(time.Time{})
+	}
+}
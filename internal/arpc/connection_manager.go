@@ -20,6 +20,21 @@ const (
 	StateFailed
 )
 
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
 // ReconnectConfig holds the parameters for automatic reconnection.
 type ReconnectConfig struct {
 	AutoReconnect    bool
@@ -30,6 +45,16 @@ type ReconnectConfig struct {
 	ReconnectCtx     context.Context
 	BackoffJitter    float64
 	CircuitBreakTime time.Duration
+	// MaxAttempts is the number of consecutive failures after which the
+	// circuit breaker opens and retries are held off for CircuitBreakTime.
+	MaxAttempts int
+	// KeepaliveInterval is the period of the pings Serve sends to detect a
+	// dead peer; zero disables them. Each ping waits up to KeepaliveTimeout
+	// for a reply, and the connection is closed after KeepaliveMaxMissed
+	// consecutive pings went unanswered.
+	KeepaliveInterval  time.Duration
+	KeepaliveTimeout   time.Duration
+	KeepaliveMaxMissed int
 }
 
 // dialResult is used by dialWithProbe to deliver dialing results.
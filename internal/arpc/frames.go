@@ -0,0 +1,29 @@
+package arpc
+
+import (
+	binarystream "github.com/sonroyaalmerol/pbs-plus/internal/arpc/binary"
+	"github.com/xtaci/smux"
+)
+
+// FrameStream answers a call made with CallStream. fn runs once the
+// response header is written and hands each message to send, which writes it
+// as one frame; the sequence ends when fn returns nil. When fn fails, the
+// sequence is left unfinished and the client gets an error.
+//
+// The stream can stay open as long as fn has messages to send. fn should
+// return once the context of the request is done, which happens when the
+// client cancels the call or goes away.
+func FrameStream(fn func(send func([]byte) error) error) Response {
+	return Response{
+		Status: 213,
+		RawStream: func(stream *smux.Stream) {
+			err := fn(func(data []byte) error {
+				return binarystream.SendFrame(stream, data)
+			})
+			if err != nil {
+				return
+			}
+			_ = binarystream.SendEndOfFrames(stream)
+		},
+	}
+}
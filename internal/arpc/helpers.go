@@ -32,7 +32,7 @@ func HijackUpgradeHTTP(w http.ResponseWriter, r *http.Request, hostname string,
 		return nil, err
 	}
 
-	return mgr.GetOrCreateSession(hostname, version, conn)
+	return mgr.RegisterSession(hostname, version, conn)
 }
 
 // upgradeHTTPClient helps a client upgrade an HTTP connection.
@@ -0,0 +1,131 @@
+package arpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/xtaci/smux"
+)
+
+// pingMethod is the reserved method of the keepalive frame a client sends to
+// check that the server still answers. Routers reply to it themselves.
+const pingMethod = "arpc/ping"
+
+// Defaults applied when KeepaliveInterval is set without the other
+// keepalive parameters.
+const (
+	defaultKeepaliveTimeout   = 10 * time.Second
+	defaultKeepaliveMaxMissed = 3
+)
+
+// writePong answers a keepalive frame on stream.
+func writePong(stream *smux.Stream) {
+	resp := Response{Status: http.StatusOK}
+	respBytes, err := resp.Encode()
+	if err != nil {
+		return
+	}
+	_, _ = stream.Write(respBytes)
+}
+
+// ping sends a keepalive frame over sess and waits up to timeout for the
+// reply. Any response counts, so that servers predating keepalives, which
+// answer with method not found, are not mistaken for dead peers.
+func ping(sess *smux.Session, timeout time.Duration) error {
+	stream, err := sess.OpenStream()
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	_ = stream.SetDeadline(time.Now().Add(timeout))
+
+	req := Request{Method: pingMethod}
+	reqBytes, err := req.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	if _, err := stream.Write(reqBytes); err != nil {
+		return fmt.Errorf("failed to write request: %w", err)
+	}
+
+	prefix := make([]byte, 4)
+	if _, err := io.ReadFull(stream, prefix); err != nil {
+		return fmt.Errorf("failed to read length prefix: %w", err)
+	}
+	totalLength := binary.LittleEndian.Uint32(prefix)
+	if totalLength < 4 {
+		return fmt.Errorf("invalid total length %d", totalLength)
+	}
+	buf := make([]byte, totalLength)
+	copy(buf, prefix)
+	if _, err := io.ReadFull(stream, buf[4:]); err != nil {
+		return fmt.Errorf("failed to read full response: %w", err)
+	}
+
+	var resp Response
+	if err := resp.Decode(buf); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// keepalive pings the server every rc.KeepaliveInterval until ctx is done.
+// After rc.KeepaliveMaxMissed consecutive pings without a reply the current
+// smux session is closed, which makes Serve return or reconnect.
+func (s *Session) keepalive(ctx context.Context, rc ReconnectConfig) {
+	timeout := rc.KeepaliveTimeout
+	if timeout <= 0 {
+		timeout = defaultKeepaliveTimeout
+	}
+	maxMissed := rc.KeepaliveMaxMissed
+	if maxMissed <= 0 {
+		maxMissed = defaultKeepaliveMaxMissed
+	}
+
+	ticker := time.NewTicker(rc.KeepaliveInterval)
+	defer ticker.Stop()
+
+	missed := 0
+	var pinged *smux.Session
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sess := s.muxSess.Load()
+		if sess == nil || sess.IsClosed() {
+			continue
+		}
+		// Misses only count against the session they were seen on.
+		if sess != pinged {
+			pinged, missed = sess, 0
+		}
+
+		err := ping(sess, timeout)
+		if err == nil {
+			missed = 0
+			continue
+		}
+
+		missed++
+		if missed < maxMissed {
+			continue
+		}
+
+		syslog.L.Warn().
+			WithMessage("arpc peer stopped answering keepalives, closing session").
+			WithField("missed", missed).
+			WithField("error", err.Error()).
+			Write()
+		_ = sess.Close()
+		missed = 0
+	}
+}
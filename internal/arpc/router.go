@@ -1,10 +1,14 @@
 package arpc
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils/safemap"
 	"github.com/xtaci/smux"
@@ -17,22 +21,86 @@ var bufferPool = sync.Pool{
 	},
 }
 
+// MaxRequestSize caps the length of a request. Requests carrying bulk data,
+// such as WriteAt, stay well below it.
+const MaxRequestSize = 64 << 20
+
+// readRequest reads the length-prefixed request at the start of r. buf is
+// used when the request fits in it; larger ones get a buffer of their own.
+// Nothing past the request is consumed, so a Cancel frame sent after it is
+// left for watchStream.
+func readRequest(r io.Reader, buf []byte) ([]byte, error) {
+	if _, err := io.ReadFull(r, buf[:4]); err != nil {
+		return nil, err
+	}
+	total := int(binary.LittleEndian.Uint32(buf))
+	if total < 4 || total > MaxRequestSize {
+		return nil, fmt.Errorf("invalid request length %d", total)
+	}
+
+	frame := buf
+	if total > len(buf) {
+		frame = make([]byte, total)
+		copy(frame, buf[:4])
+	}
+	frame = frame[:total]
+	if _, err := io.ReadFull(r, frame[4:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
 // HandlerFunc handles an RPC Request and returns a Response.
 type HandlerFunc func(req Request) (Response, error)
 
 // Router holds a map from method names to handler functions.
 type Router struct {
-	handlers *safemap.Map[string, HandlerFunc]
+	handlers *safemap.Map[string, route]
+}
+
+// route is a registered handler and its options.
+type route struct {
+	handler HandlerFunc
+	timeout time.Duration
+}
+
+// HandleOption configures a handler registered with Handle.
+type HandleOption func(*route)
+
+// WithTimeout aborts calls to the handler that run longer than d: the
+// client gets a 504 response whose error is a timeout, and the context of
+// the request is cancelled so the handler can stop.
+func WithTimeout(d time.Duration) HandleOption {
+	return func(rt *route) {
+		rt.timeout = d
+	}
+}
+
+// handlerTimeoutError is returned to clients of calls that ran past the
+// timeout of their handler.
+type handlerTimeoutError struct {
+	method  string
+	timeout time.Duration
+}
+
+func (e *handlerTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.method, e.timeout)
 }
 
+func (e *handlerTimeoutError) Timeout() bool { return true }
+
 // NewRouter creates a new Router instance.
 func NewRouter() Router {
-	return Router{handlers: safemap.New[string, HandlerFunc]()}
+	return Router{handlers: safemap.New[string, route]()}
 }
 
 // Handle registers a handler for a given method name.
-func (r *Router) Handle(method string, handler HandlerFunc) {
-	r.handlers.Set(method, handler)
+func (r *Router) Handle(method string, handler HandlerFunc, opts ...HandleOption) {
+	rt := route{handler: handler}
+	for _, opt := range opts {
+		opt(&rt)
+	}
+	r.handlers.Set(method, rt)
 }
 
 // CloseHandle removes a handler.
@@ -50,7 +118,7 @@ func (r *Router) ServeStream(stream *smux.Stream) {
 	defer bufferPool.Put(reqBuf)
 
 	// Read the request from the stream
-	n, err := stream.Read(reqBuf)
+	frame, err := readRequest(stream, reqBuf)
 	if err != nil {
 		writeErrorResponse(stream, http.StatusBadRequest, err)
 		return
@@ -58,7 +126,7 @@ func (r *Router) ServeStream(stream *smux.Stream) {
 
 	// Decode the request
 	var req Request
-	if err := req.Decode(reqBuf[:n]); err != nil {
+	if err := req.Decode(frame); err != nil {
 		writeErrorResponse(stream, http.StatusBadRequest, err)
 		return
 	}
@@ -69,15 +137,31 @@ func (r *Router) ServeStream(stream *smux.Stream) {
 		return
 	}
 
+	if req.Method == pingMethod {
+		writePong(stream)
+		return
+	}
+
 	// Find the handler for the method
-	handler, ok := r.handlers.Get(req.Method)
+	rt, ok := r.handlers.Get(req.Method)
 	if !ok {
 		writeErrorResponse(stream, http.StatusNotFound, fmt.Errorf("method not found: %s", req.Method))
 		return
 	}
 
-	// Call the handler
-	resp, err := handler(req)
+	// Call the handler with a context that is cancelled when the client
+	// gives up on the call.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req.ctx = ctx
+
+	stopWatch := watchStream(stream, cancel)
+	resp, err := rt.call(req, stream)
+	stopWatch()
+	if errors.Is(err, errHandlerTimeout) {
+		writeErrorResponse(stream, http.StatusGatewayTimeout, &handlerTimeoutError{method: req.Method, timeout: rt.timeout})
+		return
+	}
 	if err != nil {
 		writeErrorResponse(stream, http.StatusInternalServerError, err)
 		return
@@ -94,8 +178,57 @@ func (r *Router) ServeStream(stream *smux.Stream) {
 		return
 	}
 
-	// If this is a streaming response, execute the callback
+	// If this is a streaming response, execute the callback. The client may
+	// still cancel while the callback streams.
 	if resp.Status == 213 && resp.RawStream != nil {
+		stopWatch := watchStream(stream, cancel)
 		resp.RawStream(stream)
+		stopWatch()
+	}
+}
+
+// errHandlerTimeout tells ServeStream that a handler ran past its timeout.
+var errHandlerTimeout = errors.New("handler timed out")
+
+// call runs the handler of rt, giving up on it with errHandlerTimeout once
+// its timeout passes. stream is the stream of the call.
+func (rt route) call(req Request, stream *smux.Stream) (Response, error) {
+	if rt.timeout <= 0 {
+		return rt.handler(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.ctx, rt.timeout)
+	defer cancel()
+	parent := req.ctx
+	req.ctx = ctx
+
+	type result struct {
+		resp Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := rt.handler(req)
+		done <- result{resp, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.resp, res.err
+	case <-ctx.Done():
+		// A call the client gave up on is not a timeout.
+		if parent.Err() != nil {
+			res := <-done
+			return res.resp, res.err
+		}
+		// A late streaming response still gets its callback, which fails
+		// on the closed stream but releases what it holds.
+		go func() {
+			res := <-done
+			if res.err == nil && res.resp.RawStream != nil {
+				res.resp.RawStream(stream)
+			}
+		}()
+		return Response{}, errHandlerTimeout
 	}
 }
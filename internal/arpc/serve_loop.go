@@ -0,0 +1,138 @@
+package arpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// serveBackoff tracks consecutive serve failures and computes how long to
+// wait before the next attempt.
+type serveBackoff struct {
+	rc       ReconnectConfig
+	backoff  time.Duration
+	failures int
+}
+
+func newServeBackoff(rc ReconnectConfig) *serveBackoff {
+	if rc.InitialBackoff <= 0 {
+		rc.InitialBackoff = 100 * time.Millisecond
+	}
+	if rc.MaxBackoff <= 0 {
+		rc.MaxBackoff = 30 * time.Second
+	}
+	if rc.BackoffJitter <= 0 {
+		rc.BackoffJitter = 0.2
+	}
+	if rc.CircuitBreakTime <= 0 {
+		rc.CircuitBreakTime = 60 * time.Second
+	}
+	if rc.MaxAttempts <= 0 {
+		rc.MaxAttempts = 10
+	}
+
+	return &serveBackoff{rc: rc, backoff: rc.InitialBackoff}
+}
+
+// failure records a failed attempt and returns the delay before the next
+// one. Once MaxAttempts consecutive failures have been seen the circuit
+// opens and the delay becomes CircuitBreakTime.
+func (b *serveBackoff) failure() (time.Duration, bool) {
+	b.failures++
+	if b.failures >= b.rc.MaxAttempts {
+		b.failures = 0
+		b.backoff = b.rc.InitialBackoff
+		return getJitteredBackoff(b.rc.CircuitBreakTime, b.rc.BackoffJitter), true
+	}
+
+	delay := getJitteredBackoff(b.backoff, b.rc.BackoffJitter)
+	b.backoff = min(b.backoff*2, b.rc.MaxBackoff)
+	return delay, false
+}
+
+// reset clears the failure count after a connection stayed up.
+func (b *serveBackoff) reset() {
+	b.failures = 0
+	b.backoff = b.rc.InitialBackoff
+}
+
+// ServeWithBackoff calls serve until ctx is cancelled. Failed attempts are
+// retried with exponential backoff and jitter; after rc.MaxAttempts
+// consecutive failures the circuit breaker opens and the next attempt is
+// held off for rc.CircuitBreakTime. onFailure, if set, is called after each
+// failed attempt. A serve call that lasted longer than rc.MaxBackoff is
+// treated as a healthy connection and resets the backoff.
+func ServeWithBackoff(ctx context.Context, rc ReconnectConfig, serve func() error, onFailure func(error)) {
+	b := newServeBackoff(rc)
+	state := StateConnected
+
+	setState := func(next ConnectionState, fields map[string]interface{}) {
+		if next == state {
+			return
+		}
+		syslog.L.Info().
+			WithMessage("arpc connection state changed").
+			WithField("from", state.String()).
+			WithField("to", next.String()).
+			WithFields(fields).
+			Write()
+		state = next
+	}
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		started := time.Now()
+		err := serve()
+		if err == nil {
+			err = context.Canceled
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if onFailure != nil {
+			onFailure(err)
+		}
+
+		if time.Since(started) > b.rc.MaxBackoff {
+			setState(StateConnected, nil)
+			b.reset()
+		}
+
+		attempt := b.failures + 1
+		delay, circuitOpen := b.failure()
+		syslog.L.Warn().
+			WithMessage("arpc serve attempt failed").
+			WithField("attempt", attempt).
+			WithField("retry_in", delay.String()).
+			WithField("error", err.Error()).
+			Write()
+		if circuitOpen {
+			setState(StateFailed, map[string]interface{}{
+				"error":  err.Error(),
+				"period": delay.String(),
+			})
+		} else {
+			setState(StateReconnecting, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		timer.Reset(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package arpc
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils/safemap"
 )
@@ -10,12 +15,39 @@ import (
 // SessionManager manages client sessions.
 type SessionManager struct {
 	sessions *safemap.Map[string, *Session] // Map of client ID to Session
+
+	// mu serializes registrations, so that a session is only removed while
+	// it is still the one registered for its client.
+	mu sync.Mutex
+
+	// seen keeps the status of every client connected since startup, so that
+	// a client that went away is still reported with its last-seen time.
+	seen *safemap.Map[string, SessionStatus]
+}
+
+// SessionStatus describes the connection of a client as known to the
+// manager.
+type SessionStatus struct {
+	Connected   bool
+	Version     string
+	ConnectedAt time.Time
+	// LastSeen is the time the client disconnected, or the time of the
+	// lookup while it is connected. It is zero for a client not seen since
+	// startup.
+	LastSeen time.Time
+	// ActiveStreams is the number of calls of the client being served.
+	ActiveStreams int
 }
 
+// DefaultMaxConcurrentStreams caps the streams a client can have served at
+// once on a managed session.
+const DefaultMaxConcurrentStreams = 256
+
 // NewSessionManager creates a new SessionManager instance.
 func NewSessionManager() *SessionManager {
 	return &SessionManager{
 		sessions: safemap.New[string, *Session](),
+		seen:     safemap.New[string, SessionStatus](),
 	}
 }
 
@@ -23,17 +55,83 @@ func NewSessionManager() *SessionManager {
 // If a session already exists for the given client ID, it is returned.
 // Otherwise, a new session is created, initialized, and stored.
 func (sm *SessionManager) GetOrCreateSession(clientID string, version string, conn net.Conn) (*Session, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
 	// Check if a session already exists for the client
 	if session, exists := sm.sessions.Get(clientID); exists {
 		return session, nil
 	}
 
-	// Create a new session
+	session, err := newManagedSession(version, conn)
+	if err != nil {
+		return nil, err
+	}
+	sm.register(clientID, session)
+	return session, nil
+}
+
+// RegisterSession creates a session for a client that just connected over
+// conn. A session still registered for the client belongs to a connection
+// the client gave up on; it is replaced and closed.
+func (sm *SessionManager) RegisterSession(clientID string, version string, conn net.Conn) (*Session, error) {
+	session, err := newManagedSession(version, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.mu.Lock()
+	stale, replaced := sm.sessions.Get(clientID)
+	sm.register(clientID, session)
+	sm.mu.Unlock()
+
+	if replaced {
+		_ = stale.Close()
+	}
+	return session, nil
+}
+
+// UnregisterSession removes session from the client ID it was registered
+// under and closes it. It reports false, and leaves the registry alone, when
+// the client has since reconnected with another session.
+func (sm *SessionManager) UnregisterSession(clientID string, session *Session) bool {
+	sm.mu.Lock()
+	current, exists := sm.sessions.Get(clientID)
+	registered := exists && current == session
+	if registered {
+		sm.sessions.Del(clientID)
+		sm.markSeen(clientID)
+	}
+	sm.mu.Unlock()
+
+	_ = session.Close()
+	return registered
+}
+
+// register stores session for clientID. The caller holds mu.
+func (sm *SessionManager) register(clientID string, session *Session) {
+	sm.sessions.Set(clientID, session)
+	now := time.Now()
+	sm.seen.Set(clientID, SessionStatus{Version: session.version, ConnectedAt: now, LastSeen: now})
+}
+
+// markSeen records that clientID went away now.
+func (sm *SessionManager) markSeen(clientID string) {
+	if status, ok := sm.seen.Get(clientID); ok {
+		status.LastSeen = time.Now()
+		sm.seen.Set(clientID, status)
+	}
+}
+
+// newManagedSession creates the server session of a client, with the
+// handlers every managed session answers.
+func newManagedSession(version string, conn net.Conn) (*Session, error) {
 	session, err := NewServerSession(conn, nil)
 	if err != nil {
 		return nil, err
 	}
 	session.version = version
+	session.SetMaxConcurrentStreams(DefaultMaxConcurrentStreams)
 
 	// Initialize the router for the session
 	router := NewRouter()
@@ -50,9 +148,6 @@ func (sm *SessionManager) GetOrCreateSession(clientID string, version string, co
 		return Response{Status: 200, Data: data}, nil
 	})
 	session.SetRouter(router)
-
-	// Store the session in the map
-	sm.sessions.Set(clientID, session)
 	return session, nil
 }
 
@@ -62,17 +157,129 @@ func (sm *SessionManager) GetSession(clientID string) (*Session, bool) {
 	return sm.sessions.Get(clientID)
 }
 
+// Status returns the connection status of a client. A client not seen
+// since startup has a zero status.
+func (sm *SessionManager) Status(clientID string) SessionStatus {
+	status, _ := sm.seen.Get(clientID)
+	if session, connected := sm.sessions.Get(clientID); connected {
+		status.Connected = true
+		status.LastSeen = time.Now()
+		status.ActiveStreams = session.ActiveStreams()
+	}
+	return status
+}
+
+// ErrSessionNotFound is returned when no session is registered for a client.
+var ErrSessionNotFound = errors.New("no active session")
+
+// WaitForSession looks up the session of clientID up to attempts times,
+// sleeping interval between lookups, so that a client that is reconnecting
+// has a chance to come back. It returns the number of lookups made; on
+// failure the error wraps the reason of the last one.
+func (sm *SessionManager) WaitForSession(ctx context.Context, clientID string, attempts int, interval time.Duration) (*Session, int, error) {
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if session, exists := sm.sessions.Get(clientID); exists {
+			return session, attempt, nil
+		}
+		lastErr = fmt.Errorf("%w for %s", ErrSessionNotFound, clientID)
+
+		if attempt == attempts {
+			return nil, attempt, lastErr
+		}
+
+		timer.Reset(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, attempt, fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+		}
+	}
+
+	return nil, attempts, lastErr
+}
+
 // CloseSession closes and removes a Session for a client.
 // If the session does not exist, it returns an error.
 func (sm *SessionManager) CloseSession(clientID string) error {
+	sm.mu.Lock()
 	session, exists := sm.sessions.Get(clientID)
 	if !exists {
+		sm.mu.Unlock()
 		return errors.New("session not found")
 	}
 
 	// Remove the session from the map
 	sm.sessions.Del(clientID)
+	sm.markSeen(clientID)
+	sm.mu.Unlock()
 
 	// Close the session
 	return session.Close()
 }
+
+// CloseClientSessions closes the session of clientID along with the per-job
+// sessions it opened, registered as "clientID|jobId". It returns how many
+// sessions were closed.
+func (sm *SessionManager) CloseClientSessions(clientID string) int {
+	sm.mu.Lock()
+	var ids []string
+	sm.sessions.ForEach(func(id string, _ *Session) bool {
+		if id == clientID || strings.HasPrefix(id, clientID+"|") {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	var sessions []*Session
+	for _, id := range ids {
+		if session, ok := sm.sessions.GetAndDel(id); ok {
+			sm.markSeen(id)
+			sessions = append(sessions, session)
+		}
+	}
+	sm.mu.Unlock()
+
+	for _, session := range sessions {
+		_ = session.Close()
+	}
+	return len(sessions)
+}
+
+// Shutdown drains every session with Session.Shutdown, in parallel, and
+// returns once all of them are closed. Errors of individual sessions are
+// joined.
+func (sm *SessionManager) Shutdown(ctx context.Context) error {
+	var sessions []*Session
+	sm.sessions.ForEach(func(_ string, session *Session) bool {
+		sessions = append(sessions, session)
+		return true
+	})
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, session := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := session.Shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,112 @@
+package arpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/xtaci/smux"
+)
+
+// ErrShuttingDown is returned to calls that reach a session while it is
+// draining.
+var ErrShuttingDown = errors.New("arpc session is shutting down")
+
+// beginStream registers a stream about to be served. It fails with
+// ErrShuttingDown once the session is draining and with ErrTooManyStreams
+// while it serves its maximum number of streams.
+func (s *Session) beginStream() error {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	if s.drained != nil {
+		return ErrShuttingDown
+	}
+	if s.maxStreams > 0 && s.inflight >= s.maxStreams {
+		return ErrTooManyStreams
+	}
+	s.inflight++
+	return nil
+}
+
+// endStream unregisters a stream registered by beginStream.
+func (s *Session) endStream() {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	s.inflight--
+	if s.drained != nil && s.inflight == 0 {
+		close(s.drained)
+	}
+}
+
+// draining reports whether Shutdown was called.
+func (s *Session) draining() bool {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	return s.drained != nil
+}
+
+// rejectStream answers the request on stream with err, as returned by
+// beginStream.
+func rejectStream(stream *smux.Stream, err error) {
+	defer stream.Close()
+
+	// Drain the request first so the caller is not cut off mid-write.
+	if discardErr := discardRequest(stream); discardErr != nil {
+		return
+	}
+
+	status := http.StatusServiceUnavailable
+	if errors.Is(err, ErrTooManyStreams) {
+		status = http.StatusTooManyRequests
+	}
+	writeErrorResponse(stream, status, err)
+}
+
+// discardRequest reads the length-prefixed request at the start of r
+// without keeping it. A request with an invalid length is left unread; the
+// reply is sent regardless.
+func discardRequest(r io.Reader) error {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return err
+	}
+	total := int64(binary.LittleEndian.Uint32(prefix[:]))
+	if total < 4 || total > MaxRequestSize {
+		return nil
+	}
+	_, err := io.CopyN(io.Discard, r, total-4)
+	return err
+}
+
+// Shutdown drains the session: streams opened from now on are answered with
+// ErrShuttingDown, while the handlers already running, including their
+// streamed responses, are left to finish. The session is closed once they
+// have or when ctx is done, whichever comes first; in the latter case the
+// context error is returned.
+func (s *Session) Shutdown(ctx context.Context) error {
+	s.drainMu.Lock()
+	if s.drained == nil {
+		s.drained = make(chan struct{})
+		if s.inflight == 0 {
+			close(s.drained)
+		}
+	}
+	drained := s.drained
+	s.drainMu.Unlock()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if closeErr := s.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
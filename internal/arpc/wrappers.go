@@ -1,6 +1,8 @@
 package arpc
 
 import (
+	"context"
+
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc/arpcdata"
 	"github.com/xtaci/smux"
 )
@@ -8,6 +10,18 @@ import (
 type Request struct {
 	Method  string
 	Payload []byte // Serialized data of one of the other structs
+
+	ctx context.Context // Skipped during encoding/decoding
+}
+
+// Context returns the context of the call. On the server it is cancelled
+// when the client cancels the call or closes its stream, or when the
+// handler runs past its timeout.
+func (req Request) Context() context.Context {
+	if req.ctx == nil {
+		return context.Background()
+	}
+	return req.ctx
 }
 
 func (req *Request) Encode() ([]byte, error) {
@@ -13,6 +13,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	authErrors "github.com/sonroyaalmerol/pbs-plus/internal/auth/errors"
@@ -34,6 +35,51 @@ type Options struct {
 	Hostnames []string
 	// IP addresses to include in SAN
 	IPs []net.IP
+	// How often the renewal loop checks the certificates
+	RenewalInterval time.Duration
+	// Reissue the server certificate this long before it expires
+	RenewBefore time.Duration
+}
+
+// Environment variables overriding the renewal schedule.
+const (
+	// RenewalIntervalEnv is the time between two certificate checks, such
+	// as "30m".
+	RenewalIntervalEnv = "PBS_PLUS_CERT_RENEWAL_INTERVAL"
+	// RenewBeforeEnv is how long before expiry the server certificate is
+	// reissued, such as "720h".
+	RenewBeforeEnv = "PBS_PLUS_CERT_RENEW_BEFORE"
+)
+
+// MinRenewalInterval keeps a misconfigured interval from turning the renewal
+// loop into a busy loop.
+const MinRenewalInterval = time.Minute
+
+// ApplyRenewalEnv overrides RenewalInterval and RenewBefore with the values
+// of RenewalIntervalEnv and RenewBeforeEnv read through getenv. Unset
+// variables keep the current values; an invalid one is an error and leaves
+// o unchanged.
+func (o *Options) ApplyRenewalEnv(getenv func(string) string) error {
+	interval, renewBefore := o.RenewalInterval, o.RenewBefore
+
+	if raw := strings.TrimSpace(getenv(RenewalIntervalEnv)); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid %s %q", RenewalIntervalEnv, raw)
+		}
+		interval = max(parsed, MinRenewalInterval)
+	}
+
+	if raw := strings.TrimSpace(getenv(RenewBeforeEnv)); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("invalid %s %q", RenewBeforeEnv, raw)
+		}
+		renewBefore = parsed
+	}
+
+	o.RenewalInterval, o.RenewBefore = interval, renewBefore
+	return nil
 }
 
 // DefaultOptions returns default certificate generation options
@@ -77,14 +123,18 @@ func DefaultOptions() *Options {
 		OutputDir:    "/etc/proxmox-backup/pbs-plus/certs",
 		Hostnames:    hostnames,
 		IPs:          ips,
+
+		RenewalInterval: time.Hour,
+		RenewBefore:     30 * 24 * time.Hour,
 	}
 }
 
 // Generator handles certificate generation
 type Generator struct {
-	options *Options
-	ca      *x509.Certificate
-	caKey   *rsa.PrivateKey
+	options     *Options
+	ca          *x509.Certificate
+	caKey       *rsa.PrivateKey
+	revocations *RevocationList
 }
 
 // NewGenerator creates a new certificate generator
@@ -99,7 +149,8 @@ func NewGenerator(options *Options) (*Generator, error) {
 	}
 
 	return &Generator{
-		options: options,
+		options:     options,
+		revocations: NewRevocationList(revocationPath(options)),
 	}, nil
 }
 
@@ -251,100 +302,6 @@ func (g *Generator) savePrivateKey(filename string, key *rsa.PrivateKey) error {
 	return nil
 }
 
-func (g *Generator) ValidateExistingCerts() error {
-	serverCertPath := filepath.Join(g.options.OutputDir, "server.crt")
-	caPath := filepath.Join(g.options.OutputDir, "ca.crt")
-	caKeyPath := filepath.Join(g.options.OutputDir, "ca.key")
-
-	// Check if files exist
-	if _, err := os.Stat(serverCertPath); os.IsNotExist(err) {
-		return fmt.Errorf("server certificate not found: %s", serverCertPath)
-	}
-	if _, err := os.Stat(caPath); os.IsNotExist(err) {
-		return fmt.Errorf("CA certificate not found: %s", caPath)
-	}
-	if _, err := os.Stat(caKeyPath); os.IsNotExist(err) {
-		return fmt.Errorf("CA certificate not found: %s", caPath)
-	}
-
-	// Load server certificate
-	serverCertPEM, err := os.ReadFile(serverCertPath)
-	if err != nil {
-		return fmt.Errorf("failed to read server certificate: %w", err)
-	}
-	block, _ := pem.Decode(serverCertPEM)
-	if block == nil {
-		return fmt.Errorf("failed to parse server certificate PEM")
-	}
-	serverCert, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		return fmt.Errorf("failed to parse server certificate: %w", err)
-	}
-
-	// Load CA certificate
-	caPEM, err := os.ReadFile(caPath)
-	if err != nil {
-		return fmt.Errorf("failed to read CA certificate: %w", err)
-	}
-	block, _ = pem.Decode(caPEM)
-	if block == nil {
-		return fmt.Errorf("failed to parse CA certificate PEM")
-	}
-	caCert, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		return fmt.Errorf("failed to parse CA certificate: %w", err)
-	}
-
-	g.ca = caCert
-
-	caKeyPEM, err := os.ReadFile(caKeyPath)
-	if err != nil {
-		return fmt.Errorf("failed to read CA key: %w", err)
-	}
-	block, _ = pem.Decode(caKeyPEM)
-	if block == nil {
-		return fmt.Errorf("failed to parse CA key PEM")
-	}
-
-	caKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return fmt.Errorf("failed to parse CA key: %w", err)
-	}
-
-	g.caKey = caKey
-
-	// Verify server certificate is signed by CA
-	roots := x509.NewCertPool()
-	roots.AddCert(caCert)
-	opts := x509.VerifyOptions{
-		Roots: roots,
-		KeyUsages: []x509.ExtKeyUsage{
-			x509.ExtKeyUsageServerAuth,
-		},
-	}
-
-	if _, err := serverCert.Verify(opts); err != nil {
-		return fmt.Errorf("server certificate validation failed: %w", err)
-	}
-
-	// Check certificate expiry
-	now := time.Now()
-	if now.Before(serverCert.NotBefore) {
-		return fmt.Errorf("server certificate is not yet valid")
-	}
-	if now.After(serverCert.NotAfter) {
-		return fmt.Errorf("server certificate has expired")
-	}
-	if now.Before(caCert.NotBefore) {
-		return fmt.Errorf("CA certificate is not yet valid")
-	}
-	if now.After(caCert.NotAfter) {
-		return fmt.Errorf("CA certificate has expired")
-	}
-
-	return nil
-}
-
 func GenerateCSR(commonName string, keySize int) ([]byte, *rsa.PrivateKey, error) {
 	privKey, err := rsa.GenerateKey(rand.Reader, keySize)
 	if err != nil {
@@ -418,6 +375,11 @@ func (g *Generator) SignCSR(csr []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to create certificate: %w", err)
 	}
 
+	// Indexed so that RevokeCert finds the certificate by agent name.
+	if err := g.revocations.RecordIssued(template); err != nil {
+		return nil, fmt.Errorf("failed to record certificate: %w", err)
+	}
+
 	return EncodeCertPEM(certBytes), nil
 }
 
@@ -429,6 +391,15 @@ func EncodeCertPEM(cert []byte) []byte {
 	})
 }
 
+// ParseCertPEM parses the first certificate of PEM encoded data.
+func ParseCertPEM(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
 func EncodeKeyPEM(key *rsa.PrivateKey) []byte {
 	return pem.EncodeToMemory(&pem.Block{
 		Type:  "RSA PRIVATE KEY",
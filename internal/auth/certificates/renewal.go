@@ -0,0 +1,245 @@
+package certificates
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RenewalAction tells the renewal loop what, if anything, to regenerate.
+type RenewalAction int
+
+const (
+	// RenewalNone means the CA and server certificate are both usable.
+	RenewalNone RenewalAction = iota
+	// RenewalServerCert means only the server certificate has to be
+	// reissued; the CA, and the trust agents have in it, stays.
+	RenewalServerCert
+	// RenewalCA means the CA itself is missing or invalid. Regenerating it
+	// invalidates every enrolled agent, so it should be rare and loud.
+	RenewalCA
+	// RenewalBlocked means the CA or its key exists but cannot be read.
+	// That may be a permission problem or a damaged file, so nothing is
+	// regenerated until an operator fixes or removes the file.
+	RenewalBlocked
+)
+
+func (a RenewalAction) String() string {
+	switch a {
+	case RenewalNone:
+		return "none"
+	case RenewalServerCert:
+		return "server-cert"
+	case RenewalCA:
+		return "ca"
+	case RenewalBlocked:
+		return "blocked"
+	default:
+		return "unknown"
+	}
+}
+
+// CertStatus is the diagnosis of one certificate or key file.
+type CertStatus struct {
+	// Path is the file inspected.
+	Path string
+	// Missing is set when the file does not exist.
+	Missing bool
+	// NotAfter is the end of the validity period of a certificate that
+	// could be parsed. It is zero for keys.
+	NotAfter time.Time
+	// DaysUntilExpiry is the number of whole days left before NotAfter,
+	// negative once it has passed.
+	DaysUntilExpiry int
+	// Expiring is set when the certificate is still valid but falls within
+	// the renewal window.
+	Expiring bool
+	// Err explains why the file is unusable; nil when it is fine.
+	Err error
+}
+
+// OK reports whether the file is present and usable.
+func (s CertStatus) OK() bool {
+	return !s.Missing && s.Err == nil
+}
+
+// CertReport describes the CA, its key and the server certificate on disk,
+// and what has to be regenerated as a result.
+type CertReport struct {
+	CA     CertStatus
+	CAKey  CertStatus
+	Server CertStatus
+	// Action is what the renewal loop has to regenerate, and Reason why.
+	Action RenewalAction
+	Reason error
+}
+
+// InspectCerts inspects the CA, its key and the server certificate on disk.
+// When the CA is usable it is loaded into the generator so GenerateCert can
+// sign a new server certificate with it.
+func (g *Generator) InspectCerts() CertReport {
+	report, caCert, caKey := inspectCerts(g.options.OutputDir, time.Now(), g.options.RenewBefore)
+	if report.Action != RenewalCA {
+		g.ca = caCert
+		g.caKey = caKey
+	}
+	return report
+}
+
+// CheckRenewal decides what has to be regenerated. The returned error
+// explains the decision. See InspectCerts for the details of each file.
+func (g *Generator) CheckRenewal() (RenewalAction, error) {
+	report := g.InspectCerts()
+	return report.Action, report.Reason
+}
+
+func inspectCerts(dir string, now time.Time, renewBefore time.Duration) (CertReport, *x509.Certificate, *rsa.PrivateKey) {
+	report := CertReport{
+		CA:     CertStatus{Path: filepath.Join(dir, "ca.crt")},
+		CAKey:  CertStatus{Path: filepath.Join(dir, "ca.key")},
+		Server: CertStatus{Path: filepath.Join(dir, "server.crt")},
+	}
+
+	caCert, caErr := loadCertificate(report.CA.Path)
+	report.CA.diagnose(caCert, caErr, now, renewBefore)
+	if caErr == nil && !caCert.IsCA {
+		report.CA.Err = errors.New("not a certificate authority")
+	}
+
+	caKey, keyErr := loadPrivateKey(report.CAKey.Path)
+	report.CAKey.diagnose(nil, keyErr, now, renewBefore)
+	if caErr == nil && keyErr == nil {
+		if pub, ok := caCert.PublicKey.(*rsa.PublicKey); !ok || !pub.Equal(&caKey.PublicKey) {
+			report.CAKey.Err = errors.New("does not match the CA certificate")
+		}
+	}
+
+	serverCert, serverErr := loadCertificate(report.Server.Path)
+	report.Server.diagnose(serverCert, serverErr, now, capRenewBefore(serverCert, renewBefore))
+	if serverErr == nil && caErr == nil {
+		if err := serverCert.CheckSignatureFrom(caCert); err != nil {
+			report.Server.Err = fmt.Errorf("not signed by the CA: %w", err)
+		}
+	}
+
+	if caErr == nil {
+		caErr = keyErr
+	}
+	report.Action, report.Reason = decideRenewal(caCert, caKey, caErr, serverCert, serverErr, now, renewBefore)
+	return report, caCert, caKey
+}
+
+// diagnose fills s from the result of loading its file.
+func (s *CertStatus) diagnose(cert *x509.Certificate, err error, now time.Time, renewBefore time.Duration) {
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.Missing = true
+		} else {
+			s.Err = err
+		}
+		return
+	}
+	if cert == nil {
+		return
+	}
+
+	s.NotAfter = cert.NotAfter
+	s.DaysUntilExpiry = int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24))
+	switch {
+	case now.Before(cert.NotBefore):
+		s.Err = errors.New("not yet valid")
+	case now.After(cert.NotAfter):
+		s.Err = fmt.Errorf("expired at %s", cert.NotAfter.Format(time.RFC3339))
+	case now.Add(renewBefore).After(cert.NotAfter):
+		s.Expiring = true
+	}
+}
+
+// capRenewBefore caps renewBefore at a third of the lifetime of cert so
+// short-lived certificates are not reissued on every check.
+func capRenewBefore(cert *x509.Certificate, renewBefore time.Duration) time.Duration {
+	if cert == nil {
+		return renewBefore
+	}
+	return min(renewBefore, cert.NotAfter.Sub(cert.NotBefore)/3)
+}
+
+// decideRenewal separates a server certificate that merely needs reissuing
+// from a CA that is actually invalid. A CA file that is present but fails to
+// load is never regenerated, only a missing one. renewBefore is capped at a third of
+// the server certificate's lifetime so short-lived certificates are not
+// reissued on every check.
+func decideRenewal(
+	caCert *x509.Certificate,
+	caKey *rsa.PrivateKey,
+	caErr error,
+	serverCert *x509.Certificate,
+	serverErr error,
+	now time.Time,
+	renewBefore time.Duration,
+) (RenewalAction, error) {
+	if caErr != nil {
+		if !errors.Is(caErr, os.ErrNotExist) {
+			return RenewalBlocked, fmt.Errorf("CA unreadable, not regenerating it: %w", caErr)
+		}
+		return RenewalCA, fmt.Errorf("CA missing: %w", caErr)
+	}
+	if now.Before(caCert.NotBefore) {
+		return RenewalCA, errors.New("CA certificate is not yet valid")
+	}
+	if now.After(caCert.NotAfter) {
+		return RenewalCA, errors.New("CA certificate has expired")
+	}
+	if !caCert.IsCA {
+		return RenewalCA, errors.New("CA certificate is not a certificate authority")
+	}
+	if pub, ok := caCert.PublicKey.(*rsa.PublicKey); !ok || !pub.Equal(&caKey.PublicKey) {
+		return RenewalCA, errors.New("CA key does not match CA certificate")
+	}
+
+	if serverErr != nil {
+		return RenewalServerCert, fmt.Errorf("server certificate unusable: %w", serverErr)
+	}
+	if err := serverCert.CheckSignatureFrom(caCert); err != nil {
+		return RenewalServerCert, fmt.Errorf("server certificate not signed by CA: %w", err)
+	}
+	if now.Before(serverCert.NotBefore) {
+		return RenewalServerCert, errors.New("server certificate is not yet valid")
+	}
+
+	if now.Add(capRenewBefore(serverCert, renewBefore)).After(serverCert.NotAfter) {
+		return RenewalServerCert, fmt.Errorf("server certificate expires at %s", serverCert.NotAfter.Format(time.RFC3339))
+	}
+
+	return RenewalNone, nil
+}
+
+func loadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse certificate PEM: %s", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse key PEM: %s", path)
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
@@ -0,0 +1,267 @@
+package certificates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestGenerator(t *testing.T) *Generator {
+	t.Helper()
+
+	opts := DefaultOptions()
+	opts.OutputDir = t.TempDir()
+	opts.KeySize = 1024
+	opts.ValidDays = 90
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	if err := g.GenerateCA(); err != nil {
+		t.Fatalf("failed to generate CA: %v", err)
+	}
+	if err := g.GenerateCert("server"); err != nil {
+		t.Fatalf("failed to generate server cert: %v", err)
+	}
+	return g
+}
+
+func loadAll(t *testing.T, g *Generator) (caErr error, serverErr error, decide func(now time.Time) RenewalAction) {
+	t.Helper()
+
+	caCert, caErr := loadCertificate(filepath.Join(g.options.OutputDir, "ca.crt"))
+	caKey, keyErr := loadPrivateKey(filepath.Join(g.options.OutputDir, "ca.key"))
+	if caErr == nil {
+		caErr = keyErr
+	}
+	serverCert, serverErr := loadCertificate(filepath.Join(g.options.OutputDir, "server.crt"))
+
+	return caErr, serverErr, func(now time.Time) RenewalAction {
+		action, _ := decideRenewal(caCert, caKey, caErr, serverCert, serverErr, now, 7*24*time.Hour)
+		return action
+	}
+}
+
+func TestDecideRenewal(t *testing.T) {
+	g := newTestGenerator(t)
+	_, _, decide := loadAll(t, g)
+
+	now := time.Now()
+	if action := decide(now); action != RenewalNone {
+		t.Fatalf("fresh certificates: expected %v, got %v", RenewalNone, action)
+	}
+
+	// Within the renewal window only the server certificate is reissued.
+	if action := decide(now.AddDate(0, 0, 85)); action != RenewalServerCert {
+		t.Fatalf("server cert near expiry: expected %v, got %v", RenewalServerCert, action)
+	}
+
+	// Once the CA itself has expired, there is no way around a new CA.
+	if action := decide(now.AddDate(0, 0, 91)); action != RenewalCA {
+		t.Fatalf("expired CA: expected %v, got %v", RenewalCA, action)
+	}
+}
+
+func TestCheckRenewalServerCertOnly(t *testing.T) {
+	g := newTestGenerator(t)
+
+	// A damaged server certificate must not cost the agents their CA.
+	if err := os.WriteFile(filepath.Join(g.options.OutputDir, "server.crt"), []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	action, reason := g.CheckRenewal()
+	if action != RenewalServerCert {
+		t.Fatalf("expected %v, got %v (%v)", RenewalServerCert, action, reason)
+	}
+
+	caBefore, err := os.ReadFile(filepath.Join(g.options.OutputDir, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.GenerateCert("server"); err != nil {
+		t.Fatalf("failed to reissue server cert with loaded CA: %v", err)
+	}
+	caAfter, err := os.ReadFile(filepath.Join(g.options.OutputDir, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(caBefore) != string(caAfter) {
+		t.Fatal("CA changed while renewing the server certificate")
+	}
+
+	if action, reason := g.CheckRenewal(); action != RenewalNone {
+		t.Fatalf("after reissue: expected %v, got %v (%v)", RenewalNone, action, reason)
+	}
+}
+
+func TestCheckRenewalForeignServerCert(t *testing.T) {
+	g := newTestGenerator(t)
+	other := newTestGenerator(t)
+
+	// A server certificate signed by some other CA is reissued, not the CA.
+	foreign, err := os.ReadFile(filepath.Join(other.options.OutputDir, "server.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(g.options.OutputDir, "server.crt"), foreign, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if action, reason := g.CheckRenewal(); action != RenewalServerCert {
+		t.Fatalf("expected %v, got %v (%v)", RenewalServerCert, action, reason)
+	}
+}
+
+func TestCheckRenewalInvalidCA(t *testing.T) {
+	g := newTestGenerator(t)
+	other := newTestGenerator(t)
+
+	// A CA key that does not belong to the CA certificate is a real CA problem.
+	foreignKey, err := os.ReadFile(filepath.Join(other.options.OutputDir, "ca.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(g.options.OutputDir, "ca.key"), foreignKey, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if action, reason := g.CheckRenewal(); action != RenewalCA {
+		t.Fatalf("expected %v, got %v (%v)", RenewalCA, action, reason)
+	}
+
+	if err := os.Remove(filepath.Join(g.options.OutputDir, "ca.crt")); err != nil {
+		t.Fatal(err)
+	}
+	if action, _ := g.CheckRenewal(); action != RenewalCA {
+		t.Fatalf("missing CA: expected %v, got %v", RenewalCA, action)
+	}
+}
+
+func TestInspectCerts(t *testing.T) {
+	g := newTestGenerator(t)
+	dir := g.options.OutputDir
+	renewBefore := 7 * 24 * time.Hour
+	now := time.Now()
+
+	// All valid.
+	report, _, _ := inspectCerts(dir, now, renewBefore)
+	for name, status := range map[string]CertStatus{"ca": report.CA, "ca key": report.CAKey, "server": report.Server} {
+		if !status.OK() || status.Expiring {
+			t.Fatalf("%s: expected a valid file, got %+v", name, status)
+		}
+	}
+	if report.Server.DaysUntilExpiry < 88 || report.Server.DaysUntilExpiry > 90 {
+		t.Fatalf("server: expected about 89 days left, got %d", report.Server.DaysUntilExpiry)
+	}
+	if report.Action != RenewalNone {
+		t.Fatalf("expected %v, got %v (%v)", RenewalNone, report.Action, report.Reason)
+	}
+
+	// Near expiry: both certificates are flagged, but only the server one
+	// is reissued.
+	report, _, _ = inspectCerts(dir, now.AddDate(0, 0, 85), renewBefore)
+	if !report.Server.OK() || !report.Server.Expiring {
+		t.Fatalf("server near expiry: got %+v", report.Server)
+	}
+	if !report.CA.OK() || !report.CA.Expiring {
+		t.Fatalf("CA near expiry: got %+v", report.CA)
+	}
+	if report.Action != RenewalServerCert {
+		t.Fatalf("near expiry: expected %v, got %v", RenewalServerCert, report.Action)
+	}
+
+	// Expired.
+	report, _, _ = inspectCerts(dir, now.AddDate(0, 0, 91), renewBefore)
+	if report.CA.Err == nil || report.Server.Err == nil {
+		t.Fatalf("expired: expected errors, got CA %+v, server %+v", report.CA, report.Server)
+	}
+	if report.Server.DaysUntilExpiry >= 0 {
+		t.Fatalf("expired: expected negative days left, got %d", report.Server.DaysUntilExpiry)
+	}
+	if !report.CAKey.OK() {
+		t.Fatalf("expired: the CA key itself is fine, got %+v", report.CAKey)
+	}
+	if report.Action != RenewalCA {
+		t.Fatalf("expired: expected %v, got %v", RenewalCA, report.Action)
+	}
+
+	// Missing server certificate, damaged CA key.
+	if err := os.Remove(filepath.Join(dir, "server.crt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ca.key"), []byte("garbage"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	report, _, _ = inspectCerts(dir, now, renewBefore)
+	if !report.Server.Missing {
+		t.Fatalf("expected the server certificate to be missing, got %+v", report.Server)
+	}
+	if report.CAKey.Missing || report.CAKey.Err == nil {
+		t.Fatalf("expected the CA key to be invalid, got %+v", report.CAKey)
+	}
+	if !report.CA.OK() {
+		t.Fatalf("expected the CA certificate to be fine, got %+v", report.CA)
+	}
+	// A CA key that exists but does not load is left for an operator
+	// rather than replaced along with every agent certificate.
+	if report.Action != RenewalBlocked {
+		t.Fatalf("expected %v, got %v", RenewalBlocked, report.Action)
+	}
+
+	// Only once the damaged key is gone is the CA regenerated.
+	if err := os.Remove(filepath.Join(dir, "ca.key")); err != nil {
+		t.Fatal(err)
+	}
+	report, _, _ = inspectCerts(dir, now, renewBefore)
+	if !report.CAKey.Missing {
+		t.Fatalf("expected the CA key to be missing, got %+v", report.CAKey)
+	}
+	if report.Action != RenewalCA {
+		t.Fatalf("expected %v, got %v", RenewalCA, report.Action)
+	}
+}
+
+func TestApplyRenewalEnv(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	opts := DefaultOptions()
+	if err := opts.ApplyRenewalEnv(env(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if opts.RenewalInterval != time.Hour || opts.RenewBefore != 30*24*time.Hour {
+		t.Fatalf("unset variables changed the defaults: %v, %v", opts.RenewalInterval, opts.RenewBefore)
+	}
+
+	err := opts.ApplyRenewalEnv(env(map[string]string{
+		RenewalIntervalEnv: "10s",
+		RenewBeforeEnv:     "168h",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.RenewalInterval != MinRenewalInterval {
+		t.Fatalf("expected the interval to be raised to %v, got %v", MinRenewalInterval, opts.RenewalInterval)
+	}
+	if opts.RenewBefore != 7*24*time.Hour {
+		t.Fatalf("expected 168h, got %v", opts.RenewBefore)
+	}
+
+	for _, bad := range []map[string]string{
+		{RenewalIntervalEnv: "soon"},
+		{RenewalIntervalEnv: "0s"},
+		{RenewBeforeEnv: "-1h"},
+	} {
+		before := *opts
+		if err := opts.ApplyRenewalEnv(env(bad)); err == nil {
+			t.Fatalf("expected %v to be rejected", bad)
+		}
+		if opts.RenewalInterval != before.RenewalInterval || opts.RenewBefore != before.RenewBefore {
+			t.Fatalf("%v changed the options", bad)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	authErrors "github.com/sonroyaalmerol/pbs-plus/internal/auth/errors"
+)
+
+// RevocationFileName is the file in the certificate directory that lists
+// revoked agents.
+const RevocationFileName = "revoked-agents.json"
+
+// ErrCertRevoked is returned for a certificate that was revoked.
+var ErrCertRevoked = errors.New("certificate revoked")
+
+// RevocationList records the serial numbers of the agent certificates that
+// were revoked. To revoke an agent by name, it also indexes the serials issued
+// to each common name by SignCSR. Entries are dropped once the certificate
+// expires. The file is re-read when it changes, so revocations by another
+// process apply without a restart.
+type RevocationList struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	entries revocationEntries
+}
+
+// revocationEntries is the content of the revocation file. Serials are
+// hexadecimal and map to the expiry of their certificate as a Unix time.
+type revocationEntries struct {
+	Issued  map[string]map[string]int64 `json:"issued"`
+	Revoked map[string]int64            `json:"revoked"`
+}
+
+func newRevocationEntries() revocationEntries {
+	return revocationEntries{
+		Issued:  map[string]map[string]int64{},
+		Revoked: map[string]int64{},
+	}
+}
+
+// clone copies the entries, leaving out the certificates expired at now.
+func (e revocationEntries) clone(now time.Time) revocationEntries {
+	c := newRevocationEntries()
+	for cn, serials := range e.Issued {
+		for serial, notAfter := range serials {
+			if notAfter < now.Unix() {
+				continue
+			}
+			if c.Issued[cn] == nil {
+				c.Issued[cn] = map[string]int64{}
+			}
+			c.Issued[cn][serial] = notAfter
+		}
+	}
+	for serial, notAfter := range e.Revoked {
+		if notAfter >= now.Unix() {
+			c.Revoked[serial] = notAfter
+		}
+	}
+	return c
+}
+
+// NewRevocationList returns the revocation list stored at path. A missing
+// file is an empty list.
+func NewRevocationList(path string) *RevocationList {
+	return &RevocationList{path: path, entries: newRevocationEntries()}
+}
+
+// RecordIssued indexes cert under its common name, so that Revoke can find
+// it.
+func (l *RevocationList) RecordIssued(cert *x509.Certificate) error {
+	return l.update("record_cert", func(entries revocationEntries) error {
+		cn := cert.Subject.CommonName
+		if entries.Issued[cn] == nil {
+			entries.Issued[cn] = map[string]int64{}
+		}
+		entries.Issued[cn][serialKey(cert)] = cert.NotAfter.Unix()
+		return nil
+	})
+}
+
+// Revoke rejects the certificates issued to commonName so far, along with
+// certs, which covers certificates issued before they were indexed. A later
+// bootstrap of the agent gets a certificate with a new serial, which is
+// accepted.
+func (l *RevocationList) Revoke(commonName string, certs ...*x509.Certificate) error {
+	if commonName == "" {
+		return authErrors.WrapError("revoke_cert", errors.New("empty common name"))
+	}
+
+	return l.update("revoke_cert", func(entries revocationEntries) error {
+		revoked := 0
+		for serial, notAfter := range entries.Issued[commonName] {
+			entries.Revoked[serial] = notAfter
+			revoked++
+		}
+		delete(entries.Issued, commonName)
+		for _, cert := range certs {
+			entries.Revoked[serialKey(cert)] = cert.NotAfter.Unix()
+			revoked++
+		}
+		if revoked == 0 {
+			return fmt.Errorf("no certificate issued to %s", commonName)
+		}
+		return nil
+	})
+}
+
+// update applies fn to a copy of the current entries and writes the result.
+func (l *RevocationList) update(op string, fn func(revocationEntries) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.reload(); err != nil {
+		return authErrors.WrapError(op, err)
+	}
+
+	entries := l.entries.clone(time.Now())
+	if err := fn(entries); err != nil {
+		return authErrors.WrapError(op, err)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return authErrors.WrapError(op, err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return authErrors.WrapError(op, err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return authErrors.WrapError(op, err)
+	}
+
+	l.entries = entries
+	if info, err := os.Stat(l.path); err == nil {
+		l.modTime, l.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// IsRevoked reports whether the serial of cert was revoked. An unreadable
+// list revokes nothing and is retried on the next check.
+func (l *RevocationList) IsRevoked(cert *x509.Certificate) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_ = l.reload()
+
+	_, ok := l.entries.Revoked[serialKey(cert)]
+	return ok
+}
+
+func serialKey(cert *x509.Certificate) string {
+	return cert.SerialNumber.Text(16)
+}
+
+// VerifyPeerCertificate rejects revoked client certificates during the TLS
+// handshake. It has the signature of tls.Config.VerifyPeerCertificate.
+func (l *RevocationList) VerifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return nil
+	}
+
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse client certificate: %w", err)
+	}
+	if l.IsRevoked(cert) {
+		return fmt.Errorf("%w: %s", ErrCertRevoked, cert.Subject.CommonName)
+	}
+	return nil
+}
+
+// reload re-reads the list when the file changed. The caller holds mu.
+func (l *RevocationList) reload() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			l.entries = newRevocationEntries()
+			l.modTime, l.size = time.Time{}, 0
+			return nil
+		}
+		return err
+	}
+	if info.ModTime().Equal(l.modTime) && info.Size() == l.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	entries := newRevocationEntries()
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("invalid revocation list %s: %w", l.path, err)
+	}
+	if entries.Issued == nil {
+		entries.Issued = map[string]map[string]int64{}
+	}
+	if entries.Revoked == nil {
+		entries.Revoked = map[string]int64{}
+	}
+
+	l.entries = entries
+	l.modTime, l.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// RevokeCert revokes the certificates SignCSR issued so far to the agent
+// commonName, along with certs. The agent has to bootstrap again to get a new
+// one.
+func (g *Generator) RevokeCert(commonName string, certs ...*x509.Certificate) error {
+	return g.revocations.Revoke(commonName, certs...)
+}
+
+// IsRevoked reports whether cert was revoked with RevokeCert.
+func (g *Generator) IsRevoked(cert *x509.Certificate) bool {
+	return g.revocations.IsRevoked(cert)
+}
+
+func revocationPath(options *Options) string {
+	return filepath.Join(options.OutputDir, RevocationFileName)
+}
@@ -9,6 +9,9 @@ var (
 	// ErrInvalidToken indicates the provided token is invalid or expired
 	ErrInvalidToken = errors.New("invalid or expired token")
 
+	// ErrRevokedToken indicates the provided token was revoked
+	ErrRevokedToken = errors.New("revoked token")
+
 	// ErrUnauthorized indicates the client is not authorized
 	ErrUnauthorized = errors.New("unauthorized")
 
@@ -0,0 +1,101 @@
+//go:build linux
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+)
+
+// The proxy runs as backup (gid 34) and needs to read the mounted cert/key.
+const (
+	ProxyFileUID = 0
+	ProxyFileGID = 34
+)
+
+// proxyKeyForbiddenPerm are the permission bits the server key must never have.
+const proxyKeyForbiddenPerm os.FileMode = 0007
+
+// fileDrift describes how a file's ownership or permissions differ from what
+// is expected. An empty result means the file is as expected.
+func fileDrift(info os.FileInfo, uid, gid int, forbidden os.FileMode) []string {
+	var drift []string
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return []string{"unable to read file ownership"}
+	}
+	if int(stat.Uid) != uid || int(stat.Gid) != gid {
+		drift = append(drift, fmt.Sprintf("owner is %d:%d, expected %d:%d", stat.Uid, stat.Gid, uid, gid))
+	}
+	if perm := info.Mode().Perm(); perm&forbidden != 0 {
+		drift = append(drift, fmt.Sprintf("mode is %04o, must not include %04o", perm, forbidden))
+	}
+	if perm := info.Mode().Perm(); perm&0040 == 0 {
+		drift = append(drift, fmt.Sprintf("mode is %04o, group cannot read", perm))
+	}
+
+	return drift
+}
+
+// repairFile checks path against the expected ownership and permissions and
+// corrects it. It returns the drift that was found.
+func repairFile(path string, uid, gid int, forbidden os.FileMode) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := fileDrift(info, uid, gid, forbidden)
+	if len(drift) == 0 {
+		return nil, nil
+	}
+
+	if err := os.Chown(path, uid, gid); err != nil {
+		return drift, fmt.Errorf("failed to change owner of %s: %w", path, err)
+	}
+	perm := (info.Mode().Perm() | 0040) &^ forbidden
+	if err := os.Chmod(path, perm); err != nil {
+		return drift, fmt.Errorf("failed to change mode of %s: %w", path, err)
+	}
+
+	return drift, nil
+}
+
+// VerifyMount checks that the server cert/key still have the ownership and
+// permissions the proxy needs and are still mounted over the proxy's files.
+// Any drift is repaired and returned, keyed by the affected path.
+func (c *Config) VerifyMount() (map[string][]string, error) {
+	found := make(map[string][]string)
+
+	for path, forbidden := range map[string]os.FileMode{
+		c.CertFile: 0,
+		c.KeyFile:  proxyKeyForbiddenPerm,
+	} {
+		drift, err := repairFile(path, ProxyFileUID, ProxyFileGID, forbidden)
+		if len(drift) > 0 {
+			found[path] = drift
+		}
+		if err != nil {
+			return found, err
+		}
+	}
+
+	remount := false
+	for _, target := range []string{proxyCert, proxyKey} {
+		if !utils.IsMounted(target) {
+			found[target] = append(found[target], "not mounted")
+			remount = true
+		}
+	}
+	if remount {
+		if err := c.Mount(); err != nil {
+			return found, fmt.Errorf("failed to remount certificates: %w", err)
+		}
+	}
+
+	return found, nil
+}
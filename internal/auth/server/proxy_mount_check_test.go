@@ -0,0 +1,77 @@
+//go:build linux
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileDrift(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.key")
+	if err := os.WriteFile(path, []byte("key"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift := fileDrift(info, uid, gid, proxyKeyForbiddenPerm); len(drift) != 0 {
+		t.Fatalf("expected no drift, got %v", drift)
+	}
+
+	if drift := fileDrift(info, uid, gid+1, proxyKeyForbiddenPerm); len(drift) != 1 {
+		t.Fatalf("expected ownership drift, got %v", drift)
+	}
+
+	if err := os.Chmod(path, 0604); err != nil {
+		t.Fatal(err)
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// World-readable and not group-readable.
+	if drift := fileDrift(info, uid, gid, proxyKeyForbiddenPerm); len(drift) != 2 {
+		t.Fatalf("expected two permission drifts, got %v", drift)
+	}
+	// The certificate may be world-readable, but the group must still read it.
+	if drift := fileDrift(info, uid, gid, 0); len(drift) != 1 {
+		t.Fatalf("expected group-read drift, got %v", drift)
+	}
+}
+
+func TestRepairFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.key")
+	if err := os.WriteFile(path, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0606); err != nil {
+		t.Fatal(err)
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+
+	drift, err := repairFile(path, uid, gid, proxyKeyForbiddenPerm)
+	if err != nil {
+		t.Fatalf("repair failed: %v", err)
+	}
+	if len(drift) == 0 {
+		t.Fatal("expected drift to be reported")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0640 {
+		t.Fatalf("expected mode 0640 after repair, got %04o", perm)
+	}
+
+	drift, err = repairFile(path, uid, gid, proxyKeyForbiddenPerm)
+	if err != nil || len(drift) != 0 {
+		t.Fatalf("expected clean file after repair, got %v (%v)", drift, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/sonroyaalmerol/pbs-plus/internal/auth/certificates"
 	authErrors "github.com/sonroyaalmerol/pbs-plus/internal/auth/errors"
 )
 
@@ -17,6 +18,10 @@ type Config struct {
 	KeyFile  string
 	CAFile   string
 	CAKey    string
+	// CRLFile lists revoked agent certificates; see
+	// certificates.RevocationList. Client certificates are not checked for
+	// revocation when it is empty.
+	CRLFile string
 
 	// Token configuration
 	TokenExpiration time.Duration
@@ -32,6 +37,9 @@ type Config struct {
 	// Rate limiting
 	RateLimit float64 // Requests per second
 	RateBurst int     // Maximum burst size
+
+	// How often the mounted cert/key are checked for drift
+	MountCheckInterval time.Duration
 }
 
 // DefaultConfig returns a default server configuration
@@ -47,6 +55,8 @@ func DefaultConfig() *Config {
 
 		RateLimit: 100.0,
 		RateBurst: 200,
+
+		MountCheckInterval: 5 * time.Minute,
 	}
 }
 
@@ -87,9 +97,14 @@ func (c *Config) LoadTLSConfig() (*tls.Config, error) {
 			errors.New("failed to append CA certificate"))
 	}
 
-	return &tls.Config{
+	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		ClientCAs:    caCertPool,
 		ClientAuth:   tls.VerifyClientCertIfGiven,
-	}, nil
+	}
+	if c.CRLFile != "" {
+		tlsConfig.VerifyPeerCertificate = certificates.NewRevocationList(c.CRLFile).VerifyPeerCertificate
+	}
+
+	return tlsConfig, nil
 }
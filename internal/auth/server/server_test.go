@@ -0,0 +1,120 @@
+//go:build linux
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/auth/certificates"
+)
+
+func TestLoadTLSConfigRejectsRevokedCert(t *testing.T) {
+	dir := t.TempDir()
+	generator, err := certificates.NewGenerator(&certificates.Options{
+		Organization: "PBS Plus",
+		CommonName:   "PBS Plus CA",
+		ValidDays:    1,
+		KeySize:      2048,
+		OutputDir:    dir,
+		Hostnames:    []string{"localhost"},
+		IPs:          []net.IP{net.ParseIP("127.0.0.1")},
+	})
+	if err != nil {
+		t.Fatalf("NewGenerator failed: %v", err)
+	}
+	if err := generator.GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA failed: %v", err)
+	}
+	if err := generator.GenerateCert("server"); err != nil {
+		t.Fatalf("GenerateCert(server) failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.CertFile = filepath.Join(dir, "server.crt")
+	config.KeyFile = filepath.Join(dir, "server.key")
+	config.CAFile = filepath.Join(dir, "ca.crt")
+	config.CRLFile = filepath.Join(dir, certificates.RevocationFileName)
+
+	tlsConfig, err := config.LoadTLSConfig()
+	if err != nil {
+		t.Fatalf("LoadTLSConfig failed: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	defer srv.Close()
+
+	caPEM, err := os.ReadFile(config.CAFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+
+	// issue signs a certificate for an agent the way its bootstrap does.
+	issue := func(name string) tls.Certificate {
+		csr, key, err := certificates.GenerateCSR(name, 2048)
+		if err != nil {
+			t.Fatalf("GenerateCSR(%s) failed: %v", name, err)
+		}
+		certPEM, err := generator.SignCSR(csr)
+		if err != nil {
+			t.Fatalf("SignCSR(%s) failed: %v", name, err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, certificates.EncodeKeyPEM(key))
+		if err != nil {
+			t.Fatalf("X509KeyPair(%s) failed: %v", name, err)
+		}
+		return cert
+	}
+
+	get := func(cert tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				RootCAs:      roots,
+			},
+			DisableKeepAlives: true,
+		}}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	revoked := issue("revoked-agent")
+	other := issue("other-agent")
+
+	if err := get(revoked); err != nil {
+		t.Fatalf("expected handshake to succeed before revocation, got %v", err)
+	}
+
+	if err := generator.RevokeCert("revoked-agent"); err != nil {
+		t.Fatalf("RevokeCert failed: %v", err)
+	}
+
+	if err := get(revoked); err == nil {
+		t.Fatal("expected handshake with revoked certificate to fail")
+	}
+	if err := get(other); err != nil {
+		t.Fatalf("expected handshake with other certificate to succeed, got %v", err)
+	}
+
+	// A bootstrap right after the revocation, within the same second, gets a
+	// certificate with a new serial.
+	if err := get(issue("revoked-agent")); err != nil {
+		t.Fatalf("expected handshake with re-issued certificate to succeed, got %v", err)
+	}
+}
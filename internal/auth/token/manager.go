@@ -3,7 +3,13 @@ package token
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -19,6 +25,11 @@ type Claims struct {
 type Manager struct {
 	secret []byte
 	config Config
+
+	revokedMu sync.RWMutex
+	// revoked maps the ID of each revoked token to the Unix time it was
+	// revoked at.
+	revoked map[string]int64
 }
 
 // Config represents token manager configuration
@@ -27,6 +38,9 @@ type Config struct {
 	TokenExpiration time.Duration
 	// SecretKey is the key used to sign tokens
 	SecretKey string
+	// RevocationFile persists the IDs of revoked tokens. Revocations are
+	// kept in memory only when it is empty.
+	RevocationFile string
 }
 
 // NewManager creates a new token manager
@@ -45,8 +59,13 @@ func NewManager(config Config) (*Manager, error) {
 	}
 
 	m := &Manager{
-		secret: []byte(config.SecretKey),
-		config: config,
+		secret:  []byte(config.SecretKey),
+		config:  config,
+		revoked: make(map[string]int64),
+	}
+
+	if err := m.loadRevocations(); err != nil {
+		return nil, authErrors.WrapError("load_revocations", err)
 	}
 
 	return m, nil
@@ -54,8 +73,14 @@ func NewManager(config Config) (*Manager, error) {
 
 // GenerateToken creates a new JWT token for an agent
 func (m *Manager) GenerateToken() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", authErrors.WrapError("generate_token", err)
+	}
+
 	claims := Claims{
 		StandardClaims: jwt.StandardClaims{
+			Id:        hex.EncodeToString(id),
 			ExpiresAt: time.Now().Add(m.config.TokenExpiration).Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
@@ -82,9 +107,104 @@ func (m *Manager) ValidateToken(tokenString string) error {
 		return authErrors.WrapError("validate_token", err)
 	}
 
-	if _, ok := token.Claims.(*Claims); ok && token.Valid {
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		if claims.Id != "" && m.IsRevoked(claims.Id) {
+			return authErrors.ErrRevokedToken
+		}
 		return nil
 	}
 
 	return authErrors.ErrInvalidToken
 }
+
+// TokenID returns the ID (jti) of a token issued by the manager. Tokens
+// issued before tokens carried an ID have none.
+func (m *Manager) TokenID(tokenString string) (string, error) {
+	var claims Claims
+	if _, _, err := new(jwt.Parser).ParseUnverified(tokenString, &claims); err != nil {
+		return "", authErrors.WrapError("token_id", err)
+	}
+	return claims.Id, nil
+}
+
+// Revoke rejects the token with ID tokenID from now on, even before it
+// expires.
+func (m *Manager) Revoke(tokenID string) error {
+	if tokenID == "" {
+		return authErrors.WrapError("revoke_token", errors.New("empty token id"))
+	}
+
+	m.revokedMu.Lock()
+	defer m.revokedMu.Unlock()
+
+	if _, ok := m.revoked[tokenID]; ok {
+		return nil
+	}
+	m.revoked[tokenID] = time.Now().Unix()
+
+	if err := m.saveRevocations(); err != nil {
+		delete(m.revoked, tokenID)
+		return authErrors.WrapError("revoke_token", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether the token with ID tokenID was revoked.
+func (m *Manager) IsRevoked(tokenID string) bool {
+	m.revokedMu.RLock()
+	defer m.revokedMu.RUnlock()
+
+	_, ok := m.revoked[tokenID]
+	return ok
+}
+
+// loadRevocations reads the revocation file. Entries older than the token
+// lifetime are dropped: every token they could match has expired.
+func (m *Manager) loadRevocations() error {
+	if m.config.RevocationFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(m.config.RevocationFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var revoked map[string]int64
+	if err := json.Unmarshal(data, &revoked); err != nil {
+		return fmt.Errorf("invalid revocation file %s: %w", m.config.RevocationFile, err)
+	}
+
+	cutoff := time.Now().Add(-m.config.TokenExpiration).Unix()
+	for id, revokedAt := range revoked {
+		if revokedAt >= cutoff {
+			m.revoked[id] = revokedAt
+		}
+	}
+	return nil
+}
+
+// saveRevocations replaces the revocation file with the current list. The
+// caller holds revokedMu.
+func (m *Manager) saveRevocations() error {
+	if m.config.RevocationFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(m.revoked)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.config.RevocationFile), 0700); err != nil {
+		return err
+	}
+	tmp := m.config.RevocationFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.config.RevocationFile)
+}
@@ -0,0 +1,63 @@
+package token
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	authErrors "github.com/sonroyaalmerol/pbs-plus/internal/auth/errors"
+)
+
+func TestRevokeToken(t *testing.T) {
+	revocationFile := filepath.Join(t.TempDir(), "revoked-tokens.json")
+	config := Config{
+		TokenExpiration: time.Hour,
+		SecretKey:       "test-secret",
+		RevocationFile:  revocationFile,
+	}
+
+	m, err := NewManager(config)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	tokenStr, err := m.GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	other, err := m.GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	id, err := m.TokenID(tokenStr)
+	if err != nil || id == "" {
+		t.Fatalf("expected token to carry an id, got %q, %v", id, err)
+	}
+	if err := m.ValidateToken(tokenStr); err != nil {
+		t.Fatalf("expected fresh token to validate, got %v", err)
+	}
+
+	if err := m.Revoke(id); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if !m.IsRevoked(id) {
+		t.Fatal("expected token to be revoked")
+	}
+	if err := m.ValidateToken(tokenStr); !errors.Is(err, authErrors.ErrRevokedToken) {
+		t.Fatalf("expected ErrRevokedToken, got %v", err)
+	}
+	if err := m.ValidateToken(other); err != nil {
+		t.Fatalf("expected other token to stay valid, got %v", err)
+	}
+
+	// The revocation survives a restart.
+	reloaded, err := NewManager(config)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := reloaded.ValidateToken(tokenStr); !errors.Is(err, authErrors.ErrRevokedToken) {
+		t.Fatalf("expected revocation to be persisted, got %v", err)
+	}
+}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	binarystream "github.com/sonroyaalmerol/pbs-plus/internal/arpc/binary"
+	"github.com/sonroyaalmerol/pbs-plus/internal/backend/metrics"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 )
 
@@ -25,6 +27,10 @@ func (f *ARPCFile) Close() error {
 		return syscall.EIO
 	}
 
+	if f.record != nil {
+		f.fs.finishRecord(f)
+	}
+
 	req := types.CloseReq{HandleID: f.handleID}
 	_, err := f.fs.session.CallMsgWithTimeout(1*time.Minute, f.jobId+"/Close", &req)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -33,6 +39,12 @@ func (f *ARPCFile) Close() error {
 	}
 	f.isClosed.Store(true)
 
+	if f.partial != nil {
+		if err := f.partial.close(); err != nil {
+			syslog.L.Error(err).WithMessage("failed to save partial file cache").WithField("name", f.name).Write()
+		}
+	}
+
 	return nil
 }
 
@@ -66,14 +78,31 @@ func (f *ARPCFile) ReadAt(p []byte, off int64) (int, error) {
 		return 0, syscall.EIO
 	}
 
+	var n int
+	var err error
+	if f.partial != nil {
+		n, err = f.partial.readAt(f, p, off)
+	} else {
+		n, err = f.readAt(p, off)
+	}
+	if f.record != nil && n > 0 {
+		f.record.hash.write(p[:n], off)
+	}
+	return n, err
+}
+
+// readAt reads from the agent.
+func (f *ARPCFile) readAt(p []byte, off int64) (int, error) {
+
 	if f.fs.session == nil {
 		return 0, syscall.EIO
 	}
 
 	req := types.ReadAtReq{
-		HandleID: f.handleID,
-		Offset:   off,
-		Length:   len(p),
+		HandleID:     f.handleID,
+		Offset:       off,
+		Length:       len(p),
+		AcceptCodecs: binarystream.SupportedCodecs,
 	}
 
 	bytesRead, err := f.fs.session.CallBinary(f.fs.ctx, f.jobId+"/ReadAt", &req, p)
@@ -86,6 +115,7 @@ func (f *ARPCFile) ReadAt(p []byte, off int64) (int, error) {
 	}
 
 	atomic.AddInt64(&f.fs.totalBytes, int64(bytesRead))
+	metrics.AddBytes(f.jobId, int64(bytesRead))
 
 	// If we read less than requested, it indicates EOF
 	if bytesRead < len(p) {
@@ -94,3 +124,40 @@ func (f *ARPCFile) ReadAt(p []byte, off int64) (int, error) {
 
 	return bytesRead, nil
 }
+
+// HashAt asks the agent for the digest of length bytes starting at off,
+// hashed with algorithm (types.HashXXH3, types.HashSHA256 or
+// types.HashBLAKE3), without transferring the data. It also returns how many
+// bytes were hashed, which is less than length when the range runs past EOF.
+func (f *ARPCFile) HashAt(off int64, length int64, algorithm string) ([]byte, int64, error) {
+	if f.isClosed.Load() {
+		return nil, 0, syscall.EIO
+	}
+
+	if f.fs.session == nil {
+		return nil, 0, syscall.EIO
+	}
+
+	req := types.HashReq{
+		HandleID:  f.handleID,
+		Offset:    off,
+		Length:    length,
+		Algorithm: algorithm,
+	}
+
+	raw, err := f.fs.session.CallMsgWithTimeout(5*time.Minute, f.jobId+"/HashAt", &req)
+	if err != nil {
+		syslog.L.Error(err).WithMessage("failed to handle hash request").WithField("name", f.name).Write()
+		if arpc.IsOSError(err) {
+			return nil, 0, err
+		}
+		return nil, 0, syscall.EIO
+	}
+
+	var resp types.HashResp
+	if err := resp.Decode(raw); err != nil {
+		return nil, 0, syscall.EIO
+	}
+
+	return resp.Digest, resp.Length, nil
+}
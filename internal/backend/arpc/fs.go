@@ -4,7 +4,12 @@ package arpcfs
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -12,7 +17,10 @@ import (
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/backend/metrics"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/safemap"
 	"github.com/zeebo/xxh3"
 )
 
@@ -32,13 +40,14 @@ func hashPath(path string) uint64 {
 func NewARPCFS(ctx context.Context, session *arpc.Session, hostname string, jobId string, backupMode string) *ARPCFS {
 	ctxFs, cancel := context.WithCancel(ctx)
 	fs := &ARPCFS{
-		basePath:   "/",
-		ctx:        ctxFs,
-		cancel:     cancel,
-		session:    session,
-		JobId:      jobId,
-		Hostname:   hostname,
-		backupMode: backupMode,
+		basePath:    "/",
+		ctx:         ctxFs,
+		cancel:      cancel,
+		session:     session,
+		JobId:       jobId,
+		Hostname:    hostname,
+		backupMode:  backupMode,
+		failedPaths: safemap.New[string, string](),
 	}
 
 	return fs
@@ -142,8 +151,72 @@ func (fs *ARPCFS) OpenFile(filename string, flag int, perm os.FileMode) (ARPCFil
 	}, nil
 }
 
-// Attr retrieves file attributes via RPC and then tracks the access.
+// SmallFileThreshold is the largest file the FUSE layer reads with a single
+// ReadFile call instead of OpenFile, ReadAt and Close.
+const SmallFileThreshold = 64 * 1024
+
+var smallFilePool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, SmallFileThreshold)
+		return &b
+	},
+}
+
+// ReadFile fetches the whole content of a file of at most SmallFileThreshold
+// bytes in one round trip. Callers should fall back to OpenFile when it
+// fails, since the file may have grown past the threshold.
+func (fs *ARPCFS) ReadFile(filename string) ([]byte, error) {
+	if fs.session == nil {
+		syslog.L.Error(os.ErrInvalid).
+			WithMessage("arpc session is nil").
+			Write()
+		return nil, syscall.EIO
+	}
+
+	bufPtr := smallFilePool.Get().(*[]byte)
+	defer smallFilePool.Put(bufPtr)
+	buf := *bufPtr
+
+	req := types.ReadFileReq{Path: filename, MaxSize: len(buf)}
+	bytesRead, err := fs.session.CallBinary(fs.ctx, fs.JobId+"/ReadFile", &req, buf)
+	if err != nil {
+		if arpc.IsOSError(err) {
+			return nil, err
+		}
+		return nil, syscall.EIO
+	}
+
+	atomic.AddInt64(&fs.totalBytes, int64(bytesRead))
+	metrics.AddBytes(fs.JobId, int64(bytesRead))
+
+	data := make([]byte, bytesRead)
+	copy(data, buf[:bytesRead])
+	return data, nil
+}
+
+// Attr retrieves file attributes via RPC and then tracks the access. Stats
+// are coalesced with concurrent ones when SetStatBatch enabled batching.
 func (fs *ARPCFS) Attr(filename string) (types.AgentFileInfo, error) {
+	var fi types.AgentFileInfo
+	var err error
+	if b := fs.statBatcher.Load(); b != nil && !fs.statBatchUnsupported.Load() {
+		fi, err = b.stat(filename)
+		if errors.Is(err, errStatBatchUnsupported) {
+			fi, err = fs.attr(filename)
+		}
+	} else {
+		fi, err = fs.attr(filename)
+	}
+	if err != nil {
+		return types.AgentFileInfo{}, err
+	}
+
+	fs.countAccess(fi)
+	return fi, nil
+}
+
+// attr stats filename with a single Attr call.
+func (fs *ARPCFS) attr(filename string) (types.AgentFileInfo, error) {
 	var fi types.AgentFileInfo
 	if fs.session == nil {
 		syslog.L.Error(os.ErrInvalid).
@@ -165,14 +238,16 @@ func (fs *ARPCFS) Attr(filename string) (types.AgentFileInfo, error) {
 	if err != nil {
 		return types.AgentFileInfo{}, syscall.EIO
 	}
+	return fi, nil
+}
 
+func (fs *ARPCFS) countAccess(fi types.AgentFileInfo) {
 	if fi.IsDir {
 		atomic.AddInt64(&fs.folderCount, 1)
 	} else {
 		atomic.AddInt64(&fs.fileCount, 1)
+		metrics.AddFile(fs.JobId)
 	}
-
-	return fi, nil
 }
 
 // Xattr retrieves extended attributes and logs the access similarly.
@@ -202,6 +277,70 @@ func (fs *ARPCFS) Xattr(filename string) (types.AgentFileInfo, error) {
 	return fi, nil
 }
 
+// Readlink returns the target of the link filename, as stored on the agent.
+func (fs *ARPCFS) Readlink(filename string) (string, error) {
+	if fs.session == nil {
+		syslog.L.Error(os.ErrInvalid).
+			WithMessage("arpc session is nil").
+			Write()
+		return "", syscall.EIO
+	}
+
+	var target arpc.StringMsg
+	req := types.StatReq{Path: filename}
+	raw, err := fs.session.CallMsgWithTimeout(1*time.Minute, fs.JobId+"/Readlink", &req)
+	if err != nil {
+		if arpc.IsOSError(err) {
+			return "", err
+		}
+		return "", syscall.EIO
+	}
+
+	if err := target.Decode(raw); err != nil {
+		return "", syscall.EIO
+	}
+	return string(target), nil
+}
+
+// Streams lists the alternate data streams of filename. Agents that predate
+// the Streams call report none.
+func (fs *ARPCFS) Streams(filename string) (types.StreamInfos, error) {
+	if fs.session == nil {
+		syslog.L.Error(os.ErrInvalid).
+			WithMessage("arpc session is nil").
+			Write()
+		return nil, syscall.EIO
+	}
+	if fs.streamsUnsupported.Load() {
+		return nil, nil
+	}
+
+	var streams types.StreamInfos
+	req := types.StatReq{Path: filename}
+	raw, err := fs.session.CallMsgWithTimeout(1*time.Minute, fs.JobId+"/Streams", &req)
+	if err != nil {
+		if isMethodNotFound(err) {
+			fs.streamsUnsupported.Store(true)
+			return nil, nil
+		}
+		if arpc.IsOSError(err) {
+			return nil, err
+		}
+		return nil, syscall.EIO
+	}
+
+	if err := streams.Decode(raw); err != nil {
+		return nil, syscall.EIO
+	}
+	return streams, nil
+}
+
+// isMethodNotFound reports whether err is the reply of an agent that does
+// not know the called method.
+func isMethodNotFound(err error) bool {
+	return strings.Contains(err.Error(), "method not found")
+}
+
 // StatFS calls StatFS via RPC.
 func (fs *ARPCFS) StatFS() (types.StatFS, error) {
 	if fs.session == nil {
@@ -233,6 +372,30 @@ func (fs *ARPCFS) StatFS() (types.StatFS, error) {
 	return fsStat, nil
 }
 
+// ChangedFiles asks the agent for the paths changed since the last finished
+// backup of the job, as read from its change journal. The result is not
+// Available when the agent has no usable journal, including agents that
+// predate the call, and the whole tree has to be walked.
+func (fs *ARPCFS) ChangedFiles() (types.ChangedFiles, error) {
+	if fs.session == nil {
+		return types.ChangedFiles{}, syscall.EIO
+	}
+
+	var changed types.ChangedFiles
+	raw, err := fs.session.CallMsgWithTimeout(1*time.Minute, fs.JobId+"/ChangedFiles", nil)
+	if err != nil {
+		if isMethodNotFound(err) {
+			return types.ChangedFiles{}, nil
+		}
+		return types.ChangedFiles{}, err
+	}
+
+	if err := changed.Decode(raw); err != nil {
+		return types.ChangedFiles{}, err
+	}
+	return changed, nil
+}
+
 var bufPool = sync.Pool{
 	New: func() interface{} {
 		b := make([]byte, 256*1024) // 256KB initial buffer
@@ -272,9 +435,267 @@ func (fs *ARPCFS) ReadDir(path string) (types.ReadDirEntries, error) {
 		return nil, syscall.EIO
 	}
 
-	return resp, nil
+	// Entries the agent could not stat are left out of the listing and
+	// remembered so the backup can report or retry them.
+	entries := resp[:0]
+	for _, entry := range resp {
+		entryPath := filepath.Join(path, entry.Name)
+		if entry.Err != "" {
+			fs.failedPaths.Set(entryPath, entry.Err)
+			syslog.L.Warn().
+				WithMessage("agent failed to read directory entry").
+				WithField("path", entryPath).
+				WithField("error", entry.Err).
+				Write()
+			continue
+		}
+		fs.failedPaths.Del(entryPath)
+		if fs.excluded(entryPath, entry) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ReadDirChunked lists req.Path through method, a ReadDirStream handler, and
+// hands the entries to fn in batches as they arrive. An error from fn stops
+// the listing.
+func ReadDirChunked(ctx context.Context, session *arpc.Session, method string, req *types.ReadDirStreamReq, fn func(types.ReadDirEntries) error) error {
+	return session.CallStream(ctx, method, req, func(frame []byte) error {
+		var batch types.ReadDirEntries
+		if err := batch.Decode(frame); err != nil {
+			return err
+		}
+		return fn(batch)
+	})
+}
+
+// ReadDirChunked lists path in batches like ReadDir, without holding the
+// whole listing in memory.
+func (fs *ARPCFS) ReadDirChunked(path string, fn func(types.ReadDirEntries) error) error {
+	if fs.session == nil {
+		syslog.L.Error(os.ErrInvalid).
+			WithMessage("arpc session is nil").
+			Write()
+		return syscall.EIO
+	}
+
+	req := types.ReadDirStreamReq{Path: path}
+	err := ReadDirChunked(fs.ctx, fs.session, fs.JobId+"/ReadDirStream", &req, func(batch types.ReadDirEntries) error {
+		entries := batch[:0]
+		for _, entry := range batch {
+			entryPath := filepath.Join(path, entry.Name)
+			if entry.Err != "" {
+				fs.failedPaths.Set(entryPath, entry.Err)
+				continue
+			}
+			fs.failedPaths.Del(entryPath)
+			if fs.excluded(entryPath, entry) {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		return fn(entries)
+	})
+	if err != nil {
+		if arpc.IsOSError(err) {
+			return err
+		}
+		return syscall.EIO
+	}
+	return nil
+}
+
+// FailedPaths returns the paths that were skipped during directory listings
+// because the agent could not read them, mapped to the reported error.
+func (fs *ARPCFS) FailedPaths() map[string]string {
+	failed := make(map[string]string, fs.failedPaths.Len())
+	fs.failedPaths.ForEach(func(path string, errStr string) bool {
+		failed[path] = errStr
+		return true
+	})
+	return failed
 }
 
 func (fs *ARPCFS) Root() string {
 	return fs.basePath
 }
+
+// SetFilter hides the entries m excludes from directory listings, so
+// proxmox-backup-client never sees them. Patterns are matched relative to
+// root, regardless of case when caseInsensitive is set. This is used for the
+// inclusions of a job, and for the regex exclusions the client cannot apply
+// on agents that do not filter their listings themselves.
+func (fs *ARPCFS) SetFilter(m pattern.PathFilter, root string, caseInsensitive bool) {
+	if m == nil {
+		fs.filter.Store(nil)
+		return
+	}
+	fs.filter.Store(pattern.NewRootedMatcher(m, root, caseInsensitive))
+}
+
+func (fs *ARPCFS) excluded(entryPath string, entry types.AgentDirEntry) bool {
+	filter := fs.filter.Load()
+	if filter == nil {
+		return false
+	}
+	if filter.Excluded(entryPath, os.FileMode(entry.Mode).IsDir()) {
+		fs.excludedCount.Add(1)
+		return true
+	}
+	return false
+}
+
+// PushExclusions sends filters to the agent, which then leaves the entries
+// they exclude out of its listings. Patterns are matched relative to root.
+// It reports false when the agent predates the call and the filtering has to
+// stay on this side.
+func (fs *ARPCFS) PushExclusions(filters pattern.EffectiveFilters, root string) (bool, error) {
+	if fs.session == nil {
+		return false, syscall.EIO
+	}
+
+	req := types.ExclusionsReq{Root: root, Rules: filters.Rules}
+	_, err := fs.session.CallMsgWithTimeout(1*time.Minute, fs.JobId+"/SetExclusions", &req)
+	if err != nil {
+		if isMethodNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ExcludedCount returns the number of listed entries the exclusions hid,
+// whether the agent or this side dropped them.
+func (fs *ARPCFS) ExcludedCount() int64 {
+	count := fs.excludedCount.Load()
+	if fs.session == nil {
+		return count
+	}
+
+	raw, err := fs.session.CallMsgWithTimeout(1*time.Minute, fs.JobId+"/ExclusionStats", nil)
+	if err != nil {
+		return count
+	}
+	var stats types.ExclusionStats
+	if err := stats.Decode(raw); err != nil {
+		return count
+	}
+	return count + stats.Excluded
+}
+
+// SetManifest makes the filesystem record every file it opens in m.
+func (fs *ARPCFS) SetManifest(m *Manifest) {
+	fs.manifest.Store(m)
+}
+
+// SetPartialFiles makes the filesystem read the files c matches by changed
+// block only, serving the unchanged ones from c.
+func (fs *ARPCFS) SetPartialFiles(c *PartialFileCache) {
+	fs.partialFiles.Store(c)
+}
+
+// PartialFiles returns the partial file cache, nil when none is set.
+func (fs *ARPCFS) PartialFiles() *PartialFileCache {
+	return fs.partialFiles.Load()
+}
+
+// AttachPartial makes file, of the given size, read through the partial
+// file cache if it is a partial file. Should the cache be unusable, the file
+// is read whole from the agent.
+func (fs *ARPCFS) AttachPartial(file *ARPCFile, size int64) {
+	c := fs.partialFiles.Load()
+	if c == nil || !c.Matches(file.name) {
+		return
+	}
+
+	partial, err := c.open(file.name, size)
+	if err != nil {
+		syslog.L.Warn().
+			WithMessage("failed to open partial file cache, reading the whole file").
+			WithField("path", file.name).
+			WithField("error", err.Error()).
+			Write()
+		return
+	}
+	file.partial = partial
+}
+
+// CloseManifest stops recording and flushes the manifest, if one is kept.
+func (fs *ARPCFS) CloseManifest() error {
+	m := fs.manifest.Swap(nil)
+	if m == nil {
+		return nil
+	}
+	return m.Close()
+}
+
+// RecordFile adds an opened file to the manifest, if one is kept. data holds
+// the content of files read whole. The hash of a file read through file, if
+// wanted, is computed from the reads of the backup client and the entry is
+// added once file is closed.
+func (fs *ARPCFS) RecordFile(filename string, size int64, modTime time.Time, data []byte, file *ARPCFile) {
+	m := fs.manifest.Load()
+	if m == nil || m.Has(filename) {
+		return
+	}
+
+	entry := ManifestEntry{
+		Path:    filename,
+		Size:    size,
+		ModTime: modTime.Unix(),
+	}
+
+	if m.Hash() {
+		switch {
+		case data != nil:
+			sum := sha256.Sum256(data)
+			entry.SHA256 = hex.EncodeToString(sum[:])
+		case file != nil:
+			file.record = &pendingRecord{entry: entry, hash: newStreamHash()}
+			return
+		}
+	}
+
+	fs.addRecord(m, entry)
+}
+
+// finishRecord adds the pending manifest entry of file, which is about to be
+// closed. Should the reads of the backup client not have covered the file
+// exactly, the agent hashes it instead.
+func (fs *ARPCFS) finishRecord(file *ARPCFile) {
+	record := file.record
+	file.record = nil
+
+	m := fs.manifest.Load()
+	if m == nil {
+		return
+	}
+
+	entry := record.entry
+	if digest, ok := record.hash.sum(entry.Size); ok {
+		entry.SHA256 = hex.EncodeToString(digest)
+	} else if digest, _, err := file.HashAt(0, entry.Size, types.HashSHA256); err != nil {
+		syslog.L.Warn().
+			WithMessage("failed to hash file for manifest").
+			WithField("path", entry.Path).
+			WithField("error", err.Error()).
+			Write()
+	} else {
+		entry.SHA256 = hex.EncodeToString(digest)
+	}
+
+	fs.addRecord(m, entry)
+}
+
+func (fs *ARPCFS) addRecord(m *Manifest, entry ManifestEntry) {
+	if err := m.Add(entry); err != nil {
+		syslog.L.Error(err).
+			WithMessage("failed to record file in manifest").
+			WithField("path", entry.Path).
+			Write()
+	}
+}
@@ -0,0 +1,365 @@
+//go:build linux
+
+package arpcfs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/snapshots"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestARPCFS serves dir with an agentfs server over an in-memory pipe and
+// returns an ARPCFS connected to it.
+func newTestARPCFS(t *testing.T, dir string) *ARPCFS {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	serverRouter := arpc.NewRouter()
+	agentFsServer := agentfs.NewAgentFSServer("agentFs", snapshots.Snapshot{Path: dir, SourcePath: ""}, agentfs.AgentFSOptions{})
+	agentFsServer.RegisterHandlers(&serverRouter)
+	t.Cleanup(agentFsServer.Close)
+
+	serverSession, err := arpc.NewServerSession(serverConn, nil)
+	require.NoError(t, err)
+	serverSession.SetRouter(serverRouter)
+	go func() {
+		_ = serverSession.Serve()
+	}()
+	t.Cleanup(func() { _ = serverSession.Close() })
+
+	clientSession, err := arpc.NewClientSession(clientConn, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = clientSession.Close() })
+
+	return NewARPCFS(ctx, clientSession, "host", "agentFs", "")
+}
+
+func TestReadDirChunkedLargeDirectory(t *testing.T) {
+	const fileCount = 100_000
+
+	testDir := t.TempDir()
+	for i := 0; i < fileCount; i++ {
+		file, err := os.Create(filepath.Join(testDir, fmt.Sprintf("file-%06d", i)))
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+	}
+
+	fs := newTestARPCFS(t, testDir)
+
+	t.Run("DefaultChunkSize", func(t *testing.T) {
+		seen := make(map[string]int, fileCount)
+		largestBatch := 0
+		err := fs.ReadDirChunked(".", func(batch types.ReadDirEntries) error {
+			largestBatch = max(largestBatch, len(batch))
+			for _, entry := range batch {
+				seen[entry.Name]++
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		// Memory on either side is bounded by the batch, not the listing.
+		assert.LessOrEqual(t, largestBatch, types.ReadDirChunkSize)
+		require.Len(t, seen, fileCount)
+		for i := 0; i < fileCount; i++ {
+			name := fmt.Sprintf("file-%06d", i)
+			require.Equal(t, 1, seen[name], name)
+		}
+	})
+
+	t.Run("CustomChunkSize", func(t *testing.T) {
+		const chunkSize = 1000
+
+		total := 0
+		batches := 0
+		req := types.ReadDirStreamReq{Path: ".", ChunkSize: chunkSize}
+		err := ReadDirChunked(context.Background(), fs.session, "agentFs/ReadDirStream", &req, func(batch types.ReadDirEntries) error {
+			require.LessOrEqual(t, len(batch), chunkSize)
+			total += len(batch)
+			batches++
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, fileCount, total)
+		assert.GreaterOrEqual(t, batches, fileCount/chunkSize)
+	})
+
+	t.Run("CallbackErrorStopsListing", func(t *testing.T) {
+		stop := fmt.Errorf("stop")
+		calls := 0
+		err := fs.ReadDirChunked(".", func(batch types.ReadDirEntries) error {
+			calls++
+			return stop
+		})
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("MissingDirectory", func(t *testing.T) {
+		err := fs.ReadDirChunked("missing", func(batch types.ReadDirEntries) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestReadDirFilter(t *testing.T) {
+	testDir := t.TempDir()
+	for _, name := range []string{"src/a.go", "src/a.go.bak", "src/keep/b.bak", "logs/x.log", "other/c.bak"} {
+		path := filepath.Join(testDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	fs := newTestARPCFS(t, testDir)
+
+	matcher, err := pattern.NewMatcher(pattern.ResolvePatterns(nil, []pattern.Pattern{
+		{Value: `\.bak$`, MatchType: pattern.MatchRegex},
+		{Value: `!^/src/keep/`, MatchType: pattern.MatchRegex},
+		{Value: "/logs"},
+	}, false))
+	require.NoError(t, err)
+
+	// Patterns are relative to the backup source, the drive root here.
+	fs.SetFilter(matcher, "", false)
+
+	names := func(dir string) []string {
+		entries, err := fs.ReadDir(dir)
+		require.NoError(t, err)
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Name)
+		}
+		return result
+	}
+
+	assert.ElementsMatch(t, []string{"src", "other"}, names("."))
+	assert.ElementsMatch(t, []string{"a.go", "keep"}, names("src"))
+	assert.ElementsMatch(t, []string{"b.bak"}, names("src/keep"))
+
+	var chunked []string
+	require.NoError(t, fs.ReadDirChunked("src", func(batch types.ReadDirEntries) error {
+		for _, entry := range batch {
+			chunked = append(chunked, entry.Name)
+		}
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"a.go", "keep"}, chunked)
+
+	// With a subpath, patterns are relative to it and paths outside it are
+	// left alone.
+	fs.SetFilter(matcher, "src", false)
+	assert.ElementsMatch(t, []string{"a.go", "keep"}, names("src"))
+	assert.Empty(t, names("src/keep"))
+	assert.ElementsMatch(t, []string{"c.bak"}, names("other"))
+
+	fs.SetFilter(nil, "", false)
+	assert.ElementsMatch(t, []string{"src", "logs", "other"}, names("."))
+}
+
+func TestPushExclusions(t *testing.T) {
+	testDir := t.TempDir()
+	for _, name := range []string{"src/a.go", "src/a.go.bak", "src/keep/b.bak", "logs/x.log", "other/c.bak"} {
+		path := filepath.Join(testDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	fs := newTestARPCFS(t, testDir)
+
+	filters := pattern.ResolvePatterns(nil, []pattern.Pattern{
+		{Value: `\.bak$`, MatchType: pattern.MatchRegex},
+		{Value: `!^/src/keep/`, MatchType: pattern.MatchRegex},
+		{Value: "/logs"},
+	}, false)
+	pushed, err := fs.PushExclusions(filters, "")
+	require.NoError(t, err)
+	require.True(t, pushed)
+
+	// Listings are read straight off the wire, with no filtering on this
+	// side.
+	wire := func(dir string) []string {
+		buf := make([]byte, 64*1024)
+		n, err := fs.session.CallBinary(context.Background(), "agentFs/ReadDir", &types.ReadDirReq{Path: dir}, buf)
+		require.NoError(t, err)
+		var entries types.ReadDirEntries
+		require.NoError(t, entries.Decode(buf[:n]))
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Name)
+		}
+		return result
+	}
+	wireChunked := func(dir string) []string {
+		var result []string
+		req := types.ReadDirStreamReq{Path: dir}
+		require.NoError(t, ReadDirChunked(context.Background(), fs.session, "agentFs/ReadDirStream", &req, func(batch types.ReadDirEntries) error {
+			for _, entry := range batch {
+				result = append(result, entry.Name)
+			}
+			return nil
+		}))
+		return result
+	}
+
+	assert.ElementsMatch(t, []string{"src", "other"}, wire("."))
+	assert.ElementsMatch(t, []string{"a.go", "keep"}, wire("src"))
+	assert.ElementsMatch(t, []string{"b.bak"}, wire("src/keep"))
+	assert.ElementsMatch(t, []string{"a.go", "keep"}, wireChunked("src"))
+	assert.Empty(t, wireChunked("other"))
+
+	// logs, src/a.go.bak twice and other/c.bak.
+	assert.Equal(t, int64(4), fs.ExcludedCount())
+
+	// Clearing the rules lists everything again.
+	pushed, err = fs.PushExclusions(pattern.EffectiveFilters{}, "")
+	require.NoError(t, err)
+	require.True(t, pushed)
+	assert.ElementsMatch(t, []string{"src", "logs", "other"}, wire("."))
+	assert.Equal(t, int64(4), fs.ExcludedCount())
+}
+
+func TestPushExclusionsLargeRuleSet(t *testing.T) {
+	testDir := t.TempDir()
+	for _, name := range []string{"keep.txt", "cache-199/x", "drop.tmp"} {
+		path := filepath.Join(testDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	fs := newTestARPCFS(t, testDir)
+
+	// Realistic exclusion lists are far larger than a single 4 KB read.
+	var job []pattern.Pattern
+	for i := 0; i < 200; i++ {
+		job = append(job, pattern.Pattern{Value: fmt.Sprintf("/cache-%d", i)})
+	}
+	job = append(job, pattern.Pattern{Value: `\.tmp$`, MatchType: pattern.MatchRegex})
+	filters := pattern.ResolvePatterns(nil, job, false)
+
+	req := types.ExclusionsReq{Rules: filters.Rules}
+	encoded, err := req.Encode()
+	require.NoError(t, err)
+	require.Greater(t, len(encoded), 4096)
+
+	pushed, err := fs.PushExclusions(filters, "")
+	require.NoError(t, err)
+	require.True(t, pushed)
+
+	buf := make([]byte, 64*1024)
+	n, err := fs.session.CallBinary(context.Background(), "agentFs/ReadDir", &types.ReadDirReq{Path: "."}, buf)
+	require.NoError(t, err)
+	var entries types.ReadDirEntries
+	require.NoError(t, entries.Decode(buf[:n]))
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	assert.ElementsMatch(t, []string{"keep.txt"}, names)
+}
+
+func TestAttrBatch(t *testing.T) {
+	testDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "a.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(testDir, "dir"), 0755))
+
+	fs := newTestARPCFS(t, testDir)
+
+	infos, errs, err := fs.AttrBatch([]string{"a.txt", "missing", "dir"})
+	require.NoError(t, err, "a missing path must not fail the batch")
+	require.Len(t, infos, 3)
+	require.Len(t, errs, 3)
+
+	require.NoError(t, errs[0])
+	assert.Equal(t, "a.txt", infos[0].Name)
+	assert.Equal(t, int64(5), infos[0].Size)
+
+	assert.True(t, os.IsNotExist(errs[1]), "got %v", errs[1])
+
+	require.NoError(t, errs[2])
+	assert.Equal(t, "dir", infos[2].Name)
+	assert.True(t, infos[2].IsDir)
+
+	stats := fs.GetStats()
+	assert.Equal(t, int64(1), stats.FilesAccessed)
+	assert.Equal(t, int64(1), stats.FoldersAccessed)
+}
+
+func TestAttrBatchLongPaths(t *testing.T) {
+	testDir := t.TempDir()
+
+	// A full default batch of long names does not fit in a single 4 KB read.
+	paths := make([]string, DefaultStatBatchSize)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("%02d-%s.txt", i, strings.Repeat("n", 80))
+		require.NoError(t, os.WriteFile(filepath.Join(testDir, paths[i]), []byte("hello"), 0644))
+	}
+
+	fs := newTestARPCFS(t, testDir)
+
+	infos, errs, err := fs.AttrBatch(paths)
+	require.NoError(t, err)
+	require.Len(t, infos, len(paths))
+	for i, path := range paths {
+		require.NoError(t, errs[i], path)
+		assert.Equal(t, path, infos[i].Name)
+		assert.Equal(t, int64(5), infos[i].Size)
+	}
+}
+
+func TestAttrBatched(t *testing.T) {
+	testDir := t.TempDir()
+	const fileCount = 50
+	for i := 0; i < fileCount; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(testDir, fmt.Sprintf("file-%02d", i)), make([]byte, i), 0644))
+	}
+
+	fs := newTestARPCFS(t, testDir)
+	fs.SetStatBatch(8, 5*time.Millisecond)
+
+	type result struct {
+		info types.AgentFileInfo
+		err  error
+	}
+	results := make([]result, fileCount+1)
+	var wg sync.WaitGroup
+	for i := range results {
+		name := fmt.Sprintf("file-%02d", i)
+		if i == fileCount {
+			name = "missing"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := fs.Attr(name)
+			results[i] = result{info, err}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < fileCount; i++ {
+		require.NoError(t, results[i].err)
+		assert.Equal(t, fmt.Sprintf("file-%02d", i), results[i].info.Name)
+		assert.Equal(t, int64(i), results[i].info.Size)
+	}
+	assert.True(t, os.IsNotExist(results[fileCount].err), "got %v", results[fileCount].err)
+	assert.Equal(t, int64(fileCount), fs.GetStats().FilesAccessed)
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -17,6 +18,7 @@ import (
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
 	arpcfs "github.com/sonroyaalmerol/pbs-plus/internal/backend/arpc"
+	"golang.org/x/sys/unix"
 )
 
 var nodePool = &sync.Pool{
@@ -43,6 +45,7 @@ func newRoot(fs *arpcfs.ARPCFS) fs.InodeEmbedder {
 	rootNode.fullPathCache = ""
 	rootNode.name = ""
 	rootNode.parent = nil
+	rootNode.attr = nodeAttr{}
 	return rootNode
 }
 
@@ -84,6 +87,33 @@ type Node struct {
 	name          string
 	fullPathCache string
 	parent        *Node
+
+	// attr holds the attributes last reported by the agent. Getattr and
+	// Lookup update it while Open reads it, so it is guarded by attrMu.
+	attrMu sync.Mutex
+	attr   nodeAttr
+}
+
+// nodeAttr is what Open needs to know about a file before opening it.
+type nodeAttr struct {
+	// size is the file size, used to pick the read path.
+	size int64
+	// modTime is the modification time.
+	modTime time.Time
+	// known is false until the agent reported the attributes at least once.
+	known bool
+}
+
+func (n *Node) setAttr(size int64, modTime time.Time) {
+	n.attrMu.Lock()
+	n.attr = nodeAttr{size: size, modTime: modTime, known: true}
+	n.attrMu.Unlock()
+}
+
+func (n *Node) getAttr() nodeAttr {
+	n.attrMu.Lock()
+	defer n.attrMu.Unlock()
+	return n.attr
 }
 
 func (n *Node) getPath() string {
@@ -146,6 +176,7 @@ var _ = (fs.NodeAccesser)((*Node)(nil))
 var _ = (fs.NodeOpendirer)((*Node)(nil))
 var _ = (fs.NodeReleaser)((*Node)(nil))
 var _ = (fs.NodeStatxer)((*Node)(nil))
+var _ = (fs.NodeReadlinker)((*Node)(nil))
 
 func (n *Node) Access(ctx context.Context, mask uint32) syscall.Errno {
 	// For read-only filesystem, deny write access (bit 1)
@@ -212,6 +243,16 @@ func (n *Node) Statx(ctx context.Context, f fs.FileHandle, flags uint32, mask ui
 	return 0
 }
 
+// Readlink implements NodeReadlinker for the links of agents that list
+// them as links.
+func (n *Node) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	target, err := n.fs.Readlink(n.getPath())
+	if err != nil {
+		return nil, fs.ToErrno(err)
+	}
+	return []byte(target), 0
+}
+
 // Getattr implements NodeGetattrer
 func (n *Node) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	fi, err := n.fs.Attr(n.getPath())
@@ -228,6 +269,8 @@ func (n *Node) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut)
 		mode |= syscall.S_IFREG
 	}
 
+	n.setAttr(fi.Size, fi.ModTime)
+
 	out.Mode = mode
 	out.Size = uint64(fi.Size)
 	out.Blocks = fi.Blocks
@@ -240,7 +283,24 @@ func (n *Node) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut)
 	return 0
 }
 
+// adsXattrPrefix prefixes the extended attributes holding the alternate
+// data streams of a file, so the backup records them with the file. Streams
+// larger than arpcfs.SmallFileThreshold are not exposed: they would not fit
+// the size limit of an extended attribute.
+const adsXattrPrefix = "user.ads."
+
 func (n *Node) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	if stream, ok := strings.CutPrefix(attr, adsXattrPrefix); ok {
+		data, err := n.fs.ReadFile(n.getPath() + ":" + stream)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return 0, syscall.ENODATA
+			}
+			return 0, fs.ToErrno(err)
+		}
+		return copyXattr(data, dest)
+	}
+
 	fi, err := n.fs.Xattr(n.getPath())
 	if err != nil {
 		return 0, fs.ToErrno(err)
@@ -281,6 +341,12 @@ func (n *Node) Getxattr(ctx context.Context, attr string, dest []byte) (uint32,
 		return 0, syscall.ENODATA
 	}
 
+	return copyXattr(data, dest)
+}
+
+// copyXattr copies the value of an extended attribute to dest. A nil dest
+// only asks for the length.
+func copyXattr(data []byte, dest []byte) (uint32, syscall.Errno) {
 	length := uint32(len(data))
 
 	if dest == nil {
@@ -317,6 +383,16 @@ func (n *Node) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errn
 		attrs = append(attrs, "user.acls")
 	}
 
+	streams, err := n.fs.Streams(n.getPath())
+	if err != nil {
+		return 0, fs.ToErrno(err)
+	}
+	for _, stream := range streams {
+		if stream.Size <= arpcfs.SmallFileThreshold {
+			attrs = append(attrs, adsXattrPrefix+stream.Name)
+		}
+	}
+
 	// Create the null-terminated list of attribute names.
 	var list []byte
 	for _, attr := range attrs {
@@ -354,6 +430,7 @@ func (n *Node) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs
 	if err != nil {
 		return nil, fs.ToErrno(err)
 	}
+	childNode.setAttr(fi.Size, fi.ModTime)
 
 	mode := fi.Mode
 	if fi.IsDir {
@@ -416,10 +493,30 @@ func (n *Node) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 
 // Open implements NodeOpener
 func (n *Node) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	attr := n.getAttr()
+
+	// Small files are fetched whole in a single round trip and served from
+	// memory. If that fails (e.g. the file grew), fall back to a handle.
+	// Without a known size the file may be of any size, so it goes through
+	// a handle right away.
+	if attr.known && attr.size <= arpcfs.SmallFileThreshold {
+		if data, err := n.fs.ReadFile(n.getPath()); err == nil {
+			n.fs.RecordFile(n.getPath(), int64(len(data)), attr.modTime, data, nil)
+			return &FileHandle{
+				fs:   n.fs,
+				data: data,
+			}, 0, 0
+		}
+	}
+
 	file, err := n.fs.OpenFile(n.getPath(), int(flags), 0)
 	if err != nil {
 		return nil, 0, fs.ToErrno(err)
 	}
+	if attr.known {
+		n.fs.AttachPartial(&file, attr.size)
+	}
+	n.fs.RecordFile(n.getPath(), attr.size, attr.modTime, nil, &file)
 
 	return &FileHandle{
 		fs:   n.fs,
@@ -445,10 +542,12 @@ func (n *Node) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
 	return 0
 }
 
-// FileHandle handles file operations
+// FileHandle handles file operations. Small files have no remote handle;
+// file is nil and their whole content is held in data.
 type FileHandle struct {
 	fs   *arpcfs.ARPCFS
 	file *arpcfs.ARPCFile
+	data []byte
 }
 
 var _ = (fs.FileReader)((*FileHandle)(nil))
@@ -457,6 +556,14 @@ var _ = (fs.FileLseeker)((*FileHandle)(nil))
 
 // Read implements FileReader
 func (fh *FileHandle) Read(ctx context.Context, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	if fh.file == nil {
+		if offset >= int64(len(fh.data)) {
+			return fuse.ReadResultData(nil), 0
+		}
+		end := min(offset+int64(len(dest)), int64(len(fh.data)))
+		return fuse.ReadResultData(fh.data[offset:end]), 0
+	}
+
 	n, err := fh.file.ReadAt(dest, offset)
 	if err != nil && err != io.EOF {
 		return nil, fs.ToErrno(err)
@@ -466,6 +573,10 @@ func (fh *FileHandle) Read(ctx context.Context, dest []byte, offset int64) (fuse
 }
 
 func (fh *FileHandle) Lseek(ctx context.Context, off uint64, whence uint32) (uint64, syscall.Errno) {
+	if fh.file == nil {
+		return lseekInMemory(uint64(len(fh.data)), off, whence)
+	}
+
 	n, err := fh.file.Lseek(int64(off), int(whence))
 	if err != nil && err != io.EOF {
 		return 0, fs.ToErrno(err)
@@ -475,6 +586,31 @@ func (fh *FileHandle) Lseek(ctx context.Context, off uint64, whence uint32) (uin
 }
 
 func (fh *FileHandle) Release(ctx context.Context) syscall.Errno {
+	if fh.file == nil {
+		fh.data = nil
+		return 0
+	}
+
 	err := fh.file.Close()
 	return fs.ToErrno(err)
 }
+
+// lseekInMemory resolves a seek on a file held in memory, which has no holes.
+func lseekInMemory(size uint64, off uint64, whence uint32) (uint64, syscall.Errno) {
+	switch whence {
+	case unix.SEEK_DATA:
+		if off >= size {
+			return 0, syscall.ENXIO
+		}
+		return off, 0
+	case unix.SEEK_HOLE:
+		if off >= size {
+			return 0, syscall.ENXIO
+		}
+		return size, 0
+	case io.SeekEnd:
+		return size + off, 0
+	default:
+		return off, 0
+	}
+}
@@ -0,0 +1,216 @@
+package arpcfs
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"hash"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultManifestLimit bounds the number of files a manifest records. Files
+// past the limit are counted but not listed.
+const DefaultManifestLimit = 5_000_000
+
+// PendingManifestName is the file a manifest is written to while the backup
+// runs, inside the log directory of the job. It is renamed after the run
+// with ManifestName.
+const PendingManifestName = "manifest.pending.gz"
+
+// ManifestName returns the file name of the manifest of the run upid.
+func ManifestName(upid string) string {
+	return upid + ".manifest.gz"
+}
+
+// ManifestEntry describes one file presented to the backup client.
+type ManifestEntry struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+// manifestTrailer closes a manifest that hit its limit.
+type manifestTrailer struct {
+	Truncated bool  `json:"truncated"`
+	Omitted   int64 `json:"omitted"`
+}
+
+// Manifest writes a gzip-compressed list of backed-up files, one JSON object
+// per line. Each path is recorded once, however often it is opened.
+type Manifest struct {
+	mu      sync.Mutex
+	file    *os.File
+	gz      *gzip.Writer
+	enc     *json.Encoder
+	hash    bool
+	limit   int
+	seen    map[string]struct{}
+	omitted int64
+	closed  bool
+}
+
+// NewManifest creates the manifest file at path. When hash is set, entries
+// carry the SHA-256 of the file content. A limit of 0 or less uses
+// DefaultManifestLimit.
+func NewManifest(path string, hash bool, limit int) (*Manifest, error) {
+	if limit <= 0 {
+		limit = DefaultManifestLimit
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(file)
+	return &Manifest{
+		file:  file,
+		gz:    gz,
+		enc:   json.NewEncoder(gz),
+		hash:  hash,
+		limit: limit,
+		seen:  make(map[string]struct{}),
+	}, nil
+}
+
+// Hash reports whether entries should carry a content hash.
+func (m *Manifest) Hash() bool {
+	return m.hash
+}
+
+// Has reports whether path was already recorded.
+func (m *Manifest) Has(path string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.seen[path]
+	return ok
+}
+
+// Add records entry unless its path was recorded before.
+func (m *Manifest) Add(entry ManifestEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return os.ErrClosed
+	}
+	if _, ok := m.seen[entry.Path]; ok {
+		return nil
+	}
+	if len(m.seen) >= m.limit {
+		m.omitted++
+		return nil
+	}
+
+	m.seen[entry.Path] = struct{}{}
+	return m.enc.Encode(entry)
+}
+
+// Close writes the trailer, if the limit was hit, and flushes the manifest.
+func (m *Manifest) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	m.seen = nil
+
+	var errs []error
+	if m.omitted > 0 {
+		errs = append(errs, m.enc.Encode(manifestTrailer{Truncated: true, Omitted: m.omitted}))
+	}
+	errs = append(errs, m.gz.Close(), m.file.Close())
+	return errors.Join(errs...)
+}
+
+// maxPendingHash bounds the bytes a streamHash holds for reads that arrive
+// ahead of the hashed prefix.
+const maxPendingHash = 8 << 20
+
+// streamHash computes the SHA-256 of a file from the reads the backup client
+// makes, so the manifest costs no extra pass over the data. Reads may arrive
+// out of order: those past the hashed prefix are held until the gap is
+// filled, and the hash gives up once too much is held.
+type streamHash struct {
+	mu           sync.Mutex
+	h            hash.Hash
+	next         int64
+	pending      map[int64][]byte
+	pendingBytes int
+	broken       bool
+}
+
+func newStreamHash() *streamHash {
+	return &streamHash{
+		h:       sha256.New(),
+		pending: make(map[int64][]byte),
+	}
+}
+
+// write feeds the n bytes of p read at off.
+func (s *streamHash) write(p []byte, off int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.broken {
+		return
+	}
+	if off > s.next {
+		if s.pendingBytes+len(p) > maxPendingHash {
+			s.broken = true
+			s.pending = nil
+			return
+		}
+		if held, ok := s.pending[off]; ok && len(held) >= len(p) {
+			return
+		}
+		s.pendingBytes += len(p) - len(s.pending[off])
+		s.pending[off] = append([]byte(nil), p...)
+		return
+	}
+	s.consume(p, off)
+
+	for progressed := true; progressed; {
+		progressed = false
+		for heldOff, held := range s.pending {
+			if heldOff > s.next {
+				continue
+			}
+			s.consume(held, heldOff)
+			s.pendingBytes -= len(held)
+			delete(s.pending, heldOff)
+			progressed = true
+		}
+	}
+}
+
+// consume hashes the part of p, read at off <= s.next, past the prefix.
+func (s *streamHash) consume(p []byte, off int64) {
+	end := off + int64(len(p))
+	if end <= s.next {
+		return
+	}
+	s.h.Write(p[s.next-off:])
+	s.next = end
+}
+
+// sum returns the digest if exactly the first size bytes were fed.
+func (s *streamHash) sum(size int64) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.broken || s.next != size || len(s.pending) > 0 {
+		return nil, false
+	}
+	return s.h.Sum(nil), true
+}
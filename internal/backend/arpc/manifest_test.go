@@ -0,0 +1,228 @@
+//go:build linux
+
+package arpcfs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readManifest decodes a manifest file into its entries and trailer.
+func readManifest(t *testing.T, path string) ([]ManifestEntry, manifestTrailer) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	defer gz.Close()
+
+	var entries []ManifestEntry
+	var trailer manifestTrailer
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if strings.Contains(string(line), `"truncated"`) {
+			require.NoError(t, json.Unmarshal(line, &trailer))
+			continue
+		}
+		var entry ManifestEntry
+		require.NoError(t, json.Unmarshal(line, &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+
+	return entries, trailer
+}
+
+func TestManifestMatchesPresentedFiles(t *testing.T) {
+	testDir := t.TempDir()
+
+	files := map[string][]byte{
+		"small.txt":          []byte("small file content"),
+		"nested/medium.bin":  make([]byte, 200*1024),
+		"nested/deep/a.txt":  []byte("a"),
+		"nested/deep/empty":  {},
+		"another/large.data": make([]byte, 2*1024*1024+17),
+	}
+	for name, content := range files {
+		for i := range content {
+			content[i] = byte(i % 251)
+		}
+		path := filepath.Join(testDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, content, 0644))
+	}
+
+	fs := newTestARPCFS(t, testDir)
+
+	manifestPath := filepath.Join(t.TempDir(), PendingManifestName)
+	manifest, err := NewManifest(manifestPath, true, 0)
+	require.NoError(t, err)
+	fs.SetManifest(manifest)
+
+	// Walk the tree and open every file the way the FUSE layer does for the
+	// backup client, keeping what was presented.
+	presented := make(map[string][]byte)
+	var walk func(dir string)
+	walk = func(dir string) {
+		entries, err := fs.ReadDir(dir)
+		require.NoError(t, err)
+
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name)
+			fi, err := fs.Attr(path)
+			require.NoError(t, err)
+			if fi.IsDir {
+				walk(path)
+				continue
+			}
+
+			if fi.Size <= SmallFileThreshold {
+				data, err := fs.ReadFile(path)
+				require.NoError(t, err)
+				fs.RecordFile(path, int64(len(data)), fi.ModTime, data, nil)
+				presented[path] = data
+				continue
+			}
+
+			file, err := fs.OpenFile(path, os.O_RDONLY, 0)
+			require.NoError(t, err)
+			fs.RecordFile(path, fi.Size, fi.ModTime, nil, &file)
+
+			data := make([]byte, fi.Size)
+			n, err := file.ReadAt(data, 0)
+			if err != io.EOF {
+				require.NoError(t, err)
+			}
+			require.NoError(t, file.Close())
+			presented[path] = data[:n]
+		}
+	}
+	walk(".")
+
+	// Opening a file again does not duplicate its entry.
+	fs.RecordFile("small.txt", int64(len(files["small.txt"])), time.Now(), files["small.txt"], nil)
+
+	require.NoError(t, fs.CloseManifest())
+
+	entries, trailer := readManifest(t, manifestPath)
+	assert.False(t, trailer.Truncated)
+	require.Len(t, entries, len(presented))
+
+	for _, entry := range entries {
+		data, ok := presented[entry.Path]
+		require.True(t, ok, "unexpected manifest entry %s", entry.Path)
+
+		info, err := os.Stat(filepath.Join(testDir, entry.Path))
+		require.NoError(t, err)
+
+		sum := sha256.Sum256(data)
+		assert.Equal(t, int64(len(data)), entry.Size, entry.Path)
+		assert.Equal(t, info.ModTime().Unix(), entry.ModTime, entry.Path)
+		assert.Equal(t, hex.EncodeToString(sum[:]), entry.SHA256, entry.Path)
+		assert.Equal(t, files[entry.Path], data, entry.Path)
+	}
+}
+
+func TestManifestLimit(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), PendingManifestName)
+	manifest, err := NewManifest(manifestPath, false, 2)
+	require.NoError(t, err)
+
+	for _, path := range []string{"a", "b", "a", "c", "d"} {
+		require.NoError(t, manifest.Add(ManifestEntry{Path: path, Size: 1}))
+	}
+	require.NoError(t, manifest.Close())
+
+	entries, trailer := readManifest(t, manifestPath)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "a", entries[0].Path)
+	assert.Equal(t, "b", entries[1].Path)
+	assert.Empty(t, entries[0].SHA256)
+	assert.True(t, trailer.Truncated)
+	assert.Equal(t, int64(2), trailer.Omitted)
+
+	assert.ErrorIs(t, manifest.Add(ManifestEntry{Path: "e"}), os.ErrClosed)
+}
+
+func TestStreamHash(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	want := sha256.Sum256(data)
+
+	// Reads arriving out of order and overlapping still hash the content.
+	h := newStreamHash()
+	h.write(data[500:800], 500)
+	h.write(data[200:500], 200)
+	h.write(data[0:300], 0)
+	h.write(data[800:], 800)
+	digest, ok := h.sum(int64(len(data)))
+	require.True(t, ok)
+	assert.Equal(t, want[:], digest)
+
+	// A gap leaves the hash incomplete.
+	h = newStreamHash()
+	h.write(data[:400], 0)
+	h.write(data[600:], 600)
+	_, ok = h.sum(int64(len(data)))
+	assert.False(t, ok)
+
+	// Holding too much ahead of the prefix gives up.
+	h = newStreamHash()
+	h.write(make([]byte, maxPendingHash+1), 1)
+	h.write(data, 0)
+	_, ok = h.sum(int64(len(data)))
+	assert.False(t, ok)
+}
+
+func TestManifestHashOfPartlyReadFile(t *testing.T) {
+	testDir := t.TempDir()
+	content := make([]byte, 200*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "file.bin"), content, 0644))
+
+	fs := newTestARPCFS(t, testDir)
+
+	manifestPath := filepath.Join(t.TempDir(), PendingManifestName)
+	manifest, err := NewManifest(manifestPath, true, 0)
+	require.NoError(t, err)
+	fs.SetManifest(manifest)
+
+	fi, err := fs.Attr("file.bin")
+	require.NoError(t, err)
+	file, err := fs.OpenFile("file.bin", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	fs.RecordFile("file.bin", fi.Size, fi.ModTime, nil, &file)
+
+	// Nothing is recorded until the file is closed, and a file read only in
+	// part is hashed by the agent.
+	assert.False(t, manifest.Has("file.bin"))
+	_, err = file.ReadAt(make([]byte, 4096), 0)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	require.NoError(t, fs.CloseManifest())
+
+	entries, _ := readManifest(t, manifestPath)
+	require.Len(t, entries, 1)
+	sum := sha256.Sum256(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), entries[0].SHA256)
+}
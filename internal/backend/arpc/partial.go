@@ -0,0 +1,266 @@
+//go:build linux
+
+package arpcfs
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+	"github.com/zeebo/xxh3"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultPartialBlockSize is the granularity at which a partial file is
+// compared with its content of the previous run.
+const DefaultPartialBlockSize = 1 << 20
+
+// PartialFileCache keeps, on this side, the content the previous run read of
+// the files a job lists as partial files, along with the digest of every
+// block. Such files, typically disk images or databases, change in place a
+// few regions at a time: on the next run only the blocks whose digest on the
+// agent differs are transferred, the others are served from the cache.
+type PartialFileCache struct {
+	dir       string
+	blockSize int64
+	matcher   *pattern.RootedMatcher
+
+	// Bytes served from the cache instead of read from the agent.
+	reused atomic.Int64
+}
+
+// NewPartialFileCache keeps the partial files of a job in dir. A file is
+// partial when m matches its path relative to root, compared regardless of
+// case when caseInsensitive is set. A blockSize of 0 or less uses
+// DefaultPartialBlockSize.
+func NewPartialFileCache(dir string, m *pattern.Matcher, root string, caseInsensitive bool, blockSize int64) *PartialFileCache {
+	if blockSize <= 0 {
+		blockSize = DefaultPartialBlockSize
+	}
+	return &PartialFileCache{
+		dir:       dir,
+		blockSize: blockSize,
+		matcher:   pattern.NewRootedMatcher(m, root, caseInsensitive),
+	}
+}
+
+// Matches reports whether the file at name is a partial file.
+func (c *PartialFileCache) Matches(name string) bool {
+	return c.matcher.Excluded(name, false)
+}
+
+// ReusedBytes returns how many bytes were served from the cache rather than
+// read from the agent.
+func (c *PartialFileCache) ReusedBytes() int64 {
+	return c.reused.Load()
+}
+
+// partialIndex lists the blocks of a cached file. It is written when the
+// file is closed and removed while the file is open, so a run that did not
+// finish leaves no index vouching for half-written data.
+type partialIndex struct {
+	Path      string         `json:"path"`
+	BlockSize int64          `json:"block_size"`
+	Blocks    []partialBlock `json:"blocks"`
+}
+
+type partialBlock struct {
+	Length int64  `json:"len"`
+	Digest string `json:"xxh3,omitempty"`
+	Hole   bool   `json:"hole,omitempty"`
+}
+
+// partialFile serves the reads of one open partial file.
+type partialFile struct {
+	mu        sync.Mutex
+	cache     *PartialFileCache
+	name      string
+	indexPath string
+	data      *os.File
+	size      int64
+	previous  []partialBlock
+	current   []partialBlock
+	resolved  []bool
+}
+
+func (c *PartialFileCache) open(name string, size int64) (*partialFile, error) {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	base := filepath.Join(c.dir, fmt.Sprintf("%016x", hashPath(name)))
+	pf := &partialFile{
+		cache:     c,
+		name:      name,
+		indexPath: base + ".json",
+		size:      size,
+	}
+
+	if raw, err := os.ReadFile(pf.indexPath); err == nil {
+		var index partialIndex
+		if json.Unmarshal(raw, &index) == nil && index.Path == name && index.BlockSize == c.blockSize {
+			pf.previous = index.Blocks
+		}
+	}
+	if err := os.Remove(pf.indexPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	data, err := os.OpenFile(base+".data", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := data.Truncate(size); err != nil {
+		data.Close()
+		return nil, err
+	}
+	pf.data = data
+
+	blocks := (size + c.blockSize - 1) / c.blockSize
+	pf.current = make([]partialBlock, blocks)
+	pf.resolved = make([]bool, blocks)
+
+	return pf, nil
+}
+
+func (pf *partialFile) readAt(f *ARPCFile, p []byte, off int64) (int, error) {
+	if off >= pf.size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), pf.size)
+
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	blockSize := pf.cache.blockSize
+	n := 0
+	for pos := off; pos < end; {
+		block := pos / blockSize
+		if err := pf.resolve(f, block); err != nil {
+			return n, err
+		}
+
+		chunk := min(end, (block+1)*blockSize) - pos
+		dst := p[n : n+int(chunk)]
+		if pf.current[block].Hole {
+			clear(dst)
+		} else if _, err := pf.data.ReadAt(dst, pos); err != nil {
+			return n, err
+		}
+		n += int(chunk)
+		pos += chunk
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// resolve makes the cache hold the current content of block: holes are
+// recorded as such, blocks whose digest on the agent matches the previous
+// run are kept, and the others are read from the agent.
+func (pf *partialFile) resolve(f *ARPCFile, block int64) error {
+	if pf.resolved[block] {
+		return nil
+	}
+
+	start := block * pf.cache.blockSize
+	length := min(pf.cache.blockSize, pf.size-start)
+
+	// A block without data is served as zeros without asking for a digest.
+	if next, err := f.Lseek(start, unix.SEEK_DATA); err == nil && int64(next) >= start+length {
+		pf.current[block] = partialBlock{Length: length, Hole: true}
+		pf.resolved[block] = true
+		return nil
+	}
+
+	if prev := pf.previousBlock(block); prev.Digest != "" && prev.Length == length {
+		digest, hashed, err := f.HashAt(start, length, types.HashXXH3)
+		if err == nil && hashed == length && hex.EncodeToString(digest) == prev.Digest {
+			pf.current[block] = prev
+			pf.resolved[block] = true
+			pf.cache.reused.Add(length)
+			return nil
+		}
+	}
+
+	buf := make([]byte, length)
+	n, err := f.readAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if _, err := pf.data.WriteAt(buf, start); err != nil {
+		return err
+	}
+
+	// A file that shrank while being read is served padded with zeros, and
+	// the block is left without a digest so the next run reads it again.
+	entry := partialBlock{Length: length}
+	if int64(n) == length {
+		h := xxh3.New()
+		h.Write(buf)
+		entry.Digest = hex.EncodeToString(h.Sum(nil))
+	}
+	pf.current[block] = entry
+	pf.resolved[block] = true
+	return nil
+}
+
+func (pf *partialFile) previousBlock(block int64) partialBlock {
+	if block >= int64(len(pf.previous)) {
+		return partialBlock{}
+	}
+	return pf.previous[block]
+}
+
+// close writes the index of the blocks the cache now holds. Blocks this run
+// did not read still hold the data of the previous run.
+func (pf *partialFile) close() error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	if pf.data == nil {
+		return nil
+	}
+
+	index := partialIndex{
+		Path:      pf.name,
+		BlockSize: pf.cache.blockSize,
+		Blocks:    pf.current,
+	}
+	for block, resolved := range pf.resolved {
+		if resolved {
+			continue
+		}
+		prev := pf.previousBlock(int64(block))
+		length := min(pf.cache.blockSize, pf.size-int64(block)*pf.cache.blockSize)
+		if prev.Length == length {
+			index.Blocks[block] = prev
+		}
+	}
+
+	err := pf.data.Close()
+	pf.data = nil
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	tmp := pf.indexPath + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, pf.indexPath)
+}
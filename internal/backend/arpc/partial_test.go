@@ -0,0 +1,132 @@
+//go:build linux
+
+package arpcfs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readPartial reads name whole through the filesystem, the way the FUSE layer
+// does, and returns the content along with the bytes read from the agent.
+func readPartial(t *testing.T, fs *ARPCFS, name string, size int64) ([]byte, int64) {
+	t.Helper()
+
+	before := fs.GetStats().TotalBytes
+
+	file, err := fs.Open(name)
+	require.NoError(t, err)
+	fs.AttachPartial(&file, size)
+	require.NotNil(t, file.partial, "%s should be read as a partial file", name)
+
+	var content bytes.Buffer
+	buf := make([]byte, 128*1024)
+	for off := int64(0); ; {
+		n, err := file.ReadAt(buf, off)
+		content.Write(buf[:n])
+		off += int64(n)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.NoError(t, file.Close())
+
+	return content.Bytes(), int64(fs.GetStats().TotalBytes - before)
+}
+
+func TestPartialFileReadsChangedBlocksOnly(t *testing.T) {
+	const (
+		blockSize = 1 << 20
+		size      = 16 * blockSize
+	)
+
+	testDir := t.TempDir()
+	path := filepath.Join(testDir, "disk.img")
+	original := make([]byte, size)
+	_, err := rand.Read(original)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, original, 0644))
+
+	matcher, err := pattern.NewMatcher(pattern.ResolveFilters(nil, []string{"*.img"}, false))
+	require.NoError(t, err)
+	cache := NewPartialFileCache(t.TempDir(), matcher, "", false, blockSize)
+
+	fs := newTestARPCFS(t, testDir)
+	fs.SetPartialFiles(cache)
+
+	t.Run("FirstRunReadsEverything", func(t *testing.T) {
+		content, transferred := readPartial(t, fs, "disk.img", size)
+		assert.True(t, bytes.Equal(original, content), "content differs")
+		assert.Equal(t, int64(size), transferred)
+		assert.Zero(t, cache.ReusedBytes())
+	})
+
+	// Change a region in the middle of the file, within a single block.
+	changed := bytes.Clone(original)
+	_, err = rand.Read(changed[8*blockSize+100 : 8*blockSize+4196])
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, changed, 0644))
+
+	t.Run("SecondRunReadsChangedBlock", func(t *testing.T) {
+		content, transferred := readPartial(t, fs, "disk.img", size)
+		assert.True(t, bytes.Equal(changed, content), "content differs")
+		assert.Equal(t, int64(blockSize), transferred, "only the changed block should be read from the agent")
+		assert.Equal(t, int64(size-blockSize), cache.ReusedBytes())
+	})
+
+	t.Run("UnchangedRunReadsNothing", func(t *testing.T) {
+		reused := cache.ReusedBytes()
+		content, transferred := readPartial(t, fs, "disk.img", size)
+		assert.True(t, bytes.Equal(changed, content), "content differs")
+		assert.Zero(t, transferred)
+		assert.Equal(t, int64(size), cache.ReusedBytes()-reused)
+	})
+}
+
+func TestPartialFileInterruptedRun(t *testing.T) {
+	const blockSize = 64 * 1024
+
+	testDir := t.TempDir()
+	data := make([]byte, 4*blockSize)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "db.sqlite"), data, 0644))
+
+	matcher, err := pattern.NewMatcher(pattern.ResolveFilters(nil, []string{"*.sqlite"}, false))
+	require.NoError(t, err)
+	cache := NewPartialFileCache(t.TempDir(), matcher, "", false, blockSize)
+
+	fs := newTestARPCFS(t, testDir)
+	fs.SetPartialFiles(cache)
+
+	_, transferred := readPartial(t, fs, "db.sqlite", int64(len(data)))
+	require.Equal(t, int64(len(data)), transferred)
+
+	// A run that opens the file and never closes it leaves no index behind,
+	// so the next run cannot trust the cached data and reads it all again.
+	file, err := fs.Open("db.sqlite")
+	require.NoError(t, err)
+	fs.AttachPartial(&file, int64(len(data)))
+	_, err = file.ReadAt(make([]byte, 10), 0)
+	require.NoError(t, err)
+	interrupted := file.partial
+	t.Cleanup(func() { interrupted.data.Close() })
+	file.partial = nil
+	require.NoError(t, file.Close())
+
+	content, transferred := readPartial(t, fs, "db.sqlite", int64(len(data)))
+	assert.True(t, bytes.Equal(data, content), "content differs")
+	assert.Equal(t, int64(len(data)), transferred)
+
+	assert.False(t, cache.Matches("db.sqlite-wal"))
+}
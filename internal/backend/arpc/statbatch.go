@@ -0,0 +1,207 @@
+//go:build linux
+
+package arpcfs
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// Defaults for the Attr batching enabled by SetStatBatch. The interval
+// bounds the latency added to a stat that has to wait for others.
+const (
+	DefaultStatBatchSize     = 64
+	DefaultStatBatchInterval = 500 * time.Microsecond
+)
+
+// errStatBatchUnsupported is returned by batched stats when the agent
+// predates StatBatch; Attr then falls back to single calls.
+var errStatBatchUnsupported = errors.New("agent does not support StatBatch")
+
+type statResult struct {
+	info types.AgentFileInfo
+	err  error
+}
+
+type statWaiter struct {
+	path string
+	done chan statResult
+}
+
+// statBatcher coalesces concurrent Attr calls into StatBatch calls. A batch
+// is sent once it holds size paths or interval after its first path was
+// queued, whichever comes first.
+type statBatcher struct {
+	fs       *ARPCFS
+	size     int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []statWaiter
+	timer   *time.Timer
+}
+
+// SetStatBatch makes Attr coalesce concurrent stats into batches of up to
+// size paths, each sent at most interval after its first path was queued.
+// A size of 1 or less disables batching.
+func (fs *ARPCFS) SetStatBatch(size int, interval time.Duration) {
+	if size <= 1 {
+		fs.statBatcher.Store(nil)
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultStatBatchInterval
+	}
+	fs.statBatcher.Store(&statBatcher{
+		fs:       fs,
+		size:     min(size, types.StatBatchMaxPaths),
+		interval: interval,
+	})
+}
+
+// stat queues path for the next batch and waits for its result.
+func (b *statBatcher) stat(path string) (types.AgentFileInfo, error) {
+	done := make(chan statResult, 1)
+
+	b.mu.Lock()
+	b.pending = append(b.pending, statWaiter{path: path, done: done})
+	switch {
+	case len(b.pending) >= b.size:
+		batch := b.take()
+		b.mu.Unlock()
+		go b.flush(batch)
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.interval, b.flushPending)
+		b.mu.Unlock()
+	default:
+		b.mu.Unlock()
+	}
+
+	result := <-done
+	return result.info, result.err
+}
+
+// take empties the pending batch. b.mu must be held.
+func (b *statBatcher) take() []statWaiter {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+func (b *statBatcher) flushPending() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+	b.flush(batch)
+}
+
+func (b *statBatcher) flush(batch []statWaiter) {
+	if len(batch) == 0 {
+		return
+	}
+
+	paths := make([]string, len(batch))
+	for i, waiter := range batch {
+		paths[i] = waiter.path
+	}
+
+	infos, errs, err := b.fs.statBatch(paths)
+	for i, waiter := range batch {
+		if err != nil {
+			waiter.done <- statResult{err: err}
+			continue
+		}
+		waiter.done <- statResult{info: infos[i], err: errs[i]}
+	}
+}
+
+// AttrBatch retrieves the attributes of paths in as few round-trips as
+// possible. infos and errs are aligned with paths; a path that cannot be
+// stat'ed only sets its own error. err is set when the batch as a whole
+// failed.
+func (fs *ARPCFS) AttrBatch(paths []string) (infos []types.AgentFileInfo, errs []error, err error) {
+	infos = make([]types.AgentFileInfo, len(paths))
+	errs = make([]error, len(paths))
+
+	for start := 0; start < len(paths); start += types.StatBatchMaxPaths {
+		end := min(start+types.StatBatchMaxPaths, len(paths))
+
+		chunkInfos, chunkErrs, err := fs.statBatch(paths[start:end])
+		if errors.Is(err, errStatBatchUnsupported) {
+			for i := start; i < end; i++ {
+				infos[i], errs[i] = fs.attr(paths[i])
+			}
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		copy(infos[start:end], chunkInfos)
+		copy(errs[start:end], chunkErrs)
+	}
+
+	for i, info := range infos {
+		if errs[i] == nil {
+			fs.countAccess(info)
+		}
+	}
+	return infos, errs, nil
+}
+
+// statBatch sends one StatBatch call for paths. Per-path errors are mapped
+// like the error of a single Attr call.
+func (fs *ARPCFS) statBatch(paths []string) ([]types.AgentFileInfo, []error, error) {
+	if fs.session == nil {
+		syslog.L.Error(os.ErrInvalid).
+			WithMessage("arpc session is nil").
+			Write()
+		return nil, nil, syscall.EIO
+	}
+	if fs.statBatchUnsupported.Load() {
+		return nil, nil, errStatBatchUnsupported
+	}
+
+	req := types.StatBatchReq{Paths: paths}
+	raw, err := fs.session.CallMsgWithTimeout(1*time.Minute, fs.JobId+"/StatBatch", &req)
+	if err != nil {
+		if isMethodNotFound(err) {
+			fs.statBatchUnsupported.Store(true)
+			return nil, nil, errStatBatchUnsupported
+		}
+		if arpc.IsOSError(err) {
+			return nil, nil, err
+		}
+		return nil, nil, syscall.EIO
+	}
+
+	var resp types.StatBatchResp
+	if err := resp.Decode(raw); err != nil || len(resp) != len(paths) {
+		return nil, nil, syscall.EIO
+	}
+
+	infos := make([]types.AgentFileInfo, len(resp))
+	errs := make([]error, len(resp))
+	for i, entry := range resp {
+		if entry.Err == nil {
+			infos[i] = entry.Info
+			continue
+		}
+		if err := arpc.UnwrapError(*entry.Err); arpc.IsOSError(err) {
+			errs[i] = err
+		} else {
+			errs[i] = syscall.EIO
+		}
+	}
+	return infos, errs, nil
+}
@@ -7,6 +7,8 @@ import (
 	gofuse "github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/safemap"
 )
 
 // ARPCFS implements billy.Filesystem using aRPC calls
@@ -21,6 +23,29 @@ type ARPCFS struct {
 
 	backupMode string
 
+	// Paths the agent listed but could not stat, keyed by path with the
+	// agent-side error as value.
+	failedPaths *safemap.Map[string, string]
+
+	// Manifest of the files opened during the run; nil when not kept.
+	manifest atomic.Pointer[Manifest]
+
+	// Cache of the files read by changed block only; nil when the job lists
+	// no partial files.
+	partialFiles atomic.Pointer[PartialFileCache]
+
+	// Exclusions applied to directory listings; nil when the client or the
+	// agent applies all of them.
+	filter        atomic.Pointer[pattern.RootedMatcher]
+	excludedCount atomic.Int64
+
+	// Batches concurrent Attr calls; nil when every stat is sent alone.
+	statBatcher          atomic.Pointer[statBatcher]
+	statBatchUnsupported atomic.Bool
+
+	// Set once the agent turned out to predate the Streams call.
+	streamsUnsupported atomic.Bool
+
 	// Atomic counters for the number of unique file and folder accesses.
 	fileCount   int64
 	folderCount int64
@@ -51,4 +76,17 @@ type ARPCFile struct {
 	handleID types.FileHandleId
 	isClosed atomic.Bool
 	jobId    string
+
+	// Serves the reads of a partial file; nil for other files.
+	partial *partialFile
+	// The manifest entry recorded once the file is closed, with its hash
+	// computed from the reads; nil when no hash is wanted.
+	record *pendingRecord
+}
+
+// pendingRecord is a manifest entry waiting for the content hash of its
+// file.
+type pendingRecord struct {
+	entry ManifestEntry
+	hash  *streamHash
 }
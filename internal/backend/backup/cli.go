@@ -10,22 +10,45 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
 )
 
-func processFile(path string, removedCount *int64) error {
+// countJunkLines returns the number of lines of path that processFile would
+// remove.
+func countJunkLines(path string, substrings []string) (int64, error) {
 	inputFile, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("opening file %s: %w", path, err)
+		return 0, fmt.Errorf("opening file %s: %w", path, err)
+	}
+	defer inputFile.Close()
+
+	var count int64
+	scanner := bufio.NewScanner(inputFile)
+	for scanner.Scan() {
+		if isJunkLog(scanner.Text(), substrings) {
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("scanning file %s: %w", path, err)
+	}
+	return count, nil
+}
+
+// processFile removes the lines of path containing any of substrings and
+// returns their number.
+func processFile(path string, substrings []string) (int64, error) {
+	inputFile, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening file %s: %w", path, err)
 	}
 	defer inputFile.Close()
 
 	// Get original file info to preserve permissions, ownership, and timestamps
 	info, err := inputFile.Stat()
 	if err != nil {
-		return fmt.Errorf("getting stat of file %s: %w", path, err)
+		return 0, fmt.Errorf("getting stat of file %s: %w", path, err)
 	}
 	origMode := info.Mode()
 	origModTime := info.ModTime()
@@ -34,7 +57,7 @@ func processFile(path string, removedCount *int64) error {
 	// Retrieve UID and GID from the underlying stat
 	statT, ok := info.Sys().(*syscall.Stat_t)
 	if !ok {
-		return fmt.Errorf("failed to retrieve underlying stat from file %s", path)
+		return 0, fmt.Errorf("failed to retrieve underlying stat from file %s", path)
 	}
 	origUid := int(statT.Uid)
 	origGid := int(statT.Gid)
@@ -45,7 +68,7 @@ func processFile(path string, removedCount *int64) error {
 	dir := filepath.Dir(path)
 	tmpFile, err := os.CreateTemp(dir, "clean_")
 	if err != nil {
-		return fmt.Errorf("creating temp file in %s: %w", dir, err)
+		return 0, fmt.Errorf("creating temp file in %s: %w", dir, err)
 	}
 
 	tmpName := tmpFile.Name()
@@ -57,46 +80,55 @@ func processFile(path string, removedCount *int64) error {
 	var removedInFile int64
 	for scanner.Scan() {
 		line := scanner.Text()
-		if isJunkLog(line) {
+		if isJunkLog(line, substrings) {
 			// Count the removed junk log line and skip writing it
 			removedInFile++
 		} else {
 			if _, err := writer.WriteString(line + "\n"); err != nil {
-				return fmt.Errorf("writing to temp file for %s: %w", path, err)
+				return 0, fmt.Errorf("writing to temp file for %s: %w", path, err)
 			}
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("scanning file %s: %w", path, err)
+		return 0, fmt.Errorf("scanning file %s: %w", path, err)
 	}
 	if err := writer.Flush(); err != nil {
-		return fmt.Errorf("flushing writer for %s: %w", path, err)
+		return 0, fmt.Errorf("flushing writer for %s: %w", path, err)
 	}
 
 	// Ensure the temp file has the same permissions as the original
 	if err := os.Chmod(tmpName, origMode); err != nil {
-		return fmt.Errorf("setting permissions on temp file for %s: %w", path, err)
+		return 0, fmt.Errorf("setting permissions on temp file for %s: %w", path, err)
 	}
 
 	// Preserve the owner and group of the original file
 	if err := os.Chown(tmpName, origUid, origGid); err != nil {
-		return fmt.Errorf("setting ownership on temp file for %s: %w", path, err)
+		return 0, fmt.Errorf("setting ownership on temp file for %s: %w", path, err)
 	}
 
 	// Preserve the original timestamps
 	if err := os.Chtimes(tmpName, origAccessTime, origModTime); err != nil {
-		return fmt.Errorf("setting timestamps on temp file for %s: %w", path, err)
+		return 0, fmt.Errorf("setting timestamps on temp file for %s: %w", path, err)
 	}
 
 	if err := os.Rename(tmpName, path); err != nil {
-		return fmt.Errorf("renaming temp file for %s: %w", path, err)
+		return 0, fmt.Errorf("renaming temp file for %s: %w", path, err)
 	}
 
-	atomic.AddInt64(removedCount, removedInFile)
-	return nil
+	return removedInFile, nil
 }
 
-func RemoveJunkLogsRecursively(rootDir string) (int64, error) {
+// RemoveJunkLogsRecursively removes the lines containing any of substrings
+// from the task logs in the subdirectories of rootDir. It returns the number
+// of lines removed per file, leaving out files without any. With dryRun the
+// lines are only counted.
+func RemoveJunkLogsRecursively(rootDir string, substrings []string, dryRun bool) (map[string]int64, error) {
+	// List the entries in the root directory.
+	entries, err := os.ReadDir(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
 	fileCh := make(chan string, 100)
 
 	var wg sync.WaitGroup
@@ -105,18 +137,31 @@ func RemoveJunkLogsRecursively(rootDir string) (int64, error) {
 	var errOnce sync.Once
 	var finalErr error
 
-	// Global atomic counter for removed lines.
-	var totalRemoved int64
+	var removedMu sync.Mutex
+	removed := make(map[string]int64)
 
 	worker := func() {
 		defer wg.Done()
 		for path := range fileCh {
-			log.Printf("Processing file: %s", path)
-			if err := processFile(path, &totalRemoved); err != nil {
+			var count int64
+			var err error
+			if dryRun {
+				count, err = countJunkLines(path, substrings)
+			} else {
+				log.Printf("Processing file: %s", path)
+				count, err = processFile(path, substrings)
+			}
+			if err != nil {
 				errOnce.Do(func() {
 					finalErr = err
 				})
 				log.Printf("Error processing file %s: %v", path, err)
+				continue
+			}
+			if count > 0 {
+				removedMu.Lock()
+				removed[path] = count
+				removedMu.Unlock()
 			}
 		}
 	}
@@ -127,12 +172,6 @@ func RemoveJunkLogsRecursively(rootDir string) (int64, error) {
 		go worker()
 	}
 
-	// List the entries in the root directory.
-	entries, err := os.ReadDir(rootDir)
-	if err != nil {
-		return 0, err
-	}
-
 	// Process only the subdirectories of rootDir.
 	for _, entry := range entries {
 		if entry.IsDir() {
@@ -159,8 +198,8 @@ func RemoveJunkLogsRecursively(rootDir string) (int64, error) {
 	wg.Wait()
 
 	if finalErr != nil {
-		return totalRemoved, finalErr
+		return removed, finalErr
 	}
 
-	return totalRemoved, nil
+	return removed, nil
 }
@@ -0,0 +1,68 @@
+//go:build linux
+
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTaskLogs(t *testing.T) (string, map[string]string) {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]string{
+		filepath.Join(root, "0A", "task-a"): "starting backup\nPOST /dynamic_chunk\nupload_chunk done: 1\nbackup finished\n",
+		filepath.Join(root, "0B", "task-b"): "GET /previous\nCUSTOM noisy line\nkept\n",
+		filepath.Join(root, "0B", "task-c"): "nothing to clean\n",
+		// Files directly in the root are not task logs.
+		filepath.Join(root, "active"): "POST /dynamic_chunk\n",
+	}
+	for path, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return root, files
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRemoveJunkLogsDryRun(t *testing.T) {
+	root, files := writeTaskLogs(t)
+
+	counted, err := RemoveJunkLogsRecursively(root, JunkSubstrings, true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{
+		filepath.Join(root, "0A", "task-a"): 2,
+		filepath.Join(root, "0B", "task-b"): 1,
+	}, counted)
+	for path, content := range files {
+		assert.Equal(t, content, readFile(t, path), "dry run modified %s", path)
+	}
+
+	removed, err := RemoveJunkLogsRecursively(root, JunkSubstrings, false)
+	require.NoError(t, err)
+	assert.Equal(t, counted, removed)
+
+	assert.Equal(t, "starting backup\nbackup finished\n", readFile(t, filepath.Join(root, "0A", "task-a")))
+	assert.Equal(t, files[filepath.Join(root, "active")], readFile(t, filepath.Join(root, "active")))
+}
+
+func TestRemoveJunkLogsCustomPatterns(t *testing.T) {
+	root, _ := writeTaskLogs(t)
+
+	removed, err := RemoveJunkLogsRecursively(root, []string{"CUSTOM"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{filepath.Join(root, "0B", "task-b"): 1}, removed)
+	assert.Equal(t, "GET /previous\nkept\n", readFile(t, filepath.Join(root, "0B", "task-b")))
+}
@@ -13,13 +13,21 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/store"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/proxmox"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
 )
 
-func prepareBackupCommand(ctx context.Context, job types.Job, storeInstance *store.Store, srcPath string, isAgent bool) (*exec.Cmd, error) {
+// repositoryServerAddr is the server the client reaches for the localhost
+// repository of a job, on the default proxmox-backup-client port.
+const repositoryServerAddr = "localhost:8007"
+
+func prepareBackupCommand(ctx context.Context, job types.Job, storeInstance *store.Store, srcPath string, target types.Target) (*exec.Cmd, error) {
 	if srcPath == "" {
 		return nil, fmt.Errorf("RunBackup: source path is required")
 	}
 
+	isAgent := utils.IsAgentPath(target.Path)
 	backupId, err := getBackupId(isAgent, job.Target)
 	if err != nil {
 		return nil, fmt.Errorf("RunBackup: failed to get backup ID: %w", err)
@@ -30,7 +38,32 @@ func prepareBackupCommand(ctx context.Context, job types.Job, storeInstance *sto
 		return nil, fmt.Errorf("RunBackup: invalid job store configuration")
 	}
 
-	cmdArgs := buildCommandArgs(storeInstance, job, srcPath, jobStore, backupId)
+	if job.EncryptionKeyFile != "" {
+		if err := utils.ValidateEncryptionKeyFile(job.EncryptionKeyFile); err != nil {
+			return nil, fmt.Errorf("RunBackup: %w", err)
+		}
+	}
+
+	filters := storeInstance.EffectiveFilters(job, utils.IsCaseInsensitiveTarget(target.Path))
+	if !isAgent && filters.HasRegex() {
+		// Regex rules are applied by the agent mount; proxmox-backup-client
+		// reads local targets directly and only understands globs.
+		syslog.L.Warn().
+			WithMessage("regex exclusions are not applied to local targets").
+			WithFields(map[string]interface{}{"jobId": job.ID, "target": target.Name}).
+			Write()
+	}
+	if !isAgent && len(job.Inclusions) > 0 {
+		// Like regex rules, inclusions are applied by the agent mount. The
+		// job handlers refuse them on local targets; this catches jobs
+		// saved before that or whose target was since changed.
+		syslog.L.Warn().
+			WithMessage("inclusions are not applied to local targets, backing up the whole source").
+			WithFields(map[string]interface{}{"jobId": job.ID, "target": target.Name}).
+			Write()
+	}
+
+	cmdArgs := buildCommandArgs(storeInstance, job, srcPath, jobStore, backupId, filters)
 	if len(cmdArgs) == 0 {
 		return nil, fmt.Errorf("RunBackup: failed to build command arguments")
 	}
@@ -59,7 +92,7 @@ func getBackupId(isAgent bool, targetName string) (string, error) {
 	return strings.TrimSpace(strings.Split(targetName, " - ")[0]), nil
 }
 
-func buildCommandArgs(storeInstance *store.Store, job types.Job, srcPath string, jobStore string, backupId string) []string {
+func buildCommandArgs(storeInstance *store.Store, job types.Job, srcPath string, jobStore string, backupId string, filters pattern.EffectiveFilters) []string {
 	if srcPath == "" || jobStore == "" || backupId == "" {
 		return nil
 	}
@@ -78,35 +111,18 @@ func buildCommandArgs(storeInstance *store.Store, job types.Job, srcPath string,
 		"--repository", jobStore,
 		detectionMode,
 		"--backup-id", backupId,
-		"--crypt-mode=none",
 	}
 
-	// Add exclusions
-	for _, exclusion := range job.Exclusions {
-		path := exclusion.Path
-		if !strings.HasPrefix(exclusion.Path, "/") && !strings.HasPrefix(exclusion.Path, "!") && !strings.HasPrefix(exclusion.Path, "**/") {
-			path = "**/" + path
-		}
-
-		cmdArgs = append(cmdArgs, "--exclude", path)
+	if job.EncryptionKeyFile != "" {
+		cmdArgs = append(cmdArgs, "--crypt-mode=encrypt", "--keyfile", job.EncryptionKeyFile)
+	} else {
+		cmdArgs = append(cmdArgs, "--crypt-mode=none")
 	}
 
-	// Get global exclusions
-	globalExclusions, err := storeInstance.Database.GetAllGlobalExclusions()
-	if err == nil && globalExclusions != nil {
-		for _, exclusion := range globalExclusions {
-			path := exclusion.Path
-			if !strings.HasPrefix(exclusion.Path, "/") && !strings.HasPrefix(exclusion.Path, "!") && !strings.HasPrefix(exclusion.Path, "**/") {
-				path = "**/" + path
-			}
-
-			cmdArgs = append(cmdArgs, "--exclude", path)
-		}
-	}
+	cmdArgs = append(cmdArgs, filters.Args()...)
 
 	// Add namespace if specified
 	if job.Namespace != "" {
-		_ = CreateNamespace(job.Namespace, job, storeInstance)
 		cmdArgs = append(cmdArgs, "--ns", job.Namespace)
 	}
 
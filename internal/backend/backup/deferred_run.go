@@ -0,0 +1,115 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/proxmox"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/system"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// shouldDefer reports whether a failed scheduled run of job should wait for
+// the agent to check in instead of failing.
+func shouldDefer(job types.Job, runErr error) bool {
+	return job.RunOnCheckIn && errors.Is(runErr, ErrAgentUnreachable)
+}
+
+// pendingForAgent returns the jobs with a deferred run that target hostname.
+func pendingForAgent(jobs []types.Job, hostname string) []types.Job {
+	var pending []types.Job
+	for _, job := range jobs {
+		if job.PendingCheckIn == 0 {
+			continue
+		}
+		if strings.TrimSpace(strings.Split(job.Target, " - ")[0]) != hostname {
+			continue
+		}
+		pending = append(pending, job)
+	}
+	return pending
+}
+
+// DeferRun marks a scheduled run of job that could not reach its agent as
+// pending, so it is started when the agent next checks in. It reports
+// whether the run was deferred; if not, the caller handles runErr as usual.
+func DeferRun(storeInstance *store.Store, job types.Job, runErr error) bool {
+	if !shouldDefer(job, runErr) {
+		return false
+	}
+
+	latestJob, err := storeInstance.Database.GetJob(job.ID)
+	if err != nil {
+		latestJob = job
+	}
+	if latestJob.PendingCheckIn == 0 {
+		latestJob.PendingCheckIn = time.Now().Unix()
+	}
+
+	if err := storeInstance.Database.UpdateJob(nil, latestJob); err != nil {
+		syslog.L.Error(err).WithMessage("failed to defer job run").WithField("jobId", job.ID).Write()
+		return false
+	}
+
+	syslog.L.Info().
+		WithMessage("agent unreachable, run deferred until the agent checks in").
+		WithField("jobId", job.ID).
+		WithField("target", job.Target).
+		Write()
+
+	return true
+}
+
+// RunDeferred starts the deferred runs of every job targeting hostname. It is
+// called when the agent establishes its session.
+func RunDeferred(ctx context.Context, storeInstance *store.Store, hostname string) {
+	jobs, err := storeInstance.Database.GetAllJobs()
+	if err != nil {
+		syslog.L.Error(err).WithMessage("failed to list jobs for deferred runs").Write()
+		return
+	}
+
+	for _, job := range pendingForAgent(jobs, hostname) {
+		pendingSince := job.PendingCheckIn
+
+		// Cleared before running so a run that fails again is not retried
+		// on every reconnect; it is deferred anew if the agent drops again.
+		job.PendingCheckIn = 0
+		if err := storeInstance.Database.UpdateJob(nil, job); err != nil {
+			syslog.L.Error(err).WithMessage("failed to clear deferred run").WithField("jobId", job.ID).Write()
+			continue
+		}
+
+		syslog.L.Info().
+			WithMessage("agent checked in, starting deferred run").
+			WithField("jobId", job.ID).
+			WithField("deferredSince", time.Unix(pendingSince, 0).Format(time.RFC3339)).
+			Write()
+
+		system.RemoveAllRetrySchedules(job)
+		started := time.Now()
+		if _, err := RunBackup(ctx, job, storeInstance, false); err != nil {
+			syslog.L.Error(err).WithField("jobId", job.ID).Write()
+
+			if errors.Is(err, ErrOneInstance) || errors.Is(err, ErrJobDisabled) || DeferRun(storeInstance, job, err) {
+				continue
+			}
+			var upid string
+			if task, err := proxmox.GenerateTaskErrorFile(job, err, append([]string{"Error handling from a deferred job run", "Job ID: " + job.ID, "Source Mode: " + job.SourceMode}, TaskLogLines(err)...)); err != nil {
+				syslog.L.Error(err).WithField("jobId", job.ID).Write()
+			} else {
+				upid = task.UPID
+				if err := storeInstance.Database.UpdateJobStatus(nil, job.ID, types.JobStatus{LastRunUpid: task.UPID}); err != nil {
+					syslog.L.Error(err).WithField("jobId", job.ID).WithField("upid", task.UPID).Write()
+				}
+			}
+			NotifyRunError(ctx, job, started, upid, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldDefer(t *testing.T) {
+	unreachable := fmt.Errorf("%w: %w", ErrAgentUnreachable, errors.New("mount failed"))
+
+	job := types.Job{ID: "laptop", Target: "laptop - C", RunOnCheckIn: true}
+	assert.True(t, shouldDefer(job, unreachable))
+
+	// Other failures are reported as usual.
+	assert.False(t, shouldDefer(job, errors.New("datastore full")))
+	assert.False(t, shouldDefer(job, ErrOneInstance))
+
+	// Jobs without the option keep failing when the agent is away.
+	job.RunOnCheckIn = false
+	assert.False(t, shouldDefer(job, unreachable))
+}
+
+func TestPendingForAgent(t *testing.T) {
+	jobs := []types.Job{
+		{ID: "laptop-c", Target: "laptop - C", RunOnCheckIn: true, PendingCheckIn: 1700000000},
+		{ID: "laptop-d", Target: "laptop - D", RunOnCheckIn: true},
+		{ID: "desktop-c", Target: "desktop - C", RunOnCheckIn: true, PendingCheckIn: 1700000000},
+		{ID: "laptop2-c", Target: "laptop2 - C", RunOnCheckIn: true, PendingCheckIn: 1700000000},
+	}
+
+	pending := pendingForAgent(jobs, "laptop")
+	require.Len(t, pending, 1)
+	assert.Equal(t, "laptop-c", pending[0].ID)
+
+	assert.Empty(t, pendingForAgent(jobs, "server"))
+}
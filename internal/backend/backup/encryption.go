@@ -0,0 +1,22 @@
+//go:build linux
+
+package backup
+
+import (
+	"slices"
+)
+
+// redactedArg replaces the value of arguments that must not be logged.
+const redactedArg = "<redacted>"
+
+// redactArgs returns a copy of the proxmox-backup-client arguments args
+// that is safe to log.
+func redactArgs(args []string) []string {
+	redacted := slices.Clone(args)
+	for i := range redacted {
+		if redacted[i] == "--keyfile" && i+1 < len(redacted) {
+			redacted[i+1] = redactedArg
+		}
+	}
+	return redacted
+}
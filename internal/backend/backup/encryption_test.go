@@ -0,0 +1,36 @@
+//go:build linux
+
+package backup
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCommandArgsEncryption(t *testing.T) {
+	keyFile := "/etc/proxmox-backup/pbs-plus/keys/job.key"
+	job := types.Job{ID: "job", Target: "host - C"}
+
+	args := buildCommandArgs(nil, job, "/mnt/src", "token@localhost:store", "host", pattern.EffectiveFilters{})
+	assert.Contains(t, args, "--crypt-mode=none")
+	assert.NotContains(t, args, "--keyfile")
+
+	job.EncryptionKeyFile = keyFile
+	args = buildCommandArgs(nil, job, "/mnt/src", "token@localhost:store", "host", pattern.EffectiveFilters{})
+	assert.Contains(t, args, "--crypt-mode=encrypt")
+	assert.NotContains(t, args, "--crypt-mode=none")
+	i := slices.Index(args, "--keyfile")
+	require.GreaterOrEqual(t, i, 0)
+	require.Less(t, i+1, len(args))
+	assert.Equal(t, keyFile, args[i+1])
+
+	redacted := redactArgs(args)
+	assert.NotContains(t, strings.Join(redacted, " "), keyFile)
+	assert.Equal(t, keyFile, args[i+1], "redacting modified the command")
+}
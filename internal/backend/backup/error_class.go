@@ -0,0 +1,99 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/system"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+// Error classes. Errors returned by RunBackup and BackupOperation.Wait match
+// exactly one of them with errors.Is, along with the sentinel they wrap.
+var (
+	// ErrTransient is a failure a later attempt may not run into, such as an
+	// unreachable agent or a busy datastore. Only these are retried.
+	ErrTransient = errors.New("transient backup failure")
+	// ErrPermanent is a failure that repeats until the job or its target is
+	// fixed, such as a missing target or namespace.
+	ErrPermanent = errors.New("permanent backup failure")
+	// ErrCancelled is a run stopped on purpose.
+	ErrCancelled = errors.New("backup cancelled")
+)
+
+// permanentErrors are the sentinels retrying does not help with. Anything
+// else is transient, so unknown failures are retried as they always were.
+var permanentErrors = []error{
+	ErrOneInstance,
+	ErrJobDisabled,
+	ErrAPITokenRequired,
+	ErrNamespaceMissing,
+	ErrNamespaceCreate,
+	ErrTargetNotFound,
+	ErrSizeGuardExceeded,
+	ErrPrepareBackupCommand,
+}
+
+// classifiedError attaches an error class to a backup failure without
+// changing its message.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// errorClass returns the class err belongs to.
+func errorClass(err error) error {
+	switch {
+	case errors.Is(err, ErrTransient):
+		return ErrTransient
+	case errors.Is(err, ErrPermanent):
+		return ErrPermanent
+	case errors.Is(err, ErrCancelled), errors.Is(err, context.Canceled):
+		return ErrCancelled
+	}
+	for _, permanent := range permanentErrors {
+		if errors.Is(err, permanent) {
+			return ErrPermanent
+		}
+	}
+	return ErrTransient
+}
+
+// classify wraps err with its class, unless it already carries one.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	class := errorClass(err)
+	if errors.Is(err, class) {
+		return err
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// IsRetryable reports whether a run that failed with err should be retried.
+func IsRetryable(err error) bool {
+	return err != nil && errorClass(err) == ErrTransient
+}
+
+// setRetrySchedule is replaced in tests.
+var setRetrySchedule = system.SetRetrySchedule
+
+// ScheduleRetry schedules the next attempt of job after a run failed with
+// err, if err is transient, and reports whether it did.
+func ScheduleRetry(job types.Job, err error) (bool, error) {
+	if !IsRetryable(err) {
+		return false, nil
+	}
+	return true, setRetrySchedule(job)
+}
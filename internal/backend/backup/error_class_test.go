@@ -0,0 +1,112 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		err   error
+		class error
+	}{
+		{"AgentUnreachable", fmt.Errorf("%w: %w", ErrTargetUnreachable, ErrAgentUnreachable), ErrTransient},
+		{"TargetBusy", ErrTargetBusy, ErrTransient},
+		{"TaskDetectionTimedOut", fmt.Errorf("%w: %v", ErrTaskDetectionTimedOut, context.DeadlineExceeded), ErrTransient},
+		{"Unknown", errors.New("exit status 255"), ErrTransient},
+		{"TargetNotFound", fmt.Errorf("%w: laptop - C", ErrTargetNotFound), ErrPermanent},
+		{"NamespaceMissing", ErrNamespaceMissing, ErrPermanent},
+		{"SizeGuard", ErrSizeGuardExceeded, ErrPermanent},
+		{"ContextCancelled", fmt.Errorf("waiting for target slot: %w", context.Canceled), ErrCancelled},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := classify(tc.err)
+			assert.ErrorIs(t, err, tc.class)
+			assert.ErrorIs(t, err, tc.err, "the original error is still matched")
+			assert.Equal(t, tc.err.Error(), err.Error(), "the message is unchanged")
+			for _, other := range []error{ErrTransient, ErrPermanent, ErrCancelled} {
+				if other != tc.class {
+					assert.NotErrorIs(t, err, other)
+				}
+			}
+		})
+	}
+
+	assert.NoError(t, classify(nil))
+}
+
+func TestRunBackupDisabledJob(t *testing.T) {
+	job := types.Job{ID: "paused", Store: "local", Target: "laptop - C", Schedule: "daily"}
+
+	// Refused before the store, the target or any lock is touched.
+	op, err := RunBackup(context.Background(), job, nil, true)
+	assert.Nil(t, op)
+	assert.ErrorIs(t, err, ErrJobDisabled)
+	assert.ErrorIs(t, err, ErrPermanent, "a disabled job is not retried")
+}
+
+func TestRunResult(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+
+	assert.NoError(t, runResult(nil, true, false))
+	assert.ErrorIs(t, runResult(nil, false, false), ErrTransient)
+	assert.ErrorIs(t, runResult(exitErr, false, false), ErrTransient)
+
+	cancelled := runResult(exitErr, false, true)
+	assert.ErrorIs(t, cancelled, ErrCancelled)
+	assert.ErrorIs(t, cancelled, exitErr)
+	assert.NotErrorIs(t, cancelled, ErrTransient)
+}
+
+func TestScheduleRetry(t *testing.T) {
+	saved := setRetrySchedule
+	t.Cleanup(func() { setRetrySchedule = saved })
+
+	var scheduled []string
+	setRetrySchedule = func(job types.Job) error {
+		scheduled = append(scheduled, job.ID)
+		return nil
+	}
+
+	for _, tc := range []struct {
+		job   string
+		err   error
+		retry bool
+	}{
+		{"transient", classify(ErrTargetUnreachable), true},
+		{"task-failed", runResult(nil, false, false), true},
+		{"permanent", classify(ErrTargetNotFound), false},
+		{"cancelled", runResult(errors.New("killed"), false, true), false},
+		{"one-instance", classify(ErrOneInstance), false},
+	} {
+		retried, err := ScheduleRetry(types.Job{ID: tc.job}, tc.err)
+		require.NoError(t, err, tc.job)
+		assert.Equal(t, tc.retry, retried, tc.job)
+	}
+
+	assert.Equal(t, []string{"transient", "task-failed"}, scheduled)
+}
+
+func TestTaskLogLinesOfBlockedRun(t *testing.T) {
+	blocked := classify(&sizeGuardError{
+		decision: "size guard: estimated size 2 GB (from previous snapshot) exceeds job quota of 1 GB, refusing to start",
+		err:      fmt.Errorf("%w: over quota", ErrSizeGuardExceeded),
+	})
+	assert.ErrorIs(t, blocked, ErrSizeGuardExceeded)
+
+	lines := TaskLogLines(blocked)
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "refusing to start")
+	assert.Equal(t, sizeGuardDedupNote, lines[1])
+
+	assert.Empty(t, TaskLogLines(errors.New("other failure")))
+}
@@ -3,8 +3,6 @@
 package backup
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -15,13 +13,9 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/store"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/proxmox"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
 )
 
-type NamespaceReq struct {
-	Name   string `json:"name"`
-	Parent string `json:"parent"`
-}
-
 type PBSStoreGroups struct {
 	Owner string `json:"owner"`
 }
@@ -30,55 +24,6 @@ type PBSStoreGroupsResponse struct {
 	Data PBSStoreGroups `json:"data"`
 }
 
-func CreateNamespace(namespace string, job types.Job, storeInstance *store.Store) error {
-	if storeInstance == nil {
-		return fmt.Errorf("CreateNamespace: store is required")
-	}
-
-	if proxmox.Session.APIToken == nil {
-		return fmt.Errorf("CreateNamespace: api token is required")
-	}
-
-	namespaceSplit := strings.Split(namespace, "/")
-
-	for i, ns := range namespaceSplit {
-		var reqBody []byte
-		var err error
-
-		if i == 0 {
-			reqBody, err = json.Marshal(&NamespaceReq{
-				Name: ns,
-			})
-			if err != nil {
-				return fmt.Errorf("CreateNamespace: error creating req body -> %w", err)
-			}
-		} else {
-			reqBody, err = json.Marshal(&NamespaceReq{
-				Name:   ns,
-				Parent: strings.Join(namespaceSplit[:i], "/"),
-			})
-			if err != nil {
-				return fmt.Errorf("CreateNamespace: error creating req body -> %w", err)
-			}
-		}
-
-		_ = proxmox.Session.ProxmoxHTTPRequest(
-			http.MethodPost,
-			fmt.Sprintf("/api2/json/admin/datastore/%s/namespace", job.Store),
-			bytes.NewBuffer(reqBody),
-			nil,
-		)
-	}
-
-	job.Namespace = namespace
-	err := storeInstance.Database.UpdateJob(nil, job)
-	if err != nil {
-		return fmt.Errorf("CreateNamespace: error updating job to namespace -> %w", err)
-	}
-
-	return nil
-}
-
 func GetCurrentOwner(job types.Job, storeInstance *store.Store) (string, error) {
 	if storeInstance == nil {
 		return "", fmt.Errorf("GetCurrentOwner: store is required")
@@ -154,7 +99,7 @@ func SetDatastoreOwner(job types.Job, storeInstance *store.Store, owner string)
 		hostname = strings.TrimSpace(string(hostnameFile))
 	}
 
-	isAgent := strings.HasPrefix(target.Path, "agent://")
+	isAgent := utils.IsAgentPath(target.Path)
 	backupId := hostname
 	if isAgent {
 		backupId = strings.TrimSpace(strings.Split(target.Name, " - ")[0])
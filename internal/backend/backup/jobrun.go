@@ -9,11 +9,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/alexflint/go-filemutex"
+	"github.com/sonroyaalmerol/pbs-plus/internal/backend/metrics"
 	"github.com/sonroyaalmerol/pbs-plus/internal/backend/mount"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/proxmox"
@@ -27,17 +29,32 @@ import (
 var (
 	ErrJobMutexCreation = errors.New("failed to create job mutex")
 	ErrOneInstance      = errors.New("a job is still running; only one instance allowed")
+	ErrJobDisabled      = errors.New("job is disabled; enable it to run it")
 
 	ErrStdoutTempCreation = errors.New("failed to create stdout temp file")
 
 	ErrBackupMutexCreation = errors.New("failed to create backup mutex")
 	ErrBackupMutexLock     = errors.New("failed to lock backup mutex")
 
+	ErrStoreMutexCreation = errors.New("failed to create datastore mutex")
+	ErrStoreMutexLock     = errors.New("failed to lock datastore mutex")
+
+	ErrTargetMutexCreation = errors.New("failed to create target mutex")
+	ErrTargetMutexLock     = errors.New("failed to lock target mutex")
+	ErrTargetBusy          = errors.New("target is running its maximum number of concurrent jobs")
+
 	ErrAPITokenRequired = errors.New("API token is required")
 
+	ErrNamespaceCheck   = errors.New("failed to check namespace")
+	ErrNamespaceMissing = errors.New("namespace does not exist")
+	ErrNamespaceCreate  = errors.New("failed to create namespace")
+
 	ErrTargetGet         = errors.New("failed to get target")
 	ErrTargetNotFound    = errors.New("target does not exist")
 	ErrTargetUnreachable = errors.New("target unreachable")
+	ErrAgentUnreachable  = mount.ErrAgentUnreachable
+
+	ErrSizeGuardExceeded = errors.New("backup size guard exceeded")
 
 	ErrMountInitialization  = errors.New("mount initialization error")
 	ErrPrepareBackupCommand = errors.New("failed to prepare backup command")
@@ -52,8 +69,23 @@ var (
 	ErrTaskDetectionTimedOut = errors.New("task detection timed out")
 
 	ErrJobStatusUpdateFailed = errors.New("failed to update job status")
+
+	ErrTaskFailed = errors.New("backup task failed")
 )
 
+// AgentRetryBudget bounds how long a backup waits for the ARPC session of
+// its agent before giving up.
+type AgentRetryBudget struct {
+	Attempts int
+	Interval time.Duration
+}
+
+// DefaultAgentRetryBudget gives a reconnecting agent about 15 seconds.
+var DefaultAgentRetryBudget = AgentRetryBudget{
+	Attempts: 6,
+	Interval: 3 * time.Second,
+}
+
 // BackupOperation encapsulates a backup operation.
 type BackupOperation struct {
 	Task      proxmox.Task
@@ -61,7 +93,8 @@ type BackupOperation struct {
 	err       error
 }
 
-// Wait blocks until the backup operation is complete.
+// Wait blocks until the backup operation is complete. A failed backup is
+// classified as ErrTransient or ErrCancelled.
 func (b *BackupOperation) Wait() error {
 	if b.waitGroup != nil {
 		b.waitGroup.Wait()
@@ -69,15 +102,52 @@ func (b *BackupOperation) Wait() error {
 	return b.err
 }
 
+// RunBackup starts the backup of job. A failure to start is classified as
+// ErrTransient, ErrPermanent or ErrCancelled. A disabled job is refused with
+// ErrJobDisabled.
 func RunBackup(
 	ctx context.Context,
 	job types.Job,
 	storeInstance *store.Store,
 	skipCheck bool,
 ) (*BackupOperation, error) {
-	jobInstanceMutex, err := filemutex.New(
-		fmt.Sprintf("/tmp/pbs-plus-mutex-job-%s", job.ID),
-	)
+	if !job.Enabled {
+		return nil, classify(ErrJobDisabled)
+	}
+
+	op, err := runBackup(ctx, job, storeInstance, skipCheck, nil)
+	if err != nil && !errors.Is(err, ErrOneInstance) {
+		metrics.RecordRun(job.ID, false)
+	}
+	return op, classify(err)
+}
+
+// runBackup starts the backup of job. A non-nil testRun redirects the backup
+// to scratch space and leaves the job status untouched.
+func runBackup(
+	ctx context.Context,
+	job types.Job,
+	storeInstance *store.Store,
+	skipCheck bool,
+	testRun *TestRunOptions,
+) (*BackupOperation, error) {
+	started := time.Now()
+
+	if testRun != nil {
+		testRun.apply(&job)
+		syslog.L.Info().
+			WithMessage("starting test run").
+			WithField("jobId", job.ID).
+			WithField("store", job.Store).
+			WithField("namespace", job.Namespace).
+			Write()
+	}
+
+	mutexPath := fmt.Sprintf("/tmp/pbs-plus-mutex-job-%s", job.ID)
+	if testRun != nil {
+		mutexPath = testRun.mutexPath(job.ID)
+	}
+	jobInstanceMutex, err := filemutex.New(mutexPath)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrJobMutexCreation, err)
 	}
@@ -91,15 +161,28 @@ func RunBackup(
 	}
 	clientLogPath := clientLogFile.Name()
 
+	if testRun != nil {
+		_, _ = fmt.Fprintf(clientLogFile, "test run: writing to datastore %s, namespace %s\n",
+			job.Store, job.Namespace)
+	}
+
 	errorMonitorDone := make(chan struct{})
 
 	var agentMount *mount.AgentMount
+	var storeMutex *filemutex.FileMutex
+	var targetSlot *filemutex.FileMutex
 
 	errCleanUp := func() {
 		utils.ClearIOStats(job.CurrentPID)
 		job.CurrentPID = 0
 
 		_ = jobInstanceMutex.Close()
+		if storeMutex != nil {
+			_ = storeMutex.Close()
+		}
+		if targetSlot != nil {
+			_ = targetSlot.Close()
+		}
 		if agentMount != nil {
 			agentMount.Unmount()
 			agentMount.CloseMount()
@@ -111,6 +194,48 @@ func RunBackup(
 		close(errorMonitorDone)
 	}
 
+	target, err := storeInstance.Database.GetTarget(job.Target)
+	if err != nil {
+		errCleanUp()
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrTargetNotFound, job.Target)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrTargetGet, err)
+	}
+
+	// The slot is taken before the global backup mutex so that a job
+	// queueing for its target does not hold up jobs of other targets.
+	if target.MaxConcurrency > 0 {
+		syslog.L.Info().WithMessage("waiting for target slot").WithField("target", target.Name).Write()
+
+		var waited time.Duration
+		targetSlot, waited, err = acquireTargetSlot(ctx, func(slot int) string {
+			return targetSlotPath(target.Name, slot)
+		}, target.MaxConcurrency, target.FailWhenBusy)
+		if err != nil {
+			errCleanUp()
+			return nil, err
+		}
+		_, _ = fmt.Fprintf(clientLogFile, "waited %s for one of the %d job slots of target %s\n",
+			waited.Round(time.Second), target.MaxConcurrency, target.Name)
+	}
+
+	// The datastore lock is taken only once the job holds a slot of its
+	// target, so a job queueing for its target does not hold the datastore
+	// against jobs of other targets.
+	if job.SerializeStore {
+		syslog.L.Info().WithMessage("waiting for datastore lock").WithField("store", job.Store).Write()
+
+		var waited time.Duration
+		storeMutex, waited, err = acquireStoreLock(ctx, storeLockPath(job.Store))
+		if err != nil {
+			errCleanUp()
+			return nil, err
+		}
+		_, _ = fmt.Fprintf(clientLogFile, "waited %s for exclusive access to datastore %s\n",
+			waited.Round(time.Second), job.Store)
+	}
+
 	backupMutex, err := filemutex.New("/tmp/pbs-plus-mutex-lock")
 	if err != nil {
 		errCleanUp()
@@ -128,43 +253,71 @@ func RunBackup(
 		return nil, ErrAPITokenRequired
 	}
 
-	target, err := storeInstance.Database.GetTarget(job.Target)
-	if err != nil {
+	if created, err := ensureNamespace(proxmox.Session, job); err != nil {
 		errCleanUp()
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("%w: %s", ErrTargetNotFound, job.Target)
-		}
-		return nil, fmt.Errorf("%w: %v", ErrTargetGet, err)
+		return nil, err
+	} else if created != "" {
+		syslog.L.Info().WithMessage(created).WithField("jobId", job.ID).Write()
+		_, _ = fmt.Fprintln(clientLogFile, created)
 	}
 
 	if !skipCheck {
 		targetSplit := strings.Split(target.Name, " - ")
-		_, exists := storeInstance.ARPCSessionManager.GetSession(targetSplit[0])
-		if !exists {
+		budget := DefaultAgentRetryBudget
+		if target.MaxRetries > 0 {
+			budget.Attempts = target.MaxRetries + 1
+		}
+		started := time.Now()
+		_, attempts, err := storeInstance.ARPCSessionManager.WaitForSession(ctx, targetSplit[0], budget.Attempts, budget.Interval)
+		if err != nil {
+			errCleanUp()
+			return nil, fmt.Errorf("%w: %w", ErrTargetUnreachable, &mount.AgentUnreachableError{
+				Hostname:  targetSplit[0],
+				Attempts:  attempts,
+				Elapsed:   time.Since(started),
+				LastError: err.Error(),
+			})
+		}
+	}
+
+	if job.SizeGuard != "" {
+		decision, err := checkSizeGuard(job, target)
+		syslog.L.Info().WithMessage(decision).WithField("jobId", job.ID).Write()
+		if err != nil {
+			// The client log is discarded with the run; the decision
+			// reaches the error task log through TaskLogLines.
 			errCleanUp()
-			return nil, fmt.Errorf("%w: %s", ErrTargetUnreachable, job.Target)
+			return nil, err
 		}
+		_, _ = fmt.Fprintln(clientLogFile, decision)
 	}
 
 	srcPath := target.Path
-	isAgent := strings.HasPrefix(target.Path, "agent://")
+	isAgent := utils.IsAgentPath(target.Path)
 	if isAgent {
 		agentMount, err = mount.Mount(storeInstance, job, target)
 		if err != nil {
 			errCleanUp()
-			return nil, fmt.Errorf("%w: %v", ErrMountInitialization, err)
+			return nil, fmt.Errorf("%w: %w", ErrMountInitialization, err)
 		}
 		srcPath = agentMount.Path
+		_, _ = fmt.Fprintln(clientLogFile, changeJournalLine(agentMount.ChangedFiles))
 
 		// In case mount updates the job.
 		latestAgent, err := storeInstance.Database.GetJob(job.ID)
 		if err == nil {
+			latestAgent.RetryAttempt = job.RetryAttempt
 			job = latestAgent
+			if testRun != nil {
+				testRun.apply(&job)
+			}
 		}
 	}
 	srcPath = filepath.Join(srcPath, job.Subpath)
 
-	cmd, err := prepareBackupCommand(ctx, job, storeInstance, srcPath, isAgent)
+	backupId, _ := getBackupId(isAgent, job.Target)
+
+	cmd, err := prepareBackupCommand(ctx, job, storeInstance, srcPath, target)
 	if err != nil {
 		errCleanUp()
 		return nil, fmt.Errorf("%w: %v", ErrPrepareBackupCommand, err)
@@ -217,7 +370,18 @@ func RunBackup(
 	cmd.Stdout = stdoutWriter
 	cmd.Stderr = stdoutWriter
 
-	syslog.L.Info().WithMessage("starting backup job").WithField("args", cmd.Args).Write()
+	// Seen on the connection the client is about to make, to tell whether
+	// the datastore server changed since the last run.
+	observedFingerprint, err := observeFingerprint(ctx, repositoryServerAddr)
+	if err != nil {
+		syslog.L.Warn().
+			WithMessage("failed to observe the datastore fingerprint").
+			WithField("jobId", job.ID).
+			WithField("error", err.Error()).
+			Write()
+	}
+
+	syslog.L.Info().WithMessage("starting backup job").WithField("args", redactArgs(cmd.Args)).Write()
 	if err := cmd.Start(); err != nil {
 		monitorCancel()
 		if currOwner != "" {
@@ -225,13 +389,12 @@ func RunBackup(
 		}
 		errCleanUp()
 		return nil, fmt.Errorf("%w (%s): %v",
-			ErrProxmoxBackupClientStart, cmd.String(), err)
+			ErrProxmoxBackupClientStart, strings.Join(redactArgs(cmd.Args), " "), err)
 	}
 
 	if cmd.Process != nil {
 		job.CurrentPID = cmd.Process.Pid
 	}
-
 	go monitorPBSClientLogs(clientLogPath, cmd, errorMonitorDone)
 
 	syslog.L.Info().WithMessage("waiting for task monitoring results").Write()
@@ -262,16 +425,22 @@ func RunBackup(
 		return nil, fmt.Errorf("%w: %v", ErrTaskDetectionTimedOut, monitorCtx.Err())
 	}
 
-	if err := updateJobStatus(false, job, task, storeInstance); err != nil {
-		errCleanUp()
-		if currOwner != "" {
-			_ = SetDatastoreOwner(job, storeInstance, currOwner)
+	if testRun == nil {
+		if err := updateJobStatus(false, job, task, storeInstance); err != nil {
+			errCleanUp()
+			if currOwner != "" {
+				_ = SetDatastoreOwner(job, storeInstance, currOwner)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrJobStatusUpdateFailed, err)
 		}
-		return nil, fmt.Errorf("%w: %v", ErrJobStatusUpdateFailed, err)
 	}
 
 	syslog.L.Info().WithMessage("task monitoring finished").WithField("task", task.UPID).Write()
 
+	if testRun == nil {
+		metrics.RunStarted()
+	}
+
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	operation := &BackupOperation{
@@ -282,16 +451,59 @@ func RunBackup(
 	go func() {
 		defer wg.Done()
 		defer jobInstanceMutex.Close()
-
-		if err := cmd.Wait(); err != nil {
-			operation.err = err
+		if storeMutex != nil {
+			defer storeMutex.Close()
 		}
+		if targetSlot != nil {
+			defer targetSlot.Close()
+		}
+
+		waitErr := cmd.Wait()
 
 		utils.ClearIOStats(job.CurrentPID)
 		job.CurrentPID = 0
 
 		close(errorMonitorDone)
 
+		info, err := readRunInfo(clientLogPath, observedFingerprint)
+		if err != nil {
+			syslog.L.Error(err).WithMessage("failed to read run info from client log").Write()
+		}
+		if info.Fingerprint != "" && job.LastRunFingerprint != "" && info.Fingerprint != job.LastRunFingerprint {
+			syslog.L.Warn().
+				WithMessage("datastore fingerprint changed since the last run").
+				WithField("jobId", job.ID).
+				WithField("previous", job.LastRunFingerprint).
+				WithField("current", info.Fingerprint).
+				Write()
+			_, _ = fmt.Fprintf(clientLogFile, "WARNING: datastore fingerprint changed from %s to %s; verify the server is not misconfigured or intercepted\n",
+				job.LastRunFingerprint, info.Fingerprint)
+		}
+		if info.Fingerprint != "" {
+			job.LastRunFingerprint = info.Fingerprint
+		}
+		job.LastRunVerifyState = info.VerifyState
+
+		if agentMount != nil {
+			if failed, err := agentMount.FailedPaths(); err != nil {
+				syslog.L.Warn().
+					WithMessage("failed to get unreadable paths of the mount").
+					WithField("jobId", job.ID).
+					WithField("error", err.Error()).
+					Write()
+			} else {
+				for _, line := range failedPathsLines(failed) {
+					_, _ = fmt.Fprintln(clientLogFile, line)
+				}
+			}
+		}
+
+		if testRun != nil {
+			for _, line := range testRun.finish(job, backupId, info) {
+				_, _ = fmt.Fprintln(clientLogFile, line)
+			}
+		}
+
 		_ = clientLogFile.Close()
 
 		succeeded, cancelled, err := processPBSProxyLogs(task.UPID, clientLogPath)
@@ -302,17 +514,55 @@ func RunBackup(
 		}
 		_ = os.Remove(clientLogPath)
 
-		if err := updateJobStatus(succeeded, job, task, storeInstance); err != nil {
-			syslog.L.Error(err).
-				WithMessage("failed to update job status - post cmd.Wait").
-				Write()
-		}
+		operation.err = runResult(waitErr, succeeded, cancelled)
 
-		if succeeded || cancelled {
-			system.RemoveAllRetrySchedules(job)
+		if testRun != nil {
+			syslog.L.Info().
+				WithMessage("test run finished").
+				WithField("jobId", job.ID).
+				WithField("upid", task.UPID).
+				WithField("succeeded", succeeded).
+				Write()
 		} else {
-			if err := system.SetRetrySchedule(job); err != nil {
+			metrics.RunFinished(job.ID, succeeded)
+
+			if err := updateJobStatus(succeeded, job, task, storeInstance); err != nil {
+				syslog.L.Error(err).
+					WithMessage("failed to update job status - post cmd.Wait").
+					Write()
+			}
+
+			if retried, err := ScheduleRetry(job, operation.err); err != nil {
 				syslog.L.Error(err).WithField("jobId", job.ID).Write()
+			} else if !retried {
+				system.RemoveAllRetrySchedules(job)
+			}
+
+			payload := WebhookPayload{
+				JobID:    job.ID,
+				State:    WebhookStateSuccess,
+				Duration: int64(time.Since(started).Seconds()),
+				UPID:     task.UPID,
+				Bytes:    info.BytesUploaded,
+			}
+			switch {
+			case cancelled:
+				payload.State = WebhookStateCancelled
+			case !succeeded:
+				payload.State = WebhookStateFailed
+				payload.Error = operation.err.Error()
+			}
+			NotifyWebhook(context.Background(), job, payload)
+
+			if err := storeInstance.Database.AppendJobRun(job.ID, types.JobRun{
+				UPID:      task.UPID,
+				State:     payload.State,
+				StartTime: started.Unix(),
+				Duration:  payload.Duration,
+				Bytes:     payload.Bytes,
+				Error:     payload.Error,
+			}); err != nil {
+				syslog.L.Error(err).WithMessage("failed to record run history").WithField("jobId", job.ID).Write()
 			}
 		}
 
@@ -323,8 +573,70 @@ func RunBackup(
 		if agentMount != nil {
 			agentMount.Unmount()
 			agentMount.CloseMount()
+
+			if job.Manifest {
+				if err := commitManifest(job.ID, task.UPID); err != nil {
+					syslog.L.Error(err).
+						WithMessage("failed to save file manifest").
+						WithField("jobId", job.ID).
+						Write()
+				}
+			}
 		}
 	}()
 
 	return operation, nil
 }
+
+// runResult is the error of a finished backup task, given the error the
+// client exited with and what the task log says of the outcome.
+func runResult(waitErr error, succeeded, cancelled bool) error {
+	switch {
+	case cancelled:
+		if waitErr == nil {
+			return ErrCancelled
+		}
+		return &classifiedError{class: ErrCancelled, err: waitErr}
+	case succeeded:
+		return nil
+	case waitErr == nil:
+		return classify(ErrTaskFailed)
+	}
+	return classify(waitErr)
+}
+
+// changeJournalLine describes, for the task log, whether the agent could
+// tell which files changed since the last backup.
+func changeJournalLine(changed int) string {
+	if changed < 0 {
+		return "change journal unavailable, walking the full tree"
+	}
+	return fmt.Sprintf("change journal: %d files changed since the last backup", changed)
+}
+
+// maxFailedPathLines bounds the unreadable paths listed in the task log.
+const maxFailedPathLines = 100
+
+// failedPathsLines summarizes, for the task log, the entries the agent
+// listed but could not read and so are missing from the backup.
+func failedPathsLines(failed map[string]string) []string {
+	if len(failed) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(failed))
+	for path := range failed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	lines := []string{fmt.Sprintf("WARNING: %d entries could not be read by the agent and were skipped", len(paths))}
+	for i, path := range paths {
+		if i == maxFailedPathLines {
+			lines = append(lines, fmt.Sprintf("WARNING: ... and %d more", len(paths)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("WARNING: skipped %s: %s", path, failed[path]))
+	}
+	return lines
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	arpcfs "github.com/sonroyaalmerol/pbs-plus/internal/backend/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
+)
+
+// ErrManifestNotFound is returned when a run kept no file manifest.
+var ErrManifestNotFound = errors.New("no file manifest for run")
+
+// ManifestPath returns the path of the file manifest of run upid of job,
+// stored next to the run log.
+func ManifestPath(jobId string, upid string) (string, error) {
+	if upid == "" || strings.ContainsAny(upid, `/\`) || upid == "." || upid == ".." {
+		return "", fmt.Errorf("invalid upid: %q", upid)
+	}
+	return filepath.Join(constants.JobLogsBasePath, jobId, arpcfs.ManifestName(upid)), nil
+}
+
+// commitManifest files the manifest written during the run under its upid.
+func commitManifest(jobId string, upid string) error {
+	path, err := ManifestPath(jobId, upid)
+	if err != nil {
+		return err
+	}
+
+	pending := filepath.Join(constants.JobLogsBasePath, jobId, arpcfs.PendingManifestName)
+	if err := os.Rename(pending, path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrManifestNotFound
+		}
+		return err
+	}
+	return nil
+}
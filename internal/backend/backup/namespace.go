@@ -0,0 +1,63 @@
+//go:build linux
+
+package backup
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+// namespaceClient is the part of the PBS API the namespace pre-flight
+// check needs.
+type namespaceClient interface {
+	ListNamespaces(store string) ([]string, error)
+	CreateNamespace(store, parent, name string) error
+}
+
+// ensureNamespace checks that the namespace of job exists on its datastore
+// and, when the job sets CreateNamespace, creates it along with any missing
+// parent. It returns a line for the task log when something was created.
+func ensureNamespace(client namespaceClient, job types.Job) (string, error) {
+	if job.Namespace == "" {
+		return "", nil
+	}
+
+	existing, err := client.ListNamespaces(job.Store)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNamespaceCheck, err)
+	}
+	if slices.Contains(existing, job.Namespace) {
+		return "", nil
+	}
+
+	if !job.CreateNamespace {
+		return "", fmt.Errorf("%w: namespace %q does not exist on datastore %s and the job does not create missing namespaces",
+			ErrNamespaceMissing, job.Namespace, job.Store)
+	}
+
+	// PBS creates one level at a time.
+	parts := strings.Split(job.Namespace, "/")
+	for i := range parts {
+		if slices.Contains(existing, strings.Join(parts[:i+1], "/")) {
+			continue
+		}
+		if err := client.CreateNamespace(job.Store, strings.Join(parts[:i], "/"), parts[i]); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrNamespaceCreate, err)
+		}
+	}
+
+	// Errors reported by PBS in the response body do not fail the request,
+	// so check that the namespace is there now.
+	existing, err = client.ListNamespaces(job.Store)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNamespaceCheck, err)
+	}
+	if !slices.Contains(existing, job.Namespace) {
+		return "", fmt.Errorf("%w: namespace %q still missing on datastore %s", ErrNamespaceCreate, job.Namespace, job.Store)
+	}
+
+	return fmt.Sprintf("created namespace %s on datastore %s", job.Namespace, job.Store), nil
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package backup
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubNamespaces struct {
+	existing []string
+	created  []string
+}
+
+func (s *stubNamespaces) ListNamespaces(store string) ([]string, error) {
+	return slices.Clone(s.existing), nil
+}
+
+func (s *stubNamespaces) CreateNamespace(store, parent, name string) error {
+	ns := name
+	if parent != "" {
+		ns = parent + "/" + name
+	}
+	s.created = append(s.created, ns)
+	s.existing = append(s.existing, ns)
+	return nil
+}
+
+func TestEnsureNamespace(t *testing.T) {
+	t.Run("exists", func(t *testing.T) {
+		client := &stubNamespaces{existing: []string{"", "hosts"}}
+		line, err := ensureNamespace(client, types.Job{Store: "main", Namespace: "hosts"})
+		require.NoError(t, err)
+		assert.Empty(t, line)
+		assert.Empty(t, client.created)
+	})
+
+	t.Run("missing with create", func(t *testing.T) {
+		client := &stubNamespaces{existing: []string{"", "hosts"}}
+		job := types.Job{Store: "main", Namespace: "hosts/web/daily", CreateNamespace: true}
+		line, err := ensureNamespace(client, job)
+		require.NoError(t, err)
+		assert.Equal(t, "created namespace hosts/web/daily on datastore main", line)
+		assert.Equal(t, []string{"hosts/web", "hosts/web/daily"}, client.created)
+	})
+
+	t.Run("missing without create", func(t *testing.T) {
+		client := &stubNamespaces{existing: []string{""}}
+		_, err := ensureNamespace(client, types.Job{Store: "main", Namespace: "hosts"})
+		assert.ErrorIs(t, err, ErrNamespaceMissing)
+		assert.Empty(t, client.created)
+	})
+}
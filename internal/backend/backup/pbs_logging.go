@@ -14,6 +14,8 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
 )
 
+// JunkSubstrings are the default substrings of the proxy log lines that are
+// dropped from task logs.
 var JunkSubstrings = []string{
 	"upload_chunk done:",
 	"POST /dynamic_chunk",
@@ -26,8 +28,8 @@ var JunkSubstrings = []string{
 	"from previous backup.",
 }
 
-func isJunkLog(line string) bool {
-	for _, junk := range JunkSubstrings {
+func isJunkLog(line string, substrings []string) bool {
+	for _, junk := range substrings {
 		if strings.Contains(line, junk) {
 			return true
 		}
@@ -82,7 +84,7 @@ func processPBSProxyLogs(upid, clientLogPath string) (bool, bool, error) {
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		if isJunkLog(line) {
+		if isJunkLog(line, JunkSubstrings) {
 			continue // Skip junk lines
 		}
 		if _, err := tmpWriter.WriteString(line + "\n"); err != nil {
@@ -0,0 +1,263 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	agentTypes "github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	arpcfs "github.com/sonroyaalmerol/pbs-plus/internal/backend/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+)
+
+// MaxPlanExcludedPaths bounds the excluded paths listed in a BackupPlan.
+// Paths past the limit are only counted.
+const MaxPlanExcludedPaths = 10000
+
+// planJobSuffix is appended to the job ID of the agent session a plan walks,
+// so planning never disturbs a running backup of the same job.
+const planJobSuffix = "-plan"
+
+// BackupPlan summarizes what a run of a job would back up.
+type BackupPlan struct {
+	JobID      string `json:"job_id"`
+	FileCount  int64  `json:"file_count"`
+	DirCount   int64  `json:"dir_count"`
+	TotalBytes int64  `json:"total_bytes"`
+	// Excluded lists the files and directories the filters remove. An
+	// excluded directory is listed once, without its content.
+	Excluded          []string `json:"excluded"`
+	ExcludedCount     int64    `json:"excluded_count"`
+	ExcludedTruncated bool     `json:"excluded_truncated"`
+	// Unreadable lists paths that could not be listed or stat'ed.
+	Unreadable []string                 `json:"unreadable,omitempty"`
+	Filters    pattern.EffectiveFilters `json:"filters"`
+	Duration   int64                    `json:"duration"`
+}
+
+// PlanFS is the view of a backup source a plan walks. Paths are relative to
+// the source root, "." being the root itself.
+type PlanFS interface {
+	ReadDir(path string) (agentTypes.ReadDirEntries, error)
+	Attr(path string) (agentTypes.AgentFileInfo, error)
+}
+
+// localPlanFS walks a target on the local filesystem.
+type localPlanFS struct {
+	root string
+}
+
+func (l localPlanFS) ReadDir(dir string) (agentTypes.ReadDirEntries, error) {
+	entries, err := os.ReadDir(filepath.Join(l.root, dir))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(agentTypes.ReadDirEntries, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, agentTypes.AgentDirEntry{
+			Name: entry.Name(),
+			Mode: uint32(entry.Type()),
+		})
+	}
+	return result, nil
+}
+
+func (l localPlanFS) Attr(name string) (agentTypes.AgentFileInfo, error) {
+	info, err := os.Lstat(filepath.Join(l.root, name))
+	if err != nil {
+		return agentTypes.AgentFileInfo{}, err
+	}
+	return agentTypes.AgentFileInfo{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		Mode:    uint32(info.Mode()),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}, nil
+}
+
+// PlanBackup walks the source of job, applies its filters and returns
+// what a run would back up. No PBS task is created and nothing is written
+// to the datastore. Agent targets are walked through a short-lived session
+// in direct mode, without a snapshot.
+func PlanBackup(ctx context.Context, job types.Job, storeInstance *store.Store) (*BackupPlan, error) {
+	target, err := storeInstance.Database.GetTarget(job.Target)
+	if err != nil {
+		return nil, fmt.Errorf("PlanBackup: %w: %v", ErrTargetGet, err)
+	}
+
+	caseInsensitive := utils.IsCaseInsensitiveTarget(target.Path)
+	filters := storeInstance.EffectiveFilters(job, caseInsensitive)
+	exclusions, err := pattern.NewMatcher(filters)
+	if err != nil {
+		return nil, fmt.Errorf("PlanBackup: %w", err)
+	}
+	inclusions, err := storeInstance.InclusionMatcher(job, caseInsensitive)
+	if err != nil {
+		return nil, fmt.Errorf("PlanBackup: %w", err)
+	}
+
+	var fs PlanFS
+	if target.IsAgent {
+		agentFS, cleanup, err := openAgentPlanFS(ctx, storeInstance, job, target)
+		if err != nil {
+			return nil, fmt.Errorf("PlanBackup: %w", err)
+		}
+		defer cleanup()
+		fs = agentFS
+	} else {
+		fs = localPlanFS{root: target.Path}
+	}
+
+	started := time.Now()
+	plan, err := planWalk(ctx, fs, job.Subpath, pattern.Combine(exclusions, inclusions))
+	if err != nil {
+		return nil, fmt.Errorf("PlanBackup: %w", err)
+	}
+	plan.JobID = job.ID
+	plan.Filters = filters
+	plan.Duration = time.Since(started).Milliseconds()
+
+	return plan, nil
+}
+
+// planWalk walks fs from subpath, depth first, skipping what filter
+// excludes. Paths are matched and reported relative to subpath, as
+// proxmox-backup-client sees them.
+func planWalk(ctx context.Context, fs PlanFS, subpath string, filter pattern.PathFilter) (*BackupPlan, error) {
+	root := path.Clean("/" + filepath.ToSlash(subpath))
+	rootRel := strings.TrimPrefix(root, "/")
+	if rootRel == "" {
+		rootRel = "."
+	}
+
+	rootInfo, err := fs.Attr(rootRel)
+	if err != nil {
+		return nil, err
+	}
+	if !rootInfo.IsDir {
+		return nil, fmt.Errorf("source %s is not a directory", root)
+	}
+
+	plan := &BackupPlan{Excluded: []string{}}
+	excluded := func(p string) {
+		plan.ExcludedCount++
+		if len(plan.Excluded) >= MaxPlanExcludedPaths {
+			plan.ExcludedTruncated = true
+			return
+		}
+		plan.Excluded = append(plan.Excluded, p)
+	}
+
+	var walk func(rel string, display string) error
+	walk = func(rel string, display string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entries, err := fs.ReadDir(rel)
+		if err != nil {
+			plan.Unreadable = append(plan.Unreadable, display)
+			return nil
+		}
+
+		for _, entry := range entries {
+			entryRel := path.Join(rel, entry.Name)
+			entryDisplay := path.Join(display, entry.Name)
+			isDir := os.FileMode(entry.Mode).IsDir()
+
+			if filter.Excluded(entryDisplay, isDir) {
+				excluded(entryDisplay)
+				continue
+			}
+
+			if isDir {
+				plan.DirCount++
+				if err := walk(entryRel, entryDisplay); err != nil {
+					return err
+				}
+				continue
+			}
+
+			plan.FileCount++
+			info, err := fs.Attr(entryRel)
+			if err != nil {
+				plan.Unreadable = append(plan.Unreadable, entryDisplay)
+				continue
+			}
+			if info.Mode&uint32(os.ModeType) == 0 {
+				plan.TotalBytes += info.Size
+			}
+		}
+		return nil
+	}
+
+	if err := walk(rootRel, "/"); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// openAgentPlanFS asks the agent of target for a direct-mode session of its
+// own and returns the filesystem it serves together with the function that
+// tears it down.
+func openAgentPlanFS(ctx context.Context, storeInstance *store.Store, job types.Job, target types.Target) (PlanFS, func(), error) {
+	hostname := strings.Split(target.Name, " - ")[0]
+	agentTarget, err := utils.ParseAgentTarget(target.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	drive := agentTarget.Drive
+
+	session, ok := storeInstance.ARPCSessionManager.GetSession(hostname)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrTargetUnreachable, hostname)
+	}
+
+	planId := job.ID + planJobSuffix
+	req := agentTypes.BackupReq{
+		Drive:      drive,
+		JobId:      planId,
+		SourceMode: "direct",
+	}
+
+	resp, err := session.CallContext(ctx, "backup", &req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("starting agent session: %w", err)
+	}
+	if resp.Status != 200 {
+		return nil, nil, fmt.Errorf("starting agent session: %s", resp.Message)
+	}
+
+	cleanup := func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if _, err := session.CallContext(cleanupCtx, "cleanup", &req); err != nil {
+			syslog.L.Error(err).WithMessage("failed to close plan session on agent").WithField("jobId", job.ID).Write()
+		}
+	}
+
+	childKey := hostname + "|" + planId
+	child, _, err := storeInstance.ARPCSessionManager.WaitForSession(ctx, childKey, 10, 500*time.Millisecond)
+	if err != nil {
+		cleanup()
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%w: %v", ErrTargetUnreachable, err)
+	}
+
+	return arpcfs.NewARPCFS(ctx, child, hostname, planId, "direct"), cleanup, nil
+}
@@ -0,0 +1,124 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanWalk(t *testing.T) {
+	root := t.TempDir()
+	fixture := map[string]int{
+		"docs/report.pdf":            1000,
+		"docs/draft.tmp":             50,
+		"docs/keep.tmp":              70,
+		"src/main.go":                300,
+		"src/node_modules/a/a.js":    4000,
+		"src/node_modules/b/b.js":    4000,
+		"home/alice/Downloads/x.iso": 9000,
+		"home/alice/notes.txt":       20,
+		"cache/blob":                 5000,
+		"var/cache":                  10,
+		"empty/.keep":                0,
+	}
+	for name, size := range fixture {
+		p := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, make([]byte, size), 0644))
+	}
+
+	filters := pattern.ResolveFilters(
+		[]string{"*.tmp", "node_modules", "cache/"},
+		[]string{"!keep.tmp", "/home/*/Downloads"},
+		false,
+	)
+	matcher, err := pattern.NewMatcher(filters)
+	require.NoError(t, err)
+
+	t.Run("whole source", func(t *testing.T) {
+		plan, err := planWalk(context.Background(), localPlanFS{root: root}, "", matcher)
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, []string{
+			"/docs/draft.tmp",
+			"/src/node_modules",
+			"/home/alice/Downloads",
+			"/cache",
+		}, plan.Excluded)
+		assert.Equal(t, int64(4), plan.ExcludedCount)
+		assert.False(t, plan.ExcludedTruncated)
+
+		// report.pdf, keep.tmp, main.go, notes.txt, var/cache (a file, so
+		// the directory-only rule skips it) and .keep.
+		assert.Equal(t, int64(6), plan.FileCount)
+		assert.Equal(t, int64(1000+70+300+20+10), plan.TotalBytes)
+		// docs, src, home, home/alice, var, empty.
+		assert.Equal(t, int64(6), plan.DirCount)
+		assert.Empty(t, plan.Unreadable)
+	})
+
+	t.Run("subpath", func(t *testing.T) {
+		plan, err := planWalk(context.Background(), localPlanFS{root: root}, "src", matcher)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"/node_modules"}, plan.Excluded)
+		assert.Equal(t, int64(1), plan.FileCount)
+		assert.Equal(t, int64(300), plan.TotalBytes)
+	})
+
+	t.Run("inclusions", func(t *testing.T) {
+		inclusions, err := pattern.NewInclusionMatcher([]pattern.Pattern{
+			{Value: "/docs"},
+			{Value: "/home/*/notes.txt"},
+			{Value: "/home/*/Downloads"},
+		}, false)
+		require.NoError(t, err)
+
+		plan, err := planWalk(context.Background(), localPlanFS{root: root}, "", pattern.Combine(matcher, inclusions))
+		require.NoError(t, err)
+
+		// Exclusions still apply within the included set.
+		assert.ElementsMatch(t, []string{
+			"/docs/draft.tmp",
+			"/src",
+			"/home/alice/Downloads",
+			"/cache",
+			"/var",
+			"/empty",
+		}, plan.Excluded)
+		// report.pdf, keep.tmp and notes.txt.
+		assert.Equal(t, int64(3), plan.FileCount)
+		assert.Equal(t, int64(1000+70+20), plan.TotalBytes)
+		// docs, and home and home/alice leading to notes.txt.
+		assert.Equal(t, int64(3), plan.DirCount)
+	})
+
+	t.Run("no inclusions", func(t *testing.T) {
+		inclusions, err := pattern.NewInclusionMatcher(nil, false)
+		require.NoError(t, err)
+
+		plan, err := planWalk(context.Background(), localPlanFS{root: root}, "", pattern.Combine(matcher, inclusions))
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), plan.FileCount)
+		assert.Equal(t, int64(4), plan.ExcludedCount)
+	})
+
+	t.Run("missing subpath", func(t *testing.T) {
+		_, err := planWalk(context.Background(), localPlanFS{root: root}, "missing", matcher)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := planWalk(ctx, localPlanFS{root: root}, "", matcher)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
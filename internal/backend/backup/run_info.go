@@ -0,0 +1,141 @@
+//go:build linux
+
+package backup
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fingerprintTimeout bounds the connection observeFingerprint makes.
+const fingerprintTimeout = 5 * time.Second
+
+// Verification states recorded with a job run.
+const (
+	VerifyStateOK     = "ok"
+	VerifyStateFailed = "failed"
+)
+
+var (
+	fingerprintLineRe = regexp.MustCompile(`(?i)^\s*fingerprint:\s*([0-9a-f]{2}(?::[0-9a-f]{2}){31})\s*$`)
+	verifyLineRe      = regexp.MustCompile(`(?i)\bverif(?:y|ication)\b.*\b(ok|successful|failed)\b`)
+	uploadedLineRe    = regexp.MustCompile(`\bhad to backup ([0-9]+(?:\.[0-9]+)?) ([KMGTP]?i?B) of\b`)
+)
+
+var byteUnits = map[string]float64{
+	"B":   1,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+	"PiB": 1 << 50,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"PB":  1e15,
+}
+
+// runInfo holds what a backup run reveals about the datastore it wrote to.
+type runInfo struct {
+	Fingerprint string
+	VerifyState string
+	// BytesUploaded sums what the client had to back up over all archives.
+	BytesUploaded int64
+}
+
+// parseRunInfo scans proxmox-backup-client output for the server certificate
+// fingerprint (printed when the client asks to trust an unknown server) and
+// for the result of a verification, if one ran, and for the amount of data
+// each archive uploaded.
+func parseRunInfo(r io.Reader) (runInfo, error) {
+	var info runInfo
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := fingerprintLineRe.FindStringSubmatch(line); match != nil {
+			info.Fingerprint = strings.ToLower(match[1])
+			continue
+		}
+		if match := uploadedLineRe.FindStringSubmatch(line); match != nil {
+			if value, err := strconv.ParseFloat(match[1], 64); err == nil {
+				info.BytesUploaded += int64(value * byteUnits[match[2]])
+			}
+			continue
+		}
+		if match := verifyLineRe.FindStringSubmatch(line); match != nil {
+			if strings.EqualFold(match[1], "failed") {
+				info.VerifyState = VerifyStateFailed
+			} else if info.VerifyState != VerifyStateFailed {
+				info.VerifyState = VerifyStateOK
+			}
+		}
+	}
+
+	return info, scanner.Err()
+}
+
+// observeFingerprint connects to the datastore server at addr, as the client
+// does, and returns the fingerprint of the certificate it presents. The
+// PBS_FINGERPRINT handed to the client comes from the server's own status, so
+// only a certificate seen on the wire tells whether the server changed.
+func observeFingerprint(ctx context.Context, addr string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, fingerprintTimeout)
+	defer cancel()
+
+	// The certificate is checked by its fingerprint, like the client does,
+	// rather than against a CA.
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", errors.New("server presented no certificate")
+	}
+	return certFingerprint(certs[0]), nil
+}
+
+// certFingerprint returns the SHA-256 fingerprint of cert in the form
+// proxmox-backup-client prints it.
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// readRunInfo collects the run info from the client log at path. When the
+// client did not print a fingerprint, observed, the fingerprint seen when the
+// client started, is used.
+func readRunInfo(path string, observed string) (runInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return runInfo{Fingerprint: observed}, err
+	}
+	defer file.Close()
+
+	info, err := parseRunInfo(file)
+	if info.Fingerprint == "" {
+		info.Fingerprint = observed
+	}
+	return info, err
+}
@@ -0,0 +1,114 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFingerprint = "64:d3:ff:3a:50:38:53:5a:9b:f7:50:52:f8:2a:b1:d5:21:9c:54:8d:c3:7a:62:f5:24:4b:96:b5:4e:d4:de:03"
+
+func TestParseRunInfoWithFingerprintPrompt(t *testing.T) {
+	output := strings.Join([]string{
+		"Starting backup: host/pve01/2025-01-01T00:00:00Z",
+		"fingerprint: " + strings.ToUpper(testFingerprint),
+		"Are you sure you want to continue connecting? (y/n): y",
+		"root.pxar: had to backup 1.2 GiB of 10 GiB (compressed 900 MiB) in 12.00s",
+		"verify datastore snapshot: OK",
+		"End Time: 2025-01-01T00:00:12Z",
+	}, "\n")
+
+	info, err := parseRunInfo(strings.NewReader(output))
+	require.NoError(t, err)
+	assert.Equal(t, testFingerprint, info.Fingerprint)
+	assert.Equal(t, VerifyStateOK, info.VerifyState)
+	assert.Equal(t, int64(1288490188), info.BytesUploaded)
+}
+
+func TestParseRunInfoSumsArchives(t *testing.T) {
+	output := strings.Join([]string{
+		"root.pxar: had to backup 512 KiB of 4 MiB (compressed 100 KiB) in 0.10s",
+		"catalog.pcat1: had to backup 2 B of 2 B (compressed 2 B) in 0.00s",
+	}, "\n")
+
+	info, err := parseRunInfo(strings.NewReader(output))
+	require.NoError(t, err)
+	assert.Equal(t, int64(512*1024+2), info.BytesUploaded)
+}
+
+func TestParseRunInfoWithoutFingerprintPrompt(t *testing.T) {
+	output := strings.Join([]string{
+		"Starting backup: host/pve01/2025-01-01T00:00:00Z",
+		"root.pxar: had to backup 1.2 GiB of 10 GiB (compressed 900 MiB) in 12.00s",
+		"End Time: 2025-01-01T00:00:12Z",
+	}, "\n")
+
+	info, err := parseRunInfo(strings.NewReader(output))
+	require.NoError(t, err)
+	assert.Empty(t, info.Fingerprint)
+	assert.Empty(t, info.VerifyState)
+
+	// Without a prompt the fingerprint observed on the wire is recorded.
+	logPath := filepath.Join(t.TempDir(), "client.log")
+	require.NoError(t, os.WriteFile(logPath, []byte(output), 0644))
+
+	info, err = readRunInfo(logPath, testFingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, testFingerprint, info.Fingerprint)
+}
+
+func TestObserveFingerprint(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	fingerprint, err := observeFingerprint(context.Background(), srv.Listener.Addr().String())
+	require.NoError(t, err)
+	assert.Equal(t, certFingerprint(srv.Certificate()), fingerprint)
+	assert.Regexp(t, fingerprintLineRe, "fingerprint: "+fingerprint)
+
+	srv.Close()
+	_, err = observeFingerprint(context.Background(), srv.Listener.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestParseRunInfoVerificationFailure(t *testing.T) {
+	output := strings.Join([]string{
+		"verify chunk 1: ok",
+		"verification failed: chunk checksum mismatch",
+	}, "\n")
+
+	info, err := parseRunInfo(strings.NewReader(output))
+	require.NoError(t, err)
+	assert.Equal(t, VerifyStateFailed, info.VerifyState)
+}
+
+func TestFailedPathsLines(t *testing.T) {
+	assert.Empty(t, failedPathsLines(nil))
+
+	lines := failedPathsLines(map[string]string{
+		"/data/b": "permission denied",
+		"/data/a": "input/output error",
+	})
+	assert.Equal(t, []string{
+		"WARNING: 2 entries could not be read by the agent and were skipped",
+		"WARNING: skipped /data/a: input/output error",
+		"WARNING: skipped /data/b: permission denied",
+	}, lines)
+
+	many := make(map[string]string, maxFailedPathLines+5)
+	for i := range maxFailedPathLines + 5 {
+		many[filepath.Join("/data", strings.Repeat("x", i+1))] = "permission denied"
+	}
+	lines = failedPathsLines(many)
+	require.Len(t, lines, maxFailedPathLines+2)
+	assert.Equal(t, "WARNING: ... and 5 more", lines[len(lines)-1])
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/proxmox"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+)
+
+// estimateBackupSize guesses how much data a run of job will read. The size
+// of the previous snapshot is preferred; without history the used space of
+// the source volume is taken as an upper bound.
+//
+// The estimate is the logical size of the data, before deduplication and
+// compression. Chunks already in the datastore are not stored again, so a
+// run usually takes far less space than estimated: the guard errs on the
+// side of blocking, which suits a quota meant to catch runaway sources.
+func estimateBackupSize(job types.Job, target types.Target) (int64, string) {
+	isAgent := utils.IsAgentPath(target.Path)
+
+	if backupId, err := getBackupId(isAgent, job.Target); err == nil {
+		snapshot, err := proxmox.Session.GetLatestSnapshot(job.Store, job.Namespace, backupId)
+		if err == nil && snapshot.Size > 0 {
+			return snapshot.Size, "previous snapshot"
+		}
+	}
+
+	if isAgent {
+		if target.DriveUsedBytes > 0 {
+			return int64(target.DriveUsedBytes), "agent drive usage"
+		}
+		return 0, ""
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(filepath.Join(target.Path, job.Subpath), &stat); err == nil {
+		used := int64(stat.Blocks-stat.Bfree) * int64(stat.Bsize)
+		if used > 0 {
+			return used, "source filesystem usage"
+		}
+	}
+
+	return 0, ""
+}
+
+// evaluateSizeGuard compares an estimate against the job quota and the free
+// space of the datastore. A zero maxSize or a negative avail disables the
+// respective check.
+func evaluateSizeGuard(estimate, maxSize, avail int64) (bool, string) {
+	if maxSize > 0 && estimate > maxSize {
+		return true, fmt.Sprintf("exceeds job quota of %s", utils.HumanReadableBytes(maxSize))
+	}
+	if avail >= 0 && estimate > avail {
+		return true, fmt.Sprintf("exceeds datastore free space of %s", utils.HumanReadableBytes(avail))
+	}
+	return false, ""
+}
+
+// sizeGuardError is returned when the size guard blocks a run. It carries
+// the decision line, since a blocked run never gets a task log of its own.
+type sizeGuardError struct {
+	decision string
+	err      error
+}
+
+func (e *sizeGuardError) Error() string { return e.err.Error() }
+func (e *sizeGuardError) Unwrap() error { return e.err }
+
+// TaskLogLines returns the lines the task log of a run that failed to start
+// should carry besides the error itself, such as why the size guard blocked
+// it.
+func TaskLogLines(err error) []string {
+	var guardErr *sizeGuardError
+	if errors.As(err, &guardErr) {
+		return []string{guardErr.decision, sizeGuardDedupNote}
+	}
+	return nil
+}
+
+// sizeGuardDedupNote tells the reader of a blocked run's task log what the
+// estimate leaves out.
+const sizeGuardDedupNote = "size guard: the estimate is the logical size before deduplication; the space actually used is usually smaller"
+
+// checkSizeGuard runs the size guard configured on job and returns a line
+// describing the decision for the task log. The returned error is non-nil
+// only when the guard blocks the run.
+func checkSizeGuard(job types.Job, target types.Target) (string, error) {
+	estimate, source := estimateBackupSize(job, target)
+	if estimate <= 0 {
+		return "size guard: unable to estimate backup size, skipping check", nil
+	}
+
+	avail := int64(-1)
+	if status, err := proxmox.Session.GetDatastoreStatus(job.Store); err == nil {
+		avail = status.Avail
+	}
+
+	exceeded, reason := evaluateSizeGuard(estimate, job.MaxSize, avail)
+	estimateStr := fmt.Sprintf("estimated size %s (from %s)", utils.HumanReadableBytes(estimate), source)
+	if !exceeded {
+		return fmt.Sprintf("size guard: %s is within limits", estimateStr), nil
+	}
+
+	if job.SizeGuard == types.SizeGuardBlock {
+		decision := fmt.Sprintf("size guard: %s %s, refusing to start", estimateStr, reason)
+		return decision, &sizeGuardError{
+			decision: decision,
+			err:      fmt.Errorf("%w: %s %s", ErrSizeGuardExceeded, estimateStr, reason),
+		}
+	}
+
+	return fmt.Sprintf("size guard: WARNING: %s %s, continuing", estimateStr, reason), nil
+}
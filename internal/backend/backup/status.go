@@ -17,24 +17,17 @@ func updateJobStatus(succeeded bool, job types.Job, task proxmox.Task, storeInst
 		return err
 	}
 
-	// Update job status
-	latestJob, err := storeInstance.Database.GetJob(job.ID)
-	if err != nil {
-		syslog.L.Error(err).WithMessage("unable to get job").Write()
-		return err
+	status := types.JobStatus{
+		CurrentPID:         job.CurrentPID,
+		LastRunUpid:        taskFound.UPID,
+		LastRunFingerprint: job.LastRunFingerprint,
+		LastRunVerifyState: job.LastRunVerifyState,
 	}
-
-	latestJob.CurrentPID = job.CurrentPID
-	latestJob.LastRunUpid = taskFound.UPID
-	latestJob.LastRunState = taskFound.Status
-	latestJob.LastRunEndtime = taskFound.EndTime
-
 	if succeeded {
-		latestJob.LastSuccessfulUpid = taskFound.UPID
-		latestJob.LastSuccessfulEndtime = task.EndTime
+		status.LastSuccessfulUpid = taskFound.UPID
 	}
 
-	if err := storeInstance.Database.UpdateJob(nil, latestJob); err != nil {
+	if err := storeInstance.Database.UpdateJobStatus(nil, job.ID, status); err != nil {
 		syslog.L.Error(err).WithMessage("unable to update job").Write()
 		return err
 	}
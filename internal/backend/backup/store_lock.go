@@ -0,0 +1,51 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexflint/go-filemutex"
+)
+
+const storeLockPollInterval = time.Second
+
+// storeLockPath returns the lock file shared by all jobs writing to store.
+func storeLockPath(store string) string {
+	return fmt.Sprintf("/tmp/pbs-plus-mutex-store-%s", store)
+}
+
+// acquireStoreLock blocks until no other serialized job is writing to the
+// same datastore, or until ctx is done. It returns the held lock and how long
+// the caller waited for it.
+func acquireStoreLock(ctx context.Context, lockPath string) (*filemutex.FileMutex, time.Duration, error) {
+	storeMutex, err := filemutex.New(lockPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrStoreMutexCreation, err)
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(storeLockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := storeMutex.TryLock()
+		if err == nil {
+			return storeMutex, time.Since(start), nil
+		}
+		if !errors.Is(err, filemutex.AlreadyLocked) {
+			_ = storeMutex.Close()
+			return nil, time.Since(start), fmt.Errorf("%w: %v", ErrStoreMutexLock, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = storeMutex.Close()
+			return nil, time.Since(start), fmt.Errorf("%w: %v", ErrStoreMutexLock, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreLockSerializesJobsOnSameStore(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "store-lock")
+
+	first, waited, err := acquireStoreLock(context.Background(), lockPath)
+	require.NoError(t, err)
+	assert.Less(t, waited, storeLockPollInterval)
+
+	var wg sync.WaitGroup
+	var secondWaited time.Duration
+	var secondErr error
+	acquired := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		second, waited, err := acquireStoreLock(context.Background(), lockPath)
+		secondWaited, secondErr = waited, err
+		close(acquired)
+		if err == nil {
+			_ = second.Close()
+		}
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second job acquired the store lock while the first job held it")
+	case <-time.After(2 * storeLockPollInterval):
+	}
+
+	require.NoError(t, first.Close())
+	wg.Wait()
+
+	require.NoError(t, secondErr)
+	assert.GreaterOrEqual(t, secondWaited, 2*storeLockPollInterval)
+}
+
+func TestStoreLockHonorsContext(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "store-lock")
+
+	held, _, err := acquireStoreLock(context.Background(), lockPath)
+	require.NoError(t, err)
+	defer held.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+
+	_, _, err = acquireStoreLock(ctx, lockPath)
+	assert.ErrorIs(t, err, ErrStoreMutexLock)
+}
+
+func TestStoreLockPathPerStore(t *testing.T) {
+	assert.NotEqual(t, storeLockPath("store-a"), storeLockPath("store-b"))
+	assert.Equal(t, storeLockPath("store-a"), storeLockPath("store-a"))
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/alexflint/go-filemutex"
+)
+
+const targetSlotPollInterval = time.Second
+
+// targetSlotPath returns the lock file of one of the concurrency slots of
+// target. Target names are escaped since they may contain slashes.
+func targetSlotPath(target string, slot int) string {
+	return fmt.Sprintf("/tmp/pbs-plus-mutex-target-%s-%d", url.PathEscape(target), slot)
+}
+
+// acquireTargetSlot takes one of the maxConcurrency slots of target. When
+// all slots are held it fails with ErrTargetBusy if failWhenBusy is set, and
+// otherwise waits for a slot to free up or for ctx to be done. It returns
+// the held slot and how long the caller waited for it.
+func acquireTargetSlot(ctx context.Context, slotPath func(slot int) string, maxConcurrency int, failWhenBusy bool) (*filemutex.FileMutex, time.Duration, error) {
+	slots := make([]*filemutex.FileMutex, 0, maxConcurrency)
+	closeSlots := func(keep *filemutex.FileMutex) {
+		for _, slot := range slots {
+			if slot != keep {
+				_ = slot.Close()
+			}
+		}
+	}
+	for i := 0; i < maxConcurrency; i++ {
+		slot, err := filemutex.New(slotPath(i))
+		if err != nil {
+			closeSlots(nil)
+			return nil, 0, fmt.Errorf("%w: %v", ErrTargetMutexCreation, err)
+		}
+		slots = append(slots, slot)
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(targetSlotPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, slot := range slots {
+			err := slot.TryLock()
+			if err == nil {
+				closeSlots(slot)
+				return slot, time.Since(start), nil
+			}
+			if !errors.Is(err, filemutex.AlreadyLocked) {
+				closeSlots(nil)
+				return nil, time.Since(start), fmt.Errorf("%w: %v", ErrTargetMutexLock, err)
+			}
+		}
+
+		if failWhenBusy {
+			closeSlots(nil)
+			return nil, time.Since(start), fmt.Errorf("%w (%d running)", ErrTargetBusy, maxConcurrency)
+		}
+
+		select {
+		case <-ctx.Done():
+			closeSlots(nil)
+			return nil, time.Since(start), fmt.Errorf("%w: %v", ErrTargetMutexLock, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
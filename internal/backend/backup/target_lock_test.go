@@ -0,0 +1,100 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempSlotPath(t *testing.T) func(slot int) string {
+	dir := t.TempDir()
+	return func(slot int) string {
+		return filepath.Join(dir, fmt.Sprintf("target-slot-%d", slot))
+	}
+}
+
+func TestTargetSlotsCapConcurrentJobs(t *testing.T) {
+	slotPath := tempSlotPath(t)
+	const (
+		maxConcurrency = 2
+		jobs           = 5
+	)
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	errs := make(chan error, jobs)
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slot, _, err := acquireTargetSlot(context.Background(), slotPath, maxConcurrency, false)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer slot.Close()
+
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(200 * time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(maxConcurrency), peak.Load())
+}
+
+func TestTargetSlotsFailWhenBusy(t *testing.T) {
+	slotPath := tempSlotPath(t)
+
+	for i := 0; i < 2; i++ {
+		held, waited, err := acquireTargetSlot(context.Background(), slotPath, 2, true)
+		require.NoError(t, err)
+		assert.Less(t, waited, targetSlotPollInterval)
+		defer held.Close()
+	}
+
+	start := time.Now()
+	_, _, err := acquireTargetSlot(context.Background(), slotPath, 2, true)
+	assert.ErrorIs(t, err, ErrTargetBusy)
+	assert.Less(t, time.Since(start), targetSlotPollInterval, "a busy target must fail without waiting")
+}
+
+func TestTargetSlotsHonorContext(t *testing.T) {
+	slotPath := tempSlotPath(t)
+
+	held, _, err := acquireTargetSlot(context.Background(), slotPath, 1, false)
+	require.NoError(t, err)
+	defer held.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+
+	_, _, err = acquireTargetSlot(ctx, slotPath, 1, false)
+	assert.ErrorIs(t, err, ErrTargetMutexLock)
+}
+
+func TestTargetSlotPathPerTarget(t *testing.T) {
+	assert.NotEqual(t, targetSlotPath("laptop - C", 0), targetSlotPath("laptop - D", 0))
+	assert.NotEqual(t, targetSlotPath("laptop - C", 0), targetSlotPath("laptop - C", 1))
+	assert.Equal(t, "/tmp", filepath.Dir(targetSlotPath("share/with/slashes", 0)))
+}
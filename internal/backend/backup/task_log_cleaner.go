@@ -0,0 +1,240 @@
+//go:build linux
+
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// Environment variables configuring the task log cleaner of the proxy.
+const (
+	// TaskLogCleanupEnv set to "true" enables the cleaner.
+	TaskLogCleanupEnv = "PBS_PLUS_TASK_LOG_CLEANUP"
+	// TaskLogCleanupIntervalEnv is the time between two passes, such as "6h".
+	TaskLogCleanupIntervalEnv = "PBS_PLUS_TASK_LOG_CLEANUP_INTERVAL"
+	// TaskLogMaxAgeEnv, when set, deletes task logs last written longer ago,
+	// such as "720h".
+	TaskLogMaxAgeEnv = "PBS_PLUS_TASK_LOG_MAX_AGE"
+)
+
+const (
+	DefaultTaskLogCleanupInterval = 24 * time.Hour
+	MinTaskLogCleanupInterval     = 10 * time.Minute
+)
+
+// TaskLogCleanerConfig configures the periodic removal of junk lines from
+// task logs, the job of the clean-task-logs command.
+type TaskLogCleanerConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	// MaxAge deletes finished task logs older than it. Zero keeps them.
+	MaxAge time.Duration
+}
+
+// TaskLogCleanerConfigFromEnv reads the cleaner configuration from the
+// environment through getenv. It is disabled unless TaskLogCleanupEnv is
+// set.
+func TaskLogCleanerConfigFromEnv(getenv func(string) string) (TaskLogCleanerConfig, error) {
+	config := TaskLogCleanerConfig{Interval: DefaultTaskLogCleanupInterval}
+
+	if raw := strings.TrimSpace(getenv(TaskLogCleanupEnv)); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s %q: %w", TaskLogCleanupEnv, raw, err)
+		}
+		config.Enabled = enabled
+	}
+
+	if raw := strings.TrimSpace(getenv(TaskLogCleanupIntervalEnv)); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s %q: %w", TaskLogCleanupIntervalEnv, raw, err)
+		}
+		config.Interval = max(interval, MinTaskLogCleanupInterval)
+	}
+
+	if raw := strings.TrimSpace(getenv(TaskLogMaxAgeEnv)); raw != "" {
+		maxAge, err := time.ParseDuration(raw)
+		if err != nil || maxAge < 0 {
+			return config, fmt.Errorf("invalid %s %q", TaskLogMaxAgeEnv, raw)
+		}
+		config.MaxAge = maxAge
+	}
+
+	return config, nil
+}
+
+// TaskLogCleanReport tells what a pass of CleanTaskLogs did.
+type TaskLogCleanReport struct {
+	LinesRemoved int64
+	FilesCleaned int
+	FilesPruned  int
+	// FilesSkipped counts the logs of tasks still running.
+	FilesSkipped int
+}
+
+// CleanTaskLogs removes the lines containing any of substrings from the
+// finished task logs in the subdirectories of rootDir, and deletes the
+// finished logs last written before now minus maxAge when maxAge is not
+// zero. Logs of running tasks, listed in rootDir/active or without their
+// final TASK status line yet, are never touched.
+func CleanTaskLogs(rootDir string, substrings []string, maxAge time.Duration, now time.Time) (TaskLogCleanReport, error) {
+	var report TaskLogCleanReport
+
+	running, err := runningTasks(filepath.Join(rootDir, "active"))
+	if err != nil {
+		return report, err
+	}
+
+	entries, err := os.ReadDir(rootDir)
+	if err != nil {
+		return report, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		err := filepath.WalkDir(filepath.Join(rootDir, entry.Name()), func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			if running[d.Name()] {
+				report.FilesSkipped++
+				return nil
+			}
+			finished, err := taskLogFinished(path)
+			if err != nil {
+				return err
+			}
+			if !finished {
+				report.FilesSkipped++
+				return nil
+			}
+
+			if maxAge > 0 {
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				if now.Sub(info.ModTime()) > maxAge {
+					if err := os.Remove(path); err != nil {
+						return err
+					}
+					report.FilesPruned++
+					return nil
+				}
+			}
+
+			count, err := countJunkLines(path, substrings)
+			if err != nil || count == 0 {
+				return err
+			}
+			removed, err := processFile(path, substrings)
+			if err != nil {
+				return err
+			}
+			report.LinesRemoved += removed
+			report.FilesCleaned++
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// runningTasks returns the UPIDs of the running tasks listed in the active
+// file of the task log directory. Finished tasks are listed there too, with
+// their end time and status after the UPID.
+func runningTasks(activePath string) (map[string]bool, error) {
+	running := make(map[string]bool)
+
+	file, err := os.Open(activePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return running, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 1 {
+			running[fields[0]] = true
+		}
+	}
+	return running, scanner.Err()
+}
+
+// taskLogFinished reports whether the task log at path ends with the status
+// line written when its task finishes.
+func taskLogFinished(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	const tailSize = 4096
+	offset := max(info.Size()-tailSize, 0)
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return false, err
+	}
+
+	tail = bytes.TrimRight(tail, "\n")
+	lastLine := tail[bytes.LastIndexByte(tail, '\n')+1:]
+	return bytes.HasPrefix(lastLine, []byte("TASK ")), nil
+}
+
+// RunTaskLogCleaner cleans the task logs in rootDir of the default junk
+// lines every config.Interval until ctx is done.
+func RunTaskLogCleaner(ctx context.Context, rootDir string, config TaskLogCleanerConfig) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := CleanTaskLogs(rootDir, JunkSubstrings, config.MaxAge, time.Now())
+		if err != nil {
+			syslog.L.Error(err).WithMessage("failed to clean task logs").Write()
+		} else {
+			syslog.L.Info().
+				WithMessage("cleaned task logs").
+				WithField("linesRemoved", report.LinesRemoved).
+				WithField("filesCleaned", report.FilesCleaned).
+				WithField("filesPruned", report.FilesPruned).
+				WithField("filesSkipped", report.FilesSkipped).
+				Write()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
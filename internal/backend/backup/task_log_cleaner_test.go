@@ -0,0 +1,92 @@
+//go:build linux
+
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanTaskLogs(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+
+	const (
+		runningUPID = "UPID:pbs:00000A01:0000B001:00000000:65000001:backup:store:root@pam:"
+		listedUPID  = "UPID:pbs:00000A02:0000B002:00000000:65000002:backup:store:root@pam:"
+	)
+	finished := filepath.Join(root, "0A", "UPID:pbs:00000A00:0000B000:00000000:65000000:backup:store:root@pam:")
+	running := filepath.Join(root, "0A", runningUPID)
+	listed := filepath.Join(root, "0B", listedUPID)
+	old := filepath.Join(root, "0B", "UPID:pbs:00000A03:0000B003:00000000:64000000:backup:store:root@pam:")
+	clean := filepath.Join(root, "0C", "UPID:pbs:00000A04:0000B004:00000000:65000004:backup:store:root@pam:")
+
+	files := map[string]string{
+		finished: "starting backup\nPOST /dynamic_chunk\nupload_chunk done: 1\nbackup finished\nTASK OK\n",
+		// Still being written: no final status line yet.
+		running: "starting backup\nPOST /dynamic_chunk\n",
+		// Ends like a finished log, but the active file says it runs.
+		listed: "POST /dynamic_chunk\nTASK OK\n",
+		old:    "POST /dynamic_chunk\nTASK ERROR: failed\n",
+		clean:  "nothing to clean\nTASK WARNINGS: 1\n",
+	}
+	for path, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	require.NoError(t, os.Chtimes(old, now.Add(-60*24*time.Hour), now.Add(-60*24*time.Hour)))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "active"), []byte(
+		runningUPID+"\n"+
+			listedUPID+"\n"+
+			"UPID:pbs:00000A00:0000B000:00000000:65000000:backup:store:root@pam: 65000010 OK\n"), 0644))
+
+	t.Run("JunkOnly", func(t *testing.T) {
+		report, err := CleanTaskLogs(root, JunkSubstrings, 0, now)
+		require.NoError(t, err)
+		assert.Equal(t, TaskLogCleanReport{LinesRemoved: 3, FilesCleaned: 2, FilesSkipped: 2}, report)
+
+		assert.Equal(t, "starting backup\nbackup finished\nTASK OK\n", readFile(t, finished))
+		assert.Equal(t, "TASK ERROR: failed\n", readFile(t, old))
+		assert.Equal(t, files[running], readFile(t, running), "running task log modified")
+		assert.Equal(t, files[listed], readFile(t, listed), "active task log modified")
+		assert.Equal(t, files[clean], readFile(t, clean))
+	})
+
+	t.Run("MaxAge", func(t *testing.T) {
+		report, err := CleanTaskLogs(root, JunkSubstrings, 30*24*time.Hour, now)
+		require.NoError(t, err)
+		assert.Equal(t, TaskLogCleanReport{FilesPruned: 1, FilesSkipped: 2}, report)
+
+		assert.NoFileExists(t, old)
+		assert.FileExists(t, finished)
+		assert.FileExists(t, running)
+	})
+}
+
+func TestTaskLogCleanerConfigFromEnv(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	config, err := TaskLogCleanerConfigFromEnv(env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, TaskLogCleanerConfig{Interval: DefaultTaskLogCleanupInterval}, config)
+
+	config, err = TaskLogCleanerConfigFromEnv(env(map[string]string{
+		TaskLogCleanupEnv:         "true",
+		TaskLogCleanupIntervalEnv: "1m",
+		TaskLogMaxAgeEnv:          "720h",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, TaskLogCleanerConfig{Enabled: true, Interval: MinTaskLogCleanupInterval, MaxAge: 720 * time.Hour}, config)
+
+	_, err = TaskLogCleanerConfigFromEnv(env(map[string]string{TaskLogCleanupEnv: "sometimes"}))
+	assert.Error(t, err)
+	_, err = TaskLogCleanerConfigFromEnv(env(map[string]string{TaskLogMaxAgeEnv: "-1h"}))
+	assert.Error(t, err)
+}
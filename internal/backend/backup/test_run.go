@@ -0,0 +1,99 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/proxmox"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// TestRunOptions redirects a backup to scratch space so a job configuration
+// can be validated end-to-end without touching its real destination.
+type TestRunOptions struct {
+	// Store overrides the datastore of the job. Empty keeps the job's store.
+	Store string
+	// Namespace is the scratch namespace. Empty uses constants.TestRunNamespace.
+	Namespace string
+	// Prune removes the test snapshot once the backup has finished.
+	Prune bool
+}
+
+// apply points job at the scratch destination, which is created if needed.
+func (opts *TestRunOptions) apply(job *types.Job) {
+	job.CreateNamespace = true
+	if opts.Store != "" {
+		job.Store = opts.Store
+	}
+	if opts.Namespace != "" {
+		job.Namespace = opts.Namespace
+	} else {
+		job.Namespace = constants.TestRunNamespace
+	}
+}
+
+// mutexPath returns the lock held while a test run of jobId is running. It
+// is not the lock of the job itself, so a test run never keeps the scheduled
+// run of the job from starting; it only keeps two test runs of the same job
+// apart.
+func (opts *TestRunOptions) mutexPath(jobId string) string {
+	return fmt.Sprintf("/tmp/pbs-plus-mutex-test-run-%s", jobId)
+}
+
+// finish prunes the test snapshot if asked to and returns the summary of the
+// run, written to the task log.
+func (opts *TestRunOptions) finish(job types.Job, backupId string, info runInfo) []string {
+	lines := []string{
+		fmt.Sprintf("test run finished: uploaded %d bytes to datastore %s, namespace %s",
+			info.BytesUploaded, job.Store, job.Namespace),
+	}
+	if info.VerifyState != "" {
+		lines = append(lines, fmt.Sprintf("test run: verification %s", info.VerifyState))
+	}
+	if !opts.Prune {
+		return append(lines, fmt.Sprintf("test run: kept backup group %s", backupId))
+	}
+
+	if err := opts.prune(job, backupId); err != nil {
+		return append(lines, fmt.Sprintf("test run: failed to prune backup group %s: %v", backupId, err))
+	}
+	return append(lines, fmt.Sprintf("test run: pruned backup group %s", backupId))
+}
+
+// prune deletes the backup group written by the test run.
+func (opts *TestRunOptions) prune(job types.Job, backupId string) error {
+	if err := proxmox.Session.DeleteBackupGroup(job.Store, job.Namespace, backupId); err != nil {
+		syslog.L.Error(err).
+			WithMessage("failed to prune test run data").
+			WithField("jobId", job.ID).
+			WithField("store", job.Store).
+			WithField("namespace", job.Namespace).
+			Write()
+		return err
+	}
+
+	syslog.L.Info().
+		WithMessage("pruned test run data").
+		WithField("jobId", job.ID).
+		WithField("store", job.Store).
+		WithField("namespace", job.Namespace).
+		Write()
+	return nil
+}
+
+// RunTestBackup runs job against the scratch destination described by opts.
+// The real client and network path are exercised, but the job's run status
+// and retry schedule are left untouched.
+func RunTestBackup(
+	ctx context.Context,
+	job types.Job,
+	storeInstance *store.Store,
+	opts TestRunOptions,
+) (*BackupOperation, error) {
+	return runBackup(ctx, job, storeInstance, false, &opts)
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package backup
+
+import (
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestRunMutexPath(t *testing.T) {
+	opts := TestRunOptions{}
+	assert.Equal(t, "/tmp/pbs-plus-mutex-test-run-nightly", opts.mutexPath("nightly"))
+}
+
+func TestTestRunFinishSummary(t *testing.T) {
+	opts := TestRunOptions{}
+	job := types.Job{ID: "nightly", Store: "scratch", Namespace: "pbs-plus-test"}
+
+	lines := opts.finish(job, "host", runInfo{BytesUploaded: 2048, VerifyState: "ok"})
+	assert.Equal(t, []string{
+		"test run finished: uploaded 2048 bytes to datastore scratch, namespace pbs-plus-test",
+		"test run: verification ok",
+		"test run: kept backup group host",
+	}, lines)
+}
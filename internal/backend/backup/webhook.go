@@ -0,0 +1,124 @@
+//go:build linux
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// Run states reported to job webhooks.
+const (
+	WebhookStateSuccess   = "success"
+	WebhookStateFailed    = "failed"
+	WebhookStateCancelled = "cancelled"
+)
+
+// WebhookPayload is posted as JSON to the WebhookURL of a job when a run
+// ends.
+type WebhookPayload struct {
+	JobID string `json:"jobId"`
+	State string `json:"state"`
+	// Duration of the run in seconds.
+	Duration int64  `json:"duration"`
+	UPID     string `json:"upid"`
+	// Bytes the client uploaded to the datastore.
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+}
+
+// webhookAttempts and webhookBackoff bound the delivery of one payload. The
+// pause doubles after each failed attempt.
+var (
+	webhookAttempts = 3
+	webhookBackoff  = 2 * time.Second
+	webhookClient   = &http.Client{Timeout: 10 * time.Second}
+)
+
+// NotifyWebhook posts payload to the WebhookURL of job, if it has one.
+// Failures are logged; they never affect the outcome of the run.
+func NotifyWebhook(ctx context.Context, job types.Job, payload WebhookPayload) {
+	if job.WebhookURL == "" {
+		return
+	}
+
+	if err := sendWebhook(ctx, job.WebhookURL, payload); err != nil {
+		syslog.L.Error(err).
+			WithMessage("failed to deliver job webhook").
+			WithField("jobId", job.ID).
+			Write()
+	}
+}
+
+// sendWebhook posts payload to url, retrying network errors, 429 and 5xx
+// responses with exponential backoff. Other responses are final.
+func sendWebhook(ctx context.Context, url string, payload WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("sendWebhook: %w", err)
+	}
+
+	backoff := webhookBackoff
+	var lastErr error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		retry, err := postWebhook(ctx, url, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == webhookAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("sendWebhook: %w (last error: %v)", ctx.Err(), lastErr)
+		}
+		backoff *= 2
+	}
+
+	return fmt.Errorf("sendWebhook: %w", lastErr)
+}
+
+// postWebhook makes a single delivery attempt and reports whether a failure
+// is worth retrying.
+func postWebhook(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+}
+
+// NotifyRunError reports to the webhook of job a run that failed before
+// proxmox-backup-client started. upid is that of the error task written for
+// the failure, if any.
+func NotifyRunError(ctx context.Context, job types.Job, started time.Time, upid string, runErr error) {
+	NotifyWebhook(ctx, job, WebhookPayload{
+		JobID:    job.ID,
+		State:    WebhookStateFailed,
+		Duration: int64(time.Since(started).Seconds()),
+		UPID:     upid,
+		Error:    runErr.Error(),
+	})
+}
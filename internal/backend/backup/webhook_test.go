@@ -0,0 +1,121 @@
+//go:build linux
+
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookRecorder answers with the given statuses in turn, then 200, and
+// keeps the decoded payloads it received.
+func webhookRecorder(t *testing.T, statuses ...int) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+
+	var received []map[string]any
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var payload map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received = append(received, payload)
+
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &received
+}
+
+func fastWebhookRetries(t *testing.T) {
+	t.Helper()
+	oldBackoff := webhookBackoff
+	webhookBackoff = time.Millisecond
+	t.Cleanup(func() { webhookBackoff = oldBackoff })
+}
+
+func TestNotifyWebhookPayload(t *testing.T) {
+	fastWebhookRetries(t)
+
+	t.Run("success", func(t *testing.T) {
+		server, received := webhookRecorder(t)
+		job := types.Job{ID: "daily", WebhookURL: server.URL}
+
+		NotifyWebhook(context.Background(), job, WebhookPayload{
+			JobID:    job.ID,
+			State:    WebhookStateSuccess,
+			Duration: 42,
+			UPID:     "UPID:pbs:0000:0000:0000:backup:daily:root@pam:",
+			Bytes:    1024,
+		})
+
+		require.Len(t, *received, 1)
+		assert.Equal(t, map[string]any{
+			"jobId":    "daily",
+			"state":    "success",
+			"duration": float64(42),
+			"upid":     "UPID:pbs:0000:0000:0000:backup:daily:root@pam:",
+			"bytes":    float64(1024),
+		}, (*received)[0])
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		server, received := webhookRecorder(t)
+		job := types.Job{ID: "daily", WebhookURL: server.URL}
+
+		NotifyRunError(context.Background(), job, time.Now().Add(-3*time.Second), "", errors.New("target unreachable"))
+
+		require.Len(t, *received, 1)
+		assert.Equal(t, map[string]any{
+			"jobId":    "daily",
+			"state":    "failed",
+			"duration": float64(3),
+			"upid":     "",
+			"bytes":    float64(0),
+			"error":    "target unreachable",
+		}, (*received)[0])
+	})
+}
+
+func TestSendWebhookRetries(t *testing.T) {
+	fastWebhookRetries(t)
+
+	t.Run("transient failures are retried", func(t *testing.T) {
+		server, received := webhookRecorder(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		require.NoError(t, sendWebhook(context.Background(), server.URL, WebhookPayload{JobID: "daily"}))
+		assert.Len(t, *received, 3)
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		server, received := webhookRecorder(t, 500, 502, 503, 504)
+		assert.Error(t, sendWebhook(context.Background(), server.URL, WebhookPayload{JobID: "daily"}))
+		assert.Len(t, *received, webhookAttempts)
+	})
+
+	t.Run("client errors are final", func(t *testing.T) {
+		server, received := webhookRecorder(t, http.StatusBadRequest)
+		assert.Error(t, sendWebhook(context.Background(), server.URL, WebhookPayload{JobID: "daily"}))
+		assert.Len(t, *received, 1)
+	})
+
+	t.Run("no url", func(t *testing.T) {
+		NotifyWebhook(context.Background(), types.Job{ID: "daily"}, WebhookPayload{JobID: "daily"})
+	})
+}
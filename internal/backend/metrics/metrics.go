@@ -0,0 +1,187 @@
+// Package metrics keeps the backup counters served on /metrics in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+// Run results counted by RecordRun.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+type jobCounters struct {
+	bytes     atomic.Int64
+	files     atomic.Int64
+	successes atomic.Int64
+	failures  atomic.Int64
+}
+
+var (
+	jobsMu sync.RWMutex
+	jobs   = map[string]*jobCounters{}
+
+	activeBackups atomic.Int64
+)
+
+func counters(jobID string) *jobCounters {
+	jobsMu.RLock()
+	c, ok := jobs[jobID]
+	jobsMu.RUnlock()
+	if ok {
+		return c
+	}
+
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	if c, ok = jobs[jobID]; !ok {
+		c = &jobCounters{}
+		jobs[jobID] = c
+	}
+	return c
+}
+
+// AddBytes counts n bytes read from the source of jobID.
+func AddBytes(jobID string, n int64) {
+	counters(jobID).bytes.Add(n)
+}
+
+// AddFile counts a file of jobID read by the backup.
+func AddFile(jobID string) {
+	counters(jobID).files.Add(1)
+}
+
+// RunStarted counts a backup as active until the matching RunFinished.
+func RunStarted() {
+	activeBackups.Add(1)
+}
+
+// RunFinished ends a backup counted by RunStarted and records its result.
+func RunFinished(jobID string, succeeded bool) {
+	activeBackups.Add(-1)
+	RecordRun(jobID, succeeded)
+}
+
+// RecordRun counts a finished run of jobID, including runs that failed
+// before they started.
+func RecordRun(jobID string, succeeded bool) {
+	c := counters(jobID)
+	if succeeded {
+		c.successes.Add(1)
+	} else {
+		c.failures.Add(1)
+	}
+}
+
+// Write writes all metrics to w. Counters cover the runs and reads made by
+// this process; the last run gauges are taken from jobs, which reflects
+// scheduled runs executed by other processes as well.
+func Write(w io.Writer, jobList []types.Job) error {
+	var b strings.Builder
+
+	header(&b, "pbs_plus_active_backups", "gauge", "Backups currently running in this process.")
+	fmt.Fprintf(&b, "pbs_plus_active_backups %d\n", activeBackups.Load())
+
+	running := 0
+	for _, job := range jobList {
+		if job.LastRunUpid != "" && job.LastRunState == "" {
+			running++
+		}
+	}
+	header(&b, "pbs_plus_running_jobs", "gauge", "Jobs whose last task is still running.")
+	fmt.Fprintf(&b, "pbs_plus_running_jobs %d\n", running)
+
+	sorted := make([]types.Job, len(jobList))
+	copy(sorted, jobList)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	header(&b, "pbs_plus_job_last_run_duration_seconds", "gauge", "Duration of the last finished run of the job.")
+	for _, job := range sorted {
+		if job.LastRunState == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "pbs_plus_job_last_run_duration_seconds{job=%s} %d\n", quote(job.ID), job.Duration)
+	}
+
+	header(&b, "pbs_plus_job_last_run_success", "gauge", "Whether the last finished run of the job succeeded.")
+	for _, job := range sorted {
+		if job.LastRunState == "" {
+			continue
+		}
+		success := 0
+		if job.LastRunState == "OK" {
+			success = 1
+		}
+		fmt.Fprintf(&b, "pbs_plus_job_last_run_success{job=%s} %d\n", quote(job.ID), success)
+	}
+
+	jobsMu.RLock()
+	ids := make([]string, 0, len(jobs))
+	for id := range jobs {
+		ids = append(ids, id)
+	}
+	jobsMu.RUnlock()
+	sort.Strings(ids)
+
+	header(&b, "pbs_plus_job_runs_total", "counter", "Finished runs of the job by result.")
+	for _, id := range ids {
+		c := counters(id)
+		fmt.Fprintf(&b, "pbs_plus_job_runs_total{job=%s,result=%q} %d\n", quote(id), ResultSuccess, c.successes.Load())
+		fmt.Fprintf(&b, "pbs_plus_job_runs_total{job=%s,result=%q} %d\n", quote(id), ResultFailure, c.failures.Load())
+	}
+
+	header(&b, "pbs_plus_job_bytes_transferred_total", "counter", "Bytes read from agents for the job.")
+	for _, id := range ids {
+		fmt.Fprintf(&b, "pbs_plus_job_bytes_transferred_total{job=%s} %d\n", quote(id), counters(id).bytes.Load())
+	}
+
+	header(&b, "pbs_plus_job_files_processed_total", "counter", "Files read from agents for the job.")
+	for _, id := range ids {
+		fmt.Fprintf(&b, "pbs_plus_job_files_processed_total{job=%s} %d\n", quote(id), counters(id).files.Load())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler serves the metrics. listJobs returns the configured jobs with the
+// state of their last run.
+func Handler(listJobs func() ([]types.Job, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		jobList, err := listJobs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = Write(w, jobList)
+	}
+}
+
+func header(b *strings.Builder, name string, kind string, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// quote escapes a label value as the exposition format requires.
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+func TestHandlerReportsJobSeries(t *testing.T) {
+	jobsMu.Lock()
+	jobs = map[string]*jobCounters{}
+	jobsMu.Unlock()
+	activeBackups.Store(0)
+
+	// A fake run of "nightly": one file of 4 KiB read from the agent, then
+	// a second run that is still in progress.
+	RunStarted()
+	AddFile("nightly")
+	AddBytes("nightly", 4096)
+	RunFinished("nightly", true)
+	RunStarted()
+	RecordRun("broken", false)
+
+	listJobs := func() ([]types.Job, error) {
+		return []types.Job{
+			{ID: "nightly", LastRunUpid: "UPID:2", LastRunState: "OK", Duration: 42},
+			{ID: "broken", LastRunUpid: "UPID:1", LastRunState: "connection error"},
+			{ID: "weekly", LastRunUpid: "UPID:3"},
+		}, nil
+	}
+
+	srv := httptest.NewServer(Handler(listJobs))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, series := range []string{
+		"pbs_plus_active_backups 1",
+		"pbs_plus_running_jobs 1",
+		`pbs_plus_job_last_run_duration_seconds{job="nightly"} 42`,
+		`pbs_plus_job_last_run_success{job="nightly"} 1`,
+		`pbs_plus_job_last_run_success{job="broken"} 0`,
+		`pbs_plus_job_runs_total{job="nightly",result="success"} 1`,
+		`pbs_plus_job_runs_total{job="nightly",result="failure"} 0`,
+		`pbs_plus_job_runs_total{job="broken",result="failure"} 1`,
+		`pbs_plus_job_bytes_transferred_total{job="nightly"} 4096`,
+		`pbs_plus_job_files_processed_total{job="nightly"} 1`,
+		"# TYPE pbs_plus_job_runs_total counter",
+	} {
+		if !strings.Contains(string(body), series+"\n") {
+			t.Errorf("missing series %q in:\n%s", series, body)
+		}
+	}
+	if strings.Contains(string(body), `duration_seconds{job="weekly"}`) {
+		t.Errorf("unexpected duration for a job that is still running:\n%s", body)
+	}
+}
+
+func TestQuoteLabelValue(t *testing.T) {
+	if got := quote("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Fatalf("unexpected quoting: %s", got)
+	}
+}
@@ -3,6 +3,7 @@
 package mount
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/rpc"
@@ -17,22 +18,49 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
 )
 
+// ErrAgentUnreachable is matched by AgentUnreachableError.
+var ErrAgentUnreachable = errors.New("agent unreachable")
+
+// AgentUnreachableError reports that the agent did not connect within the
+// retry budget of the backup.
+type AgentUnreachableError struct {
+	Hostname  string
+	Attempts  int
+	Elapsed   time.Duration
+	LastError string
+}
+
+func (e *AgentUnreachableError) Error() string {
+	return fmt.Sprintf("agent %s unreachable after %d connection attempts over %s (last error: %s)",
+		e.Hostname, e.Attempts, e.Elapsed.Round(time.Second), e.LastError)
+}
+
+func (e *AgentUnreachableError) Is(target error) bool {
+	return target == ErrAgentUnreachable
+}
+
 type AgentMount struct {
 	JobId    string
 	Hostname string
 	Drive    string
 	Path     string
+	// ChangedFiles is the number of files changed since the last backup
+	// according to the change journal of the agent, -1 without one.
+	ChangedFiles int
 }
 
 func Mount(storeInstance *store.Store, job types.Job, target types.Target) (*AgentMount, error) {
 	// Parse target information
 	splittedTargetName := strings.Split(target.Name, " - ")
 	targetHostname := splittedTargetName[0]
-	agentPath := strings.TrimPrefix(target.Path, "agent://")
-	agentPathParts := strings.Split(agentPath, "/")
-	agentDrive := agentPathParts[1]
+	agentTarget, err := utils.ParseAgentTarget(target.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Mount: %w", err)
+	}
+	agentDrive := agentTarget.Drive
 
 	agentMount := &AgentMount{
 		JobId:    job.ID,
@@ -43,7 +71,7 @@ func Mount(storeInstance *store.Store, job types.Job, target types.Target) (*Age
 	// Setup mount path
 	agentMount.Path = filepath.Join(constants.AgentMountBasePath, job.ID)
 	// Create mount directory if it doesn't exist
-	err := os.MkdirAll(agentMount.Path, 0700)
+	err = os.MkdirAll(agentMount.Path, 0700)
 	if err != nil {
 		agentMount.CloseMount()
 		return nil, fmt.Errorf("Mount: error creating directory \"%s\" -> %w", agentMount.Path, err)
@@ -83,6 +111,7 @@ func Mount(storeInstance *store.Store, job types.Job, target types.Target) (*Age
 			errCleanup()
 			return nil, fmt.Errorf("backup RPC returned an error %d: %s", reply.Status, reply.Message)
 		}
+		agentMount.ChangedFiles = reply.ChangedFiles
 	}
 
 	isAccessible := false
@@ -123,6 +152,28 @@ func (a *AgentMount) Unmount() {
 	}
 }
 
+// FailedPaths returns the entries the agent listed but could not read,
+// mapped to the error it reported. It must be called before CloseMount.
+func (a *AgentMount) FailedPaths() (map[string]string, error) {
+	args := &rpcmount.FailedPathsArgs{
+		JobId:          a.JobId,
+		TargetHostname: a.Hostname,
+	}
+	var reply rpcmount.FailedPathsReply
+
+	conn, err := net.DialTimeout("unix", constants.MountSocketPath, 5*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial RPC server: %w", err)
+	}
+	rpcClient := rpc.NewClient(conn)
+	defer rpcClient.Close()
+
+	if err := rpcClient.Call("MountRPCService.FailedPaths", args, &reply); err != nil {
+		return nil, fmt.Errorf("failed to call failed paths RPC: %w", err)
+	}
+	return reply.Paths, nil
+}
+
 func (a *AgentMount) CloseMount() {
 	args := &rpcmount.CleanupArgs{
 		JobId:          a.JobId,
@@ -4,6 +4,8 @@ package config
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -13,8 +15,6 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
-	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
@@ -63,12 +63,24 @@ type SectionConfig[T any] struct {
 	allowUnknown     bool
 	includeFiles     []string
 	watcher          *fsnotify.Watcher
-	onConfigChange   func(*ConfigData[T])
+	onConfigChange   func(*ConfigData[T], error)
 	parseSectionHead func(string) (string, string, error)
 	parseSectionLine func(string) (string, string, error)
 
-	cache       *safemap.Map[string, *ConfigData[T]]
-	lastModTime atomic.Int64
+	cache *safemap.Map[string, cachedConfig[T]]
+}
+
+// cachedConfig is a parsed file along with the stat it was parsed at. Other
+// processes write the same files, so the cache is only trusted while both
+// still match.
+type cachedConfig[T any] struct {
+	config  *ConfigData[T]
+	modTime int64 // UnixNano
+	size    int64
+}
+
+func (c cachedConfig[T]) matches(stat os.FileInfo) bool {
+	return c.modTime == stat.ModTime().UnixNano() && c.size == stat.Size()
 }
 
 func NewSectionConfig[T any](plugin *SectionPlugin[T]) *SectionConfig[T] {
@@ -78,7 +90,7 @@ func NewSectionConfig[T any](plugin *SectionPlugin[T]) *SectionConfig[T] {
 		parseSectionHead: defaultParseSectionHeader,
 		parseSectionLine: defaultParseSectionContent,
 		fileMutex:        NewFileMutexManager(),
-		cache:            safemap.New[string, *ConfigData[T]](),
+		cache:            safemap.New[string, cachedConfig[T]](),
 	}
 }
 
@@ -94,15 +106,14 @@ func (sc *SectionConfig[T]) Parse(filename string) (*ConfigData[T], error) {
 		return nil, os.ErrNotExist
 	}
 
-	currentModTimeUnix := stat.ModTime().Unix()
-	lastMod := sc.lastModTime.Load()
-	if cached, exists := sc.cache.Get(filename); exists && lastMod == currentModTimeUnix {
+	if cached, exists := sc.cache.Get(filename); exists && cached.matches(stat) {
 		// The file has not changed so return the cached config.
-		return cached, nil
+		return cached.config, nil
 	}
 
 	// Otherwise, read and parse the config file.
 	var config *ConfigData[T]
+	var parsedStat os.FileInfo
 	err = sc.fileMutex.WithReadLock(filename, func() error {
 		file, err := os.Open(filename)
 		if err != nil {
@@ -110,6 +121,13 @@ func (sc *SectionConfig[T]) Parse(filename string) (*ConfigData[T], error) {
 		}
 		defer file.Close()
 
+		// Stat the open file so the cache entry describes exactly the
+		// content parsed, even if the file is replaced meanwhile.
+		parsedStat, err = file.Stat()
+		if err != nil {
+			return err
+		}
+
 		config = &ConfigData[T]{
 			Sections: make(map[string]*Section[T]),
 			Order:    make([]string, 0),
@@ -198,17 +216,12 @@ func (sc *SectionConfig[T]) Parse(filename string) (*ConfigData[T], error) {
 		return nil, err
 	}
 
-	// Update the cache with the freshly parsed value and current mod time.
-	stat, err = os.Stat(filename)
-	var currentModTime time.Time
-	if err == nil {
-		currentModTime = stat.ModTime()
-	} else {
-		// if unable to stat, use current time as fallback
-		currentModTime = time.Now()
-	}
-	sc.cache.Set(filename, config)
-	sc.lastModTime.Store(currentModTime.Unix())
+	// Update the cache with the freshly parsed value.
+	sc.cache.Set(filename, cachedConfig[T]{
+		config:  config,
+		modTime: parsedStat.ModTime().UnixNano(),
+		size:    parsedStat.Size(),
+	})
 
 	return config, nil
 }
@@ -229,7 +242,7 @@ func (sc *SectionConfig[T]) Write(config *ConfigData[T]) error {
 				continue
 			}
 
-			filename := filepath.Join(sc.plugin.FolderPath, utils.EncodePath(sectionID)+".cfg")
+			filename := filepath.Join(sc.plugin.FolderPath, utils.EncodeConfigName(sectionID))
 			singleConfig := &ConfigData[T]{
 				FilePath: filename,
 				Sections: map[string]*Section[T]{sectionID: section},
@@ -276,29 +289,29 @@ func (sc *SectionConfig[T]) Write(config *ConfigData[T]) error {
 		output.WriteString("\n")
 	}
 
-	err := sc.fileMutex.WithWriteLock(config.FilePath, func() error {
+	return sc.fileMutex.WithWriteLock(config.FilePath, func() error {
 		dir := filepath.Dir(config.FilePath)
 		if err := os.MkdirAll(dir, 0750); err != nil {
 			return fmt.Errorf("failed to create directory: %w", err)
 		}
-		return os.WriteFile(config.FilePath, []byte(output.String()), 0644)
-	})
-	if err != nil {
-		return err
-	}
-
-	// After writing, update the cache with the new config and mod time.
-	stat, statErr := os.Stat(config.FilePath)
-	var currentModTime time.Time
-	if statErr == nil {
-		currentModTime = stat.ModTime()
-	} else {
-		currentModTime = time.Now()
-	}
-	sc.cache.Set(config.FilePath, config)
-	sc.lastModTime.Store(currentModTime.Unix())
+		if err := writeFileAtomic(config.FilePath, []byte(output.String()), 0644); err != nil {
+			return err
+		}
 
-	return nil
+		// Stat while still holding the lock, so the cache entry cannot
+		// describe the file of a later writer.
+		stat, err := os.Stat(config.FilePath)
+		if err != nil {
+			sc.cache.Del(config.FilePath)
+			return nil
+		}
+		sc.cache.Set(config.FilePath, cachedConfig[T]{
+			config:  config,
+			modTime: stat.ModTime().UnixNano(),
+			size:    stat.Size(),
+		})
+		return nil
+	})
 }
 
 // marshalValue converts a reflected value to its string representation
@@ -324,11 +337,25 @@ func marshalValue(value reflect.Value, tag ConfigTag) (string, error) {
 			items = append(items, str)
 		}
 		return strings.Join(items, ","), nil
+	case TypeObject:
+		return marshalObject(value)
 	default:
 		return "", fmt.Errorf("unsupported type: %s", tag.Type)
 	}
 }
 
+// marshalObject encodes a struct, map or pointer to one as JSON, which
+// escapes newlines and so fits the single line of a property.
+func marshalObject(value reflect.Value) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value.Interface()); err != nil {
+		return "", fmt.Errorf("invalid object: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
 // unmarshalValue converts a string to the appropriate type based on the field's type
 func unmarshalValue(str string, fieldType reflect.Type, tag ConfigTag) (reflect.Value, error) {
 	switch tag.Type {
@@ -369,6 +396,15 @@ func unmarshalValue(str string, fieldType reflect.Type, tag ConfigTag) (reflect.
 			slice = reflect.Append(slice, val)
 		}
 		return slice, nil
+	case TypeObject:
+		if str == "" && !tag.Required {
+			return reflect.Zero(fieldType), nil
+		}
+		ptr := reflect.New(fieldType)
+		if err := json.Unmarshal([]byte(str), ptr.Interface()); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid object: %w", err)
+		}
+		return ptr.Elem(), nil
 	default:
 		return reflect.Value{}, fmt.Errorf("unsupported type: %s", tag.Type)
 	}
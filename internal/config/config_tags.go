@@ -119,6 +119,11 @@ func validateFieldWithTags(value interface{}, tags ConfigTag) error {
 		if tags.MaxLength != nil && length > *tags.MaxLength {
 			return fmt.Errorf("array length %d is greater than maximum %d", length, *tags.MaxLength)
 		}
+
+	case TypeObject:
+		if tags.Required && (value == nil || reflect.ValueOf(value).IsZero()) {
+			return fmt.Errorf("required object is empty")
+		}
 	}
 
 	return nil
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
 	"github.com/stretchr/testify/assert"
@@ -552,3 +555,267 @@ func TestCustomKeyConfig(t *testing.T) {
 		assert.Contains(t, err.Error(), "is empty")
 	})
 }
+
+func TestSectionConfig_InterruptedWrite(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testPlugin := &SectionPlugin[BasicTestConfig]{
+		TypeName:   "test",
+		FolderPath: tempDir,
+	}
+	config := NewSectionConfig(testPlugin)
+
+	newData := func(value string) *ConfigData[BasicTestConfig] {
+		return &ConfigData[BasicTestConfig]{
+			Sections: map[string]*Section[BasicTestConfig]{
+				"test-interrupted": {
+					Type: "test",
+					ID:   "test-interrupted",
+					Properties: BasicTestConfig{
+						Name:  "Interrupted",
+						Value: value,
+					},
+				},
+			},
+			Order: []string{"test-interrupted"},
+		}
+	}
+
+	require.NoError(t, config.Write(newData("original")))
+
+	testFile := filepath.Join(tempDir, utils.EncodePath("test-interrupted")+".cfg")
+	original, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+
+	// Simulate a crash halfway through writing the new content.
+	origWriteTempContent := writeTempContent
+	writeTempContent = func(f *os.File, data []byte) error {
+		if _, err := f.Write(data[:len(data)/2]); err != nil {
+			return err
+		}
+		return fmt.Errorf("simulated interruption")
+	}
+	defer func() { writeTempContent = origWriteTempContent }()
+
+	err = config.Write(newData("replacement"))
+	require.Error(t, err)
+
+	current, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(current))
+
+	readData, err := config.Parse(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, "original", readData.Sections["test-interrupted"].Properties.Value)
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file was left behind")
+
+	// Once writes succeed again, the file is replaced.
+	writeTempContent = origWriteTempContent
+	require.NoError(t, config.Write(newData("replacement")))
+	readData, err = config.Parse(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, "replacement", readData.Sections["test-interrupted"].Properties.Value)
+}
+
+func TestSectionConfig_ConcurrentWriters(t *testing.T) {
+	tempDir := t.TempDir()
+	origLockDir := lockDir
+	lockDir = t.TempDir()
+	defer func() { lockDir = origLockDir }()
+
+	testFile := filepath.Join(tempDir, "shared.cfg")
+	newConfig := func() *SectionConfig[BasicTestConfig] {
+		return NewSectionConfig(&SectionPlugin[BasicTestConfig]{
+			TypeName:   "test",
+			FolderPath: tempDir,
+		})
+	}
+	newData := func(writer string, iteration int) *ConfigData[BasicTestConfig] {
+		data := &ConfigData[BasicTestConfig]{
+			FilePath: testFile,
+			Sections: make(map[string]*Section[BasicTestConfig]),
+		}
+		for i := 0; i < 20; i++ {
+			id := fmt.Sprintf("section-%02d", i)
+			data.Sections[id] = &Section[BasicTestConfig]{
+				Type: "test",
+				ID:   id,
+				Properties: BasicTestConfig{
+					Name:  writer,
+					Value: fmt.Sprintf("%d", iteration),
+				},
+			}
+			data.Order = append(data.Order, id)
+		}
+		return data
+	}
+
+	// Each writer stands for a separate process: its own SectionConfig and
+	// so its own in-process mutexes.
+	writers := map[string]*SectionConfig[BasicTestConfig]{
+		"a": newConfig(),
+		"b": newConfig(),
+	}
+	reader := newConfig()
+	require.NoError(t, writers["a"].Write(newData("a", 0)))
+
+	var wg sync.WaitGroup
+	for name, sc := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				assert.NoError(t, sc.Write(newData(name, i)))
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Every parse sees one complete write.
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+
+		data, err := reader.Parse(testFile)
+		require.NoError(t, err)
+		require.Len(t, data.Sections, 20)
+		first := data.Sections["section-00"].Properties
+		for _, section := range data.Sections {
+			require.Equal(t, first, section.Properties)
+		}
+	}
+
+	// A write made in the same second as the previous ones is still seen
+	// by instances that cached the file.
+	require.NoError(t, writers["b"].Write(newData("b", 1000)))
+	for _, sc := range []*SectionConfig[BasicTestConfig]{reader, writers["a"], writers["b"]} {
+		data, err := sc.Parse(testFile)
+		require.NoError(t, err)
+		assert.Equal(t, BasicTestConfig{Name: "b", Value: "1000"}, data.Sections["section-19"].Properties)
+	}
+}
+
+func TestFileMutexManager_CrossInstanceWriteLock(t *testing.T) {
+	origLockDir := lockDir
+	lockDir = t.TempDir()
+	defer func() { lockDir = origLockDir }()
+
+	path := filepath.Join(t.TempDir(), "locked.cfg")
+	first, second := NewFileMutexManager(), NewFileMutexManager()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = first.WithWriteLock(path, func() error {
+			close(entered)
+			<-release
+			return nil
+		})
+	}()
+	<-entered
+
+	secondDone := make(chan struct{})
+	go func() {
+		_ = second.WithWriteLock(path, func() error { return nil })
+		close(secondDone)
+	}()
+
+	select {
+	case <-secondDone:
+		t.Fatal("second manager entered while the first held the lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-secondDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second manager never got the lock")
+	}
+
+	// A failing writer releases the lock.
+	require.Error(t, first.WithWriteLock(path, func() error { return fmt.Errorf("boom") }))
+	require.NoError(t, second.WithWriteLock(path, func() error { return nil }))
+}
+
+type RetryPolicy struct {
+	Attempts    int           `json:"attempts"`
+	Interval    time.Duration `json:"interval"`
+	Multiplier  float64       `json:"multiplier,omitempty"`
+	StatusCodes []int         `json:"status_codes,omitempty"`
+}
+
+type ObjectTestConfig struct {
+	Name    string            `config:"type=string,required"`
+	Retry   RetryPolicy       `config:"type=object,key=retry_policy"`
+	Webhook *RetryPolicy      `config:"type=object"`
+	Headers map[string]string `config:"type=object"`
+}
+
+func TestSectionConfig_ObjectProperties(t *testing.T) {
+	tempDir := t.TempDir()
+	config := NewSectionConfig(&SectionPlugin[ObjectTestConfig]{
+		TypeName:   "object-test",
+		FolderPath: tempDir,
+	})
+
+	testFile := filepath.Join(tempDir, utils.EncodePath("test-object")+".cfg")
+	props := ObjectTestConfig{
+		Name: "nested",
+		Retry: RetryPolicy{
+			Attempts:    3,
+			Interval:    30 * time.Second,
+			Multiplier:  1.5,
+			StatusCodes: []int{502, 503},
+		},
+		Webhook: &RetryPolicy{Attempts: 1},
+		Headers: map[string]string{"X-Token": "a b\nc", "Accept": "<json>"},
+	}
+	err := config.Write(&ConfigData[ObjectTestConfig]{
+		Sections: map[string]*Section[ObjectTestConfig]{
+			"test-object": {Type: "object-test", ID: "test-object", Properties: props},
+		},
+		Order: []string{"test-object"},
+	})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "\tretry_policy {\"attempts\":3,")
+	assert.Contains(t, string(content), "\"Accept\":\"<json>\"")
+	assert.Len(t, strings.Split(strings.TrimSpace(string(content)), "\n"), 5, "one line per property")
+
+	readData, err := config.Parse(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, props, readData.Sections["test-object"].Properties)
+
+	t.Run("Zero objects are left out", func(t *testing.T) {
+		err := config.Write(&ConfigData[ObjectTestConfig]{
+			Sections: map[string]*Section[ObjectTestConfig]{
+				"test-flat": {Type: "object-test", ID: "test-flat", Properties: ObjectTestConfig{Name: "flat"}},
+			},
+			Order: []string{"test-flat"},
+		})
+		require.NoError(t, err)
+
+		flatFile := filepath.Join(tempDir, utils.EncodePath("test-flat")+".cfg")
+		content, err := os.ReadFile(flatFile)
+		require.NoError(t, err)
+		assert.Equal(t, "object-test: test-flat\n\tname flat\n\n", string(content))
+
+		readData, err := config.Parse(flatFile)
+		require.NoError(t, err)
+		assert.Equal(t, ObjectTestConfig{Name: "flat"}, readData.Sections["test-flat"].Properties)
+	})
+}
@@ -1,10 +1,39 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
 	"path/filepath"
 	"sync"
+
+	"github.com/alexflint/go-filemutex"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
 )
 
+// lockDir holds the lock files that serialize access to config files across
+// processes, such as the server and a --job run. It sits next to the config
+// folders rather than in the temporary directory, which a unit with
+// PrivateTmp or a tmp cleaner would split between processes, and out of the
+// config folders themselves, which are scanned for section files.
+var lockDir = constants.ConfigLocksPath
+
+// processLock returns the advisory lock guarding path across processes.
+func processLock(path string) (*filemutex.FileMutex, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+
+	if err := os.MkdirAll(lockDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(absPath))
+	return filemutex.New(filepath.Join(lockDir, hex.EncodeToString(sum[:16])+".lock"))
+}
+
 type FileMutexManager struct {
 	mu     sync.RWMutex
 	locks  map[string]*sync.RWMutex
@@ -56,6 +85,10 @@ func (fm *FileMutexManager) releaseLock(path string) {
 	delete(fm.refCnt, absPath)
 }
 
+// WithReadLock runs fn while holding a shared lock on path, both within
+// the process and across processes. Reads go ahead with the in-process lock
+// alone if the cross-process lock cannot be taken; writes are atomic, so the
+// file is still never seen half written.
 func (fm *FileMutexManager) WithReadLock(path string, fn func() error) error {
 	lock := fm.getLock(path)
 	lock.RLock()
@@ -63,9 +96,19 @@ func (fm *FileMutexManager) WithReadLock(path string, fn func() error) error {
 		lock.RUnlock()
 		fm.releaseLock(path)
 	}()
+
+	if flock, err := processLock(path); err == nil {
+		defer flock.Close()
+		if err := flock.RLock(); err != nil {
+			return fmt.Errorf("failed to lock %s for reading: %w", path, err)
+		}
+	}
+
 	return fn()
 }
 
+// WithWriteLock runs fn while holding an exclusive lock on path, both within
+// the process and across processes.
 func (fm *FileMutexManager) WithWriteLock(path string, fn func() error) error {
 	lock := fm.getLock(path)
 	lock.Lock()
@@ -73,5 +116,16 @@ func (fm *FileMutexManager) WithWriteLock(path string, fn func() error) error {
 		lock.Unlock()
 		fm.releaseLock(path)
 	}()
+
+	flock, err := processLock(path)
+	if err != nil {
+		return err
+	}
+	// Closing the lock file releases the lock.
+	defer flock.Close()
+	if err := flock.Lock(); err != nil {
+		return fmt.Errorf("failed to lock %s for writing: %w", path, err)
+	}
+
 	return fn()
 }
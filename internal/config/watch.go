@@ -0,0 +1,98 @@
+//go:build linux
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch calls onChange with the content of each config file of the plugin
+// folder that is created, written or removed, once debounce has passed
+// without another event for it. A removed file is passed with no sections;
+// one that does not parse is passed with the parse error. Watching stops
+// when ctx is done.
+func (sc *SectionConfig[T]) Watch(ctx context.Context, debounce time.Duration, onChange func(*ConfigData[T], error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	if err := watcher.Add(sc.plugin.FolderPath); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", sc.plugin.FolderPath, err)
+	}
+
+	sc.mu.Lock()
+	if sc.watcher != nil {
+		sc.mu.Unlock()
+		watcher.Close()
+		return fmt.Errorf("%s is already watched", sc.plugin.FolderPath)
+	}
+	sc.watcher = watcher
+	sc.onConfigChange = onChange
+	sc.mu.Unlock()
+
+	go sc.watch(ctx, watcher, debounce, onChange)
+	return nil
+}
+
+func (sc *SectionConfig[T]) watch(ctx context.Context, watcher *fsnotify.Watcher, debounce time.Duration, onChange func(*ConfigData[T], error)) {
+	defer func() {
+		watcher.Close()
+		sc.mu.Lock()
+		sc.watcher = nil
+		sc.onConfigChange = nil
+		sc.mu.Unlock()
+	}()
+
+	pending := make(map[string]struct{})
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// Atomic writes go through hidden temporary files.
+			name := filepath.Base(event.Name)
+			if filepath.Ext(name) != ".cfg" || strings.HasPrefix(name, ".") {
+				continue
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) &&
+				!event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			pending[event.Name] = struct{}{}
+			timer.Reset(debounce)
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		case <-timer.C:
+			for path := range pending {
+				data, err := sc.Parse(path)
+				switch {
+				case errors.Is(err, os.ErrNotExist):
+					onChange(&ConfigData[T]{FilePath: path, Sections: map[string]*Section[T]{}}, nil)
+				case err != nil:
+					onChange(&ConfigData[T]{FilePath: path}, err)
+				default:
+					onChange(data, nil)
+				}
+			}
+			clear(pending)
+		}
+	}
+}
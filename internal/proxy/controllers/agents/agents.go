@@ -3,12 +3,14 @@
 package agents
 
 import (
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/sonroyaalmerol/pbs-plus/internal/auth/certificates"
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
@@ -39,6 +41,71 @@ func AgentLogHandler(storeInstance *store.Store) http.HandlerFunc {
 	}
 }
 
+// AgentRevokeHandler revokes the certificates issued to the agent named by
+// the hostname form value and closes its live aRPC sessions. The agent is
+// refused from then on until it bootstraps again.
+func AgentRevokeHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Invalid HTTP method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		hostname := strings.TrimSpace(r.FormValue("hostname"))
+		if hostname == "" {
+			http.Error(w, "missing hostname", http.StatusBadRequest)
+			return
+		}
+
+		targets, err := storeInstance.Database.GetAllTargets()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		if err := storeInstance.CertGenerator.RevokeCert(hostname, agentCerts(targets, hostname)...); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		// The revocation is only checked on handshakes, so sessions opened
+		// before it are closed here. The agent cannot reconnect with the
+		// revoked certificate.
+		storeInstance.ARPCSessionManager.CloseClientSessions(hostname)
+
+		syslog.L.Info().WithMessage("agent certificate revoked").WithField("hostname", hostname).Write()
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]string{"success": "true"})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+	}
+}
+
+// agentCerts returns the certificates stored in the targets of the agent
+// hostname, which include those issued before SignCSR indexed them.
+func agentCerts(targets []types.Target, hostname string) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for _, target := range targets {
+		if strings.Split(target.Name, " - ")[0] != hostname || target.Auth == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(target.Auth)
+		if err != nil {
+			continue
+		}
+		if cert, err := certificates.ParseCertPEM(decoded); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+	return certs
+}
+
 type BootstrapRequest struct {
 	Hostname string            `json:"hostname"`
 	CSR      string            `json:"csr"`
@@ -95,6 +162,17 @@ func AgentBootstrapHandler(storeInstance *store.Store) http.HandlerFunc {
 			return
 		}
 
+		// Claimed only once the certificate is signed, so a bad request does
+		// not burn the token. Of two installs racing on it, only the one
+		// that claims it gets its certificate.
+		if token.SingleUse {
+			if err := storeInstance.Database.ConsumeToken(tokenStr); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				controllers.WriteErrorResponse(w, fmt.Errorf("[%s]: token already used", r.RemoteAddr))
+				return
+			}
+		}
+
 		encodedCert := base64.StdEncoding.EncodeToString(cert)
 		encodedCA := base64.StdEncoding.EncodeToString(storeInstance.CertGenerator.GetCAPEM())
 
@@ -116,7 +194,7 @@ func AgentBootstrapHandler(storeInstance *store.Store) http.HandlerFunc {
 		for _, drive := range reqParsed.Drives {
 			newTarget := types.Target{
 				Name:            fmt.Sprintf("%s - %s", reqParsed.Hostname, drive.Letter),
-				Path:            fmt.Sprintf("agent://%s/%s", clientIP, drive.Letter),
+				Path:            utils.AgentTarget{Host: clientIP, Drive: drive.Letter}.String(),
 				Auth:            encodedCert,
 				TokenUsed:       tokenStr,
 				DriveType:       drive.Type,
@@ -168,6 +246,14 @@ func AgentRenewHandler(storeInstance *store.Store) http.HandlerFunc {
 			return
 		}
 
+		// A revoked agent has to bootstrap again with a token.
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 &&
+			storeInstance.CertGenerator.IsRevoked(r.TLS.PeerCertificates[0]) {
+			w.WriteHeader(http.StatusUnauthorized)
+			controllers.WriteErrorResponse(w, fmt.Errorf("[%s]: certificate revoked, bootstrap the agent again", r.RemoteAddr))
+			return
+		}
+
 		existingTarget, err := storeInstance.Database.GetTarget(reqParsed.Hostname + " - C")
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -210,7 +296,7 @@ func AgentRenewHandler(storeInstance *store.Store) http.HandlerFunc {
 		for _, drive := range reqParsed.Drives {
 			newTarget := types.Target{
 				Name:            fmt.Sprintf("%s - %s", reqParsed.Hostname, drive.Letter),
-				Path:            fmt.Sprintf("agent://%s/%s", clientIP, drive.Letter),
+				Path:            utils.AgentTarget{Host: clientIP, Drive: drive.Letter}.String(),
 				Auth:            encodedCert,
 				TokenUsed:       existingTarget.TokenUsed,
 				DriveType:       drive.Type,
@@ -0,0 +1,79 @@
+//go:build linux
+
+package agents
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+)
+
+// logTailMethod is the arpc method agents stream their logs on.
+const logTailMethod = "logs/tail"
+
+// relayLogs tails the logs of the agent on session at level or above and
+// writes each entry to w as a line of JSON, calling flush after each. It
+// returns once ctx is done or the agent ends the stream.
+func relayLogs(ctx context.Context, session *arpc.Session, level string, w io.Writer, flush func()) error {
+	params := arpc.MapStringStringMsg{"level": level}
+	return session.CallStream(ctx, logTailMethod, &params, func(frame []byte) error {
+		if _, err := w.Write(append(frame, '\n')); err != nil {
+			return err
+		}
+		flush()
+		return nil
+	})
+}
+
+// AgentLogTailHandler relays the logs of a connected agent live, as
+// newline-delimited JSON entries, until the client disconnects. The level
+// query parameter sets the lowest level relayed and defaults to info.
+func AgentLogTailHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		level := r.URL.Query().Get("level")
+		if level == "" {
+			level = "info"
+		}
+		if _, err := syslog.ParseLevel(level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		hostname := utils.DecodePath(r.PathValue("hostname"))
+		session, ok := storeInstance.ARPCSessionManager.GetSession(hostname)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			controllers.WriteErrorResponse(w, fmt.Errorf("%w for %s", arpc.ErrSessionNotFound, hostname))
+			return
+		}
+
+		flusher, _ := w.(http.Flusher)
+		flush := func() {
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flush()
+
+		err := relayLogs(r.Context(), session, level, w, flush)
+		if err != nil && r.Context().Err() == nil {
+			syslog.L.Error(err).WithMessage("agent log stream ended").WithField("hostname", hostname).Write()
+		}
+	}
+}
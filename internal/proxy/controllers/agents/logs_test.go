@@ -0,0 +1,110 @@
+//go:build linux
+
+package agents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	agentControllers "github.com/sonroyaalmerol/pbs-plus/internal/agent/controllers"
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedBuffer is written by the relay while the test reads it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Split(strings.TrimSpace(b.buf.String()), "\n")
+}
+
+func TestRelayAgentLogs(t *testing.T) {
+	assert.Equal(t, agentControllers.LogTailMethod, logTailMethod)
+
+	agentConn, serverConn := net.Pipe()
+	agentSession, err := arpc.NewClientSession(agentConn, nil)
+	require.NoError(t, err)
+	defer agentSession.Close()
+	serverSession, err := arpc.NewServerSession(serverConn, nil)
+	require.NoError(t, err)
+	defer serverSession.Close()
+
+	router := arpc.NewRouter()
+	router.Handle(agentControllers.LogTailMethod, agentControllers.LogTailHandler)
+	agentSession.SetRouter(router)
+	go func() { _ = agentSession.Serve() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var out lockedBuffer
+	relayed := make(chan error, 1)
+	go func() {
+		relayed <- relayLogs(ctx, serverSession, "warn", &out, func() {})
+	}()
+
+	// Keep logging until the relay picks entries up, since the agent only
+	// streams what is logged once the call reached it.
+	marker := "tail-" + time.Now().Format(time.RFC3339Nano)
+	require.Eventually(t, func() bool {
+		syslog.L.Info().WithMessage(marker + " info").Write()
+		syslog.L.Warn().WithMessage(marker+" warn").WithField("jobId", "nightly").Write()
+		syslog.L.Error(errors.New("disk full")).WithMessage(marker + " error").Write()
+		return len(out.lines()) >= 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-relayed:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(2 * time.Second):
+		t.Fatal("relay did not stop once cancelled")
+	}
+
+	var sawWarn, sawError bool
+	for _, line := range out.lines() {
+		var entry struct {
+			Level   string                 `json:"level"`
+			Message string                 `json:"message"`
+			Error   string                 `json:"error"`
+			Fields  map[string]interface{} `json:"fields"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		if !strings.HasPrefix(entry.Message, marker) {
+			continue
+		}
+
+		switch entry.Level {
+		case "warn":
+			sawWarn = true
+			assert.Equal(t, "nightly", entry.Fields["jobId"])
+		case "error":
+			sawError = true
+			assert.Equal(t, "disk full", entry.Error)
+		default:
+			t.Errorf("entry below the requested level relayed: %s", line)
+		}
+	}
+	assert.True(t, sawWarn, "warn entries are relayed")
+	assert.True(t, sawError, "error entries are relayed")
+}
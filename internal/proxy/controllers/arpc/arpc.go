@@ -3,9 +3,11 @@
 package arpc
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/backend/backup"
 	s "github.com/sonroyaalmerol/pbs-plus/internal/store"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 )
@@ -18,6 +20,16 @@ func ARPCHandler(store *s.Store) http.HandlerFunc {
 		jobId := r.Header.Get("X-PBS-Plus-JobId")
 		agentVersion := r.Header.Get("X-PBS-Plus-Version")
 
+		// Sessions are registered under the name the agent's certificate was
+		// issued to; the header only tells about agents renamed since.
+		if claimed := r.Header.Get("X-PBS-Agent"); claimed != "" && claimed != agentHostname {
+			syslog.L.Warn().
+				WithMessage("agent hostname differs from its certificate").
+				WithField("hostname", agentHostname).
+				WithField("claimed", claimed).
+				Write()
+		}
+
 		if jobId != "" {
 			agentHostname = agentHostname + "|" + jobId
 		}
@@ -28,14 +40,21 @@ func ARPCHandler(store *s.Store) http.HandlerFunc {
 			return
 		}
 		defer func() {
-			store.ARPCSessionManager.CloseSession(agentHostname)
-			s.DisconnectSession(agentHostname)
+			// A reconnected agent already replaced this session; its mount
+			// belongs to the new one.
+			if store.ARPCSessionManager.UnregisterSession(agentHostname, session) {
+				s.DisconnectSession(agentHostname)
+			}
 		}()
 
 		syslog.L.Info().WithMessage("agent successfully connected").WithField("hostname", agentHostname).Write()
+
+		if jobId == "" {
+			go backup.RunDeferred(store.Ctx, store, agentHostname)
+		}
 		defer syslog.L.Info().WithMessage("agent disconnected").WithField("hostname", agentHostname).Write()
 
-		if err := session.Serve(); err != nil {
+		if err := session.Serve(); err != nil && !errors.Is(err, arpc.ErrShuttingDown) {
 			syslog.L.Error(err).WithMessage("error occurred while serving session").WithField("hostname", agentHostname).Write()
 		}
 	}
@@ -4,8 +4,10 @@ package exclusions
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store"
@@ -62,9 +64,18 @@ func ExtJsExclusionHandler(storeInstance *store.Store) http.HandlerFunc {
 			return
 		}
 
+		caseSensitive, err := parseCaseSensitive(r.FormValue("case_sensitive"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
 		newExclusion := types.Exclusion{
-			Path:    r.FormValue("path"),
-			Comment: r.FormValue("comment"),
+			Path:          r.FormValue("path"),
+			Comment:       r.FormValue("comment"),
+			MatchType:     r.FormValue("match_type"),
+			CaseSensitive: caseSensitive,
 		}
 
 		err = storeInstance.Database.CreateExclusion(nil, newExclusion)
@@ -112,6 +123,17 @@ func ExtJsExclusionSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 			if r.FormValue("comment") != "" {
 				exclusion.Comment = r.FormValue("comment")
 			}
+			if r.FormValue("match_type") != "" {
+				exclusion.MatchType = r.FormValue("match_type")
+			}
+			if r.FormValue("case_sensitive") != "" {
+				exclusion.CaseSensitive, err = parseCaseSensitive(r.FormValue("case_sensitive"))
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+			}
 
 			if delArr, ok := r.Form["delete"]; ok {
 				for _, attr := range delArr {
@@ -120,6 +142,10 @@ func ExtJsExclusionSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 						exclusion.Path = ""
 					case "comment":
 						exclusion.Comment = ""
+					case "match_type":
+						exclusion.MatchType = ""
+					case "case_sensitive":
+						exclusion.CaseSensitive = nil
 					}
 				}
 			}
@@ -178,3 +204,16 @@ func ExtJsExclusionSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 		}
 	}
 }
+
+// parseCaseSensitive reads the case_sensitive form value. An empty value
+// leaves the case sensitivity to the target.
+func parseCaseSensitive(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	caseSensitive, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid case_sensitive value %q", value)
+	}
+	return &caseSensitive, nil
+}
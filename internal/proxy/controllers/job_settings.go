@@ -0,0 +1,74 @@
+//go:build linux
+
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+// JobSettings are the settings shared by jobs and job templates, as read
+// from a form.
+type JobSettings struct {
+	types.JobTemplate
+	// Given holds the form keys of the settings that were submitted, even
+	// if empty, so that a template only fills what a job left out.
+	Given map[string]bool
+}
+
+// ParseJobSettings reads the settings shared by jobs and job templates from
+// the form of r, as sent when either is created. Empty numbers are 0.
+func ParseJobSettings(r *http.Request) (JobSettings, error) {
+	retry, err := formInt(r, types.JobSettingRetry)
+	if err != nil {
+		return JobSettings{}, err
+	}
+	retryInterval, err := formInt(r, types.JobSettingRetryInterval)
+	if err != nil {
+		return JobSettings{}, err
+	}
+
+	given := make(map[string]bool)
+	for _, key := range []string{
+		types.JobSettingStore,
+		types.JobSettingSourceMode,
+		types.JobSettingMode,
+		types.JobSettingSchedule,
+		types.JobSettingComment,
+		types.JobSettingNotificationMode,
+		types.JobSettingNamespace,
+		types.JobSettingRetry,
+		types.JobSettingRetryInterval,
+	} {
+		if _, ok := r.Form[key]; ok {
+			given[key] = true
+		}
+	}
+
+	return JobSettings{
+		JobTemplate: types.JobTemplate{
+			ID:               r.FormValue("id"),
+			Store:            r.FormValue(types.JobSettingStore),
+			SourceMode:       r.FormValue(types.JobSettingSourceMode),
+			Mode:             r.FormValue(types.JobSettingMode),
+			Schedule:         r.FormValue(types.JobSettingSchedule),
+			Comment:          r.FormValue(types.JobSettingComment),
+			NotificationMode: r.FormValue(types.JobSettingNotificationMode),
+			Namespace:        r.FormValue(types.JobSettingNamespace),
+			Retry:            retry,
+			RetryInterval:    retryInterval,
+		},
+		Given: given,
+	}, nil
+}
+
+// formInt parses the integer form value key, treating an empty value as 0.
+func formInt(r *http.Request, key string) (int, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
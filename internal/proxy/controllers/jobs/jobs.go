@@ -7,10 +7,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
 	"github.com/sonroyaalmerol/pbs-plus/internal/backend/backup"
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store"
@@ -19,11 +23,14 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
 
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 )
 
+// D2DJobHandler lists the jobs, only those labelled with the tag query
+// parameter when it is set.
 func D2DJobHandler(storeInstance *store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -31,7 +38,15 @@ func D2DJobHandler(storeInstance *store.Store) http.HandlerFunc {
 			return
 		}
 
-		allJobs, err := storeInstance.Database.GetAllJobs()
+		var (
+			allJobs []types.Job
+			err     error
+		)
+		if tag := r.URL.Query().Get("tag"); tag != "" {
+			allJobs, err = storeInstance.Database.GetJobsByTag(tag)
+		} else {
+			allJobs, err = storeInstance.Database.GetAllJobs()
+		}
 		if err != nil {
 			controllers.WriteErrorResponse(w, err)
 			return
@@ -72,6 +87,109 @@ func D2DJobHandler(storeInstance *store.Store) http.HandlerFunc {
 	}
 }
 
+// D2DJobEffectiveFiltersHandler returns the resolved exclusion rules of a job
+// in the order they are applied, along with any include/exclude conflicts.
+func D2DJobEffectiveFiltersHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		job, err := storeInstance.Database.GetJob(utils.DecodePath(r.PathValue("job")))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		caseInsensitive := false
+		if target, err := storeInstance.Database.GetTarget(job.Target); err == nil {
+			caseInsensitive = utils.IsCaseInsensitiveTarget(target.Path)
+		}
+
+		toReturn := EffectiveFiltersResponse{
+			Data: storeInstance.EffectiveFilters(job, caseInsensitive),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toReturn)
+	}
+}
+
+// D2DJobPlanHandler walks the source of the job given by the job query
+// parameter and reports what a run would back up, without running it.
+func D2DJobPlanHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		job, err := storeInstance.Database.GetJob(utils.DecodePath(r.URL.Query().Get("job")))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		plan, err := backup.PlanBackup(r.Context(), job, storeInstance)
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(BackupPlanResponse{Data: plan})
+	}
+}
+
+// D2DJobManifestHandler serves the gzip-compressed file manifest of a run of
+// the job. The run is picked with the upid query parameter and defaults to
+// the last run.
+func D2DJobManifestHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		job, err := storeInstance.Database.GetJob(utils.DecodePath(r.PathValue("job")))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		upid := r.URL.Query().Get("upid")
+		if upid == "" {
+			upid = job.LastRunUpid
+		}
+
+		path, err := backup.ManifestPath(job.ID, upid)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				err = backup.ErrManifestNotFound
+			}
+			w.WriteHeader(http.StatusNotFound)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.ID+"-"+upid+".manifest.gz"))
+		_, _ = io.Copy(w, file)
+	}
+}
+
 func ExtJsJobRunHandler(storeInstance *store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		response := JobRunResponse{}
@@ -86,35 +204,28 @@ func ExtJsJobRunHandler(storeInstance *store.Store) http.HandlerFunc {
 			return
 		}
 
-		system.RemoveAllRetrySchedules(job)
+		testRun := r.FormValue("test") == "1" || r.FormValue("test") == "true"
+		if testRun && r.FormValue("test-store") != "" && !utils.IsValidID(r.FormValue("test-store")) {
+			controllers.WriteErrorResponse(w, fmt.Errorf("invalid test datastore: %s", r.FormValue("test-store")))
+			return
+		}
 
-		op, err := backup.RunBackup(context.Background(), job, storeInstance, false)
+		started := time.Now()
+		var op *backup.BackupOperation
+		if testRun {
+			op, err = backup.RunTestBackup(context.Background(), job, storeInstance, backup.TestRunOptions{
+				Store: r.FormValue("test-store"),
+				Prune: r.FormValue("test-prune") == "1" || r.FormValue("test-prune") == "true",
+			})
+		} else {
+			system.RemoveAllRetrySchedules(job)
+			op, err = backup.RunBackup(context.Background(), job, storeInstance, false)
+		}
 		if err != nil {
-			syslog.L.Error(err).WithField("jobId", job.ID).Write()
-
-			if !errors.Is(err, backup.ErrOneInstance) {
-				if task, err := proxmox.GenerateTaskErrorFile(job, err, []string{"Error handling from a web job run request", "Job ID: " + job.ID, "Source Mode: " + job.SourceMode}); err != nil {
-					syslog.L.Error(err).WithField("jobId", job.ID).Write()
-				} else {
-					// Update job status
-					latestJob, err := storeInstance.Database.GetJob(job.ID)
-					if err != nil {
-						latestJob = job
-					}
-
-					latestJob.LastRunUpid = task.UPID
-					latestJob.LastRunState = task.Status
-					latestJob.LastRunEndtime = task.EndTime
-
-					err = storeInstance.Database.UpdateJob(nil, latestJob)
-					if err != nil {
-						syslog.L.Error(err).WithField("jobId", latestJob.ID).WithField("upid", task.UPID).Write()
-					}
-				}
+			syslog.L.Error(err).WithField("jobId", job.ID).WithField("testRun", testRun).Write()
 
-				if err := system.SetRetrySchedule(job); err != nil {
-					syslog.L.Error(err).WithField("jobId", job.ID).Write()
-				}
+			if !testRun && !errors.Is(err, backup.ErrOneInstance) && !errors.Is(err, backup.ErrJobDisabled) {
+				reportRunError(storeInstance, job, started, err)
 			}
 
 			controllers.WriteErrorResponse(w, err)
@@ -133,6 +244,168 @@ func ExtJsJobRunHandler(storeInstance *store.Store) http.HandlerFunc {
 	}
 }
 
+// reportRunError records a web run of job that failed to start as a failed
+// task, schedules its retries if the failure is transient and notifies about
+// it.
+func reportRunError(storeInstance *store.Store, job types.Job, started time.Time, runErr error) {
+	var upid string
+	if task, err := proxmox.GenerateTaskErrorFile(job, runErr, append([]string{"Error handling from a web job run request", "Job ID: " + job.ID, "Source Mode: " + job.SourceMode}, backup.TaskLogLines(runErr)...)); err != nil {
+		syslog.L.Error(err).WithField("jobId", job.ID).Write()
+	} else {
+		upid = task.UPID
+
+		// Update job status
+		err = storeInstance.Database.UpdateJobStatus(nil, job.ID, types.JobStatus{LastRunUpid: task.UPID})
+		if err != nil {
+			syslog.L.Error(err).WithField("jobId", job.ID).WithField("upid", task.UPID).Write()
+		}
+	}
+
+	if _, err := backup.ScheduleRetry(job, runErr); err != nil {
+		syslog.L.Error(err).WithField("jobId", job.ID).Write()
+	}
+
+	go backup.NotifyRunError(context.Background(), job, started, upid, runErr)
+}
+
+// ExtJsJobRunNowHandler runs a job immediately over the session its agent
+// already holds. Unlike a regular run, it does not wait for an agent that is
+// not connected and answers with 409 instead.
+func ExtJsJobRunNowHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		job, err := storeInstance.Database.GetJob(utils.DecodePath(r.PathValue("job")))
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		target, err := storeInstance.Database.GetTarget(job.Target)
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		runNow(w, storeInstance.ARPCSessionManager, target, func() (string, error) {
+			return startRun(storeInstance, job)
+		})
+	}
+}
+
+// startRun starts a regular run of job in place of its pending retries and
+// returns its UPID. A run that fails to start is reported like a failed run.
+func startRun(storeInstance *store.Store, job types.Job) (string, error) {
+	started := time.Now()
+	system.RemoveAllRetrySchedules(job)
+	op, err := backup.RunBackup(context.Background(), job, storeInstance, false)
+	if err != nil {
+		syslog.L.Error(err).WithField("jobId", job.ID).Write()
+		if !errors.Is(err, backup.ErrOneInstance) && !errors.Is(err, backup.ErrJobDisabled) {
+			reportRunError(storeInstance, job, started, err)
+		}
+		return "", err
+	}
+	return op.Task.UPID, nil
+}
+
+// runNow starts a run with run and answers with its UPID, or with 409 when
+// target is an agent without a live session.
+func runNow(w http.ResponseWriter, sessions *arpc.SessionManager, target types.Target, run func() (string, error)) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if target.IsAgent {
+		hostname := strings.Split(target.Name, " - ")[0]
+		if _, connected := sessions.GetSession(hostname); !connected {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(JobRunResponse{
+				Message: fmt.Sprintf("agent %s is not connected", hostname),
+				Status:  http.StatusConflict,
+			})
+			return
+		}
+	}
+
+	upid, err := run()
+	if err != nil {
+		controllers.WriteErrorResponse(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(JobRunResponse{
+		Data:    upid,
+		Status:  http.StatusOK,
+		Success: true,
+	})
+}
+
+// validatePartialFiles checks the newline-separated partial file patterns of
+// a job, written like exclusions.
+func validatePartialFiles(raw string) error {
+	for _, p := range pattern.ParseRawList(raw) {
+		if err := pattern.ValidatePattern(p.Value, p.MatchType); err != nil {
+			return fmt.Errorf("invalid partial file pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseExclusions reads the newline-separated exclusion patterns of job id,
+// checking that each of them compiles as it will be stored.
+func parseExclusions(raw string, id string) ([]types.Exclusion, error) {
+	exclusions := []types.Exclusion{}
+	for _, p := range pattern.ParseRawList(raw) {
+		if err := pattern.ValidatePattern(pattern.NormalizePattern(p.Value, p.MatchType), p.MatchType); err != nil {
+			return nil, fmt.Errorf("invalid exclusion pattern: %w", err)
+		}
+		exclusions = append(exclusions, types.Exclusion{
+			Path:      p.Value,
+			MatchType: p.MatchType,
+			JobID:     id,
+		})
+	}
+	return exclusions, nil
+}
+
+// validateInclusionTarget rejects inclusions on a job of a local target.
+// proxmox-backup-client reads local targets directly; only the agent mount
+// applies inclusions, so the job would back up the whole source instead.
+func validateInclusionTarget(storeInstance *store.Store, job types.Job) error {
+	if len(job.Inclusions) == 0 {
+		return nil
+	}
+	target, err := storeInstance.Database.GetTarget(job.Target)
+	if err != nil {
+		return nil
+	}
+	if !utils.IsAgentPath(target.Path) {
+		return fmt.Errorf("inclusions are only supported on agent targets, %s is a local target", target.Name)
+	}
+	return nil
+}
+
+// parseInclusions reads the newline-separated inclusion patterns of job id,
+// written like exclusions but without negation.
+func parseInclusions(raw string, id string) ([]types.Inclusion, error) {
+	patterns := pattern.ParseRawList(raw)
+	if _, err := pattern.NewInclusionMatcher(patterns, false); err != nil {
+		return nil, fmt.Errorf("invalid inclusion pattern: %w", err)
+	}
+
+	inclusions := []types.Inclusion{}
+	for _, p := range patterns {
+		inclusions = append(inclusions, types.Inclusion{
+			Path:      p.Value,
+			MatchType: p.MatchType,
+			JobID:     id,
+		})
+	}
+	return inclusions, nil
+}
+
 func ExtJsJobHandler(storeInstance *store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		response := JobConfigResponse{}
@@ -149,44 +422,119 @@ func ExtJsJobHandler(storeInstance *store.Store) http.HandlerFunc {
 			return
 		}
 
-		retry, err := strconv.Atoi(r.FormValue("retry"))
+		settings, err := controllers.ParseJobSettings(r)
 		if err != nil {
-			if r.FormValue("retry") == "" {
-				retry = 0
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		retryMultiplier, err := strconv.ParseFloat(r.FormValue("retry-multiplier"), 64)
+		if err != nil {
+			if r.FormValue("retry-multiplier") == "" {
+				retryMultiplier = 1
 			} else {
 				controllers.WriteErrorResponse(w, err)
 				return
 			}
 		}
 
-		newJob := types.Job{
-			ID:               r.FormValue("id"),
-			Store:            r.FormValue("store"),
-			SourceMode:       r.FormValue("sourcemode"),
-			Mode:             r.FormValue("mode"),
-			Target:           r.FormValue("target"),
-			Subpath:          r.FormValue("subpath"),
-			Schedule:         r.FormValue("schedule"),
-			Comment:          r.FormValue("comment"),
-			Namespace:        r.FormValue("ns"),
-			NotificationMode: r.FormValue("notification-mode"),
-			Retry:            retry,
-			Exclusions:       []types.Exclusion{},
-		}
-
-		rawExclusions := r.FormValue("rawexclusions")
-		for _, exclusion := range strings.Split(rawExclusions, "\n") {
-			exclusion = strings.TrimSpace(exclusion)
-			if exclusion == "" {
-				continue
+		retryMaxInterval, err := strconv.Atoi(r.FormValue("retry-max-interval"))
+		if err != nil {
+			if r.FormValue("retry-max-interval") == "" {
+				retryMaxInterval = 0
+			} else {
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+		}
+
+		maxSize, err := strconv.ParseInt(r.FormValue("max-size"), 10, 64)
+		if err != nil {
+			if r.FormValue("max-size") == "" {
+				maxSize = 0
+			} else {
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+		}
+
+		bandwidthLimit, err := strconv.ParseInt(r.FormValue("bandwidth-limit"), 10, 64)
+		if err != nil {
+			if r.FormValue("bandwidth-limit") == "" {
+				bandwidthLimit = 0
+			} else {
+				controllers.WriteErrorResponse(w, err)
+				return
 			}
+		}
+
+		newJob := types.Job{
+			ID:                settings.ID,
+			Store:             settings.Store,
+			SourceMode:        settings.SourceMode,
+			Mode:              settings.Mode,
+			Target:            r.FormValue("target"),
+			Subpath:           r.FormValue("subpath"),
+			Schedule:          settings.Schedule,
+			Comment:           settings.Comment,
+			Tags:              types.ParseTags(r.FormValue("tags")),
+			Enabled:           r.FormValue("enabled") != "false" && r.FormValue("enabled") != "0",
+			Namespace:         settings.Namespace,
+			NotificationMode:  settings.NotificationMode,
+			Retry:             settings.Retry,
+			RetryInterval:     settings.RetryInterval,
+			RetryMultiplier:   retryMultiplier,
+			RetryMaxInterval:  retryMaxInterval,
+			Template:          r.FormValue("template"),
+			MaxSize:           maxSize,
+			BandwidthLimit:    bandwidthLimit,
+			WebhookURL:        strings.TrimSpace(r.FormValue("webhook-url")),
+			SizeGuard:         r.FormValue("size-guard"),
+			SerializeStore:    r.FormValue("serialize-store") == "true" || r.FormValue("serialize-store") == "1",
+			CreateNamespace:   r.FormValue("create-namespace") != "false" && r.FormValue("create-namespace") != "0",
+			EncryptionKeyFile: strings.TrimSpace(r.FormValue("encryption-key-file")),
+			PartialFiles:      strings.TrimSpace(r.FormValue("partial-files")),
+			RunOnCheckIn:      r.FormValue("run-on-checkin") == "true" || r.FormValue("run-on-checkin") == "1",
+			Manifest:          r.FormValue("manifest") == "true" || r.FormValue("manifest") == "1",
+			ManifestHash:      r.FormValue("manifest-hash") == "true" || r.FormValue("manifest-hash") == "1",
+			Exclusions:        []types.Exclusion{},
+		}
 
-			exclusionInst := types.Exclusion{
-				Path:  exclusion,
-				JobID: newJob.ID,
+		if err := storeInstance.Database.ApplyJobTemplate(&newJob, settings.Given); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			controllers.WriteErrorResponse(w, fmt.Errorf("error applying template %s: %w", newJob.Template, err))
+			return
+		}
+
+		newJob.Exclusions, err = parseExclusions(r.FormValue("rawexclusions"), newJob.ID)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		if newJob.EncryptionKeyFile != "" {
+			if err := utils.ValidateEncryptionKeyFile(newJob.EncryptionKeyFile); err != nil {
+				controllers.WriteErrorResponse(w, err)
+				return
 			}
+		}
+
+		if err := validatePartialFiles(newJob.PartialFiles); err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
 
-			newJob.Exclusions = append(newJob.Exclusions, exclusionInst)
+		newJob.Inclusions, err = parseInclusions(r.FormValue("rawinclusions"), newJob.ID)
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		if err := validateInclusionTarget(storeInstance, newJob); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			controllers.WriteErrorResponse(w, err)
+			return
 		}
 
 		err = storeInstance.Database.CreateJob(nil, newJob)
@@ -245,6 +593,12 @@ func ExtJsJobSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 			if r.FormValue("notification-mode") != "" {
 				job.NotificationMode = r.FormValue("notification-mode")
 			}
+			if _, ok := r.Form["tags"]; ok {
+				job.Tags = types.ParseTags(r.FormValue("tags"))
+			}
+			if r.FormValue("enabled") != "" {
+				job.Enabled = r.FormValue("enabled") == "true" || r.FormValue("enabled") == "1"
+			}
 
 			retry, err := strconv.Atoi(r.FormValue("retry"))
 			if err != nil {
@@ -253,24 +607,103 @@ func ExtJsJobSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 
 			job.Retry = retry
 
+			if r.FormValue("retry-interval") != "" {
+				retryInterval, err := strconv.Atoi(r.FormValue("retry-interval"))
+				if err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+				job.RetryInterval = retryInterval
+			}
+			if r.FormValue("retry-multiplier") != "" {
+				retryMultiplier, err := strconv.ParseFloat(r.FormValue("retry-multiplier"), 64)
+				if err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+				job.RetryMultiplier = retryMultiplier
+			}
+			if r.FormValue("retry-max-interval") != "" {
+				retryMaxInterval, err := strconv.Atoi(r.FormValue("retry-max-interval"))
+				if err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+				job.RetryMaxInterval = retryMaxInterval
+			}
+			if r.FormValue("max-size") != "" {
+				maxSize, err := strconv.ParseInt(r.FormValue("max-size"), 10, 64)
+				if err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+				job.MaxSize = maxSize
+			}
+			if r.FormValue("bandwidth-limit") != "" {
+				bandwidthLimit, err := strconv.ParseInt(r.FormValue("bandwidth-limit"), 10, 64)
+				if err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+				job.BandwidthLimit = bandwidthLimit
+			}
+			if r.FormValue("size-guard") != "" {
+				job.SizeGuard = r.FormValue("size-guard")
+			}
+			if r.FormValue("webhook-url") != "" {
+				job.WebhookURL = strings.TrimSpace(r.FormValue("webhook-url"))
+			}
+			if r.FormValue("serialize-store") != "" {
+				job.SerializeStore = r.FormValue("serialize-store") == "true" || r.FormValue("serialize-store") == "1"
+			}
+			if r.FormValue("create-namespace") != "" {
+				job.CreateNamespace = r.FormValue("create-namespace") == "true" || r.FormValue("create-namespace") == "1"
+			}
+			if r.FormValue("encryption-key-file") != "" {
+				job.EncryptionKeyFile = strings.TrimSpace(r.FormValue("encryption-key-file"))
+				if err := utils.ValidateEncryptionKeyFile(job.EncryptionKeyFile); err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+			}
+			if r.FormValue("partial-files") != "" {
+				job.PartialFiles = strings.TrimSpace(r.FormValue("partial-files"))
+				if err := validatePartialFiles(job.PartialFiles); err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+			}
+			if r.FormValue("run-on-checkin") != "" {
+				job.RunOnCheckIn = r.FormValue("run-on-checkin") == "true" || r.FormValue("run-on-checkin") == "1"
+				if !job.RunOnCheckIn {
+					job.PendingCheckIn = 0
+				}
+			}
+			if r.FormValue("manifest") != "" {
+				job.Manifest = r.FormValue("manifest") == "true" || r.FormValue("manifest") == "1"
+			}
+			if r.FormValue("manifest-hash") != "" {
+				job.ManifestHash = r.FormValue("manifest-hash") == "true" || r.FormValue("manifest-hash") == "1"
+			}
+
 			job.Subpath = r.FormValue("subpath")
 			job.Namespace = r.FormValue("ns")
 			job.Exclusions = []types.Exclusion{}
 
 			if r.FormValue("rawexclusions") != "" {
-				rawExclusions := r.FormValue("rawexclusions")
-				for _, exclusion := range strings.Split(rawExclusions, "\n") {
-					exclusion = strings.TrimSpace(exclusion)
-					if exclusion == "" {
-						continue
-					}
-
-					exclusionInst := types.Exclusion{
-						Path:  exclusion,
-						JobID: job.ID,
-					}
+				job.Exclusions, err = parseExclusions(r.FormValue("rawexclusions"), job.ID)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+			}
 
-					job.Exclusions = append(job.Exclusions, exclusionInst)
+			if _, ok := r.Form["rawinclusions"]; ok {
+				job.Inclusions, err = parseInclusions(r.FormValue("rawinclusions"), job.ID)
+				if err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
 				}
 			}
 
@@ -291,18 +724,59 @@ func ExtJsJobSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 						job.Schedule = ""
 					case "comment":
 						job.Comment = ""
+					case "tags":
+						job.Tags = []string{}
+					case "enabled":
+						job.Enabled = true
 					case "ns":
 						job.Namespace = ""
 					case "retry":
 						job.Retry = 0
+					case "retry-interval":
+						job.RetryInterval = 1
+					case "retry-multiplier":
+						job.RetryMultiplier = 1
+					case "retry-max-interval":
+						job.RetryMaxInterval = 0
 					case "notification-mode":
 						job.NotificationMode = ""
+					case "max-size":
+						job.MaxSize = 0
+					case "bandwidth-limit":
+						job.BandwidthLimit = 0
+					case "webhook-url":
+						job.WebhookURL = ""
+					case "size-guard":
+						job.SizeGuard = ""
+					case "serialize-store":
+						job.SerializeStore = false
+					case "create-namespace":
+						job.CreateNamespace = true
+					case "encryption-key-file":
+						job.EncryptionKeyFile = ""
+					case "partial-files":
+						job.PartialFiles = ""
+					case "run-on-checkin":
+						job.RunOnCheckIn = false
+						job.PendingCheckIn = 0
+					case "manifest":
+						job.Manifest = false
+					case "manifest-hash":
+						job.ManifestHash = false
 					case "rawexclusions":
 						job.Exclusions = []types.Exclusion{}
+					case "rawinclusions":
+						job.Inclusions = []types.Inclusion{}
 					}
 				}
 			}
 
+			if err := validateInclusionTarget(storeInstance, job); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+
 			err = storeInstance.Database.UpdateJob(nil, job)
 			if err != nil {
 				controllers.WriteErrorResponse(w, err)
@@ -0,0 +1,293 @@
+//go:build linux
+
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUPID = "UPID:pbs:000A1B2C:0123ABCD:00000001:6718AD00:backup:store1:root@pam:"
+
+// fakeAgent connects an agent named hostname whose backup handler answers
+// with testUPID, and returns the number of backup calls it received.
+func fakeAgent(t *testing.T, sessions *arpc.SessionManager, hostname string) *int {
+	t.Helper()
+
+	serverConn, agentConn := net.Pipe()
+	serverSession, err := sessions.RegisterSession(hostname, "v1", serverConn)
+	require.NoError(t, err)
+	go func() { _ = serverSession.Serve() }()
+
+	agentSession, err := arpc.NewClientSession(agentConn, nil)
+	require.NoError(t, err)
+
+	calls := 0
+	router := arpc.NewRouter()
+	router.Handle("backup", func(req arpc.Request) (arpc.Response, error) {
+		calls++
+		msg := arpc.StringMsg(testUPID)
+		data, err := msg.Encode()
+		if err != nil {
+			return arpc.Response{}, err
+		}
+		return arpc.Response{Status: http.StatusOK, Data: data}, nil
+	})
+	agentSession.SetRouter(router)
+	go func() { _ = agentSession.Serve() }()
+
+	t.Cleanup(func() {
+		agentSession.Close()
+		sessions.UnregisterSession(hostname, serverSession)
+	})
+	return &calls
+}
+
+// backupOver runs a backup through the session of hostname, as a run
+// mounting the agent would.
+func backupOver(sessions *arpc.SessionManager, hostname string) func() (string, error) {
+	return func() (string, error) {
+		session, ok := sessions.GetSession(hostname)
+		if !ok {
+			return "", arpc.ErrSessionNotFound
+		}
+		resp, err := session.Call("backup", nil)
+		if err != nil {
+			return "", err
+		}
+		var upid arpc.StringMsg
+		if err := upid.Decode(resp.Data); err != nil {
+			return "", err
+		}
+		return string(upid), nil
+	}
+}
+
+func TestRunNow(t *testing.T) {
+	sessions := arpc.NewSessionManager()
+	calls := fakeAgent(t, sessions, "online")
+
+	t.Run("connected agent", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		target := types.Target{Name: "online - C", IsAgent: true}
+		runNow(rec, sessions, target, backupOver(sessions, "online"))
+
+		var resp JobRunResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, resp.Success)
+		assert.Equal(t, testUPID, resp.Data)
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("disconnected agent", func(t *testing.T) {
+		ran := false
+		rec := httptest.NewRecorder()
+		target := types.Target{Name: "offline - C", IsAgent: true}
+		runNow(rec, sessions, target, func() (string, error) {
+			ran = true
+			return "", nil
+		})
+
+		var resp JobRunResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, http.StatusConflict, resp.Status)
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Message, "offline")
+		assert.False(t, ran, "run started without a connected agent")
+	})
+}
+
+func TestApplyToTag(t *testing.T) {
+	storeInstance, err := store.Initialize(t.Context(), map[string]string{"sqlite": filepath.Join(t.TempDir(), "plus.db")})
+	require.NoError(t, err)
+
+	for _, job := range []types.Job{
+		{ID: "web-1", Store: "local", Target: "pve01", Tags: []string{"prod", "web"}, Enabled: true},
+		{ID: "web-2", Store: "local", Target: "pve02", Tags: []string{"web"}, Enabled: true},
+		{ID: "db-1", Store: "local", Target: "pve03", Tags: []string{"prod", "db"}, Enabled: true},
+		{ID: "untagged", Store: "local", Target: "pve04", Enabled: true},
+	} {
+		require.NoError(t, storeInstance.Database.CreateJob(nil, job))
+	}
+
+	var ran []string
+	run := func(job types.Job) (string, error) {
+		ran = append(ran, job.ID)
+		if job.ID == "db-1" {
+			return "", errors.New("agent offline")
+		}
+		return testUPID, nil
+	}
+
+	t.Run("run", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		applyToTag(rec, storeInstance, "prod", BulkActionRun, run)
+
+		var resp JobBulkResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.ElementsMatch(t, []string{"web-1", "db-1"}, ran)
+		assert.Equal(t, map[string]JobBulkResult{
+			"web-1": {UPID: testUPID},
+			"db-1":  {Error: "agent offline"},
+		}, resp.Data)
+		assert.False(t, resp.Success, "a job failed to start")
+	})
+
+	t.Run("disable and enable", func(t *testing.T) {
+		enabled := func() map[string]bool {
+			jobs, err := storeInstance.Database.GetAllJobs()
+			require.NoError(t, err)
+			states := make(map[string]bool)
+			for _, job := range jobs {
+				states[job.ID] = job.Enabled
+			}
+			return states
+		}
+
+		for _, action := range []string{BulkActionEnable, BulkActionDisable} {
+			rec := httptest.NewRecorder()
+			applyToTag(rec, storeInstance, "web", action, run)
+			var resp JobBulkResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			assert.True(t, resp.Success)
+			assert.ElementsMatch(t, []string{"web-1", "web-2"}, keys(resp.Data))
+		}
+		assert.Equal(t, map[string]bool{"web-1": false, "web-2": false, "db-1": true, "untagged": true}, enabled())
+
+		rec := httptest.NewRecorder()
+		applyToTag(rec, storeInstance, "prod", BulkActionEnable, run)
+		assert.Equal(t, map[string]bool{"web-1": true, "web-2": false, "db-1": true, "untagged": true}, enabled())
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		ran = nil
+		for _, tc := range []struct{ tag, action string }{
+			{"web", "explode"},
+			{"bad tag", BulkActionRun},
+		} {
+			rec := httptest.NewRecorder()
+			applyToTag(rec, storeInstance, tc.tag, tc.action, run)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+		assert.Empty(t, ran)
+	})
+}
+
+func keys(results map[string]JobBulkResult) []string {
+	ids := make([]string, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestD2DJobPlanHandlerDecodesJobID(t *testing.T) {
+	storeInstance, err := store.Initialize(t.Context(), map[string]string{"sqlite": filepath.Join(t.TempDir(), "plus.db")})
+	require.NoError(t, err)
+
+	source := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(source, "a.txt"), []byte("hello"), 0644))
+	require.NoError(t, storeInstance.Database.CreateTarget(nil, types.Target{Name: "local-src", Path: source}))
+	job := types.Job{ID: "nightly-db", Store: "local", Target: "local-src", Enabled: true}
+	require.NoError(t, storeInstance.Database.CreateJob(nil, job))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api2/json/d2d/backup/plan?job="+utils.EncodePath(job.ID), nil)
+	D2DJobPlanHandler(storeInstance)(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp BackupPlanResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, job.ID, resp.Data.JobID)
+	assert.Equal(t, int64(1), resp.Data.FileCount)
+}
+
+// submitJobForm sends form to a job handler, for the job id if set.
+func submitJobForm(handler http.HandlerFunc, method string, id string, form url.Values) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/api2/extjs/config/disk-backup-job", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id != "" {
+		req.SetPathValue("job", utils.EncodePath(id))
+	}
+	handler(rec, req)
+	return rec
+}
+
+func TestJobHandlersRejectInvalidExclusions(t *testing.T) {
+	storeInstance, err := store.Initialize(t.Context(), map[string]string{"sqlite": filepath.Join(t.TempDir(), "plus.db")})
+	require.NoError(t, err)
+
+	form := url.Values{
+		"id":            {"invalid-exclusions"},
+		"store":         {"local"},
+		"target":        {"nas"},
+		"rawexclusions": {"*.tmp\nregex:(unclosed"},
+	}
+	rec := submitJobForm(ExtJsJobHandler(storeInstance), http.MethodPost, "", form)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	_, err = storeInstance.Database.GetJob("invalid-exclusions")
+	assert.Error(t, err, "the job is not created")
+
+	form.Set("rawexclusions", "*.tmp")
+	rec = submitJobForm(ExtJsJobHandler(storeInstance), http.MethodPost, "", form)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = submitJobForm(ExtJsJobSingleHandler(storeInstance), http.MethodPut, "invalid-exclusions", url.Values{
+		"rawexclusions": {"[unclosed"},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	job, err := storeInstance.Database.GetJob("invalid-exclusions")
+	require.NoError(t, err)
+	require.Len(t, job.Exclusions, 1)
+	assert.Equal(t, "*.tmp", job.Exclusions[0].Path)
+}
+
+func TestJobHandlersRejectInclusionsOnLocalTargets(t *testing.T) {
+	storeInstance, err := store.Initialize(t.Context(), map[string]string{"sqlite": filepath.Join(t.TempDir(), "plus.db")})
+	require.NoError(t, err)
+	require.NoError(t, storeInstance.Database.CreateTarget(nil, types.Target{Name: "local-src", Path: t.TempDir()}))
+
+	form := url.Values{
+		"id":            {"local-inclusions"},
+		"store":         {"local"},
+		"target":        {"local-src"},
+		"rawinclusions": {"/Documents"},
+	}
+	rec := submitJobForm(ExtJsJobHandler(storeInstance), http.MethodPost, "", form)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	_, err = storeInstance.Database.GetJob("local-inclusions")
+	assert.Error(t, err, "the job is not created")
+
+	form.Del("rawinclusions")
+	rec = submitJobForm(ExtJsJobHandler(storeInstance), http.MethodPost, "", form)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = submitJobForm(ExtJsJobSingleHandler(storeInstance), http.MethodPut, "local-inclusions", url.Values{
+		"rawinclusions": {"/Documents"},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	job, err := storeInstance.Database.GetJob("local-inclusions")
+	require.NoError(t, err)
+	assert.Empty(t, job.Inclusions)
+}
@@ -0,0 +1,92 @@
+//go:build linux
+
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+)
+
+// Actions accepted by ExtJsJobTagHandler.
+const (
+	BulkActionRun     = "run"
+	BulkActionEnable  = "enable"
+	BulkActionDisable = "disable"
+)
+
+// ExtJsJobTagHandler applies the action form value to every job labelled
+// with the tag of the path, and answers with the outcome per job.
+func ExtJsJobTagHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		applyToTag(w, storeInstance, utils.DecodePath(r.PathValue("tag")), r.FormValue("action"), func(job types.Job) (string, error) {
+			return startRun(storeInstance, job)
+		})
+	}
+}
+
+// applyToTag applies action to the jobs labelled with tag, using run to
+// start a run of a job.
+func applyToTag(w http.ResponseWriter, storeInstance *store.Store, tag string, action string, run func(types.Job) (string, error)) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !types.IsValidTag(tag) {
+		w.WriteHeader(http.StatusBadRequest)
+		controllers.WriteErrorResponse(w, fmt.Errorf("invalid tag: %s", tag))
+		return
+	}
+
+	var apply func(job types.Job) (string, error)
+	switch action {
+	case BulkActionRun:
+		apply = run
+	case BulkActionEnable, BulkActionDisable:
+		enabled := action == BulkActionEnable
+		apply = func(job types.Job) (string, error) {
+			if job.Enabled == enabled {
+				return "", nil
+			}
+			job.Enabled = enabled
+			return "", storeInstance.Database.UpdateJob(nil, job)
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		controllers.WriteErrorResponse(w, fmt.Errorf("unknown bulk action: %s", action))
+		return
+	}
+
+	jobs, err := storeInstance.Database.GetJobsByTag(tag)
+	if err != nil {
+		controllers.WriteErrorResponse(w, err)
+		return
+	}
+
+	results := make(map[string]JobBulkResult, len(jobs))
+	failed := 0
+	for _, job := range jobs {
+		upid, err := apply(job)
+		if err != nil {
+			results[job.ID] = JobBulkResult{Error: err.Error()}
+			failed++
+			continue
+		}
+		results[job.ID] = JobBulkResult{UPID: upid}
+	}
+
+	json.NewEncoder(w).Encode(JobBulkResponse{
+		Message: fmt.Sprintf("%s applied to %d of %d jobs tagged %s", action, len(jobs)-failed, len(jobs), tag),
+		Data:    results,
+		Status:  http.StatusOK,
+		Success: failed == 0,
+	})
+}
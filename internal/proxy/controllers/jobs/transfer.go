@@ -0,0 +1,93 @@
+//go:build linux
+
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/sqlite"
+)
+
+// jobsExportVersion is the version of the JobsExport format.
+const jobsExportVersion = 1
+
+// D2DJobExportHandler returns every job with its exclusions and inclusions
+// as a single document accepted by D2DJobImportHandler.
+func D2DJobExportHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		jobs, err := storeInstance.Database.ExportJobs()
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="pbs-plus-jobs.json"`)
+		json.NewEncoder(w).Encode(JobsExport{Version: jobsExportVersion, Jobs: jobs})
+	}
+}
+
+// D2DJobImportHandler creates the jobs of a JobsExport document, all or
+// none. Jobs that already exist are refused with 409 Conflict unless the
+// force query parameter is set, in which case they are replaced.
+func D2DJobImportHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var doc JobsExport
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			controllers.WriteErrorResponse(w, fmt.Errorf("invalid import document: %w", err))
+			return
+		}
+		if doc.Version != jobsExportVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			controllers.WriteErrorResponse(w, fmt.Errorf("unsupported import document version %d", doc.Version))
+			return
+		}
+
+		force := r.URL.Query().Get("force") == "true" || r.URL.Query().Get("force") == "1"
+		result, err := storeInstance.Database.ImportJobs(doc.Jobs, force)
+		if err != nil {
+			var importErr *sqlite.JobImportError
+			if !errors.As(err, &importErr) {
+				w.WriteHeader(http.StatusInternalServerError)
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+
+			status := http.StatusBadRequest
+			if len(importErr.Invalid) == 0 {
+				status = http.StatusConflict
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(JobImportResponse{
+				Message: importErr.Error(),
+				Errors:  importErr,
+				Status:  status,
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(JobImportResponse{
+			Data:    &result,
+			Status:  http.StatusOK,
+			Success: true,
+		})
+	}
+}
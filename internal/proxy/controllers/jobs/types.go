@@ -3,7 +3,10 @@
 package jobs
 
 import (
+	"github.com/sonroyaalmerol/pbs-plus/internal/backend/backup"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/sqlite"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
 )
 
 type JobsResponse struct {
@@ -26,3 +29,40 @@ type JobRunResponse struct {
 	Status  int               `json:"status"`
 	Success bool              `json:"success"`
 }
+
+type EffectiveFiltersResponse struct {
+	Data pattern.EffectiveFilters `json:"data"`
+}
+
+type BackupPlanResponse struct {
+	Data *backup.BackupPlan `json:"data"`
+}
+
+// JobsExport is the document exchanged by the job export and import
+// endpoints.
+type JobsExport struct {
+	Version int         `json:"version"`
+	Jobs    []types.Job `json:"jobs"`
+}
+
+type JobImportResponse struct {
+	Errors  *sqlite.JobImportError  `json:"errors,omitempty"`
+	Message string                  `json:"message"`
+	Data    *sqlite.JobImportResult `json:"data,omitempty"`
+	Status  int                     `json:"status"`
+	Success bool                    `json:"success"`
+}
+
+// JobBulkResult is the outcome of a bulk action on one job: the UPID of the
+// run it started, if any, or why it failed.
+type JobBulkResult struct {
+	UPID  string `json:"upid,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type JobBulkResponse struct {
+	Message string                   `json:"message"`
+	Data    map[string]JobBulkResult `json:"data"`
+	Status  int                      `json:"status"`
+	Success bool                     `json:"success"`
+}
@@ -0,0 +1,113 @@
+//go:build linux
+
+package plus
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/auth/certificates"
+	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+// Certificate states reported for an agent target.
+const (
+	CertStatusValid   = "valid"
+	CertStatusExpired = "expired"
+	CertStatusRevoked = "revoked"
+	CertStatusInvalid = "invalid"
+	// CertStatusMissing is reported for agent targets that were never
+	// bootstrapped.
+	CertStatusMissing = "missing"
+)
+
+// HealthHandler reports, for each agent target, whether its agent holds a
+// live aRPC session, when it was last seen and the state of the certificate
+// it was bootstrapped with. It does not contact the agents.
+func HealthHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		targets, err := storeInstance.Database.GetAllTargets()
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		var isRevoked func(*x509.Certificate) bool
+		if storeInstance.CertGenerator != nil {
+			isRevoked = storeInstance.CertGenerator.IsRevoked
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HealthResponse{
+			Data: agentHealth(targets, storeInstance.ARPCSessionManager, isRevoked, time.Now()),
+		})
+	}
+}
+
+func agentHealth(targets []types.Target, sessions *arpc.SessionManager, isRevoked func(*x509.Certificate) bool, now time.Time) []AgentHealth {
+	health := []AgentHealth{}
+	for _, target := range targets {
+		if !target.IsAgent {
+			continue
+		}
+
+		hostname := strings.Split(target.Name, " - ")[0]
+		status := sessions.Status(hostname)
+
+		entry := AgentHealth{
+			Target:       target.Name,
+			Hostname:     hostname,
+			Connected:    status.Connected,
+			AgentVersion: status.Version,
+		}
+		if !status.LastSeen.IsZero() {
+			entry.LastSeen = status.LastSeen.Unix()
+		}
+		if status.Connected {
+			entry.ConnectedAt = status.ConnectedAt.Unix()
+		}
+
+		cert, err := targetCert(target)
+		switch {
+		case target.Auth == "":
+			entry.CertStatus = CertStatusMissing
+		case err != nil:
+			entry.CertStatus = CertStatusInvalid
+		default:
+			entry.CertExpiry = cert.NotAfter.Unix()
+			switch {
+			case isRevoked != nil && isRevoked(cert):
+				entry.CertStatus = CertStatusRevoked
+			case now.After(cert.NotAfter):
+				entry.CertStatus = CertStatusExpired
+			default:
+				entry.CertStatus = CertStatusValid
+			}
+		}
+
+		health = append(health, entry)
+	}
+	return health
+}
+
+// targetCert parses the certificate issued to the agent of target when it
+// bootstrapped, stored base64 encoded in its auth field.
+func targetCert(target types.Target) (*x509.Certificate, error) {
+	decoded, err := base64.StdEncoding.DecodeString(target.Auth)
+	if err != nil {
+		return nil, err
+	}
+	return certificates.ParseCertPEM(decoded)
+}
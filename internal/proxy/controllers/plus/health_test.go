@@ -0,0 +1,140 @@
+//go:build linux
+
+package plus
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+// encodedCert returns a certificate for hostname valid until notAfter,
+// encoded like the auth field of a bootstrapped target.
+func encodedCert(t *testing.T, hostname string, notAfter time.Time) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hostname},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// connectAgent registers a session for hostname as if its agent connected.
+func connectAgent(t *testing.T, sessions *arpc.SessionManager, hostname, version string) {
+	t.Helper()
+
+	serverConn, agentConn := net.Pipe()
+	t.Cleanup(func() { agentConn.Close() })
+	if _, err := sessions.GetOrCreateSession(hostname, version, serverConn); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+}
+
+func TestAgentHealth(t *testing.T) {
+	now := time.Now()
+	sessions := arpc.NewSessionManager()
+
+	connectAgent(t, sessions, "online", "v1.2.3")
+	t.Cleanup(func() { sessions.CloseSession("online") })
+
+	connectAgent(t, sessions, "offline", "v1.2.0")
+	if err := sessions.CloseSession("offline"); err != nil {
+		t.Fatalf("failed to close session: %v", err)
+	}
+
+	revoked := map[string]bool{"revoked": true}
+	targets := []types.Target{
+		{Name: "online - C", IsAgent: true, Auth: encodedCert(t, "online", now.Add(24*time.Hour))},
+		{Name: "online - D", IsAgent: true, Auth: encodedCert(t, "online", now.Add(24*time.Hour))},
+		{Name: "offline - C", IsAgent: true, Auth: encodedCert(t, "offline", now.Add(-time.Hour))},
+		{Name: "revoked - C", IsAgent: true, Auth: encodedCert(t, "revoked", now.Add(24*time.Hour))},
+		{Name: "manual - C", IsAgent: true},
+		{Name: "garbled - C", IsAgent: true, Auth: "bm90IGEgY2VydA=="},
+		{Name: "local", Path: "/mnt/local"},
+	}
+
+	health := agentHealth(targets, sessions, func(cert *x509.Certificate) bool {
+		return revoked[cert.Subject.CommonName]
+	}, now)
+
+	data, err := json.Marshal(HealthResponse{Data: health})
+	if err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	var resp struct {
+		Data []map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	byTarget := map[string]map[string]any{}
+	for _, entry := range resp.Data {
+		byTarget[entry["target"].(string)] = entry
+	}
+	if len(byTarget) != 6 {
+		t.Fatalf("expected the 6 agent targets, got %v", byTarget)
+	}
+
+	expected := map[string]struct {
+		connected bool
+		seen      bool
+		version   string
+		cert      string
+	}{
+		"online - C":  {true, true, "v1.2.3", CertStatusValid},
+		"online - D":  {true, true, "v1.2.3", CertStatusValid},
+		"offline - C": {false, true, "v1.2.0", CertStatusExpired},
+		"revoked - C": {false, false, "", CertStatusRevoked},
+		"manual - C":  {false, false, "", CertStatusMissing},
+		"garbled - C": {false, false, "", CertStatusInvalid},
+	}
+	for name, want := range expected {
+		entry := byTarget[name]
+		if entry["connected"] != want.connected {
+			t.Errorf("%s: connected = %v, want %v", name, entry["connected"], want.connected)
+		}
+		if seen := entry["last_seen"].(float64) != 0; seen != want.seen {
+			t.Errorf("%s: last_seen = %v, want seen %v", name, entry["last_seen"], want.seen)
+		}
+		if entry["agent_version"] != want.version {
+			t.Errorf("%s: agent_version = %v, want %q", name, entry["agent_version"], want.version)
+		}
+		if entry["cert_status"] != want.cert {
+			t.Errorf("%s: cert_status = %v, want %q", name, entry["cert_status"], want.cert)
+		}
+	}
+
+	if byTarget["online - C"]["connected_at"].(float64) == 0 {
+		t.Error("expected a connected agent to report when it connected")
+	}
+	if byTarget["offline - C"]["connected_at"].(float64) != 0 {
+		t.Error("expected a disconnected agent to report no connection time")
+	}
+	if byTarget["manual - C"]["cert_expiry"].(float64) != 0 {
+		t.Error("expected no certificate expiry without a certificate")
+	}
+}
@@ -16,6 +16,8 @@ $updaterUrl = "{{.UpdaterUrl}}"
 # Registry settings
 $serverUrl = "{{.ServerUrl}}"
 $bootstrapToken = "{{.BootstrapToken}}"
+$updateChannel = "{{.UpdateChannel}}"
+$excludedDrives = "{{.ExcludedDrives}}"
 
 $tempDir = Join-Path -Path $env:TEMP -ChildPath "PBSPlusInstall"
 $installDir = Join-Path -Path ${env:ProgramFiles(x86)} -ChildPath "PBS Plus Agent"
@@ -301,6 +303,8 @@ try {
     # Set the registry values
     Set-ItemProperty -Path "HKLM:\SOFTWARE\PBSPlus\Config" -Name "ServerURL" -Value $serverUrl -Type String
     Set-ItemProperty -Path "HKLM:\SOFTWARE\PBSPlus\Config" -Name "BootstrapToken" -Value $bootstrapToken -Type String
+    Set-ItemProperty -Path "HKLM:\SOFTWARE\PBSPlus\Config" -Name "UpdateChannel" -Value $updateChannel -Type String
+    Set-ItemProperty -Path "HKLM:\SOFTWARE\PBSPlus\Config" -Name "ExcludedDrives" -Value $excludedDrives -Type String
     
     Write-Host "Registry settings created successfully" -ForegroundColor Green
 }
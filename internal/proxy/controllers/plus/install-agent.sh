@@ -0,0 +1,91 @@
+#!/bin/sh
+# PBS Plus Agent Installation Script
+# Installs the agent as a systemd service and enrolls it with the server
+
+set -e
+
+if [ "$(id -u)" -ne 0 ]; then
+    echo "This script requires root privileges. Please run it as root." >&2
+    exit 1
+fi
+
+if ! command -v systemctl >/dev/null 2>&1; then
+    echo "systemd is required to run the PBS Plus Agent." >&2
+    exit 1
+fi
+
+# Set URLs and paths
+agentUrl='{{.AgentUrl}}'
+
+# Registry settings
+serverUrl='{{.ServerUrl}}'
+bootstrapToken='{{.BootstrapToken}}'
+updateChannel='{{.UpdateChannel}}'
+excludedDrives='{{.ExcludedDrives}}'
+
+binaryPath="/usr/bin/pbs-plus-agent"
+unitPath="/etc/systemd/system/pbs-plus-agent.service"
+registryDir="/etc/pbs-plus-agent/registry/Software/PBSPlus"
+
+tempFile="$(mktemp)"
+trap 'rm -f "$tempFile"' EXIT
+
+# Download the agent, ignoring the server's self-signed certificate
+echo "Downloading $agentUrl"
+if command -v curl >/dev/null 2>&1; then
+    curl -fsSLk --retry 3 --retry-delay 5 -o "$tempFile" "$agentUrl"
+elif command -v wget >/dev/null 2>&1; then
+    wget -q --no-check-certificate --tries=3 -O "$tempFile" "$agentUrl"
+else
+    echo "Either curl or wget is required to download the agent." >&2
+    exit 1
+fi
+
+# Stop a running agent before replacing its binary
+if systemctl is-active --quiet pbs-plus-agent.service; then
+    echo "Stopping running PBS Plus Agent..."
+    systemctl stop pbs-plus-agent.service
+fi
+
+install -m 0755 "$tempFile" "$binaryPath"
+echo "Agent installed to $binaryPath"
+
+# Drop previous credentials so the agent bootstraps with the new token
+rm -rf "$registryDir/Auth"
+
+echo "Creating registry settings..."
+mkdir -p "$registryDir/Config"
+printf '%s' "$serverUrl" > "$registryDir/Config/ServerURL"
+printf '%s' "$bootstrapToken" > "$registryDir/Config/BootstrapToken"
+printf '%s' "$updateChannel" > "$registryDir/Config/UpdateChannel"
+printf '%s' "$excludedDrives" > "$registryDir/Config/ExcludedDrives"
+
+cat > "$unitPath" <<'UNIT'
+[Unit]
+Description=PBS Plus Agent
+Wants=network-online.target
+After=network.target
+
+[Service]
+Type=simple
+ExecStart=/usr/bin/pbs-plus-agent
+ExecReload=/bin/kill -HUP $MAINPID
+PIDFile=/run/proxmox-backup/pbs-plus-agent.pid
+Restart=on-failure
+User=root
+Group=root
+
+[Install]
+WantedBy=multi-user.target
+UNIT
+
+systemctl daemon-reload
+systemctl enable pbs-plus-agent.service
+systemctl restart pbs-plus-agent.service
+
+if systemctl is-active --quiet pbs-plus-agent.service; then
+    echo "Installation completed successfully."
+else
+    echo "PBS Plus Agent service is not running, check 'journalctl -u pbs-plus-agent'." >&2
+    exit 1
+fi
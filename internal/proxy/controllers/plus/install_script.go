@@ -0,0 +1,116 @@
+//go:build linux
+
+package plus
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Update channels an agent can be enrolled on. Agents on the manual channel
+// are never updated by the updater service.
+const (
+	UpdateChannelStable = "stable"
+	UpdateChannelManual = "manual"
+)
+
+// Install scripts embedded in the binary, one per agent platform.
+const (
+	windowsInstallScript = "install-agent.ps1"
+	linuxInstallScript   = "install-agent.sh"
+)
+
+var (
+	scriptTokenRegex = regexp.MustCompile(`^[A-Za-z0-9_\-.]+$`)
+	scriptHostRegex  = regexp.MustCompile(`^[A-Za-z0-9.\-:\[\]]+$`)
+	driveLetterRegex = regexp.MustCompile(`^[A-Za-z]$`)
+	mountPointRegex  = regexp.MustCompile(`^/[A-Za-z0-9._\-/]*$`)
+)
+
+// newScriptConfig builds the parameters of the given install script from the
+// server URL and the request query. The script is a template without escaping, so every
+// value that ends up in it is validated here.
+//
+// Supported query parameters:
+//   - t: the bootstrap token
+//   - channel: the update channel (stable or manual)
+//   - exclude: comma-separated drive letters (Windows) or mount points (Linux)
+//     that are not registered as targets
+func newScriptConfig(script string, serverUrl string, query url.Values) (ScriptConfig, error) {
+	parsed, err := url.Parse(serverUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") ||
+		!scriptHostRegex.MatchString(parsed.Host) || parsed.Path != "" {
+		return ScriptConfig{}, fmt.Errorf("invalid server url: %s", serverUrl)
+	}
+
+	config := ScriptConfig{
+		ServerUrl:     serverUrl,
+		UpdateChannel: UpdateChannelStable,
+	}
+
+	switch script {
+	case windowsInstallScript:
+		config.AgentUrl = serverUrl + "/api2/json/plus/binary"
+		config.UpdaterUrl = serverUrl + "/api2/json/plus/updater-binary"
+	case linuxInstallScript:
+		config.AgentUrl = serverUrl + "/api2/json/plus/binary/linux"
+	default:
+		return ScriptConfig{}, fmt.Errorf("unknown install script: %s", script)
+	}
+
+	if token := query.Get("t"); token != "" {
+		if !scriptTokenRegex.MatchString(token) {
+			return ScriptConfig{}, fmt.Errorf("invalid bootstrap token")
+		}
+		config.BootstrapToken = token
+	}
+
+	if channel := query.Get("channel"); channel != "" {
+		if channel != UpdateChannelStable && channel != UpdateChannelManual {
+			return ScriptConfig{}, fmt.Errorf("invalid update channel: %s", channel)
+		}
+		config.UpdateChannel = channel
+	}
+
+	if exclude := query.Get("exclude"); exclude != "" {
+		var drives []string
+		for _, drive := range strings.Split(exclude, ",") {
+			drive = strings.TrimSpace(drive)
+			if script == linuxInstallScript {
+				if !mountPointRegex.MatchString(drive) {
+					return ScriptConfig{}, fmt.Errorf("invalid mount point: %s", drive)
+				}
+				drives = append(drives, drive)
+				continue
+			}
+
+			drive = strings.TrimSuffix(drive, ":")
+			if !driveLetterRegex.MatchString(drive) {
+				return ScriptConfig{}, fmt.Errorf("invalid drive letter: %s", drive)
+			}
+			drives = append(drives, strings.ToUpper(drive))
+		}
+		config.ExcludedDrives = strings.Join(drives, ",")
+	}
+
+	return config, nil
+}
+
+// renderInstallScript writes the embedded install script with config applied.
+func renderInstallScript(w io.Writer, script string, config ScriptConfig) error {
+	scriptContent, err := scriptFS.ReadFile(script)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("script").Parse(string(scriptContent))
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, config)
+}
@@ -0,0 +1,126 @@
+//go:build linux
+
+package plus
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestInstallScriptContainsInjectedValues(t *testing.T) {
+	query := url.Values{}
+	query.Set("t", "eyJhbGciOiJIUzI1NiJ9.eyJleHAiOjF9.c2lnbmF0dXJl")
+	query.Set("channel", UpdateChannelManual)
+	query.Set("exclude", "d, e:")
+
+	config, err := newScriptConfig(windowsInstallScript, "https://pbs.example.com:8008", query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var script bytes.Buffer
+	if err := renderInstallScript(&script, windowsInstallScript, config); err != nil {
+		t.Fatalf("failed to render script: %v", err)
+	}
+
+	for _, want := range []string{
+		`$serverUrl = "https://pbs.example.com:8008"`,
+		`$agentUrl = "https://pbs.example.com:8008/api2/json/plus/binary"`,
+		`$updaterUrl = "https://pbs.example.com:8008/api2/json/plus/updater-binary"`,
+		`$bootstrapToken = "eyJhbGciOiJIUzI1NiJ9.eyJleHAiOjF9.c2lnbmF0dXJl"`,
+		`$updateChannel = "manual"`,
+		`$excludedDrives = "D,E"`,
+	} {
+		if !strings.Contains(script.String(), want) {
+			t.Errorf("script does not contain %s", want)
+		}
+	}
+	if strings.Contains(script.String(), "{{") {
+		t.Error("script contains unrendered template actions")
+	}
+}
+
+func TestLinuxInstallScriptContainsInjectedValues(t *testing.T) {
+	query := url.Values{}
+	query.Set("t", "eyJhbGciOiJIUzI1NiJ9.eyJleHAiOjF9.c2lnbmF0dXJl")
+	query.Set("exclude", "/home, /mnt/data")
+
+	config, err := newScriptConfig(linuxInstallScript, "https://pbs.example.com:8008", query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var script bytes.Buffer
+	if err := renderInstallScript(&script, linuxInstallScript, config); err != nil {
+		t.Fatalf("failed to render script: %v", err)
+	}
+
+	for _, want := range []string{
+		`serverUrl='https://pbs.example.com:8008'`,
+		`agentUrl='https://pbs.example.com:8008/api2/json/plus/binary/linux'`,
+		`bootstrapToken='eyJhbGciOiJIUzI1NiJ9.eyJleHAiOjF9.c2lnbmF0dXJl'`,
+		`updateChannel='stable'`,
+		`excludedDrives='/home,/mnt/data'`,
+		`systemctl enable pbs-plus-agent.service`,
+	} {
+		if !strings.Contains(script.String(), want) {
+			t.Errorf("script does not contain %s", want)
+		}
+	}
+	if strings.Contains(script.String(), "{{") {
+		t.Error("script contains unrendered template actions")
+	}
+
+	query.Set("exclude", "C")
+	if _, err := newScriptConfig(linuxInstallScript, "https://pbs.example.com:8008", query); err == nil {
+		t.Error("expected drive letter to be rejected for linux")
+	}
+	query.Set("exclude", "/home/$(reboot)")
+	if _, err := newScriptConfig(linuxInstallScript, "https://pbs.example.com:8008", query); err == nil {
+		t.Error("expected shell expansion in mount point to be rejected")
+	}
+}
+
+func TestInstallScriptDefaults(t *testing.T) {
+	config, err := newScriptConfig(windowsInstallScript, "http://10.0.0.1:8008", url.Values{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.UpdateChannel != UpdateChannelStable {
+		t.Errorf("expected default channel %q, got %q", UpdateChannelStable, config.UpdateChannel)
+	}
+	if config.BootstrapToken != "" || config.ExcludedDrives != "" {
+		t.Errorf("expected empty token and exclusions, got %+v", config)
+	}
+}
+
+func TestInstallScriptRejectsInvalidParameters(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverUrl string
+		key       string
+		value     string
+	}{
+		{"token with quote", "https://pbs:8008", "t", `abc"; Remove-Item C:\`},
+		{"unknown channel", "https://pbs:8008", "channel", "nightly"},
+		{"drive is not a letter", "https://pbs:8008", "exclude", "C,DE"},
+		{"drive with quote", "https://pbs:8008", "exclude", `C,"`},
+		{"mount point as drive", "https://pbs:8008", "exclude", "/home"},
+		{"host with quote", `https://pbs";evil:8008`, "", ""},
+		{"unsupported scheme", "ftp://pbs:8008", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{}
+			if tt.key != "" {
+				query.Set(tt.key, tt.value)
+			}
+			if _, err := newScriptConfig(windowsInstallScript, tt.serverUrl, query); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
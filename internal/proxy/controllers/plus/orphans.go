@@ -0,0 +1,50 @@
+//go:build linux
+
+package plus
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// OrphansHandler lists orphaned config entries on GET and removes them on
+// DELETE. Unused targets are only removed when the "targets" query parameter
+// is set.
+func OrphansHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		orphans, err := storeInstance.Database.FindOrphans()
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			includeTargets := r.FormValue("targets") == "true" || r.FormValue("targets") == "1"
+			if err := storeInstance.Database.DeleteOrphans(nil, orphans, includeTargets); err != nil {
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+			if !includeTargets {
+				orphans.Targets = []string{}
+			}
+
+			syslog.L.Info().
+				WithMessage("removed orphaned config entries").
+				WithField("exclusions", len(orphans.Exclusions)).
+				WithField("targets", len(orphans.Targets)).
+				Write()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(OrphansResponse{Data: orphans})
+	}
+}
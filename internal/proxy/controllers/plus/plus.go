@@ -3,20 +3,28 @@
 package plus
 
 import (
+	"bytes"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"text/template"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/store"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 )
 
-//go:embed install-agent.ps1
+//go:embed install-agent.ps1 install-agent.sh
 var scriptFS embed.FS
 
 func AgentInstallScriptHandler(storeInstance *store.Store, version string) http.HandlerFunc {
+	return installScriptHandler(storeInstance, windowsInstallScript)
+}
+
+func AgentInstallLinuxScriptHandler(storeInstance *store.Store, version string) http.HandlerFunc {
+	return installScriptHandler(storeInstance, linuxInstallScript)
+}
+
+func installScriptHandler(storeInstance *store.Store, script string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Invalid HTTP method", http.StatusMethodNotAllowed)
@@ -41,39 +49,30 @@ func AgentInstallScriptHandler(storeInstance *store.Store, version string) http.
 
 		baseServerUrl := fmt.Sprintf("%s://%s", scheme, host)
 
-		config := ScriptConfig{
-			ServerUrl:  baseServerUrl,
-			AgentUrl:   baseServerUrl + "/api2/json/plus/binary",
-			UpdaterUrl: baseServerUrl + "/api2/json/plus/updater-binary",
-		}
-
-		if token := r.URL.Query().Get("t"); token != "" {
-			config.BootstrapToken = token
-		}
-
-		// Read the embedded PowerShell script
-		scriptContent, err := scriptFS.ReadFile("install-agent.ps1")
+		config, err := newScriptConfig(script, baseServerUrl, r.URL.Query())
 		if err != nil {
-			syslog.L.Error(err).Write()
-			http.Error(w, "failed to write response body", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Parse the template
-		tmpl, err := template.New("script").Parse(string(scriptContent))
-		if err != nil {
+		// Refuse to hand out a script that can only fail at bootstrap.
+		if config.BootstrapToken != "" {
+			token, err := storeInstance.Database.GetToken(config.BootstrapToken)
+			if err != nil || token.Revoked {
+				http.Error(w, "bootstrap token is invalid or already used", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var rendered bytes.Buffer
+		if err := renderInstallScript(&rendered, script, config); err != nil {
 			syslog.L.Error(err).Write()
 			http.Error(w, "failed to write response body", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		err = tmpl.Execute(w, config)
-		if err != nil {
-			syslog.L.Error(err).Write()
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+		_, _ = w.Write(rendered.Bytes())
 	}
 }
 
@@ -113,6 +112,24 @@ func DownloadBinary(storeInstance *store.Store, version string) http.HandlerFunc
 	}
 }
 
+func DownloadLinuxBinary(storeInstance *store.Store, version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if version == "v0.0.0" {
+			version = "dev"
+		}
+
+		// Construct the passthrough URL
+		targetURL := fmt.Sprintf("%s%s/pbs-plus-agent-%s-linux-amd64", PBS_DOWNLOAD_BASE, version, version)
+
+		proxyUrl(targetURL, w, r)
+	}
+}
+
 func DownloadUpdater(storeInstance *store.Store, version string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
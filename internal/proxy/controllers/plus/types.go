@@ -2,6 +2,8 @@
 
 package plus
 
+import "github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+
 type VersionResponse struct {
 	Version string `json:"version"`
 }
@@ -11,5 +13,25 @@ type ScriptConfig struct {
 	UpdaterUrl     string
 	ServerUrl      string
 	BootstrapToken string
+	UpdateChannel  string
+	ExcludedDrives string
+}
+
+type OrphansResponse struct {
+	Data types.Orphans `json:"data"`
 }
 
+type AgentHealth struct {
+	Target       string `json:"target"`
+	Hostname     string `json:"hostname"`
+	Connected    bool   `json:"connected"`
+	LastSeen     int64  `json:"last_seen"`
+	ConnectedAt  int64  `json:"connected_at"`
+	AgentVersion string `json:"agent_version"`
+	CertStatus   string `json:"cert_status"`
+	CertExpiry   int64  `json:"cert_expiry"`
+}
+
+type HealthResponse struct {
+	Data []AgentHealth `json:"data"`
+}
@@ -0,0 +1,94 @@
+//go:build linux
+
+package targets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+)
+
+// pingFunc asks the agent of a target for its version.
+type pingFunc func(ctx context.Context, target types.Target) (string, error)
+
+// testTarget checks that target can be backed up from right now: its agent
+// answers a ping, or its local path is a readable directory.
+func testTarget(ctx context.Context, target types.Target, ping pingFunc) TargetTestResult {
+	result := TargetTestResult{Target: target.Name, IsAgent: target.IsAgent}
+
+	if target.Path == "" || !utils.ValidateTargetPath(target.Path) {
+		result.Error = fmt.Sprintf("invalid target path '%s'", target.Path)
+		return result
+	}
+
+	start := time.Now()
+	var err error
+	if target.IsAgent {
+		result.AgentVersion, err = ping(ctx, target)
+	} else {
+		err = checkLocalPath(target.Path)
+	}
+	result.LatencyMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Reachable = true
+	return result
+}
+
+func checkLocalPath(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	info, err := dir.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	if _, err := dir.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s is not readable: %w", path, err)
+	}
+	return nil
+}
+
+// ExtJsTargetTestHandler tests the connection to a single target on demand.
+// The listing only reports whether agents answer; this also tells why not.
+func ExtJsTargetTestHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		target, err := storeInstance.Database.GetTarget(utils.DecodePath(r.PathValue("target")))
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(TargetTestResponse{
+			Data:    testTarget(r.Context(), target, storeInstance.AgentPing),
+			Status:  http.StatusOK,
+			Success: true,
+		})
+	}
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package targets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestTarget(t *testing.T) {
+	pinged := 0
+	ping := func(ctx context.Context, target types.Target) (string, error) {
+		pinged++
+		if target.Name == "online - C" {
+			return "v0.51.0", nil
+		}
+		return "", errors.New("AgentPing: offline did not answer after 1 attempts")
+	}
+
+	t.Run("reachable agent", func(t *testing.T) {
+		result := testTarget(context.Background(), types.Target{Name: "online - C", Path: "agent://10.0.0.1/C", IsAgent: true}, ping)
+		assert.True(t, result.Reachable)
+		assert.Equal(t, "v0.51.0", result.AgentVersion)
+		assert.Empty(t, result.Error)
+	})
+
+	t.Run("unreachable agent", func(t *testing.T) {
+		result := testTarget(context.Background(), types.Target{Name: "offline - C", Path: "agent://10.0.0.2/C", IsAgent: true}, ping)
+		assert.False(t, result.Reachable)
+		assert.Empty(t, result.AgentVersion)
+		assert.Contains(t, result.Error, "did not answer")
+	})
+
+	t.Run("reachable local path", func(t *testing.T) {
+		result := testTarget(context.Background(), types.Target{Name: "local", Path: t.TempDir()}, ping)
+		assert.True(t, result.Reachable)
+		assert.Empty(t, result.Error)
+	})
+
+	t.Run("unreachable local path", func(t *testing.T) {
+		dir := t.TempDir()
+		missing := testTarget(context.Background(), types.Target{Name: "missing", Path: filepath.Join(dir, "gone")}, ping)
+		assert.False(t, missing.Reachable)
+		assert.Contains(t, missing.Error, "no such file or directory")
+
+		file := filepath.Join(dir, "file")
+		require.NoError(t, os.WriteFile(file, nil, 0644))
+		notDir := testTarget(context.Background(), types.Target{Name: "file", Path: file}, ping)
+		assert.False(t, notDir.Reachable)
+		assert.Contains(t, notDir.Error, "not a directory")
+	})
+
+	t.Run("malformed paths", func(t *testing.T) {
+		before := pinged
+		for _, target := range []types.Target{
+			{Name: "empty"},
+			{Name: "relative", Path: "mnt/data"},
+			{Name: "bad ip - C", Path: "agent://not-an-ip/C", IsAgent: true},
+			{Name: "bad drive - C", Path: "agent://10.0.0.1/CD", IsAgent: true},
+		} {
+			result := testTarget(context.Background(), target, ping)
+			assert.False(t, result.Reachable, target.Name)
+			assert.Contains(t, result.Error, "invalid target path", target.Name)
+		}
+		assert.Equal(t, before, pinged, "malformed agent targets are not pinged")
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
@@ -28,16 +29,11 @@ func D2DTargetHandler(storeInstance *store.Store) http.HandlerFunc {
 			return
 		}
 
+		// The status comes from the last ping of each agent, so an
+		// unreachable agent does not hold up the listing.
 		for i := range all {
 			if all[i].IsAgent {
-				targetSplit := strings.Split(all[i].Name, " - ")
-				hostname := targetSplit[0]
-
-				arpcSess, ok := storeInstance.ARPCSessionManager.GetSession(hostname)
-				if ok {
-					all[i].ConnectionStatus = true
-					all[i].AgentVersion = arpcSess.GetVersion()
-				}
+				all[i].AgentVersion, all[i].ConnectionStatus = storeInstance.AgentStatus(all[i])
 			}
 		}
 
@@ -117,7 +113,7 @@ func D2DTargetAgentHandler(storeInstance *store.Store) http.HandlerFunc {
 
 			_ = storeInstance.Database.CreateTarget(tx, types.Target{
 				Name:            hostname + " - " + parsedDrive.Letter,
-				Path:            "agent://" + clientIP + "/" + parsedDrive.Letter,
+				Path:            utils.AgentTarget{Host: clientIP, Drive: parsedDrive.Letter}.String(),
 				Auth:            targetTemplate.Auth,
 				TokenUsed:       targetTemplate.TokenUsed,
 				DriveType:       parsedDrive.Type,
@@ -180,9 +176,29 @@ func ExtJsTargetHandler(storeInstance *store.Store) http.HandlerFunc {
 			return
 		}
 
+		connectTimeout, err := parseOptionalInt(r.FormValue("connect_timeout"))
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+		maxRetries, err := parseOptionalInt(r.FormValue("max_retries"))
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+		maxConcurrency, err := parseOptionalInt(r.FormValue("max_concurrency"))
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
 		newTarget := types.Target{
-			Name: r.FormValue("name"),
-			Path: r.FormValue("path"),
+			Name:           r.FormValue("name"),
+			Path:           r.FormValue("path"),
+			ConnectTimeout: connectTimeout,
+			MaxRetries:     maxRetries,
+			MaxConcurrency: maxConcurrency,
+			FailWhenBusy:   r.FormValue("fail_when_busy") == "true" || r.FormValue("fail_when_busy") == "1",
 		}
 
 		err = storeInstance.Database.CreateTarget(nil, newTarget)
@@ -231,6 +247,30 @@ func ExtJsTargetSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 			if r.FormValue("path") != "" {
 				target.Path = r.FormValue("path")
 			}
+			if r.FormValue("connect_timeout") != "" {
+				target.ConnectTimeout, err = parseOptionalInt(r.FormValue("connect_timeout"))
+				if err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+			}
+			if r.FormValue("max_retries") != "" {
+				target.MaxRetries, err = parseOptionalInt(r.FormValue("max_retries"))
+				if err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+			}
+			if r.FormValue("max_concurrency") != "" {
+				target.MaxConcurrency, err = parseOptionalInt(r.FormValue("max_concurrency"))
+				if err != nil {
+					controllers.WriteErrorResponse(w, err)
+					return
+				}
+			}
+			if r.FormValue("fail_when_busy") != "" {
+				target.FailWhenBusy = r.FormValue("fail_when_busy") == "true" || r.FormValue("fail_when_busy") == "1"
+			}
 
 			if delArr, ok := r.Form["delete"]; ok {
 				for _, attr := range delArr {
@@ -239,6 +279,14 @@ func ExtJsTargetSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 						target.Name = ""
 					case "path":
 						target.Path = ""
+					case "connect_timeout":
+						target.ConnectTimeout = 0
+					case "max_retries":
+						target.MaxRetries = 0
+					case "max_concurrency":
+						target.MaxConcurrency = 0
+					case "fail_when_busy":
+						target.FailWhenBusy = false
 					}
 				}
 			}
@@ -264,12 +312,7 @@ func ExtJsTargetSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 			}
 
 			if target.IsAgent {
-				targetSplit := strings.Split(target.Name, " - ")
-				arpcSess, ok := storeInstance.ARPCSessionManager.GetSession(targetSplit[0])
-				if ok {
-					target.ConnectionStatus = true
-					target.AgentVersion = arpcSess.GetVersion()
-				}
+				target.AgentVersion, target.ConnectionStatus = storeInstance.AgentStatus(target)
 			}
 
 			response.Status = http.StatusOK
@@ -294,3 +337,19 @@ func ExtJsTargetSingleHandler(storeInstance *store.Store) http.HandlerFunc {
 		}
 	}
 }
+
+// parseOptionalInt parses a non-negative integer form value, treating an
+// empty value as 0.
+func parseOptionalInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid value %d: must not be negative", n)
+	}
+	return n, nil
+}
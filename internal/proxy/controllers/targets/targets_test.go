@@ -0,0 +1,26 @@
+//go:build linux
+
+package targets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptionalInt(t *testing.T) {
+	n, err := parseOptionalInt("")
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = parseOptionalInt("30")
+	require.NoError(t, err)
+	assert.Equal(t, 30, n)
+
+	_, err = parseOptionalInt("-1")
+	assert.Error(t, err, "negative timeouts and retry counts are rejected")
+
+	_, err = parseOptionalInt("ten")
+	assert.Error(t, err)
+}
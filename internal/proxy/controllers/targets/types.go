@@ -18,3 +18,20 @@ type TargetConfigResponse struct {
 	Status  int               `json:"status"`
 	Success bool              `json:"success"`
 }
+
+// TargetTestResult describes an on-demand connection test of a target.
+type TargetTestResult struct {
+	Target       string `json:"target"`
+	IsAgent      bool   `json:"is_agent"`
+	Reachable    bool   `json:"reachable"`
+	LatencyMs    int64  `json:"latency_ms"`
+	AgentVersion string `json:"agent_version,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+type TargetTestResponse struct {
+	Message string           `json:"message"`
+	Data    TargetTestResult `json:"data"`
+	Status  int              `json:"status"`
+	Success bool             `json:"success"`
+}
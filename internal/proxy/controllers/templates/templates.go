@@ -0,0 +1,195 @@
+//go:build linux
+
+package templates
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/proxy/controllers"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+)
+
+func D2DJobTemplateHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		all, err := storeInstance.Database.GetAllJobTemplates()
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		digest, err := utils.CalculateDigest(all)
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		toReturn := JobTemplatesResponse{
+			Data:   all,
+			Digest: digest,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toReturn)
+	}
+}
+
+func ExtJsJobTemplateHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := JobTemplateConfigResponse{}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := r.ParseForm()
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		settings, err := controllers.ParseJobSettings(r)
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		err = storeInstance.Database.CreateJobTemplate(nil, settings.JobTemplate)
+		if err != nil {
+			controllers.WriteErrorResponse(w, err)
+			return
+		}
+
+		response.Status = http.StatusOK
+		response.Success = true
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func ExtJsJobTemplateSingleHandler(storeInstance *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := JobTemplateConfigResponse{}
+		if r.Method != http.MethodPut && r.Method != http.MethodGet && r.Method != http.MethodDelete {
+			http.Error(w, "Invalid HTTP method", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method == http.MethodPut {
+			template, err := storeInstance.Database.GetJobTemplate(utils.DecodePath(r.PathValue("template")))
+			if err != nil {
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+
+			err = r.ParseForm()
+			if err != nil {
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+
+			if r.FormValue("store") != "" {
+				template.Store = r.FormValue("store")
+			}
+			if r.FormValue("mode") != "" {
+				template.Mode = r.FormValue("mode")
+			}
+			if r.FormValue("sourcemode") != "" {
+				template.SourceMode = r.FormValue("sourcemode")
+			}
+			if r.FormValue("schedule") != "" {
+				template.Schedule = r.FormValue("schedule")
+			}
+			if r.FormValue("comment") != "" {
+				template.Comment = r.FormValue("comment")
+			}
+			if r.FormValue("notification-mode") != "" {
+				template.NotificationMode = r.FormValue("notification-mode")
+			}
+			if r.FormValue("ns") != "" {
+				template.Namespace = r.FormValue("ns")
+			}
+			if retry, err := strconv.Atoi(r.FormValue("retry")); err == nil {
+				template.Retry = retry
+			}
+			if retryInterval, err := strconv.Atoi(r.FormValue("retry-interval")); err == nil {
+				template.RetryInterval = retryInterval
+			}
+
+			if delArr, ok := r.Form["delete"]; ok {
+				for _, attr := range delArr {
+					switch attr {
+					case "store":
+						template.Store = ""
+					case "mode":
+						template.Mode = ""
+					case "sourcemode":
+						template.SourceMode = ""
+					case "schedule":
+						template.Schedule = ""
+					case "comment":
+						template.Comment = ""
+					case "notification-mode":
+						template.NotificationMode = ""
+					case "ns":
+						template.Namespace = ""
+					case "retry":
+						template.Retry = 0
+					case "retry-interval":
+						template.RetryInterval = 0
+					}
+				}
+			}
+
+			err = storeInstance.Database.UpdateJobTemplate(nil, template)
+			if err != nil {
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+
+			response.Status = http.StatusOK
+			response.Success = true
+			json.NewEncoder(w).Encode(response)
+
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			template, err := storeInstance.Database.GetJobTemplate(utils.DecodePath(r.PathValue("template")))
+			if err != nil {
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+
+			response.Status = http.StatusOK
+			response.Success = true
+			response.Data = template
+			json.NewEncoder(w).Encode(response)
+
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			err := storeInstance.Database.DeleteJobTemplate(nil, utils.DecodePath(r.PathValue("template")))
+			if err != nil {
+				controllers.WriteErrorResponse(w, err)
+				return
+			}
+
+			response.Status = http.StatusOK
+			response.Success = true
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+}
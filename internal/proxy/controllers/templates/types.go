@@ -0,0 +1,20 @@
+//go:build linux
+
+package templates
+
+import (
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+type JobTemplatesResponse struct {
+	Data   []types.JobTemplate `json:"data"`
+	Digest string              `json:"digest"`
+}
+
+type JobTemplateConfigResponse struct {
+	Errors  map[string]string `json:"errors"`
+	Message string            `json:"message"`
+	Data    types.JobTemplate `json:"data"`
+	Status  int               `json:"status"`
+	Success bool              `json:"success"`
+}
@@ -60,10 +60,11 @@ func ExtJsTokenHandler(storeInstance *store.Store) http.HandlerFunc {
 		}
 
 		newToken := types.AgentToken{
-			Comment: r.FormValue("comment"),
+			Comment:   r.FormValue("comment"),
+			SingleUse: r.FormValue("single_use") == "true" || r.FormValue("single_use") == "1",
 		}
 
-		err = storeInstance.Database.CreateToken(newToken.Comment)
+		err = storeInstance.Database.CreateToken(newToken.Comment, newToken.SingleUse)
 		if err != nil {
 			controllers.WriteErrorResponse(w, err)
 			return
@@ -8,12 +8,18 @@ import (
 	"encoding/pem"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/store"
 )
 
 func AgentOnly(store *store.Store, next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkBearerToken(store, r); err != nil {
+			http.Error(w, "authentication failed - invalid or revoked token", http.StatusUnauthorized)
+			return
+		}
+
 		if err := checkAgentAuth(store, r); err != nil {
 			http.Error(w, "authentication failed - no authentication credentials provided", http.StatusUnauthorized)
 			return
@@ -36,6 +42,11 @@ func ServerOnly(store *store.Store, next http.Handler) http.HandlerFunc {
 
 func AgentOrServer(store *store.Store, next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkBearerToken(store, r); err != nil {
+			http.Error(w, "authentication failed - invalid or revoked token", http.StatusUnauthorized)
+			return
+		}
+
 		authenticated := false
 
 		if err := checkAgentAuth(store, r); err == nil {
@@ -55,6 +66,24 @@ func AgentOrServer(store *store.Store, next http.Handler) http.HandlerFunc {
 	}
 }
 
+// checkBearerToken rejects requests carrying an agent bearer token that is
+// expired, forged or revoked. Requests without one are left to the other
+// checks.
+func checkBearerToken(store *store.Store, r *http.Request) error {
+	tokenStr, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+
+	if store == nil || store.Database == nil || store.Database.TokenManager == nil {
+		return fmt.Errorf("CheckBearerToken: token manager unavailable")
+	}
+	if err := store.Database.TokenManager.ValidateToken(strings.TrimSpace(tokenStr)); err != nil {
+		return fmt.Errorf("CheckBearerToken: %w", err)
+	}
+	return nil
+}
+
 func checkAgentAuth(store *store.Store, r *http.Request) error {
 	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
 		return fmt.Errorf("CheckAgentAuth: client certificate required")
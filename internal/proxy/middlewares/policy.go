@@ -0,0 +1,126 @@
+//go:build linux
+
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+)
+
+// Policy declares who may call a route.
+type Policy int
+
+const (
+	// PolicyUnset is the zero value. Routes registered with it reject every
+	// request and fail Router.Validate.
+	PolicyUnset Policy = iota
+	// PolicyPublic needs no credentials.
+	PolicyPublic
+	// PolicyAgent requires a client certificate matching the pinned
+	// certificate of the agent.
+	PolicyAgent
+	// PolicyServer requires server credentials.
+	PolicyServer
+	// PolicyAgentOrServer accepts either an agent certificate or server
+	// credentials.
+	PolicyAgentOrServer
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyPublic:
+		return "public"
+	case PolicyAgent:
+		return "agent"
+	case PolicyServer:
+		return "server"
+	case PolicyAgentOrServer:
+		return "agent-or-server"
+	default:
+		return "unset"
+	}
+}
+
+func (p Policy) valid() bool {
+	return p >= PolicyPublic && p <= PolicyAgentOrServer
+}
+
+// Router registers routes on a ServeMux together with the policy each one
+// declares, so the authentication requirement of every route is visible in
+// one place and can be checked at startup.
+type Router struct {
+	mux   *http.ServeMux
+	store *store.Store
+
+	mu       sync.Mutex
+	policies map[string]Policy
+}
+
+func NewRouter(store *store.Store, mux *http.ServeMux) *Router {
+	return &Router{
+		mux:      mux,
+		store:    store,
+		policies: make(map[string]Policy),
+	}
+}
+
+// HandleFunc registers handler for pattern behind the middleware of policy.
+func (r *Router) HandleFunc(pattern string, policy Policy, handler http.HandlerFunc) {
+	r.mu.Lock()
+	r.policies[pattern] = policy
+	r.mu.Unlock()
+
+	r.mux.HandleFunc(pattern, r.wrap(policy, handler))
+}
+
+func (r *Router) wrap(policy Policy, handler http.HandlerFunc) http.HandlerFunc {
+	switch policy {
+	case PolicyPublic:
+		return handler
+	case PolicyAgent:
+		return AgentOnly(r.store, handler)
+	case PolicyServer:
+		return ServerOnly(r.store, handler)
+	case PolicyAgentOrServer:
+		return AgentOrServer(r.store, handler)
+	default:
+		// Fail closed: a route without a known policy is never served.
+		return func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "route has no authentication policy", http.StatusForbidden)
+		}
+	}
+}
+
+// Policies returns the declared policy of every registered route.
+func (r *Router) Policies() map[string]Policy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	policies := make(map[string]Policy, len(r.policies))
+	for pattern, policy := range r.policies {
+		policies[pattern] = policy
+	}
+	return policies
+}
+
+// Validate returns an error naming every route that was registered without
+// a known policy.
+func (r *Router) Validate() error {
+	var missing []string
+	for pattern, policy := range r.Policies() {
+		if !policy.valid() {
+			missing = append(missing, pattern)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return fmt.Errorf("routes without an authentication policy: %s", strings.Join(missing, ", "))
+}
@@ -0,0 +1,172 @@
+//go:build linux
+
+package middlewares
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/auth/token"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/sqlite"
+)
+
+func newPolicyRouter(t *testing.T, policy Policy) *http.ServeMux {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	router := NewRouter(nil, mux)
+	router.HandleFunc("/route", policy, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+func serve(mux *http.ServeMux, req *http.Request) int {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func serverRequest() *http.Request {
+	return httptest.NewRequest(http.MethodGet, "/route", nil)
+}
+
+func agentRequest(withCert bool) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/route", nil)
+	req.Header.Set("X-PBS-Agent", "agent1")
+	if withCert {
+		req.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{}}},
+		}
+	}
+	return req
+}
+
+func TestPolicyPublic(t *testing.T) {
+	mux := newPolicyRouter(t, PolicyPublic)
+
+	if code := serve(mux, serverRequest()); code != http.StatusOK {
+		t.Fatalf("expected public route to serve server request, got %d", code)
+	}
+	if code := serve(mux, agentRequest(false)); code != http.StatusOK {
+		t.Fatalf("expected public route to serve unauthenticated agent request, got %d", code)
+	}
+}
+
+func TestPolicyAgent(t *testing.T) {
+	mux := newPolicyRouter(t, PolicyAgent)
+
+	if code := serve(mux, serverRequest()); code != http.StatusUnauthorized {
+		t.Fatalf("expected agent route to reject request without client cert, got %d", code)
+	}
+	// A certificate without a subject is rejected before it is compared to
+	// the pinned certificate.
+	if code := serve(mux, agentRequest(true)); code != http.StatusUnauthorized {
+		t.Fatalf("expected agent route to reject unidentified client cert, got %d", code)
+	}
+}
+
+func TestPolicyServer(t *testing.T) {
+	mux := newPolicyRouter(t, PolicyServer)
+
+	if code := serve(mux, serverRequest()); code != http.StatusOK {
+		t.Fatalf("expected server route to serve server request, got %d", code)
+	}
+	if code := serve(mux, agentRequest(false)); code != http.StatusUnauthorized {
+		t.Fatalf("expected server route to reject agent request, got %d", code)
+	}
+}
+
+func TestPolicyAgentOrServer(t *testing.T) {
+	mux := newPolicyRouter(t, PolicyAgentOrServer)
+
+	if code := serve(mux, serverRequest()); code != http.StatusOK {
+		t.Fatalf("expected agent-or-server route to serve server request, got %d", code)
+	}
+	if code := serve(mux, agentRequest(false)); code != http.StatusUnauthorized {
+		t.Fatalf("expected agent-or-server route to reject agent without cert, got %d", code)
+	}
+}
+
+func TestPolicyUnset(t *testing.T) {
+	mux := newPolicyRouter(t, PolicyUnset)
+
+	if code := serve(mux, serverRequest()); code != http.StatusForbidden {
+		t.Fatalf("expected route without policy to fail closed, got %d", code)
+	}
+}
+
+func TestRouterValidate(t *testing.T) {
+	router := NewRouter(nil, http.NewServeMux())
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+
+	router.HandleFunc("/public", PolicyPublic, noop)
+	router.HandleFunc("/agent", PolicyAgent, noop)
+	router.HandleFunc("/server", PolicyServer, noop)
+	router.HandleFunc("/either", PolicyAgentOrServer, noop)
+	if err := router.Validate(); err != nil {
+		t.Fatalf("expected all routes to have a policy, got %v", err)
+	}
+
+	router.HandleFunc("/forgotten", PolicyUnset, noop)
+	router.HandleFunc("/bogus", Policy(42), noop)
+	err := router.Validate()
+	if err == nil {
+		t.Fatal("expected routes without a policy to fail validation")
+	}
+	if got, want := err.Error(), "routes without an authentication policy: /bogus, /forgotten"; got != want {
+		t.Fatalf("unexpected error: got %q, want %q", got, want)
+	}
+
+	if got := router.Policies()["/agent"]; got != PolicyAgent {
+		t.Fatalf("expected /agent to report agent policy, got %s", got)
+	}
+}
+
+func TestPolicyRejectsRevokedToken(t *testing.T) {
+	tokens, err := token.NewManager(token.Config{SecretKey: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	storeInstance := &store.Store{Database: &sqlite.Database{TokenManager: tokens}}
+
+	mux := http.NewServeMux()
+	router := NewRouter(storeInstance, mux)
+	router.HandleFunc("/route", PolicyAgentOrServer, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tokenStr, err := tokens.GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	withToken := func() *http.Request {
+		req := serverRequest()
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+		return req
+	}
+
+	if code := serve(mux, withToken()); code != http.StatusOK {
+		t.Fatalf("expected valid token to be accepted, got %d", code)
+	}
+
+	id, err := tokens.TokenID(tokenStr)
+	if err != nil {
+		t.Fatalf("TokenID failed: %v", err)
+	}
+	if err := tokens.Revoke(id); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+
+	if code := serve(mux, withToken()); code != http.StatusUnauthorized {
+		t.Fatalf("expected revoked token to be rejected, got %d", code)
+	}
+	if code := serve(mux, serverRequest()); code != http.StatusOK {
+		t.Fatalf("expected request without token to be unaffected, got %d", code)
+	}
+}
@@ -16,11 +16,14 @@ import (
 	"time"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/agent/agentfs/types"
+	binarystream "github.com/sonroyaalmerol/pbs-plus/internal/arpc/binary"
 	arpcfs "github.com/sonroyaalmerol/pbs-plus/internal/backend/arpc"
 	"github.com/sonroyaalmerol/pbs-plus/internal/backend/arpc/mount"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
 )
 
 type BackupArgs struct {
@@ -33,6 +36,9 @@ type BackupReply struct {
 	Status     int
 	Message    string
 	BackupMode string
+	// ChangedFiles is the number of files the agent change journal reports
+	// changed since the last backup, or -1 when the tree is walked in full.
+	ChangedFiles int
 }
 
 type CleanupArgs struct {
@@ -46,6 +52,17 @@ type CleanupReply struct {
 	Message string
 }
 
+type FailedPathsArgs struct {
+	JobId          string
+	TargetHostname string
+}
+
+type FailedPathsReply struct {
+	// Paths maps the entries the agent listed but could not read to the
+	// error it reported for them.
+	Paths map[string]string
+}
+
 type MountRPCService struct {
 	Store *store.Store
 }
@@ -71,7 +88,8 @@ func (s *MountRPCService) Backup(args *BackupArgs, reply *BackupReply) error {
 	ctx, cancel := context.WithTimeout(s.Store.Ctx, 5*time.Minute)
 	defer cancel()
 
-	// Retrieve the ARPC session for the target.
+	// Retrieve the ARPC session for the target. The job already waited for
+	// a reconnecting agent before asking for the mount.
 	arpcSess, exists := s.Store.ARPCSessionManager.GetSession(args.TargetHostname)
 	if !exists {
 		reply.Status = 500
@@ -118,6 +136,10 @@ func (s *MountRPCService) Backup(args *BackupArgs, reply *BackupReply) error {
 		reply.Message = "MountHandler: Failed to send backup request to target -> unable to reach child target"
 		return errors.New(reply.Message)
 	}
+	// The child session carries only this job's data, so its limit is the
+	// limit of the job.
+	arpcFSRPC.SetRateLimiter(binarystream.NewLimiter(job.BandwidthLimit))
+
 	arpcFS := arpcfs.NewARPCFS(s.Store.Ctx, arpcFSRPC, args.TargetHostname, args.JobId, backupMode)
 	if arpcFS == nil {
 		reply.Status = 500
@@ -125,6 +147,87 @@ func (s *MountRPCService) Backup(args *BackupArgs, reply *BackupReply) error {
 		return errors.New(reply.Message)
 	}
 
+	// The agent leaves excluded entries out of its listings so they never
+	// cross the wire. proxmox-backup-client cannot apply regex exclusions,
+	// so on agents that predate the push the mount hides what they match.
+	// The full rule set is applied to keep the precedence between regex and
+	// glob rules.
+	target, targetErr := s.Store.Database.GetTarget(job.Target)
+	caseInsensitive := targetErr == nil && utils.IsCaseInsensitiveTarget(target.Path)
+	if targetErr == nil {
+		filters := s.Store.EffectiveFilters(job, caseInsensitive)
+		pushed := false
+		if len(filters.Rules) > 0 {
+			pushed, err = arpcFS.PushExclusions(filters, job.Subpath)
+			if err != nil {
+				syslog.L.Warn().
+					WithMessage("failed to push exclusions to agent, filtering on the server").
+					WithField("jobId", args.JobId).
+					WithField("error", err.Error()).
+					Write()
+			}
+		}
+		var exclusions *pattern.Matcher
+		if !pushed && filters.HasRegex() {
+			exclusions, err = pattern.NewMatcher(filters)
+			if err != nil {
+				reply.Status = 500
+				reply.Message = fmt.Sprintf("MountHandler: invalid exclusions -> %v", err)
+				return fmt.Errorf("backup: %w", err)
+			}
+		}
+
+		// Inclusions are always applied here; exclusions, wherever they
+		// are applied, still remove entries within the included set.
+		inclusions, err := s.Store.InclusionMatcher(job, caseInsensitive)
+		if err != nil {
+			reply.Status = 500
+			reply.Message = fmt.Sprintf("MountHandler: invalid inclusions -> %v", err)
+			return fmt.Errorf("backup: %w", err)
+		}
+
+		if filter := pattern.Combine(exclusions, inclusions); filter != nil {
+			arpcFS.SetFilter(filter, job.Subpath, caseInsensitive)
+		}
+	}
+
+	if job.Manifest {
+		manifestPath := filepath.Join(constants.JobLogsBasePath, args.JobId, arpcfs.PendingManifestName)
+		manifest, err := arpcfs.NewManifest(manifestPath, job.ManifestHash, arpcfs.DefaultManifestLimit)
+		if err != nil {
+			syslog.L.Error(err).WithMessage("failed to create file manifest").WithField("jobId", args.JobId).Write()
+		} else {
+			arpcFS.SetManifest(manifest)
+		}
+	}
+
+	// Partial files are compared block by block with what the previous run
+	// read, so only the changed blocks cross the wire.
+	if partials := pattern.ParseRawList(job.PartialFiles); len(partials) > 0 {
+		matcher, err := pattern.NewMatcher(pattern.ResolvePatterns(nil, partials, caseInsensitive))
+		if err != nil {
+			syslog.L.Error(err).WithMessage("invalid partial file patterns, reading them whole").WithField("jobId", args.JobId).Write()
+		} else {
+			cacheDir := filepath.Join(constants.PartialFilesBasePath, args.JobId)
+			arpcFS.SetPartialFiles(arpcfs.NewPartialFileCache(cacheDir, matcher, job.Subpath, caseInsensitive, arpcfs.DefaultPartialBlockSize))
+		}
+	}
+
+	// FUSE looks up the entries of a directory concurrently, which batching
+	// turns into a few StatBatch calls instead of one Attr call each.
+	arpcFS.SetStatBatch(arpcfs.DefaultStatBatchSize, arpcfs.DefaultStatBatchInterval)
+
+	reply.ChangedFiles = -1
+	if changed, err := arpcFS.ChangedFiles(); err != nil {
+		syslog.L.Warn().
+			WithMessage("failed to get changed files, walking the full tree").
+			WithField("jobId", args.JobId).
+			WithField("error", err.Error()).
+			Write()
+	} else if changed.Available {
+		reply.ChangedFiles = len(changed.Paths)
+	}
+
 	store.CreateFSConnection(childKey, arpcFSRPC, arpcFS)
 
 	// Set up the local mount path.
@@ -166,6 +269,20 @@ func (s *MountRPCService) Cleanup(args *CleanupArgs, reply *CleanupReply) error
 	ctx, cancel := context.WithTimeout(s.Store.Ctx, 5*time.Minute)
 	defer cancel()
 
+	// Flush the file manifest before the job renames it.
+	if arpcFS := store.GetSessionFS(args.TargetHostname + "|" + args.JobId); arpcFS != nil {
+		if excluded := arpcFS.ExcludedCount(); excluded > 0 {
+			syslog.L.Info().
+				WithMessage("entries left out by exclusions").
+				WithField("jobId", args.JobId).
+				WithField("excluded", excluded).
+				Write()
+		}
+		if err := arpcFS.CloseManifest(); err != nil {
+			syslog.L.Error(err).WithMessage("failed to close file manifest").WithField("jobId", args.JobId).Write()
+		}
+	}
+
 	// Try to acquire an ARPC session for the target.
 	arpcSess, exists := s.Store.ARPCSessionManager.GetSession(args.TargetHostname)
 	if !exists {
@@ -204,6 +321,17 @@ func (s *MountRPCService) Cleanup(args *CleanupArgs, reply *CleanupReply) error
 	return nil
 }
 
+// FailedPaths reports the entries of the mounted tree the agent could not
+// read, so the job can list them in its task log.
+func (s *MountRPCService) FailedPaths(args *FailedPathsArgs, reply *FailedPathsReply) error {
+	arpcFS := store.GetSessionFS(args.TargetHostname + "|" + args.JobId)
+	if arpcFS == nil {
+		return fmt.Errorf("failed paths: no mount for job %s", args.JobId)
+	}
+	reply.Paths = arpcFS.FailedPaths()
+	return nil
+}
+
 func StartRPCServer(socketPath string, storeInstance *store.Store) error {
 	// Remove any stale socket file.
 	_ = os.RemoveAll(socketPath)
@@ -10,6 +10,8 @@ Ext.define("pbs-disk-backup-job-status", {
     "ns",
     "schedule",
     "comment",
+    "tags",
+    "enabled",
     "duration",
     "current_bytes_total",
     "current_bytes_speed",
@@ -56,12 +58,12 @@ Ext.define("pbs-model-targets", {
 
 Ext.define("pbs-model-tokens", {
   extend: "Ext.data.Model",
-  fields: ["token", "comment", "created_at", "revoked"],
+  fields: ["token", "comment", "created_at", "revoked", "single_use"],
   idProperty: "token",
 });
 
 Ext.define("pbs-model-exclusions", {
   extend: "Ext.data.Model",
-  fields: ["path", "comment"],
+  fields: ["path", "match_type", "case_sensitive", "comment"],
   idProperty: "path",
 });
@@ -101,6 +101,22 @@ Ext.define("PBS.D2DManagement.ExclusionPanel", {
       dataIndex: "path",
       flex: 1,
     },
+    {
+      text: gettext("Match Type"),
+      dataIndex: "match_type",
+      width: 120,
+    },
+    {
+      text: gettext("Case Sensitive"),
+      dataIndex: "case_sensitive",
+      width: 120,
+      renderer: function (value) {
+        if (value === undefined || value === null || value === "") {
+          return gettext("Target default");
+        }
+        return Proxmox.Utils.format_boolean(value);
+      },
+    },
     {
       text: gettext("Comment"),
       dataIndex: "comment",
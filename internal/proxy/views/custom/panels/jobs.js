@@ -443,6 +443,13 @@ Ext.define("PBS.config.DiskBackupJobView", {
       width: 120,
       sortable: true,
     },
+    {
+      header: gettext("Enabled"),
+      dataIndex: "enabled",
+      renderer: Proxmox.Utils.format_boolean,
+      width: 70,
+      sortable: true,
+    },
     {
       header: gettext("Schedule"),
       dataIndex: "schedule",
@@ -57,6 +57,47 @@ Ext.define("PBS.D2DManagement.TargetPanel", {
       }).show();
     },
 
+    onTest: function () {
+      let me = this;
+      let view = me.getView();
+      let selection = view.getSelection();
+      if (!selection || selection.length < 1) {
+        return;
+      }
+
+      let name = selection[0].data.name;
+      Proxmox.Utils.API2Request({
+        url:
+          pbsPlusBaseUrl +
+          `/api2/extjs/config/d2d-target/${encodeURIComponent(encodePathValue(name))}/test`,
+        method: "POST",
+        waitMsgTarget: view,
+        failure: (response) =>
+          Ext.Msg.alert(gettext("Error"), response.htmlStatus),
+        success: function (response) {
+          let result = response.result.data;
+          let lines = [
+            `${gettext("Latency")}: ${result.latency_ms} ms`,
+          ];
+          if (result.agent_version) {
+            lines.push(`${gettext("Agent Version")}: ${Ext.htmlEncode(result.agent_version)}`);
+          }
+          if (result.error) {
+            lines.push(`${gettext("Error")}: ${Ext.htmlEncode(result.error)}`);
+          }
+          Ext.Msg.show({
+            title: Ext.htmlEncode(name),
+            icon: result.reachable ? Ext.Msg.INFO : Ext.Msg.ERROR,
+            message:
+              `<b>${result.reachable ? gettext("Reachable") : gettext("Unreachable")}</b><br>` +
+              lines.join("<br>"),
+            buttons: Ext.Msg.OK,
+          });
+          me.reload();
+        },
+      });
+    },
+
     reload: function () {
       this.getView().getStore().rstore.load();
     },
@@ -171,6 +212,12 @@ Ext.define("PBS.D2DManagement.TargetPanel", {
       handler: "onEdit",
       disabled: true,
     },
+    {
+      text: gettext("Test Connection"),
+      xtype: "proxmoxButton",
+      handler: "onTest",
+      disabled: true,
+    },
     {
       xtype: "proxmoxStdRemoveButton",
       baseurl: pbsPlusBaseUrl + "/api2/extjs/config/d2d-target",
@@ -73,6 +73,7 @@ Ext.define("PBS.D2DManagement.TokenPanel", {
         `[System.Net.ServicePointManager]::ServerCertificateValidationCallback={$true}; ` +
         `[Net.ServicePointManager]::SecurityProtocol=[Net.SecurityProtocolType]::Tls12; ` +
         `iex(New-Object Net.WebClient).DownloadString("https://${hostname}:8008/plus/agent/install/win?t=${token}")`;
+      const shellCommand = `curl -fsSLk "https://${hostname}:8008/plus/agent/install/linux?t=${token}" | sudo sh`;
 
       Ext.create("Ext.window.Window", {
         modal: true,
@@ -88,6 +89,13 @@ Ext.define("PBS.D2DManagement.TokenPanel", {
             value: powershellCommand,
             editable: false,
           },
+          {
+            fieldLabel: gettext("Linux (Shell)"),
+            xtype: "textfield",
+            inputId: "sh-command",
+            value: shellCommand,
+            editable: false,
+          },
         ],
         buttons: [
           {
@@ -96,7 +104,15 @@ Ext.define("PBS.D2DManagement.TokenPanel", {
             handler: async function (b) {
               await navigator.clipboard.writeText(powershellCommand);
             },
-            text: gettext("Copy"),
+            text: gettext("Copy Windows"),
+          },
+          {
+            xtype: "button",
+            iconCls: "fa fa-clipboard",
+            handler: async function (b) {
+              await navigator.clipboard.writeText(shellCommand);
+            },
+            text: gettext("Copy Linux"),
           },
           {
             text: gettext("Ok"),
@@ -207,6 +223,12 @@ Ext.define("PBS.D2DManagement.TokenPanel", {
       renderer: "render_valid",
       flex: 3,
     },
+    {
+      header: gettext("Single Use"),
+      dataIndex: "single_use",
+      renderer: Proxmox.Utils.format_boolean,
+      flex: 1,
+    },
     {
       header: gettext("Created At"),
       dataIndex: "created_at",
@@ -28,7 +28,7 @@ Ext.define("PBS.D2DManagement.BackupWindow", {
   },
 
   layout: "hbox",
-  width: 400,
+  width: 450,
   method: "POST",
   isCreate: true,
   submitText: gettext("Start Backup"),
@@ -66,6 +66,34 @@ Ext.define("PBS.D2DManagement.BackupWindow", {
                 value: "{id}",
               },
             },
+            {
+              xtype: "proxmoxcheckbox",
+              boxLabel: gettext("Test run (scratch namespace)"),
+              name: "test",
+              uncheckedValue: 0,
+              listeners: {
+                change: function (field, value) {
+                  let container = field.up("container");
+                  container.down("[name=test-store]").setDisabled(!value);
+                  container.down("[name=test-prune]").setDisabled(!value);
+                },
+              },
+            },
+            {
+              xtype: "pbsDataStoreSelector",
+              fieldLabel: gettext("Test Datastore"),
+              emptyText: gettext("Job datastore"),
+              name: "test-store",
+              allowBlank: true,
+              disabled: true,
+            },
+            {
+              xtype: "proxmoxcheckbox",
+              boxLabel: gettext("Prune test data afterwards"),
+              name: "test-prune",
+              uncheckedValue: 0,
+              disabled: true,
+            },
           ],
         },
       ],
@@ -1,3 +1,12 @@
+var exclusionMatchTypes = Ext.create("Ext.data.Store", {
+  fields: ["display", "value"],
+  data: [
+    { display: "Glob", value: "glob" },
+    { display: "Regular Expression", value: "regex" },
+    { display: "Literal", value: "literal" },
+  ],
+});
+
 Ext.define("PBS.D2DManagement.ExclusionEditWindow", {
   extend: "Proxmox.window.Edit",
   alias: "widget.pbsExclusionEditWindow",
@@ -32,6 +41,31 @@ Ext.define("PBS.D2DManagement.ExclusionEditWindow", {
         editable: "{isCreate}",
       },
     },
+    {
+      xtype: "combo",
+      fieldLabel: gettext("Match Type"),
+      name: "match_type",
+      queryMode: "local",
+      store: exclusionMatchTypes,
+      displayField: "display",
+      valueField: "value",
+      editable: false,
+      forceSelection: true,
+      allowBlank: true,
+      value: "glob",
+    },
+    {
+      xtype: "proxmoxKVComboBox",
+      fieldLabel: gettext("Case Sensitivity"),
+      name: "case_sensitive",
+      comboItems: [
+        ["__default__", gettext("Target default")],
+        ["true", gettext("Case-sensitive")],
+        ["false", gettext("Case-insensitive")],
+      ],
+      value: "__default__",
+      deleteEmpty: true,
+    },
     {
       fieldLabel: gettext("Comment"),
       xtype: "proxmoxtextfield",
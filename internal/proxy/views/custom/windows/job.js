@@ -15,6 +15,15 @@ var sourceModes = Ext.create("Ext.data.Store", {
   ],
 });
 
+var sizeGuardActions = Ext.create("Ext.data.Store", {
+  fields: ["display", "value"],
+  data: [
+    { display: "Disabled", value: "" },
+    { display: "Warn", value: "warn" },
+    { display: "Block", value: "block" },
+  ],
+});
+
 Ext.define("PBS.D2DManagement.BackupJobEdit", {
   extend: "Proxmox.window.Edit",
   alias: "widget.pbsDiskBackupJobEdit",
@@ -137,6 +146,17 @@ Ext.define("PBS.D2DManagement.BackupJobEdit", {
         ],
 
         column2: [
+          {
+            xtype: "proxmoxcheckbox",
+            fieldLabel: gettext("Enabled"),
+            name: "enabled",
+            checked: true,
+            uncheckedValue: 0,
+            defaultValue: 1,
+            cbind: {
+              deleteDefaultValue: "{!isCreate}",
+            },
+          },
           {
             fieldLabel: gettext("Schedule"),
             xtype: "pbsD2DCalendarEvent",
@@ -159,6 +179,18 @@ Ext.define("PBS.D2DManagement.BackupJobEdit", {
             emptyText: gettext("1"),
             name: "retry-interval",
           },
+          {
+            xtype: "proxmoxtextfield",
+            fieldLabel: gettext("Retry backoff multiplier"),
+            emptyText: gettext("1"),
+            name: "retry-multiplier",
+          },
+          {
+            xtype: "proxmoxtextfield",
+            fieldLabel: gettext("Max retry interval (minutes)"),
+            emptyText: gettext("none"),
+            name: "retry-max-interval",
+          },
           {
             xtype: "combo",
             fieldLabel: gettext("Backup Mode"),
@@ -191,6 +223,100 @@ Ext.define("PBS.D2DManagement.BackupJobEdit", {
               value: "{sourceModeValue}",
             },
           },
+          {
+            xtype: "combo",
+            fieldLabel: gettext("Size Guard"),
+            name: "size-guard",
+            queryMode: "local",
+            store: sizeGuardActions,
+            displayField: "display",
+            valueField: "value",
+            editable: false,
+            forceSelection: true,
+            allowBlank: true,
+            value: "",
+          },
+          {
+            xtype: "proxmoxtextfield",
+            fieldLabel: gettext("Max size (bytes)"),
+            emptyText: gettext("no quota"),
+            name: "max-size",
+          },
+          {
+            xtype: "proxmoxtextfield",
+            fieldLabel: gettext("Bandwidth limit (bytes/s)"),
+            emptyText: gettext("unlimited"),
+            name: "bandwidth-limit",
+          },
+          {
+            xtype: "proxmoxtextfield",
+            fieldLabel: gettext("Webhook URL"),
+            emptyText: gettext("none"),
+            name: "webhook-url",
+            cbind: {
+              deleteEmpty: "{!isCreate}",
+            },
+          },
+          {
+            xtype: "proxmoxtextfield",
+            fieldLabel: gettext("Encryption Key File"),
+            emptyText: gettext("none (unencrypted)"),
+            name: "encryption-key-file",
+            cbind: {
+              deleteEmpty: "{!isCreate}",
+            },
+          },
+          {
+            xtype: "proxmoxcheckbox",
+            fieldLabel: gettext("Serialize Datastore"),
+            name: "serialize-store",
+            uncheckedValue: 0,
+            defaultValue: 0,
+            cbind: {
+              deleteDefaultValue: "{!isCreate}",
+            },
+          },
+          {
+            xtype: "proxmoxcheckbox",
+            fieldLabel: gettext("Create Namespace"),
+            name: "create-namespace",
+            checked: true,
+            uncheckedValue: 0,
+            defaultValue: 1,
+            cbind: {
+              deleteDefaultValue: "{!isCreate}",
+            },
+          },
+          {
+            xtype: "proxmoxcheckbox",
+            fieldLabel: gettext("Run on Agent Check-in"),
+            name: "run-on-checkin",
+            uncheckedValue: 0,
+            defaultValue: 0,
+            cbind: {
+              deleteDefaultValue: "{!isCreate}",
+            },
+          },
+          {
+            xtype: "proxmoxcheckbox",
+            fieldLabel: gettext("Keep File Manifest"),
+            name: "manifest",
+            uncheckedValue: 0,
+            defaultValue: 0,
+            cbind: {
+              deleteDefaultValue: "{!isCreate}",
+            },
+          },
+          {
+            xtype: "proxmoxcheckbox",
+            fieldLabel: gettext("Hash Manifest Files"),
+            name: "manifest-hash",
+            uncheckedValue: 0,
+            defaultValue: 0,
+            cbind: {
+              deleteDefaultValue: "{!isCreate}",
+            },
+          },
         ],
 
         columnB: [
@@ -202,6 +328,24 @@ Ext.define("PBS.D2DManagement.BackupJobEdit", {
               deleteEmpty: "{!isCreate}",
             },
           },
+          {
+            fieldLabel: gettext("Tags"),
+            xtype: "proxmoxtextfield",
+            name: "tags",
+            emptyText: gettext("Comma separated, e.g. prod, web"),
+            cbind: {
+              deleteEmpty: "{!isCreate}",
+            },
+          },
+          {
+            xtype: "textarea",
+            name: "partial-files",
+            fieldLabel: gettext("Partial Files"),
+            value: "",
+            emptyText: gettext(
+              "Newline delimited list of files, such as disk images or databases, of which only the changed blocks are read from the agent. Patterns follow the exclusions syntax.",
+            ),
+          },
           {
             xtype: "textarea",
             name: "rawexclusions",
@@ -209,7 +353,17 @@ Ext.define("PBS.D2DManagement.BackupJobEdit", {
             fieldLabel: gettext("Exclusions"),
             value: "",
             emptyText: gettext(
-              "Newline delimited list of exclusions following the .pxarexclude patterns.",
+              "Newline delimited list of exclusions following the .pxarexclude patterns. Prefix a line with regex: or literal: to change how it matches.",
+            ),
+          },
+          {
+            xtype: "textarea",
+            name: "rawinclusions",
+            height: "100%",
+            fieldLabel: gettext("Inclusions"),
+            value: "",
+            emptyText: gettext(
+              "Newline delimited list of paths to back up, leaving out everything else. Patterns follow the exclusions syntax but cannot be negated; exclusions still apply within them. Only supported for agent targets.",
             ),
           },
         ],
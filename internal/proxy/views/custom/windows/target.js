@@ -42,5 +42,45 @@ Ext.define("PBS.D2DManagement.TargetEditWindow", {
         editable: "{isCreate}",
       },
     },
+    {
+      xtype: "proxmoxintegerfield",
+      fieldLabel: gettext("Connect timeout (s)"),
+      name: "connect_timeout",
+      minValue: 0,
+      emptyText: gettext("default"),
+      cbind: {
+        deleteEmpty: "{!isCreate}",
+      },
+    },
+    {
+      xtype: "proxmoxintegerfield",
+      fieldLabel: gettext("Max retries"),
+      name: "max_retries",
+      minValue: 0,
+      emptyText: gettext("none"),
+      cbind: {
+        deleteEmpty: "{!isCreate}",
+      },
+    },
+    {
+      xtype: "proxmoxintegerfield",
+      fieldLabel: gettext("Max concurrent jobs"),
+      name: "max_concurrency",
+      minValue: 0,
+      emptyText: gettext("unlimited"),
+      cbind: {
+        deleteEmpty: "{!isCreate}",
+      },
+    },
+    {
+      xtype: "proxmoxcheckbox",
+      fieldLabel: gettext("Fail when busy"),
+      name: "fail_when_busy",
+      uncheckedValue: 0,
+      defaultValue: 0,
+      cbind: {
+        deleteDefaultValue: "{!isCreate}",
+      },
+    },
   ],
 });
@@ -32,5 +32,15 @@ Ext.define("PBS.D2DManagement.TokenEditWindow", {
         editable: "{isCreate}",
       },
     },
+    {
+      xtype: "proxmoxcheckbox",
+      fieldLabel: gettext("Single Use"),
+      name: "single_use",
+      uncheckedValue: 0,
+      defaultValue: 0,
+      cbind: {
+        disabled: "{!isCreate}",
+      },
+    },
   ],
 });
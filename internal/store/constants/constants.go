@@ -1,15 +1,22 @@
 package constants
 
 const (
-	ProxyTargetURL     = "https://127.0.0.1:8007"        // The target server URL
-	ModifiedFilePath   = "/js/proxmox-backup-gui.js"     // The specific JS file to modify
-	CertFile           = "/etc/proxmox-backup/proxy.pem" // Path to generated SSL certificate
-	KeyFile            = "/etc/proxmox-backup/proxy.key" // Path to generated private key
-	TimerBasePath      = "/lib/systemd/system"
-	DbBasePath         = "/var/lib/proxmox-backup"
-	AgentMountBasePath = "/mnt/pbs-plus-mounts"
-	LogsBasePath       = "/var/log/proxmox-backup"
-	TaskLogsBasePath   = LogsBasePath + "/tasks"
-	JobLogsBasePath    = "/var/log/pbs-plus"
-	MountSocketPath    = "/var/run/pbs_agent_mount.sock"
+	ProxyTargetURL        = "https://127.0.0.1:8007"        // The target server URL
+	ModifiedFilePath      = "/js/proxmox-backup-gui.js"     // The specific JS file to modify
+	CertFile              = "/etc/proxmox-backup/proxy.pem" // Path to generated SSL certificate
+	KeyFile               = "/etc/proxmox-backup/proxy.key" // Path to generated private key
+	TimerBasePath         = "/lib/systemd/system"
+	DbBasePath            = "/var/lib/proxmox-backup"
+	AgentMountBasePath    = "/mnt/pbs-plus-mounts"
+	LogsBasePath          = "/var/log/proxmox-backup"
+	TaskLogsBasePath      = LogsBasePath + "/tasks"
+	JobLogsBasePath       = "/var/log/pbs-plus"
+	MountSocketPath       = "/var/run/pbs_agent_mount.sock"
+	InstanceLockPath      = "/var/run/pbs-plus.lock" // Held by the running server to keep it a singleton
+	DefaultJobTemplate    = "default"                // Template applied to new jobs that do not name one
+	TestRunNamespace      = "pbs-plus-test"          // Scratch namespace used by test runs
+	TokenRevocationFile   = "/etc/proxmox-backup/pbs-plus/revoked-tokens.json"
+	MigrationManifestFile = "/etc/proxmox-backup/pbs-plus/migration.json" // Progress of the legacy config migration
+	PartialFilesBasePath  = "/var/lib/pbs-plus/partial"                   // Content of the partial files of each job as last backed up
+	ConfigLocksPath       = "/etc/proxmox-backup/pbs-plus/.locks"         // Cross-process locks of the config files
 )
@@ -76,10 +76,8 @@ func Initialize(paths map[string]string) (*Database, error) {
 		return nil, fmt.Errorf("Initialize: error checking init file: %w", err)
 	}
 
-	for key, path := range paths {
-		if key == "cert" || key == "key" {
-			continue
-		}
+	for _, key := range []string{"jobs", "targets", "exclusions", "tokens"} {
+		path := paths[key]
 		if path == "" {
 			return nil, fmt.Errorf("Initialize: empty path for key: %s", key)
 		}
@@ -115,3 +113,13 @@ func Initialize(paths map[string]string) (*Database, error) {
 
 	return database, nil
 }
+
+// Remove deletes the legacy config directories. The init marker is kept.
+func (database *Database) Remove() error {
+	for _, key := range []string{"jobs", "targets", "exclusions", "tokens"} {
+		if err := os.RemoveAll(database.paths[key]); err != nil {
+			return fmt.Errorf("Remove: error removing %s: %w", database.paths[key], err)
+		}
+	}
+	return nil
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	configLib "github.com/sonroyaalmerol/pbs-plus/internal/config"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
@@ -14,6 +13,39 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
 )
 
+// globalExclusionFile is the file of the exclusions that apply to every job.
+const globalExclusionFile = "global.cfg"
+
+// exclusionConfigPath returns the file holding the exclusions of jobId, or
+// the global exclusions when jobId is empty.
+func (database *Database) exclusionConfigPath(jobId string) string {
+	if jobId == "" {
+		return filepath.Join(database.paths["exclusions"], globalExclusionFile)
+	}
+	return filepath.Join(database.paths["exclusions"], utils.EncodeConfigName(jobId))
+}
+
+// jobExclusionFiles lists the files holding the exclusions of a job,
+// leaving out the global file and files that are not configs.
+func (database *Database) jobExclusionFiles() ([]string, error) {
+	entries, err := os.ReadDir(database.paths["exclusions"])
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == globalExclusionFile {
+			continue
+		}
+		if _, ok := utils.DecodeConfigName(entry.Name()); !ok {
+			continue
+		}
+		files = append(files, filepath.Join(database.paths["exclusions"], entry.Name()))
+	}
+	return files, nil
+}
+
 func (database *Database) RegisterExclusionPlugin() {
 	plugin := &configLib.SectionPlugin[types.Exclusion]{
 		TypeName:   "exclusion",
@@ -30,18 +62,13 @@ func (database *Database) RegisterExclusionPlugin() {
 }
 
 func (database *Database) CreateExclusion(exclusion types.Exclusion) error {
-	exclusion.Path = strings.ReplaceAll(exclusion.Path, "\\", "/")
+	exclusion.Path = pattern.NormalizePattern(exclusion.Path, exclusion.MatchType)
 
 	if !pattern.IsValidPattern(exclusion.Path) {
 		return fmt.Errorf("CreateExclusion: invalid path pattern -> %s", exclusion.Path)
 	}
 
-	filename := "global"
-	if exclusion.JobID != "" {
-		filename = utils.EncodePath(exclusion.JobID)
-	}
-
-	configPath := filepath.Join(database.paths["exclusions"], filename+".cfg")
+	configPath := database.exclusionConfigPath(exclusion.JobID)
 
 	// Read existing exclusions
 	var configData *configLib.ConfigData[types.Exclusion]
@@ -77,7 +104,7 @@ func (database *Database) CreateExclusion(exclusion types.Exclusion) error {
 }
 
 func (database *Database) GetAllJobExclusions(jobId string) ([]types.Exclusion, error) {
-	configPath := filepath.Join(database.paths["exclusions"], utils.EncodePath(jobId)+".cfg")
+	configPath := database.exclusionConfigPath(jobId)
 	configData, err := database.exclusionsConfig.Parse(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -102,7 +129,7 @@ func (database *Database) GetAllJobExclusions(jobId string) ([]types.Exclusion,
 }
 
 func (database *Database) GetAllGlobalExclusions() ([]types.Exclusion, error) {
-	configPath := filepath.Join(database.paths["exclusions"], "global.cfg")
+	configPath := database.exclusionConfigPath("")
 	configData, err := database.exclusionsConfig.Parse(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -128,7 +155,7 @@ func (database *Database) GetAllGlobalExclusions() ([]types.Exclusion, error) {
 
 func (database *Database) GetExclusion(path string) (*types.Exclusion, error) {
 	// Check global exclusions first
-	globalPath := filepath.Join(database.paths["exclusions"], "global.cfg")
+	globalPath := database.exclusionConfigPath("")
 	if configData, err := database.exclusionsConfig.Parse(globalPath); err == nil {
 		sectionID := fmt.Sprintf("excl-%s", path)
 		if section, exists := configData.Sections[sectionID]; exists {
@@ -137,7 +164,7 @@ func (database *Database) GetExclusion(path string) (*types.Exclusion, error) {
 	}
 
 	// Check job-specific exclusions
-	files, err := os.ReadDir(database.paths["exclusions"])
+	files, err := database.jobExclusionFiles()
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, err
@@ -145,11 +172,7 @@ func (database *Database) GetExclusion(path string) (*types.Exclusion, error) {
 		return nil, fmt.Errorf("GetExclusion: error reading directory: %w", err)
 	}
 
-	for _, file := range files {
-		if file.Name() == "global" {
-			continue
-		}
-		configPath := filepath.Join(database.paths["exclusions"], file.Name())
+	for _, configPath := range files {
 		configData, err := database.exclusionsConfig.Parse(configPath)
 		if err != nil {
 			continue
@@ -165,15 +188,12 @@ func (database *Database) GetExclusion(path string) (*types.Exclusion, error) {
 }
 
 func (database *Database) UpdateExclusion(exclusion types.Exclusion) error {
-	exclusion.Path = strings.ReplaceAll(exclusion.Path, "\\", "/")
+	exclusion.Path = pattern.NormalizePattern(exclusion.Path, exclusion.MatchType)
 	if !pattern.IsValidPattern(exclusion.Path) {
 		return fmt.Errorf("UpdateExclusion: invalid path pattern -> %s", exclusion.Path)
 	}
 
-	configPath := filepath.Join(database.paths["exclusions"], "global.cfg")
-	if exclusion.JobID != "" {
-		configPath = filepath.Join(database.paths["exclusions"], utils.EncodePath(exclusion.JobID)+".cfg")
-	}
+	configPath := database.exclusionConfigPath(exclusion.JobID)
 
 	configData, err := database.exclusionsConfig.Parse(configPath)
 	if err != nil {
@@ -192,20 +212,16 @@ func (database *Database) UpdateExclusion(exclusion types.Exclusion) error {
 }
 
 func (database *Database) DeleteExclusion(path string) error {
-	path = strings.ReplaceAll(path, "\\", "/")
+	path = pattern.NormalizePattern(path, pattern.MatchGlob)
 	sectionID := fmt.Sprintf("excl-%s", path)
 
 	// Try job-specific exclusions first
-	files, err := os.ReadDir(database.paths["exclusions"])
+	files, err := database.jobExclusionFiles()
 	if err != nil {
 		return fmt.Errorf("DeleteExclusion: error reading directory: %w", err)
 	}
 
-	for _, file := range files {
-		if file.Name() == "global" {
-			continue
-		}
-		configPath := filepath.Join(database.paths["exclusions"], file.Name())
+	for _, configPath := range files {
 		configData, err := database.exclusionsConfig.Parse(configPath)
 		if err != nil {
 			continue
@@ -238,7 +254,7 @@ func (database *Database) DeleteExclusion(path string) error {
 	}
 
 	// Try global exclusion
-	globalPath := filepath.Join(database.paths["exclusions"], "global.cfg")
+	globalPath := database.exclusionConfigPath("")
 	if configData, err := database.exclusionsConfig.Parse(globalPath); err == nil {
 		if _, exists := configData.Sections[sectionID]; exists {
 			delete(configData.Sections, sectionID)
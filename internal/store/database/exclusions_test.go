@@ -0,0 +1,78 @@
+//go:build linux
+
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0750))
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0640))
+	return path
+}
+
+func TestExclusionLookup(t *testing.T) {
+	dir := t.TempDir()
+	database := &Database{paths: map[string]string{"exclusions": dir}}
+	database.RegisterExclusionPlugin()
+
+	require.NoError(t, database.CreateExclusion(types.Exclusion{Path: "*.log", Comment: "global"}))
+	require.NoError(t, database.CreateExclusion(types.Exclusion{Path: "*.tmp", JobID: "web", Comment: "web"}))
+	require.NoError(t, database.CreateExclusion(types.Exclusion{Path: "/cache", JobID: "db", Comment: "db"}))
+	require.NoError(t, database.CreateExclusion(types.Exclusion{Path: "/spool", JobID: "db", Comment: "db"}))
+
+	// Leftovers that are not configs sort before the real files and must
+	// not be looked at.
+	dbConfig, err := os.ReadFile(filepath.Join(dir, utils.EncodeConfigName("db")))
+	require.NoError(t, err)
+	writeConfig(t, dir, "0-stale.cfg.tmp", string(dbConfig))
+	writeConfig(t, dir, "0-notes.txt", "exclusion: excl-*.log\n\tpath *.log\n\tcomment stray\n")
+
+	for path, comment := range map[string]string{"*.log": "global", "*.tmp": "web", "/cache": "db"} {
+		exclusion, err := database.GetExclusion(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, comment, exclusion.Comment, path)
+	}
+	_, err = database.GetExclusion("/missing")
+	assert.Error(t, err)
+
+	t.Run("delete job exclusion", func(t *testing.T) {
+		require.NoError(t, database.DeleteExclusion("/cache"))
+
+		exclusions, err := database.GetAllJobExclusions("db")
+		require.NoError(t, err)
+		require.Len(t, exclusions, 1)
+		assert.Equal(t, "/spool", exclusions[0].Path)
+
+		stale, err := os.ReadFile(filepath.Join(dir, "0-stale.cfg.tmp"))
+		require.NoError(t, err)
+		assert.Equal(t, dbConfig, stale, "non-config file is left alone")
+
+		_, err = database.GetExclusion("/cache")
+		assert.Error(t, err)
+	})
+
+	t.Run("delete global exclusion", func(t *testing.T) {
+		require.NoError(t, database.DeleteExclusion("*.log"))
+
+		_, err := os.Stat(filepath.Join(dir, globalExclusionFile))
+		assert.True(t, os.IsNotExist(err), "empty global file is removed")
+		_, err = database.GetExclusion("*.log")
+		assert.Error(t, err)
+		assert.Error(t, database.DeleteExclusion("*.log"), "deleted only once")
+
+		exclusions, err := database.GetAllJobExclusions("web")
+		require.NoError(t, err)
+		assert.Len(t, exclusions, 1)
+	})
+}
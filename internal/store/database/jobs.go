@@ -165,7 +165,7 @@ func (database *Database) GetJob(id string) (types.Job, error) {
 }
 
 func (database *Database) getJobTarget(id string) string {
-	jobPath := filepath.Join(database.paths["jobs"], utils.EncodePath(id)+".cfg")
+	jobPath := filepath.Join(database.paths["jobs"], utils.EncodeConfigName(id))
 	configData, err := database.jobsConfig.Parse(jobPath)
 	if err != nil {
 		return ""
@@ -181,7 +181,7 @@ func (database *Database) getJobTarget(id string) string {
 }
 
 func (database *Database) getJob(id string) (types.Job, error) {
-	jobPath := filepath.Join(database.paths["jobs"], utils.EncodePath(id)+".cfg")
+	jobPath := filepath.Join(database.paths["jobs"], utils.EncodeConfigName(id))
 	configData, err := database.jobsConfig.Parse(jobPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -195,9 +195,12 @@ func (database *Database) getJob(id string) (types.Job, error) {
 		return types.Job{}, fmt.Errorf("GetJob: section %s does not exist", id)
 	}
 
-	// Convert config to Job struct
+	// Convert config to Job struct. Jobs cannot be disabled in jobs.d, and
+	// their missing namespaces were always created.
 	job := section.Properties
 	job.ID = id
+	job.Enabled = true
+	job.CreateNamespace = true
 
 	// Get exclusions
 	exclusions, err := database.GetAllJobExclusions(id)
@@ -276,8 +279,7 @@ func (database *Database) UpdateJob(job types.Job) error {
 	}
 
 	// Update exclusions
-	exclusionFileName := utils.EncodePath(job.ID)
-	exclusionPath := filepath.Join(database.paths["exclusions"], exclusionFileName+".cfg")
+	exclusionPath := filepath.Join(database.paths["exclusions"], utils.EncodeConfigName(job.ID))
 	if err := os.RemoveAll(exclusionPath); err != nil {
 		return fmt.Errorf("UpdateJob: error removing old exclusions: %w", err)
 	}
@@ -331,7 +333,12 @@ func (database *Database) GetAllJobs() ([]types.Job, error) {
 			continue
 		}
 
-		job, err := database.getJob(utils.DecodePath(strings.TrimSuffix(file.Name(), ".cfg")))
+		id, ok := utils.DecodeConfigName(file.Name())
+		if !ok {
+			continue
+		}
+
+		job, err := database.getJob(id)
 		if err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
 				syslog.L.Error(err).WithField("id", job.ID).Write()
@@ -351,7 +358,7 @@ func (database *Database) GetAllJobs() ([]types.Job, error) {
 }
 
 func (database *Database) DeleteJob(id string) error {
-	jobPath := filepath.Join(database.paths["jobs"], utils.EncodePath(id)+".cfg")
+	jobPath := filepath.Join(database.paths["jobs"], utils.EncodeConfigName(id))
 	if err := os.Remove(jobPath); err != nil {
 		if !os.IsNotExist(err) {
 			return fmt.Errorf("DeleteJob: error deleting job file: %w", err)
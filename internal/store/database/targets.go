@@ -22,8 +22,8 @@ func (database *Database) RegisterTargetPlugin() {
 			if config.Path == "" {
 				return fmt.Errorf("target path empty")
 			}
-			if !utils.ValidateTargetPath(config.Path) {
-				return fmt.Errorf("invalid target path: %s", config.Path)
+			if err := utils.CheckTargetPath(config.Path); err != nil {
+				return fmt.Errorf("invalid target path: %w", err)
 			}
 			return nil
 		},
@@ -55,7 +55,7 @@ func (database *Database) CreateTarget(target types.Target) error {
 }
 
 func (database *Database) GetTarget(name string) (types.Target, error) {
-	targetPath := filepath.Join(database.paths["targets"], utils.EncodePath(name)+".cfg")
+	targetPath := filepath.Join(database.paths["targets"], utils.EncodeConfigName(name))
 	configData, err := database.targetsConfig.Parse(targetPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -72,7 +72,7 @@ func (database *Database) GetTarget(name string) (types.Target, error) {
 	target := section.Properties
 	target.Name = name
 
-	if strings.HasPrefix(target.Path, "agent://") {
+	if utils.IsAgentPath(target.Path) {
 		target.IsAgent = true
 	} else {
 		target.ConnectionStatus = utils.IsValid(target.Path)
@@ -102,7 +102,7 @@ func (database *Database) UpdateTarget(target types.Target) error {
 }
 
 func (database *Database) DeleteTarget(name string) error {
-	targetPath := filepath.Join(database.paths["targets"], utils.EncodePath(name)+".cfg")
+	targetPath := filepath.Join(database.paths["targets"], utils.EncodeConfigName(name))
 	if err := os.Remove(targetPath); err != nil {
 		if !os.IsNotExist(err) {
 			return fmt.Errorf("DeleteTarget: error deleting target file: %w", err)
@@ -124,7 +124,12 @@ func (database *Database) GetAllTargets() ([]types.Target, error) {
 			continue
 		}
 
-		target, err := database.GetTarget(utils.DecodePath(strings.TrimSuffix(file.Name(), ".cfg")))
+		id, ok := utils.DecodeConfigName(file.Name())
+		if !ok {
+			continue
+		}
+
+		target, err := database.GetTarget(id)
 		if err != nil {
 			syslog.L.Error(err).WithField("id", file.Name()).Write()
 			continue
@@ -147,19 +152,19 @@ func (database *Database) GetAllTargetsByIP(clientIP string) ([]types.Target, er
 			continue
 		}
 
-		target, err := database.GetTarget(utils.DecodePath(strings.TrimSuffix(file.Name(), ".cfg")))
+		id, ok := utils.DecodeConfigName(file.Name())
+		if !ok {
+			continue
+		}
+
+		target, err := database.GetTarget(id)
 		if err != nil {
 			syslog.L.Error(err).WithField("id", file.Name()).Write()
 			continue
 		}
 
-		// Check if it's an agent target and matches the clientIP
-		if target.IsAgent {
-			// Split path into parts: ["agent:", "", "<clientIP>", "<driveLetter>"]
-			parts := strings.Split(target.Path, "/")
-			if len(parts) >= 3 && parts[2] == clientIP {
-				targets = append(targets, target)
-			}
+		if agentTarget, err := utils.ParseAgentTarget(target.Path); err == nil && agentTarget.Host == clientIP {
+			targets = append(targets, target)
 		}
 	}
 
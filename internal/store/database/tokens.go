@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	configLib "github.com/sonroyaalmerol/pbs-plus/internal/config"
@@ -54,7 +53,7 @@ func (database *Database) CreateToken(comment string) error {
 }
 
 func (database *Database) GetToken(token string) (types.AgentToken, error) {
-	configPath := filepath.Join(database.paths["tokens"], utils.EncodePath(token)+".cfg")
+	configPath := filepath.Join(database.paths["tokens"], utils.EncodeConfigName(token))
 	configData, err := database.tokensConfig.Parse(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -93,7 +92,12 @@ func (database *Database) GetAllTokens() ([]types.AgentToken, error) {
 			continue
 		}
 
-		token, err := database.GetToken(utils.DecodePath(strings.TrimSuffix(file.Name(), ".cfg")))
+		id, ok := utils.DecodeConfigName(file.Name())
+		if !ok {
+			continue
+		}
+
+		token, err := database.GetToken(id)
 		if err != nil {
 			syslog.L.Error(err).WithField("id", file.Name())
 			continue
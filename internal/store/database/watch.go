@@ -0,0 +1,129 @@
+//go:build linux
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	configLib "github.com/sonroyaalmerol/pbs-plus/internal/config"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/system"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// jobWatchDebounce is how long edits to a job file must settle before its
+// schedule is applied, so an editor saving in several steps applies it once.
+var jobWatchDebounce = time.Second
+
+// ScheduleHooks apply the schedule of a job. The zero value uses the
+// systemd timers.
+type ScheduleHooks struct {
+	Set    func(job types.Job) error
+	Delete func(id string) error
+}
+
+// WatchJobSchedules keeps the timers of jobs in line with jobs.d when its
+// files are edited by hand: jobs whose schedule changed are rescheduled and
+// jobs whose section or file was removed are unscheduled. Files that do not
+// parse are logged and leave their timers alone.
+func (database *Database) WatchJobSchedules(ctx context.Context, hooks ScheduleHooks) error {
+	if hooks.Set == nil {
+		hooks.Set = system.SetSchedule
+	}
+	if hooks.Delete == nil {
+		hooks.Delete = system.DeleteSchedule
+	}
+
+	// Schedules last applied per job, per file.
+	known := make(map[string]map[string]string)
+	files, err := configFiles(database.paths["jobs"])
+	if err != nil {
+		return fmt.Errorf("WatchJobSchedules: error listing jobs: %w", err)
+	}
+	for _, file := range files {
+		data, err := database.jobsConfig.Parse(file)
+		if err != nil {
+			continue
+		}
+		known[file] = jobSchedules(data)
+	}
+
+	var mu sync.Mutex
+	return database.jobsConfig.Watch(ctx, jobWatchDebounce, func(data *configLib.ConfigData[types.Job], err error) {
+		if err != nil {
+			syslog.L.Error(err).WithMessage("ignoring invalid job config change").WithField("path", data.FilePath).Write()
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		previous := known[data.FilePath]
+		current := jobSchedules(data)
+		for id := range previous {
+			if _, ok := current[id]; ok {
+				continue
+			}
+			if err := hooks.Delete(id); err != nil {
+				syslog.L.Error(err).WithField("id", id).Write()
+			}
+		}
+		for id, schedule := range current {
+			if old, ok := previous[id]; ok && old == schedule {
+				continue
+			}
+			job := data.Sections[id].Properties
+			job.ID = id
+			job.Enabled = true
+			if err := hooks.Set(job); err != nil {
+				syslog.L.Error(err).WithField("id", id).Write()
+			}
+		}
+
+		if len(current) == 0 {
+			delete(known, data.FilePath)
+		} else {
+			known[data.FilePath] = current
+		}
+	})
+}
+
+func jobSchedules(data *configLib.ConfigData[types.Job]) map[string]string {
+	schedules := make(map[string]string, len(data.Sections))
+	for id, section := range data.Sections {
+		schedules[id] = section.Properties.Schedule
+	}
+	return schedules
+}
+
+// configFiles lists the config files of dir, sorted by name. A missing dir
+// has none.
+func configFiles(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".cfg") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
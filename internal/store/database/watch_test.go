@@ -0,0 +1,130 @@
+//go:build linux
+
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scheduleRecorder collects the calls of ScheduleHooks.
+type scheduleRecorder struct {
+	mu      sync.Mutex
+	set     []types.Job
+	deleted []string
+}
+
+func (r *scheduleRecorder) hooks() ScheduleHooks {
+	return ScheduleHooks{
+		Set: func(job types.Job) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.set = append(r.set, job)
+			return nil
+		},
+		Delete: func(id string) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.deleted = append(r.deleted, id)
+			return nil
+		},
+	}
+}
+
+func (r *scheduleRecorder) calls() ([]types.Job, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]types.Job(nil), r.set...), append([]string(nil), r.deleted...)
+}
+
+func TestWatchJobSchedules(t *testing.T) {
+	jobWatchDebounce = 50 * time.Millisecond
+	t.Cleanup(func() { jobWatchDebounce = time.Second })
+
+	dir := t.TempDir()
+	database := &Database{paths: map[string]string{"jobs": dir}}
+	database.RegisterJobPlugin()
+
+	nightly := writeConfig(t, dir, "nightly.cfg", "job: nightly\n\tstore local\n\ttarget server\n\tschedule daily\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var recorder scheduleRecorder
+	require.NoError(t, database.WatchJobSchedules(ctx, recorder.hooks()))
+
+	t.Run("schedule change", func(t *testing.T) {
+		// Several quick writes are applied once, with the last schedule.
+		for _, schedule := range []string{"hourly", "weekly", "Mon *-*-* 02:00"} {
+			require.NoError(t, os.WriteFile(nightly,
+				[]byte("job: nightly\n\tstore local\n\ttarget server\n\tschedule "+schedule+"\n"), 0640))
+		}
+
+		require.Eventually(t, func() bool {
+			set, _ := recorder.calls()
+			return len(set) > 0
+		}, 2*time.Second, 10*time.Millisecond)
+		time.Sleep(3 * jobWatchDebounce)
+
+		set, deleted := recorder.calls()
+		require.Len(t, set, 1)
+		assert.Equal(t, "nightly", set[0].ID)
+		assert.Equal(t, "Mon *-*-* 02:00", set[0].Schedule)
+		assert.Empty(t, deleted)
+	})
+
+	t.Run("unchanged schedule", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(nightly,
+			[]byte("job: nightly\n\tstore local\n\ttarget server\n\tschedule Mon *-*-* 02:00\n\tcomment edited\n"), 0640))
+		time.Sleep(4 * jobWatchDebounce)
+
+		set, _ := recorder.calls()
+		assert.Len(t, set, 1, "editing other properties does not reschedule")
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(nightly,
+			[]byte("job: nightly\n\ttarget server\n\tschedule daily\n"), 0640))
+		time.Sleep(4 * jobWatchDebounce)
+
+		set, deleted := recorder.calls()
+		assert.Len(t, set, 1)
+		assert.Empty(t, deleted)
+	})
+
+	t.Run("new and removed files", func(t *testing.T) {
+		writeConfig(t, dir, "weekly.cfg", "job: weekly\n\tstore local\n\ttarget server\n\tschedule weekly\n")
+		require.NoError(t, os.Remove(nightly))
+		// Temporary files of atomic writes are ignored.
+		writeConfig(t, dir, ".weekly.cfg.tmp-1", "garbage")
+
+		require.Eventually(t, func() bool {
+			set, deleted := recorder.calls()
+			return len(set) == 2 && len(deleted) == 1
+		}, 2*time.Second, 10*time.Millisecond)
+
+		set, deleted := recorder.calls()
+		assert.Equal(t, "weekly", set[1].ID)
+		assert.Equal(t, "weekly", set[1].Schedule)
+		assert.Equal(t, []string{"nightly"}, deleted)
+	})
+
+	t.Run("stops with context", func(t *testing.T) {
+		cancel()
+		time.Sleep(jobWatchDebounce)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "weekly.cfg"),
+			[]byte("job: weekly\n\tstore local\n\ttarget server\n\tschedule daily\n"), 0640))
+		time.Sleep(4 * jobWatchDebounce)
+
+		set, _ := recorder.calls()
+		assert.Len(t, set, 2)
+	})
+}
@@ -5,14 +5,18 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/sqlite"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -88,14 +92,33 @@ func TestJobCRUD(t *testing.T) {
 		assert.Equal(t, job.Store, retrievedJob.Store)
 		assert.Equal(t, job.Target, retrievedJob.Target)
 
+		assert.False(t, retrievedJob.CreateNamespace)
+		assert.Equal(t, 1.0, retrievedJob.RetryMultiplier)
+
 		// Test Update
 		job.Comment = "Updated comment"
+		job.CreateNamespace = true
+		job.EncryptionKeyFile = "/etc/proxmox-backup/pbs-plus/keys/test.key"
+		job.RetryMultiplier = 2.5
+		job.RetryMaxInterval = 120
+		job.PartialFiles = "*.vhdx\n/data/db.sqlite"
+		job.Inclusions = []types.Inclusion{
+			{Path: "/Users/*/Documents", JobID: job.ID},
+			{Path: `\.pst$`, JobID: job.ID, MatchType: "regex"},
+		}
 		err = store.Database.UpdateJob(nil, job)
 		assert.NoError(t, err)
 
 		updatedJob, err := store.Database.GetJob(job.ID)
 		assert.NoError(t, err)
 		assert.Equal(t, "Updated comment", updatedJob.Comment)
+		assert.True(t, updatedJob.CreateNamespace)
+		assert.Equal(t, job.EncryptionKeyFile, updatedJob.EncryptionKeyFile)
+		assert.Equal(t, 2.5, updatedJob.RetryMultiplier)
+		assert.Equal(t, 120, updatedJob.RetryMaxInterval)
+		assert.Equal(t, job.PartialFiles, updatedJob.PartialFiles)
+		assert.Len(t, updatedJob.Inclusions, 2)
+		assert.Equal(t, "/Users/*/Documents\nregex:\\.pst$", updatedJob.RawInclusions)
 
 		// Test GetAll
 		jobs, err := store.Database.GetAllJobs()
@@ -157,6 +180,67 @@ func TestJobCRUD(t *testing.T) {
 	})
 }
 
+func TestUpdateJobStatusConcurrentEdits(t *testing.T) {
+	store := setupTestStore(t)
+
+	require.NoError(t, store.Database.CreateJob(nil, types.Job{
+		ID:     "status-job",
+		Store:  "local",
+		Target: "test-target",
+	}))
+
+	const edits = 20
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < edits; i++ {
+			job, err := store.Database.GetJob("status-job")
+			if !assert.NoError(t, err) {
+				return
+			}
+			job.Exclusions = append(job.Exclusions, types.Exclusion{
+				JobID: job.ID,
+				Path:  fmt.Sprintf("/edit-%d", i),
+			})
+			assert.NoError(t, store.Database.UpdateJob(nil, job))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < edits; i++ {
+			assert.NoError(t, store.Database.UpdateJobStatus(nil, "status-job", types.JobStatus{
+				CurrentPID:  i,
+				LastRunUpid: fmt.Sprintf("upid-%d", i),
+			}))
+		}
+	}()
+	wg.Wait()
+
+	job, err := store.Database.GetJob("status-job")
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("upid-%d", edits-1), job.LastRunUpid)
+	assert.Equal(t, edits-1, job.CurrentPID)
+	assert.Len(t, job.Exclusions, edits)
+
+	// Fields left empty keep their stored value.
+	require.NoError(t, store.Database.UpdateJobStatus(nil, "status-job", types.JobStatus{
+		LastRunUpid:        "upid-ok",
+		LastSuccessfulUpid: "upid-ok",
+		LastRunFingerprint: "aa:bb",
+	}))
+	require.NoError(t, store.Database.UpdateJobStatus(nil, "status-job", types.JobStatus{
+		LastRunUpid: "upid-failed",
+	}))
+	job, err = store.Database.GetJob("status-job")
+	require.NoError(t, err)
+	assert.Equal(t, "upid-failed", job.LastRunUpid)
+	assert.Equal(t, "upid-ok", job.LastSuccessfulUpid)
+	assert.Equal(t, "aa:bb", job.LastRunFingerprint)
+
+	assert.Error(t, store.Database.UpdateJobStatus(nil, "missing", types.JobStatus{}))
+}
+
 func TestJobValidation(t *testing.T) {
 	store := setupTestStore(t)
 
@@ -177,6 +261,7 @@ func TestJobValidation(t *testing.T) {
 				Comment:          "Valid test job",
 				NotificationMode: "always",
 				Namespace:        "test",
+				WebhookURL:       "https://hooks.example.com/backup",
 			},
 			wantErr: false,
 		},
@@ -192,6 +277,17 @@ func TestJobValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "invalid schedule string",
 		},
+		{
+			name: "invalid webhook url",
+			job: types.Job{
+				ID:         "test-invalid-webhook",
+				Store:      "local",
+				Target:     "test",
+				WebhookURL: "ftp://hooks.example.com",
+			},
+			wantErr: true,
+			errMsg:  "invalid webhook url",
+		},
 		{
 			name: "empty required fields",
 			job: types.Job{
@@ -251,6 +347,41 @@ func TestTargetValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "invalid target path",
 		},
+		{
+			name: "valid linux agent target",
+			target: types.Target{
+				Name: "linux-agent-target",
+				Path: "agent://192.168.1.100//mnt/data",
+			},
+			wantErr: false,
+		},
+		{
+			name: "agent host is not an IP",
+			target: types.Target{
+				Name: "agent-hostname",
+				Path: "agent://server/C",
+			},
+			wantErr: true,
+			errMsg:  "not an IP address",
+		},
+		{
+			name: "agent drive is not a letter",
+			target: types.Target{
+				Name: "agent-bad-drive",
+				Path: "agent://192.168.1.100/CD",
+			},
+			wantErr: true,
+			errMsg:  "not a drive letter",
+		},
+		{
+			name: "agent target with subpath",
+			target: types.Target{
+				Name: "agent-subpath",
+				Path: "agent://192.168.1.100/C/Users",
+			},
+			wantErr: true,
+			errMsg:  "invalid target path",
+		},
 		{
 			name: "empty path",
 			target: types.Target{
@@ -277,6 +408,217 @@ func TestTargetValidation(t *testing.T) {
 	}
 }
 
+func TestTargetsByIP(t *testing.T) {
+	store := setupTestStore(t)
+
+	for name, path := range map[string]string{
+		"pc1 - C":   "agent://10.0.0.1/C",
+		"pc1 - D":   "agent://10.0.0.1/D",
+		"pc10 - C":  "agent://10.0.0.10/C",
+		"srv - /":   "agent://10.0.0.1//",
+		"local-one": "/mnt/10.0.0.1",
+	} {
+		require.NoError(t, store.Database.CreateTarget(nil, types.Target{Name: name, Path: path}))
+	}
+
+	targets, err := store.Database.GetAllTargetsByIP("10.0.0.1")
+	require.NoError(t, err)
+	var names []string
+	for _, target := range targets {
+		assert.True(t, target.IsAgent)
+		names = append(names, target.Name)
+	}
+	assert.ElementsMatch(t, []string{"pc1 - C", "pc1 - D", "srv - /"}, names, "a host sharing the prefix is left out")
+}
+
+func TestTargetConnectionPolicy(t *testing.T) {
+	store := setupTestStore(t)
+
+	target := types.Target{
+		Name:           "laptop - C",
+		Path:           "agent://192.168.1.100/C",
+		ConnectTimeout: 10,
+		MaxRetries:     3,
+	}
+	require.NoError(t, store.Database.CreateTarget(nil, target))
+
+	got, err := store.Database.GetTarget(target.Name)
+	require.NoError(t, err)
+	assert.Equal(t, 10, got.ConnectTimeout)
+	assert.Equal(t, 3, got.MaxRetries)
+
+	// An agent re-registering its drives does not know the policy and must
+	// not reset it.
+	require.NoError(t, store.Database.CreateTarget(nil, types.Target{
+		Name:      target.Name,
+		Path:      target.Path,
+		DriveType: "Fixed",
+	}))
+	got, err = store.Database.GetTarget(target.Name)
+	require.NoError(t, err)
+	assert.Equal(t, "Fixed", got.DriveType)
+	assert.Equal(t, 10, got.ConnectTimeout)
+	assert.Equal(t, 3, got.MaxRetries)
+
+	got.ConnectTimeout = 0
+	got.MaxRetries = -1
+	require.NoError(t, store.Database.UpdateTarget(nil, got))
+	got, err = store.Database.GetTarget(target.Name)
+	require.NoError(t, err)
+	assert.Equal(t, 0, got.ConnectTimeout)
+	assert.Equal(t, 0, got.MaxRetries)
+}
+
+func TestTargetConcurrencyLimit(t *testing.T) {
+	store := setupTestStore(t)
+
+	target := types.Target{
+		Name:           "laptop - C",
+		Path:           "agent://192.168.1.100/C",
+		MaxConcurrency: 2,
+		FailWhenBusy:   true,
+	}
+	require.NoError(t, store.Database.CreateTarget(nil, target))
+
+	got, err := store.Database.GetTarget(target.Name)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.MaxConcurrency)
+	assert.True(t, got.FailWhenBusy)
+
+	// Re-registering drives keeps the limit.
+	require.NoError(t, store.Database.CreateTarget(nil, types.Target{
+		Name: target.Name,
+		Path: target.Path,
+	}))
+	got, err = store.Database.GetTarget(target.Name)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.MaxConcurrency)
+	assert.True(t, got.FailWhenBusy)
+
+	got.MaxConcurrency = -1
+	got.FailWhenBusy = false
+	require.NoError(t, store.Database.UpdateTarget(nil, got))
+	got, err = store.Database.GetTarget(target.Name)
+	require.NoError(t, err)
+	assert.Equal(t, 0, got.MaxConcurrency)
+	assert.False(t, got.FailWhenBusy)
+}
+
+func TestJobsByTarget(t *testing.T) {
+	store := setupTestStore(t)
+
+	for _, name := range []string{"target-a", "target-b"} {
+		require.NoError(t, store.Database.CreateTarget(nil, types.Target{
+			Name: name,
+			Path: "/mnt/" + name,
+		}))
+	}
+	for _, job := range []types.Job{
+		{ID: "job-a1", Store: "local", Target: "target-a"},
+		{ID: "job-a2", Store: "local", Target: "target-a"},
+		{ID: "job-b1", Store: "local", Target: "target-b"},
+	} {
+		require.NoError(t, store.Database.CreateJob(nil, job))
+	}
+
+	jobs, err := store.Database.GetJobsByTarget("target-a")
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "job-a1", jobs[0].ID)
+	assert.Equal(t, "job-a2", jobs[1].ID)
+
+	jobs, err = store.Database.GetJobsByTarget("missing")
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+
+	err = store.Database.DeleteTarget(nil, "target-a")
+	require.ErrorIs(t, err, sqlite.ErrTargetInUse)
+	assert.Contains(t, err.Error(), "job-a1")
+	_, err = store.Database.GetTarget("target-a")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Database.DeleteJob(nil, "job-a1"))
+	require.NoError(t, store.Database.DeleteJob(nil, "job-a2"))
+	require.NoError(t, store.Database.DeleteTarget(nil, "target-a"))
+	_, err = store.Database.GetTarget("target-a")
+	assert.Error(t, err)
+}
+
+func TestJobsByTag(t *testing.T) {
+	store := setupTestStore(t)
+
+	for _, job := range []types.Job{
+		{ID: "job-web", Store: "local", Target: "target", Tags: []string{"prod", "web"}},
+		{ID: "job-db", Store: "local", Target: "target", Tags: []string{"prod", "db_main"}},
+		{ID: "job-dev", Store: "local", Target: "target", Tags: []string{"dev", "web"}},
+		{ID: "job-none", Store: "local", Target: "target"},
+	} {
+		require.NoError(t, store.Database.CreateJob(nil, job))
+	}
+
+	jobIDs := func(tag string) []string {
+		jobs, err := store.Database.GetJobsByTag(tag)
+		require.NoError(t, err)
+		var ids []string
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+
+	assert.ElementsMatch(t, []string{"job-web", "job-db"}, jobIDs("prod"))
+	assert.ElementsMatch(t, []string{"job-web", "job-dev"}, jobIDs("web"))
+	assert.ElementsMatch(t, []string{"job-db"}, jobIDs("db_main"))
+	assert.Empty(t, jobIDs("dbxmain"), "tags are not matched as patterns")
+	assert.Empty(t, jobIDs("pro"), "tags are matched whole")
+
+	job, err := store.Database.GetJob("job-db")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod", "db_main"}, job.Tags)
+
+	job.Tags = []string{"dev"}
+	require.NoError(t, store.Database.UpdateJob(nil, job))
+	assert.ElementsMatch(t, []string{"job-web"}, jobIDs("prod"))
+	assert.ElementsMatch(t, []string{"job-dev", "job-db"}, jobIDs("dev"))
+
+	for _, tag := range []string{"", "has space", "a,b", "-lead", strings.Repeat("x", 65)} {
+		err := store.Database.CreateJob(nil, types.Job{ID: "job-bad", Store: "local", Target: "target", Tags: []string{tag}})
+		assert.Error(t, err, "tag %q", tag)
+	}
+	job.Tags = []string{"bad tag"}
+	assert.Error(t, store.Database.UpdateJob(nil, job))
+}
+
+func TestJobEnabled(t *testing.T) {
+	store := setupTestStore(t)
+
+	require.NoError(t, store.Database.CreateJob(nil, types.Job{ID: "job-on", Store: "local", Target: "target", Enabled: true}))
+	require.NoError(t, store.Database.CreateJob(nil, types.Job{ID: "job-off", Store: "local", Target: "target", Schedule: "daily"}))
+
+	job, err := store.Database.GetJob("job-on")
+	require.NoError(t, err)
+	assert.True(t, job.Enabled)
+
+	job, err = store.Database.GetJob("job-off")
+	require.NoError(t, err)
+	assert.False(t, job.Enabled)
+	assert.Equal(t, "daily", job.Schedule, "the schedule is kept for when the job is enabled again")
+
+	job.Enabled = true
+	require.NoError(t, store.Database.UpdateJob(nil, job))
+	job, err = store.Database.GetJob("job-off")
+	require.NoError(t, err)
+	assert.True(t, job.Enabled)
+
+	// Documents written before the flag existed describe enabled jobs.
+	var decoded types.Job
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"old","store":"local","target":"target"}`), &decoded))
+	assert.True(t, decoded.Enabled)
+	assert.True(t, decoded.CreateNamespace, "missing namespaces were always created")
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"old","enabled":false}`), &decoded))
+	assert.False(t, decoded.Enabled)
+}
+
 func TestExclusionPatternValidation(t *testing.T) {
 	store := setupTestStore(t)
 
@@ -331,6 +673,125 @@ func TestExclusionPatternValidation(t *testing.T) {
 	}
 }
 
+func TestJobWithInvalidExclusionRollsBack(t *testing.T) {
+	store := setupTestStore(t)
+
+	job := types.Job{
+		ID:         "invalid-exclusion-job",
+		Store:      "local",
+		Target:     "test-target",
+		Exclusions: []types.Exclusion{{Path: "[invalid[pattern"}},
+	}
+	assert.Error(t, store.Database.CreateJob(nil, job))
+	_, err := store.Database.GetJob(job.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "the job is not created")
+
+	job.Exclusions = []types.Exclusion{{Path: "*.tmp", JobID: job.ID}}
+	require.NoError(t, store.Database.CreateJob(nil, job))
+
+	job.Comment = "updated"
+	job.Exclusions = []types.Exclusion{{Path: "[invalid[pattern", JobID: job.ID}}
+	assert.Error(t, store.Database.UpdateJob(nil, job))
+
+	retrieved, err := store.Database.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, retrieved.Comment, "the update is rolled back")
+	require.Len(t, retrieved.Exclusions, 1)
+	assert.Equal(t, "*.tmp", retrieved.Exclusions[0].Path)
+}
+
+func TestExclusionMatchTypes(t *testing.T) {
+	store := setupTestStore(t)
+
+	tests := []struct {
+		name      string
+		exclusion types.Exclusion
+		wantType  string
+		wantErr   bool
+	}{
+		{
+			name:      "missing match type defaults to glob",
+			exclusion: types.Exclusion{Path: "*.cache"},
+			wantType:  pattern.MatchGlob,
+		},
+		{
+			name:      "glob",
+			exclusion: types.Exclusion{Path: "/srv/**/tmp", MatchType: "glob"},
+			wantType:  pattern.MatchGlob,
+		},
+		{
+			name:      "regex keeps its backslashes",
+			exclusion: types.Exclusion{Path: `^/var/log/.*\.gz$`, MatchType: "regex"},
+			wantType:  pattern.MatchRegex,
+		},
+		{
+			name:      "literal with glob metacharacters",
+			exclusion: types.Exclusion{Path: "/data/[draft] *.txt", MatchType: "literal"},
+			wantType:  pattern.MatchLiteral,
+		},
+		{
+			name:      "invalid regex",
+			exclusion: types.Exclusion{Path: `(unclosed`, MatchType: "regex"},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown match type",
+			exclusion: types.Exclusion{Path: "*.iso", MatchType: "fuzzy"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.Database.CreateExclusion(nil, tt.exclusion)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := store.Database.GetExclusion(tt.exclusion.Path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, got.MatchType)
+		})
+	}
+}
+
+func TestExclusionCaseSensitivity(t *testing.T) {
+	store := setupTestStore(t)
+	sensitive, insensitive := true, false
+
+	require.NoError(t, store.Database.CreateExclusion(nil, types.Exclusion{Path: `C:\Users\\Build\`, CaseSensitive: &sensitive}))
+	require.NoError(t, store.Database.CreateExclusion(nil, types.Exclusion{Path: "/Temp"}))
+
+	got, err := store.Database.GetExclusion("C:/Users/Build/")
+	require.NoError(t, err, "pattern is stored normalized")
+	require.NotNil(t, got.CaseSensitive)
+	assert.True(t, *got.CaseSensitive)
+	assert.Error(t, store.Database.CreateExclusion(nil, types.Exclusion{Path: "C:/Users//Build/"}),
+		"normalized forms are the same exclusion")
+
+	got, err = store.Database.GetExclusion("/Temp")
+	require.NoError(t, err)
+	assert.Nil(t, got.CaseSensitive, "unset flag follows the target")
+
+	got.CaseSensitive = &insensitive
+	require.NoError(t, store.Database.UpdateExclusion(nil, *got))
+
+	// The default global exclusions come first.
+	filters := store.EffectiveFilters(types.Job{}, false)
+	require.GreaterOrEqual(t, len(filters.Rules), 2)
+	rules := filters.Rules[len(filters.Rules)-2:]
+	assert.False(t, rules[0].CaseInsensitive)
+	assert.Equal(t, "**/C:/Users/Build/", rules[0].Arg)
+	assert.True(t, rules[1].CaseInsensitive)
+	assert.Equal(t, "/[tT][eE][mM][pP]", rules[1].Arg)
+
+	require.NoError(t, store.Database.DeleteExclusion(nil, `C:\Users\Build\`))
+	_, err = store.Database.GetExclusion("C:/Users/Build/")
+	assert.Error(t, err)
+}
+
 func TestConcurrentOperations(t *testing.T) {
 	store := setupTestStore(t)
 	var wg sync.WaitGroup
@@ -410,3 +871,151 @@ func TestConcurrentOperations(t *testing.T) {
 		}
 	})
 }
+
+func TestJobTemplateInheritance(t *testing.T) {
+	store := setupTestStore(t)
+
+	template := types.JobTemplate{
+		ID:               "nightly",
+		Store:            "local",
+		Schedule:         `*-*-* 02:00:00`,
+		Comment:          "Nightly template",
+		NotificationMode: "always",
+		Namespace:        "templated",
+		Retry:            3,
+		RetryInterval:    5,
+	}
+
+	t.Run("Template CRUD", func(t *testing.T) {
+		err := store.Database.CreateJobTemplate(nil, template)
+		require.NoError(t, err)
+
+		retrieved, err := store.Database.GetJobTemplate(template.ID)
+		require.NoError(t, err)
+		assert.Equal(t, template, retrieved)
+
+		retrieved.Comment = "Updated template"
+		err = store.Database.UpdateJobTemplate(nil, retrieved)
+		require.NoError(t, err)
+
+		updated, err := store.Database.GetJobTemplate(template.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Updated template", updated.Comment)
+
+		err = store.Database.CreateJobTemplate(nil, types.JobTemplate{
+			ID:       "invalid-schedule",
+			Schedule: "invalid-cron",
+		})
+		assert.Error(t, err)
+
+		all, err := store.Database.GetAllJobTemplates()
+		require.NoError(t, err)
+		assert.Len(t, all, 1)
+	})
+
+	t.Run("Inherit And Override", func(t *testing.T) {
+		job := types.Job{
+			ID:       "templated-job",
+			Target:   "test-target",
+			Template: template.ID,
+			Comment:  "Own comment",
+		}
+		err := store.Database.ApplyJobTemplate(&job, map[string]bool{types.JobSettingComment: true})
+		require.NoError(t, err)
+		err = store.Database.CreateJob(nil, job)
+		require.NoError(t, err)
+
+		retrieved, err := store.Database.GetJob(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, template.Store, retrieved.Store)
+		assert.Equal(t, template.Schedule, retrieved.Schedule)
+		assert.Equal(t, template.Namespace, retrieved.Namespace)
+		assert.Equal(t, template.Retry, retrieved.Retry)
+		assert.Equal(t, template.RetryInterval, retrieved.RetryInterval)
+		assert.Equal(t, "Own comment", retrieved.Comment)
+		assert.Equal(t, template.ID, retrieved.Template, "the template is recorded with the job")
+
+		require.NoError(t, store.Database.UpdateJob(nil, retrieved))
+		retrieved, err = store.Database.GetJob(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, template.ID, retrieved.Template, "updates keep the template")
+	})
+
+	t.Run("Values Are Copied At Creation", func(t *testing.T) {
+		tmpl, err := store.Database.GetJobTemplate(template.ID)
+		require.NoError(t, err)
+		tmpl.Namespace = "changed"
+		require.NoError(t, store.Database.UpdateJobTemplate(nil, tmpl))
+
+		retrieved, err := store.Database.GetJob("templated-job")
+		require.NoError(t, err)
+		assert.Equal(t, template.Namespace, retrieved.Namespace)
+	})
+
+	t.Run("Given Zero Values Override", func(t *testing.T) {
+		job := types.Job{
+			ID:       "zero-override-job",
+			Target:   "test-target",
+			Template: template.ID,
+		}
+		err := store.Database.ApplyJobTemplate(&job, map[string]bool{
+			types.JobSettingComment:       true,
+			types.JobSettingNamespace:     true,
+			types.JobSettingRetry:         true,
+			types.JobSettingRetryInterval: true,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, template.Store, job.Store)
+		assert.Empty(t, job.Comment)
+		assert.Empty(t, job.Namespace)
+		assert.Zero(t, job.Retry)
+		assert.Zero(t, job.RetryInterval)
+	})
+
+	t.Run("Missing Template", func(t *testing.T) {
+		job := types.Job{
+			ID:       "missing-template-job",
+			Target:   "test-target",
+			Template: "does-not-exist",
+		}
+		err := store.Database.ApplyJobTemplate(&job, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Default Template", func(t *testing.T) {
+		err := store.Database.CreateJobTemplate(nil, types.JobTemplate{
+			ID:       "default",
+			Store:    "default-store",
+			Schedule: `*-*-* 03:00:00`,
+		})
+		require.NoError(t, err)
+
+		job := types.Job{
+			ID:     "default-template-job",
+			Target: "test-target",
+		}
+		require.NoError(t, store.Database.ApplyJobTemplate(&job, nil))
+		err = store.Database.CreateJob(nil, job)
+		require.NoError(t, err)
+
+		retrieved, err := store.Database.GetJob("default-template-job")
+		require.NoError(t, err)
+		assert.Equal(t, "default-store", retrieved.Store)
+		assert.Equal(t, `*-*-* 03:00:00`, retrieved.Schedule)
+		assert.Equal(t, "default", retrieved.Template)
+
+		manual := types.Job{
+			ID:     "manual-job",
+			Target: "test-target",
+		}
+		err = store.Database.ApplyJobTemplate(&manual, map[string]bool{types.JobSettingSchedule: true})
+		require.NoError(t, err)
+		assert.Empty(t, manual.Schedule, "a job that submits no schedule stays manual")
+		assert.Equal(t, "default-store", manual.Store)
+
+		require.NoError(t, store.Database.DeleteJobTemplate(nil, "default"))
+		_, err = store.Database.GetJobTemplate("default")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
@@ -0,0 +1,36 @@
+//go:build linux
+
+package store
+
+import (
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+)
+
+// EffectiveFilters resolves the job and global exclusions into the ordered
+// rule set of a backup. See pattern.ResolveFilters for the precedence order.
+func (s *Store) EffectiveFilters(job types.Job, caseInsensitive bool) pattern.EffectiveFilters {
+	var jobExclusions []pattern.Pattern
+	for _, exclusion := range job.Exclusions {
+		jobExclusions = append(jobExclusions, pattern.Pattern{Value: exclusion.Path, MatchType: exclusion.MatchType, CaseSensitive: exclusion.CaseSensitive})
+	}
+
+	var globalExclusions []pattern.Pattern
+	if exclusions, err := s.Database.GetAllGlobalExclusions(); err == nil {
+		for _, exclusion := range exclusions {
+			globalExclusions = append(globalExclusions, pattern.Pattern{Value: exclusion.Path, MatchType: exclusion.MatchType, CaseSensitive: exclusion.CaseSensitive})
+		}
+	}
+
+	return pattern.ResolvePatterns(globalExclusions, jobExclusions, caseInsensitive)
+}
+
+// InclusionMatcher compiles the inclusions of job. It returns nil when the
+// job has none and backs up its whole source.
+func (s *Store) InclusionMatcher(job types.Job, caseInsensitive bool) (*pattern.InclusionMatcher, error) {
+	var inclusions []pattern.Pattern
+	for _, inclusion := range job.Inclusions {
+		inclusions = append(inclusions, pattern.Pattern{Value: inclusion.Path, MatchType: inclusion.MatchType})
+	}
+	return pattern.NewInclusionMatcher(inclusions, caseInsensitive)
+}
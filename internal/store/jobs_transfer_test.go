@@ -0,0 +1,126 @@
+//go:build linux
+
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/sqlite"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTransferStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Initialize(t.Context(), map[string]string{"sqlite": filepath.Join(t.TempDir(), "plus.db")})
+	require.NoError(t, err)
+	return store
+}
+
+func TestJobExportImport(t *testing.T) {
+	source := newTransferStore(t)
+
+	jobs := []types.Job{
+		{
+			ID:               "nightly",
+			Store:            "local",
+			Target:           "pve01 - C",
+			Subpath:          "Users",
+			Schedule:         "daily",
+			Namespace:        "pve01",
+			Retry:            3,
+			RetryMultiplier:  2,
+			RetryMaxInterval: 60,
+			WebhookURL:       "https://hooks.example.com/backup",
+			PartialFiles:     "*.vhdx",
+			Tags:             []string{"prod", "windows"},
+			Template:         "workstations",
+			Exclusions: []types.Exclusion{
+				{Path: "*.transfer-tmp", JobID: "nightly", Comment: "scratch", MatchType: "glob"},
+				{Path: `\.bak$`, JobID: "nightly", MatchType: "regex"},
+			},
+			Inclusions: []types.Inclusion{{Path: "/Users/*/Documents", JobID: "nightly"}},
+		},
+		{ID: "weekly", Store: "remote", Target: "nas", Schedule: "weekly"},
+	}
+	for _, job := range jobs {
+		require.NoError(t, source.Database.CreateJob(nil, job))
+	}
+	require.NoError(t, source.Database.UpdateJobStatus(nil, "nightly", types.JobStatus{LastRunUpid: "UPID:pbs:1"}))
+
+	exported, err := source.Database.ExportJobs()
+	require.NoError(t, err)
+	require.Len(t, exported, 2)
+	for _, job := range exported {
+		assert.Empty(t, job.LastRunUpid, "runtime state is not exported")
+	}
+
+	t.Run("into a fresh store", func(t *testing.T) {
+		target := newTransferStore(t)
+
+		result, err := target.Database.ImportJobs(exported, false)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"nightly", "weekly"}, result.Created)
+		assert.Empty(t, result.Replaced)
+
+		reexported, err := target.Database.ExportJobs()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, exported, reexported)
+
+		nightly, err := target.Database.GetJob("nightly")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, jobs[0].Exclusions, nightly.Exclusions)
+		assert.Equal(t, jobs[0].Tags, nightly.Tags)
+		assert.Equal(t, "workstations", nightly.Template)
+		assert.Equal(t, "/Users/*/Documents", nightly.RawInclusions)
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		target := newTransferStore(t)
+		require.NoError(t, target.Database.CreateJob(nil, types.Job{ID: "weekly", Store: "other", Target: "nas"}))
+
+		_, err := target.Database.ImportJobs(exported, false)
+		var importErr *sqlite.JobImportError
+		require.ErrorAs(t, err, &importErr)
+		assert.Equal(t, []string{"weekly"}, importErr.Conflicts)
+
+		all, err := target.Database.GetAllJobs()
+		require.NoError(t, err)
+		require.Len(t, all, 1, "nothing is imported")
+		assert.Equal(t, "other", all[0].Store)
+
+		result, err := target.Database.ImportJobs(exported, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"nightly"}, result.Created)
+		assert.Equal(t, []string{"weekly"}, result.Replaced)
+
+		weekly, err := target.Database.GetJob("weekly")
+		require.NoError(t, err)
+		assert.Equal(t, "remote", weekly.Store)
+	})
+
+	t.Run("invalid jobs", func(t *testing.T) {
+		target := newTransferStore(t)
+
+		invalid := append([]types.Job(nil), exported...)
+		invalid = append(invalid,
+			types.Job{ID: "broken", Store: "local", Target: "nas", Schedule: "whenever"},
+			types.Job{ID: "stealing", Store: "local", Target: "nas", Exclusions: []types.Exclusion{{Path: "*.transfer-tmp"}}},
+			types.Job{ID: "keyless", Store: "local", Target: "nas", EncryptionKeyFile: filepath.Join(t.TempDir(), "missing.key")},
+		)
+
+		_, err := target.Database.ImportJobs(invalid, false)
+		var importErr *sqlite.JobImportError
+		require.ErrorAs(t, err, &importErr)
+		assert.Contains(t, importErr.Invalid["broken"], "invalid schedule")
+		assert.Contains(t, importErr.Invalid["stealing"], "also listed by job nightly")
+		assert.Equal(t, utils.ErrEncryptionKeyMissing.Error(), importErr.Invalid["keyless"])
+
+		all, err := target.Database.GetAllJobs()
+		require.NoError(t, err)
+		assert.Empty(t, all, "nothing is imported")
+	})
+}
@@ -0,0 +1,278 @@
+//go:build linux
+
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+)
+
+// migrationManifestVersion is the version of the legacy data migration. A
+// manifest of another version does not vouch for any step.
+const migrationManifestVersion = 1
+
+// Steps of the legacy data migration, in the order they run.
+const (
+	migrationStepJobs       = "jobs"
+	migrationStepExclusions = "exclusions"
+	migrationStepTargets    = "targets"
+	migrationStepVerify     = "verify"
+	migrationStepCleanup    = "cleanup"
+)
+
+// migrationManifest records the steps of the legacy data migration that
+// completed, so that an interrupted migration resumes where it stopped.
+type migrationManifest struct {
+	Version   int      `json:"version"`
+	Completed []string `json:"completed"`
+}
+
+func (m *migrationManifest) done(step string) bool {
+	return m.Version == migrationManifestVersion && slices.Contains(m.Completed, step)
+}
+
+// MigrationReport tells what a run of MigrateLegacyData did. Entries found
+// already migrated, by a previous interrupted run, are counted as skipped.
+type MigrationReport struct {
+	Steps      []string `json:"steps"`
+	Jobs       int      `json:"jobs"`
+	Exclusions int      `json:"exclusions"`
+	Targets    int      `json:"targets"`
+	Skipped    int      `json:"skipped"`
+}
+
+func (r MigrationReport) String() string {
+	if len(r.Steps) == 0 {
+		return "no legacy data to migrate"
+	}
+	return fmt.Sprintf("ran steps %v: migrated %d jobs, %d exclusions and %d targets, skipped %d already migrated",
+		r.Steps, r.Jobs, r.Exclusions, r.Targets, r.Skipped)
+}
+
+func (s *Store) loadMigrationManifest() (migrationManifest, error) {
+	var manifest migrationManifest
+
+	data, err := os.ReadFile(s.migrationManifest)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return migrationManifest{Version: migrationManifestVersion}, nil
+		}
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("error parsing %s: %w", s.migrationManifest, err)
+	}
+	if manifest.Version != migrationManifestVersion {
+		return migrationManifest{Version: migrationManifestVersion}, nil
+	}
+	return manifest, nil
+}
+
+// saveMigrationManifest writes the manifest through a temporary file, so an
+// interruption never leaves a truncated manifest behind.
+func (s *Store) saveMigrationManifest(manifest migrationManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.migrationManifest), 0750); err != nil {
+		return err
+	}
+	tmp := s.migrationManifest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.migrationManifest)
+}
+
+// MigrateLegacyData moves the jobs, exclusions and targets of the legacy
+// config directories to sqlite and removes the directories. Every step is
+// recorded in the migration manifest once done, so running it again is a
+// no-op and an interrupted run resumes with the step it did not finish.
+func (s *Store) MigrateLegacyData() (MigrationReport, error) {
+	var report MigrationReport
+	if s.LegacyDatabase == nil {
+		return report, nil
+	}
+
+	manifest, err := s.loadMigrationManifest()
+	if err != nil {
+		return report, fmt.Errorf("MigrateLegacyData: error reading migration manifest: %w", err)
+	}
+	if manifest.done(migrationStepCleanup) {
+		return report, nil
+	}
+
+	syslog.L.Info().WithMessage("Legacy database format detected, attempting to migrate automatically...").Write()
+
+	steps := []struct {
+		name string
+		run  func(*MigrationReport) error
+	}{
+		{migrationStepJobs, s.migrateLegacyJobs},
+		{migrationStepExclusions, s.migrateLegacyExclusions},
+		{migrationStepTargets, s.migrateLegacyTargets},
+		{migrationStepVerify, s.verifyLegacyMigration},
+		{migrationStepCleanup, s.removeLegacyData},
+	}
+	for _, step := range steps {
+		if manifest.done(step.name) {
+			continue
+		}
+		if err := step.run(&report); err != nil {
+			return report, fmt.Errorf("MigrateLegacyData: %s: %w", step.name, err)
+		}
+
+		report.Steps = append(report.Steps, step.name)
+		manifest.Completed = append(manifest.Completed, step.name)
+		if err := s.saveMigrationManifest(manifest); err != nil {
+			return report, fmt.Errorf("MigrateLegacyData: error saving migration manifest: %w", err)
+		}
+	}
+
+	syslog.L.Info().WithMessage("PBS Plus has successfully migrated your legacy database to the newer model. Legacy databases has been deleted: /etc/proxmox-backup/pbs-plus/[jobs.d, targets.d, exclusions.d, tokens.d]").Write()
+
+	return report, nil
+}
+
+func (s *Store) migrateLegacyJobs(report *MigrationReport) error {
+	syslog.L.Info().WithMessage("Migrating legacy jobs...").Write()
+
+	legacyJobs, err := s.LegacyDatabase.GetAllJobs()
+	if err != nil {
+		return fmt.Errorf("error retrieving legacy jobs: %w", err)
+	}
+
+	tx, err := s.Database.NewTransaction()
+	if err != nil {
+		return fmt.Errorf("error creating transaction: %w", err)
+	}
+	for _, job := range legacyJobs {
+		if _, err := s.Database.GetJob(job.ID); err == nil {
+			report.Skipped++
+			continue
+		}
+		if err := s.Database.CreateJob(tx, job); err != nil {
+			syslog.L.Error(err).WithField("job", job.ID).Write()
+			continue
+		}
+		report.Jobs++
+	}
+	return tx.Commit()
+}
+
+func (s *Store) migrateLegacyExclusions(report *MigrationReport) error {
+	syslog.L.Info().WithMessage("Migrating legacy exclusions...").Write()
+
+	defaultExclusionsMap := make(map[string]struct{})
+	for _, defaultExc := range constants.DefaultExclusions {
+		defaultExclusionsMap[defaultExc] = struct{}{}
+	}
+
+	legacyGlobals, err := s.LegacyDatabase.GetAllGlobalExclusions()
+	if err != nil {
+		return fmt.Errorf("error retrieving legacy global exclusions: %w", err)
+	}
+
+	tx, err := s.Database.NewTransaction()
+	if err != nil {
+		return fmt.Errorf("error creating transaction: %w", err)
+	}
+	for _, excl := range legacyGlobals {
+		if _, ok := defaultExclusionsMap[excl.Path]; ok {
+			continue
+		}
+		if existing, err := s.Database.GetExclusion(excl.Path); err == nil && existing.JobID == "" {
+			report.Skipped++
+			continue
+		}
+		if err := s.Database.CreateExclusion(tx, excl); err != nil {
+			syslog.L.Error(err).WithField("exclusion", excl.Path).Write()
+			continue
+		}
+		report.Exclusions++
+	}
+	return tx.Commit()
+}
+
+func (s *Store) migrateLegacyTargets(report *MigrationReport) error {
+	syslog.L.Info().WithMessage("Migrating legacy targets...").Write()
+
+	legacyTargets, err := s.LegacyDatabase.GetAllTargets()
+	if err != nil {
+		return fmt.Errorf("error retrieving legacy targets: %w", err)
+	}
+
+	tx, err := s.Database.NewTransaction()
+	if err != nil {
+		return fmt.Errorf("error creating transaction: %w", err)
+	}
+	for _, target := range legacyTargets {
+		if _, err := s.Database.GetTarget(target.Name); err == nil {
+			report.Skipped++
+			continue
+		}
+		if err := s.Database.CreateTarget(tx, target); err != nil {
+			syslog.L.Error(err).WithField("target", target.Name).Write()
+			continue
+		}
+		report.Targets++
+	}
+	return tx.Commit()
+}
+
+func (s *Store) verifyLegacyMigration(_ *MigrationReport) error {
+	syslog.L.Info().WithMessage("Verifying jobs migration...").Write()
+	legacyJobs, err := s.LegacyDatabase.GetAllJobs()
+	if err != nil {
+		return fmt.Errorf("error retrieving legacy jobs: %w", err)
+	}
+	newJobs, err := s.Database.GetAllJobs()
+	if err != nil {
+		return fmt.Errorf("error retrieving new jobs: %w", err)
+	}
+	if len(legacyJobs) != len(newJobs) {
+		return fmt.Errorf("legacyJobs != newJobs: %d != %d", len(legacyJobs), len(newJobs))
+	}
+
+	syslog.L.Info().WithMessage("Verifying exclusions migration...").Write()
+	legacyGlobals, err := s.LegacyDatabase.GetAllGlobalExclusions()
+	if err != nil {
+		return fmt.Errorf("error retrieving legacy global exclusions: %w", err)
+	}
+	newGlobals, err := s.Database.GetAllGlobalExclusions()
+	if err != nil {
+		return fmt.Errorf("error retrieving new globals: %w", err)
+	}
+	if len(legacyGlobals) != len(newGlobals) {
+		return fmt.Errorf("legacyGlobals != newGlobals: %d != %d", len(legacyGlobals), len(newGlobals))
+	}
+
+	syslog.L.Info().WithMessage("Verifying targets migration...").Write()
+	legacyTargets, err := s.LegacyDatabase.GetAllTargets()
+	if err != nil {
+		return fmt.Errorf("error retrieving legacy targets: %w", err)
+	}
+	newTargets, err := s.Database.GetAllTargets()
+	if err != nil {
+		return fmt.Errorf("error retrieving new targets: %w", err)
+	}
+	if len(legacyTargets) != len(newTargets) {
+		return fmt.Errorf("legacyTargets != newTargets : %d != %d", len(legacyTargets), len(newTargets))
+	}
+
+	return nil
+}
+
+func (s *Store) removeLegacyData(_ *MigrationReport) error {
+	syslog.L.Info().WithMessage("Deleting legacy database directories...").Write()
+	return s.LegacyDatabase.Remove()
+}
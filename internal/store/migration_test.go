@@ -0,0 +1,109 @@
+//go:build linux
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupLegacyStore creates a store over a legacy config directory holding
+// two jobs, a custom global exclusion and a target.
+func setupLegacyStore(t *testing.T) (*Store, map[string]string) {
+	t.Helper()
+
+	base := t.TempDir()
+	paths := map[string]string{
+		"sqlite":     filepath.Join(base, "plus.db"),
+		"migration":  filepath.Join(base, "migration.json"),
+		"init":       filepath.Join(base, ".init"),
+		"jobs":       filepath.Join(base, "jobs.d"),
+		"targets":    filepath.Join(base, "targets.d"),
+		"exclusions": filepath.Join(base, "exclusions.d"),
+		"tokens":     filepath.Join(base, "tokens.d"),
+	}
+	require.NoError(t, os.MkdirAll(paths["jobs"], 0750))
+
+	store, err := Initialize(t.Context(), paths)
+	require.NoError(t, err)
+	require.NotNil(t, store.LegacyDatabase)
+
+	require.NoError(t, store.LegacyDatabase.CreateTarget(types.Target{Name: "legacy-target", Path: "/mnt/legacy"}))
+	for _, id := range []string{"legacy-job-1", "legacy-job-2"} {
+		require.NoError(t, store.LegacyDatabase.CreateJob(types.Job{
+			ID:     id,
+			Store:  "local",
+			Target: "legacy-target",
+		}))
+	}
+	require.NoError(t, store.LegacyDatabase.CreateExclusion(types.Exclusion{Path: "*.legacy", Comment: "custom"}))
+
+	return store, paths
+}
+
+func TestMigrateLegacyDataTwice(t *testing.T) {
+	store, paths := setupLegacyStore(t)
+
+	report, err := store.MigrateLegacyData()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jobs", "exclusions", "targets", "verify", "cleanup"}, report.Steps)
+	assert.Equal(t, 2, report.Jobs)
+	assert.Equal(t, 1, report.Exclusions)
+	assert.Equal(t, 1, report.Targets)
+	assert.Zero(t, report.Skipped)
+
+	assert.NoDirExists(t, paths["jobs"])
+	assert.FileExists(t, paths["migration"])
+
+	jobs, err := store.Database.GetAllJobs()
+	require.NoError(t, err)
+	assert.Len(t, jobs, 2)
+
+	report, err = store.MigrateLegacyData()
+	require.NoError(t, err)
+	assert.Empty(t, report.Steps, "second run was not a no-op")
+
+	jobs, err = store.Database.GetAllJobs()
+	require.NoError(t, err)
+	assert.Len(t, jobs, 2)
+}
+
+func TestMigrateLegacyDataResume(t *testing.T) {
+	store, paths := setupLegacyStore(t)
+
+	// A run interrupted after committing the jobs, before recording it.
+	legacyJobs, err := store.LegacyDatabase.GetAllJobs()
+	require.NoError(t, err)
+	require.NoError(t, store.Database.CreateJob(nil, legacyJobs[0]))
+	// The run before that recorded a manifest of an older migration.
+	require.NoError(t, os.WriteFile(paths["migration"], []byte(`{"version":0,"completed":["jobs","exclusions","targets","verify"]}`), 0640))
+
+	report, err := store.MigrateLegacyData()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jobs", "exclusions", "targets", "verify", "cleanup"}, report.Steps)
+	assert.Equal(t, 1, report.Jobs)
+	assert.Equal(t, 1, report.Skipped)
+
+	jobs, err := store.Database.GetAllJobs()
+	require.NoError(t, err)
+	assert.Len(t, jobs, 2)
+
+	// A run interrupted before its cleanup only removes what is left.
+	store, paths = setupLegacyStore(t)
+	require.NoError(t, os.WriteFile(paths["migration"], []byte(`{"version":1,"completed":["jobs","exclusions","targets","verify"]}`), 0640))
+
+	report, err = store.MigrateLegacyData()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cleanup"}, report.Steps)
+	assert.Zero(t, report.Jobs)
+	assert.NoDirExists(t, paths["jobs"])
+
+	jobs, err = store.Database.GetAllJobs()
+	require.NoError(t, err)
+	assert.Empty(t, jobs, "completed steps were run again")
+}
@@ -0,0 +1,151 @@
+//go:build linux
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+// DefaultAgentConnectTimeout bounds each ping of an agent target that does
+// not set its own ConnectTimeout.
+const DefaultAgentConnectTimeout = 5 * time.Second
+
+// agentPingRetryInterval is the pause between two pings of the same agent.
+var agentPingRetryInterval = time.Second
+
+// agentStatusTTL is how long AgentStatus reuses a ping answer before it
+// pings the agent again.
+var agentStatusTTL = 30 * time.Second
+
+// agentStatus is the cached ping answer of an agent.
+type agentStatus struct {
+	version   string
+	connected bool
+	checkedAt time.Time
+	// pinging is set while a refresh of the status is running.
+	pinging bool
+}
+
+// AgentConnectTimeout returns the time a single connection check of target
+// may take.
+func AgentConnectTimeout(target types.Target) time.Duration {
+	if target.ConnectTimeout > 0 {
+		return time.Duration(target.ConnectTimeout) * time.Second
+	}
+	return DefaultAgentConnectTimeout
+}
+
+// AgentPing checks that the agent of target answers on its ARPC session and
+// returns the version it reports. Each attempt is bounded by the
+// ConnectTimeout of the target and failed attempts are retried MaxRetries
+// times.
+func (s *Store) AgentPing(ctx context.Context, target types.Target) (string, error) {
+	if !target.IsAgent {
+		return "", fmt.Errorf("AgentPing: %s is not an agent target", target.Name)
+	}
+
+	hostname := strings.Split(target.Name, " - ")[0]
+	timeout := AgentConnectTimeout(target)
+	attempts := target.MaxRetries + 1
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		version, err := s.pingAgentOnce(ctx, hostname, timeout)
+		if err == nil {
+			return version, nil
+		}
+		lastErr = err
+
+		if attempt == attempts {
+			break
+		}
+		select {
+		case <-time.After(agentPingRetryInterval):
+		case <-ctx.Done():
+			return "", fmt.Errorf("AgentPing: %w (last error: %v)", ctx.Err(), lastErr)
+		}
+	}
+
+	return "", fmt.Errorf("AgentPing: %s did not answer after %d attempts: %w", hostname, attempts, lastErr)
+}
+
+// AgentStatus reports whether the agent of target answers and the version it
+// reports, from the last ping of the agent. It does not wait for the agent:
+// a stale answer is refreshed in the background and is reported until the
+// refresh completes. An agent without an ARPC session is reported offline,
+// and one not pinged yet is reported from its session.
+func (s *Store) AgentStatus(target types.Target) (string, bool) {
+	if !target.IsAgent {
+		return "", false
+	}
+
+	hostname := strings.Split(target.Name, " - ")[0]
+	session, ok := s.ARPCSessionManager.GetSession(hostname)
+	if !ok {
+		return "", false
+	}
+
+	s.agentStatusMu.Lock()
+	defer s.agentStatusMu.Unlock()
+
+	if s.agentStatuses == nil {
+		s.agentStatuses = make(map[string]agentStatus)
+	}
+	status, known := s.agentStatuses[hostname]
+	if (!known || time.Since(status.checkedAt) > agentStatusTTL) && !status.pinging {
+		status.pinging = true
+		s.agentStatuses[hostname] = status
+		go s.refreshAgentStatus(hostname, target)
+	}
+
+	if !known {
+		return session.GetVersion(), true
+	}
+	return status.version, status.connected
+}
+
+func (s *Store) refreshAgentStatus(hostname string, target types.Target) {
+	ctx := s.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	version, err := s.AgentPing(ctx, target)
+
+	s.agentStatusMu.Lock()
+	s.agentStatuses[hostname] = agentStatus{
+		version:   version,
+		connected: err == nil,
+		checkedAt: time.Now(),
+	}
+	s.agentStatusMu.Unlock()
+}
+
+func (s *Store) pingAgentOnce(ctx context.Context, hostname string, timeout time.Duration) (string, error) {
+	session, ok := s.ARPCSessionManager.GetSession(hostname)
+	if !ok {
+		return "", fmt.Errorf("%w for %s", arpc.ErrSessionNotFound, hostname)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := session.CallContext(ctx, "ping", nil)
+	if err != nil {
+		return "", err
+	}
+	if resp.Status != 200 {
+		return "", fmt.Errorf("ping returned status %d", resp.Status)
+	}
+
+	var info arpc.MapStringStringMsg
+	if err := info.Decode(resp.Data); err != nil {
+		return "", err
+	}
+	return info["version"], nil
+}
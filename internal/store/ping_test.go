@@ -0,0 +1,161 @@
+//go:build linux
+
+package store
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/arpc"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAgent connects an agent to a fresh session manager whose ping handler
+// waits for delay before answering. It returns the store and the number of
+// pings the agent received.
+func stubAgent(t *testing.T, hostname string, delay time.Duration) (*Store, *atomic.Int32) {
+	t.Helper()
+
+	serverConn, agentConn := net.Pipe()
+
+	store := &Store{ARPCSessionManager: arpc.NewSessionManager()}
+	serverSession, err := store.ARPCSessionManager.GetOrCreateSession(hostname, "test", serverConn)
+	require.NoError(t, err)
+	go func() { _ = serverSession.Serve() }()
+
+	agentSession, err := arpc.NewClientSession(agentConn, nil)
+	require.NoError(t, err)
+
+	var pings atomic.Int32
+	router := arpc.NewRouter()
+	router.Handle("ping", func(req arpc.Request) (arpc.Response, error) {
+		pings.Add(1)
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return arpc.Response{}, req.Context().Err()
+		}
+		resp := arpc.MapStringStringMsg{"version": "v1.2.3", "hostname": hostname}
+		data, err := resp.Encode()
+		if err != nil {
+			return arpc.Response{}, err
+		}
+		return arpc.Response{Status: 200, Data: data}, nil
+	})
+	agentSession.SetRouter(router)
+	go func() { _ = agentSession.Serve() }()
+
+	t.Cleanup(func() {
+		agentSession.Close()
+		serverSession.Close()
+	})
+
+	return store, &pings
+}
+
+func TestAgentPing(t *testing.T) {
+	interval := agentPingRetryInterval
+	agentPingRetryInterval = 10 * time.Millisecond
+	t.Cleanup(func() { agentPingRetryInterval = interval })
+
+	t.Run("answers within timeout", func(t *testing.T) {
+		store, pings := stubAgent(t, "fast", 0)
+		target := types.Target{Name: "fast - C", IsAgent: true, ConnectTimeout: 1}
+
+		version, err := store.AgentPing(context.Background(), target)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.2.3", version)
+		assert.Equal(t, int32(1), pings.Load())
+	})
+
+	t.Run("timeout is honored", func(t *testing.T) {
+		store, pings := stubAgent(t, "slow", 5*time.Second)
+		target := types.Target{Name: "slow - C", IsAgent: true, ConnectTimeout: 1}
+
+		start := time.Now()
+		_, err := store.AgentPing(context.Background(), target)
+		elapsed := time.Since(start)
+
+		require.Error(t, err)
+		assert.GreaterOrEqual(t, elapsed, time.Second)
+		assert.Less(t, elapsed, 3*time.Second)
+		assert.Equal(t, int32(1), pings.Load())
+	})
+
+	t.Run("retries the configured number of times", func(t *testing.T) {
+		store, pings := stubAgent(t, "retry", 5*time.Second)
+		target := types.Target{Name: "retry - C", IsAgent: true, ConnectTimeout: 1, MaxRetries: 2}
+
+		start := time.Now()
+		_, err := store.AgentPing(context.Background(), target)
+		elapsed := time.Since(start)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "after 3 attempts")
+		assert.GreaterOrEqual(t, elapsed, 3*time.Second)
+		assert.Less(t, elapsed, 6*time.Second)
+		require.Eventually(t, func() bool { return pings.Load() == 3 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("missing session is retried", func(t *testing.T) {
+		store := &Store{ARPCSessionManager: arpc.NewSessionManager()}
+		target := types.Target{Name: "gone - C", IsAgent: true, MaxRetries: 1}
+
+		_, err := store.AgentPing(context.Background(), target)
+		assert.ErrorIs(t, err, arpc.ErrSessionNotFound)
+		assert.Contains(t, err.Error(), "after 2 attempts")
+	})
+
+	t.Run("default timeout", func(t *testing.T) {
+		assert.Equal(t, DefaultAgentConnectTimeout, AgentConnectTimeout(types.Target{}))
+		assert.Equal(t, 7*time.Second, AgentConnectTimeout(types.Target{ConnectTimeout: 7}))
+	})
+}
+
+func TestAgentStatus(t *testing.T) {
+	ttl := agentStatusTTL
+	t.Cleanup(func() { agentStatusTTL = ttl })
+
+	t.Run("does not wait for a slow agent", func(t *testing.T) {
+		store, pings := stubAgent(t, "slow", 500*time.Millisecond)
+		target := types.Target{Name: "slow - C", IsAgent: true, ConnectTimeout: 5}
+
+		start := time.Now()
+		version, connected := store.AgentStatus(target)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+		assert.True(t, connected, "an agent not pinged yet is reported from its session")
+		assert.Equal(t, "test", version)
+
+		// Once the background ping answers, its version is reported.
+		require.Eventually(t, func() bool {
+			version, connected := store.AgentStatus(target)
+			return connected && version == "v1.2.3"
+		}, 3*time.Second, 10*time.Millisecond)
+		assert.Equal(t, int32(1), pings.Load(), "a fresh answer is reused")
+	})
+
+	t.Run("stale answer is refreshed once", func(t *testing.T) {
+		agentStatusTTL = 0
+		store, pings := stubAgent(t, "stale", 200*time.Millisecond)
+		target := types.Target{Name: "stale - C", IsAgent: true, ConnectTimeout: 5}
+
+		for range 5 {
+			store.AgentStatus(target)
+		}
+		require.Eventually(t, func() bool { return pings.Load() == 1 }, time.Second, 10*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, int32(1), pings.Load(), "concurrent lookups share one ping")
+	})
+
+	t.Run("agent without session", func(t *testing.T) {
+		store := &Store{ARPCSessionManager: arpc.NewSessionManager()}
+		version, connected := store.AgentStatus(types.Target{Name: "gone - C", IsAgent: true})
+		assert.False(t, connected)
+		assert.Empty(t, version)
+	})
+}
@@ -0,0 +1,161 @@
+//go:build linux
+
+package proxmox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type DatastoreStatus struct {
+	Total int64 `json:"total"`
+	Used  int64 `json:"used"`
+	Avail int64 `json:"avail"`
+}
+
+type DatastoreStatusResponse struct {
+	Data DatastoreStatus `json:"data"`
+}
+
+type DatastoreSnapshot struct {
+	BackupType string `json:"backup-type"`
+	BackupID   string `json:"backup-id"`
+	BackupTime int64  `json:"backup-time"`
+	Size       int64  `json:"size"`
+}
+
+type DatastoreSnapshotsResponse struct {
+	Data []DatastoreSnapshot `json:"data"`
+}
+
+type DatastoreNamespace struct {
+	NS string `json:"ns"`
+}
+
+type DatastoreNamespacesResponse struct {
+	Data []DatastoreNamespace `json:"data"`
+}
+
+// GetDatastoreStatus returns the usage of the given datastore.
+func (proxmoxSess *ProxmoxSession) GetDatastoreStatus(store string) (*DatastoreStatus, error) {
+	var resp DatastoreStatusResponse
+
+	err := proxmoxSess.ProxmoxHTTPRequest(
+		http.MethodGet,
+		fmt.Sprintf("/api2/json/admin/datastore/%s/status", url.PathEscape(store)),
+		nil,
+		&resp,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetDatastoreStatus: error creating http request -> %w", err)
+	}
+
+	return &resp.Data, nil
+}
+
+// GetLatestSnapshot returns the most recent host snapshot of backupId in the
+// given datastore and namespace.
+func (proxmoxSess *ProxmoxSession) GetLatestSnapshot(store, namespace, backupId string) (*DatastoreSnapshot, error) {
+	var resp DatastoreSnapshotsResponse
+
+	query := url.Values{}
+	query.Set("backup-type", "host")
+	query.Set("backup-id", backupId)
+	if namespace != "" {
+		query.Set("ns", namespace)
+	}
+
+	err := proxmoxSess.ProxmoxHTTPRequest(
+		http.MethodGet,
+		fmt.Sprintf("/api2/json/admin/datastore/%s/snapshots?%s", url.PathEscape(store), query.Encode()),
+		nil,
+		&resp,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetLatestSnapshot: error creating http request -> %w", err)
+	}
+
+	var latest *DatastoreSnapshot
+	for i := range resp.Data {
+		if latest == nil || resp.Data[i].BackupTime > latest.BackupTime {
+			latest = &resp.Data[i]
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("GetLatestSnapshot: no snapshot found for %s", backupId)
+	}
+
+	return latest, nil
+}
+
+// DeleteBackupGroup removes the host backup group of backupId, including all
+// of its snapshots, from the given datastore and namespace.
+func (proxmoxSess *ProxmoxSession) DeleteBackupGroup(store, namespace, backupId string) error {
+	query := url.Values{}
+	query.Set("backup-type", "host")
+	query.Set("backup-id", backupId)
+	if namespace != "" {
+		query.Set("ns", namespace)
+	}
+
+	err := proxmoxSess.ProxmoxHTTPRequest(
+		http.MethodDelete,
+		fmt.Sprintf("/api2/json/admin/datastore/%s/groups?%s", url.PathEscape(store), query.Encode()),
+		nil,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("DeleteBackupGroup: error creating http request -> %w", err)
+	}
+
+	return nil
+}
+
+// ListNamespaces returns the path of every namespace of the given datastore.
+// The root namespace is listed as an empty path.
+func (proxmoxSess *ProxmoxSession) ListNamespaces(store string) ([]string, error) {
+	var resp DatastoreNamespacesResponse
+
+	err := proxmoxSess.ProxmoxHTTPRequest(
+		http.MethodGet,
+		fmt.Sprintf("/api2/json/admin/datastore/%s/namespace", url.PathEscape(store)),
+		nil,
+		&resp,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ListNamespaces: error creating http request -> %w", err)
+	}
+
+	namespaces := make([]string, 0, len(resp.Data))
+	for _, ns := range resp.Data {
+		namespaces = append(namespaces, ns.NS)
+	}
+	return namespaces, nil
+}
+
+// CreateNamespace creates the namespace name directly below parent in the
+// given datastore. An empty parent is the root namespace.
+func (proxmoxSess *ProxmoxSession) CreateNamespace(store, parent, name string) error {
+	body, err := json.Marshal(map[string]string{
+		"name":   name,
+		"parent": parent,
+	})
+	if err != nil {
+		return fmt.Errorf("CreateNamespace: error encoding request -> %w", err)
+	}
+
+	err = proxmoxSess.ProxmoxHTTPRequest(
+		http.MethodPost,
+		fmt.Sprintf("/api2/json/admin/datastore/%s/namespace", url.PathEscape(store)),
+		bytes.NewReader(body),
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("CreateNamespace: error creating http request -> %w", err)
+	}
+
+	return nil
+}
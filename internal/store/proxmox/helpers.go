@@ -22,67 +22,53 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 )
 
+// upidPattern matches a UPID in the form written by Proxmox Backup Server:
+// UPID:node:pid:pstart:task_id:starttime:worker_type:worker_id:auth_id:
+var upidPattern = regexp.MustCompile(`^UPID:([a-zA-Z0-9](?:[a-zA-Z0-9\-]*[a-zA-Z0-9])?):([0-9A-Fa-f]{8}):([0-9A-Fa-f]{8,9}):([0-9A-Fa-f]{8,16}):([0-9A-Fa-f]{8}):([^:\s]+):([^:\s]*):([^:\s]+):$`)
+
 // ParseUPID parses a Proxmox Backup Server UPID string and returns a Task struct.
+// The worker ID is kept in its escaped form, as it appears in the UPID.
 func ParseUPID(upid string) (Task, error) {
-	// Define the regex pattern for the UPID.
-	pattern := `^UPID:(?P<node>[a-zA-Z0-9]([a-zA-Z0-9\-]*[a-zA-Z0-9])?):(?P<pid>[0-9A-Fa-f]{8}):(?P<pstart>[0-9A-Fa-f]{8,9}):(?P<task_id>[0-9A-Fa-f]{8,16}):(?P<starttime>[0-9A-Fa-f]{8}):(?P<wtype>[^:\s]+):(?P<wid>[^:\s]*):(?P<authid>[^:\s]+):$`
-
-	// Compile the regex.
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		return Task{}, fmt.Errorf("failed to compile regex: %w", err)
-	}
-
-	// Match the UPID string against the regex.
-	matches := re.FindStringSubmatch(upid)
+	matches := upidPattern.FindStringSubmatch(upid)
 	if matches == nil {
 		return Task{}, fmt.Errorf("invalid UPID format")
 	}
 
-	// Create a new Task instance.
-	task := Task{
-		UPID: upid, // Store the original UPID string.
+	pid, err := strconv.ParseUint(matches[2], 16, 32)
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to parse PID: %w", err)
 	}
-
-	// Extract the named groups using the regex's SubexpNames.
-	for i, name := range re.SubexpNames() {
-		if name == "" || i >= len(matches) {
-			continue
-		}
-		switch name {
-		case "node":
-			task.Node = matches[i]
-		case "pid":
-			// Convert PID from hex to int.
-			pid, err := strconv.ParseInt(matches[i], 16, 32)
-			if err != nil {
-				return Task{}, fmt.Errorf("failed to parse PID: %w", err)
-			}
-			task.PID = int(pid)
-		case "pstart":
-			// Convert PStart from hex to int.
-			pstart, err := strconv.ParseInt(matches[i], 16, 32)
-			if err != nil {
-				return Task{}, fmt.Errorf("failed to parse PStart: %w", err)
-			}
-			task.PStart = int(pstart)
-		case "starttime":
-			// Convert StartTime from hex to int64.
-			startTime, err := strconv.ParseInt(matches[i], 16, 64)
-			if err != nil {
-				return Task{}, fmt.Errorf("failed to parse StartTime: %w", err)
-			}
-			task.StartTime = startTime
-		case "wtype":
-			task.WorkerType = matches[i]
-		case "wid":
-			task.WID = matches[i]
-		case "authid":
-			task.User = matches[i]
-		}
+	pstart, err := strconv.ParseUint(matches[3], 16, 64)
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to parse PStart: %w", err)
+	}
+	taskID, err := strconv.ParseUint(matches[4], 16, 64)
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to parse TaskID: %w", err)
 	}
+	startTime, err := strconv.ParseInt(matches[5], 16, 64)
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to parse StartTime: %w", err)
+	}
+
+	return Task{
+		UPID:       upid,
+		Node:       matches[1],
+		PID:        int(pid),
+		PStart:     int(pstart),
+		TaskID:     taskID,
+		StartTime:  startTime,
+		WorkerType: matches[6],
+		WID:        matches[7],
+		User:       matches[8],
+	}, nil
+}
 
-	return task, nil
+// EncodeUPID returns the UPID of the task in the canonical form used by
+// Proxmox Backup Server. WID is expected to be escaped already.
+func (task Task) EncodeUPID() string {
+	return fmt.Sprintf("UPID:%s:%08X:%08X:%08X:%08X:%s:%s:%s:",
+		task.Node, task.PID, task.PStart, task.TaskID, task.StartTime, task.WorkerType, task.WID, task.User)
 }
 
 var pstart = atomic.Int32{}
@@ -100,7 +86,6 @@ func GenerateTaskErrorFile(job types.Job, pbsError error, additionalData []strin
 
 	targetName := strings.TrimSpace(strings.Split(job.Target, " - ")[0])
 	wid := fmt.Sprintf("%s%shost-%s", encodeToHexEscapes(job.Store), encodeToHexEscapes(":"), encodeToHexEscapes(targetName))
-	startTime := fmt.Sprintf("%08X", uint32(time.Now().Unix()))
 	hostname, err := os.Hostname()
 	if err != nil {
 		hostnameBytes, err := os.ReadFile("/etc/hostname")
@@ -110,26 +95,20 @@ func GenerateTaskErrorFile(job types.Job, pbsError error, additionalData []strin
 		hostname = strings.TrimSpace(string(hostnameBytes))
 	}
 
-	wtype := "backup"
-	node := hostname
-
 	task := Task{
-		Node:       node,
+		Node:       hostname,
 		PID:        os.Getpid(),
 		PStart:     getPStart(),
+		TaskID:     uint64(rand.Uint32()),
 		StartTime:  time.Now().Unix(),
-		WorkerType: wtype,
+		WorkerType: "backup",
 		WID:        wid,
 		User:       authId,
 	}
 
-	pid := fmt.Sprintf("%08X", task.PID)
-	pstart := fmt.Sprintf("%08X", task.PStart)
-	taskID := fmt.Sprintf("%08X", rand.Uint32())
-
-	upid := fmt.Sprintf("UPID:%s:%s:%s:%s:%s:%s:%s:%s:", node, pid, pstart, taskID, startTime, wtype, wid, authId)
-
+	upid := task.EncodeUPID()
 	task.UPID = upid
+	startTime := fmt.Sprintf("%08X", task.StartTime)
 
 	path, err := GetLogPath(upid)
 	if err != nil {
@@ -0,0 +1,78 @@
+//go:build linux
+
+package proxmox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var upidCorpus = []string{
+	`UPID:phoenix:000026F4:00000BDD:00000001:5F3B0E4C:backup:store1\x3ahost\x2dserver:root@pam:`,
+	`UPID:pbs:00000C1F:0000BC84:00000003:67A1B2C3:garbage_collection:main:root@pam:`,
+	`UPID:pbs-node-1:0001E240:1A2B3C4D5:0000002A:65F0A1B2:verificationjob:main\x3av\x2d3f1a2b3c\x2d4d5e:root@pam:`,
+	`UPID:pbs:000004D2:00001234:00000000:66000000:termproxy::root@pam:`,
+	`UPID:backup01:00000A3C:00002F10:0000001B:6718C2F0:reader:datastore1\x3avm\x2f100\x2f2024\x2d10\x2d23T08\x3a00\x3a00Z:backup@pbs!token:`,
+	`UPID:pbs:FFFFFFFE:00000001:FEDCBA9876543210:6718C2F0:prune:main:root@pam:`,
+}
+
+func TestParseUPIDFields(t *testing.T) {
+	task, err := ParseUPID(upidCorpus[0])
+	require.NoError(t, err)
+
+	assert.Equal(t, Task{
+		UPID:       upidCorpus[0],
+		Node:       "phoenix",
+		PID:        0x26F4,
+		PStart:     0xBDD,
+		TaskID:     1,
+		StartTime:  0x5F3B0E4C,
+		WorkerType: "backup",
+		WID:        `store1\x3ahost\x2dserver`,
+		User:       "root@pam",
+	}, task)
+}
+
+func TestUPIDRoundTrip(t *testing.T) {
+	for _, upid := range upidCorpus {
+		task, err := ParseUPID(upid)
+		require.NoError(t, err, upid)
+		assert.Equal(t, upid, task.EncodeUPID())
+
+		reparsed, err := ParseUPID(task.EncodeUPID())
+		require.NoError(t, err, upid)
+		assert.Equal(t, task, reparsed)
+	}
+
+	task := Task{
+		Node:       "phoenix",
+		PID:        4242,
+		PStart:     7,
+		TaskID:     0xABCDEF,
+		StartTime:  1729670400,
+		WorkerType: "backup",
+		WID:        encodeToHexEscapes("store1") + encodeToHexEscapes(":") + "host" + encodeToHexEscapes("-") + "web",
+		User:       "root@pam!pbs-plus",
+	}
+	task.UPID = task.EncodeUPID()
+	assert.Equal(t, `UPID:phoenix:00001092:00000007:00ABCDEF:6718AD00:backup:store1\x3ahost\x2dweb:root@pam!pbs-plus:`, task.UPID)
+
+	parsed, err := ParseUPID(task.UPID)
+	require.NoError(t, err)
+	assert.Equal(t, task, parsed)
+}
+
+func TestParseUPIDInvalid(t *testing.T) {
+	for _, upid := range []string{
+		"",
+		"UPID:1",
+		`UPID:phoenix:000026F4:00000BDD:00000001:5F3B0E4C:backup:store1\x3ahost:root@pam`,
+		`UPID:-phoenix:000026F4:00000BDD:00000001:5F3B0E4C:backup:store1:root@pam:`,
+		`UPID:phoenix:26F4:00000BDD:00000001:5F3B0E4C:backup:store1:root@pam:`,
+	} {
+		_, err := ParseUPID(upid)
+		assert.Error(t, err, upid)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils/safemap"
 )
 
@@ -100,7 +101,7 @@ func (proxmoxSess *ProxmoxSession) GetJobTask(
 		}
 	}
 
-	isAgent := strings.HasPrefix(target.Path, "agent://")
+	isAgent := utils.IsAgentPath(target.Path)
 	backupId := hostname
 	if isAgent {
 		backupId = strings.TrimSpace(strings.Split(target.Name, " - ")[0])
@@ -10,6 +10,7 @@ type Task struct {
 	Node       string `json:"node"`
 	PID        int    `json:"pid"`
 	PStart     int    `json:"pstart"`
+	TaskID     uint64 `json:"task_id,omitempty"`
 	StartTime  int64  `json:"starttime"`
 	EndTime    int64  `json:"endtime"`
 	UPID       string `json:"upid"`
@@ -7,7 +7,6 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
@@ -32,15 +31,20 @@ func (database *Database) CreateExclusion(tx *sql.Tx, exclusion types.Exclusion)
 		return errors.New("path is empty")
 	}
 
-	exclusion.Path = strings.ReplaceAll(exclusion.Path, "\\", "/")
-	if !pattern.IsValidPattern(exclusion.Path) {
-		return fmt.Errorf("CreateExclusion: invalid path pattern -> %s", exclusion.Path)
+	matchType, err := pattern.NormalizeMatchType(exclusion.MatchType)
+	if err != nil {
+		return fmt.Errorf("CreateExclusion: %w", err)
+	}
+	exclusion.MatchType = matchType
+	exclusion.Path = pattern.NormalizePattern(exclusion.Path, exclusion.MatchType)
+	if err := pattern.ValidatePattern(exclusion.Path, exclusion.MatchType); err != nil {
+		return fmt.Errorf("CreateExclusion: invalid path pattern -> %s: %w", exclusion.Path, err)
 	}
 
-	_, err := tx.Exec(`
-        INSERT INTO exclusions (job_id, path, comment)
-        VALUES (?, ?, ?)
-    `, exclusion.JobID, exclusion.Path, exclusion.Comment)
+	_, err = tx.Exec(`
+        INSERT INTO exclusions (job_id, path, comment, match_type, case_sensitive)
+        VALUES (?, ?, ?, ?, ?)
+    `, exclusion.JobID, exclusion.Path, exclusion.Comment, exclusion.MatchType, exclusion.CaseSensitive)
 	if err != nil {
 		return fmt.Errorf("CreateExclusion: error inserting exclusion: %w", err)
 	}
@@ -50,7 +54,7 @@ func (database *Database) CreateExclusion(tx *sql.Tx, exclusion types.Exclusion)
 // GetAllJobExclusions returns all exclusions associated with a job.
 func (database *Database) GetAllJobExclusions(jobId string) ([]types.Exclusion, error) {
 	rows, err := database.readDb.Query(`
-        SELECT job_id, path, comment FROM exclusions
+        SELECT job_id, path, comment, match_type, case_sensitive FROM exclusions
         WHERE job_id = ?
     `, jobId)
 	if err != nil {
@@ -63,13 +67,17 @@ func (database *Database) GetAllJobExclusions(jobId string) ([]types.Exclusion,
 
 	for rows.Next() {
 		var excl types.Exclusion
-		if err := rows.Scan(&excl.JobID, &excl.Path, &excl.Comment); err != nil {
+		var matchType sql.NullString
+		var caseSensitive sql.NullBool
+		if err := rows.Scan(&excl.JobID, &excl.Path, &excl.Comment, &matchType, &caseSensitive); err != nil {
 			continue // Skip problematic rows.
 		}
 		if seenPaths[excl.Path] {
 			continue
 		}
 		seenPaths[excl.Path] = true
+		excl.MatchType = exclusionMatchType(matchType)
+		excl.CaseSensitive = exclusionCaseSensitive(caseSensitive)
 		exclusions = append(exclusions, excl)
 	}
 	return exclusions, nil
@@ -78,7 +86,7 @@ func (database *Database) GetAllJobExclusions(jobId string) ([]types.Exclusion,
 // GetAllGlobalExclusions returns all exclusions that are not tied to any job.
 func (database *Database) GetAllGlobalExclusions() ([]types.Exclusion, error) {
 	rows, err := database.readDb.Query(`
-        SELECT job_id, path, comment FROM exclusions
+        SELECT job_id, path, comment, match_type, case_sensitive FROM exclusions
         WHERE job_id IS NULL OR job_id = ''
     `)
 	if err != nil {
@@ -90,13 +98,17 @@ func (database *Database) GetAllGlobalExclusions() ([]types.Exclusion, error) {
 	seenPaths := make(map[string]bool)
 	for rows.Next() {
 		var excl types.Exclusion
-		if err := rows.Scan(&excl.JobID, &excl.Path, &excl.Comment); err != nil {
+		var matchType sql.NullString
+		var caseSensitive sql.NullBool
+		if err := rows.Scan(&excl.JobID, &excl.Path, &excl.Comment, &matchType, &caseSensitive); err != nil {
 			continue
 		}
 		if seenPaths[excl.Path] {
 			continue
 		}
 		seenPaths[excl.Path] = true
+		excl.MatchType = exclusionMatchType(matchType)
+		excl.CaseSensitive = exclusionCaseSensitive(caseSensitive)
 		exclusions = append(exclusions, excl)
 	}
 	return exclusions, nil
@@ -105,13 +117,17 @@ func (database *Database) GetAllGlobalExclusions() ([]types.Exclusion, error) {
 // GetExclusion retrieves a single exclusion by its path.
 func (database *Database) GetExclusion(path string) (*types.Exclusion, error) {
 	row := database.readDb.QueryRow(`
-        SELECT job_id, path, comment FROM exclusions WHERE path = ?
+        SELECT job_id, path, comment, match_type, case_sensitive FROM exclusions WHERE path = ?
     `, path)
 	var excl types.Exclusion
-	err := row.Scan(&excl.JobID, &excl.Path, &excl.Comment)
+	var matchType sql.NullString
+	var caseSensitive sql.NullBool
+	err := row.Scan(&excl.JobID, &excl.Path, &excl.Comment, &matchType, &caseSensitive)
 	if err != nil {
 		return nil, fmt.Errorf("GetExclusion: exclusion not found for path: %s", path)
 	}
+	excl.MatchType = exclusionMatchType(matchType)
+	excl.CaseSensitive = exclusionCaseSensitive(caseSensitive)
 	return &excl, nil
 }
 
@@ -133,14 +149,19 @@ func (database *Database) UpdateExclusion(tx *sql.Tx, exclusion types.Exclusion)
 		return errors.New("path is empty")
 	}
 
-	exclusion.Path = strings.ReplaceAll(exclusion.Path, "\\", "/")
-	if !pattern.IsValidPattern(exclusion.Path) {
-		return fmt.Errorf("UpdateExclusion: invalid path pattern -> %s", exclusion.Path)
+	matchType, err := pattern.NormalizeMatchType(exclusion.MatchType)
+	if err != nil {
+		return fmt.Errorf("UpdateExclusion: %w", err)
+	}
+	exclusion.MatchType = matchType
+	exclusion.Path = pattern.NormalizePattern(exclusion.Path, exclusion.MatchType)
+	if err := pattern.ValidatePattern(exclusion.Path, exclusion.MatchType); err != nil {
+		return fmt.Errorf("UpdateExclusion: invalid path pattern -> %s: %w", exclusion.Path, err)
 	}
 
 	res, err := tx.Exec(`
-        UPDATE exclusions SET job_id = ?, comment = ? WHERE path = ?
-    `, exclusion.JobID, exclusion.Comment, exclusion.Path)
+        UPDATE exclusions SET job_id = ?, comment = ?, match_type = ?, case_sensitive = ? WHERE path = ?
+    `, exclusion.JobID, exclusion.Comment, exclusion.MatchType, exclusion.CaseSensitive, exclusion.Path)
 	if err != nil {
 		return fmt.Errorf("UpdateExclusion: error updating exclusion: %w", err)
 	}
@@ -165,10 +186,10 @@ func (database *Database) DeleteExclusion(tx *sql.Tx, path string) error {
 		defer tx.Commit()
 	}
 
-	path = strings.ReplaceAll(path, "\\", "/")
+	// Regex exclusions are stored as is, others normalized.
 	res, err := tx.Exec(`
-        DELETE FROM exclusions WHERE path = ?
-    `, path)
+        DELETE FROM exclusions WHERE path = ? OR path = ?
+    `, path, pattern.NormalizePattern(path, pattern.MatchGlob))
 	if err != nil {
 		return fmt.Errorf("DeleteExclusion: error deleting exclusion: %w", err)
 	}
@@ -178,3 +199,22 @@ func (database *Database) DeleteExclusion(tx *sql.Tx, path string) error {
 	}
 	return nil
 }
+
+// exclusionMatchType maps a stored match type to its normalized form. Rows
+// without one predate match types and are globs.
+func exclusionMatchType(stored sql.NullString) string {
+	matchType, err := pattern.NormalizeMatchType(stored.String)
+	if err != nil {
+		return pattern.MatchGlob
+	}
+	return matchType
+}
+
+// exclusionCaseSensitive maps a stored case sensitivity to the flag of an
+// exclusion. Rows without one follow the target.
+func exclusionCaseSensitive(stored sql.NullBool) *bool {
+	if !stored.Valid {
+		return nil
+	}
+	return &stored.Bool
+}
@@ -0,0 +1,91 @@
+//go:build linux
+
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+// JobHistoryLength is the number of runs kept in the history of a job.
+const JobHistoryLength = 20
+
+// jobHistoryPath returns the history file of job id, kept with its logs so
+// it goes away with the job.
+func jobHistoryPath(id string) string {
+	return filepath.Join(constants.JobLogsBasePath, id, "history.json")
+}
+
+// AppendJobRun adds run to the history of job id, dropping the oldest runs
+// beyond JobHistoryLength.
+func (database *Database) AppendJobRun(id string, run types.JobRun) error {
+	database.historyMu.Lock()
+	defer database.historyMu.Unlock()
+
+	if err := appendJobRun(jobHistoryPath(id), run, JobHistoryLength); err != nil {
+		return fmt.Errorf("AppendJobRun: %w", err)
+	}
+	return nil
+}
+
+// GetJobHistory returns the last runs of job id, oldest first.
+func (database *Database) GetJobHistory(id string) ([]types.JobRun, error) {
+	database.historyMu.Lock()
+	defer database.historyMu.Unlock()
+
+	runs, err := readJobHistory(jobHistoryPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("GetJobHistory: %w", err)
+	}
+	return runs, nil
+}
+
+func readJobHistory(path string) ([]types.JobRun, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []types.JobRun{}, nil
+		}
+		return nil, err
+	}
+
+	var runs []types.JobRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return runs, nil
+}
+
+// appendJobRun writes the history at path with run added, keeping the last
+// limit runs. The file is replaced whole so a crash leaves either history.
+func appendJobRun(path string, run types.JobRun, limit int) error {
+	runs, err := readJobHistory(path)
+	if err != nil {
+		// A damaged history is started over rather than blocking new runs.
+		runs = nil
+	}
+
+	runs = append(runs, run)
+	if len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+
+	data, err := json.Marshal(runs)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
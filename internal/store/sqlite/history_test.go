@@ -0,0 +1,60 @@
+//go:build linux
+
+package sqlite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobHistoryRingBuffer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nightly", "history.json")
+
+	runs, err := readJobHistory(path)
+	require.NoError(t, err)
+	assert.Empty(t, runs, "a job that never ran has an empty history")
+
+	const limit = 5
+	for i := 1; i <= limit+3; i++ {
+		state := "success"
+		if i%3 == 0 {
+			state = "failed"
+		}
+		require.NoError(t, appendJobRun(path, types.JobRun{
+			UPID:      fmt.Sprintf("UPID:pbs:%d", i),
+			State:     state,
+			StartTime: int64(1700000000 + i*3600),
+			Duration:  int64(60 * i),
+			Bytes:     int64(i << 20),
+		}, limit))
+	}
+
+	runs, err = readJobHistory(path)
+	require.NoError(t, err)
+	require.Len(t, runs, limit)
+	for i, run := range runs {
+		n := i + 4
+		assert.Equal(t, fmt.Sprintf("UPID:pbs:%d", n), run.UPID, "oldest first")
+		assert.Equal(t, int64(60*n), run.Duration)
+		assert.Equal(t, int64(n<<20), run.Bytes)
+	}
+	assert.Equal(t, "failed", runs[2].State)
+
+	t.Run("damaged history", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("{truncated"), 0644))
+		_, err := readJobHistory(path)
+		assert.Error(t, err)
+
+		require.NoError(t, appendJobRun(path, types.JobRun{UPID: "UPID:pbs:next"}, limit))
+		runs, err := readJobHistory(path)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, "UPID:pbs:next", runs[0].UPID)
+	})
+}
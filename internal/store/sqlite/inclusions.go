@@ -0,0 +1,115 @@
+//go:build linux
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+	_ "modernc.org/sqlite"
+)
+
+// CreateInclusion inserts a new inclusion into the database.
+func (database *Database) CreateInclusion(tx *sql.Tx, inclusion types.Inclusion) error {
+	if tx == nil {
+		database.writeMu.Lock()
+		defer database.writeMu.Unlock()
+
+		var err error
+		tx, err = database.writeDb.BeginTx(context.Background(), &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Commit()
+	}
+
+	if inclusion.JobID == "" {
+		return errors.New("job id is empty")
+	}
+	if inclusion.Path == "" {
+		return errors.New("path is empty")
+	}
+	if strings.HasPrefix(inclusion.Path, "!") {
+		return fmt.Errorf("CreateInclusion: inclusion %s cannot be negated", inclusion.Path)
+	}
+
+	matchType, err := pattern.NormalizeMatchType(inclusion.MatchType)
+	if err != nil {
+		return fmt.Errorf("CreateInclusion: %w", err)
+	}
+	inclusion.MatchType = matchType
+	// Backslashes are escapes in a regex, not Windows separators.
+	if inclusion.MatchType != pattern.MatchRegex {
+		inclusion.Path = strings.ReplaceAll(inclusion.Path, "\\", "/")
+	}
+	if err := pattern.ValidatePattern(inclusion.Path, inclusion.MatchType); err != nil {
+		return fmt.Errorf("CreateInclusion: invalid path pattern -> %s: %w", inclusion.Path, err)
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO inclusions (job_id, path, comment, match_type)
+        VALUES (?, ?, ?, ?)
+    `, inclusion.JobID, inclusion.Path, inclusion.Comment, inclusion.MatchType)
+	if err != nil {
+		return fmt.Errorf("CreateInclusion: error inserting inclusion: %w", err)
+	}
+	return nil
+}
+
+// GetAllJobInclusions returns all inclusions of a job.
+func (database *Database) GetAllJobInclusions(jobId string) ([]types.Inclusion, error) {
+	rows, err := database.readDb.Query(`
+        SELECT job_id, path, comment, match_type FROM inclusions
+        WHERE job_id = ?
+    `, jobId)
+	if err != nil {
+		return nil, fmt.Errorf("GetAllJobInclusions: error querying inclusions: %w", err)
+	}
+	defer rows.Close()
+
+	var inclusions []types.Inclusion
+	for rows.Next() {
+		var incl types.Inclusion
+		var comment, matchType sql.NullString
+		if err := rows.Scan(&incl.JobID, &incl.Path, &comment, &matchType); err != nil {
+			continue // Skip problematic rows.
+		}
+		incl.Comment = comment.String
+		incl.MatchType = exclusionMatchType(matchType)
+		inclusions = append(inclusions, incl)
+	}
+	return inclusions, nil
+}
+
+// DeleteInclusion removes an inclusion of a job from the database.
+func (database *Database) DeleteInclusion(tx *sql.Tx, jobId string, path string) error {
+	if tx == nil {
+		database.writeMu.Lock()
+		defer database.writeMu.Unlock()
+
+		var err error
+		tx, err = database.writeDb.BeginTx(context.Background(), &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Commit()
+	}
+
+	// Regex inclusions are stored with their backslashes.
+	res, err := tx.Exec(`
+        DELETE FROM inclusions WHERE job_id = ? AND (path = ? OR path = ?)
+    `, jobId, path, strings.ReplaceAll(path, "\\", "/"))
+	if err != nil {
+		return fmt.Errorf("DeleteInclusion: error deleting inclusion: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return fmt.Errorf("DeleteInclusion: inclusion not found for path: %s", path)
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
 	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
 	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
 	_ "modernc.org/sqlite"
 )
 
@@ -51,18 +52,24 @@ func (database *Database) generateUniqueJobID(job types.Job) (string, error) {
 		maxAttempts)
 }
 
-// CreateJob creates a new job record and adds any associated exclusions.
-func (database *Database) CreateJob(tx *sql.Tx, job types.Job) error {
+// CreateJob creates a new job record and adds any associated exclusions and
+// inclusions.
+func (database *Database) CreateJob(tx *sql.Tx, job types.Job) (err error) {
 	if tx == nil {
 		database.writeMu.Lock()
 		defer database.writeMu.Unlock()
 
-		var err error
 		tx, err = database.writeDb.BeginTx(context.Background(), &sql.TxOptions{})
 		if err != nil {
 			return err
 		}
-		defer tx.Commit()
+		defer func() {
+			if err != nil {
+				_ = tx.Rollback()
+				return
+			}
+			err = tx.Commit()
+		}()
 	}
 
 	if job.ID == "" {
@@ -73,26 +80,14 @@ func (database *Database) CreateJob(tx *sql.Tx, job types.Job) error {
 		job.ID = id
 	}
 
-	if job.Target == "" {
-		return errors.New("target is empty")
+	if err := validateJob(job); err != nil {
+		return fmt.Errorf("CreateJob: %w", err)
 	}
-
-	if job.Store == "" {
-		return errors.New("datastore is empty")
+	if job.MaxSize < 0 {
+		job.MaxSize = 0
 	}
-
-	if !utils.IsValidID(job.ID) && job.ID != "" {
-		return fmt.Errorf("CreateJob: invalid id string -> %s", job.ID)
-	}
-
-	if !utils.IsValidNamespace(job.Namespace) && job.Namespace != "" {
-		return fmt.Errorf("invalid namespace string: %s", job.Namespace)
-	}
-	if err := utils.ValidateOnCalendar(job.Schedule); err != nil && job.Schedule != "" {
-		return fmt.Errorf("invalid schedule string: %s", job.Schedule)
-	}
-	if !utils.IsValidPathString(job.Subpath) {
-		return fmt.Errorf("invalid subpath string: %s", job.Subpath)
+	if job.BandwidthLimit < 0 {
+		job.BandwidthLimit = 0
 	}
 
 	// Ensure retry parameters are sane.
@@ -102,17 +97,29 @@ func (database *Database) CreateJob(tx *sql.Tx, job types.Job) error {
 	if job.Retry < 0 {
 		job.Retry = 0
 	}
+	if job.RetryMultiplier < 1 {
+		job.RetryMultiplier = 1
+	}
+	if job.RetryMaxInterval < 0 {
+		job.RetryMaxInterval = 0
+	}
 
 	// Insert the job.
-	_, err := tx.Exec(`
+	_, err = tx.Exec(`
         INSERT INTO jobs (
             id, store, mode, source_mode, target, subpath, schedule, comment,
             notification_mode, namespace, current_pid, last_run_upid, last_successful_upid, retry,
-            retry_interval, raw_exclusions
-        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+            retry_interval, raw_exclusions, max_size, size_guard, serialize_store,
+            last_run_fingerprint, last_run_verify_state, run_on_checkin, pending_checkin,
+            manifest, manifest_hash, bandwidth_limit, webhook_url, create_namespace, encryption_key_file,
+            retry_multiplier, retry_max_interval, partial_files, tags, enabled, template
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `, job.ID, job.Store, job.Mode, job.SourceMode, job.Target, job.Subpath,
 		job.Schedule, job.Comment, job.NotificationMode, job.Namespace, job.CurrentPID,
-		job.LastRunUpid, job.LastSuccessfulUpid, job.Retry, job.RetryInterval, job.RawExclusions)
+		job.LastRunUpid, job.LastSuccessfulUpid, job.Retry, job.RetryInterval, job.RawExclusions,
+		job.MaxSize, job.SizeGuard, job.SerializeStore, job.LastRunFingerprint, job.LastRunVerifyState,
+		job.RunOnCheckIn, job.PendingCheckIn, job.Manifest, job.ManifestHash, job.BandwidthLimit, job.WebhookURL, job.CreateNamespace, job.EncryptionKeyFile,
+		job.RetryMultiplier, job.RetryMaxInterval, job.PartialFiles, strings.Join(job.Tags, ","), job.Enabled, job.Template)
 	if err != nil {
 		return fmt.Errorf("CreateJob: error inserting job: %w", err)
 	}
@@ -123,8 +130,14 @@ func (database *Database) CreateJob(tx *sql.Tx, job types.Job) error {
 			exclusion.JobID = job.ID
 		}
 		if err := database.CreateExclusion(tx, exclusion); err != nil {
-			syslog.L.Error(err).WithField("id", job.ID).Write()
-			continue
+			return fmt.Errorf("CreateJob: error creating exclusion %s: %w", exclusion.Path, err)
+		}
+	}
+
+	for _, inclusion := range job.Inclusions {
+		inclusion.JobID = job.ID
+		if err := database.CreateInclusion(tx, inclusion); err != nil {
+			return fmt.Errorf("CreateJob: error creating inclusion %s: %w", inclusion.Path, err)
 		}
 	}
 
@@ -135,26 +148,86 @@ func (database *Database) CreateJob(tx *sql.Tx, job types.Job) error {
 	return nil
 }
 
-// GetJob retrieves a job by id and assembles its exclusions.
-func (database *Database) GetJob(id string) (types.Job, error) {
-	row := database.readDb.QueryRow(`
-        SELECT id, store, mode, source_mode, target, subpath, schedule, comment,
-               notification_mode, namespace, current_pid, last_run_upid, last_successful_upid,
-							 retry, retry_interval, raw_exclusions
-        FROM jobs WHERE id = ?
-    `, id)
-
-	var job types.Job
+// validateJob checks the configuration of a job before it is written.
+func validateJob(job types.Job) error {
+	if job.Target == "" {
+		return errors.New("target is empty")
+	}
+	if job.Store == "" {
+		return errors.New("datastore is empty")
+	}
+	if !utils.IsValidID(job.ID) && job.ID != "" {
+		return fmt.Errorf("invalid id string -> %s", job.ID)
+	}
+	if !utils.IsValidNamespace(job.Namespace) && job.Namespace != "" {
+		return fmt.Errorf("invalid namespace string: %s", job.Namespace)
+	}
+	if err := utils.ValidateSchedules(job.Schedule); err != nil && job.Schedule != "" {
+		return fmt.Errorf("invalid schedule string: %s", job.Schedule)
+	}
+	if !utils.IsValidPathString(job.Subpath) {
+		return fmt.Errorf("invalid subpath string: %s", job.Subpath)
+	}
+	if !types.IsValidSizeGuard(job.SizeGuard) {
+		return fmt.Errorf("invalid size guard action: %s", job.SizeGuard)
+	}
+	if job.WebhookURL != "" && !utils.IsValidWebhookURL(job.WebhookURL) {
+		return fmt.Errorf("invalid webhook url: %s", job.WebhookURL)
+	}
+	for _, tag := range job.Tags {
+		if !types.IsValidTag(tag) {
+			return fmt.Errorf("invalid tag: %s", tag)
+		}
+	}
+	return nil
+}
+
+// jobColumns lists the jobs columns read by scanJob, in scan order.
+const jobColumns = `id, store, mode, source_mode, target, subpath, schedule, comment,
+	notification_mode, namespace, current_pid, last_run_upid, last_successful_upid,
+	retry, retry_interval, raw_exclusions, max_size, size_guard, serialize_store,
+	last_run_fingerprint, last_run_verify_state, run_on_checkin, pending_checkin,
+	manifest, manifest_hash, bandwidth_limit, webhook_url, create_namespace, encryption_key_file,
+	retry_multiplier, retry_max_interval, partial_files, tags, enabled, template`
+
+// scanJob reads a row selected with jobColumns into a job.
+func scanJob(row interface{ Scan(dest ...any) error }) (types.Job, error) {
+	var (
+		job  types.Job
+		tags string
+	)
 	err := row.Scan(&job.ID, &job.Store, &job.Mode, &job.SourceMode,
 		&job.Target, &job.Subpath, &job.Schedule, &job.Comment,
 		&job.NotificationMode, &job.Namespace, &job.CurrentPID, &job.LastRunUpid,
-		&job.LastSuccessfulUpid, &job.Retry, &job.RetryInterval, &job.RawExclusions)
+		&job.LastSuccessfulUpid, &job.Retry, &job.RetryInterval, &job.RawExclusions,
+		&job.MaxSize, &job.SizeGuard, &job.SerializeStore,
+		&job.LastRunFingerprint, &job.LastRunVerifyState, &job.RunOnCheckIn, &job.PendingCheckIn,
+		&job.Manifest, &job.ManifestHash, &job.BandwidthLimit, &job.WebhookURL, &job.CreateNamespace, &job.EncryptionKeyFile,
+		&job.RetryMultiplier, &job.RetryMaxInterval, &job.PartialFiles, &tags, &job.Enabled, &job.Template)
+	if err != nil {
+		return types.Job{}, err
+	}
+	job.Tags = types.ParseTags(tags)
+	return job, nil
+}
+
+// GetJob retrieves a job by id and assembles its exclusions and inclusions.
+func (database *Database) GetJob(id string) (types.Job, error) {
+	row := database.readDb.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id)
+
+	job, err := scanJob(row)
 	if err != nil {
 		return types.Job{}, fmt.Errorf("GetJob: error fetching job: %w", err)
 	}
 
 	database.getJobExtras(&job)
 
+	if history, err := database.GetJobHistory(job.ID); err == nil {
+		job.History = history
+	} else {
+		syslog.L.Error(err).WithField("id", job.ID).Write()
+	}
+
 	return job, nil
 }
 
@@ -165,11 +238,27 @@ func (database *Database) getJobExtras(job *types.Job) {
 		job.Exclusions = exclusions
 		pathSlice := []string{}
 		for _, exclusion := range exclusions {
-			pathSlice = append(pathSlice, exclusion.Path)
+			pathSlice = append(pathSlice, pattern.FormatRaw(pattern.Pattern{
+				Value:     exclusion.Path,
+				MatchType: exclusion.MatchType,
+			}))
 		}
 		job.RawExclusions = strings.Join(pathSlice, "\n")
 	}
 
+	inclusions, err := database.GetAllJobInclusions(job.ID)
+	if err == nil && inclusions != nil {
+		job.Inclusions = inclusions
+		pathSlice := []string{}
+		for _, inclusion := range inclusions {
+			pathSlice = append(pathSlice, pattern.FormatRaw(pattern.Pattern{
+				Value:     inclusion.Path,
+				MatchType: inclusion.MatchType,
+			}))
+		}
+		job.RawInclusions = strings.Join(pathSlice, "\n")
+	}
+
 	if job.LastRunUpid != "" {
 		task, err := proxmox.Session.GetTaskByUPID(job.LastRunUpid)
 		if err == nil {
@@ -193,58 +282,68 @@ func (database *Database) getJobExtras(job *types.Job) {
 	}
 }
 
-// UpdateJob updates an existing job and its exclusions.
-func (database *Database) UpdateJob(tx *sql.Tx, job types.Job) error {
+// UpdateJob updates an existing job, its exclusions and its inclusions. The
+// runtime status of the job is owned by UpdateJobStatus and is not written
+// here.
+func (database *Database) UpdateJob(tx *sql.Tx, job types.Job) (err error) {
 	if tx == nil {
 		database.writeMu.Lock()
 		defer database.writeMu.Unlock()
 
-		var err error
 		tx, err = database.writeDb.BeginTx(context.Background(), &sql.TxOptions{})
 		if err != nil {
 			return err
 		}
-		defer tx.Commit()
-	}
-
-	if !utils.IsValidID(job.ID) && job.ID != "" {
-		return fmt.Errorf("UpdateJob: invalid id string -> %s", job.ID)
-	}
-
-	if job.Target == "" {
-		return errors.New("target is empty")
+		defer func() {
+			if err != nil {
+				_ = tx.Rollback()
+				return
+			}
+			err = tx.Commit()
+		}()
 	}
 
-	if job.Store == "" {
-		return errors.New("datastore is empty")
+	if err := validateJob(job); err != nil {
+		return fmt.Errorf("UpdateJob: %w", err)
 	}
-
 	if job.RetryInterval <= 0 {
 		job.RetryInterval = 1
 	}
 	if job.Retry < 0 {
 		job.Retry = 0
 	}
-	if !utils.IsValidNamespace(job.Namespace) && job.Namespace != "" {
-		return fmt.Errorf("invalid namespace string: %s", job.Namespace)
+	if job.RetryMultiplier < 1 {
+		job.RetryMultiplier = 1
 	}
-	if err := utils.ValidateOnCalendar(job.Schedule); err != nil && job.Schedule != "" {
-		return fmt.Errorf("invalid schedule string: %s", job.Schedule)
+	if job.RetryMaxInterval < 0 {
+		job.RetryMaxInterval = 0
 	}
-	if !utils.IsValidPathString(job.Subpath) {
-		return fmt.Errorf("invalid subpath string: %s", job.Subpath)
+	if job.MaxSize < 0 {
+		job.MaxSize = 0
+	}
+	if job.BandwidthLimit < 0 {
+		job.BandwidthLimit = 0
 	}
 
-	_, err := tx.Exec(`
+	_, err = tx.Exec(`
         UPDATE jobs SET store = ?, mode = ?, source_mode = ?, target = ?,
             subpath = ?, schedule = ?, comment = ?, notification_mode = ?,
-            namespace = ?, current_pid = ?, last_run_upid = ?, retry = ?,
-            retry_interval = ?, raw_exclusions = ?, last_successful_upid = ?
+            namespace = ?, retry = ?, retry_interval = ?, raw_exclusions = ?,
+            max_size = ?, size_guard = ?, serialize_store = ?,
+            run_on_checkin = ?, pending_checkin = ?,
+            manifest = ?, manifest_hash = ?, bandwidth_limit = ?, webhook_url = ?,
+            create_namespace = ?, encryption_key_file = ?,
+            retry_multiplier = ?, retry_max_interval = ?, partial_files = ?, tags = ?, enabled = ?,
+            template = ?
         WHERE id = ?
     `, job.Store, job.Mode, job.SourceMode, job.Target, job.Subpath,
 		job.Schedule, job.Comment, job.NotificationMode, job.Namespace,
-		job.CurrentPID, job.LastRunUpid, job.Retry, job.RetryInterval,
-		job.RawExclusions, job.LastSuccessfulUpid, job.ID)
+		job.Retry, job.RetryInterval, job.RawExclusions,
+		job.MaxSize, job.SizeGuard, job.SerializeStore,
+		job.RunOnCheckIn, job.PendingCheckIn,
+		job.Manifest, job.ManifestHash, job.BandwidthLimit, job.WebhookURL, job.CreateNamespace, job.EncryptionKeyFile,
+		job.RetryMultiplier, job.RetryMaxInterval, job.PartialFiles, strings.Join(job.Tags, ","), job.Enabled,
+		job.Template, job.ID)
 	if err != nil {
 		return fmt.Errorf("UpdateJob: error updating job: %w", err)
 	}
@@ -262,8 +361,20 @@ func (database *Database) UpdateJob(tx *sql.Tx, job types.Job) error {
 			continue
 		}
 		if err := database.CreateExclusion(tx, exclusion); err != nil {
-			syslog.L.Error(err).WithField("id", job.ID).Write()
-			continue
+			return fmt.Errorf("UpdateJob: error creating exclusion %s: %w", exclusion.Path, err)
+		}
+	}
+
+	if _, err := tx.Exec(`
+        DELETE FROM inclusions WHERE job_id = ?
+    `, job.ID); err != nil {
+		return fmt.Errorf("UpdateJob: error removing old inclusions: %w", err)
+	}
+
+	for _, inclusion := range job.Inclusions {
+		inclusion.JobID = job.ID
+		if err := database.CreateInclusion(tx, inclusion); err != nil {
+			return fmt.Errorf("UpdateJob: error creating inclusion %s: %w", inclusion.Path, err)
 		}
 	}
 
@@ -271,50 +382,134 @@ func (database *Database) UpdateJob(tx *sql.Tx, job types.Job) error {
 		syslog.L.Error(err).WithField("id", job.ID).Write()
 	}
 
-	if job.LastRunUpid != "" {
-		go func() {
-			jobLogsPath := filepath.Join(constants.JobLogsBasePath, job.ID)
-			if err := os.MkdirAll(jobLogsPath, 0755); err != nil {
-				syslog.L.Error(err).WithField("id", job.ID).Write()
-			} else {
-				jobLogPath := filepath.Join(jobLogsPath, job.LastRunUpid)
-				if _, err := os.Lstat(jobLogPath); err != nil {
-					origLogPath, err := proxmox.GetLogPath(job.LastRunUpid)
-					if err != nil {
-						syslog.L.Error(err).WithField("id", job.ID).Write()
-					}
-					err = os.Symlink(origLogPath, jobLogPath)
-					if err != nil {
-						syslog.L.Error(err).WithField("id", job.ID).Write()
-					}
-				}
-			}
-		}()
+	return nil
+}
+
+// UpdateJobStatus writes the runtime status of a run to job id. Unlike
+// UpdateJob it leaves the configuration and exclusions of the job alone, so
+// edits made while the run was in progress are not overwritten.
+// LastSuccessfulUpid and LastRunFingerprint are only written when set.
+func (database *Database) UpdateJobStatus(tx *sql.Tx, id string, status types.JobStatus) error {
+	if tx == nil {
+		database.writeMu.Lock()
+		defer database.writeMu.Unlock()
+
+		var err error
+		tx, err = database.writeDb.BeginTx(context.Background(), &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Commit()
+	}
+
+	res, err := tx.Exec(`
+        UPDATE jobs SET current_pid = ?, last_run_upid = ?,
+            last_successful_upid = COALESCE(NULLIF(?, ''), last_successful_upid),
+            last_run_fingerprint = COALESCE(NULLIF(?, ''), last_run_fingerprint),
+            last_run_verify_state = ?
+        WHERE id = ?
+    `, status.CurrentPID, status.LastRunUpid, status.LastSuccessfulUpid,
+		status.LastRunFingerprint, status.LastRunVerifyState, id)
+	if err != nil {
+		return fmt.Errorf("UpdateJobStatus: error updating job: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("UpdateJobStatus: %w", sql.ErrNoRows)
+	}
+
+	if status.LastRunUpid != "" {
+		go linkJobLog(id, status.LastRunUpid)
 	}
 
 	return nil
 }
 
+// linkJobLog links the task log of upid into the log folder of job id.
+func linkJobLog(id string, upid string) {
+	jobLogsPath := filepath.Join(constants.JobLogsBasePath, id)
+	if err := os.MkdirAll(jobLogsPath, 0755); err != nil {
+		syslog.L.Error(err).WithField("id", id).Write()
+		return
+	}
+
+	jobLogPath := filepath.Join(jobLogsPath, upid)
+	if _, err := os.Lstat(jobLogPath); err != nil {
+		origLogPath, err := proxmox.GetLogPath(upid)
+		if err != nil {
+			syslog.L.Error(err).WithField("id", id).Write()
+		}
+		err = os.Symlink(origLogPath, jobLogPath)
+		if err != nil {
+			syslog.L.Error(err).WithField("id", id).Write()
+		}
+	}
+}
+
+// GetJobsByTarget returns the jobs backing up targetName. Unlike GetAllJobs
+// it does not attach exclusions, task state or schedules, so it is cheap
+// enough for checks such as whether a target is still in use.
+func (database *Database) GetJobsByTarget(targetName string) ([]types.Job, error) {
+	jobs, err := queryJobsByTarget(database.readDb, targetName)
+	if err != nil {
+		return nil, fmt.Errorf("GetJobsByTarget: %w", err)
+	}
+	return jobs, nil
+}
+
+// queryJobsByTarget runs the GetJobsByTarget query on q, a database or a
+// transaction.
+func queryJobsByTarget(q interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}, targetName string) ([]types.Job, error) {
+	rows, err := q.Query(`SELECT `+jobColumns+` FROM jobs WHERE target = ? ORDER BY id`, targetName)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []types.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
 // GetAllJobs returns all job records.
 func (database *Database) GetAllJobs() ([]types.Job, error) {
-	rows, err := database.readDb.Query(`
-			SELECT id, store, mode, source_mode, target, subpath, schedule, comment,
-						 notification_mode, namespace, current_pid, last_run_upid, last_successful_upid,
-						 retry, retry_interval, raw_exclusions
-			FROM jobs
-  `)
+	jobs, err := database.getJobs("")
+	if err != nil {
+		return nil, fmt.Errorf("GetAllJobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetJobsByTag returns the job records labelled with tag.
+func (database *Database) GetJobsByTag(tag string) ([]types.Job, error) {
+	// Tags cannot hold commas, so the comma-separated list is matched whole
+	// items at a time.
+	jobs, err := database.getJobs("WHERE instr(',' || tags || ',', ?) > 0", ","+tag+",")
 	if err != nil {
-		return nil, fmt.Errorf("GetJob: error fetching job: %w", err)
+		return nil, fmt.Errorf("GetJobsByTag: %w", err)
+	}
+	return jobs, nil
+}
+
+// getJobs returns the job records matching the where clause, along with their
+// exclusions, task state and schedules.
+func (database *Database) getJobs(where string, args ...any) ([]types.Job, error) {
+	rows, err := database.readDb.Query(`SELECT `+jobColumns+` FROM jobs `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching jobs: %w", err)
 	}
 	defer rows.Close()
 
 	var jobs []types.Job
 	for rows.Next() {
-		var job types.Job
-		err := rows.Scan(&job.ID, &job.Store, &job.Mode, &job.SourceMode,
-			&job.Target, &job.Subpath, &job.Schedule, &job.Comment,
-			&job.NotificationMode, &job.Namespace, &job.CurrentPID, &job.LastRunUpid,
-			&job.LastSuccessfulUpid, &job.Retry, &job.RetryInterval, &job.RawExclusions)
+		job, err := scanJob(rows)
 		if err != nil {
 			continue
 		}
@@ -335,7 +530,7 @@ func (database *Database) GetAllJobs() ([]types.Job, error) {
 	return jobs, nil
 }
 
-// DeleteJob deletes a job and any related exclusions.
+// DeleteJob deletes a job and any related exclusions and inclusions.
 func (database *Database) DeleteJob(tx *sql.Tx, id string) error {
 	if tx == nil {
 		database.writeMu.Lock()
@@ -354,10 +549,13 @@ func (database *Database) DeleteJob(tx *sql.Tx, id string) error {
 		return fmt.Errorf("DeleteJob: error deleting job: %w", err)
 	}
 
-	// Delete associated exclusions.
+	// Delete associated exclusions and inclusions.
 	if _, err := tx.Exec("DELETE FROM exclusions WHERE job_id = ?", id); err != nil {
 		syslog.L.Error(err).WithField("id", id).Write()
 	}
+	if _, err := tx.Exec("DELETE FROM inclusions WHERE job_id = ?", id); err != nil {
+		syslog.L.Error(err).WithField("id", id).Write()
+	}
 
 	jobLogsPath := filepath.Join(constants.JobLogsBasePath, id)
 	if err := os.RemoveAll(jobLogsPath); err != nil {
@@ -366,6 +564,10 @@ func (database *Database) DeleteJob(tx *sql.Tx, id string) error {
 		}
 	}
 
+	if err := os.RemoveAll(filepath.Join(constants.PartialFilesBasePath, id)); err != nil {
+		syslog.L.Error(err).WithField("id", id).Write()
+	}
+
 	if err := system.DeleteSchedule(id); err != nil {
 		syslog.L.Error(err).WithField("id", id).Write()
 	}
@@ -0,0 +1,263 @@
+//go:build linux
+
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/system"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/syslog"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+)
+
+// JobImportResult lists the jobs written by ImportJobs.
+type JobImportResult struct {
+	Created  []string `json:"created"`
+	Replaced []string `json:"replaced"`
+}
+
+// JobImportError tells why ImportJobs refused an import. Nothing was
+// written when it is returned.
+type JobImportError struct {
+	// Conflicts are the IDs of imported jobs that already exist.
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Invalid maps the IDs of jobs that failed validation to the reason.
+	Invalid map[string]string `json:"invalid,omitempty"`
+}
+
+func (e *JobImportError) Error() string {
+	var parts []string
+	if len(e.Invalid) > 0 {
+		ids := make([]string, 0, len(e.Invalid))
+		for id := range e.Invalid {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			parts = append(parts, fmt.Sprintf("job %q: %s", id, e.Invalid[id]))
+		}
+	}
+	if len(e.Conflicts) > 0 {
+		parts = append(parts, "jobs already exist: "+strings.Join(e.Conflicts, ", "))
+	}
+	return "import refused: " + strings.Join(parts, "; ")
+}
+
+// ExportJobs returns the configuration of every job along with its own
+// exclusions and inclusions. Runtime state is left out so the jobs can be
+// imported on another server.
+func (database *Database) ExportJobs() ([]types.Job, error) {
+	jobs, err := database.GetAllJobs()
+	if err != nil {
+		return nil, fmt.Errorf("ExportJobs: %w", err)
+	}
+
+	exported := make([]types.Job, 0, len(jobs))
+	for _, job := range jobs {
+		exclusions, err := database.GetAllJobExclusions(job.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ExportJobs: error getting exclusions of %s: %w", job.ID, err)
+		}
+		inclusions, err := database.GetAllJobInclusions(job.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ExportJobs: error getting inclusions of %s: %w", job.ID, err)
+		}
+
+		exported = append(exported, types.Job{
+			ID:                job.ID,
+			Store:             job.Store,
+			SourceMode:        job.SourceMode,
+			Mode:              job.Mode,
+			Target:            job.Target,
+			Subpath:           job.Subpath,
+			Schedule:          job.Schedule,
+			Comment:           job.Comment,
+			Tags:              job.Tags,
+			Enabled:           job.Enabled,
+			NotificationMode:  job.NotificationMode,
+			Namespace:         job.Namespace,
+			Retry:             job.Retry,
+			RetryInterval:     job.RetryInterval,
+			RetryMultiplier:   job.RetryMultiplier,
+			RetryMaxInterval:  job.RetryMaxInterval,
+			MaxSize:           job.MaxSize,
+			BandwidthLimit:    job.BandwidthLimit,
+			WebhookURL:        job.WebhookURL,
+			SizeGuard:         job.SizeGuard,
+			SerializeStore:    job.SerializeStore,
+			CreateNamespace:   job.CreateNamespace,
+			EncryptionKeyFile: job.EncryptionKeyFile,
+			PartialFiles:      job.PartialFiles,
+			RunOnCheckIn:      job.RunOnCheckIn,
+			Manifest:          job.Manifest,
+			ManifestHash:      job.ManifestHash,
+			Template:          job.Template,
+			Exclusions:        exclusions,
+			Inclusions:        inclusions,
+		})
+	}
+	return exported, nil
+}
+
+// ImportJobs creates jobs in a single transaction: either all of them are
+// written or none is. Jobs whose ID already exists are reported as conflicts
+// unless force is set, in which case they are replaced. Validation failures
+// and conflicts are returned as a *JobImportError.
+func (database *Database) ImportJobs(jobs []types.Job, force bool) (JobImportResult, error) {
+	var result JobImportResult
+
+	database.writeMu.Lock()
+	defer database.writeMu.Unlock()
+
+	existing := make(map[string]types.Job)
+	importErr := &JobImportError{Invalid: make(map[string]string)}
+	seen := make(map[string]bool)
+	for i := range jobs {
+		job := &jobs[i]
+		if job.ID == "" {
+			importErr.Invalid[fmt.Sprintf("#%d", i)] = "id is empty"
+			continue
+		}
+		if seen[job.ID] {
+			importErr.Invalid[job.ID] = "id is listed more than once"
+			continue
+		}
+		seen[job.ID] = true
+
+		if err := validateJob(*job); err != nil {
+			importErr.Invalid[job.ID] = err.Error()
+			continue
+		}
+		if job.EncryptionKeyFile != "" {
+			if err := utils.ValidateEncryptionKeyFile(job.EncryptionKeyFile); err != nil {
+				importErr.Invalid[job.ID] = err.Error()
+				continue
+			}
+		}
+
+		current, err := database.GetJob(job.ID)
+		if err == nil {
+			existing[job.ID] = current
+			if !force {
+				importErr.Conflicts = append(importErr.Conflicts, job.ID)
+			}
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return result, fmt.Errorf("ImportJobs: error looking up job %s: %w", job.ID, err)
+		}
+	}
+
+	// Exclusion paths are unique across jobs, so a path held by a job that
+	// is not replaced here would be dropped on insert.
+	owners := make(map[string]string)
+	for i := range jobs {
+		job := &jobs[i]
+		if _, invalid := importErr.Invalid[job.ID]; invalid || job.ID == "" {
+			continue
+		}
+		if err := database.checkImportedPatterns(job, owners, existing); err != nil {
+			importErr.Invalid[job.ID] = err.Error()
+		}
+	}
+
+	if len(importErr.Invalid) > 0 || len(importErr.Conflicts) > 0 {
+		return result, importErr
+	}
+
+	tx, err := database.writeDb.Begin()
+	if err != nil {
+		return result, fmt.Errorf("ImportJobs: error starting transaction: %w", err)
+	}
+
+	// Writing a job installs its timers, which the rollback cannot undo.
+	var scheduled []types.Job
+	rollback := func() {
+		_ = tx.Rollback()
+		for _, job := range scheduled {
+			var err error
+			if previous, ok := existing[job.ID]; ok {
+				err = system.SetSchedule(previous)
+			} else {
+				err = system.DeleteSchedule(job.ID)
+			}
+			if err != nil {
+				syslog.L.Error(err).WithField("id", job.ID).Write()
+			}
+		}
+	}
+
+	for _, job := range jobs {
+		if _, ok := existing[job.ID]; ok {
+			err = database.UpdateJob(tx, job)
+			result.Replaced = append(result.Replaced, job.ID)
+		} else {
+			err = database.CreateJob(tx, job)
+			result.Created = append(result.Created, job.ID)
+		}
+		scheduled = append(scheduled, job)
+		if err != nil {
+			rollback()
+			return JobImportResult{}, fmt.Errorf("ImportJobs: error writing job %s: %w", job.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		rollback()
+		return JobImportResult{}, fmt.Errorf("ImportJobs: error committing: %w", err)
+	}
+	return result, nil
+}
+
+// checkImportedPatterns validates the exclusions and inclusions of an
+// imported job, setting their job ID. owners maps the exclusion paths of the
+// jobs checked so far to their job.
+func (database *Database) checkImportedPatterns(job *types.Job, owners map[string]string, existing map[string]types.Job) error {
+	for i := range job.Exclusions {
+		exclusion := &job.Exclusions[i]
+		exclusion.JobID = job.ID
+
+		matchType, err := pattern.NormalizeMatchType(exclusion.MatchType)
+		if err != nil {
+			return err
+		}
+		exclusion.Path = pattern.NormalizePattern(exclusion.Path, matchType)
+		if err := pattern.ValidatePattern(exclusion.Path, matchType); err != nil {
+			return fmt.Errorf("invalid exclusion %s: %w", exclusion.Path, err)
+		}
+
+		if owner, ok := owners[exclusion.Path]; ok {
+			return fmt.Errorf("exclusion %s is also listed by job %s", exclusion.Path, owner)
+		}
+		owners[exclusion.Path] = job.ID
+
+		current, err := database.GetExclusion(exclusion.Path)
+		if err != nil || current == nil {
+			continue
+		}
+		if _, replaced := existing[current.JobID]; current.JobID == job.ID && replaced {
+			continue
+		}
+		if current.JobID == "" {
+			return fmt.Errorf("exclusion %s already exists as a global exclusion", exclusion.Path)
+		}
+		return fmt.Errorf("exclusion %s already belongs to job %s", exclusion.Path, current.JobID)
+	}
+
+	patterns := make([]pattern.Pattern, 0, len(job.Inclusions))
+	for i := range job.Inclusions {
+		job.Inclusions[i].JobID = job.ID
+		patterns = append(patterns, pattern.Pattern{
+			Value:     job.Inclusions[i].Path,
+			MatchType: job.Inclusions[i].MatchType,
+		})
+	}
+	if _, err := pattern.NewInclusionMatcher(patterns, false); err != nil {
+		return fmt.Errorf("invalid inclusions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS job_templates (
+  id TEXT PRIMARY KEY,
+  store TEXT,
+  mode TEXT,
+  source_mode TEXT,
+  schedule TEXT,
+  comment TEXT,
+  notification_mode TEXT,
+  namespace TEXT,
+  retry INTEGER,
+  retry_interval INTEGER
+);
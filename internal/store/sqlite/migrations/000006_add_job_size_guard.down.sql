@@ -0,0 +1,2 @@
+ALTER TABLE jobs DROP COLUMN size_guard;
+ALTER TABLE jobs DROP COLUMN max_size;
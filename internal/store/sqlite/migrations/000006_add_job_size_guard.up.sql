@@ -0,0 +1,2 @@
+ALTER TABLE jobs ADD COLUMN max_size INTEGER DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN size_guard TEXT DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN serialize_store BOOLEAN DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE jobs DROP COLUMN last_run_verify_state;
+ALTER TABLE jobs DROP COLUMN last_run_fingerprint;
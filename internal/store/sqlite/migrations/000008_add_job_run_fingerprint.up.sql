@@ -0,0 +1,2 @@
+ALTER TABLE jobs ADD COLUMN last_run_fingerprint TEXT DEFAULT '';
+ALTER TABLE jobs ADD COLUMN last_run_verify_state TEXT DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE tokens ADD COLUMN single_use BOOLEAN DEFAULT FALSE;
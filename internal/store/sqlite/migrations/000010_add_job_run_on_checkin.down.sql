@@ -0,0 +1,2 @@
+ALTER TABLE jobs DROP COLUMN pending_checkin;
+ALTER TABLE jobs DROP COLUMN run_on_checkin;
@@ -0,0 +1,2 @@
+ALTER TABLE jobs ADD COLUMN run_on_checkin BOOLEAN DEFAULT FALSE;
+ALTER TABLE jobs ADD COLUMN pending_checkin INTEGER DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE jobs DROP COLUMN manifest_hash;
+ALTER TABLE jobs DROP COLUMN manifest;
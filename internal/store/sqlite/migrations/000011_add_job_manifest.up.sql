@@ -0,0 +1,2 @@
+ALTER TABLE jobs ADD COLUMN manifest BOOLEAN DEFAULT FALSE;
+ALTER TABLE jobs ADD COLUMN manifest_hash BOOLEAN DEFAULT FALSE;
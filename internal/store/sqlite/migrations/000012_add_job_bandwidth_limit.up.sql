@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN bandwidth_limit INTEGER DEFAULT 0;
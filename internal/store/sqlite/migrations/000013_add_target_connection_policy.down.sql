@@ -0,0 +1,2 @@
+ALTER TABLE targets DROP COLUMN max_retries;
+ALTER TABLE targets DROP COLUMN connect_timeout;
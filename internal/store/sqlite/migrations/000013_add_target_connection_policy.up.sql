@@ -0,0 +1,2 @@
+ALTER TABLE targets ADD COLUMN connect_timeout INTEGER DEFAULT 0;
+ALTER TABLE targets ADD COLUMN max_retries INTEGER DEFAULT 0;
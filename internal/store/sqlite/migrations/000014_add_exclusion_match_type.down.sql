@@ -0,0 +1 @@
+ALTER TABLE exclusions DROP COLUMN match_type;
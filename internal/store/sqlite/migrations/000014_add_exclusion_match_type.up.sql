@@ -0,0 +1 @@
+ALTER TABLE exclusions ADD COLUMN match_type TEXT DEFAULT 'glob';
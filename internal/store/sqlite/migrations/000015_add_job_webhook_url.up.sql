@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN webhook_url TEXT DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE targets DROP COLUMN fail_when_busy;
+ALTER TABLE targets DROP COLUMN max_concurrency;
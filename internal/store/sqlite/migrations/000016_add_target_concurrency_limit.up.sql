@@ -0,0 +1,2 @@
+ALTER TABLE targets ADD COLUMN max_concurrency INTEGER DEFAULT 0;
+ALTER TABLE targets ADD COLUMN fail_when_busy BOOLEAN DEFAULT 0;
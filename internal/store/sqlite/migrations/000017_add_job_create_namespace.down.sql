@@ -0,0 +1 @@
+ALTER TABLE jobs DROP COLUMN create_namespace;
@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN create_namespace BOOLEAN DEFAULT 1;
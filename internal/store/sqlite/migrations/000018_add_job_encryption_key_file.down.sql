@@ -0,0 +1 @@
+ALTER TABLE jobs DROP COLUMN encryption_key_file;
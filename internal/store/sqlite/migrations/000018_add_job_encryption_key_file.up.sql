@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN encryption_key_file TEXT DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE jobs DROP COLUMN retry_max_interval;
+ALTER TABLE jobs DROP COLUMN retry_multiplier;
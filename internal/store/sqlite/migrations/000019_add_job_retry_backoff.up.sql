@@ -0,0 +1,2 @@
+ALTER TABLE jobs ADD COLUMN retry_multiplier REAL DEFAULT 1;
+ALTER TABLE jobs ADD COLUMN retry_max_interval INTEGER DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN partial_files TEXT DEFAULT '';
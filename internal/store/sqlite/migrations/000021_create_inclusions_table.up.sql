@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS inclusions (
+  job_id TEXT NOT NULL,
+  path TEXT NOT NULL,
+  comment TEXT,
+  match_type TEXT DEFAULT 'glob',
+  PRIMARY KEY (job_id, path)
+);
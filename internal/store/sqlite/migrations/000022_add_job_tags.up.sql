@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN tags TEXT DEFAULT '';
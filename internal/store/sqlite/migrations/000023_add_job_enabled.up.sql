@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN enabled BOOLEAN DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE exclusions DROP COLUMN case_sensitive;
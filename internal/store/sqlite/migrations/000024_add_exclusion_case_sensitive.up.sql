@@ -0,0 +1 @@
+ALTER TABLE exclusions ADD COLUMN case_sensitive BOOLEAN;
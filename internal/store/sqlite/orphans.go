@@ -0,0 +1,118 @@
+//go:build linux
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	_ "modernc.org/sqlite"
+)
+
+// FindOrphans reports exclusions of deleted jobs and targets that no job
+// uses.
+func (database *Database) FindOrphans() (types.Orphans, error) {
+	jobs, err := database.GetAllJobs()
+	if err != nil {
+		return types.Orphans{}, fmt.Errorf("FindOrphans: %w", err)
+	}
+
+	targets, err := database.GetAllTargets()
+	if err != nil {
+		return types.Orphans{}, fmt.Errorf("FindOrphans: %w", err)
+	}
+
+	rows, err := database.readDb.Query(`
+        SELECT job_id, path, comment, match_type, case_sensitive FROM exclusions
+        WHERE job_id IS NOT NULL AND job_id != ''
+    `)
+	if err != nil {
+		return types.Orphans{}, fmt.Errorf("FindOrphans: error fetching exclusions: %w", err)
+	}
+	defer rows.Close()
+
+	var exclusions []types.Exclusion
+	for rows.Next() {
+		var exclusion types.Exclusion
+		var matchType sql.NullString
+		var caseSensitive sql.NullBool
+		if err := rows.Scan(&exclusion.JobID, &exclusion.Path, &exclusion.Comment, &matchType, &caseSensitive); err != nil {
+			continue
+		}
+		exclusion.MatchType = exclusionMatchType(matchType)
+		exclusion.CaseSensitive = exclusionCaseSensitive(caseSensitive)
+		exclusions = append(exclusions, exclusion)
+	}
+
+	return findOrphans(jobs, targets, exclusions), nil
+}
+
+func findOrphans(jobs []types.Job, targets []types.Target, exclusions []types.Exclusion) types.Orphans {
+	jobIds := make(map[string]bool, len(jobs))
+	usedTargets := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		jobIds[job.ID] = true
+		usedTargets[job.Target] = true
+	}
+
+	orphans := types.Orphans{
+		Exclusions: []types.Exclusion{},
+		Targets:    []string{},
+	}
+	for _, exclusion := range exclusions {
+		if exclusion.JobID != "" && !jobIds[exclusion.JobID] {
+			orphans.Exclusions = append(orphans.Exclusions, exclusion)
+		}
+	}
+	for _, target := range targets {
+		if !usedTargets[target.Name] {
+			orphans.Targets = append(orphans.Targets, target.Name)
+		}
+	}
+
+	return orphans
+}
+
+// DeleteOrphans removes the orphaned exclusions and, if includeTargets is
+// set, the unused targets. Unused targets are only removed on request since
+// agents register every drive, including ones never backed up.
+func (database *Database) DeleteOrphans(tx *sql.Tx, orphans types.Orphans, includeTargets bool) error {
+	if tx == nil {
+		database.writeMu.Lock()
+		defer database.writeMu.Unlock()
+
+		var err error
+		tx, err = database.writeDb.BeginTx(context.Background(), &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Commit()
+	}
+
+	for _, exclusion := range orphans.Exclusions {
+		_, err := tx.Exec("DELETE FROM exclusions WHERE path = ? AND job_id = ?", exclusion.Path, exclusion.JobID)
+		if err != nil {
+			return fmt.Errorf("DeleteOrphans: error deleting exclusion: %w", err)
+		}
+	}
+
+	if !includeTargets {
+		return nil
+	}
+
+	for _, name := range orphans.Targets {
+		// Re-checked inside the transaction so a job created since the
+		// orphans were listed keeps its target.
+		_, err := tx.Exec(`
+            DELETE FROM targets WHERE name = ?
+            AND NOT EXISTS (SELECT 1 FROM jobs WHERE target = ?)
+        `, name, name)
+		if err != nil {
+			return fmt.Errorf("DeleteOrphans: error deleting target: %w", err)
+		}
+	}
+
+	return nil
+}
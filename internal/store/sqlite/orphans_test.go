@@ -0,0 +1,48 @@
+//go:build linux
+
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindOrphans(t *testing.T) {
+	jobs := []types.Job{
+		{ID: "daily", Target: "pve01 - C"},
+		{ID: "weekly", Target: "nas"},
+	}
+	targets := []types.Target{
+		{Name: "pve01 - C"},
+		{Name: "pve01 - D"},
+		{Name: "nas"},
+		{Name: "old-share"},
+	}
+	exclusions := []types.Exclusion{
+		{Path: "*.tmp", JobID: "daily"},
+		{Path: "cache", JobID: "deleted-job"},
+		{Path: "/proc"},
+		{Path: "node_modules", JobID: "deleted-job"},
+	}
+
+	orphans := findOrphans(jobs, targets, exclusions)
+
+	assert.Equal(t, []types.Exclusion{
+		{Path: "cache", JobID: "deleted-job"},
+		{Path: "node_modules", JobID: "deleted-job"},
+	}, orphans.Exclusions)
+	assert.Equal(t, []string{"pve01 - D", "old-share"}, orphans.Targets)
+}
+
+func TestFindOrphansNone(t *testing.T) {
+	jobs := []types.Job{{ID: "daily", Target: "pve01 - C"}}
+	targets := []types.Target{{Name: "pve01 - C"}}
+	exclusions := []types.Exclusion{{Path: "*.tmp", JobID: "daily"}, {Path: "/proc"}}
+
+	orphans := findOrphans(jobs, targets, exclusions)
+
+	assert.Empty(t, orphans.Exclusions)
+	assert.Empty(t, orphans.Targets)
+}
@@ -21,11 +21,15 @@ import (
 
 const maxAttempts = 100
 
+// DefaultPath is the database used when Initialize is given no path.
+const DefaultPath = "/etc/proxmox-backup/pbs-plus/plus.db"
+
 // Database is our SQLite-backed store.
 type Database struct {
 	readDb       *sql.DB
 	writeDb      *sql.DB
 	writeMu      sync.Mutex
+	historyMu    sync.Mutex
 	dbPath       string
 	TokenManager *token.Manager
 }
@@ -36,7 +40,7 @@ type Database struct {
 // It returns a pointer to a Database instance.
 func Initialize(dbPath string) (*Database, error) {
 	if dbPath == "" {
-		dbPath = "/etc/proxmox-backup/pbs-plus/plus.db"
+		dbPath = DefaultPath
 	}
 
 	initialized := false
@@ -5,6 +5,7 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -13,6 +14,9 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// ErrTargetInUse is returned when deleting a target that jobs still back up.
+var ErrTargetInUse = errors.New("target is used by jobs")
+
 // CreateTarget inserts a new target.
 func (database *Database) CreateTarget(tx *sql.Tx, target types.Target) error {
 	if tx == nil {
@@ -30,23 +34,42 @@ func (database *Database) CreateTarget(tx *sql.Tx, target types.Target) error {
 	if target.Path == "" {
 		return fmt.Errorf("target path empty")
 	}
-	if !utils.ValidateTargetPath(target.Path) {
-		return fmt.Errorf("invalid target path: %s", target.Path)
+	if err := utils.CheckTargetPath(target.Path); err != nil {
+		return fmt.Errorf("invalid target path: %w", err)
+	}
+	if target.ConnectTimeout < 0 {
+		target.ConnectTimeout = 0
+	}
+	if target.MaxRetries < 0 {
+		target.MaxRetries = 0
+	}
+	if target.MaxConcurrency < 0 {
+		target.MaxConcurrency = 0
 	}
 
 	_, err := tx.Exec(`
         INSERT INTO targets (name, path, auth, token_used, drive_type, drive_name, drive_fs, drive_total_bytes,
-					drive_used_bytes, drive_free_bytes, drive_total, drive_used, drive_free)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+					drive_used_bytes, drive_free_bytes, drive_total, drive_used, drive_free,
+					connect_timeout, max_retries, max_concurrency, fail_when_busy)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `,
 		target.Name, target.Path, target.Auth, target.TokenUsed,
 		target.DriveType, target.DriveName, target.DriveFS,
 		target.DriveTotalBytes, target.DriveUsedBytes, target.DriveFreeBytes,
 		target.DriveTotal, target.DriveUsed, target.DriveFree,
+		target.ConnectTimeout, target.MaxRetries,
+		target.MaxConcurrency, target.FailWhenBusy,
 	)
 	if err != nil {
 		// If the target already exists, update it.
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			// Agents re-register their drives without knowing the
+			// connection settings of their targets; keep the stored ones.
+			if target.ConnectTimeout == 0 && target.MaxRetries == 0 &&
+				target.MaxConcurrency == 0 && !target.FailWhenBusy {
+				_ = tx.QueryRow(`SELECT connect_timeout, max_retries, max_concurrency, fail_when_busy FROM targets WHERE name = ?`, target.Name).
+					Scan(&target.ConnectTimeout, &target.MaxRetries, &target.MaxConcurrency, &target.FailWhenBusy)
+			}
 			return database.UpdateTarget(tx, target)
 		}
 		return fmt.Errorf("CreateTarget: error inserting target: %w", err)
@@ -71,8 +94,17 @@ func (database *Database) UpdateTarget(tx *sql.Tx, target types.Target) error {
 	if target.Path == "" {
 		return fmt.Errorf("target path empty")
 	}
-	if !utils.ValidateTargetPath(target.Path) {
-		return fmt.Errorf("invalid target path: %s", target.Path)
+	if err := utils.CheckTargetPath(target.Path); err != nil {
+		return fmt.Errorf("invalid target path: %w", err)
+	}
+	if target.ConnectTimeout < 0 {
+		target.ConnectTimeout = 0
+	}
+	if target.MaxRetries < 0 {
+		target.MaxRetries = 0
+	}
+	if target.MaxConcurrency < 0 {
+		target.MaxConcurrency = 0
 	}
 
 	_, err := tx.Exec(`
@@ -80,13 +112,16 @@ func (database *Database) UpdateTarget(tx *sql.Tx, target types.Target) error {
 					path = ?, auth = ?, token_used = ?, drive_type = ?,
 					drive_name = ?, drive_fs = ?, drive_total_bytes = ?,
 					drive_used_bytes = ?, drive_free_bytes = ?, drive_total = ?,
-					drive_used = ?, drive_free = ?
+					drive_used = ?, drive_free = ?, connect_timeout = ?, max_retries = ?,
+					max_concurrency = ?, fail_when_busy = ?
         WHERE name = ?
     `,
 		target.Path, target.Auth, target.TokenUsed,
 		target.DriveType, target.DriveName, target.DriveFS,
 		target.DriveTotalBytes, target.DriveUsedBytes, target.DriveFreeBytes,
-		target.DriveTotal, target.DriveUsed, target.DriveFree, target.Name,
+		target.DriveTotal, target.DriveUsed, target.DriveFree,
+		target.ConnectTimeout, target.MaxRetries,
+		target.MaxConcurrency, target.FailWhenBusy, target.Name,
 	)
 	if err != nil {
 		return fmt.Errorf("UpdateTarget: error updating target: %w", err)
@@ -94,7 +129,8 @@ func (database *Database) UpdateTarget(tx *sql.Tx, target types.Target) error {
 	return nil
 }
 
-// DeleteTarget removes a target.
+// DeleteTarget removes a target. Targets still referenced by a job are
+// kept and ErrTargetInUse is returned.
 func (database *Database) DeleteTarget(tx *sql.Tx, name string) error {
 	if tx == nil {
 		database.writeMu.Lock()
@@ -108,7 +144,19 @@ func (database *Database) DeleteTarget(tx *sql.Tx, name string) error {
 		defer tx.Commit()
 	}
 
-	_, err := tx.Exec("DELETE FROM targets WHERE name = ?", name)
+	jobs, err := queryJobsByTarget(tx, name)
+	if err != nil {
+		return fmt.Errorf("DeleteTarget: %w", err)
+	}
+	if len(jobs) > 0 {
+		ids := make([]string, 0, len(jobs))
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		return fmt.Errorf("DeleteTarget: %w: %s", ErrTargetInUse, strings.Join(ids, ", "))
+	}
+
+	_, err = tx.Exec("DELETE FROM targets WHERE name = ?", name)
 	if err != nil {
 		return fmt.Errorf("DeleteTarget: error deleting target: %w", err)
 	}
@@ -119,7 +167,8 @@ func (database *Database) DeleteTarget(tx *sql.Tx, name string) error {
 func (database *Database) GetTarget(name string) (types.Target, error) {
 	row := database.readDb.QueryRow(`
         SELECT name, path, auth, token_used, drive_type, drive_name, drive_fs, drive_total_bytes,
-					drive_used_bytes, drive_free_bytes, drive_total, drive_used, drive_free FROM targets
+					drive_used_bytes, drive_free_bytes, drive_total, drive_used, drive_free,
+			connect_timeout, max_retries, max_concurrency, fail_when_busy FROM targets
         WHERE name = ?
     `, name)
 	var target types.Target
@@ -128,13 +177,15 @@ func (database *Database) GetTarget(name string) (types.Target, error) {
 		&target.DriveType, &target.DriveName, &target.DriveFS,
 		&target.DriveTotalBytes, &target.DriveUsedBytes, &target.DriveFreeBytes,
 		&target.DriveTotal, &target.DriveUsed, &target.DriveFree,
+		&target.ConnectTimeout, &target.MaxRetries,
+		&target.MaxConcurrency, &target.FailWhenBusy,
 	)
 	if err != nil {
 		return types.Target{}, fmt.Errorf("GetTarget: error fetching target: %w", err)
 	}
 
 	// Adjust fields based on target.Path.
-	if strings.HasPrefix(target.Path, "agent://") {
+	if utils.IsAgentPath(target.Path) {
 		target.IsAgent = true
 	} else {
 		target.ConnectionStatus = utils.IsValid(target.Path)
@@ -147,7 +198,8 @@ func (database *Database) GetTarget(name string) (types.Target, error) {
 func (database *Database) GetAllTargets() ([]types.Target, error) {
 	rows, err := database.readDb.Query(`
 		SELECT name, path, auth, token_used, drive_type, drive_name, drive_fs, drive_total_bytes,
-			drive_used_bytes, drive_free_bytes, drive_total, drive_used, drive_free FROM targets
+			drive_used_bytes, drive_free_bytes, drive_total, drive_used, drive_free,
+			connect_timeout, max_retries, max_concurrency, fail_when_busy FROM targets
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("GetAllTargets: error querying targets: %w", err)
@@ -162,12 +214,14 @@ func (database *Database) GetAllTargets() ([]types.Target, error) {
 			&target.DriveType, &target.DriveName, &target.DriveFS,
 			&target.DriveTotalBytes, &target.DriveUsedBytes, &target.DriveFreeBytes,
 			&target.DriveTotal, &target.DriveUsed, &target.DriveFree,
+			&target.ConnectTimeout, &target.MaxRetries,
+			&target.MaxConcurrency, &target.FailWhenBusy,
 		)
 		if err != nil {
 			continue
 		}
 
-		if strings.HasPrefix(target.Path, "agent://") {
+		if utils.IsAgentPath(target.Path) {
 			target.IsAgent = true
 		} else {
 			target.ConnectionStatus = utils.IsValid(target.Path)
@@ -183,9 +237,10 @@ func (database *Database) GetAllTargets() ([]types.Target, error) {
 func (database *Database) GetAllTargetsByIP(clientIP string) ([]types.Target, error) {
 	rows, err := database.readDb.Query(`
 		SELECT name, path, auth, token_used, drive_type, drive_name, drive_fs, drive_total_bytes,
-			drive_used_bytes, drive_free_bytes, drive_total, drive_used, drive_free FROM targets
+			drive_used_bytes, drive_free_bytes, drive_total, drive_used, drive_free,
+			connect_timeout, max_retries, max_concurrency, fail_when_busy FROM targets
 		WHERE path LIKE ?
-		`, fmt.Sprintf("agent://%s%%", clientIP))
+		`, utils.AgentScheme+clientIP+"%")
 	if err != nil {
 		return nil, fmt.Errorf("GetAllTargets: error querying targets: %w", err)
 	}
@@ -199,17 +254,20 @@ func (database *Database) GetAllTargetsByIP(clientIP string) ([]types.Target, er
 			&target.DriveType, &target.DriveName, &target.DriveFS,
 			&target.DriveTotalBytes, &target.DriveUsedBytes, &target.DriveFreeBytes,
 			&target.DriveTotal, &target.DriveUsed, &target.DriveFree,
+			&target.ConnectTimeout, &target.MaxRetries,
+			&target.MaxConcurrency, &target.FailWhenBusy,
 		)
 		if err != nil {
 			continue
 		}
 
-		if strings.HasPrefix(target.Path, "agent://") {
-			target.IsAgent = true
-		} else {
-			target.ConnectionStatus = utils.IsValid(target.Path)
-			target.IsAgent = false
+		// LIKE only narrows the rows down, it cannot tell a host apart
+		// from a longer one sharing its prefix.
+		agentTarget, err := utils.ParseAgentTarget(target.Path)
+		if err != nil || agentTarget.Host != clientIP {
+			continue
 		}
+		target.IsAgent = true
 
 		targets = append(targets, target)
 	}
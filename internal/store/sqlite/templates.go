@@ -0,0 +1,226 @@
+//go:build linux
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	_ "modernc.org/sqlite"
+)
+
+// CreateJobTemplate inserts a new job template.
+func (database *Database) CreateJobTemplate(tx *sql.Tx, template types.JobTemplate) error {
+	if tx == nil {
+		database.writeMu.Lock()
+		defer database.writeMu.Unlock()
+
+		var err error
+		tx, err = database.writeDb.BeginTx(context.Background(), &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Commit()
+	}
+
+	if err := validateJobTemplate(template); err != nil {
+		return fmt.Errorf("CreateJobTemplate: %w", err)
+	}
+
+	_, err := tx.Exec(`
+        INSERT INTO job_templates (
+            id, store, mode, source_mode, schedule, comment,
+            notification_mode, namespace, retry, retry_interval
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, template.ID, template.Store, template.Mode, template.SourceMode,
+		template.Schedule, template.Comment, template.NotificationMode,
+		template.Namespace, template.Retry, template.RetryInterval)
+	if err != nil {
+		return fmt.Errorf("CreateJobTemplate: error inserting template: %w", err)
+	}
+
+	return nil
+}
+
+// GetJobTemplate retrieves a job template by id.
+func (database *Database) GetJobTemplate(id string) (types.JobTemplate, error) {
+	row := database.readDb.QueryRow(`
+        SELECT id, store, mode, source_mode, schedule, comment,
+               notification_mode, namespace, retry, retry_interval
+        FROM job_templates WHERE id = ?
+    `, id)
+
+	var template types.JobTemplate
+	err := row.Scan(&template.ID, &template.Store, &template.Mode, &template.SourceMode,
+		&template.Schedule, &template.Comment, &template.NotificationMode,
+		&template.Namespace, &template.Retry, &template.RetryInterval)
+	if err != nil {
+		return types.JobTemplate{}, fmt.Errorf("GetJobTemplate: error fetching template: %w", err)
+	}
+
+	return template, nil
+}
+
+// GetAllJobTemplates returns all job templates.
+func (database *Database) GetAllJobTemplates() ([]types.JobTemplate, error) {
+	rows, err := database.readDb.Query(`
+        SELECT id, store, mode, source_mode, schedule, comment,
+               notification_mode, namespace, retry, retry_interval
+        FROM job_templates
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("GetAllJobTemplates: error fetching templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []types.JobTemplate
+	for rows.Next() {
+		var template types.JobTemplate
+		err := rows.Scan(&template.ID, &template.Store, &template.Mode, &template.SourceMode,
+			&template.Schedule, &template.Comment, &template.NotificationMode,
+			&template.Namespace, &template.Retry, &template.RetryInterval)
+		if err != nil {
+			continue
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+// UpdateJobTemplate updates an existing job template. Jobs that were created
+// from the template keep the values they were created with.
+func (database *Database) UpdateJobTemplate(tx *sql.Tx, template types.JobTemplate) error {
+	if tx == nil {
+		database.writeMu.Lock()
+		defer database.writeMu.Unlock()
+
+		var err error
+		tx, err = database.writeDb.BeginTx(context.Background(), &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Commit()
+	}
+
+	if err := validateJobTemplate(template); err != nil {
+		return fmt.Errorf("UpdateJobTemplate: %w", err)
+	}
+
+	res, err := tx.Exec(`
+        UPDATE job_templates SET store = ?, mode = ?, source_mode = ?,
+            schedule = ?, comment = ?, notification_mode = ?, namespace = ?,
+            retry = ?, retry_interval = ?
+        WHERE id = ?
+    `, template.Store, template.Mode, template.SourceMode, template.Schedule,
+		template.Comment, template.NotificationMode, template.Namespace,
+		template.Retry, template.RetryInterval, template.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateJobTemplate: error updating template: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return fmt.Errorf("UpdateJobTemplate: template not found: %s", template.ID)
+	}
+
+	return nil
+}
+
+// DeleteJobTemplate deletes a job template.
+func (database *Database) DeleteJobTemplate(tx *sql.Tx, id string) error {
+	if tx == nil {
+		database.writeMu.Lock()
+		defer database.writeMu.Unlock()
+
+		var err error
+		tx, err = database.writeDb.BeginTx(context.Background(), &sql.TxOptions{})
+		if err != nil {
+			return err
+		}
+		defer tx.Commit()
+	}
+
+	res, err := tx.Exec("DELETE FROM job_templates WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("DeleteJobTemplate: error deleting template: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return fmt.Errorf("DeleteJobTemplate: template not found: %s", id)
+	}
+
+	return nil
+}
+
+func validateJobTemplate(template types.JobTemplate) error {
+	if template.ID == "" {
+		return errors.New("template id is empty")
+	}
+	if !utils.IsValidID(template.ID) {
+		return fmt.Errorf("invalid id string -> %s", template.ID)
+	}
+	if !utils.IsValidNamespace(template.Namespace) && template.Namespace != "" {
+		return fmt.Errorf("invalid namespace string: %s", template.Namespace)
+	}
+	if err := utils.ValidateSchedules(template.Schedule); err != nil && template.Schedule != "" {
+		return fmt.Errorf("invalid schedule string: %s", template.Schedule)
+	}
+	return nil
+}
+
+// ApplyJobTemplate fills the settings of job that are not in given, keyed by
+// their form keys (types.JobSetting*), from its template. Given settings are
+// kept even when empty, so a job can override a template with a zero value.
+// A job that does not name a template inherits from the default template if
+// one exists. Values are copied at creation time; the job does not keep a
+// live reference to the template, only its name, stored with the job as a
+// record of where its values came from.
+func (database *Database) ApplyJobTemplate(job *types.Job, given map[string]bool) error {
+	templateId := job.Template
+	if templateId == "" {
+		templateId = constants.DefaultJobTemplate
+	}
+
+	template, err := database.GetJobTemplate(templateId)
+	if err != nil {
+		if job.Template == "" && errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	job.Template = template.ID
+
+	if !given[types.JobSettingStore] {
+		job.Store = template.Store
+	}
+	if !given[types.JobSettingMode] {
+		job.Mode = template.Mode
+	}
+	if !given[types.JobSettingSourceMode] {
+		job.SourceMode = template.SourceMode
+	}
+	if !given[types.JobSettingSchedule] {
+		job.Schedule = template.Schedule
+	}
+	if !given[types.JobSettingComment] {
+		job.Comment = template.Comment
+	}
+	if !given[types.JobSettingNotificationMode] {
+		job.NotificationMode = template.NotificationMode
+	}
+	if !given[types.JobSettingNamespace] {
+		job.Namespace = template.Namespace
+	}
+	if !given[types.JobSettingRetry] {
+		job.Retry = template.Retry
+	}
+	if !given[types.JobSettingRetryInterval] {
+		job.RetryInterval = template.RetryInterval
+	}
+
+	return nil
+}
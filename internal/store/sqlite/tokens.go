@@ -11,8 +11,9 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-// CreateToken generates a new token using the manager and stores it.
-func (database *Database) CreateToken(comment string) error {
+// CreateToken generates a new token using the manager and stores it. A
+// single-use token is revoked by the first bootstrap that uses it.
+func (database *Database) CreateToken(comment string, singleUse bool) error {
 	database.writeMu.Lock()
 	defer database.writeMu.Unlock()
 
@@ -22,9 +23,9 @@ func (database *Database) CreateToken(comment string) error {
 	}
 	now := time.Now().Unix()
 	_, err = database.writeDb.Exec(`
-        INSERT INTO tokens (token, comment, created_at, revoked)
-        VALUES (?, ?, ?, ?)
-    `, tokenStr, comment, now, false)
+        INSERT INTO tokens (token, comment, created_at, revoked, single_use)
+        VALUES (?, ?, ?, ?, ?)
+    `, tokenStr, comment, now, false, singleUse)
 	if err != nil {
 		return fmt.Errorf("CreateToken: error inserting token: %w", err)
 	}
@@ -34,11 +35,11 @@ func (database *Database) CreateToken(comment string) error {
 // GetToken retrieves a token’s entry and double-checks its validity.
 func (database *Database) GetToken(tokenStr string) (types.AgentToken, error) {
 	row := database.readDb.QueryRow(`
-        SELECT token, comment, created_at, revoked FROM tokens WHERE token = ?
+        SELECT token, comment, created_at, revoked, single_use FROM tokens WHERE token = ?
     `, tokenStr)
 	var tokenProp types.AgentToken
 	err := row.Scan(&tokenProp.Token, &tokenProp.Comment, &tokenProp.CreatedAt,
-		&tokenProp.Revoked)
+		&tokenProp.Revoked, &tokenProp.SingleUse)
 	if err != nil {
 		return types.AgentToken{}, fmt.Errorf("GetToken: error fetching token: %w", err)
 	}
@@ -73,7 +74,8 @@ func (database *Database) GetAllTokens() ([]types.AgentToken, error) {
 	return tokens, nil
 }
 
-// RevokeToken marks a token as revoked.
+// RevokeToken marks a token as revoked and adds it to the revocation list
+// of the token manager.
 func (database *Database) RevokeToken(tokenData types.AgentToken) error {
 	database.writeMu.Lock()
 	defer database.writeMu.Unlock()
@@ -89,5 +91,45 @@ func (database *Database) RevokeToken(tokenData types.AgentToken) error {
 	if err != nil {
 		return fmt.Errorf("RevokeToken: error updating token: %w", err)
 	}
+	if err := database.revokeTokenID(tokenData.Token); err != nil {
+		return fmt.Errorf("RevokeToken: %w", err)
+	}
+	return nil
+}
+
+// revokeTokenID adds tokenStr to the revocation list of the token manager,
+// which rejects it wherever it is validated.
+func (database *Database) revokeTokenID(tokenStr string) error {
+	if database.TokenManager == nil {
+		return nil
+	}
+
+	id, err := database.TokenManager.TokenID(tokenStr)
+	if err != nil || id == "" {
+		// Tokens issued without an ID can only be revoked in the table.
+		return nil
+	}
+	return database.TokenManager.Revoke(id)
+}
+
+// ConsumeToken revokes a single-use token. It fails if the token was already
+// revoked, so only one caller can ever consume it.
+func (database *Database) ConsumeToken(tokenStr string) error {
+	database.writeMu.Lock()
+	defer database.writeMu.Unlock()
+
+	res, err := database.writeDb.Exec(`
+        UPDATE tokens SET revoked = ? WHERE token = ? AND single_use = ? AND revoked = ?
+    `, true, tokenStr, true, false)
+	if err != nil {
+		return fmt.Errorf("ConsumeToken: error updating token: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return fmt.Errorf("ConsumeToken: token already used or not single-use: %s", tokenStr)
+	}
+	if err := database.revokeTokenID(tokenStr); err != nil {
+		syslog.L.Error(err).WithMessage("failed to add consumed token to revocation list").Write()
+	}
 	return nil
 }
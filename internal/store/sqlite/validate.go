@@ -0,0 +1,183 @@
+//go:build linux
+
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils/pattern"
+)
+
+// RecordResult is the outcome of validating a single stored record.
+type RecordResult struct {
+	Kind string // "job", "target", "exclusion" or "inclusion"
+	ID   string
+	Err  error
+}
+
+// ValidationReport lists the records checked by ValidateStore.
+type ValidationReport struct {
+	Records []RecordResult
+}
+
+// Failed returns the number of records that did not validate.
+func (r ValidationReport) Failed() int {
+	failed := 0
+	for _, record := range r.Records {
+		if record.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// ValidateStore checks the jobs, targets, exclusions and inclusions stored in
+// the database at dbPath with the validations applied when they are written.
+// The database is opened read-only: it is neither created nor migrated, so
+// a database with an outdated schema is reported as an error. dbPath defaults
+// to DefaultPath when empty.
+func ValidateStore(dbPath string) (ValidationReport, error) {
+	if dbPath == "" {
+		dbPath = DefaultPath
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return ValidationReport{}, fmt.Errorf("ValidateStore: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath+"?mode=ro")
+	if err != nil {
+		return ValidationReport{}, fmt.Errorf("ValidateStore: error opening DB: %w", err)
+	}
+	defer db.Close()
+
+	var report ValidationReport
+	checks := []func(*sql.DB) ([]RecordResult, error){
+		validateStoredJobs,
+		validateStoredTargets,
+		validateStoredExclusions,
+		validateStoredInclusions,
+	}
+	for _, check := range checks {
+		records, err := check(db)
+		if err != nil {
+			return report, fmt.Errorf("ValidateStore: %w", err)
+		}
+		report.Records = append(report.Records, records...)
+	}
+	return report, nil
+}
+
+func validateStoredJobs(db *sql.DB) ([]RecordResult, error) {
+	rows, err := db.Query(`SELECT ` + jobColumns + ` FROM jobs ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var records []RecordResult
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err == nil {
+			err = validateJob(job)
+		}
+		records = append(records, RecordResult{Kind: "job", ID: job.ID, Err: err})
+	}
+	return records, rows.Err()
+}
+
+func validateStoredTargets(db *sql.DB) ([]RecordResult, error) {
+	rows, err := db.Query(`SELECT name, path FROM targets ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching targets: %w", err)
+	}
+	defer rows.Close()
+
+	var records []RecordResult
+	for rows.Next() {
+		var name, path string
+		err := rows.Scan(&name, &path)
+		switch {
+		case err != nil:
+		case path == "":
+			err = errors.New("target path empty")
+		default:
+			if pathErr := utils.CheckTargetPath(path); pathErr != nil {
+				err = fmt.Errorf("invalid target path: %w", pathErr)
+			}
+		}
+		records = append(records, RecordResult{Kind: "target", ID: name, Err: err})
+	}
+	return records, rows.Err()
+}
+
+func validateStoredExclusions(db *sql.DB) ([]RecordResult, error) {
+	rows, err := db.Query(`SELECT job_id, path, match_type FROM exclusions ORDER BY job_id, path`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching exclusions: %w", err)
+	}
+	defer rows.Close()
+
+	var records []RecordResult
+	for rows.Next() {
+		var jobID, matchType sql.NullString
+		var path string
+		err := rows.Scan(&jobID, &path, &matchType)
+		if err == nil {
+			err = validateStoredPattern(path, exclusionMatchType(matchType))
+		}
+		records = append(records, RecordResult{Kind: "exclusion", ID: patternRecordID(jobID.String, path), Err: err})
+	}
+	return records, rows.Err()
+}
+
+func validateStoredInclusions(db *sql.DB) ([]RecordResult, error) {
+	rows, err := db.Query(`SELECT job_id, path, match_type FROM inclusions ORDER BY job_id, path`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching inclusions: %w", err)
+	}
+	defer rows.Close()
+
+	var records []RecordResult
+	for rows.Next() {
+		var jobID, matchType sql.NullString
+		var path string
+		err := rows.Scan(&jobID, &path, &matchType)
+		if err == nil {
+			if strings.HasPrefix(path, "!") {
+				err = fmt.Errorf("inclusion %s cannot be negated", path)
+			} else {
+				err = validateStoredPattern(path, exclusionMatchType(matchType))
+			}
+		}
+		records = append(records, RecordResult{Kind: "inclusion", ID: patternRecordID(jobID.String, path), Err: err})
+	}
+	return records, rows.Err()
+}
+
+func validateStoredPattern(path, matchType string) error {
+	if path == "" {
+		return errors.New("path is empty")
+	}
+	matchType, err := pattern.NormalizeMatchType(matchType)
+	if err != nil {
+		return err
+	}
+	if err := pattern.ValidatePattern(path, matchType); err != nil {
+		return fmt.Errorf("invalid path pattern -> %s: %w", path, err)
+	}
+	return nil
+}
+
+// patternRecordID names an exclusion or inclusion by its job, if any, and
+// its path.
+func patternRecordID(jobID, path string) string {
+	if jobID == "" {
+		return path
+	}
+	return jobID + ": " + path
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jobInsertColumns are the job columns without a default value.
+const jobInsertColumns = `id, store, target, schedule, subpath, comment, notification_mode, namespace, current_pid, last_run_upid, last_successful_upid, retry, retry_interval, raw_exclusions`
+
+// seedValidationDB creates a database holding one valid and one malformed
+// record of each kind. The rows are inserted directly, as a hand edit or an
+// older release would have left them, bypassing the write validations.
+func seedValidationDB(t *testing.T) string {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "plus.db")
+	database, err := Initialize(dbPath)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		database.readDb.Close()
+		database.writeDb.Close()
+	})
+
+	_, err = database.writeDb.Exec(`DELETE FROM exclusions`)
+	require.NoError(t, err)
+
+	statements := []string{
+		`INSERT INTO targets (name, path) VALUES ('nas', '/mnt/nas')`,
+		`INSERT INTO targets (name, path) VALUES ('relative', 'mnt/nas')`,
+		`INSERT INTO jobs (` + jobInsertColumns + `) VALUES ('daily', 'local', 'nas', 'daily', '', '', '', '', 0, '', '', 0, 1, '')`,
+		`INSERT INTO jobs (` + jobInsertColumns + `) VALUES ('broken', 'local', 'nas', 'every other tuesday', '', '', '', '', 0, '', '', 0, 1, '')`,
+		`INSERT INTO exclusions (job_id, path, match_type) VALUES ('daily', '*.tmp', 'glob')`,
+		`INSERT INTO exclusions (job_id, path, match_type) VALUES ('daily', '([a-z', 'regex')`,
+		`INSERT INTO inclusions (job_id, path, match_type) VALUES ('daily', 'home/**', 'glob')`,
+		`INSERT INTO inclusions (job_id, path, match_type) VALUES ('daily', '!home/cache', 'glob')`,
+	}
+	for _, statement := range statements {
+		_, err := database.writeDb.Exec(statement)
+		require.NoError(t, err, statement)
+	}
+	return dbPath
+}
+
+func TestValidateStore(t *testing.T) {
+	dbPath := seedValidationDB(t)
+
+	report, err := ValidateStore(dbPath)
+	require.NoError(t, err)
+
+	results := map[string]error{}
+	for _, record := range report.Records {
+		results[record.Kind+" "+record.ID] = record.Err
+	}
+	require.Len(t, results, 8)
+
+	for _, valid := range []string{"job daily", "target nas", "exclusion daily: *.tmp", "inclusion daily: home/**"} {
+		assert.NoError(t, results[valid], valid)
+	}
+	assert.ErrorContains(t, results["job broken"], "invalid schedule string")
+	assert.ErrorContains(t, results["target relative"], "invalid target path")
+	assert.ErrorContains(t, results["exclusion daily: ([a-z"], "invalid path pattern")
+	assert.ErrorContains(t, results["inclusion daily: !home/cache"], "cannot be negated")
+	assert.Equal(t, 4, report.Failed())
+
+	_, err = ValidateStore(filepath.Join(t.TempDir(), "missing.db"))
+	assert.Error(t, err, "a missing database is not created")
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package system
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alexflint/go-filemutex"
+)
+
+// ErrInstanceRunning is returned when another pbs-plus server holds the
+// instance lock.
+var ErrInstanceRunning = errors.New("another pbs-plus instance is already running")
+
+// AcquireInstanceLock takes an exclusive flock on path so that only one
+// server process runs at a time. The lock is released by closing the
+// returned mutex or when the process exits.
+func AcquireInstanceLock(path string) (*filemutex.FileMutex, error) {
+	mutex, err := filemutex.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("AcquireInstanceLock: failed to create lock file %s: %w", path, err)
+	}
+
+	if err := mutex.TryLock(); err != nil {
+		_ = mutex.Close()
+		if errors.Is(err, filemutex.AlreadyLocked) {
+			return nil, fmt.Errorf("%w (lock file: %s)", ErrInstanceRunning, path)
+		}
+		return nil, fmt.Errorf("AcquireInstanceLock: failed to lock %s: %w", path, err)
+	}
+
+	return mutex, nil
+}
@@ -0,0 +1,33 @@
+//go:build linux
+
+package system
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestAcquireInstanceLockTwice(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "pbs-plus.lock")
+
+	first, err := AcquireInstanceLock(lockPath)
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+
+	if _, err := AcquireInstanceLock(lockPath); !errors.Is(err, ErrInstanceRunning) {
+		t.Fatalf("expected ErrInstanceRunning for second acquire, got %v", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("failed to release lock: %v", err)
+	}
+
+	// Once released, a new instance can start.
+	second, err := AcquireInstanceLock(lockPath)
+	if err != nil {
+		t.Fatalf("acquire after release failed: %v", err)
+	}
+	_ = second.Close()
+}
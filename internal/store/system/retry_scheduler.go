@@ -4,10 +4,10 @@ package system
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
@@ -114,8 +114,36 @@ func RemoveAllRetrySchedules(job types.Job) {
 	_ = cmd.Run()
 }
 
+// RetryDelay returns how long to wait before retry attempt of job, counted
+// from 1. The first retry waits RetryInterval minutes and every further one
+// RetryMultiplier times longer than the previous, up to RetryMaxInterval
+// minutes when set.
+func RetryDelay(job types.Job, attempt int) time.Duration {
+	base := time.Duration(max(job.RetryInterval, 1)) * time.Minute
+	multiplier := max(job.RetryMultiplier, 1)
+	limit := time.Duration(job.RetryMaxInterval) * time.Minute
+
+	delay := float64(base) * math.Pow(multiplier, float64(max(attempt, 1)-1))
+	if limit > 0 && delay > float64(limit) {
+		return limit
+	}
+	if delay > float64(math.MaxInt64) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
+
+// NextRetryAttempt returns the attempt to schedule after job.RetryAttempt
+// failed, and whether its policy allows one more.
+func NextRetryAttempt(job types.Job) (int, bool) {
+	next := max(job.RetryAttempt, 0) + 1
+	return next, next <= job.Retry
+}
+
+// SetRetrySchedule schedules the retry following the failed attempt
+// job.RetryAttempt, replacing any retry scheduled before. Once the attempts
+// of the job are used up, all its retries are removed instead.
 func SetRetrySchedule(job types.Job) error {
-	maxRetry := job.Retry
 	retryPattern := filepath.Join(
 		constants.TimerBasePath,
 		fmt.Sprintf("pbs-plus-job-%s-retry-*.timer",
@@ -126,26 +154,10 @@ func SetRetrySchedule(job types.Job) error {
 		return fmt.Errorf("SetRetrySchedule: error globbing retry timer files: %w", err)
 	}
 
-	// Determine the current highest attempt number.
-	currentAttempt := 0
-	for _, file := range retryFiles {
-		base := filepath.Base(file) // e.g. "pbs-plus-job-<jobID>-retry-1.timer"
-		idx := strings.Index(base, "retry-")
-		if idx < 0 {
-			continue
-		}
-		attemptStrWithSuffix := base[idx+len("retry-"):]
-		attemptStr := strings.TrimSuffix(attemptStrWithSuffix, ".timer")
-		if attemptInt, err := strconv.Atoi(attemptStr); err == nil {
-			if attemptInt > currentAttempt {
-				currentAttempt = attemptInt
-			}
-		}
-	}
-	newAttempt := currentAttempt + 1
-	if newAttempt > maxRetry {
+	newAttempt, ok := NextRetryAttempt(job)
+	if !ok {
 		fmt.Printf("Job %s reached max retry count (%d). No further retry scheduled.\n",
-			job.ID, maxRetry)
+			job.ID, job.Retry)
 		RemoveAllRetrySchedules(job)
 		return nil
 	}
@@ -179,7 +191,7 @@ func SetRetrySchedule(job types.Job) error {
 	}
 
 	// Compute the new retry time
-	retryTime := time.Now().Add(time.Duration(job.RetryInterval) * time.Minute)
+	retryTime := time.Now().Add(RetryDelay(job, newAttempt))
 	layout := "Mon 2006-01-02 15:04:05 MST"
 	retrySchedule := retryTime.Format(layout)
 
@@ -0,0 +1,82 @@
+//go:build linux
+
+package system
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+)
+
+func TestRetryDelayExponential(t *testing.T) {
+	job := types.Job{
+		ID:               "nightly",
+		Retry:            5,
+		RetryInterval:    2,
+		RetryMultiplier:  3,
+		RetryMaxInterval: 60,
+	}
+
+	// Attempts failing right away retry at these offsets from the first
+	// failure: 2m, then 6m, 18m, 54m later, then capped at an hour.
+	failedAt := time.Date(2025, 3, 4, 2, 0, 0, 0, time.UTC)
+	expected := []time.Time{
+		failedAt.Add(2 * time.Minute),
+		failedAt.Add(8 * time.Minute),
+		failedAt.Add(26 * time.Minute),
+		failedAt.Add(80 * time.Minute),
+		failedAt.Add(140 * time.Minute),
+	}
+
+	at := failedAt
+	for attempt := 1; attempt <= job.Retry; attempt++ {
+		at = at.Add(RetryDelay(job, attempt))
+		if !at.Equal(expected[attempt-1]) {
+			t.Errorf("attempt %d: scheduled at %s, want %s", attempt, at, expected[attempt-1])
+		}
+	}
+}
+
+func TestRetryDelayDefaults(t *testing.T) {
+	// Jobs predating the policy retry at a fixed interval.
+	job := types.Job{RetryInterval: 5}
+	for attempt := 1; attempt <= 3; attempt++ {
+		if delay := RetryDelay(job, attempt); delay != 5*time.Minute {
+			t.Errorf("attempt %d: delay %s, want 5m", attempt, delay)
+		}
+	}
+
+	if delay := RetryDelay(types.Job{}, 1); delay != time.Minute {
+		t.Errorf("unset interval: delay %s, want 1m", delay)
+	}
+
+	huge := types.Job{RetryInterval: 1, RetryMultiplier: 10}
+	if delay := RetryDelay(huge, 100); delay <= 0 {
+		t.Errorf("overflowing delay: got %s", delay)
+	}
+}
+
+func TestNextRetryAttempt(t *testing.T) {
+	job := types.Job{Retry: 3}
+
+	for failed, want := range []struct {
+		attempt int
+		ok      bool
+	}{
+		{1, true},
+		{2, true},
+		{3, true},
+		{4, false},
+	} {
+		job.RetryAttempt = failed
+		attempt, ok := NextRetryAttempt(job)
+		if attempt != want.attempt || ok != want.ok {
+			t.Errorf("after attempt %d: got (%d, %v), want (%d, %v)", failed, attempt, ok, want.attempt, want.ok)
+		}
+	}
+
+	if _, ok := NextRetryAttempt(types.Job{}); ok {
+		t.Error("expected no retry for a job without retries")
+	}
+}
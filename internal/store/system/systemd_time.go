@@ -8,15 +8,27 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/constants"
 	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
 )
 
-func generateTimer(job types.Job) error {
+// scheduleTimerName returns the timer unit of the schedule at index of job.
+// The first schedule keeps the unit name used before jobs could have several.
+func scheduleTimerName(id string, index int) string {
+	id = strings.ReplaceAll(id, " ", "-")
+	if index == 0 {
+		return fmt.Sprintf("pbs-plus-job-%s.timer", id)
+	}
+	return fmt.Sprintf("pbs-plus-job-%s-schedule-%d.timer", id, index)
+}
+
+func generateTimer(job types.Job, index int, schedule string) error {
 	if strings.Contains(job.ID, "/") || strings.Contains(job.ID, "\\") || strings.Contains(job.ID, "..") {
 		return fmt.Errorf("generateTimer: invalid job ID -> %s", job.ID)
 	}
@@ -26,13 +38,13 @@ Description=%s Backup Job Timer
 
 [Timer]
 OnCalendar=%s
+Unit=pbs-plus-job-%s.service
 Persistent=false
 
 [Install]
-WantedBy=timers.target`, job.ID, job.Schedule)
+WantedBy=timers.target`, job.ID, schedule, strings.ReplaceAll(job.ID, " ", "-"))
 
-	filePath := fmt.Sprintf("pbs-plus-job-%s.timer", strings.ReplaceAll(job.ID, " ", "-"))
-	fullPath := filepath.Join(constants.TimerBasePath, filePath)
+	fullPath := filepath.Join(constants.TimerBasePath, scheduleTimerName(job.ID, index))
 
 	file, err := os.OpenFile(fullPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
@@ -49,6 +61,32 @@ WantedBy=timers.target`, job.ID, job.Schedule)
 	return nil
 }
 
+// removeScheduleTimers disables and removes the additional schedule timers
+// of job id from index from onwards.
+func removeScheduleTimers(id string, from int) {
+	timerPattern := filepath.Join(
+		constants.TimerBasePath,
+		fmt.Sprintf("pbs-plus-job-%s-schedule-*.timer", strings.ReplaceAll(id, " ", "-")),
+	)
+	timerFiles, err := filepath.Glob(timerPattern)
+	if err != nil {
+		return
+	}
+
+	prefix := strings.TrimSuffix(scheduleTimerName(id, 1), "1.timer")
+	for _, file := range timerFiles {
+		index, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), prefix), ".timer"))
+		if err != nil || index < from {
+			continue
+		}
+
+		cmd := exec.Command("/usr/bin/systemctl", "disable", "--now", filepath.Base(file))
+		cmd.Env = os.Environ()
+		_ = cmd.Run()
+		_ = os.Remove(file)
+	}
+}
+
 func generateService(job types.Job) error {
 	if strings.Contains(job.ID, "/") || strings.Contains(job.ID, "\\") || strings.Contains(job.ID, "..") {
 		return fmt.Errorf("generateService: invalid job ID -> %s", job.ID)
@@ -104,6 +142,8 @@ func DeleteSchedule(id string) error {
 		return fmt.Errorf("DeleteSchedule: error deleting timer -> %w", err)
 	}
 
+	removeScheduleTimers(id, 1)
+
 	cmd = exec.Command("/usr/bin/systemctl", "daemon-reload")
 	cmd.Env = os.Environ()
 	err = cmd.Run()
@@ -149,17 +189,27 @@ func GetNextSchedule(job types.Job) (*time.Time, error) {
 	}
 	lastSchedMux.Unlock()
 
+	return nextScheduled(output, job.ID)
+}
+
+// nextScheduled returns the earliest next elapse of the schedule and retry
+// timers of job id in the output of "systemctl list-timers --all", or nil
+// when none of them is pending.
+func nextScheduled(output []byte, id string) (*time.Time, error) {
 	scanner := bufio.NewScanner(strings.NewReader(string(output)))
 	layout := "Mon 2006-01-02 15:04:05 MST"
 
-	// Look for both the primary timer and any retry timer entries.
-	primaryTimer := fmt.Sprintf("pbs-plus-job-%s.timer", strings.ReplaceAll(job.ID, " ", "-"))
-	retryPrefix := fmt.Sprintf("pbs-plus-job-%s-retry", strings.ReplaceAll(job.ID, " ", "-"))
+	// Look for the primary timer, the timers of additional schedules and any
+	// retry timer entries.
+	primaryTimer := scheduleTimerName(id, 0)
+	schedulePrefix := fmt.Sprintf("pbs-plus-job-%s-schedule-", strings.ReplaceAll(id, " ", "-"))
+	retryPrefix := fmt.Sprintf("pbs-plus-job-%s-retry", strings.ReplaceAll(id, " ", "-"))
 
 	var nextTimes []time.Time
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.Contains(line, primaryTimer) ||
+			strings.Contains(line, schedulePrefix) ||
 			strings.Contains(line, retryPrefix) {
 			fields := strings.Fields(line)
 			if len(fields) < 4 {
@@ -167,7 +217,7 @@ func GetNextSchedule(job types.Job) (*time.Time, error) {
 			}
 
 			nextStr := strings.Join(fields[0:4], " ")
-			if strings.TrimSpace(nextStr) == "-" {
+			if fields[0] == "-" {
 				continue
 			}
 
@@ -199,6 +249,19 @@ func GetNextSchedule(job types.Job) (*time.Time, error) {
 	return &earliest, nil
 }
 
+// activeSchedules returns the calendar expressions job is run on, none when
+// the job is disabled.
+func activeSchedules(job types.Job) []string {
+	if !job.Enabled {
+		return nil
+	}
+	return utils.SplitSchedules(job.Schedule)
+}
+
+// SetSchedule installs one timer per calendar expression of the schedule of
+// job, all starting the same service, and removes the timers of expressions
+// that were dropped. A disabled job has its timers and pending retries
+// removed.
 func SetSchedule(job types.Job) error {
 	if strings.Contains(job.ID, "/") || strings.Contains(job.ID, "\\") || strings.Contains(job.ID, "..") {
 		return fmt.Errorf("SetSchedule: invalid job ID -> %s", job.ID)
@@ -207,10 +270,16 @@ func SetSchedule(job types.Job) error {
 	svcPath := fmt.Sprintf("pbs-plus-job-%s.service", strings.ReplaceAll(job.ID, " ", "-"))
 	fullSvcPath := filepath.Join(constants.TimerBasePath, svcPath)
 
-	timerPath := fmt.Sprintf("pbs-plus-job-%s.timer", strings.ReplaceAll(job.ID, " ", "-"))
+	timerPath := scheduleTimerName(job.ID, 0)
 	fullTimerPath := filepath.Join(constants.TimerBasePath, timerPath)
 
-	if job.Schedule == "" {
+	schedules := activeSchedules(job)
+	removeScheduleTimers(job.ID, max(len(schedules), 1))
+	if !job.Enabled {
+		RemoveAllRetrySchedules(job)
+	}
+
+	if len(schedules) == 0 {
 		cmd := exec.Command("/usr/bin/systemctl", "disable", "--now", timerPath)
 		cmd.Env = os.Environ()
 		_ = cmd.Run()
@@ -223,9 +292,11 @@ func SetSchedule(job types.Job) error {
 			return fmt.Errorf("SetSchedule: error generating service -> %w", err)
 		}
 
-		err = generateTimer(job)
-		if err != nil {
-			return fmt.Errorf("SetSchedule: error generating timer -> %v", err)
+		for i, schedule := range schedules {
+			err = generateTimer(job, i, schedule)
+			if err != nil {
+				return fmt.Errorf("SetSchedule: error generating timer -> %v", err)
+			}
 		}
 	}
 
@@ -236,15 +307,13 @@ func SetSchedule(job types.Job) error {
 		return fmt.Errorf("SetSchedule: error running daemon reload -> %v", err)
 	}
 
-	if job.Schedule == "" {
-		return nil
-	}
-
-	cmd = exec.Command("/usr/bin/systemctl", "enable", "--now", timerPath)
-	cmd.Env = os.Environ()
-	err = cmd.Run()
-	if err != nil {
-		return fmt.Errorf("SetSchedule: error running enable -> %v", err)
+	for i := range schedules {
+		cmd = exec.Command("/usr/bin/systemctl", "enable", "--now", scheduleTimerName(job.ID, i))
+		cmd.Env = os.Environ()
+		err = cmd.Run()
+		if err != nil {
+			return fmt.Errorf("SetSchedule: error running enable -> %v", err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,77 @@
+//go:build linux
+
+package system
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sonroyaalmerol/pbs-plus/internal/store/types"
+	"github.com/sonroyaalmerol/pbs-plus/internal/utils"
+)
+
+const listTimersOutput = `NEXT                        LEFT          LAST                        PASSED       UNIT                                  ACTIVATES
+Tue 2025-03-04 09:00:00 UTC 1h left       Tue 2025-03-04 07:00:00 UTC 1h ago       pbs-plus-job-other.timer              pbs-plus-job-other.service
+Tue 2025-03-04 10:00:00 UTC 2h left       Tue 2025-03-04 08:00:00 UTC 2min ago     pbs-plus-job-nightly.timer            pbs-plus-job-nightly.service
+Sat 2025-03-08 02:00:00 UTC 3 days left   Sat 2025-03-01 02:00:00 UTC 3 days ago   pbs-plus-job-nightly-schedule-1.timer pbs-plus-job-nightly.service
+-                           -             -                           -            pbs-plus-job-nightly-schedule-2.timer pbs-plus-job-nightly.service
+
+4 timers listed.
+`
+
+func TestNextScheduledAcrossSchedules(t *testing.T) {
+	next, err := nextScheduled([]byte(listTimersOutput), "nightly")
+	if err != nil {
+		t.Fatalf("nextScheduled failed: %v", err)
+	}
+	if next == nil {
+		t.Fatal("expected a next run")
+	}
+	if got := next.Format("2006-01-02 15:04"); got != "2025-03-04 10:00" {
+		t.Fatalf("expected the earliest schedule of the job, got %s", got)
+	}
+
+	// The weekday timer having fired for the day, the weekend one is next.
+	output := `Sat 2025-03-08 02:00:00 UTC 3 days left   Sat 2025-03-01 02:00:00 UTC 3 days ago   pbs-plus-job-nightly-schedule-1.timer pbs-plus-job-nightly.service
+Mon 2025-03-10 10:00:00 UTC 5 days left   Fri 2025-03-07 10:00:00 UTC 1h ago       pbs-plus-job-nightly.timer            pbs-plus-job-nightly.service
+`
+	next, err = nextScheduled([]byte(output), "nightly")
+	if err != nil {
+		t.Fatalf("nextScheduled failed: %v", err)
+	}
+	if next == nil || next.Format("2006-01-02 15:04") != "2025-03-08 02:00" {
+		t.Fatalf("expected the weekend schedule, got %v", next)
+	}
+
+	next, err = nextScheduled([]byte(listTimersOutput), "missing")
+	if err != nil || next != nil {
+		t.Fatalf("expected no next run for an unscheduled job, got %v, %v", next, err)
+	}
+}
+
+func TestActiveSchedules(t *testing.T) {
+	job := types.Job{ID: "nightly", Schedule: "mon..fri 18:00; sat 02:00", Enabled: true}
+	if got := activeSchedules(job); len(got) != 2 {
+		t.Fatalf("expected both schedules of an enabled job, got %v", got)
+	}
+
+	job.Enabled = false
+	if got := activeSchedules(job); len(got) != 0 {
+		t.Fatalf("expected no schedule for a disabled job, got %v", got)
+	}
+}
+
+func TestSplitSchedules(t *testing.T) {
+	tests := map[string][]string{
+		"":                                   {},
+		"mon..fri *-*-* 08..18:00":           {"mon..fri *-*-* 08..18:00"},
+		"mon,wed 18:00\nsat 02:00":           {"mon,wed 18:00", "sat 02:00"},
+		"mon..fri 18:00; sat,sun 02:00;\r\n": {"mon..fri 18:00", "sat,sun 02:00"},
+	}
+
+	for value, want := range tests {
+		if got := utils.SplitSchedules(value); !reflect.DeepEqual(got, want) {
+			t.Errorf("SplitSchedules(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package types
 
 type Exclusion struct {
-	Path    string `config:"type=string,required" json:"path"`
-	Comment string `config:"type=string" json:"comment"`
-	JobID   string `config:"key=job_id,type=string" json:"job_id"`
+	Path      string `config:"type=string,required" json:"path"`
+	Comment   string `config:"type=string" json:"comment"`
+	JobID     string `config:"key=job_id,type=string" json:"job_id"`
+	MatchType string `config:"key=match_type,type=string" json:"match_type"`
+	// CaseSensitive overrides the case sensitivity of the target filesystem
+	// when set.
+	CaseSensitive *bool `config:"key=case_sensitive,type=object" json:"case_sensitive,omitempty"`
 }
@@ -0,0 +1,10 @@
+package types
+
+// Inclusion restricts a job to the paths it matches. Inclusions always
+// belong to a job; a job without any backs up its whole source.
+type Inclusion struct {
+	Path      string `json:"path"`
+	Comment   string `json:"comment"`
+	JobID     string `json:"job_id"`
+	MatchType string `json:"match_type"`
+}
@@ -1,5 +1,12 @@
 package types
 
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+)
+
 type Job struct {
 	ID                    string      `json:"id"`
 	Store                 string      `config:"type=string,required" json:"store"`
@@ -9,11 +16,24 @@ type Job struct {
 	Subpath               string      `config:"type=string" json:"subpath"`
 	Schedule              string      `config:"type=string" json:"schedule"`
 	Comment               string      `config:"type=string" json:"comment"`
+	Tags                  []string    `config:"type=array" json:"tags"`
+	Enabled               bool        `json:"enabled"`
 	NotificationMode      string      `config:"key=notification_mode,type=string" json:"notification-mode"`
 	Namespace             string      `config:"type=string" json:"ns"`
 	NextRun               int64       `json:"next-run"`
 	Retry                 int         `config:"type=int" json:"retry"`
 	RetryInterval         int         `config:"type=int" json:"retry-interval"`
+	RetryMultiplier       float64     `json:"retry-multiplier"`
+	RetryMaxInterval      int         `config:"key=retry_max_interval,type=int" json:"retry-max-interval"`
+	RetryAttempt          int         `json:"-"`
+	MaxSize               int64       `config:"key=max_size,type=int" json:"max-size"`
+	BandwidthLimit        int64       `config:"key=bandwidth_limit,type=int" json:"bandwidth-limit"`
+	WebhookURL            string      `config:"key=webhook_url,type=string" json:"webhook-url"`
+	SizeGuard             string      `config:"key=size_guard,type=string" json:"size-guard"`
+	SerializeStore        bool        `config:"key=serialize_store,type=bool" json:"serialize-store"`
+	CreateNamespace       bool        `config:"key=create_namespace,type=bool" json:"create-namespace"`
+	EncryptionKeyFile     string      `config:"key=encryption_key_file,type=string" json:"encryption-key-file"`
+	PartialFiles          string      `config:"key=partial_files,type=string" json:"partial-files"`
 	CurrentFileCount      string      `json:"current_file_count"`
 	CurrentFolderCount    string      `json:"current_folder_count"`
 	CurrentFilesSpeed     string      `json:"current_files_speed"`
@@ -25,9 +45,88 @@ type Job struct {
 	LastRunEndtime        int64       `json:"last-run-endtime"`
 	LastSuccessfulEndtime int64       `json:"last-successful-endtime"`
 	LastSuccessfulUpid    string      `config:"key=last_successful_upid,type=string" json:"last-successful-upid"`
+	LastRunFingerprint    string      `config:"key=last_run_fingerprint,type=string" json:"last-run-fingerprint"`
+	LastRunVerifyState    string      `config:"key=last_run_verify_state,type=string" json:"last-run-verify-state"`
+	RunOnCheckIn          bool        `config:"key=run_on_checkin,type=bool" json:"run-on-checkin"`
+	PendingCheckIn        int64       `config:"key=pending_checkin,type=int" json:"pending-checkin"`
+	Manifest              bool        `config:"key=manifest,type=bool" json:"manifest"`
+	ManifestHash          bool        `config:"key=manifest_hash,type=bool" json:"manifest-hash"`
 	Duration              int64       `json:"duration"`
 	Exclusions            []Exclusion `json:"exclusions"`
 	RawExclusions         string      `json:"rawexclusions"`
+	Inclusions            []Inclusion `json:"inclusions"`
+	RawInclusions         string      `json:"rawinclusions"`
 	ExpectedSize          string      `json:"expected_size"`
 	UPIDs                 []string    `json:"upids"`
+	Template              string      `json:"template"`
+	History               []JobRun    `json:"history,omitempty"`
+}
+
+// JobRun is an entry of the run history of a job.
+type JobRun struct {
+	UPID      string `json:"upid"`
+	State     string `json:"state"`
+	StartTime int64  `json:"start-time"`
+	Duration  int64  `json:"duration"`
+	Bytes     int64  `json:"bytes"`
+	Error     string `json:"error,omitempty"`
+}
+
+// UnmarshalJSON decodes a job, leaving it enabled and creating its missing
+// namespace when the document predates these fields.
+func (job *Job) UnmarshalJSON(data []byte) error {
+	type plain Job
+	decoded := plain{Enabled: true, CreateNamespace: true}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*job = Job(decoded)
+	return nil
+}
+
+// JobStatus is the runtime state a run writes back to its job.
+type JobStatus struct {
+	CurrentPID         int
+	LastRunUpid        string
+	LastSuccessfulUpid string
+	LastRunFingerprint string
+	LastRunVerifyState string
+}
+
+// Size guard actions taken when a backup is estimated to exceed the job's
+// MaxSize or the datastore's free space. An empty SizeGuard disables the
+// check.
+const (
+	SizeGuardWarn  = "warn"
+	SizeGuardBlock = "block"
+)
+
+func IsValidSizeGuard(action string) bool {
+	switch action {
+	case "", SizeGuardWarn, SizeGuardBlock:
+		return true
+	}
+	return false
+}
+
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._\-]{0,63}$`)
+
+// IsValidTag reports whether tag can label a job: up to 64 letters, digits,
+// dots, dashes and underscores, not starting with a dot or a dash.
+func IsValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+// ParseTags splits a comma or whitespace separated list of tags, dropping
+// empty entries and duplicates.
+func ParseTags(raw string) []string {
+	tags := []string{}
+	for _, tag := range strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	}) {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
 }
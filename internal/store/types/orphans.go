@@ -0,0 +1,10 @@
+package types
+
+// Orphans lists config entries left behind by deletions that did not fully
+// complete.
+type Orphans struct {
+	// Exclusions that belong to a job that no longer exists.
+	Exclusions []Exclusion `json:"exclusions"`
+	// Names of targets that no job backs up.
+	Targets []string `json:"targets"`
+}
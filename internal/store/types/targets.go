@@ -18,4 +18,8 @@ type Target struct {
 	DriveTotal       string `config:"key=drive_total,type=string" json:"drive_total"`
 	DriveUsed        string `config:"key=drive_used,type=string" json:"drive_used"`
 	DriveFree        string `config:"key=drive_free,type=string" json:"drive_free"`
+	ConnectTimeout   int    `config:"key=connect_timeout,type=int" json:"connect_timeout"`
+	MaxRetries       int    `config:"key=max_retries,type=int" json:"max_retries"`
+	MaxConcurrency   int    `config:"key=max_concurrency,type=int" json:"max_concurrency"`
+	FailWhenBusy     bool   `config:"key=fail_when_busy,type=bool" json:"fail_when_busy"`
 }
@@ -0,0 +1,31 @@
+package types
+
+// JobTemplate holds shared job settings that new jobs can inherit from.
+// Settings a new job does not submit are filled from its template when the
+// job is created; the values are copied, so later template edits do not
+// affect existing jobs.
+type JobTemplate struct {
+	ID               string `json:"id"`
+	Store            string `config:"type=string" json:"store"`
+	SourceMode       string `config:"key=source_mode,type=string" json:"sourcemode"`
+	Mode             string `config:"type=string" json:"mode"`
+	Schedule         string `config:"type=string" json:"schedule"`
+	Comment          string `config:"type=string" json:"comment"`
+	NotificationMode string `config:"key=notification_mode,type=string" json:"notification-mode"`
+	Namespace        string `config:"type=string" json:"ns"`
+	Retry            int    `config:"type=int" json:"retry"`
+	RetryInterval    int    `config:"type=int" json:"retry-interval"`
+}
+
+// Form keys of the settings a job can inherit from its template.
+const (
+	JobSettingStore            = "store"
+	JobSettingSourceMode       = "sourcemode"
+	JobSettingMode             = "mode"
+	JobSettingSchedule         = "schedule"
+	JobSettingComment          = "comment"
+	JobSettingNotificationMode = "notification-mode"
+	JobSettingNamespace        = "ns"
+	JobSettingRetry            = "retry"
+	JobSettingRetryInterval    = "retry-interval"
+)
@@ -5,4 +5,5 @@ type AgentToken struct {
 	Comment   string `config:"type=string" json:"comment"`
 	CreatedAt int    `config:"key=created_at,type=int,required" json:"created_at"`
 	Revoked   bool   `config:"type=bool" json:"revoked"`
+	SingleUse bool   `config:"key=single_use,type=bool" json:"single_use"`
 }
@@ -3,12 +3,118 @@ package syslog
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
 )
 
 // Global logger instance.
 var L *Logger
 
+// LevelEnv is the environment variable that sets the minimum level at
+// startup, as one of debug, info, warn or error.
+const LevelEnv = "PBS_PLUS_LOG_LEVEL"
+
+// newLogger returns a Logger writing to out in the given format. color only
+// applies to FormatText. The minimum level is info unless LevelEnv says
+// otherwise.
+func newLogger(out io.Writer, color bool, format Format) *Logger {
+	l := &Logger{out: out, color: color, format: format}
+	l.minLevel.Store(int32(LevelInfo))
+	if value := os.Getenv(LevelEnv); value != "" {
+		if level, err := ParseLevel(value); err == nil {
+			l.minLevel.Store(int32(level))
+		}
+	}
+	l.rebuild()
+	return l
+}
+
+// ParseLevel returns the level named name.
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q", name)
+}
+
+// SetMinLevel drops the entries of the global logger below level.
+func SetMinLevel(level Level) {
+	L.minLevel.Store(int32(level))
+}
+
+// enabled reports whether e is at or above the minimum level of its logger.
+// Entries with an unknown level are kept.
+func (e *LogEntry) enabled() bool {
+	level, err := ParseLevel(e.Level)
+	if err != nil {
+		return true
+	}
+	return int32(level) >= e.logger.minLevel.Load()
+}
+
+// rebuild recreates the zerolog logger from the settings of l. The caller
+// holds l.mu or has exclusive access to l.
+func (l *Logger) rebuild() {
+	out := l.out
+	if l.format == FormatText {
+		out = zerolog.NewConsoleWriter(func(w *zerolog.ConsoleWriter) {
+			w.Out = l.out
+			w.NoColor = !l.color
+		})
+	}
+
+	// The skipped frames are those of emit and Write, so the caller of
+	// Write is reported.
+	zlog := zerolog.New(out).With().Timestamp().CallerWithSkipFrameCount(4).Logger()
+	l.zlog = &zlog
+}
+
+// SetFormat switches the global logger between text and JSON output.
+func SetFormat(format Format) {
+	L.mu.Lock()
+	defer L.mu.Unlock()
+
+	L.format = format
+	L.rebuild()
+}
+
+// emit writes e through the zerolog logger. The caller holds the read lock
+// of the logger. In JSON mode the fields are nested under "fields" so they
+// cannot clash with the keys of the entry itself.
+func (e *LogEntry) emit() {
+	var event *zerolog.Event
+	switch e.Level {
+	case "debug":
+		event = e.logger.zlog.Debug()
+	case "warn":
+		event = e.logger.zlog.Warn()
+	case "error":
+		event = e.logger.zlog.Error().Err(e.Err)
+	default:
+		event = e.logger.zlog.Info()
+	}
+
+	if e.logger.format == FormatJSON {
+		if len(e.Fields) > 0 {
+			event = event.Interface("fields", e.Fields)
+		}
+	} else {
+		event = event.Fields(e.Fields)
+	}
+	event.Msg(e.Message)
+}
+
 // Error creates a new error-level LogEntry.
 func (l *Logger) Error(err error) *LogEntry {
 	return &LogEntry{
@@ -19,6 +125,15 @@ func (l *Logger) Error(err error) *LogEntry {
 	}
 }
 
+// Debug creates a new debug-level LogEntry.
+func (l *Logger) Debug() *LogEntry {
+	return &LogEntry{
+		Level:  "debug",
+		Fields: make(map[string]interface{}),
+		logger: l,
+	}
+}
+
 // Warn creates a new warning-level LogEntry.
 func (l *Logger) Warn() *LogEntry {
 	return &LogEntry{
@@ -91,19 +206,13 @@ func ParseAndLogWindowsEntry(body io.ReadCloser) error {
 	if err != nil {
 		return err
 	}
+	if !entry.enabled() {
+		return nil
+	}
 
 	entry.logger.mu.RLock()
 	defer entry.logger.mu.RUnlock()
 
-	switch entry.Level {
-	case "info":
-		entry.logger.zlog.Info().Fields(entry.Fields).Msg(entry.Message)
-	case "warn":
-		entry.logger.zlog.Warn().Fields(entry.Fields).Msg(entry.Message)
-	case "error":
-		entry.logger.zlog.Error().Err(entry.Err).Fields(entry.Fields).Msg(entry.Message)
-	default:
-		entry.logger.zlog.Info().Fields(entry.Fields).Msg(entry.Message)
-	}
+	entry.emit()
 	return nil
 }
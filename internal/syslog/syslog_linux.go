@@ -4,36 +4,24 @@ package syslog
 
 import (
 	"log/syslog"
-
-	"github.com/rs/zerolog"
 )
 
 func init() {
 	sysWriter, _ := syslog.New(syslog.LOG_ERR|syslog.LOG_LOCAL7, "pbs-plus")
-	logger := zerolog.New(zerolog.NewConsoleWriter(func(w *zerolog.ConsoleWriter) {
-		w.Out = &LogWriter{logger: sysWriter}
-		w.NoColor = true
-	})).With().Timestamp().CallerWithSkipFrameCount(3).Logger()
-
-	L = &Logger{zlog: &logger}
+	L = newLogger(&LogWriter{logger: sysWriter}, false, FormatText)
 }
 
 // Write finalizes the LogEntry and writes it using the global zerolog logger.
 // (Here, the global logger sends the pre-formatted output through the
 // ConsoleWriter and then our SyslogWriter.)
 func (e *LogEntry) Write() {
+	e.publish()
+	if !e.enabled() {
+		return
+	}
+
 	e.logger.mu.RLock()
 	defer e.logger.mu.RUnlock()
 
-	// Produce a full JSON log entry.
-	switch e.Level {
-	case "info":
-		e.logger.zlog.Info().Fields(e.Fields).Msg(e.Message)
-	case "warn":
-		e.logger.zlog.Warn().Fields(e.Fields).Msg(e.Message)
-	case "error":
-		e.logger.zlog.Error().Err(e.Err).Fields(e.Fields).Msg(e.Message)
-	default:
-		e.logger.zlog.Info().Fields(e.Fields).Msg(e.Message)
-	}
+	e.emit()
 }
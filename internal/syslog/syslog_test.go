@@ -0,0 +1,118 @@
+//go:build linux
+
+package syslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, false, FormatJSON)
+
+	l.Error(errors.New("connection reset")).
+		WithMessage("backup failed").
+		WithField("jobId", "nightly").
+		WithFields(map[string]interface{}{
+			"target": map[string]interface{}{"host": "agent-1", "drives": []string{"C", "D"}},
+		}).
+		Write()
+
+	var entry struct {
+		Level   string                 `json:"level"`
+		Message string                 `json:"message"`
+		Error   string                 `json:"error"`
+		Time    string                 `json:"time"`
+		Fields  map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON object, got %q: %v", buf.String(), err)
+	}
+
+	if entry.Level != "error" || entry.Message != "backup failed" || entry.Time == "" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if entry.Error != "connection reset" {
+		t.Fatalf("expected the error message, got %q", entry.Error)
+	}
+	if entry.Fields["jobId"] != "nightly" {
+		t.Fatalf("expected jobId field, got %v", entry.Fields)
+	}
+	target, ok := entry.Fields["target"].(map[string]interface{})
+	if !ok || target["host"] != "agent-1" || len(target["drives"].([]interface{})) != 2 {
+		t.Fatalf("expected nested target field, got %v", entry.Fields["target"])
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, false, FormatText)
+
+	l.Warn().WithMessage("agent slow").WithField("hostname", "agent-1").Write()
+
+	out := buf.String()
+	if json.Valid(buf.Bytes()) {
+		t.Fatalf("expected a text line, got JSON %q", out)
+	}
+	for _, want := range []string{"WRN", "agent slow", "hostname=agent-1", "syslog_test.go"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestMinLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, false, FormatText)
+
+	l.Debug().WithMessage("walking directory").Write()
+	if buf.Len() != 0 {
+		t.Fatalf("expected debug entry to be dropped at info level, got %q", buf.String())
+	}
+	l.Info().WithMessage("backup started").Write()
+	if !strings.Contains(buf.String(), "backup started") {
+		t.Fatalf("expected info entry, got %q", buf.String())
+	}
+
+	buf.Reset()
+	l.minLevel.Store(int32(LevelDebug))
+	l.Debug().WithMessage("walking directory").Write()
+	if !strings.Contains(buf.String(), "DBG") || !strings.Contains(buf.String(), "walking directory") {
+		t.Fatalf("expected debug entry at debug level, got %q", buf.String())
+	}
+
+	buf.Reset()
+	l.minLevel.Store(int32(LevelError))
+	l.Warn().WithMessage("agent slow").Write()
+	if buf.Len() != 0 {
+		t.Fatalf("expected warn entry to be dropped at error level, got %q", buf.String())
+	}
+}
+
+func TestMinLevelFromEnv(t *testing.T) {
+	t.Setenv(LevelEnv, "debug")
+
+	var buf bytes.Buffer
+	l := newLogger(&buf, false, FormatText)
+	l.Debug().WithMessage("walking directory").Write()
+	if !strings.Contains(buf.String(), "walking directory") {
+		t.Fatalf("expected debug entry with %s=debug, got %q", LevelEnv, buf.String())
+	}
+
+	t.Setenv(LevelEnv, "error")
+	buf.Reset()
+	l = newLogger(&buf, false, FormatText)
+	l.Info().WithMessage("backup started").Write()
+	if buf.Len() != 0 {
+		t.Fatalf("expected info entry to be dropped with %s=error, got %q", LevelEnv, buf.String())
+	}
+
+	t.Setenv(LevelEnv, "verbose")
+	if l = newLogger(&buf, false, FormatText); Level(l.minLevel.Load()) != LevelInfo {
+		t.Fatalf("expected an invalid %s to keep the info level, got %d", LevelEnv, l.minLevel.Load())
+	}
+}
@@ -4,20 +4,14 @@ package syslog
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/kardianos/service"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func init() {
-	// Configure zerolog to output via our EventLogWriter wrapped in a ConsoleWriter.
-	zlogger := zerolog.New(zerolog.NewConsoleWriter()).With().
-		CallerWithSkipFrameCount(3).
-		Timestamp().
-		Logger()
-
-	L = &Logger{zlog: &zlogger}
+	L = newLogger(os.Stdout, true, FormatText)
 }
 
 // SetServiceLogger configures the service logger for Windows Event Log integration.
@@ -30,15 +24,11 @@ func (l *Logger) SetServiceLogger(s service.Service) error {
 		return fmt.Errorf("failed to set service logger: %w", err)
 	}
 
-	zlogger := zerolog.New(zerolog.NewConsoleWriter(func(w *zerolog.ConsoleWriter) {
-		w.Out = &LogWriter{logger: logger}
-		w.NoColor = true
-	})).With().
-		CallerWithSkipFrameCount(3).
-		Timestamp().
-		Logger()
-
-	l.zlog = &zlogger
+	// Output goes through our EventLogWriter, wrapped in a ConsoleWriter
+	// in text mode.
+	l.out = &LogWriter{logger: logger}
+	l.color = false
+	l.rebuild()
 
 	log.Info().Msg("Service logger successfully added for Windows Event Log")
 	return nil
@@ -48,22 +38,17 @@ func (l *Logger) SetServiceLogger(s service.Service) error {
 // (Here, the global logger sends the pre-formatted output through the
 // ConsoleWriter and then our SyslogWriter.)
 func (e *LogEntry) Write() {
+	e.publish()
+	if !e.enabled() {
+		return
+	}
+
 	e.logger.mu.RLock()
 	defer e.logger.mu.RUnlock()
 
 	e.enqueueLog()
 
-	// Produce a full JSON log entry.
-	switch e.Level {
-	case "info":
-		e.logger.zlog.Info().Fields(e.Fields).Msg(e.Message)
-	case "warn":
-		e.logger.zlog.Warn().Fields(e.Fields).Msg(e.Message)
-	case "error":
-		e.logger.zlog.Error().Err(e.Err).Fields(e.Fields).Msg(e.Message)
-	default:
-		e.logger.zlog.Info().Fields(e.Fields).Msg(e.Message)
-	}
+	e.emit()
 }
 
 // enqueueLog adds a log message to the logQueue for processing.
@@ -0,0 +1,80 @@
+package syslog
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type subscriber struct {
+	level Level
+	ch    chan LogEntry
+}
+
+var (
+	subscribersMu sync.Mutex
+	subscribers   = map[*subscriber]struct{}{}
+	// subscriberCount lets Write skip publishing when nobody listens.
+	subscriberCount atomic.Int32
+)
+
+// Subscribe returns a channel receiving a copy of every entry written from
+// now on at or above level, whatever the minimum level of the logger. An
+// entry is dropped for a subscriber whose buffer is full. cancel ends the
+// subscription and closes the channel.
+func Subscribe(level Level, buffer int) (entries <-chan LogEntry, cancel func()) {
+	sub := &subscriber{level: level, ch: make(chan LogEntry, buffer)}
+
+	subscribersMu.Lock()
+	subscribers[sub] = struct{}{}
+	subscriberCount.Add(1)
+	subscribersMu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			subscribersMu.Lock()
+			delete(subscribers, sub)
+			subscriberCount.Add(-1)
+			close(sub.ch)
+			subscribersMu.Unlock()
+		})
+	}
+}
+
+// publish hands a copy of e to the subscribers that want its level.
+func (e *LogEntry) publish() {
+	if subscriberCount.Load() == 0 {
+		return
+	}
+
+	level, err := ParseLevel(e.Level)
+	if err != nil {
+		level = LevelInfo
+	}
+
+	entry := LogEntry{
+		Level:    e.Level,
+		Message:  e.Message,
+		Hostname: e.Hostname,
+		Err:      e.Err,
+		Fields:   make(map[string]interface{}, len(e.Fields)),
+	}
+	for k, v := range e.Fields {
+		entry.Fields[k] = v
+	}
+	if entry.Err != nil {
+		entry.ErrString = entry.Err.Error()
+	}
+
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for sub := range subscribers {
+		if level < sub.level {
+			continue
+		}
+		select {
+		case sub.ch <- entry:
+		default:
+		}
+	}
+}
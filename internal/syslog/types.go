@@ -1,14 +1,44 @@
 package syslog
 
 import (
+	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
 )
 
+// Format selects how log entries are rendered.
+type Format int
+
+const (
+	// FormatText renders entries as human-readable lines.
+	FormatText Format = iota
+	// FormatJSON renders each entry as one JSON object with the keys level,
+	// message, time, caller and, when set, error and fields.
+	FormatJSON
+)
+
+// Level is the severity of a log entry. Entries below the minimum level of
+// the logger are dropped.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
 type Logger struct {
 	mu   sync.RWMutex
 	zlog *zerolog.Logger
+
+	out    io.Writer
+	color  bool
+	format Format
+
+	minLevel atomic.Int32
 }
 
 // LogEntry represents a structured log entry.
@@ -19,7 +19,7 @@ type LogWriter struct {
 func (sw *LogWriter) Write(p []byte) (n int, err error) {
 	message := string(p)
 	if sw.logger != nil {
-		if strings.Contains(message, "ERR") {
+		if strings.Contains(message, "ERR") || strings.Contains(message, `"level":"error"`) {
 			err = sw.logger.Err(message)
 		} else {
 			err = sw.logger.Info(message)
@@ -20,7 +20,7 @@ type LogWriter struct {
 func (ew *LogWriter) Write(p []byte) (n int, err error) {
 	message := string(p)
 	if ew.logger != nil {
-		if strings.Contains(message, "ERR") {
+		if strings.Contains(message, "ERR") || strings.Contains(message, `"level":"error"`) {
 			err = ew.logger.Error(message)
 		} else {
 			err = ew.logger.Info(message)
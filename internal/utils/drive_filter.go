@@ -0,0 +1,81 @@
+package utils
+
+import "strings"
+
+// DriveFilter decides which mounted filesystems GetLocalDrives reports as
+// drives, and so which ones can become backup targets.
+type DriveFilter struct {
+	// AllowFsTypes, when not empty, lists the only filesystem types
+	// reported. It takes precedence over DenyFsTypes and IncludeNetwork.
+	AllowFsTypes map[string]bool
+	// DenyFsTypes lists filesystem types never reported.
+	DenyFsTypes map[string]bool
+	// IncludeNetwork reports network filesystems such as NFS and SMB shares.
+	IncludeNetwork bool
+}
+
+// pseudoFsTypes are kernel and virtual filesystems that hold no user data,
+// along with overlays, which mostly show up as container layers.
+var pseudoFsTypes = []string{
+	"autofs", "binfmt_misc", "bpf", "cgroup", "cgroup2", "configfs",
+	"debugfs", "devpts", "devtmpfs", "efivarfs", "fuse.gvfsd-fuse",
+	"fuse.lxcfs", "fuse.portal", "fusectl", "hugetlbfs", "mqueue",
+	"nsfs", "overlay", "proc", "pstore", "ramfs", "rpc_pipefs",
+	"securityfs", "squashfs", "sysfs", "tmpfs", "tracefs",
+}
+
+// networkFsTypes are filesystems served by another host.
+var networkFsTypes = map[string]bool{
+	"nfs":        true,
+	"nfs4":       true,
+	"cifs":       true,
+	"smbfs":      true,
+	"smb3":       true,
+	"9p":         true,
+	"afs":        true,
+	"ceph":       true,
+	"glusterfs":  true,
+	"fuse.sshfs": true,
+}
+
+// DefaultDriveFilter leaves out pseudo filesystems and network mounts.
+func DefaultDriveFilter() DriveFilter {
+	deny := make(map[string]bool, len(pseudoFsTypes))
+	for _, fsType := range pseudoFsTypes {
+		deny[fsType] = true
+	}
+	return DriveFilter{DenyFsTypes: deny}
+}
+
+// ParseFsTypeList reads a comma-separated list of filesystem types.
+func ParseFsTypeList(raw string) map[string]bool {
+	types := make(map[string]bool)
+	for _, fsType := range strings.Split(raw, ",") {
+		if fsType = strings.ToLower(strings.TrimSpace(fsType)); fsType != "" {
+			types[fsType] = true
+		}
+	}
+	return types
+}
+
+// IsNetworkFs reports whether fsType is served by another host.
+func IsNetworkFs(fsType string) bool {
+	return networkFsTypes[strings.ToLower(fsType)]
+}
+
+// Allows reports whether a filesystem of type fsType is reported. network
+// tells whether it is known to be remote, as Windows does for mapped drives,
+// regardless of its type.
+func (f DriveFilter) Allows(fsType string, network bool) bool {
+	fsType = strings.ToLower(fsType)
+	if len(f.AllowFsTypes) > 0 {
+		return f.AllowFsTypes[fsType]
+	}
+	if f.DenyFsTypes[fsType] {
+		return false
+	}
+	if network || IsNetworkFs(fsType) {
+		return f.IncludeNetwork
+	}
+	return true
+}
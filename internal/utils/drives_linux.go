@@ -5,9 +5,10 @@ package utils
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 )
@@ -20,9 +21,10 @@ func getDriveType(mountPoint, fsType string) string {
 	}
 
 	// Check if the filesystem type indicates a network drive
-	switch fsType {
-	case "nfs", "cifs", "smbfs", "fuse.sshfs":
+	if IsNetworkFs(fsType) {
 		return "Network"
+	}
+	switch fsType {
 	case "iso9660":
 		return "CD-ROM"
 	}
@@ -60,106 +62,111 @@ func humanizeBytes(bytes uint64) string {
 	return fmt.Sprintf("%.2f %s", float64(bytes)/float64(div), unitSymbol)
 }
 
-// GetLocalDrives returns a slice of DriveInfo containing detailed information about each local drive
-func GetLocalDrives() ([]DriveInfo, error) {
-	var drives []DriveInfo
+// mountEntry is a line of the mount table.
+type mountEntry struct {
+	Device     string
+	MountPoint string
+	FsType     string
+}
 
-	// List of filesystem types to ignore
-	excludedFsTypes := map[string]bool{
-		"tmpfs":           true,
-		"devtmpfs":        true,
-		"proc":            true,
-		"sysfs":           true,
-		"securityfs":      true,
-		"cgroup2":         true,
-		"pstore":          true,
-		"efivarfs":        true,
-		"bpf":             true,
-		"debugfs":         true,
-		"mqueue":          true,
-		"hugetlbfs":       true,
-		"fusectl":         true,
-		"configfs":        true,
-		"ramfs":           true,
-		"fuse.gvfsd-fuse": true,
+// readMountTable parses a mount table in the format of /proc/self/mounts.
+func readMountTable(r io.Reader) ([]mountEntry, error) {
+	var entries []mountEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		entries = append(entries, mountEntry{
+			Device:     unescapeMountField(fields[0]),
+			MountPoint: unescapeMountField(fields[1]),
+			FsType:     fields[2],
+		})
 	}
+	return entries, scanner.Err()
+}
 
-	// Use the `df` command to get information about mounted filesystems
-	output, err := exec.Command("df", "-T").Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute df command: %w", err)
+// unescapeMountField decodes the octal escapes, such as \040 for a space,
+// the kernel writes in mount table fields.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if n, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
 	}
+	return b.String()
+}
 
-	// Parse /proc/mounts to get filesystem types for each mount point
-	fsTypes := make(map[string]string)
-	mountsFile, err := os.Open("/proc/mounts")
-	if err != nil {
-		return nil, fmt.Errorf("failed to open /proc/mounts: %w", err)
+// selectMounts returns the entries filter allows, in mount order. A mount
+// point mounted over several times is reported once, as its topmost mount.
+func selectMounts(entries []mountEntry, filter DriveFilter) []mountEntry {
+	topmost := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		topmost[entry.MountPoint] = i
 	}
-	defer mountsFile.Close()
 
-	scanner := bufio.NewScanner(mountsFile)
-	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
-		if len(fields) >= 3 {
-			mountPoint := fields[1]
-			fsType := fields[2]
-			fsTypes[mountPoint] = fsType
+	var selected []mountEntry
+	for i, entry := range entries {
+		if topmost[entry.MountPoint] != i || !filter.Allows(entry.FsType, false) {
+			continue
 		}
+		selected = append(selected, entry)
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read /proc/mounts: %w", err)
-	}
+	return selected
+}
 
-	// Process the output of `df -T`
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines[1:] { // Skip the header line
-		fields := strings.Fields(line)
-		if len(fields) < 7 {
-			continue // Skip malformed lines
-		}
+// GetLocalDrives returns a slice of DriveInfo containing detailed information
+// about each mounted filesystem filter allows
+func GetLocalDrives(filter DriveFilter) ([]DriveInfo, error) {
+	var drives []DriveInfo
 
-		// Parse the fields from the `df` output
-		fsType := fields[1]
-		mountPoint := fields[6]
+	mountsFile, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /proc/self/mounts: %w", err)
+	}
+	defer mountsFile.Close()
 
-		// Check if the filesystem type is in the excluded list
-		if excludedFsTypes[fsType] {
-			continue
-		}
+	entries, err := readMountTable(mountsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc/self/mounts: %w", err)
+	}
 
+	for _, entry := range selectMounts(entries, filter) {
 		// Get disk space information
 		var stat syscall.Statfs_t
-		if err := syscall.Statfs(mountPoint, &stat); err != nil {
+		if err := syscall.Statfs(entry.MountPoint, &stat); err != nil {
 			continue // Skip if we can't get stats for the mount point
 		}
+		if stat.Blocks == 0 {
+			continue // Pseudo filesystems have no size, as df leaves them out
+		}
 
 		totalBytes := stat.Blocks * uint64(stat.Bsize)
 		freeBytes := stat.Bfree * uint64(stat.Bsize)
 		usedBytes := totalBytes - freeBytes
 
-		// Humanize byte counts
-		totalHuman := humanizeBytes(totalBytes)
-		usedHuman := humanizeBytes(usedBytes)
-		freeHuman := humanizeBytes(freeBytes)
-
-		// Determine the filesystem type dynamically
-		if dynamicFsType, ok := fsTypes[mountPoint]; ok {
-			fsType = dynamicFsType
-		}
-
 		// Append the drive information
 		drives = append(drives, DriveInfo{
-			Letter:          mountPoint,
-			Type:            getDriveType(mountPoint, fsType), // Dynamically determine the drive type
-			VolumeName:      "",                               // Linux doesn't have a direct equivalent for volume names
-			FileSystem:      fsType,
+			Letter:          entry.MountPoint,
+			Type:            getDriveType(entry.MountPoint, entry.FsType),
+			VolumeName:      "", // Linux doesn't have a direct equivalent for volume names
+			FileSystem:      entry.FsType,
 			TotalBytes:      totalBytes,
 			UsedBytes:       usedBytes,
 			FreeBytes:       freeBytes,
-			Total:           totalHuman,
-			Used:            usedHuman,
-			Free:            freeHuman,
+			Total:           humanizeBytes(totalBytes),
+			Used:            humanizeBytes(usedBytes),
+			Free:            humanizeBytes(freeBytes),
 			OperatingSystem: runtime.GOOS, // Add the operating system name
 		})
 	}
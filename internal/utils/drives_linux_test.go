@@ -0,0 +1,64 @@
+//go:build linux
+
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeMountTable = `/dev/sda1 / ext4 rw,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+tmpfs /run tmpfs rw,nosuid,nodev,mode=755 0 0
+/dev/sdb1 /srv/my\040data ext4 rw,relatime 0 0
+nas:/export /mnt/nas nfs4 rw,relatime,vers=4.2 0 0
+overlay /var/lib/docker/overlay2/abc/merged overlay rw,lowerdir=/l,upperdir=/u,workdir=/w 0 0
+/dev/sdc1 /data xfs rw,relatime 0 0
+tmpfs /data tmpfs rw,relatime 0 0
+`
+
+func selectedMountPoints(t *testing.T, filter DriveFilter) []string {
+	entries, err := readMountTable(strings.NewReader(fakeMountTable))
+	require.NoError(t, err)
+
+	var mountPoints []string
+	for _, entry := range selectMounts(entries, filter) {
+		mountPoints = append(mountPoints, entry.MountPoint)
+	}
+	return mountPoints
+}
+
+func TestSelectMounts(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		// /data is hidden by the tmpfs mounted over it.
+		assert.Equal(t, []string{"/", "/srv/my data"}, selectedMountPoints(t, DefaultDriveFilter()))
+	})
+
+	t.Run("IncludeNetwork", func(t *testing.T) {
+		filter := DefaultDriveFilter()
+		filter.IncludeNetwork = true
+		assert.Equal(t, []string{"/", "/srv/my data", "/mnt/nas"}, selectedMountPoints(t, filter))
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		filter := DefaultDriveFilter()
+		filter.DenyFsTypes["ext4"] = true
+		assert.Empty(t, selectedMountPoints(t, filter))
+	})
+
+	t.Run("Allow", func(t *testing.T) {
+		filter := DefaultDriveFilter()
+		filter.AllowFsTypes = ParseFsTypeList("overlay, NFS4")
+		assert.Equal(t, []string{"/mnt/nas", "/var/lib/docker/overlay2/abc/merged"}, selectedMountPoints(t, filter))
+	})
+}
+
+func TestGetDriveType(t *testing.T) {
+	assert.Equal(t, "Fixed", getDriveType("/", "ext4"))
+	assert.Equal(t, "Network", getDriveType("/srv/nas", "nfs4"))
+	assert.Equal(t, "Removable", getDriveType("/media/usb", "vfat"))
+}
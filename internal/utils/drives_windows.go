@@ -62,8 +62,9 @@ func humanizeBytes(bytes uint64) string {
 	return fmt.Sprintf("%.2f %s", float64(bytes)/float64(div), unitSymbol)
 }
 
-// GetLocalDrives returns a slice of DriveInfo containing detailed information about each local drive
-func GetLocalDrives() ([]DriveInfo, error) {
+// GetLocalDrives returns a slice of DriveInfo containing detailed information
+// about each local drive filter allows
+func GetLocalDrives(filter DriveFilter) ([]DriveInfo, error) {
 	var drives []DriveInfo
 
 	for _, drive := range "ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
@@ -102,6 +103,10 @@ func GetLocalDrives() ([]DriveInfo, error) {
 			fileSystemStr = windows.UTF16ToString(fileSystemName[:])
 		}
 
+		if !filter.Allows(fileSystemStr, driveType == windows.DRIVE_REMOTE) {
+			continue
+		}
+
 		// Retrieve disk space information
 		var totalFreeBytes uint64
 		if err := windows.GetDiskFreeSpaceEx(
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrEncryptionKeyMissing     = errors.New("encryption key file does not exist")
+	ErrEncryptionKeyUnreadable  = errors.New("encryption key file is not readable")
+	ErrEncryptionKeyPermissions = errors.New("encryption key file is accessible by group or others")
+)
+
+// ValidateEncryptionKeyFile checks that path names a regular file this
+// process can read and that only its owner can access, like ssh does for
+// private keys.
+func ValidateEncryptionKeyFile(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("encryption key file must be an absolute path")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrEncryptionKeyMissing
+		}
+		return fmt.Errorf("%w: %v", ErrEncryptionKeyUnreadable, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: not a regular file", ErrEncryptionKeyUnreadable)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%w (mode %04o)", ErrEncryptionKeyPermissions, info.Mode().Perm())
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEncryptionKeyUnreadable, err)
+	}
+	return f.Close()
+}
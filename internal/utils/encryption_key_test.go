@@ -0,0 +1,29 @@
+//go:build linux
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEncryptionKeyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	private := filepath.Join(dir, "private.key")
+	require.NoError(t, os.WriteFile(private, []byte("{}"), 0600))
+	assert.NoError(t, ValidateEncryptionKeyFile(private))
+
+	worldReadable := filepath.Join(dir, "world.key")
+	require.NoError(t, os.WriteFile(worldReadable, []byte("{}"), 0600))
+	require.NoError(t, os.Chmod(worldReadable, 0644))
+	assert.ErrorIs(t, ValidateEncryptionKeyFile(worldReadable), ErrEncryptionKeyPermissions)
+
+	assert.ErrorIs(t, ValidateEncryptionKeyFile(filepath.Join(dir, "missing.key")), ErrEncryptionKeyMissing)
+	assert.ErrorIs(t, ValidateEncryptionKeyFile(dir), ErrEncryptionKeyUnreadable)
+	assert.Error(t, ValidateEncryptionKeyFile("relative.key"))
+}
@@ -5,6 +5,11 @@ import (
 	"strings"
 )
 
+// configFileExt is the extension of the files EncodeConfigName names.
+const configFileExt = ".cfg"
+
+// EncodePath turns path into unpadded URL-safe base64, usable as a single
+// URL path segment or file name whatever the characters of path.
 func EncodePath(path string) string {
 	encoded := base64.StdEncoding.EncodeToString([]byte(path))
 	encoded = strings.ReplaceAll(encoded, "+", "-")
@@ -13,9 +18,11 @@ func EncodePath(path string) string {
 	return encoded
 }
 
+// DecodePath reverses EncodePath. A value that is not encoded is returned
+// as is.
 func DecodePath(orig string) string {
 	encoded := strings.ReplaceAll(orig, "-", "+")
-	encoded = strings.ReplaceAll(orig, "_", "/")
+	encoded = strings.ReplaceAll(encoded, "_", "/")
 
 	padding := len(encoded) % 4
 	if padding != 0 {
@@ -28,3 +35,19 @@ func DecodePath(orig string) string {
 	}
 	return string(decoded)
 }
+
+// EncodeConfigName returns the name of the config file holding the section
+// id.
+func EncodeConfigName(id string) string {
+	return EncodePath(id) + configFileExt
+}
+
+// DecodeConfigName returns the section ID of the config file called name, and
+// false for files that are not config files, such as temporary files.
+func DecodeConfigName(name string) (string, bool) {
+	encoded, ok := strings.CutSuffix(name, configFileExt)
+	if !ok || encoded == "" {
+		return "", false
+	}
+	return DecodePath(encoded), true
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pathologicalIDs = []string{
+	"",
+	"a",
+	"job-1",
+	"pve01 - C",
+	"agent://10.0.0.5/C",
+	"../../etc/passwd",
+	".cfg",
+	"job.cfg",
+	"a/b\\c",
+	"~>?", // encodes to '+' and '/' in standard base64
+	"日本語のジョブ",
+	"emoji 🚀 backup",
+	"tab\tnew\nline",
+	"null\x00byte",
+	"%2F%2e%2e",
+	strings.Repeat("x", 200),
+}
+
+func TestEncodePathRoundTrip(t *testing.T) {
+	for _, id := range pathologicalIDs {
+		encoded := EncodePath(id)
+		assert.NotContains(t, encoded, "/", "id %q", id)
+		assert.NotContains(t, encoded, "+", "id %q", id)
+		assert.NotContains(t, encoded, "=", "id %q", id)
+		assert.Equal(t, id, DecodePath(encoded), "id %q", id)
+	}
+}
+
+func TestConfigNameRoundTrip(t *testing.T) {
+	for _, id := range pathologicalIDs {
+		if id == "" {
+			continue
+		}
+		name := EncodeConfigName(id)
+		assert.True(t, strings.HasSuffix(name, ".cfg"))
+		decoded, ok := DecodeConfigName(name)
+		assert.True(t, ok, "id %q", id)
+		assert.Equal(t, id, decoded, "id %q", id)
+	}
+
+	for _, name := range []string{".cfg", "global", EncodePath("job") + ".cfg.tmp", "notes.txt"} {
+		_, ok := DecodeConfigName(name)
+		assert.False(t, ok, "%q is not a config file", name)
+	}
+}
+
+func FuzzEncodePath(f *testing.F) {
+	for _, id := range pathologicalIDs {
+		f.Add(id)
+	}
+	f.Fuzz(func(t *testing.T, id string) {
+		encoded := EncodePath(id)
+		if strings.ContainsAny(encoded, "/+=") {
+			t.Fatalf("EncodePath(%q) = %q is not a single path segment", id, encoded)
+		}
+		if decoded := DecodePath(encoded); decoded != id {
+			t.Fatalf("DecodePath(EncodePath(%q)) = %q", id, decoded)
+		}
+		if id == "" {
+			return
+		}
+		decoded, ok := DecodeConfigName(EncodeConfigName(id))
+		if !ok || decoded != id {
+			t.Fatalf("DecodeConfigName(EncodeConfigName(%q)) = %q, %v", id, decoded, ok)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package pattern
+
+import (
+	"strings"
+	"unicode"
+)
+
+// CaseInsensitive rewrites a glob pattern so that every letter matches both
+// its upper and lower case form, e.g. "Users/*.tmp" becomes
+// "[uU][sS][eE][rR][sS]/*.[tT][mM][pP]". Existing bracket expressions are
+// copied unchanged. This is used for targets backed by case-insensitive
+// filesystems such as NTFS, where the matcher itself is case-sensitive.
+func CaseInsensitive(pattern string) string {
+	var b strings.Builder
+	b.Grow(len(pattern) * 2)
+
+	runes := []rune(pattern)
+	inClass := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case inClass:
+			b.WriteRune(r)
+			if r == ']' {
+				inClass = false
+			}
+			continue
+		case r == '[':
+			inClass = true
+			b.WriteRune(r)
+			continue
+		case r == '\\' && i+1 < len(runes):
+			i++
+			r = runes[i]
+			if !hasCaseVariant(r) {
+				b.WriteRune('\\')
+				b.WriteRune(r)
+				continue
+			}
+		}
+
+		if hasCaseVariant(r) {
+			b.WriteRune('[')
+			b.WriteRune(unicode.ToLower(r))
+			b.WriteRune(unicode.ToUpper(r))
+			b.WriteRune(']')
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// NormalizeKey returns the key under which a path or pattern should be
+// compared. On case-insensitive targets, paths that only differ in case map
+// to the same key.
+func NormalizeKey(path string, caseInsensitive bool) string {
+	path = strings.ReplaceAll(path, "\\", "/")
+	if caseInsensitive {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// CutPathPrefix is strings.CutPrefix for paths, comparing the prefix the
+// way NormalizeKey does.
+func CutPathPrefix(path, prefix string, caseInsensitive bool) (string, bool) {
+	if len(path) < len(prefix) || NormalizeKey(path[:len(prefix)], caseInsensitive) != NormalizeKey(prefix, caseInsensitive) {
+		return path, false
+	}
+	return path[len(prefix):], true
+}
+
+// Dedupe removes patterns that collide after normalization, keeping the
+// first occurrence.
+func Dedupe(patterns []string, caseInsensitive bool) []string {
+	seen := make(map[string]struct{}, len(patterns))
+	result := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		key := NormalizeKey(p, caseInsensitive)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, p)
+	}
+	return result
+}
+
+func hasCaseVariant(r rune) bool {
+	return unicode.IsLetter(r) && unicode.ToLower(r) != unicode.ToUpper(r)
+}
@@ -0,0 +1,110 @@
+package pattern
+
+import (
+	"testing"
+
+	"github.com/gobwas/glob"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		expected string
+	}{
+		{
+			name:     "plain word",
+			pattern:  "Users",
+			expected: "[uU][sS][eE][rR][sS]",
+		},
+		{
+			name:     "wildcards and separators are kept",
+			pattern:  "**/*.tmp",
+			expected: "**/*.[tT][mM][pP]",
+		},
+		{
+			name:     "existing classes are copied",
+			pattern:  "[Tt]humbs.db",
+			expected: "[Tt][hH][uU][mM][bB][sS].[dD][bB]",
+		},
+		{
+			name:     "escaped letters are folded",
+			pattern:  `\A?`,
+			expected: "[aA]?",
+		},
+		{
+			name:     "escaped symbols are kept",
+			pattern:  `\*x`,
+			expected: `\*[xX]`,
+		},
+		{
+			name:     "digits are kept",
+			pattern:  "$$Recycle.Bin2",
+			expected: "$$[rR][eE][cC][yY][cC][lL][eE].[bB][iI][nN]2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CaseInsensitive(tt.pattern))
+			assert.True(t, IsValidPattern(CaseInsensitive(tt.pattern)))
+		})
+	}
+}
+
+func TestCaseInsensitiveMatchingOnWindowsTarget(t *testing.T) {
+	paths := []string{
+		"Users/Admin/AppData/Local/Temp",
+		"users/admin/appdata/local/temp",
+		"USERS/ADMIN/APPDATA/LOCAL/TEMP",
+	}
+
+	for _, exclusion := range []string{"Users/*/AppData/Local/Temp", "users/*/appdata/local/temp"} {
+		g, err := glob.Compile(CaseInsensitive(exclusion), '/')
+		require.NoError(t, err)
+
+		for _, path := range paths {
+			assert.True(t, g.Match(path), "%s should match %s", exclusion, path)
+		}
+		assert.False(t, g.Match("Users/Admin/AppData/Local/Tmp"))
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	patterns := []string{"Users", "users", "USERS/Public", "Windows", `Users\Public`}
+
+	assert.Equal(t, []string{"Users", "USERS/Public", "Windows"}, Dedupe(patterns, true))
+	assert.Equal(t, []string{"Users", "users", "USERS/Public", "Windows", `Users\Public`}, Dedupe(patterns, false))
+}
+
+func TestNormalizeKey(t *testing.T) {
+	assert.Equal(t, "users/public", NormalizeKey(`Users\Public`, true))
+	assert.Equal(t, "Users/Public", NormalizeKey(`Users\Public`, false))
+}
+
+func TestCutPathPrefix(t *testing.T) {
+	rest, ok := CutPathPrefix("/Users/Public/file.txt", "/users/", true)
+	assert.True(t, ok)
+	assert.Equal(t, "Public/file.txt", rest)
+
+	_, ok = CutPathPrefix("/Users/Public/file.txt", "/users/", false)
+	assert.False(t, ok)
+
+	_, ok = CutPathPrefix("/Us", "/users/", true)
+	assert.False(t, ok)
+}
+
+func TestRootedMatcherCaseVaryingRoot(t *testing.T) {
+	m, err := NewMatcher(ResolveFilters(nil, []string{"AppData"}, true))
+	require.NoError(t, err)
+
+	// The job subpath is typed as "users", the listing names "Users".
+	rooted := NewRootedMatcher(m, "users", true)
+	assert.True(t, rooted.Excluded("Users/Admin/appdata", true))
+	assert.True(t, rooted.Excluded(`USERS\Admin\AppData`, true))
+	assert.False(t, rooted.Excluded("Users/Admin/Documents", true))
+
+	assert.False(t, NewRootedMatcher(m, "users", false).Excluded("Users/Admin/AppData", true))
+}
@@ -0,0 +1,218 @@
+package pattern
+
+import "strings"
+
+// Filter precedence
+//
+// Exclusions are handed to proxmox-backup-client, which decides every path by
+// the last pattern that matches it. Rules are therefore ordered from weakest
+// to strongest:
+//
+//  1. global exclusions
+//  2. job exclusions
+//
+// so a job can re-include ("!pattern") what a global exclusion removes, and
+// within each source later entries win over earlier ones. A pattern listed
+// more than once only keeps its strongest position, which does not change
+// the outcome. When the same path is both excluded and re-included, the
+// strongest rule wins and the conflict is reported.
+//
+// proxmox-backup-client only understands globs. Literal patterns are passed
+// to it escaped; regex patterns are not passed at all and must be applied by
+// whatever presents the source to the client (see Matcher).
+
+// FilterSource tells where an exclusion rule was configured.
+type FilterSource string
+
+const (
+	FilterSourceGlobal FilterSource = "global"
+	FilterSourceJob    FilterSource = "job"
+)
+
+// FilterRule is a single resolved exclusion rule.
+type FilterRule struct {
+	// Pattern as configured by the user.
+	Pattern string `json:"pattern"`
+	// Arg is the pattern as passed to --exclude.
+	Arg       string       `json:"arg"`
+	Source    FilterSource `json:"source"`
+	Include   bool         `json:"include"`
+	MatchType string       `json:"match_type"`
+	// CaseInsensitive tells whether Arg matches regardless of case.
+	CaseInsensitive bool `json:"case_insensitive"`
+}
+
+// FilterConflict reports a path that is both excluded and re-included.
+type FilterConflict struct {
+	Path       string       `json:"path"`
+	Winner     FilterRule   `json:"winner"`
+	Overridden []FilterRule `json:"overridden"`
+}
+
+// EffectiveFilters is the merged, deduplicated and ordered filter set of a
+// job.
+type EffectiveFilters struct {
+	Rules     []FilterRule     `json:"rules"`
+	Conflicts []FilterConflict `json:"conflicts"`
+}
+
+// Args returns the rules as proxmox-backup-client arguments. Regex rules
+// are left out.
+func (f EffectiveFilters) Args() []string {
+	args := make([]string, 0, len(f.Rules)*2)
+	for _, rule := range f.Rules {
+		if rule.MatchType == MatchRegex {
+			continue
+		}
+		args = append(args, "--exclude", rule.Arg)
+	}
+	return args
+}
+
+// HasRegex reports whether any rule is a regex, which proxmox-backup-client
+// cannot apply on its own.
+func (f EffectiveFilters) HasRegex() bool {
+	for _, rule := range f.Rules {
+		if rule.MatchType == MatchRegex {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveFilters merges global and job glob exclusions following the
+// precedence order above.
+func ResolveFilters(global []string, job []string, caseInsensitive bool) EffectiveFilters {
+	return ResolvePatterns(Globs(global), Globs(job), caseInsensitive)
+}
+
+// ResolvePatterns is ResolveFilters for exclusions of any match type.
+// caseInsensitive applies to the patterns that do not set CaseSensitive.
+// Patterns with an unknown match type are dropped.
+func ResolvePatterns(global []Pattern, job []Pattern, caseInsensitive bool) EffectiveFilters {
+	var candidates []FilterRule
+	add := func(patterns []Pattern, source FilterSource) {
+		for _, p := range patterns {
+			value := strings.TrimSpace(p.Value)
+			if value == "" || value == "!" {
+				continue
+			}
+			matchType, err := NormalizeMatchType(p.MatchType)
+			if err != nil {
+				continue
+			}
+			ci := p.caseInsensitive(caseInsensitive)
+			candidates = append(candidates, FilterRule{
+				Pattern:         value,
+				Arg:             clientPattern(value, matchType, ci),
+				Source:          source,
+				Include:         strings.HasPrefix(value, "!"),
+				MatchType:       matchType,
+				CaseInsensitive: ci,
+			})
+		}
+	}
+	add(global, FilterSourceGlobal)
+	add(job, FilterSourceJob)
+
+	// Group by the path a rule applies to, regardless of whether it
+	// excludes or re-includes it.
+	keys := make([]string, len(candidates))
+	last := make(map[string]int, len(candidates))
+	for i, rule := range candidates {
+		keys[i] = ruleKey(rule)
+		last[keys[i]] = i
+	}
+
+	var result EffectiveFilters
+	conflicts := make(map[string]*FilterConflict)
+	var conflictOrder []string
+
+	for i, rule := range candidates {
+		key := keys[i]
+		winnerIdx := last[key]
+		if winnerIdx == i {
+			result.Rules = append(result.Rules, rule)
+			continue
+		}
+
+		winner := candidates[winnerIdx]
+		if winner.Include == rule.Include {
+			continue
+		}
+
+		conflict, ok := conflicts[key]
+		if !ok {
+			conflict = &FilterConflict{
+				Path:   strings.TrimPrefix(winner.Pattern, "!"),
+				Winner: winner,
+			}
+			conflicts[key] = conflict
+			conflictOrder = append(conflictOrder, key)
+		}
+		conflict.Overridden = append(conflict.Overridden, rule)
+	}
+
+	for _, key := range conflictOrder {
+		result.Conflicts = append(result.Conflicts, *conflicts[key])
+	}
+
+	return result
+}
+
+// anchor makes a relative pattern match at any depth, the way
+// proxmox-backup-client treats patterns without a leading slash.
+func anchor(p string) string {
+	if !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "**/") {
+		return "**/" + p
+	}
+	return p
+}
+
+// ruleKey returns the key under which rules of the same match type and
+// pattern collide.
+func ruleKey(rule FilterRule) string {
+	body := strings.TrimPrefix(rule.Pattern, "!")
+	switch rule.MatchType {
+	case MatchRegex:
+		if rule.CaseInsensitive {
+			body = strings.ToLower(body)
+		}
+		return "regex:" + body
+	case MatchLiteral:
+		return "literal:" + NormalizeKey(anchor(NormalizePattern(body, MatchLiteral)), rule.CaseInsensitive)
+	}
+	return NormalizeKey(anchor(NormalizePattern(body, MatchGlob)), rule.CaseInsensitive)
+}
+
+// clientPattern converts a configured pattern into the --exclude argument,
+// or for regex rules into the expression the Matcher compiles.
+func clientPattern(p string, matchType string, caseInsensitive bool) string {
+	if matchType == MatchRegex {
+		if caseInsensitive {
+			if body, ok := strings.CutPrefix(p, "!"); ok {
+				return "!(?i)" + body
+			}
+			return "(?i)" + p
+		}
+		return p
+	}
+
+	if matchType == MatchLiteral {
+		if body, ok := strings.CutPrefix(p, "!"); ok {
+			p = "!" + EscapeGlob(body)
+		} else {
+			p = EscapeGlob(p)
+		}
+	}
+
+	if !strings.HasPrefix(p, "!") {
+		p = anchor(p)
+	}
+	// Windows volumes resolve names case-insensitively, so "Users" and
+	// "users" are the same exclusion and both must match any casing.
+	if caseInsensitive {
+		p = CaseInsensitive(p)
+	}
+	return p
+}
@@ -0,0 +1,156 @@
+package pattern
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveFiltersOrder(t *testing.T) {
+	filters := ResolveFilters(
+		[]string{"*.tmp", "/proc"},
+		[]string{"node_modules", "/home/user/cache"},
+		false,
+	)
+
+	require.Empty(t, filters.Conflicts)
+	assert.Equal(t, []string{
+		"--exclude", "**/*.tmp",
+		"--exclude", "/proc",
+		"--exclude", "**/node_modules",
+		"--exclude", "/home/user/cache",
+	}, filters.Args())
+	assert.Equal(t, FilterSourceGlobal, filters.Rules[0].Source)
+	assert.Equal(t, FilterSourceJob, filters.Rules[3].Source)
+}
+
+func TestResolveFiltersDedupesToStrongestPosition(t *testing.T) {
+	filters := ResolveFilters(
+		[]string{"*.log", "/tmp"},
+		[]string{"**/*.log", "cache"},
+		false,
+	)
+
+	require.Empty(t, filters.Conflicts)
+	require.Len(t, filters.Rules, 3)
+	assert.Equal(t, "/tmp", filters.Rules[0].Arg)
+	assert.Equal(t, "**/*.log", filters.Rules[1].Arg)
+	assert.Equal(t, FilterSourceJob, filters.Rules[1].Source)
+	assert.Equal(t, "**/cache", filters.Rules[2].Arg)
+}
+
+func TestResolveFiltersJobOverridesGlobal(t *testing.T) {
+	filters := ResolveFilters(
+		[]string{"Downloads"},
+		[]string{"!Downloads"},
+		false,
+	)
+
+	require.Len(t, filters.Rules, 1)
+	assert.True(t, filters.Rules[0].Include)
+	assert.Equal(t, FilterSourceJob, filters.Rules[0].Source)
+
+	require.Len(t, filters.Conflicts, 1)
+	conflict := filters.Conflicts[0]
+	assert.Equal(t, "Downloads", conflict.Path)
+	assert.True(t, conflict.Winner.Include)
+	require.Len(t, conflict.Overridden, 1)
+	assert.Equal(t, FilterSourceGlobal, conflict.Overridden[0].Source)
+	assert.False(t, conflict.Overridden[0].Include)
+}
+
+func TestResolveFiltersLaterJobEntryWins(t *testing.T) {
+	filters := ResolveFilters(nil, []string{"!/data/keep", "/data/keep"}, false)
+
+	require.Len(t, filters.Rules, 1)
+	assert.False(t, filters.Rules[0].Include)
+	require.Len(t, filters.Conflicts, 1)
+	assert.True(t, filters.Conflicts[0].Overridden[0].Include)
+}
+
+func TestResolveFiltersCaseInsensitive(t *testing.T) {
+	filters := ResolveFilters([]string{"Users"}, []string{"users", "!USERS"}, true)
+
+	require.Len(t, filters.Rules, 1)
+	assert.Equal(t, "![uU][sS][eE][rR][sS]", filters.Rules[0].Arg)
+	require.Len(t, filters.Conflicts, 1)
+	assert.Len(t, filters.Conflicts[0].Overridden, 2)
+
+	// The same patterns stay distinct on case-sensitive targets.
+	filters = ResolveFilters([]string{"Users"}, []string{"users"}, false)
+	assert.Len(t, filters.Rules, 2)
+	assert.Empty(t, filters.Conflicts)
+}
+
+func TestResolvePatternsMatchTypes(t *testing.T) {
+	filters := ResolvePatterns(
+		[]Pattern{{Value: `\.tmp$`, MatchType: MatchRegex}},
+		[]Pattern{
+			{Value: "/data/report[1].pdf", MatchType: MatchLiteral},
+			{Value: "cache"},
+			{Value: "x", MatchType: "fuzzy"},
+		},
+		false,
+	)
+
+	require.Len(t, filters.Rules, 3)
+	assert.Equal(t, MatchRegex, filters.Rules[0].MatchType)
+	assert.Equal(t, MatchGlob, filters.Rules[2].MatchType)
+	assert.True(t, filters.HasRegex())
+	assert.Equal(t, []string{
+		"--exclude", `/data/report\[1\].pdf`,
+		"--exclude", "**/cache",
+	}, filters.Args())
+}
+
+func TestValidatePattern(t *testing.T) {
+	assert.NoError(t, ValidatePattern("*.tmp", ""))
+	assert.NoError(t, ValidatePattern(`^/var/log/.*\.gz$`, MatchRegex))
+	assert.NoError(t, ValidatePattern("!/keep/[this]", MatchLiteral))
+	assert.Error(t, ValidatePattern("[unterminated", MatchGlob))
+	assert.Error(t, ValidatePattern(`(unclosed`, MatchRegex))
+	assert.Error(t, ValidatePattern("!", MatchLiteral))
+	assert.Error(t, ValidatePattern("*.tmp", "fuzzy"))
+}
+
+func TestNormalizePattern(t *testing.T) {
+	assert.Equal(t, "C:/Users/Foo/", NormalizePattern(`C:\Users\\Foo\\`, MatchGlob))
+	assert.Equal(t, "/var/log/*.gz", NormalizePattern(" /var//log///*.gz ", MatchGlob))
+	assert.Equal(t, "/a/b[1]", NormalizePattern(`\a\b[1]`, MatchLiteral))
+	assert.Equal(t, `^C:\\Temp\\`, NormalizePattern(`^C:\\Temp\\`, MatchRegex))
+	assert.Equal(t, NormalizePattern("C:/Foo//bar/", MatchGlob), NormalizePattern(`C:\Foo\bar\`, MatchGlob))
+}
+
+func TestResolvePatternsCaseSensitive(t *testing.T) {
+	sensitive, insensitive := true, false
+
+	t.Run("follows the target by default", func(t *testing.T) {
+		for _, caseInsensitive := range []bool{false, true} {
+			filters := ResolvePatterns(nil, []Pattern{{Value: "C:/Foo"}, {Value: "c:/foo"}}, caseInsensitive)
+			if caseInsensitive {
+				assert.Len(t, filters.Rules, 1, "paths differing in case are one exclusion")
+			} else {
+				assert.Len(t, filters.Rules, 2)
+			}
+		}
+	})
+
+	t.Run("flag overrides the target", func(t *testing.T) {
+		filters := ResolvePatterns(nil, []Pattern{
+			{Value: "/Foo", CaseSensitive: &sensitive},
+			{Value: "/bar", CaseSensitive: &insensitive},
+		}, true)
+		require.Len(t, filters.Rules, 2)
+		assert.Equal(t, "/Foo", filters.Rules[0].Arg)
+		assert.False(t, filters.Rules[0].CaseInsensitive)
+		assert.Equal(t, "/[bB][aA][rR]", filters.Rules[1].Arg)
+		assert.True(t, filters.Rules[1].CaseInsensitive)
+	})
+
+	t.Run("normalized forms collide", func(t *testing.T) {
+		filters := ResolvePatterns([]Pattern{{Value: "/data//cache/"}}, []Pattern{{Value: "/data/cache/"}}, false)
+		require.Len(t, filters.Rules, 1)
+		assert.Equal(t, FilterSourceJob, filters.Rules[0].Source)
+	})
+}
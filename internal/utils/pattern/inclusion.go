@@ -0,0 +1,192 @@
+package pattern
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// PathFilter decides whether a path, absolute within the backup source, is
+// left out of the backup. Matcher and InclusionMatcher are PathFilters.
+type PathFilter interface {
+	Excluded(path string, isDir bool) bool
+}
+
+// combinedFilter excludes what any of its filters excludes.
+type combinedFilter []PathFilter
+
+func (c combinedFilter) Excluded(path string, isDir bool) bool {
+	for _, f := range c {
+		if f.Excluded(path, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Combine returns a filter excluding what any of filters excludes. Nil
+// filters are skipped; Combine returns nil when none is left.
+func Combine(filters ...PathFilter) PathFilter {
+	var combined combinedFilter
+	for _, f := range filters {
+		if f == nil {
+			continue
+		}
+		if m, ok := f.(*Matcher); ok && m == nil {
+			continue
+		}
+		if m, ok := f.(*InclusionMatcher); ok && m == nil {
+			continue
+		}
+		combined = append(combined, f)
+	}
+	switch len(combined) {
+	case 0:
+		return nil
+	case 1:
+		return combined[0]
+	}
+	return combined
+}
+
+type inclusionRule struct {
+	glob    glob.Glob
+	re      *regexp.Regexp
+	dirOnly bool
+	// segments are the per-directory globs of the pattern, used to tell
+	// whether a directory may lead to a match. A nil entry stands for "**".
+	segments []glob.Glob
+}
+
+func (r inclusionRule) match(path string, isDir bool) bool {
+	if r.re != nil {
+		return r.re.MatchString(path)
+	}
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return r.glob.Match(path)
+}
+
+// leadsTo reports whether directory dir may contain a path the rule
+// matches.
+func (r inclusionRule) leadsTo(dir string) bool {
+	if r.re != nil {
+		return true
+	}
+
+	parts := strings.Split(strings.Trim(dir, "/"), "/")
+	if parts[0] == "" {
+		parts = nil
+	}
+	for i, part := range parts {
+		if i >= len(r.segments) {
+			return false
+		}
+		if r.segments[i] == nil {
+			return true
+		}
+		if !r.segments[i].Match(part) {
+			return false
+		}
+	}
+	return len(parts) < len(r.segments)
+}
+
+// InclusionMatcher restricts a backup to the paths matching its patterns.
+// A matching directory is included with its whole subtree, and the
+// directories leading to a possible match are kept so it can be reached.
+// Exclusions are applied on top of it, see Combine.
+type InclusionMatcher struct {
+	rules []inclusionRule
+}
+
+// NewInclusionMatcher compiles inclusion patterns, which are written like
+// exclusions but cannot be negated. It returns nil when patterns is empty,
+// meaning everything is included.
+func NewInclusionMatcher(patterns []Pattern, caseInsensitive bool) (*InclusionMatcher, error) {
+	m := &InclusionMatcher{}
+	for _, p := range patterns {
+		value := strings.TrimSpace(p.Value)
+		if value == "" {
+			continue
+		}
+		if strings.HasPrefix(value, "!") {
+			return nil, fmt.Errorf("inclusion %q cannot be negated, use an exclusion instead", value)
+		}
+		matchType, err := NormalizeMatchType(p.MatchType)
+		if err != nil {
+			return nil, err
+		}
+
+		if matchType == MatchRegex {
+			re, err := regexp.Compile(clientPattern(value, matchType, caseInsensitive))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", value, err)
+			}
+			m.rules = append(m.rules, inclusionRule{re: re})
+			continue
+		}
+
+		arg := clientPattern(value, matchType, caseInsensitive)
+		dirOnly := strings.HasSuffix(arg, "/") && arg != "/"
+		arg = strings.TrimSuffix(arg, "/")
+
+		g, err := glob.Compile(arg, '/')
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", value, err)
+		}
+		rule := inclusionRule{glob: g, dirOnly: dirOnly}
+		for _, segment := range strings.Split(strings.TrimPrefix(arg, "/"), "/") {
+			if strings.Contains(segment, "**") {
+				rule.segments = append(rule.segments, nil)
+				continue
+			}
+			sg, err := glob.Compile(segment)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", value, err)
+			}
+			rule.segments = append(rule.segments, sg)
+		}
+		m.rules = append(m.rules, rule)
+	}
+
+	if len(m.rules) == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// Excluded reports whether path, absolute within the backup source, falls
+// outside the included set. A nil InclusionMatcher includes everything.
+func (m *InclusionMatcher) Excluded(path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	for _, rule := range m.rules {
+		if rule.match(path, isDir) {
+			return false
+		}
+		// Below an included directory.
+		for dir := path; ; {
+			i := strings.LastIndexByte(dir, '/')
+			if i <= 0 {
+				break
+			}
+			dir = dir[:i]
+			if rule.match(dir, true) {
+				return false
+			}
+		}
+		if isDir && rule.leadsTo(path) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package pattern
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInclusionMatcher(t *testing.T) {
+	m, err := NewInclusionMatcher([]Pattern{
+		{Value: "/home/*/Documents"},
+		{Value: "/srv/[draft]", MatchType: MatchLiteral},
+	}, false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		// Directories leading to an inclusion are kept.
+		{"/", true, false},
+		{"/home", true, false},
+		{"/home/alice", true, false},
+		{"/home/alice/Documents", true, false},
+		{"/home/alice/Documents/report.odt", false, false},
+		{"/home/alice/Documents/2024/q1.odt", false, false},
+		{"/home/alice/Music", true, true},
+		{"/home/alice/notes.txt", false, true},
+		{"/var", true, true},
+		{"/srv/[draft]/a", false, false},
+		{"/srv/d", false, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.excluded, m.Excluded(tt.path, tt.isDir), tt.path)
+	}
+}
+
+func TestInclusionMatcherUnanchored(t *testing.T) {
+	m, err := NewInclusionMatcher([]Pattern{
+		{Value: "*.pst"},
+		{Value: `/mail/.*\.eml$`, MatchType: MatchRegex},
+	}, false)
+	require.NoError(t, err)
+
+	// Matches may be at any depth, so every directory is kept.
+	assert.False(t, m.Excluded("/var/mail", true))
+	assert.False(t, m.Excluded("/var/mail/archive.pst", false))
+	assert.False(t, m.Excluded("/home/mail/inbox/1.eml", false))
+	assert.True(t, m.Excluded("/var/mail/other.txt", false))
+}
+
+func TestInclusionMatcherAnchoredOnly(t *testing.T) {
+	m, err := NewInclusionMatcher([]Pattern{{Value: "/data/projects/"}}, true)
+	require.NoError(t, err)
+
+	assert.False(t, m.Excluded("/DATA", true))
+	assert.False(t, m.Excluded("/data/Projects", true))
+	assert.True(t, m.Excluded("/data/projects", false), "a trailing slash only includes directories")
+	assert.True(t, m.Excluded("/data/other", true))
+	assert.True(t, m.Excluded("/etc", true))
+	assert.True(t, m.Excluded("/readme", false))
+}
+
+func TestInclusionMatcherEmpty(t *testing.T) {
+	m, err := NewInclusionMatcher(nil, false)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+	assert.False(t, m.Excluded("/anything", false))
+	assert.Nil(t, Combine(nil, m))
+
+	_, err = NewInclusionMatcher([]Pattern{{Value: "!/data"}}, false)
+	assert.Error(t, err)
+}
+
+func TestCombineInclusionsAndExclusions(t *testing.T) {
+	inclusions, err := NewInclusionMatcher([]Pattern{{Value: "/home/*/Documents"}}, false)
+	require.NoError(t, err)
+	exclusions, err := NewMatcher(ResolveFilters([]string{"*.tmp"}, []string{"/home/bob"}, false))
+	require.NoError(t, err)
+
+	f := Combine(exclusions, inclusions)
+	assert.False(t, f.Excluded("/home/alice/Documents/a.odt", false))
+	assert.True(t, f.Excluded("/home/alice/Documents/a.tmp", false), "exclusions apply within inclusions")
+	assert.True(t, f.Excluded("/home/bob", true))
+	assert.True(t, f.Excluded("/etc/passwd", false))
+}
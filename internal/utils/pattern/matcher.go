@@ -0,0 +1,82 @@
+package pattern
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+type compiledRule struct {
+	glob    glob.Glob
+	re      *regexp.Regexp
+	include bool
+	dirOnly bool
+}
+
+func (r compiledRule) match(path string) bool {
+	if r.re != nil {
+		return r.re.MatchString(path)
+	}
+	return r.glob.Match(path)
+}
+
+// Matcher applies a resolved filter set to paths the way
+// proxmox-backup-client does: the last rule matching a path decides it, and
+// an excluded directory takes its whole subtree with it.
+type Matcher struct {
+	rules []compiledRule
+}
+
+// NewMatcher compiles the rules of filters. Regex rules are compiled once
+// here and matched anywhere in the path unless anchored.
+func NewMatcher(filters EffectiveFilters) (*Matcher, error) {
+	m := &Matcher{rules: make([]compiledRule, 0, len(filters.Rules))}
+	for _, rule := range filters.Rules {
+		p := strings.TrimPrefix(rule.Arg, "!")
+		if rule.MatchType == MatchRegex {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", rule.Pattern, err)
+			}
+			m.rules = append(m.rules, compiledRule{re: re, include: rule.Include})
+			continue
+		}
+
+		if rule.Include {
+			p = anchor(p)
+		}
+
+		// A trailing slash restricts the rule to directories.
+		dirOnly := strings.HasSuffix(p, "/") && p != "/"
+		p = strings.TrimSuffix(p, "/")
+
+		g, err := glob.Compile(p, '/')
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", rule.Pattern, err)
+		}
+		m.rules = append(m.rules, compiledRule{glob: g, include: rule.Include, dirOnly: dirOnly})
+	}
+	return m, nil
+}
+
+// Excluded reports whether path, absolute within the backup source (e.g.
+// "/Users/foo"), is excluded. Callers walking a tree do not descend into
+// excluded directories.
+func (m *Matcher) Excluded(path string, isDir bool) bool {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		rule := m.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.match(path) {
+			return !rule.include
+		}
+	}
+	return false
+}
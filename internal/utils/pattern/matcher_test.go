@@ -0,0 +1,119 @@
+package pattern
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcher(t *testing.T) {
+	filters := ResolveFilters(
+		[]string{"*.tmp", "/proc", "cache/"},
+		[]string{"!keep.tmp", "/home/*/Downloads"},
+		false,
+	)
+	m, err := NewMatcher(filters)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		{"/a.tmp", false, true},
+		{"/deep/dir/b.tmp", false, true},
+		{"/deep/dir/keep.tmp", false, false},
+		{"/proc", true, true},
+		{"/srv/proc", true, false},
+		{"/var/cache", true, true},
+		{"/var/cache", false, false},
+		{"/home/alice/Downloads", true, true},
+		{"/home/alice/Documents", true, false},
+		{"home/alice/Downloads", true, true},
+		{"/a.txt", false, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.excluded, m.Excluded(tt.path, tt.isDir), tt.path)
+	}
+}
+
+func TestMatcherCaseInsensitive(t *testing.T) {
+	m, err := NewMatcher(ResolveFilters(nil, []string{"Users/*/AppData"}, true))
+	require.NoError(t, err)
+
+	assert.True(t, m.Excluded("/users/bob/appdata", true))
+	assert.True(t, m.Excluded("/USERS/bob/AppData", true))
+	assert.False(t, m.Excluded("/users/bob/Documents", true))
+}
+
+func TestMatcherInvalidPattern(t *testing.T) {
+	_, err := NewMatcher(ResolveFilters(nil, []string{"[unterminated"}, false))
+	assert.Error(t, err)
+}
+
+func TestMatcherMatchTypes(t *testing.T) {
+	filters := ResolvePatterns(
+		[]Pattern{
+			{Value: `\.(tmp|bak)$`, MatchType: MatchRegex},
+			{Value: "/data/[draft]*", MatchType: MatchLiteral},
+		},
+		[]Pattern{
+			{Value: "*.log"},
+			{Value: `!^/keep/`, MatchType: MatchRegex},
+		},
+		false,
+	)
+	m, err := NewMatcher(filters)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path     string
+		excluded bool
+	}{
+		{"/a.tmp", true},
+		{"/deep/b.bak", true},
+		{"/a.tmpx", false},
+		{"/keep/a.tmp", false},
+		{"/data/[draft]*", true},
+		{"/data/d", false},
+		{"/data/draft", false},
+		{"/var/x.log", true},
+		{"/keep/x.log", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.excluded, m.Excluded(tt.path, false), tt.path)
+	}
+}
+
+func TestMatcherRegexCaseInsensitive(t *testing.T) {
+	m, err := NewMatcher(ResolvePatterns(nil, []Pattern{{Value: `/thumbs\.db$`, MatchType: MatchRegex}}, true))
+	require.NoError(t, err)
+
+	assert.True(t, m.Excluded("/Photos/Thumbs.db", false))
+	assert.False(t, m.Excluded("/Photos/Thumbs.dbx", false))
+}
+
+func TestMatcherCaseSensitiveFlag(t *testing.T) {
+	sensitive, insensitive := true, false
+	patterns := []Pattern{
+		{Value: "/Users/*/AppData", CaseSensitive: &insensitive},
+		{Value: "/Build", CaseSensitive: &sensitive},
+		{Value: `/cache\.db$`, MatchType: MatchRegex, CaseSensitive: &sensitive},
+		{Value: "/Temp"},
+	}
+
+	for _, targetInsensitive := range []bool{false, true} {
+		m, err := NewMatcher(ResolvePatterns(nil, patterns, targetInsensitive))
+		require.NoError(t, err)
+
+		assert.True(t, m.Excluded("/Users/alice/AppData", true))
+		assert.True(t, m.Excluded("/users/alice/appdata", true))
+		assert.True(t, m.Excluded("/Build", true))
+		assert.False(t, m.Excluded("/build", true))
+		assert.True(t, m.Excluded("/x/cache.db", false))
+		assert.False(t, m.Excluded("/x/Cache.db", false))
+		assert.True(t, m.Excluded("/Temp", true))
+		assert.Equal(t, targetInsensitive, m.Excluded("/temp", true), "unset flag follows the target")
+	}
+}
@@ -0,0 +1,155 @@
+package pattern
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// Match types of an exclusion. An exclusion without a match type is a glob,
+// which is how every exclusion was interpreted before match types existed.
+const (
+	MatchGlob    = "glob"
+	MatchRegex   = "regex"
+	MatchLiteral = "literal"
+)
+
+// Pattern is a configured exclusion together with its match type. A leading
+// "!" re-includes what the pattern matches, whatever the match type.
+type Pattern struct {
+	Value     string
+	MatchType string
+	// CaseSensitive overrides the case sensitivity of the target when set.
+	CaseSensitive *bool
+}
+
+// caseInsensitive tells whether p matches regardless of case on a target
+// that is case-insensitive or not.
+func (p Pattern) caseInsensitive(target bool) bool {
+	if p.CaseSensitive != nil {
+		return !*p.CaseSensitive
+	}
+	return target
+}
+
+// Globs wraps plain glob patterns.
+func Globs(patterns []string) []Pattern {
+	result := make([]Pattern, 0, len(patterns))
+	for _, p := range patterns {
+		result = append(result, Pattern{Value: p, MatchType: MatchGlob})
+	}
+	return result
+}
+
+// NormalizeMatchType returns the match type matchType stands for, MatchGlob
+// when it is empty.
+func NormalizeMatchType(matchType string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(matchType)) {
+	case "", MatchGlob:
+		return MatchGlob, nil
+	case MatchRegex:
+		return MatchRegex, nil
+	case MatchLiteral:
+		return MatchLiteral, nil
+	default:
+		return "", fmt.Errorf("unknown match type %q", matchType)
+	}
+}
+
+// ValidatePattern checks that p is a valid pattern of the given match type.
+func ValidatePattern(p string, matchType string) error {
+	matchType, err := NormalizeMatchType(matchType)
+	if err != nil {
+		return err
+	}
+
+	body := strings.TrimPrefix(p, "!")
+	if body == "" {
+		return fmt.Errorf("empty pattern")
+	}
+
+	switch matchType {
+	case MatchRegex:
+		if _, err := regexp.Compile(body); err != nil {
+			return fmt.Errorf("invalid regular expression %q: %w", body, err)
+		}
+	case MatchGlob:
+		if _, err := glob.Compile(p); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// NormalizePattern returns the form under which p is stored. Glob and
+// literal patterns get forward slashes, with runs of separators collapsed
+// into one; a trailing slash, which limits the pattern to directories, is
+// kept. Regex patterns are returned as is, as their backslashes are escapes.
+func NormalizePattern(p string, matchType string) string {
+	p = strings.TrimSpace(p)
+	if matchType == MatchRegex {
+		return p
+	}
+
+	p = strings.ReplaceAll(p, "\\", "/")
+	var b strings.Builder
+	b.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
+
+// EscapeGlob escapes the glob metacharacters of s, so the result only
+// matches s itself.
+func EscapeGlob(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '{', '}', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ParseRaw reads one line of a job's raw exclusion list. Lines may start
+// with "regex:" or "literal:" to set the match type; other lines are globs.
+func ParseRaw(line string) Pattern {
+	for _, matchType := range []string{MatchRegex, MatchLiteral} {
+		if value, ok := strings.CutPrefix(line, matchType+":"); ok {
+			return Pattern{Value: value, MatchType: matchType}
+		}
+	}
+	return Pattern{Value: line, MatchType: MatchGlob}
+}
+
+// ParseRawList reads a newline-separated list of raw patterns, skipping
+// blank lines.
+func ParseRawList(raw string) []Pattern {
+	var patterns []Pattern
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		patterns = append(patterns, ParseRaw(line))
+	}
+	return patterns
+}
+
+// FormatRaw is the inverse of ParseRaw.
+func FormatRaw(p Pattern) string {
+	switch p.MatchType {
+	case MatchRegex, MatchLiteral:
+		return p.MatchType + ":" + p.Value
+	}
+	return p.Value
+}
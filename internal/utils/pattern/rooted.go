@@ -0,0 +1,36 @@
+package pattern
+
+import "path"
+
+// RootedMatcher applies a PathFilter to the entries of a listing whose patterns
+// are written relative to root, the source directory handed to
+// proxmox-backup-client. Entries outside root are never excluded.
+type RootedMatcher struct {
+	matcher         PathFilter
+	root            string
+	caseInsensitive bool
+}
+
+// NewRootedMatcher applies m below root. On case-insensitive sources, entry
+// paths that differ from root only in case are still below it.
+func NewRootedMatcher(m PathFilter, root string, caseInsensitive bool) *RootedMatcher {
+	return &RootedMatcher{
+		matcher:         m,
+		root:            path.Clean("/" + NormalizeKey(root, false)),
+		caseInsensitive: caseInsensitive,
+	}
+}
+
+// Excluded reports whether entryPath, relative to the top of the listing, is
+// excluded.
+func (r *RootedMatcher) Excluded(entryPath string, isDir bool) bool {
+	p := path.Clean("/" + NormalizeKey(entryPath, false))
+	if r.root != "/" {
+		rest, ok := CutPathPrefix(p, r.root+"/", r.caseInsensitive)
+		if !ok {
+			return false
+		}
+		p = "/" + rest
+	}
+	return r.matcher.Excluded(p, isDir)
+}
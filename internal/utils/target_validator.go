@@ -1,29 +1,116 @@
 package utils
 
 import (
+	"fmt"
 	"net"
-	"regexp"
 	"strings"
 )
 
-func ValidateTargetPath(path string) bool {
-	if strings.HasPrefix(path, "agent://") {
-		trimmed := strings.TrimPrefix(path, "agent://")
+// AgentScheme prefixes the path of the targets backed up through an agent.
+const AgentScheme = "agent://"
+
+// AgentTarget is the parsed path of an agent target. Windows agents name a
+// drive by its letter, as in agent://10.0.0.5/C; Linux agents by its mount
+// point, as in agent://10.0.0.5//mnt/data.
+type AgentTarget struct {
+	// Host is the IP address of the agent.
+	Host string
+	// Drive is a drive letter or an absolute mount point.
+	Drive string
+	// Subpath is what follows a drive letter, without surrounding slashes.
+	Subpath string
+}
+
+// IsAgentPath reports whether path uses the agent scheme, whether or not it
+// is well formed.
+func IsAgentPath(path string) bool {
+	return strings.HasPrefix(path, AgentScheme)
+}
+
+// ParseAgentTarget parses the path of an agent target.
+func ParseAgentTarget(path string) (AgentTarget, error) {
+	rest, ok := strings.CutPrefix(path, AgentScheme)
+	if !ok {
+		return AgentTarget{}, fmt.Errorf("malformed agent target %q: missing %s scheme", path, AgentScheme)
+	}
 
-		parts := strings.Split(trimmed, "/")
-		if len(parts) != 2 {
-			return false
+	host, drive, ok := strings.Cut(rest, "/")
+	if !ok || drive == "" {
+		return AgentTarget{}, fmt.Errorf("malformed agent target %q: missing drive", path)
+	}
+	if net.ParseIP(host) == nil {
+		return AgentTarget{}, fmt.Errorf("malformed agent target %q: host %q is not an IP address", path, host)
+	}
+
+	target := AgentTarget{Host: host}
+	if strings.HasPrefix(drive, "/") {
+		if strings.Contains(drive, "//") || (drive != "/" && strings.HasSuffix(drive, "/")) {
+			return AgentTarget{}, fmt.Errorf("malformed agent target %q: mount point %q is not clean", path, drive)
 		}
+		target.Drive = drive
+		return target, nil
+	}
 
-		ip, driveLetter := parts[0], parts[1]
+	drive, subpath, _ := strings.Cut(drive, "/")
+	if !isDriveLetter(drive) {
+		return AgentTarget{}, fmt.Errorf("malformed agent target %q: %q is not a drive letter", path, drive)
+	}
+	target.Drive = drive
+	target.Subpath = strings.Trim(subpath, "/")
+	return target, nil
+}
 
-		if net.ParseIP(ip) == nil {
-			return false
+// String formats t back into a target path.
+func (t AgentTarget) String() string {
+	path := AgentScheme + t.Host + "/" + t.Drive
+	if t.Subpath != "" {
+		path += "/" + t.Subpath
+	}
+	return path
+}
+
+// IsDriveLetter reports whether the drive of t is a Windows drive letter.
+func (t AgentTarget) IsDriveLetter() bool {
+	return isDriveLetter(t.Drive)
+}
+
+// CheckTargetPath tells why path is not a valid target path. Targets are
+// absolute local paths or agent targets naming a whole drive; narrowing the
+// source down to a subpath is up to the jobs of the target.
+func CheckTargetPath(path string) error {
+	if !IsAgentPath(path) {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("%q is not an absolute path", path)
 		}
+		return nil
+	}
 
-		driveLetterPattern := regexp.MustCompile(`^[a-zA-Z]$`)
-		return driveLetterPattern.MatchString(driveLetter)
+	target, err := ParseAgentTarget(path)
+	if err != nil {
+		return err
+	}
+	if target.Subpath != "" {
+		return fmt.Errorf("agent target %q: subpath %q belongs to the job", path, target.Subpath)
 	}
+	return nil
+}
 
-	return strings.HasPrefix(path, "/")
+func ValidateTargetPath(path string) bool {
+	return CheckTargetPath(path) == nil
+}
+
+// IsCaseInsensitiveTarget reports whether the target path points to a
+// filesystem that resolves names case-insensitively. Agent targets addressed
+// by a drive letter are Windows volumes.
+func IsCaseInsensitiveTarget(path string) bool {
+	target, err := ParseAgentTarget(path)
+	return err == nil && target.IsDriveLetter()
+}
+
+func isDriveLetter(drive string) bool {
+	if len(drive) != 1 {
+		return false
+	}
+	c := drive[0]
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
 }
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAgentTarget(t *testing.T) {
+	valid := []struct {
+		path   string
+		target AgentTarget
+	}{
+		{"agent://10.0.0.5/C", AgentTarget{Host: "10.0.0.5", Drive: "C"}},
+		{"agent://10.0.0.5/d", AgentTarget{Host: "10.0.0.5", Drive: "d"}},
+		{"agent://fe80::1/C", AgentTarget{Host: "fe80::1", Drive: "C"}},
+		{"agent://10.0.0.5//", AgentTarget{Host: "10.0.0.5", Drive: "/"}},
+		{"agent://10.0.0.5//mnt/data", AgentTarget{Host: "10.0.0.5", Drive: "/mnt/data"}},
+		{"agent://10.0.0.5/C/Users/alice", AgentTarget{Host: "10.0.0.5", Drive: "C", Subpath: "Users/alice"}},
+	}
+	for _, tt := range valid {
+		target, err := ParseAgentTarget(tt.path)
+		if assert.NoError(t, err, tt.path) {
+			assert.Equal(t, tt.target, target, tt.path)
+			assert.Equal(t, tt.path, target.String(), "%s formats back", tt.path)
+		}
+		assert.Equal(t, tt.target.Subpath == "", ValidateTargetPath(tt.path), "%s: a target names a whole drive", tt.path)
+	}
+
+	malformed := []string{
+		"agent://",
+		"agent://10.0.0.5",
+		"agent://10.0.0.5/",
+		"agent:///C",
+		"agent://server/C",
+		"agent://10.0.0/C",
+		"agent://10.0.0.5/CD",
+		"agent://10.0.0.5/1",
+		"agent://10.0.0.5/É",
+		"agent://10.0.0.5//mnt//data",
+		"agent://10.0.0.5//mnt/data/",
+		"/mnt/data",
+	}
+	for _, path := range malformed {
+		_, err := ParseAgentTarget(path)
+		assert.Error(t, err, path)
+		if IsAgentPath(path) {
+			assert.False(t, ValidateTargetPath(path), path)
+		}
+	}
+
+	assert.True(t, ValidateTargetPath("/mnt/data"))
+	assert.Error(t, CheckTargetPath("mnt/data"))
+}
+
+func TestIsCaseInsensitiveTarget(t *testing.T) {
+	assert.True(t, IsCaseInsensitiveTarget("agent://10.0.0.5/C"))
+	assert.False(t, IsCaseInsensitiveTarget("agent://10.0.0.5//"))
+	assert.False(t, IsCaseInsensitiveTarget("agent://10.0.0.5//C"))
+	assert.False(t, IsCaseInsensitiveTarget("agent://10.0.0.5/CD"))
+	assert.False(t, IsCaseInsensitiveTarget("/mnt/C"))
+}
@@ -26,3 +26,35 @@ func ValidateOnCalendar(value string) error {
 
 	return nil
 }
+
+// SplitSchedules splits a job schedule into its calendar expressions.
+// Expressions are separated by newlines or ";". Commas cannot separate them
+// as OnCalendar uses commas itself (e.g. "mon,wed 18:00").
+func SplitSchedules(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ';'
+	})
+
+	schedules := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			schedules = append(schedules, field)
+		}
+	}
+	return schedules
+}
+
+// ValidateSchedules checks every calendar expression of a job schedule.
+func ValidateSchedules(value string) error {
+	schedules := SplitSchedules(value)
+	if len(schedules) == 0 {
+		return fmt.Errorf("calendar specification cannot be empty")
+	}
+
+	for _, schedule := range schedules {
+		if err := ValidateOnCalendar(schedule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package utils
+
+import "net/url"
+
+// IsValidWebhookURL reports whether rawURL is an absolute http or https URL.
+func IsValidWebhookURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}